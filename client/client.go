@@ -0,0 +1,579 @@
+// Package client is a Go client for the game server: it keeps a connection up, encodes
+// structured messages and dispatches what the server sends to registered handlers.
+// The message types, payloads and Send* helpers in messages_gen.go are generated from the
+// same definitions as the server's.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/iknizzz1807/socket-server-template/messages"
+)
+
+var (
+	ErrNotConnected = errors.New("not connected")
+	ErrClosed       = errors.New("client closed")
+)
+
+// ProtocolVersion is the protocol this client speaks, sent in HELLO (see WithHello)
+const ProtocolVersion = 1
+
+const (
+	defaultHeartbeat    = 30 * time.Second
+	defaultMinReconnect = 500 * time.Millisecond
+	defaultMaxReconnect = 30 * time.Second
+	writeWait           = 10 * time.Second
+
+	// reliableWindow is how many reliable ids are remembered to drop retried duplicates
+	reliableWindow = 1024
+)
+
+// Message is a structured message as it goes over the wire
+type Message struct {
+	Type     MessageType     `json:"type"`
+	PlayerID string          `json:"player_id"`
+	Payload  json.RawMessage `json:"payload"`
+	// Timestamp is when the message was sent in unix milliseconds, of the sender's clock
+	Timestamp int64 `json:"timestamp"`
+	// Seq numbers inputs, see SendInput
+	Seq uint64 `json:"seq,omitempty"`
+	// Ack is the last input the server processed
+	Ack uint64 `json:"ack,omitempty"`
+	// RTT is our round trip in milliseconds as the server measured it, on state messages
+	// when the server is configured to send it
+	RTT uint64 `json:"rtt,omitempty"`
+	// Version of the payload format, see MessageVersions
+	Version int `json:"v,omitempty"`
+	// ID is set on reliable messages, the client acks them and drops retried duplicates
+	ID uint64 `json:"id,omitempty"`
+	// DedupID is set by SendWithID, the server applies the message once per id
+	DedupID uint64 `json:"dedup,omitempty"`
+	// Req numbers a request, answered by a message with the same number in Re, see Call
+	Req uint64 `json:"req,omitempty"`
+	Re  uint64 `json:"re,omitempty"`
+	// ServerSeq numbers the messages of a connection from 1, see OnSequenceGap
+	ServerSeq uint64 `json:"sseq,omitempty"`
+}
+
+// Decode unmarshals the payload into v
+func (m Message) Decode(v interface{}) error {
+	return json.Unmarshal(m.Payload, v)
+}
+
+// Handler gets messages of the type it was registered for, on the client's read goroutine
+type Handler func(msg Message)
+
+type options struct {
+	header       http.Header
+	dialer       *websocket.Dialer
+	heartbeat    time.Duration
+	reconnect    bool
+	minReconnect time.Duration
+	maxReconnect time.Duration
+	onConnect    func()
+	onDisconnect func(err error)
+	onGap        func(want, got uint64)
+	onTransfer   func(p TransferProgress)
+	onVoice      func(f messages.VoiceFrame)
+	hello        *HelloPayload
+	handlers     map[MessageType][]Handler
+}
+
+type Option func(*options)
+
+// WithHeader sends extra headers on every dial, e.g. Origin or auth
+func WithHeader(h http.Header) Option {
+	return func(o *options) { o.header = h }
+}
+
+// WithDialer replaces websocket.DefaultDialer
+func WithDialer(d *websocket.Dialer) Option {
+	return func(o *options) { o.dialer = d }
+}
+
+// WithHeartbeat pings the server every interval and drops the connection when pongs stop
+// coming back, 30s by default and 0 turns it off
+func WithHeartbeat(interval time.Duration) Option {
+	return func(o *options) { o.heartbeat = interval }
+}
+
+// WithReconnect sets the backoff between reconnect attempts, doubling from min up to max
+func WithReconnect(min, max time.Duration) Option {
+	return func(o *options) {
+		o.reconnect = true
+		o.minReconnect, o.maxReconnect = min, max
+	}
+}
+
+// WithoutReconnect leaves the client closed once the connection drops
+func WithoutReconnect() Option {
+	return func(o *options) { o.reconnect = false }
+}
+
+// WithHandler registers a handler before the first message can arrive, unlike On which
+// may miss what the server sends right after connecting (CAPABILITIES, WELCOME)
+func WithHandler(msgType MessageType, h Handler) Option {
+	return func(o *options) {
+		if o.handlers == nil {
+			o.handlers = make(map[MessageType][]Handler)
+		}
+		o.handlers[msgType] = append(o.handlers[msgType], h)
+	}
+}
+
+// WithHello opens every connection with a HELLO asking for features, handle the server's
+// WELCOME with WithHandler
+func WithHello(clientName string, features ...string) Option {
+	return func(o *options) {
+		o.hello = &HelloPayload{ProtocolVersion: ProtocolVersion, Features: features, Client: clientName}
+	}
+}
+
+// OnConnect runs after every successful (re)connect
+func OnConnect(fn func()) Option {
+	return func(o *options) { o.onConnect = fn }
+}
+
+// OnDisconnect runs whenever the connection drops, before reconnecting
+func OnDisconnect(fn func(err error)) Option {
+	return func(o *options) { o.onDisconnect = fn }
+}
+
+// OnSequenceGap runs when a message doesn't carry the sseq after the last one: got is
+// past want when messages went missing and below it when they arrived out of order
+func OnSequenceGap(fn func(want, got uint64)) Option {
+	return func(o *options) { o.onGap = fn }
+}
+
+// Client is a connection to the game server that reconnects on its own
+type Client struct {
+	url  string
+	opts options
+
+	mu       sync.Mutex
+	conn     *websocket.Conn
+	playerID string
+
+	// writeMu serializes writes, gorilla/websocket allows one writer at a time
+	writeMu sync.Mutex
+
+	handlersMu sync.RWMutex
+	handlers   map[MessageType][]Handler
+	any        []Handler
+
+	seq atomic.Uint64
+	ids atomic.Uint64
+
+	// lastSeq is the last sseq received on the current connection
+	lastSeq atomic.Uint64
+
+	// Reliable ids seen recently, only touched by the read loop
+	seenIDs   map[uint64]struct{}
+	seenOrder []uint64
+
+	// Clock sync, see clock.go. clockOffset is in nanoseconds.
+	syncMu      sync.Mutex
+	timeSyncs   chan timeSyncReply
+	clockOffset atomic.Int64
+	// rtt is the round trip the server reported in nanoseconds, see rtt.go
+	rtt atomic.Int64
+
+	// Our requests waiting for an answer, see rpc.go
+	callsMu sync.Mutex
+	reqs    uint64
+	calls   map[uint64]chan Message
+
+	// Chunked transfers in progress, see transfers.go
+	transfersMu sync.Mutex
+	inbound     map[string]*inboundTransfer
+	outbound    map[string]chan string
+	transferIDs atomic.Uint64
+
+	closed    chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// Connect dials url (e.g. ws://localhost:8080/ws) and keeps the connection up until Close
+// Only the first dial fails Connect, later drops are retried in the background.
+func Connect(ctx context.Context, url string, opts ...Option) (*Client, error) {
+	c := &Client{
+		url: url,
+		opts: options{
+			dialer:       websocket.DefaultDialer,
+			heartbeat:    defaultHeartbeat,
+			reconnect:    true,
+			minReconnect: defaultMinReconnect,
+			maxReconnect: defaultMaxReconnect,
+		},
+		handlers:  make(map[MessageType][]Handler),
+		seenIDs:   make(map[uint64]struct{}),
+		calls:     make(map[uint64]chan Message),
+		inbound:   make(map[string]*inboundTransfer),
+		outbound:  make(map[string]chan string),
+		timeSyncs: make(chan timeSyncReply, 1),
+		closed:    make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+	for msgType, hs := range c.opts.handlers {
+		c.handlers[msgType] = hs
+	}
+	c.handlers[TimeSync] = append(c.handlers[TimeSync], c.onTimeSync)
+	c.handlers[Ping] = append(c.handlers[Ping], c.onPing)
+	c.handlers[TransferStart] = append(c.handlers[TransferStart], c.onTransferStart)
+	c.handlers[TransferChunk] = append(c.handlers[TransferChunk], c.onTransferChunk)
+	c.handlers[TransferAbort] = append(c.handlers[TransferAbort], c.onTransferAbort)
+
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	go c.run(conn)
+	return c, nil
+}
+
+func (c *Client) dial(ctx context.Context) (*websocket.Conn, error) {
+	conn, _, err := c.opts.dialer.DialContext(ctx, c.url, c.opts.header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", c.url, err)
+	}
+
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+
+	if c.opts.hello != nil {
+		if err := SendHello(c, *c.opts.hello); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to send hello: %v", err)
+		}
+	}
+	if c.opts.onConnect != nil {
+		c.opts.onConnect()
+	}
+	return conn, nil
+}
+
+// On registers a handler for a message type, handlers run in registration order
+func (c *Client) On(msgType MessageType, h Handler) {
+	c.handlersMu.Lock()
+	defer c.handlersMu.Unlock()
+	c.handlers[msgType] = append(c.handlers[msgType], h)
+}
+
+// OnAny registers a handler for every message
+func (c *Client) OnAny(h Handler) {
+	c.handlersMu.Lock()
+	defer c.handlersMu.Unlock()
+	c.any = append(c.any, h)
+}
+
+// PlayerID is the ID the server gave the current connection, it changes on reconnect
+func (c *Client) PlayerID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.playerID
+}
+
+// Send sends a structured message, it makes Client a Sender for the generated Send* helpers
+func (c *Client) Send(msgType MessageType, payload interface{}) error {
+	return c.send(Message{Type: msgType}, payload)
+}
+
+// SendStructuredMessage is Send, named like the server side
+func (c *Client) SendStructuredMessage(msgType MessageType, payload interface{}) error {
+	return c.Send(msgType, payload)
+}
+
+// NextID returns a new message id for SendWithID
+func (c *Client) NextID() uint64 {
+	return c.ids.Add(1)
+}
+
+// SendWithID sends a message the server applies once however often it is sent with the
+// same id, answering each copy with MESSAGE_ACK. Resend with the same id after a timeout.
+func (c *Client) SendWithID(id uint64, msgType MessageType, payload interface{}) error {
+	return c.send(Message{Type: msgType, DedupID: id}, payload)
+}
+
+// SendInput sends a message numbered with the next input sequence, the server acks it
+// in GAME_STATE_SYNC so predicted inputs can be reconciled
+func (c *Client) SendInput(msgType MessageType, payload interface{}) (uint64, error) {
+	seq := c.seq.Add(1)
+	return seq, c.send(Message{Type: msgType, Seq: seq}, payload)
+}
+
+func (c *Client) send(msg Message, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %v", err)
+	}
+	msg.Payload = data
+	msg.PlayerID = c.PlayerID()
+	msg.Timestamp = time.Now().UnixMilli()
+	msg.Version = MessageVersions[msg.Type]
+
+	frame, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %v", err)
+	}
+	return c.write(websocket.TextMessage, frame)
+}
+
+func (c *Client) write(messageType int, data []byte) error {
+	select {
+	case <-c.closed:
+		return ErrClosed
+	default:
+	}
+
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return ErrNotConnected
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return conn.WriteMessage(messageType, data)
+}
+
+// Close disconnects and stops reconnecting
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+
+		c.mu.Lock()
+		conn := c.conn
+		c.mu.Unlock()
+		if conn != nil {
+			c.writeMu.Lock()
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+			c.writeMu.Unlock()
+			conn.Close()
+		}
+	})
+	<-c.done
+	return nil
+}
+
+// Done is closed once the client stopped for good, after Close or a drop without reconnect
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// run reads from conn until it drops, then reconnects with backoff
+func (c *Client) run(conn *websocket.Conn) {
+	defer close(c.done)
+
+	for {
+		err := c.readLoop(conn)
+
+		c.mu.Lock()
+		c.conn = nil
+		c.mu.Unlock()
+		conn.Close()
+
+		select {
+		case <-c.closed:
+			return
+		default:
+		}
+		if c.opts.onDisconnect != nil {
+			c.opts.onDisconnect(err)
+		}
+		if !c.opts.reconnect {
+			return
+		}
+
+		if conn = c.reconnect(); conn == nil {
+			return
+		}
+	}
+}
+
+// reconnect dials until it works or the client is closed
+func (c *Client) reconnect() *websocket.Conn {
+	backoff := c.opts.minReconnect
+	for {
+		select {
+		case <-time.After(backoff):
+		case <-c.closed:
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+		conn, err := c.dial(ctx)
+		cancel()
+		if err == nil {
+			return conn
+		}
+		log.Printf("Reconnect failed, retrying in %v: %v", backoff, err)
+
+		if backoff *= 2; backoff > c.opts.maxReconnect {
+			backoff = c.opts.maxReconnect
+		}
+	}
+}
+
+func (c *Client) readLoop(conn *websocket.Conn) error {
+	stopHeartbeat := c.startHeartbeat(conn)
+	defer stopHeartbeat()
+
+	// The server numbers every connection from 1
+	c.lastSeq.Store(0)
+
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if c.opts.heartbeat > 0 {
+			conn.SetReadDeadline(time.Now().Add(2 * c.opts.heartbeat))
+		}
+		if messageType == websocket.BinaryMessage {
+			c.receiveBinary(data)
+			continue
+		}
+		if messageType != websocket.TextMessage {
+			continue
+		}
+
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil || msg.Type == "" {
+			// Not every text frame is a structured message
+			continue
+		}
+		if msg.PlayerID != "" {
+			c.mu.Lock()
+			c.playerID = msg.PlayerID
+			c.mu.Unlock()
+		}
+		if msg.ServerSeq != 0 {
+			c.checkSeq(msg.ServerSeq)
+		}
+
+		if msg.Type != Batch {
+			c.receive(msg)
+			continue
+		}
+		var batch struct {
+			Messages []Message `json:"messages"`
+		}
+		if err := msg.Decode(&batch); err != nil {
+			log.Printf("Invalid batch from the server: %v", err)
+			continue
+		}
+		for _, m := range batch.Messages {
+			c.receive(m)
+		}
+	}
+}
+
+// receive acks and dispatches one message, batched or not
+func (c *Client) receive(msg Message) {
+	if msg.ID != 0 && !c.ackReliable(msg.ID) {
+		return
+	}
+	if msg.RTT != 0 {
+		c.rtt.Store(int64(time.Duration(msg.RTT) * time.Millisecond))
+	}
+	if msg.Re != 0 {
+		c.answerCall(msg)
+		return
+	}
+	c.dispatch(msg)
+}
+
+// LastSeq is the sseq of the last message received on the current connection
+func (c *Client) LastSeq() uint64 {
+	return c.lastSeq.Load()
+}
+
+func (c *Client) checkSeq(seq uint64) {
+	want := c.lastSeq.Load() + 1
+	if seq > c.lastSeq.Load() {
+		c.lastSeq.Store(seq)
+	}
+	if seq == want {
+		return
+	}
+	if c.opts.onGap != nil {
+		c.opts.onGap(want, seq)
+	} else {
+		log.Printf("Expected message %d from the server, got %d", want, seq)
+	}
+}
+
+// ackReliable acks a reliable message, false when it is a retry of one already handled
+func (c *Client) ackReliable(id uint64) bool {
+	// Ack duplicates too, the first ack may be what got lost
+	if err := SendMessageAck(c, MessageAckPayload{ID: id}); err != nil {
+		log.Printf("Failed to ack message %d: %v", id, err)
+	}
+
+	if _, seen := c.seenIDs[id]; seen {
+		return false
+	}
+	c.seenIDs[id] = struct{}{}
+	c.seenOrder = append(c.seenOrder, id)
+	if len(c.seenOrder) > reliableWindow {
+		delete(c.seenIDs, c.seenOrder[0])
+		c.seenOrder = c.seenOrder[1:]
+	}
+	return true
+}
+
+func (c *Client) dispatch(msg Message) {
+	c.handlersMu.RLock()
+	handlers := append(append([]Handler(nil), c.handlers[msg.Type]...), c.any...)
+	c.handlersMu.RUnlock()
+
+	for _, h := range handlers {
+		h(msg)
+	}
+}
+
+// startHeartbeat pings every interval, a connection that stops answering times out its reads
+func (c *Client) startHeartbeat(conn *websocket.Conn) (stop func()) {
+	interval := c.opts.heartbeat
+	if interval <= 0 {
+		return func() {}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * interval))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * interval))
+	})
+
+	quit := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+					return
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+	return func() { close(quit) }
+}
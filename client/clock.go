@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// Server timestamps are converted with the offset of the last SyncClock. Each round is a
+// TIME_SYNC: t0 is when the client sent it, t1 and t2 when the server received and
+// answered it, t3 when the answer arrived. The offset (server clock minus ours) is
+// ((t1 - t0) + (t2 - t3)) / 2, trusted most for the round with the shortest round trip.
+
+// timeSyncReply is a TIME_SYNC answer with the time it arrived
+type timeSyncReply struct {
+	payload TimeSyncPayload
+	arrived time.Time
+}
+
+// onTimeSync hands answers to a waiting SyncClock, on the read goroutine
+func (c *Client) onTimeSync(msg Message) {
+	arrived := time.Now()
+	var payload TimeSyncPayload
+	if err := msg.Decode(&payload); err != nil {
+		return
+	}
+	select {
+	case c.timeSyncs <- timeSyncReply{payload: payload, arrived: arrived}:
+	default:
+	}
+}
+
+// SyncClock runs rounds TIME_SYNC round trips and keeps the offset of the shortest one,
+// which ServerTime and ServerNow use from then on. Run it again now and then, clocks
+// drift.
+func (c *Client) SyncClock(ctx context.Context, rounds int) (offset, rtt time.Duration, err error) {
+	c.syncMu.Lock()
+	defer c.syncMu.Unlock()
+
+	// Drop answers to rounds an earlier call gave up on
+	for len(c.timeSyncs) > 0 {
+		<-c.timeSyncs
+	}
+
+	rtt = -1
+	for i := 0; i < max(rounds, 1); i++ {
+		t0 := time.Now().UnixMilli()
+		if err := SendTimeSync(c, TimeSyncPayload{ClientSend: t0}); err != nil {
+			return 0, 0, err
+		}
+
+		var reply timeSyncReply
+		for reply.payload.ClientSend != t0 {
+			select {
+			case reply = <-c.timeSyncs:
+			case <-ctx.Done():
+				return 0, 0, ctx.Err()
+			case <-c.closed:
+				return 0, 0, ErrClosed
+			}
+		}
+
+		t1, t2, t3 := reply.payload.ServerReceive, reply.payload.ServerSend, reply.arrived.UnixMilli()
+		roundTrip := time.Duration((t3-t0)-(t2-t1)) * time.Millisecond
+		if rtt < 0 || roundTrip < rtt {
+			rtt = roundTrip
+			offset = time.Duration(((t1-t0)+(t2-t3))/2) * time.Millisecond
+		}
+	}
+
+	c.clockOffset.Store(int64(offset))
+	return offset, rtt, nil
+}
+
+// ClockOffset is how far the server clock is ahead of ours, 0 before SyncClock
+func (c *Client) ClockOffset() time.Duration {
+	return time.Duration(c.clockOffset.Load())
+}
+
+// ServerTime converts a server timestamp, e.g. Message.Timestamp, to our clock
+func (c *Client) ServerTime(timestamp int64) time.Time {
+	return time.UnixMilli(timestamp).Add(-c.ClockOffset())
+}
+
+// ServerNow is the current time of the server clock
+func (c *Client) ServerNow() time.Time {
+	return time.Now().Add(c.ClockOffset())
+}
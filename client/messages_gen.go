@@ -0,0 +1,977 @@
+// Code generated by msggen from messages.json. DO NOT EDIT.
+
+package client
+
+// MessageType identifies what a structured message is about
+type MessageType string
+
+const (
+	// Player input, numbered with seq for client-side prediction
+	PlayerMove MessageType = "PLAYER_MOVE"
+	// Game state updates, carries ack of the last processed input
+	GameStateSync MessageType = "GAME_STATE_SYNC"
+	// Another player joined, sent once its JOIN was accepted
+	PlayerJoin  MessageType = "PLAYER_JOIN"
+	PlayerLeave MessageType = "PLAYER_LEAVE"
+	ChatMessage MessageType = "CHAT_MESSAGE"
+	// Position of a connection waiting for a free slot
+	QueueUpdate MessageType = "QUEUE_UPDATE"
+	// A waiting connection got a slot
+	QueueAdmitted MessageType = "QUEUE_ADMITTED"
+	// An entity left the player's area of interest
+	EntityLeave MessageType = "ENTITY_LEAVE"
+	RoomClosed  MessageType = "ROOM_CLOSED"
+	// Switch to spectating, echoed back on success
+	SpectateJoin MessageType = "SPECTATE_JOIN"
+	// Switch back to playing, echoed back on success
+	SpectateLeave MessageType = "SPECTATE_LEAVE"
+	// Create a party with the sender as leader
+	PartyCreate MessageType = "PARTY_CREATE"
+	// Invite a player, pushed to the invited player
+	PartyInvite MessageType = "PARTY_INVITE"
+	// Accept an invite
+	PartyJoin  MessageType = "PARTY_JOIN"
+	PartyLeave MessageType = "PARTY_LEAVE"
+	// Party members and metadata, sent to members on every change
+	PartyUpdate MessageType = "PARTY_UPDATE"
+	// Chat only delivered to the sender's party
+	PartyChat MessageType = "PARTY_CHAT"
+	// Client sets its own status, e.g. away
+	PresenceSet MessageType = "PRESENCE_SET"
+	// Pushed to friends when an account changes status
+	PresenceUpdate MessageType = "PRESENCE_UPDATE"
+	FriendAdd      MessageType = "FRIEND_ADD"
+	FriendRemove   MessageType = "FRIEND_REMOVE"
+	// Request the friend list, answered with every friend and their status
+	FriendList MessageType = "FRIEND_LIST"
+	// Private message to one account, only delivered to that account
+	DirectMessage MessageType = "DIRECT_MESSAGE"
+	// A direct message could not be delivered
+	DirectMessageFailed MessageType = "DIRECT_MESSAGE_FAILED"
+	BlockAccount        MessageType = "BLOCK_ACCOUNT"
+	UnblockAccount      MessageType = "UNBLOCK_ACCOUNT"
+	// What this deployment supports, sent on connect (also served at GET /capabilities)
+	Capabilities MessageType = "CAPABILITIES"
+	// The onboarding step the player has to finish next
+	OnboardingStep MessageType = "ONBOARDING_STEP"
+	// Finishes the current onboarding step
+	OnboardingReply MessageType = "ONBOARDING_REPLY"
+	// Onboarding is done, gameplay messages are accepted from now on
+	OnboardingComplete MessageType = "ONBOARDING_COMPLETE"
+	// An action was refused because a meter (action points, stamina, cooldown) ran short
+	ActionRejected MessageType = "ACTION_REJECTED"
+	// A synchronized countdown started, was cancelled, or is running when joining a room
+	Countdown MessageType = "COUNTDOWN"
+	// A move was rejected, snap back to the authoritative position
+	MoveCorrection MessageType = "MOVE_CORRECTION"
+	// Asks the players of a finished match for feedback
+	SurveyRequest MessageType = "SURVEY_REQUEST"
+	// Answer to a SURVEY_REQUEST, every field but match_id is optional
+	SurveyResponse MessageType = "SURVEY_RESPONSE"
+	// A message was rejected because its type, version or payload did not match the schema
+	SchemaError MessageType = "SCHEMA_ERROR"
+	// A message was rejected, sent for every rejected message with a code to switch on. Clients answer a request of the server they refuse with it
+	Error MessageType = "ERROR"
+	// First message of a client, declares its protocol version and the features it wants
+	Hello MessageType = "HELLO"
+	// Answer to HELLO with the protocol version and features to use
+	Welcome MessageType = "WELCOME"
+	// Acknowledges a message by the id it carried, reliable server messages and client messages sent with an id
+	MessageAck MessageType = "MESSAGE_ACK"
+	// The player has been idle and is disconnected with close reason IDLE unless it sends something
+	IdleWarning MessageType = "IDLE_WARNING"
+	// The player's profile, required before anything else when the server asks for it in capabilities (join_handshake)
+	Join MessageType = "JOIN"
+	// The JOIN was valid, the player is now visible to others
+	JoinAccepted MessageType = "JOIN_ACCEPTED"
+	// The JOIN was invalid, fix it and send JOIN again
+	JoinRejected MessageType = "JOIN_REJECTED"
+	// The devices an account is connected from, sent to each of them when one connects or leaves
+	SessionList MessageType = "SESSION_LIST"
+	// The token to put in front of UDP datagrams, sent on connect when the UDP channel is enabled
+	UDPSession MessageType = "UDP_SESSION"
+	// Messages written during one room tick, combined into a single frame: the payload is {"messages": [...]           } with the messages in order. Only sent to clients that asked for the batching feature in HELLO
+	Batch MessageType = "BATCH"
+	// The player is being removed from the server, the close frame with reason KICKED follows
+	Kicked MessageType = "KICKED"
+	// The key of an encrypted room or party, sent on join and whenever it changes
+	PayloadKey MessageType = "PAYLOAD_KEY"
+	// The token to resume this session with after a reconnect, see server/resume.go
+	SessionToken MessageType = "SESSION_TOKEN"
+	// The account rating changed after a rated match
+	RatingUpdate MessageType = "RATING_UPDATE"
+	// Look for a match, a party leader queues the whole party
+	MatchmakingJoin MessageType = "MATCHMAKING_JOIN"
+	// Stop looking for a match
+	MatchmakingLeave MessageType = "MATCHMAKING_LEAVE"
+	// Sent when the player starts or stops looking for a match
+	MatchmakingStatus MessageType = "MATCHMAKING_STATUS"
+	// A match was made and the player moved into its room
+	MatchFound MessageType = "MATCH_FOUND"
+	// The bracket of a tournament, sent to participants and watchers on every change
+	TournamentUpdate MessageType = "TOURNAMENT_UPDATE"
+	// Follow a tournament, answered with its TOURNAMENT_UPDATE
+	TournamentWatch MessageType = "TOURNAMENT_WATCH"
+	// Stop following a tournament
+	TournamentUnwatch MessageType = "TOURNAMENT_UNWATCH"
+	// Ask for the joinable rooms, answered with ROOM_LIST (also served at GET /rooms)
+	ListRooms MessageType = "LIST_ROOMS"
+	// One page of the joinable rooms, fullest first
+	RoomList MessageType = "ROOM_LIST"
+	// Join a room by ID, answered with ROOM_JOINED or ROOM_JOIN_REJECTED
+	RoomJoin MessageType = "ROOM_JOIN"
+	// The ROOM_JOIN went through, the password is never echoed back
+	RoomJoined MessageType = "ROOM_JOINED"
+	// The ROOM_JOIN was refused, e.g. a wrong password or a missing invite
+	RoomJoinRejected MessageType = "ROOM_JOIN_REJECTED"
+	// Invite a player into the sender's room, pushed to the invited player
+	RoomInvite MessageType = "ROOM_INVITE"
+	// A server-wide notice from the operators, see Announce
+	Announcement MessageType = "ANNOUNCEMENT"
+	// Clock sync round trip: the client sends client_send, the server answers with its receive and send times
+	TimeSync MessageType = "TIME_SYNC"
+	// Round trip probe, answer right away with PONG carrying the same n
+	Ping MessageType = "PING"
+	// Answer to PING, it does not count as activity for idle kicks
+	Pong MessageType = "PONG"
+	// Starts a payload too large for one frame, sent in TRANSFER_CHUNK messages and handled as a message of type once complete
+	TransferStart MessageType = "TRANSFER_START"
+	// A piece of a transfer, every chunk but the last has chunk_size bytes
+	TransferChunk MessageType = "TRANSFER_CHUNK"
+	// Gives up a transfer, sent by either side
+	TransferAbort MessageType = "TRANSFER_ABORT"
+	// Become a WebRTC peer of the player's room, answered with RTC_PEERS
+	RTCJoin MessageType = "RTC_JOIN"
+	// Stop being a WebRTC peer, the other peers get RTC_PEER_LEFT
+	RTCLeave MessageType = "RTC_LEAVE"
+	// The peers of the room to connect to and the ICE servers to use, answers RTC_JOIN
+	RTCPeers MessageType = "RTC_PEERS"
+	// Another player of the room became a peer, it sends the offer
+	RTCPeerJoined MessageType = "RTC_PEER_JOINED"
+	// A peer left the room or stopped signaling, close the connection to it
+	RTCPeerLeft MessageType = "RTC_PEER_LEFT"
+	// SDP offer relayed to a peer of the same room
+	RTCOffer MessageType = "RTC_OFFER"
+	// SDP answer relayed back to the peer that sent the offer
+	RTCAnswer MessageType = "RTC_ANSWER"
+	// ICE candidate relayed to a peer of the same room
+	RTCCandidate MessageType = "RTC_CANDIDATE"
+)
+
+// QueueStatusPayload is sent with QUEUE_UPDATE messages
+type QueueStatusPayload struct {
+	Position    int `json:"position"`
+	QueueLength int `json:"queue_length"`
+	// 0 until the server has seen enough slots free up to make a guess
+	EstimatedWaitSeconds int64 `json:"estimated_wait_seconds"`
+}
+
+// EntityLeavePayload is sent with ENTITY_LEAVE when an entity drops out of a player's area of interest
+type EntityLeavePayload struct {
+	EntityID string `json:"entity_id"`
+}
+
+// RoomClosedPayload is sent to every member when a room is closed
+type RoomClosedPayload struct {
+	RoomID string `json:"room_id"`
+	Reason string `json:"reason"`
+}
+
+// PartyInvitePayload is sent by the inviting client with ToPlayerID and pushed to the invited player with PartyID and FromPlayerID
+type PartyInvitePayload struct {
+	PartyID      string `json:"party_id,omitempty"`
+	FromPlayerID string `json:"from_player_id,omitempty"`
+	ToPlayerID   string `json:"to_player_id" validate:"required,max=128"`
+}
+
+type PartyJoinPayload struct {
+	PartyID string `json:"party_id" validate:"required,max=128"`
+}
+
+type PartyStatePayload struct {
+	PartyID  string            `json:"party_id"`
+	LeaderID string            `json:"leader_id"`
+	Members  []string          `json:"members"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+type PresencePayload struct {
+	AccountID string `json:"account_id,omitempty"`
+	// online, away, in_game or offline
+	Status string `json:"status"`
+}
+
+type FriendPayload struct {
+	AccountID string `json:"account_id" validate:"required,max=128"`
+}
+
+type FriendListPayload struct {
+	Friends []PresencePayload `json:"friends"`
+}
+
+type DirectMessagePayload struct {
+	// Set by the server, whatever the client sends is ignored
+	From string `json:"from,omitempty"`
+	To   string `json:"to" validate:"max=128"`
+	Text string `json:"text" validate:"max=2000"`
+	// Unix time the server received it, useful for messages delivered after reconnect
+	SentAt int64 `json:"sent_at,omitempty"`
+}
+
+type DirectMessageFailedPayload struct {
+	To     string `json:"to"`
+	Reason string `json:"reason"`
+}
+
+type BlockPayload struct {
+	AccountID string `json:"account_id" validate:"required,max=128"`
+}
+
+// CapabilitiesPayload lists enabled modules, protocol versions, codecs and limits of a deployment
+type CapabilitiesPayload struct {
+	ProtocolVersion    int `json:"protocol_version"`
+	MinProtocolVersion int `json:"min_protocol_version"`
+	// Message encodings and extensions the server accepts
+	Codecs []string `json:"codecs"`
+	// Optional features enabled on this server
+	Modules []string `json:"modules"`
+	// Numeric limits, durations are in milliseconds
+	Limits map[string]int `json:"limits"`
+}
+
+type OnboardingStepPayload struct {
+	// terms, name or tutorial
+	Step         string `json:"step"`
+	TermsVersion string `json:"terms_version,omitempty"`
+	TermsURL     string `json:"terms_url,omitempty"`
+	// Tutorial room the player was put in
+	RoomID string `json:"room_id,omitempty"`
+	// Why the previous reply was rejected
+	Error string `json:"error,omitempty"`
+}
+
+type OnboardingReplyPayload struct {
+	Step string `json:"step"`
+	// Terms step: the player accepted the terms
+	Accept bool `json:"accept,omitempty"`
+	// Name step: the picked display name
+	Name string `json:"name,omitempty"`
+}
+
+type OnboardingCompletePayload struct {
+	DisplayName string `json:"display_name,omitempty"`
+}
+
+type ActionRejectedPayload struct {
+	// The rejected message type
+	Type  MessageType `json:"type"`
+	Meter string      `json:"meter"`
+	// What the meter had left
+	Value float64 `json:"value"`
+	Cost  float64 `json:"cost"`
+}
+
+type CountdownPayload struct {
+	// What starts, e.g. race or round
+	Label string `json:"label,omitempty"`
+	// Server clock unix milliseconds when the countdown ends
+	StartAt int64 `json:"start_at"`
+	// Server clock unix milliseconds when the message was sent, to estimate the clock offset
+	ServerTime int64 `json:"server_time"`
+	// StartAt minus ServerTime, for clients without a clock offset
+	RemainingMs int64 `json:"remaining_ms"`
+	Cancelled   bool  `json:"cancelled,omitempty"`
+}
+
+// PlayerMovePayload is where the client moved its player
+type PlayerMovePayload struct {
+	X  float64 `json:"x"`
+	Y  float64 `json:"y"`
+	VX float64 `json:"vx,omitempty"`
+	VY float64 `json:"vy,omitempty"`
+}
+
+type MoveCorrectionPayload struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	// The rejected input
+	Seq    uint64 `json:"seq"`
+	Reason string `json:"reason"`
+}
+
+// EntityState is the server-authoritative state of one entity
+type EntityState struct {
+	ID string `json:"id"`
+	// Player controlling the entity, empty for server-owned ones
+	Owner      string                 `json:"owner,omitempty"`
+	X          float64                `json:"x"`
+	Y          float64                `json:"y"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	// Bumped on every change
+	Version uint64 `json:"version"`
+}
+
+// WorldStatePayload is the authoritative snapshot sent in GAME_STATE_SYNC
+type WorldStatePayload struct {
+	RoomID   string        `json:"room_id"`
+	Entities []EntityState `json:"entities"`
+}
+
+type SurveyRequestPayload struct {
+	MatchID string `json:"match_id"`
+	Mode    string `json:"mode,omitempty"`
+	Map     string `json:"map,omitempty"`
+	// Accounts that can be reported
+	Players   []string `json:"players"`
+	MaxRating int      `json:"max_rating"`
+	// Seconds left to answer
+	ExpiresIn int `json:"expires_in"`
+}
+
+type SurveyResponsePayload struct {
+	MatchID string `json:"match_id"`
+	// 1 to max_rating, 0 to skip
+	Rating   int    `json:"rating,omitempty"`
+	Feedback string `json:"feedback,omitempty"`
+	// Account of a player to report
+	Report       string `json:"report,omitempty"`
+	ReportReason string `json:"report_reason,omitempty"`
+}
+
+type ErrorPayload struct {
+	// RATE_LIMITED, INVALID_PAYLOAD, NOT_IN_ROOM, UNAUTHORIZED, or REJECTED for anything else
+	Code string `json:"code"`
+	// Type of the rejected message, empty when it couldn't be read
+	Type MessageType `json:"type,omitempty"`
+	Seq  uint64      `json:"seq,omitempty"`
+	// The dedup id the rejected message carried
+	Dedup uint64 `json:"dedup,omitempty"`
+	// Explains the rejection to people, don't switch on it
+	Reason string `json:"reason"`
+}
+
+type SchemaErrorPayload struct {
+	// Type of the rejected message
+	Type string `json:"type"`
+	// Version the client sent
+	Version int `json:"version,omitempty"`
+	// Version the server understands, 0 for unknown types
+	Supported int    `json:"supported,omitempty"`
+	Seq       uint64 `json:"seq,omitempty"`
+	Reason    string `json:"reason"`
+	// Path of the payload field that broke a rule, like members[2].name
+	Field string `json:"field,omitempty"`
+	// The rule it broke, like max=128
+	Rule string `json:"rule,omitempty"`
+}
+
+type HelloPayload struct {
+	ProtocolVersion int `json:"protocol_version"`
+	// Codecs and modules the client would like to use
+	Features []string `json:"features,omitempty"`
+	// Name and version of the client, for logs
+	Client string `json:"client,omitempty"`
+}
+
+type WelcomePayload struct {
+	// Version both sides speak from now on
+	ProtocolVersion int `json:"protocol_version"`
+	// The client asked for a newer version than the server has
+	Downgraded bool `json:"downgraded,omitempty"`
+	// The requested features the server supports
+	Features []string `json:"features"`
+	// The requested features the server does not support
+	Unsupported []string `json:"unsupported,omitempty"`
+}
+
+type MessageAckPayload struct {
+	ID uint64 `json:"id"`
+}
+
+type IdleWarningPayload struct {
+	// Milliseconds left before the disconnect
+	KickInMs int64 `json:"kick_in_ms"`
+}
+
+type JoinPayload struct {
+	// Display name
+	Name          string `json:"name"`
+	Avatar        string `json:"avatar,omitempty"`
+	ClientVersion string `json:"client_version,omitempty"`
+}
+
+type JoinAcceptedPayload struct {
+	PlayerID string `json:"player_id"`
+	Name     string `json:"name"`
+}
+
+type JoinRejectedPayload struct {
+	// The invalid field: name, avatar or client_version
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
+}
+
+type PlayerProfilePayload struct {
+	PlayerID string `json:"player_id"`
+	Name     string `json:"name"`
+	Avatar   string `json:"avatar,omitempty"`
+}
+
+type SessionPayload struct {
+	PlayerID string `json:"player_id"`
+	Device   string `json:"device,omitempty"`
+	// Unix milliseconds
+	ConnectedAt int64 `json:"connected_at"`
+	// This is the connection receiving the list
+	Current bool `json:"current,omitempty"`
+}
+
+type SessionListPayload struct {
+	Sessions []SessionPayload `json:"sessions"`
+}
+
+// UDPSessionPayload is sent with UDP_SESSION messages
+type UDPSessionPayload struct {
+	// 32 hex characters, send the 16 bytes they encode in front of every datagram
+	Token string `json:"token"`
+	Port  int    `json:"port"`
+}
+
+type KickedPayload struct {
+	// Why, for people
+	Reason string `json:"reason"`
+}
+
+type PayloadKeyPayload struct {
+	// room or party
+	Scope string `json:"scope"`
+	// ID of the room or party
+	ID string `json:"id"`
+	// Sealed payloads name the key they use by this ID
+	KeyID int `json:"key_id"`
+	// AES-256-GCM key, base64
+	Key string `json:"key"`
+}
+
+// SealedPayload An encrypted payload, see server/encryption.go
+type SealedPayload struct {
+	// Key the payload is sealed with
+	KeyID int `json:"kid"`
+	// base64 of the 12 byte nonce followed by the ciphertext
+	Data string `json:"enc"`
+}
+
+type SessionTokenPayload struct {
+	// Reconnect with ?session=<token>, it works once
+	Token string `json:"token"`
+	// This connection resumed an earlier session
+	Resumed bool `json:"resumed"`
+	// How long the session is kept after a disconnect
+	ExpiresInMs int `json:"expires_in_ms"`
+	// The sseq of the last message delivered before the disconnect, the numbering continues after it. A client that saw a lower one missed messages
+	LastSeq uint64 `json:"last_seq,omitempty"`
+}
+
+type RatingUpdatePayload struct {
+	Rating float64 `json:"rating"`
+	// Difference to the rating before the match
+	Change float64 `json:"change"`
+	Games  int     `json:"games"`
+}
+
+type MatchmakingJoinPayload struct {
+	// Game mode to play, empty for plain rooms
+	Mode string `json:"mode"`
+}
+
+type MatchmakingStatusPayload struct {
+	Queued bool   `json:"queued"`
+	Mode   string `json:"mode"`
+	// Rating the ticket is matched by, the party average for parties
+	Rating float64 `json:"rating"`
+}
+
+type MatchFoundPayload struct {
+	RoomID string `json:"room_id"`
+	Mode   string `json:"mode"`
+	// Player IDs of everyone in the match
+	Players       []string `json:"players"`
+	AverageRating float64  `json:"average_rating"`
+}
+
+// TournamentMatchPayload is one match of a bracket
+type TournamentMatchPayload struct {
+	// W<round>-<n> in the winners bracket, L<round>-<n> in the losers bracket, GF for the grand final
+	ID string `json:"id"`
+	// winners, losers or final
+	Bracket string `json:"bracket"`
+	Round   int    `json:"round"`
+	// The two account IDs, empty while not decided yet and "BYE" for a bye
+	Players []string `json:"players"`
+	Winner  string   `json:"winner"`
+	RoomID  string   `json:"room_id"`
+	// pending, playing, finished or bye
+	Status string `json:"status"`
+}
+
+type TournamentPayload struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// single_elimination or double_elimination
+	Format string `json:"format"`
+	// Account IDs by seed
+	Participants []string                 `json:"participants"`
+	Matches      []TournamentMatchPayload `json:"matches"`
+	// Set once the tournament is over
+	Champion string `json:"champion"`
+}
+
+type TournamentWatchPayload struct {
+	TournamentID string `json:"tournament_id"`
+}
+
+type ListRoomsPayload struct {
+	// Only rooms of this mode
+	Mode string `json:"mode"`
+	// Only rooms on this map
+	Map string `json:"map"`
+	// Only rooms whose name contains this, ignoring case
+	Name string `json:"name"`
+	// Also list rooms that are full
+	IncludeFull bool `json:"include_full"`
+	Offset      int  `json:"offset" validate:"min=0"`
+	// Rooms per page, 20 when 0 and at most 100
+	Limit int `json:"limit" validate:"min=0"`
+}
+
+// RoomListingPayload is one room of a ROOM_LIST
+type RoomListingPayload struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Mode    string `json:"mode"`
+	Map     string `json:"map"`
+	Players int    `json:"players"`
+	// 0 when the room has no limit
+	MaxPlayers int `json:"max_players"`
+	// Ping hint: the region the room is hosted in
+	Region string `json:"region"`
+	// Ping hint: the server hosting the room
+	Node string `json:"node"`
+}
+
+type RoomListPayload struct {
+	Rooms []RoomListingPayload `json:"rooms"`
+	// Rooms matching the filter, on all pages
+	Total  int `json:"total"`
+	Offset int `json:"offset"`
+	// Ping hint: an URL of this server clients can time requests to
+	PingURL string `json:"ping_url"`
+}
+
+type RoomJoinPayload struct {
+	RoomID string `json:"room_id" validate:"required,max=128"`
+	// Needed for password protected rooms unless invited
+	Password string `json:"password,omitempty" validate:"max=128"`
+}
+
+type RoomJoinRejectedPayload struct {
+	RoomID string `json:"room_id"`
+	Reason string `json:"reason"`
+}
+
+// RoomInvitePayload is sent by the inviting client with ToPlayerID and pushed to the invited player with the room and FromPlayerID
+type RoomInvitePayload struct {
+	RoomID       string `json:"room_id"`
+	RoomName     string `json:"room_name,omitempty"`
+	FromPlayerID string `json:"from_player_id"`
+	ToPlayerID   string `json:"to_player_id" validate:"required,max=128"`
+}
+
+type AnnouncementPayload struct {
+	Text string `json:"text"`
+}
+
+// TimeSyncPayload carries unix milliseconds, see clock.go for how clients turn it into a clock offset
+type TimeSyncPayload struct {
+	ClientSend    int64 `json:"client_send"`
+	ServerReceive int64 `json:"server_receive,omitempty"`
+	ServerSend    int64 `json:"server_send,omitempty"`
+}
+
+type PingPayload struct {
+	N uint64 `json:"n"`
+	// Smoothed round trip measured by the server so far
+	RTT int64 `json:"rtt_ms,omitempty"`
+}
+
+type PongPayload struct {
+	N uint64 `json:"n"`
+}
+
+type TransferStartPayload struct {
+	// Chosen by the sender, unique among its transfers in progress
+	TransferID string `json:"transfer_id" validate:"required,max=64"`
+	// Type of the message the payload is for
+	Type MessageType `json:"type" validate:"required"`
+	// Payload size in bytes
+	Size      int `json:"size" validate:"required,min=1"`
+	ChunkSize int `json:"chunk_size" validate:"required,min=1"`
+	// Hex SHA-256 of the payload, checked when given
+	Checksum string `json:"checksum,omitempty"`
+}
+
+type TransferChunkPayload struct {
+	TransferID string `json:"transfer_id" validate:"required,max=64"`
+	// Position of the chunk from 0
+	Index int `json:"index" validate:"min=0"`
+	// The chunk's bytes, base64 encoded
+	Data []byte `json:"data" validate:"required"`
+}
+
+type TransferAbortPayload struct {
+	TransferID string `json:"transfer_id" validate:"required,max=64"`
+	Reason     string `json:"reason"`
+}
+
+// ICEServerPayload is a STUN or TURN server, in the shape of RTCIceServer
+type ICEServerPayload struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+type RTCPeersPayload struct {
+	RoomID string `json:"room_id"`
+	// Player IDs of the other peers, they connect to the new peer
+	Peers      []string           `json:"peers"`
+	ICEServers []ICEServerPayload `json:"ice_servers,omitempty"`
+}
+
+type RTCPeerPayload struct {
+	RoomID string `json:"room_id"`
+	PeerID string `json:"peer_id"`
+}
+
+// RTCSessionPayload is sent with PeerID set to the peer to reach and delivered with PeerID set to the sender
+type RTCSessionPayload struct {
+	PeerID string `json:"peer_id" validate:"required,max=128"`
+	SDP    string `json:"sdp" validate:"required,max=65536"`
+}
+
+// RTCCandidatePayload is sent with PeerID set to the peer to reach and delivered with PeerID set to the sender
+type RTCCandidatePayload struct {
+	PeerID string `json:"peer_id" validate:"required,max=128"`
+	// Empty signals the end of candidates
+	Candidate     string `json:"candidate" validate:"max=2048"`
+	SDPMid        string `json:"sdp_mid,omitempty"`
+	SDPMLineIndex int    `json:"sdp_mline_index" validate:"min=0"`
+}
+
+// ServerInfoPayload is what a game server reports to the coordinator, see server/coordinator.go
+type ServerInfoPayload struct {
+	ID   string `json:"id" validate:"required,max=128"`
+	Name string `json:"name,omitempty" validate:"max=128"`
+	// URL clients connect to, like wss://eu1.example.com/ws
+	Address    string `json:"address" validate:"required,max=512"`
+	Region     string `json:"region,omitempty" validate:"max=64"`
+	Players    int    `json:"players" validate:"min=0"`
+	MaxPlayers int    `json:"max_players" validate:"min=0"`
+	Rooms      int    `json:"rooms,omitempty" validate:"min=0"`
+	// The optional features of the server, as in CAPABILITIES
+	Modules []string `json:"modules,omitempty"`
+	// Unix milliseconds of the last report, set by the coordinator
+	UpdatedAt int64 `json:"updated_at,omitempty"`
+}
+
+// ServerListPayload is the answer of the coordinator's GET /servers, least loaded first
+type ServerListPayload struct {
+	Servers []ServerInfoPayload `json:"servers"`
+}
+
+// MessageVersions is the payload version of every message type, sent along as "v"
+var MessageVersions = map[MessageType]int{
+	PlayerMove:          1,
+	GameStateSync:       1,
+	PlayerJoin:          1,
+	PlayerLeave:         1,
+	ChatMessage:         1,
+	QueueUpdate:         1,
+	QueueAdmitted:       1,
+	EntityLeave:         1,
+	RoomClosed:          1,
+	SpectateJoin:        1,
+	SpectateLeave:       1,
+	PartyCreate:         1,
+	PartyInvite:         1,
+	PartyJoin:           1,
+	PartyLeave:          1,
+	PartyUpdate:         1,
+	PartyChat:           1,
+	PresenceSet:         1,
+	PresenceUpdate:      1,
+	FriendAdd:           1,
+	FriendRemove:        1,
+	FriendList:          1,
+	DirectMessage:       1,
+	DirectMessageFailed: 1,
+	BlockAccount:        1,
+	UnblockAccount:      1,
+	Capabilities:        1,
+	OnboardingStep:      1,
+	OnboardingReply:     1,
+	OnboardingComplete:  1,
+	ActionRejected:      1,
+	Countdown:           1,
+	MoveCorrection:      1,
+	SurveyRequest:       1,
+	SurveyResponse:      1,
+	SchemaError:         1,
+	Error:               1,
+	Hello:               1,
+	Welcome:             1,
+	MessageAck:          1,
+	IdleWarning:         1,
+	Join:                1,
+	JoinAccepted:        1,
+	JoinRejected:        1,
+	SessionList:         1,
+	UDPSession:          1,
+	Batch:               1,
+	Kicked:              1,
+	PayloadKey:          1,
+	SessionToken:        1,
+	RatingUpdate:        1,
+	MatchmakingJoin:     1,
+	MatchmakingLeave:    1,
+	MatchmakingStatus:   1,
+	MatchFound:          1,
+	TournamentUpdate:    1,
+	TournamentWatch:     1,
+	TournamentUnwatch:   1,
+	ListRooms:           1,
+	RoomList:            1,
+	RoomJoin:            1,
+	RoomJoined:          1,
+	RoomJoinRejected:    1,
+	RoomInvite:          1,
+	Announcement:        1,
+	TimeSync:            1,
+	Ping:                1,
+	Pong:                1,
+	TransferStart:       1,
+	TransferChunk:       1,
+	TransferAbort:       1,
+	RTCJoin:             1,
+	RTCLeave:            1,
+	RTCPeers:            1,
+	RTCPeerJoined:       1,
+	RTCPeerLeft:         1,
+	RTCOffer:            1,
+	RTCAnswer:           1,
+	RTCCandidate:        1,
+}
+
+// Sender is anything that can send a structured message to the server
+type Sender interface {
+	Send(msgType MessageType, payload interface{}) error
+}
+
+// SendPlayerMove sends a PLAYER_MOVE message to the server
+func SendPlayerMove(s Sender, payload PlayerMovePayload) error {
+	return s.Send(PlayerMove, payload)
+}
+
+// SendGameStateSync sends a GAME_STATE_SYNC message to the server
+func SendGameStateSync(s Sender, payload interface{}) error {
+	return s.Send(GameStateSync, payload)
+}
+
+// SendChatMessage sends a CHAT_MESSAGE message to the server
+func SendChatMessage(s Sender, payload interface{}) error {
+	return s.Send(ChatMessage, payload)
+}
+
+// SendSpectateJoin sends a SPECTATE_JOIN message to the server
+func SendSpectateJoin(s Sender, payload interface{}) error {
+	return s.Send(SpectateJoin, payload)
+}
+
+// SendSpectateLeave sends a SPECTATE_LEAVE message to the server
+func SendSpectateLeave(s Sender, payload interface{}) error {
+	return s.Send(SpectateLeave, payload)
+}
+
+// SendPartyCreate sends a PARTY_CREATE message to the server
+func SendPartyCreate(s Sender, payload interface{}) error {
+	return s.Send(PartyCreate, payload)
+}
+
+// SendPartyInvite sends a PARTY_INVITE message to the server
+func SendPartyInvite(s Sender, payload PartyInvitePayload) error {
+	return s.Send(PartyInvite, payload)
+}
+
+// SendPartyJoin sends a PARTY_JOIN message to the server
+func SendPartyJoin(s Sender, payload PartyJoinPayload) error {
+	return s.Send(PartyJoin, payload)
+}
+
+// SendPartyLeave sends a PARTY_LEAVE message to the server
+func SendPartyLeave(s Sender, payload interface{}) error {
+	return s.Send(PartyLeave, payload)
+}
+
+// SendPartyChat sends a PARTY_CHAT message to the server
+func SendPartyChat(s Sender, payload interface{}) error {
+	return s.Send(PartyChat, payload)
+}
+
+// SendPresenceSet sends a PRESENCE_SET message to the server
+func SendPresenceSet(s Sender, payload PresencePayload) error {
+	return s.Send(PresenceSet, payload)
+}
+
+// SendFriendAdd sends a FRIEND_ADD message to the server
+func SendFriendAdd(s Sender, payload FriendPayload) error {
+	return s.Send(FriendAdd, payload)
+}
+
+// SendFriendRemove sends a FRIEND_REMOVE message to the server
+func SendFriendRemove(s Sender, payload FriendPayload) error {
+	return s.Send(FriendRemove, payload)
+}
+
+// SendFriendList sends a FRIEND_LIST message to the server
+func SendFriendList(s Sender, payload FriendListPayload) error {
+	return s.Send(FriendList, payload)
+}
+
+// SendDirectMessage sends a DIRECT_MESSAGE message to the server
+func SendDirectMessage(s Sender, payload DirectMessagePayload) error {
+	return s.Send(DirectMessage, payload)
+}
+
+// SendBlockAccount sends a BLOCK_ACCOUNT message to the server
+func SendBlockAccount(s Sender, payload BlockPayload) error {
+	return s.Send(BlockAccount, payload)
+}
+
+// SendUnblockAccount sends a UNBLOCK_ACCOUNT message to the server
+func SendUnblockAccount(s Sender, payload BlockPayload) error {
+	return s.Send(UnblockAccount, payload)
+}
+
+// SendOnboardingReply sends a ONBOARDING_REPLY message to the server
+func SendOnboardingReply(s Sender, payload OnboardingReplyPayload) error {
+	return s.Send(OnboardingReply, payload)
+}
+
+// SendSurveyResponse sends a SURVEY_RESPONSE message to the server
+func SendSurveyResponse(s Sender, payload SurveyResponsePayload) error {
+	return s.Send(SurveyResponse, payload)
+}
+
+// SendError sends a ERROR message to the server
+func SendError(s Sender, payload ErrorPayload) error {
+	return s.Send(Error, payload)
+}
+
+// SendHello sends a HELLO message to the server
+func SendHello(s Sender, payload HelloPayload) error {
+	return s.Send(Hello, payload)
+}
+
+// SendMessageAck sends a MESSAGE_ACK message to the server
+func SendMessageAck(s Sender, payload MessageAckPayload) error {
+	return s.Send(MessageAck, payload)
+}
+
+// SendJoin sends a JOIN message to the server
+func SendJoin(s Sender, payload JoinPayload) error {
+	return s.Send(Join, payload)
+}
+
+// SendMatchmakingJoin sends a MATCHMAKING_JOIN message to the server
+func SendMatchmakingJoin(s Sender, payload MatchmakingJoinPayload) error {
+	return s.Send(MatchmakingJoin, payload)
+}
+
+// SendMatchmakingLeave sends a MATCHMAKING_LEAVE message to the server
+func SendMatchmakingLeave(s Sender, payload interface{}) error {
+	return s.Send(MatchmakingLeave, payload)
+}
+
+// SendTournamentWatch sends a TOURNAMENT_WATCH message to the server
+func SendTournamentWatch(s Sender, payload TournamentWatchPayload) error {
+	return s.Send(TournamentWatch, payload)
+}
+
+// SendTournamentUnwatch sends a TOURNAMENT_UNWATCH message to the server
+func SendTournamentUnwatch(s Sender, payload TournamentWatchPayload) error {
+	return s.Send(TournamentUnwatch, payload)
+}
+
+// SendListRooms sends a LIST_ROOMS message to the server
+func SendListRooms(s Sender, payload ListRoomsPayload) error {
+	return s.Send(ListRooms, payload)
+}
+
+// SendRoomJoin sends a ROOM_JOIN message to the server
+func SendRoomJoin(s Sender, payload RoomJoinPayload) error {
+	return s.Send(RoomJoin, payload)
+}
+
+// SendRoomInvite sends a ROOM_INVITE message to the server
+func SendRoomInvite(s Sender, payload RoomInvitePayload) error {
+	return s.Send(RoomInvite, payload)
+}
+
+// SendTimeSync sends a TIME_SYNC message to the server
+func SendTimeSync(s Sender, payload TimeSyncPayload) error {
+	return s.Send(TimeSync, payload)
+}
+
+// SendPong sends a PONG message to the server
+func SendPong(s Sender, payload PongPayload) error {
+	return s.Send(Pong, payload)
+}
+
+// SendTransferStart sends a TRANSFER_START message to the server
+func SendTransferStart(s Sender, payload TransferStartPayload) error {
+	return s.Send(TransferStart, payload)
+}
+
+// SendTransferChunk sends a TRANSFER_CHUNK message to the server
+func SendTransferChunk(s Sender, payload TransferChunkPayload) error {
+	return s.Send(TransferChunk, payload)
+}
+
+// SendTransferAbort sends a TRANSFER_ABORT message to the server
+func SendTransferAbort(s Sender, payload TransferAbortPayload) error {
+	return s.Send(TransferAbort, payload)
+}
+
+// SendRTCJoin sends a RTC_JOIN message to the server
+func SendRTCJoin(s Sender, payload interface{}) error {
+	return s.Send(RTCJoin, payload)
+}
+
+// SendRTCLeave sends a RTC_LEAVE message to the server
+func SendRTCLeave(s Sender, payload interface{}) error {
+	return s.Send(RTCLeave, payload)
+}
+
+// SendRTCOffer sends a RTC_OFFER message to the server
+func SendRTCOffer(s Sender, payload RTCSessionPayload) error {
+	return s.Send(RTCOffer, payload)
+}
+
+// SendRTCAnswer sends a RTC_ANSWER message to the server
+func SendRTCAnswer(s Sender, payload RTCSessionPayload) error {
+	return s.Send(RTCAnswer, payload)
+}
+
+// SendRTCCandidate sends a RTC_CANDIDATE message to the server
+func SendRTCCandidate(s Sender, payload RTCCandidatePayload) error {
+	return s.Send(RTCCandidate, payload)
+}
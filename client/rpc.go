@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"fmt"
+)
+
+// Call sends a request and waits for the message answering it, matched by req and re.
+// The server answers requests to its request handlers, a request it refuses with ERROR,
+// returned as a *CallError. Requests of the server reach the handlers like any message,
+// answer them with Reply or refuse them with ReplyError.
+
+// CallError is the ERROR the server answered a call with
+type CallError struct {
+	Code   string
+	Reason string
+}
+
+func (e *CallError) Error() string {
+	return fmt.Sprintf("call rejected with %s: %s", e.Code, e.Reason)
+}
+
+// Call sends a request and returns the answer. Calls are lost with the connection, ctx
+// should have a deadline.
+func (c *Client) Call(ctx context.Context, msgType MessageType, payload interface{}) (Message, error) {
+	c.callsMu.Lock()
+	c.reqs++
+	req := c.reqs
+	answer := make(chan Message, 1)
+	c.calls[req] = answer
+	c.callsMu.Unlock()
+
+	defer func() {
+		c.callsMu.Lock()
+		delete(c.calls, req)
+		c.callsMu.Unlock()
+	}()
+
+	if err := c.send(Message{Type: msgType, Req: req}, payload); err != nil {
+		return Message{}, err
+	}
+
+	select {
+	case reply := <-answer:
+		if reply.Type == Error {
+			var e ErrorPayload
+			reply.Decode(&e)
+			return reply, &CallError{Code: e.Code, Reason: e.Reason}
+		}
+		return reply, nil
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	case <-c.closed:
+		return Message{}, ErrClosed
+	}
+}
+
+// Reply answers a request of the server with a message of msgType
+func (c *Client) Reply(req Message, msgType MessageType, payload interface{}) error {
+	return c.send(Message{Type: msgType, Re: req.Req}, payload)
+}
+
+// ReplyError refuses a request of the server, its call fails with code and reason
+func (c *Client) ReplyError(req Message, code, reason string) error {
+	return c.send(Message{Type: Error, Re: req.Req}, ErrorPayload{Code: code, Type: req.Type, Reason: reason})
+}
+
+// answerCall hands an answer to the call waiting for it, on the read goroutine. Answers
+// to calls that gave up are dropped.
+func (c *Client) answerCall(msg Message) {
+	c.callsMu.Lock()
+	answer, ok := c.calls[msg.Re]
+	delete(c.calls, msg.Re)
+	c.callsMu.Unlock()
+
+	if ok {
+		answer <- msg
+	}
+}
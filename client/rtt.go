@@ -0,0 +1,24 @@
+package client
+
+import "time"
+
+// The server measures our round trip with PING, which is answered with PONG right on the
+// read goroutine so handlers can't delay it. Every PING carries the RTT so far, and so do
+// state messages when the server sends it with them.
+
+// onPing answers PING and keeps the RTT the server reported
+func (c *Client) onPing(msg Message) {
+	var payload PingPayload
+	if err := msg.Decode(&payload); err != nil {
+		return
+	}
+	if payload.RTT > 0 {
+		c.rtt.Store(int64(time.Duration(payload.RTT) * time.Millisecond))
+	}
+	SendPong(c, PongPayload{N: payload.N})
+}
+
+// RTT is our smoothed round trip as the server measured it, 0 until it has
+func (c *Client) RTT() time.Duration {
+	return time.Duration(c.rtt.Load())
+}
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ListServers asks a coordinator for the game servers of a region, of every region when
+// empty, least loaded first. Full servers are left out. Connect to the Address of the
+// one picked.
+func ListServers(ctx context.Context, coordinatorURL, region string) ([]ServerInfoPayload, error) {
+	u := strings.TrimSuffix(coordinatorURL, "/") + "/servers"
+	if region != "" {
+		u += "?region=" + url.QueryEscape(region)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server list: status %d", resp.StatusCode)
+	}
+
+	var list ServerListPayload
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("invalid server list: %v", err)
+	}
+	return list.Servers, nil
+}
@@ -0,0 +1,161 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// Payloads too large for one frame come in chunks: TRANSFER_START, then TRANSFER_CHUNK
+// until the payload is complete, when it reaches the handlers of its type like any other
+// message. SendChunked uploads the same way, when the server has the chunked_transfer
+// capability. Transfers don't survive a reconnect.
+
+// defaultChunkSize is the size of the chunks SendChunked sends when given 0
+const defaultChunkSize = 16 << 10
+
+// TransferProgress is reported for every chunk sent or received, see OnTransferProgress
+type TransferProgress struct {
+	ID   string
+	Type MessageType
+	// Inbound is true for transfers from the server
+	Inbound bool
+	Done    int
+	Size    int
+}
+
+// OnTransferProgress runs fn for every chunk of a transfer, on the read goroutine for
+// inbound transfers
+func OnTransferProgress(fn func(p TransferProgress)) Option {
+	return func(o *options) { o.onTransfer = fn }
+}
+
+// inboundTransfer is a payload the server is sending, assembled in place
+type inboundTransfer struct {
+	start    TransferStartPayload
+	envelope Message
+	data     []byte
+	got      []bool
+	received int
+}
+
+func (c *Client) onTransferStart(msg Message) {
+	var start TransferStartPayload
+	if err := msg.Decode(&start); err != nil || start.Size <= 0 || start.ChunkSize <= 0 {
+		log.Printf("Invalid transfer from the server: %s", msg.Payload)
+		return
+	}
+	c.transfersMu.Lock()
+	defer c.transfersMu.Unlock()
+	c.inbound[start.TransferID] = &inboundTransfer{
+		start:    start,
+		envelope: Message{Type: start.Type, PlayerID: msg.PlayerID, Timestamp: msg.Timestamp},
+		data:     make([]byte, start.Size),
+		got:      make([]bool, 1+(start.Size-1)/start.ChunkSize),
+	}
+}
+
+func (c *Client) onTransferChunk(msg Message) {
+	var chunk TransferChunkPayload
+	if err := msg.Decode(&chunk); err != nil {
+		return
+	}
+	c.transfersMu.Lock()
+	in := c.inbound[chunk.TransferID]
+	if in == nil || chunk.Index < 0 || chunk.Index >= len(in.got) {
+		c.transfersMu.Unlock()
+		return
+	}
+	if !in.got[chunk.Index] {
+		in.got[chunk.Index] = true
+		in.received += copy(in.data[chunk.Index*in.start.ChunkSize:], chunk.Data)
+	}
+	received, complete := in.received, in.received >= in.start.Size
+	if complete {
+		delete(c.inbound, chunk.TransferID)
+	}
+	c.transfersMu.Unlock()
+
+	if c.opts.onTransfer != nil {
+		c.opts.onTransfer(TransferProgress{ID: in.start.TransferID, Type: in.start.Type, Inbound: true, Done: received, Size: in.start.Size})
+	}
+	if !complete {
+		return
+	}
+	if in.start.Checksum != "" {
+		if sum := sha256.Sum256(in.data); hex.EncodeToString(sum[:]) != in.start.Checksum {
+			log.Printf("Dropping transfer %s from the server, its checksum doesn't match", in.start.TransferID)
+			return
+		}
+	}
+	msg = in.envelope
+	msg.Payload = in.data
+	c.dispatch(msg)
+}
+
+func (c *Client) onTransferAbort(msg Message) {
+	var abort TransferAbortPayload
+	if err := msg.Decode(&abort); err != nil {
+		return
+	}
+	c.transfersMu.Lock()
+	defer c.transfersMu.Unlock()
+	delete(c.inbound, abort.TransferID)
+	if aborted, ok := c.outbound[abort.TransferID]; ok {
+		select {
+		case aborted <- abort.Reason:
+		default:
+		}
+	}
+}
+
+// SendChunked sends a payload in chunks of chunkSize bytes, 16 KiB when 0, and returns
+// once all of them are written. Cancelling ctx aborts the transfer.
+func (c *Client) SendChunked(ctx context.Context, msgType MessageType, payload interface{}, chunkSize int) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %v", err)
+	}
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
+	id := strconv.FormatUint(c.transferIDs.Add(1), 10)
+	aborted := make(chan string, 1)
+	c.transfersMu.Lock()
+	c.outbound[id] = aborted
+	c.transfersMu.Unlock()
+	defer func() {
+		c.transfersMu.Lock()
+		delete(c.outbound, id)
+		c.transfersMu.Unlock()
+	}()
+
+	sum := sha256.Sum256(data)
+	start := TransferStartPayload{TransferID: id, Type: msgType, Size: len(data), ChunkSize: chunkSize, Checksum: hex.EncodeToString(sum[:])}
+	if err := SendTransferStart(c, start); err != nil {
+		return err
+	}
+	for index := 0; index*chunkSize < len(data); index++ {
+		select {
+		case reason := <-aborted:
+			return fmt.Errorf("transfer aborted by the server: %s", reason)
+		case <-ctx.Done():
+			SendTransferAbort(c, TransferAbortPayload{TransferID: id, Reason: ctx.Err().Error()})
+			return ctx.Err()
+		default:
+		}
+		end := min((index+1)*chunkSize, len(data))
+		if err := SendTransferChunk(c, TransferChunkPayload{TransferID: id, Index: index, Data: data[index*chunkSize : end]}); err != nil {
+			return err
+		}
+		if c.opts.onTransfer != nil {
+			c.opts.onTransfer(TransferProgress{ID: id, Type: msgType, Done: end, Size: len(data)})
+		}
+	}
+	return nil
+}
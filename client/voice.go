@@ -0,0 +1,38 @@
+package client
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/iknizzz1807/socket-server-template/messages"
+)
+
+// Voice goes as binary frames of opus audio, when the server has the voice capability.
+// SendVoice speaks to the room, OnVoice hears the other players, with the volume the
+// server faded by distance. Encoding and playing the audio is up to the game.
+
+// OnVoice runs fn for every voice frame, on the read goroutine. The frame's Data is only
+// valid until fn returns.
+func OnVoice(fn func(f messages.VoiceFrame)) Option {
+	return func(o *options) { o.onVoice = fn }
+}
+
+// SendVoice sends one opus packet to the players of the room, volume 255 is full volume
+func (c *Client) SendVoice(seq uint16, volume uint8, packet []byte) error {
+	frame, err := messages.EncodeVoice(messages.VoiceFrame{Seq: seq, Volume: volume, Data: packet})
+	if err != nil {
+		return err
+	}
+	return c.write(websocket.BinaryMessage, frame)
+}
+
+// receiveBinary handles a binary frame from the server
+func (c *Client) receiveBinary(data []byte) {
+	kind, err := messages.FrameKind(data)
+	if err != nil || kind != messages.FrameVoice || c.opts.onVoice == nil {
+		return
+	}
+	frame, err := messages.DecodeVoice(data)
+	if err != nil {
+		return
+	}
+	c.opts.onVoice(frame)
+}
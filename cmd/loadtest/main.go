@@ -0,0 +1,74 @@
+// loadtest opens many WebSocket clients against a running server and reports latency
+// percentiles and error rates, see package loadtest.
+//
+// Usage:
+//
+//	go run ./cmd/loadtest -url ws://localhost:8080/ws -clients 500 -rate 10 -duration 1m
+//
+// -mix takes a JSON file with a list of steps, e.g.
+//
+//	[{"type": "CHAT_MESSAGE", "payload": "hi", "weight": 1, "expect": "CHAT_MESSAGE"},
+//	 {"type": "PLAYER_MOVE", "payload": {"x": 1, "y": 2}, "weight": 9}]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/iknizzz1807/socket-server-template/loadtest"
+)
+
+func main() {
+	url := flag.String("url", "ws://localhost:8080/ws", "websocket endpoint of the server")
+	clients := flag.Int("clients", 100, "number of concurrent clients")
+	rate := flag.Float64("rate", 1, "messages per second per client")
+	duration := flag.Duration("duration", 30*time.Second, "how long to run")
+	rampUp := flag.Duration("ramp", 5*time.Second, "spread the connects over this long")
+	mixPath := flag.String("mix", "", "JSON file with the message mix, chat echo when empty")
+	origin := flag.String("origin", "", "Origin header to send, for servers with an origin allowlist")
+	jsonOut := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	cfg := loadtest.Config{
+		URL:      *url,
+		Clients:  *clients,
+		Rate:     *rate,
+		Duration: *duration,
+		RampUp:   *rampUp,
+	}
+	if *origin != "" {
+		cfg.Header = http.Header{"Origin": {*origin}}
+	}
+	if *mixPath != "" {
+		data, err := os.ReadFile(*mixPath)
+		if err != nil {
+			log.Fatalf("Failed to read mix: %v", err)
+		}
+		if err := json.Unmarshal(data, &cfg.Mix); err != nil {
+			log.Fatalf("Invalid mix: %v", err)
+		}
+	}
+
+	// Ctrl+C stops early and still prints what was measured
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	log.Printf("Running %d clients at %.1f msg/s each against %s for %v", cfg.Clients, cfg.Rate, cfg.URL, cfg.Duration)
+	report, err := loadtest.Run(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Load test failed: %v", err)
+	}
+
+	if *jsonOut {
+		json.NewEncoder(os.Stdout).Encode(report)
+		return
+	}
+	fmt.Println(report)
+}
@@ -0,0 +1,248 @@
+// msggen generates message type constants, payload structs, handler interfaces
+// and client stubs from a message definition file, so the list of message types
+// only has to be kept in one place.
+//
+// Usage (see the go:generate line in server/server.go):
+//
+//	go run ./cmd/msggen -defs server/messages.json -go server/messages_gen.go \
+//		-client client/messages_gen.go -ts test_client/messages.gen.ts
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Definitions is the format of the message definition file
+type Definitions struct {
+	Messages []Message `json:"messages"`
+	Payloads []Payload `json:"payloads"`
+}
+
+type Message struct {
+	// Name is the Go constant name, e.g. PlayerMove
+	Name string `json:"name"`
+	// Type is the wire value, e.g. PLAYER_MOVE
+	Type string `json:"type"`
+	// Direction is "client" (client to server), "server" (server to client) or "both"
+	Direction string `json:"direction"`
+	// Payload names one of the payload structs, empty means no typed payload
+	Payload string `json:"payload,omitempty"`
+	// Gameplay messages are rejected from spectators
+	Gameplay bool `json:"gameplay,omitempty"`
+	// Version of the payload format, bump it on incompatible changes (1 when omitted)
+	Version int    `json:"version,omitempty"`
+	Doc     string `json:"doc,omitempty"`
+}
+
+type Payload struct {
+	Name   string  `json:"name"`
+	Doc    string  `json:"doc,omitempty"`
+	Fields []Field `json:"fields"`
+}
+
+type Field struct {
+	Name      string `json:"name"`
+	JSON      string `json:"json"`
+	Type      string `json:"type"`
+	OmitEmpty bool   `json:"omitempty,omitempty"`
+	Doc       string `json:"doc,omitempty"`
+	// Validate are the rules inbound payloads are checked with, see server/validation.go
+	Validate string `json:"validate,omitempty"`
+}
+
+// V is the message's payload version
+func (m Message) V() int {
+	if m.Version <= 0 {
+		return 1
+	}
+	return m.Version
+}
+
+func (m Message) FromClient() bool { return m.Direction == "client" || m.Direction == "both" }
+func (m Message) FromServer() bool { return m.Direction == "server" || m.Direction == "both" }
+
+func (f Field) Tag() string {
+	name := f.JSON
+	if f.OmitEmpty {
+		name += ",omitempty"
+	}
+	if f.Validate != "" {
+		return fmt.Sprintf("`json:\"%s\" validate:\"%s\"`", name, f.Validate)
+	}
+	return fmt.Sprintf("`json:\"%s\"`", name)
+}
+
+// direction describes who sends a message, for the docs
+func (m Message) DirectionText() string {
+	switch m.Direction {
+	case "client":
+		return "client → server"
+	case "server":
+		return "server → client"
+	default:
+		return "both ways"
+	}
+}
+
+// tsType maps a Go type from the definition file to TypeScript
+func tsType(goType string) string {
+	switch {
+	case goType == "[]byte":
+		// encoding/json sends bytes base64 encoded
+		return "string"
+	case strings.HasPrefix(goType, "[]"):
+		return tsType(goType[2:]) + "[]"
+	case strings.HasPrefix(goType, "map[string]"):
+		return "Record<string, " + tsType(goType[len("map[string]"):]) + ">"
+	case goType == "string":
+		return "string"
+	case goType == "bool":
+		return "boolean"
+	case goType == "interface{}" || goType == "any":
+		return "unknown"
+	case strings.HasPrefix(goType, "int"), strings.HasPrefix(goType, "uint"), strings.HasPrefix(goType, "float"):
+		return "number"
+	default:
+		// Another payload struct
+		return goType
+	}
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+func (d Definitions) validate() error {
+	payloads := make(map[string]bool)
+	for _, p := range d.Payloads {
+		if payloads[p.Name] {
+			return fmt.Errorf("payload %s defined twice", p.Name)
+		}
+		payloads[p.Name] = true
+	}
+
+	names := make(map[string]bool)
+	types := make(map[string]bool)
+	for _, m := range d.Messages {
+		if m.Name == "" || m.Type == "" {
+			return fmt.Errorf("message %q needs both name and type", m.Name+m.Type)
+		}
+		if names[m.Name] || types[m.Type] {
+			return fmt.Errorf("message %s (%s) defined twice", m.Name, m.Type)
+		}
+		names[m.Name], types[m.Type] = true, true
+
+		if !m.FromClient() && !m.FromServer() {
+			return fmt.Errorf("message %s: direction must be client, server or both", m.Name)
+		}
+		if m.Payload != "" && !payloads[m.Payload] {
+			return fmt.Errorf("message %s: unknown payload %s", m.Name, m.Payload)
+		}
+	}
+	return nil
+}
+
+type templateData struct {
+	Definitions
+	Source  string
+	Package string
+	// NeedsJSON is set when the dispatcher decodes typed payloads
+	NeedsJSON bool
+}
+
+var funcs = template.FuncMap{
+	"tsType":     tsType,
+	"lowerFirst": lowerFirst,
+	"lower":      strings.ToLower,
+}
+
+func render(tmpl string, data templateData, gofmt bool) ([]byte, error) {
+	t, err := template.New("out").Funcs(funcs).Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	if !gofmt {
+		return buf.Bytes(), nil
+	}
+
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated code does not compile: %v\n%s", err, buf.String())
+	}
+	return out, nil
+}
+
+func main() {
+	defsPath := flag.String("defs", "messages.json", "message definition file")
+	goOut := flag.String("go", "", "server Go output file")
+	clientOut := flag.String("client", "", "Go client stub output file")
+	tsOut := flag.String("ts", "", "TypeScript client stub output file")
+	docsOut := flag.String("docs", "", "Markdown protocol documentation output file")
+	flag.Parse()
+
+	raw, err := os.ReadFile(*defsPath)
+	if err != nil {
+		log.Fatalf("Failed to read definitions: %v", err)
+	}
+
+	var defs Definitions
+	if err := json.Unmarshal(raw, &defs); err != nil {
+		log.Fatalf("Invalid definitions: %v", err)
+	}
+	if err := defs.validate(); err != nil {
+		log.Fatalf("Invalid definitions: %v", err)
+	}
+
+	data := templateData{Definitions: defs, Source: filepath.Base(*defsPath)}
+	for _, m := range defs.Messages {
+		if m.FromClient() && m.Payload != "" {
+			data.NeedsJSON = true
+		}
+	}
+
+	outputs := []struct {
+		path  string
+		pkg   string
+		tmpl  string
+		gofmt bool
+	}{
+		{*goOut, "server", serverTemplate, true},
+		{*clientOut, "client", clientTemplate, true},
+		{*tsOut, "", tsTemplate, false},
+		{*docsOut, "", docsTemplate, false},
+	}
+
+	for _, out := range outputs {
+		if out.path == "" {
+			continue
+		}
+		data.Package = out.pkg
+		code, err := render(out.tmpl, data, out.gofmt)
+		if err != nil {
+			log.Fatalf("Failed to generate %s: %v", out.path, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(out.path), 0o755); err != nil {
+			log.Fatalf("Failed to create directory for %s: %v", out.path, err)
+		}
+		if err := os.WriteFile(out.path, code, 0o644); err != nil {
+			log.Fatalf("Failed to write %s: %v", out.path, err)
+		}
+	}
+}
@@ -0,0 +1,530 @@
+package main
+
+const payloadStructs = `{{range .Payloads}}
+{{if .Doc}}// {{.Name}} {{.Doc}}
+{{end}}type {{.Name}} struct {
+{{- range .Fields}}
+	{{if .Doc}}// {{.Doc}}
+	{{end}}{{.Name}} {{.Type}} {{.Tag}}
+{{- end}}
+}
+{{end}}`
+
+const serverTemplate = `// Code generated by msggen from {{.Source}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- if .NeedsJSON}}
+	"encoding/json"
+{{- end}}
+	"fmt"
+)
+
+const (
+{{- range .Messages}}
+	{{if .Doc}}// {{.Doc}}
+	{{end}}{{.Name}} MessageType = "{{.Type}}"
+{{- end}}
+)
+` + payloadStructs + `
+// messageSchemas is the registry of every message type, see schemas.go
+var messageSchemas = map[MessageType]MessageSchema{
+{{- range .Messages}}
+	{{.Name}}: {Type: {{.Name}}, Direction: "{{.Direction}}", Version: {{.V}}{{if .Gameplay}}, Gameplay: true{{end}}{{if .Payload}}, Payload: "{{.Payload}}", newPayload: func() interface{} { return new({{.Payload}}) }{{end}}},
+{{- end}}
+}
+
+// gameplayMessages are the message types spectators are not allowed to send
+var gameplayMessages = map[MessageType]bool{
+{{- range .Messages}}{{if .Gameplay}}
+	{{.Name}}: true,
+{{- end}}{{end}}
+}
+
+// MessageHandler has one method per message type clients may send
+// Embed UnimplementedMessageHandler to only implement some of them
+type MessageHandler interface {
+{{- range .Messages}}{{if .FromClient}}
+	Handle{{.Name}}(player *Player, msg StructuredMessage{{if .Payload}}, payload {{.Payload}}{{end}}) error
+{{- end}}{{end}}
+}
+
+// UnimplementedMessageHandler rejects every message, embed it in your handler
+type UnimplementedMessageHandler struct{}
+{{range .Messages}}{{if .FromClient}}
+func (UnimplementedMessageHandler) Handle{{.Name}}(player *Player, msg StructuredMessage{{if .Payload}}, payload {{.Payload}}{{end}}) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+{{end}}{{end}}
+// DispatchMessage decodes the payload of msg and calls the matching handler method
+func DispatchMessage(h MessageHandler, player *Player, msg StructuredMessage) error {
+	switch msg.Type {
+{{- range .Messages}}{{if .FromClient}}
+	case {{.Name}}:
+{{- if .Payload}}
+		var payload {{.Payload}}
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.Handle{{.Name}}(player, msg, payload)
+{{- else}}
+		return h.Handle{{.Name}}(player, msg)
+{{- end}}
+{{- end}}{{end}}
+	default:
+		return fmt.Errorf("unknown message type %s", msg.Type)
+	}
+}
+{{range .Messages}}{{if and .FromServer .Payload}}
+// Send{{.Name}} sends a {{.Type}} message to one player
+func (gs *GameServer) Send{{.Name}}(playerID string, payload {{.Payload}}) error {
+	return gs.SendStructuredMessage(playerID, {{.Name}}, payload)
+}
+{{end}}{{end}}`
+
+const clientTemplate = `// Code generated by msggen from {{.Source}}. DO NOT EDIT.
+
+package {{.Package}}
+
+// MessageType identifies what a structured message is about
+type MessageType string
+
+const (
+{{- range .Messages}}
+	{{if .Doc}}// {{.Doc}}
+	{{end}}{{.Name}} MessageType = "{{.Type}}"
+{{- end}}
+)
+` + payloadStructs + `
+// MessageVersions is the payload version of every message type, sent along as "v"
+var MessageVersions = map[MessageType]int{
+{{- range .Messages}}
+	{{.Name}}: {{.V}},
+{{- end}}
+}
+
+// Sender is anything that can send a structured message to the server
+type Sender interface {
+	Send(msgType MessageType, payload interface{}) error
+}
+{{range .Messages}}{{if .FromClient}}
+// Send{{.Name}} sends a {{.Type}} message to the server
+func Send{{.Name}}(s Sender, payload {{if .Payload}}{{.Payload}}{{else}}interface{}{{end}}) error {
+	return s.Send({{.Name}}, payload)
+}
+{{end}}{{end}}`
+
+const tsTemplate = `// Code generated by msggen from {{.Source}}. DO NOT EDIT.
+
+export const MessageTypes = {
+{{- range .Messages}}
+  {{.Name}}: "{{.Type}}",
+{{- end}}
+} as const;
+
+export type MessageType = (typeof MessageTypes)[keyof typeof MessageTypes];
+
+// MessageVersions is the payload version of every message type, sent along as "v"
+export const MessageVersions: Record<MessageType, number> = {
+{{- range .Messages}}
+  "{{.Type}}": {{.V}},
+{{- end}}
+};
+{{range .Payloads}}
+{{if .Doc}}/** {{.Name}} {{.Doc}} */
+{{end}}export interface {{.Name}} {
+{{- range .Fields}}
+  {{.JSON}}{{if .OmitEmpty}}?{{end}}: {{tsType .Type}};
+{{- end}}
+}
+{{end}}
+export interface StructuredMessage<P = unknown> {
+  type: MessageType;
+  player_id: string;
+  payload: P;
+  timestamp: number;
+  seq?: number;
+  ack?: number;
+  // rtt is the recipient's round trip in ms, next to ack when the server sends it
+  rtt?: number;
+  v?: number;
+  // id is set on reliable messages, acked with MESSAGE_ACK
+  id?: number;
+  // dedup makes a client message safe to retry, see send
+  dedup?: number;
+  // req numbers a request, the answer carries the number in re
+  req?: number;
+  re?: number;
+  // sseq numbers the messages of a connection from 1, a resumed session carries on after
+  // the last_seq of its SESSION_TOKEN
+  sseq?: number;
+}
+
+export type Handler<P> = (payload: P, msg: StructuredMessage<P>) => void;
+
+export interface ConnectOptions {
+  // Reconnect after the connection drops, on by default
+  reconnect?: boolean;
+  // Backoff between attempts in ms, doubling from min to max
+  minDelay?: number;
+  maxDelay?: number;
+  onOpen?: () => void;
+  onClose?: (event: CloseEvent) => void;
+  // Called when a message skips sseq numbers (got > want) or arrives out of order (got < want)
+  onSequenceGap?: (want: number, got: number) => void;
+}
+
+// listServers asks a coordinator for the game servers of a region, least loaded first.
+// Connect to the address of the one picked.
+export async function listServers(coordinatorUrl: string, region = ""): Promise<ServerInfoPayload[]> {
+  const query = region ? "?region=" + encodeURIComponent(region) : "";
+  const response = await fetch(coordinatorUrl.replace(/\/$/, "") + "/servers" + query);
+  if (!response.ok) {
+    throw new Error("server list: status " + response.status);
+  }
+  return ((await response.json()) as ServerListPayload).servers;
+}
+
+// MessageClient wraps a WebSocket with typed send and receive helpers
+export class MessageClient {
+  private handlers = new Map<string, Handler<any>[]>();
+  private socket!: WebSocket;
+  private closed = false;
+  // Reliable ids already handled, retries of them are acked and dropped
+  private seenIds = new Set<number>();
+  // Our requests waiting for an answer, by req
+  private calls = new Map<number, (msg: StructuredMessage) => void>();
+  private reqs = 0;
+  // Chunked transfers from the server in progress, by transfer_id
+  private transfers = new Map<string, { start: TransferStartPayload; chunks: string[]; received: number }>();
+  private transferIds = 0;
+  // onTransferProgress is called for every chunk sent or received
+  onTransferProgress?: (id: string, type: MessageType, done: number, size: number) => void;
+  // onVoice is called for every voice frame, volume 255 is full volume
+  onVoice?: (sender: string, seq: number, volume: number, packet: Uint8Array) => void;
+  // playerId is the ID the server gave the current connection
+  playerId = "";
+  // lastSeq is the last sseq received on the current connection
+  lastSeq = 0;
+  onSequenceGap?: (want: number, got: number) => void;
+
+  constructor(socket?: WebSocket) {
+    if (socket) {
+      this.attach(socket);
+    }
+  }
+
+  // connect opens a socket to url and, unless told otherwise, reopens it when it drops.
+  // Handlers stay registered across reconnects.
+  static connect(url: string, options: ConnectOptions = {}): MessageClient {
+    const client = new MessageClient();
+    client.onSequenceGap = options.onSequenceGap;
+    const min = options.minDelay ?? 500;
+    const max = options.maxDelay ?? 30000;
+    let delay = min;
+
+    const open = () => {
+      const socket = new WebSocket(url);
+      socket.addEventListener("open", () => {
+        delay = min;
+        options.onOpen?.();
+      });
+      socket.addEventListener("close", (event) => {
+        options.onClose?.(event);
+        if (client.closed || options.reconnect === false) {
+          return;
+        }
+        setTimeout(open, delay);
+        delay = Math.min(delay * 2, max);
+      });
+      client.attach(socket);
+    };
+    open();
+    return client;
+  }
+
+  private attach(socket: WebSocket): void {
+    this.socket = socket;
+    this.lastSeq = 0; // Every connection is numbered from 1
+    socket.binaryType = "arraybuffer";
+    socket.addEventListener("message", (event) => {
+      if (event.data instanceof ArrayBuffer) {
+        this.receiveVoice(new Uint8Array(event.data));
+        return;
+      }
+      let msg: StructuredMessage;
+      try {
+        msg = JSON.parse(event.data);
+      } catch {
+        return; // Not a structured message
+      }
+      if (msg.player_id) {
+        this.playerId = msg.player_id;
+      }
+      if (msg.sseq) {
+        this.checkSeq(msg.sseq);
+      }
+      if (msg.id && !this.ackReliable(msg.id)) {
+        return;
+      }
+      if (msg.re) {
+        // Answers go to their call, or nowhere once it gave up
+        this.calls.get(msg.re)?.(msg);
+        return;
+      }
+      if (msg.type === "TRANSFER_START" || msg.type === "TRANSFER_CHUNK" || msg.type === "TRANSFER_ABORT") {
+        const assembled = this.receiveTransfer(msg);
+        if (!assembled) {
+          return;
+        }
+        msg = assembled;
+      }
+      if (msg.type === "PING") {
+        // Answered right away, the server measures the round trip with it
+        this.send("PONG" as MessageType, { n: (msg.payload as { n: number }).n });
+      }
+      for (const handler of this.handlers.get(msg.type) ?? []) {
+        handler(msg.payload, msg);
+      }
+    });
+  }
+
+  // receiveVoice reads a voice frame, see messages/binary.go for the layout
+  private receiveVoice(frame: Uint8Array): void {
+    if (frame.length < 5 || frame[0] !== 0x02 || frame.length < 5 + frame[4]) {
+      return;
+    }
+    const sender = new TextDecoder().decode(frame.subarray(5, 5 + frame[4]));
+    this.onVoice?.(sender, (frame[1] << 8) | frame[2], frame[3], frame.subarray(5 + frame[4]));
+  }
+
+  // receiveTransfer assembles chunked payloads, returning the message once complete
+  private receiveTransfer(msg: StructuredMessage): StructuredMessage | undefined {
+    if (msg.type === "TRANSFER_START") {
+      const start = msg.payload as TransferStartPayload;
+      this.transfers.set(start.transfer_id, { start, chunks: [], received: 0 });
+      return undefined;
+    }
+    if (msg.type === "TRANSFER_ABORT") {
+      this.transfers.delete((msg.payload as TransferAbortPayload).transfer_id);
+      return undefined;
+    }
+    const chunk = msg.payload as TransferChunkPayload;
+    const transfer = this.transfers.get(chunk.transfer_id);
+    if (!transfer || transfer.chunks[chunk.index] !== undefined) {
+      return undefined;
+    }
+    const bytes = atob(chunk.data);
+    transfer.chunks[chunk.index] = bytes;
+    transfer.received += bytes.length;
+    const { start } = transfer;
+    this.onTransferProgress?.(start.transfer_id, start.type, transfer.received, start.size);
+    if (transfer.received < start.size) {
+      return undefined;
+    }
+    this.transfers.delete(start.transfer_id);
+    const utf8 = Uint8Array.from(transfer.chunks.join(""), (c) => c.charCodeAt(0));
+    return { ...msg, type: start.type, payload: JSON.parse(new TextDecoder().decode(utf8)) };
+  }
+
+  private checkSeq(seq: number): void {
+    const want = this.lastSeq + 1;
+    this.lastSeq = Math.max(this.lastSeq, seq);
+    if (seq !== want) {
+      this.onSequenceGap?.(want, seq);
+    }
+  }
+
+  // ackReliable acks a reliable message, false when it is a retry of one already handled
+  private ackReliable(id: number): boolean {
+    if (this.socket.readyState === WebSocket.OPEN) {
+      this.send("MESSAGE_ACK" as MessageType, { id });
+    }
+    if (this.seenIds.has(id)) {
+      return false;
+    }
+    this.seenIds.add(id);
+    if (this.seenIds.size > 1024) {
+      this.seenIds.delete(this.seenIds.values().next().value as number);
+    }
+    return true;
+  }
+
+  // close closes the socket for good
+  close(): void {
+    this.closed = true;
+    this.socket.close(1000);
+  }
+
+  // dedup makes the message safe to retry, the server acks it and applies it once
+  send(type: MessageType, payload: unknown = null, seq?: number, dedup?: number): void {
+    this.write({ type, payload, seq, dedup });
+  }
+
+  // call sends a request and resolves with the message answering it. It rejects when the
+  // server answers with ERROR or nothing comes within timeout ms.
+  call<R = unknown>(type: MessageType, payload: unknown = null, timeout = 10000): Promise<StructuredMessage<R>> {
+    const req = ++this.reqs;
+    return new Promise((resolve, reject) => {
+      const timer = setTimeout(() => {
+        this.calls.delete(req);
+        reject(new Error(type + " request " + req + " timed out"));
+      }, timeout);
+      this.calls.set(req, (msg) => {
+        clearTimeout(timer);
+        this.calls.delete(req);
+        if (msg.type === "ERROR") {
+          const e = msg.payload as ErrorPayload;
+          reject(Object.assign(new Error(e.code + ": " + e.reason), { code: e.code }));
+        } else {
+          resolve(msg as StructuredMessage<R>);
+        }
+      });
+      try {
+        this.write({ type, payload, req });
+      } catch (err) {
+        clearTimeout(timer);
+        this.calls.delete(req);
+        reject(err);
+      }
+    });
+  }
+
+  // sendChunked sends a payload too large for one frame in chunks of chunkSize bytes, when
+  // the server has the chunked_transfer capability
+  sendChunked(type: MessageType, payload: unknown, chunkSize = 16384): void {
+    const data = new TextEncoder().encode(JSON.stringify(payload));
+    const transfer_id = String(++this.transferIds);
+    this.write({ type: "TRANSFER_START" as MessageType, payload: { transfer_id, type, size: data.length, chunk_size: chunkSize } });
+    for (let index = 0; index * chunkSize < data.length; index++) {
+      const end = Math.min((index + 1) * chunkSize, data.length);
+      const chunk = String.fromCharCode(...data.subarray(index * chunkSize, end));
+      this.write({ type: "TRANSFER_CHUNK" as MessageType, payload: { transfer_id, index, data: btoa(chunk) } });
+      this.onTransferProgress?.(transfer_id, type, end, data.length);
+    }
+  }
+
+  // sendVoice sends one opus packet to the players of the room, when the server has the
+  // voice capability
+  sendVoice(seq: number, volume: number, packet: Uint8Array): void {
+    if (this.socket.readyState !== WebSocket.OPEN) {
+      throw new Error("not connected");
+    }
+    const frame = new Uint8Array(5 + packet.length);
+    frame.set([0x02, (seq >> 8) & 0xff, seq & 0xff, volume, 0]);
+    frame.set(packet, 5);
+    this.socket.send(frame);
+  }
+
+  // reply answers a request of the server
+  reply(req: StructuredMessage, type: MessageType, payload: unknown = null): void {
+    this.write({ type, payload, re: req.req });
+  }
+
+  // replyError refuses a request of the server with an ERROR code
+  replyError(req: StructuredMessage, code: string, reason: string): void {
+    this.write({ type: "ERROR" as MessageType, payload: { code, type: req.type, reason }, re: req.req });
+  }
+
+  private write(msg: Pick<StructuredMessage, "type" | "payload" | "seq" | "dedup" | "req" | "re">): void {
+    if (this.socket.readyState !== WebSocket.OPEN) {
+      throw new Error("not connected");
+    }
+    this.socket.send(
+      JSON.stringify({ ...msg, player_id: this.playerId, timestamp: Date.now(), v: MessageVersions[msg.type] })
+    );
+  }
+
+  on<P>(type: MessageType, handler: Handler<P>): void {
+    const list = this.handlers.get(type) ?? [];
+    list.push(handler);
+    this.handlers.set(type, list);
+  }
+{{range .Messages}}{{if .FromClient}}
+  send{{.Name}}(payload{{if .Payload}}: {{.Payload}}{{else}}?: unknown{{end}}, seq?: number): void {
+    this.send(MessageTypes.{{.Name}}, payload, seq);
+  }
+{{end}}{{end}}{{range .Messages}}{{if .FromServer}}
+  on{{.Name}}(handler: Handler<{{if .Payload}}{{.Payload}}{{else}}unknown{{end}}>): void {
+    this.on(MessageTypes.{{.Name}}, handler);
+  }
+{{end}}{{end}}}
+`
+
+const docsTemplate = `<!-- Code generated by msggen from {{.Source}}. DO NOT EDIT. -->
+
+# Message protocol
+
+Every message is a JSON object:
+
+` + "```json" + `
+{"type": "PLAYER_MOVE", "player_id": "...", "payload": {...}, "timestamp": 0, "seq": 1, "v": 1}
+` + "```" + `
+
+` + "`v`" + ` is the payload version of the message type. Messages with an unknown type, an
+unsupported version or a payload that doesn't match the schema are answered with ` + "`SCHEMA_ERROR`" + `.
+Every rejected message, for whatever reason, is also answered with ` + "`ERROR`" + `: switch on its
+` + "`code`" + ` (` + "`RATE_LIMITED`" + `, ` + "`INVALID_PAYLOAD`" + `, ` + "`NOT_IN_ROOM`" + `, ` + "`UNAUTHORIZED`" + ` or ` + "`REJECTED`" + `)
+and match it to what you sent by ` + "`type`" + `, ` + "`seq`" + ` and ` + "`dedup`" + `.
+
+A message with ` + "`req`" + ` is a request: the answer, whatever its type, carries the same number
+in ` + "`re`" + `, and a refused request is answered with ` + "`ERROR`" + ` and ` + "`re`" + `. Both sides number their
+own requests per connection. The server asks clients too, answer its requests with ` + "`re`" + `
+set to their ` + "`req`" + `, or with ` + "`ERROR`" + ` to refuse them.
+
+Payloads too large for one frame come as ` + "`TRANSFER_START`" + `, announcing the message ` + "`type`" + `, ` + "`size`" + `
+and ` + "`chunk_size`" + `, then ` + "`TRANSFER_CHUNK`" + `s with the base64 bytes. Once all chunks are in, handle the
+payload as a message of that type. Servers with the ` + "`chunked_transfer`" + ` capability take uploads the
+same way, ` + "`TRANSFER_ABORT`" + ` gives up a transfer in either direction.
+
+Servers with the ` + "`voice`" + ` capability relay voice as binary frames of opus audio to the other
+players of the room, see messages/binary.go for the layout. Frames from clients leave the
+sender empty, the server fills it in and fades the volume by distance when voice is ranged.
+Frames over the sender's bandwidth budget are refused with ` + "`ERROR`" + ` of type ` + "`VOICE`" + `.
+
+Deployments with several servers list them on a coordinator: ` + "`GET /servers?region=eu`" + ` on it
+answers a ` + "`ServerListPayload`" + ` with the address, region and population of every server,
+least loaded first. Pick one and connect to its ` + "`address`" + `.
+
+Servers with the ` + "`rtc_signaling`" + ` capability bootstrap WebRTC connections between the players
+of a room. Send ` + "`RTC_JOIN`" + ` once in the room: ` + "`RTC_PEERS`" + ` lists the peers already there, which
+get ` + "`RTC_PEER_JOINED`" + ` and send you their offers. ` + "`RTC_OFFER`" + `, ` + "`RTC_ANSWER`" + ` and ` + "`RTC_CANDIDATE`" + `
+go to the peer in ` + "`peer_id`" + ` and arrive with ` + "`peer_id`" + ` set to the sender.
+
+The server adds ` + "`sseq`" + ` to every message it sends, counting up from 1 on each connection.
+A gap or a number going backwards means messages were lost or reordered. A connection that
+resumed a session carries on after the ` + "`last_seq`" + ` of its ` + "`SESSION_TOKEN`" + `: if that is above
+the last ` + "`sseq`" + ` the client saw before the disconnect, the messages in between were lost. Reliable messages
+also carry an ` + "`id`" + `, answer them with ` + "`MESSAGE_ACK`" + `.
+
+Clients can put a ` + "`dedup`" + ` id on messages they may retry, like purchases. The server acks
+them with ` + "`MESSAGE_ACK`" + ` and drops repeats of an id it saw recently on the same connection.
+
+` + "`timestamp`" + ` is when the message was sent, in unix milliseconds of the sender's clock. Clients
+line server timestamps up with their own clock through ` + "`TIME_SYNC`" + ` round trips.
+
+With RTT measurement on, the server sends ` + "`PING`" + ` every few seconds, answer it with a ` + "`PONG`" + `
+carrying the same ` + "`n`" + `. The smoothed round trip comes back in the next ` + "`PING`" + `, and may be
+sent as ` + "`rtt`" + ` (milliseconds) next to ` + "`ack`" + ` on state messages.
+
+## Messages
+
+| Type | Direction | Payload | Version | Description |
+| --- | --- | --- | --- | --- |
+{{- range .Messages}}
+| ` + "`{{.Type}}`" + ` | {{.DirectionText}} | {{if .Payload}}[{{.Payload}}](#{{lower .Payload}}){{else}}-{{end}} | {{.V}} | {{if .Gameplay}}Gameplay. {{end}}{{.Doc}} |
+{{- end}}
+
+## Payloads
+{{range .Payloads}}
+### {{.Name}}
+{{if .Doc}}
+{{.Name}} {{.Doc}}
+{{end}}
+| Field | Type | Description |
+| --- | --- | --- |
+{{- range .Fields}}
+| ` + "`{{.JSON}}`" + `{{if .OmitEmpty}} (optional){{end}} | ` + "`{{tsType .Type}}`" + ` | {{.Doc}}{{if .Validate}}{{if .Doc}} {{end}}Rules: ` + "`{{.Validate}}`" + `{{end}} |
+{{- end}}
+{{end}}`
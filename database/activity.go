@@ -0,0 +1,52 @@
+package database
+
+import (
+	"sync"
+	"time"
+)
+
+// ActivityRecord is one entry in a player's activity log
+type ActivityRecord struct {
+	AccountID string      `json:"account_id"`
+	PlayerID  string      `json:"player_id"`
+	Kind      string      `json:"kind"`
+	At        time.Time   `json:"at"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+// ActivityStore persists player activity and state updates
+// Records of one player are always saved in the order they happened
+type ActivityStore interface {
+	SaveActivity(record ActivityRecord) error
+}
+
+// MemoryActivityStore keeps records in memory, good enough for development
+type MemoryActivityStore struct {
+	mu      sync.Mutex
+	records []ActivityRecord
+}
+
+func NewMemoryActivityStore() *MemoryActivityStore {
+	return &MemoryActivityStore{}
+}
+
+func (s *MemoryActivityStore) SaveActivity(record ActivityRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, record)
+	return nil
+}
+
+// Records returns a copy of everything saved for an account
+func (s *MemoryActivityStore) Records(accountID string) []ActivityRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []ActivityRecord
+	for _, r := range s.records {
+		if r.AccountID == accountID {
+			records = append(records, r)
+		}
+	}
+	return records
+}
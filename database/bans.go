@@ -0,0 +1,174 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Ban kinds
+const (
+	BanAccount = "account"
+	BanIP      = "ip"
+)
+
+// Ban keeps an account or an IP address out of the server
+type Ban struct {
+	Kind      string    `json:"kind"`
+	Value     string    `json:"value"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt is zero for permanent bans
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Active reports whether the ban still applies at now
+func (b Ban) Active(now time.Time) bool {
+	return b.ExpiresAt.IsZero() || now.Before(b.ExpiresAt)
+}
+
+// BanStore persists bans, adding a ban for the same kind and value replaces it
+type BanStore interface {
+	AddBan(ban Ban) error
+	RemoveBan(kind, value string) error
+	// ActiveBan returns the ban that applies to kind and value at now, if any
+	ActiveBan(kind, value string, now time.Time) (Ban, bool, error)
+	Bans() ([]Ban, error)
+}
+
+// MemoryBanStore keeps bans in memory, they are lost on restart, see FileBanStore
+type MemoryBanStore struct {
+	mu   sync.Mutex
+	bans map[string]Ban
+}
+
+func NewMemoryBanStore() *MemoryBanStore {
+	return &MemoryBanStore{bans: make(map[string]Ban)}
+}
+
+func banKey(kind, value string) string {
+	return kind + ":" + value
+}
+
+func (s *MemoryBanStore) AddBan(ban Ban) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bans[banKey(ban.Kind, ban.Value)] = ban
+	return nil
+}
+
+func (s *MemoryBanStore) RemoveBan(kind, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.bans, banKey(kind, value))
+	return nil
+}
+
+func (s *MemoryBanStore) ActiveBan(kind, value string, now time.Time) (Ban, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ban, ok := s.bans[banKey(kind, value)]
+	if ok && !ban.Active(now) {
+		delete(s.bans, banKey(kind, value))
+		return Ban{}, false, nil
+	}
+	return ban, ok, nil
+}
+
+// Bans returns every ban that hasn't expired yet
+func (s *MemoryBanStore) Bans() ([]Ban, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	bans := make([]Ban, 0, len(s.bans))
+	for key, ban := range s.bans {
+		if !ban.Active(now) {
+			delete(s.bans, key)
+			continue
+		}
+		bans = append(bans, ban)
+	}
+	return bans, nil
+}
+
+// FileBanStore keeps bans in a JSON file so they survive restarts. Lookups are served
+// from memory, the whole list is written again on every change, which is fine for the
+// few thousand bans a server has.
+type FileBanStore struct {
+	path string
+	// mu orders the writes of the file
+	mu  sync.Mutex
+	mem *MemoryBanStore
+}
+
+// NewFileBanStore loads the bans in the file at path, a missing file is an empty list
+func NewFileBanStore(path string) (*FileBanStore, error) {
+	s := &FileBanStore{path: path, mem: NewMemoryBanStore()}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var bans []Ban
+	if err := json.Unmarshal(data, &bans); err != nil {
+		return nil, fmt.Errorf("corrupt ban file %s: %v", path, err)
+	}
+	for _, ban := range bans {
+		s.mem.AddBan(ban)
+	}
+	return s, nil
+}
+
+func (s *FileBanStore) AddBan(ban Ban) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mem.AddBan(ban)
+	return s.saveLocked()
+}
+
+func (s *FileBanStore) RemoveBan(kind, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mem.RemoveBan(kind, value)
+	return s.saveLocked()
+}
+
+func (s *FileBanStore) ActiveBan(kind, value string, now time.Time) (Ban, bool, error) {
+	return s.mem.ActiveBan(kind, value, now)
+}
+
+func (s *FileBanStore) Bans() ([]Ban, error) {
+	return s.mem.Bans()
+}
+
+// saveLocked writes the bans that haven't expired to a temporary file and renames it over
+// the old one, so a crash leaves either the old or the new list behind
+func (s *FileBanStore) saveLocked() error {
+	bans, _ := s.mem.Bans()
+	sort.Slice(bans, func(i, j int) bool {
+		return banKey(bans[i].Kind, bans[i].Value) < banKey(bans[j].Kind, bans[j].Value)
+	})
+	data, err := json.MarshalIndent(bans, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to save bans: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to save bans: %v", err)
+	}
+	return nil
+}
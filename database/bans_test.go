@@ -0,0 +1,69 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileBanStoreSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+	store, err := NewFileBanStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	store.AddBan(Ban{Kind: BanAccount, Value: "cheater", Reason: "aimbot", CreatedAt: now})
+	store.AddBan(Ban{Kind: BanIP, Value: "10.0.0.1", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+	store.AddBan(Ban{Kind: BanAccount, Value: "forgiven", CreatedAt: now})
+	if err := store.RemoveBan(BanAccount, "forgiven"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewFileBanStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ban, banned, err := reopened.ActiveBan(BanAccount, "cheater", now)
+	if err != nil || !banned || ban.Reason != "aimbot" {
+		t.Fatalf("account ban after reopen: %+v %v %v", ban, banned, err)
+	}
+	if _, banned, _ := reopened.ActiveBan(BanIP, "10.0.0.1", now); !banned {
+		t.Fatal("ip ban lost on reopen")
+	}
+	if _, banned, _ := reopened.ActiveBan(BanAccount, "forgiven", now); banned {
+		t.Fatal("removed ban came back on reopen")
+	}
+}
+
+func TestFileBanStoreMissingAndCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileBanStore(filepath.Join(dir, "none.json"))
+	if err != nil {
+		t.Fatalf("missing file: %v", err)
+	}
+	if bans, _ := store.Bans(); len(bans) != 0 {
+		t.Fatalf("%d bans from a missing file", len(bans))
+	}
+
+	corrupt := filepath.Join(dir, "corrupt.json")
+	os.WriteFile(corrupt, []byte("{"), 0o600)
+	if _, err := NewFileBanStore(corrupt); err == nil {
+		t.Fatal("corrupt file loaded")
+	}
+}
+
+func TestFileBanStoreDropsExpired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+	store, _ := NewFileBanStore(path)
+	now := time.Now()
+	store.AddBan(Ban{Kind: BanAccount, Value: "short", CreatedAt: now, ExpiresAt: now.Add(time.Millisecond)})
+	time.Sleep(5 * time.Millisecond)
+	store.AddBan(Ban{Kind: BanAccount, Value: "long", CreatedAt: now})
+
+	reopened, _ := NewFileBanStore(path)
+	if bans, _ := reopened.Bans(); len(bans) != 1 || bans[0].Value != "long" {
+		t.Fatalf("bans after reopen: %+v", bans)
+	}
+}
@@ -0,0 +1,45 @@
+package database
+
+import "sync"
+
+// BlockStore persists who blocked whom
+type BlockStore interface {
+	Block(accountID, blockedID string) error
+	Unblock(accountID, blockedID string) error
+	IsBlocked(accountID, blockedID string) (bool, error)
+}
+
+// MemoryBlockStore keeps block lists in memory, they are lost on restart
+type MemoryBlockStore struct {
+	mu      sync.RWMutex
+	blocked map[string]map[string]struct{}
+}
+
+func NewMemoryBlockStore() *MemoryBlockStore {
+	return &MemoryBlockStore{blocked: make(map[string]map[string]struct{})}
+}
+
+func (s *MemoryBlockStore) Block(accountID, blockedID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.blocked[accountID] == nil {
+		s.blocked[accountID] = make(map[string]struct{})
+	}
+	s.blocked[accountID][blockedID] = struct{}{}
+	return nil
+}
+
+func (s *MemoryBlockStore) Unblock(accountID, blockedID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.blocked[accountID], blockedID)
+	return nil
+}
+
+func (s *MemoryBlockStore) IsBlocked(accountID, blockedID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, blocked := s.blocked[accountID][blockedID]
+	return blocked, nil
+}
@@ -0,0 +1,56 @@
+package database
+
+import "sync"
+
+// FriendStore persists friend lists, friendships are mutual
+type FriendStore interface {
+	Friends(accountID string) ([]string, error)
+	AddFriend(accountID, friendID string) error
+	RemoveFriend(accountID, friendID string) error
+}
+
+// MemoryFriendStore keeps friend lists in memory, they are lost on restart
+type MemoryFriendStore struct {
+	mu      sync.RWMutex
+	friends map[string]map[string]struct{}
+}
+
+func NewMemoryFriendStore() *MemoryFriendStore {
+	return &MemoryFriendStore{friends: make(map[string]map[string]struct{})}
+}
+
+func (s *MemoryFriendStore) Friends(accountID string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	friends := make([]string, 0, len(s.friends[accountID]))
+	for id := range s.friends[accountID] {
+		friends = append(friends, id)
+	}
+	return friends, nil
+}
+
+func (s *MemoryFriendStore) AddFriend(accountID, friendID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.link(accountID, friendID)
+	s.link(friendID, accountID)
+	return nil
+}
+
+func (s *MemoryFriendStore) RemoveFriend(accountID, friendID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.friends[accountID], friendID)
+	delete(s.friends[friendID], accountID)
+	return nil
+}
+
+func (s *MemoryFriendStore) link(from, to string) {
+	if s.friends[from] == nil {
+		s.friends[from] = make(map[string]struct{})
+	}
+	s.friends[from][to] = struct{}{}
+}
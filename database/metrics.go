@@ -0,0 +1,185 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// MetricsPoint is one periodic snapshot of the server's key metrics
+type MetricsPoint struct {
+	At              time.Time `json:"at"`
+	NodeID          string    `json:"node_id,omitempty"`
+	Players         int       `json:"players"`
+	Rooms           int       `json:"rooms"`
+	MessagesInRate  float64   `json:"messages_in_rate"`
+	MessagesOutRate float64   `json:"messages_out_rate"`
+	BytesIn         uint64    `json:"bytes_in"`
+	BytesOut        uint64    `json:"bytes_out"`
+	WriteErrors     uint64    `json:"write_errors"`
+	ProcessErrors   uint64    `json:"process_errors"`
+}
+
+// MetricsStore keeps metrics snapshots for trend queries
+type MetricsStore interface {
+	SaveMetrics(point MetricsPoint) error
+	// QueryMetrics returns the points in [from, to], oldest first
+	QueryMetrics(from, to time.Time) ([]MetricsPoint, error)
+}
+
+// MemoryMetricsStore keeps the most recent points in memory, good enough for small
+// deployments that don't need the history after a restart, see FileMetricsStore
+type MemoryMetricsStore struct {
+	mu     sync.Mutex
+	points []MetricsPoint
+	max    int
+}
+
+// NewMemoryMetricsStore keeps at most max points, 0 means no limit
+func NewMemoryMetricsStore(max int) *MemoryMetricsStore {
+	return &MemoryMetricsStore{max: max}
+}
+
+func (s *MemoryMetricsStore) SaveMetrics(point MetricsPoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.points = append(s.points, point)
+	if s.max > 0 && len(s.points) > s.max {
+		s.points = append(s.points[:0], s.points[len(s.points)-s.max:]...)
+	}
+	return nil
+}
+
+func (s *MemoryMetricsStore) QueryMetrics(from, to time.Time) ([]MetricsPoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var points []MetricsPoint
+	for _, p := range s.points {
+		if !p.At.Before(from) && !p.At.After(to) {
+			points = append(points, p)
+		}
+	}
+	return points, nil
+}
+
+// FileMetricsStore appends every point to a file of JSON lines so the history survives
+// restarts. The last max points are kept in memory to answer queries, the file is
+// rewritten with just those once it holds twice as many.
+type FileMetricsStore struct {
+	mem  *MemoryMetricsStore
+	path string
+	max  int
+
+	mu      sync.Mutex
+	file    *os.File
+	written int
+}
+
+// NewFileMetricsStore loads the points in the file at path and appends to it, max works
+// like in NewMemoryMetricsStore
+func NewFileMetricsStore(path string, max int) (*FileMetricsStore, error) {
+	s := &FileMetricsStore{mem: NewMemoryMetricsStore(max), path: path, max: max}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	for n, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		var point MetricsPoint
+		if err := json.Unmarshal(line, &point); err != nil {
+			// A crash can leave half a line at the end, everything before it is fine
+			if n == len(lines)-1 {
+				break
+			}
+			return nil, fmt.Errorf("metrics history %s line %d: %v", path, n+1, err)
+		}
+		s.mem.SaveMetrics(point)
+		s.written++
+	}
+
+	if s.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileMetricsStore) SaveMetrics(point MetricsPoint) error {
+	line, err := json.Marshal(point)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	s.mem.SaveMetrics(point)
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to save metrics: %v", err)
+	}
+	s.written++
+	if s.max > 0 && s.written >= 2*s.max {
+		return s.compactLocked()
+	}
+	return nil
+}
+
+func (s *FileMetricsStore) QueryMetrics(from, to time.Time) ([]MetricsPoint, error) {
+	return s.mem.QueryMetrics(from, to)
+}
+
+// Close closes the file, saving afterwards fails
+func (s *FileMetricsStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// compactLocked rewrites the file with the points kept in memory
+func (s *FileMetricsStore) compactLocked() error {
+	s.mem.mu.Lock()
+	points := append([]MetricsPoint(nil), s.mem.points...)
+	s.mem.mu.Unlock()
+
+	var buf bytes.Buffer
+	for _, p := range points {
+		line, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to compact metrics history: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to compact metrics history: %v", err)
+	}
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	s.file.Close()
+	s.file = file
+	s.written = len(points)
+	return nil
+}
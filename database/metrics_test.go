@@ -0,0 +1,84 @@
+package database
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileMetricsStoreSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.jsonl")
+	store, err := NewFileMetricsStore(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Truncate(time.Second)
+	for i := 0; i < 3; i++ {
+		if err := store.SaveMetrics(MetricsPoint{At: start.Add(time.Duration(i) * time.Minute), Players: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Close()
+
+	reopened, err := NewFileMetricsStore(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	points, _ := reopened.QueryMetrics(start, start.Add(time.Hour))
+	if len(points) != 3 || points[2].Players != 2 || !points[2].At.Equal(start.Add(2*time.Minute)) {
+		t.Fatalf("points after reopen: %+v", points)
+	}
+}
+
+func TestFileMetricsStoreCompacts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.jsonl")
+	store, err := NewFileMetricsStore(path, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Truncate(time.Second)
+	for i := 0; i < 12; i++ {
+		if err := store.SaveMetrics(MetricsPoint{At: start.Add(time.Duration(i) * time.Minute), Players: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Close()
+
+	data, _ := os.ReadFile(path)
+	if lines := bytes.Count(data, []byte("\n")); lines >= 10 {
+		t.Fatalf("file kept %d lines, want it compacted below 10", lines)
+	}
+	reopened, err := NewFileMetricsStore(path, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	points, _ := reopened.QueryMetrics(start, start.Add(time.Hour))
+	if len(points) != 5 || points[0].Players != 7 || points[4].Players != 11 {
+		t.Fatalf("points after compaction: %+v", points)
+	}
+}
+
+func TestFileMetricsStoreTornLine(t *testing.T) {
+	dir := t.TempDir()
+	torn := filepath.Join(dir, "torn.jsonl")
+	os.WriteFile(torn, []byte(`{"at":"2024-01-01T00:00:00Z","players":4}`+"\n"+`{"at":"2024-01-01T00:01`), 0o600)
+	store, err := NewFileMetricsStore(torn, 0)
+	if err != nil {
+		t.Fatalf("torn last line: %v", err)
+	}
+	store.Close()
+	points, _ := store.QueryMetrics(time.Time{}, time.Now())
+	if len(points) != 1 || points[0].Players != 4 {
+		t.Fatalf("points from a torn file: %+v", points)
+	}
+
+	corrupt := filepath.Join(dir, "corrupt.jsonl")
+	os.WriteFile(corrupt, []byte("garbage\n"+`{"at":"2024-01-01T00:00:00Z"}`+"\n"), 0o600)
+	if _, err := NewFileMetricsStore(corrupt, 0); err == nil {
+		t.Fatal("expected an error for a corrupt line in the middle")
+	}
+}
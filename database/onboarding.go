@@ -0,0 +1,52 @@
+package database
+
+import (
+	"sync"
+	"time"
+)
+
+// OnboardingRecord is how far an account got through onboarding
+type OnboardingRecord struct {
+	AccountID string `json:"account_id"`
+	// Done lists the finished steps, in order
+	Done                 []string  `json:"done"`
+	Completed            bool      `json:"completed"`
+	AcceptedTermsVersion string    `json:"accepted_terms_version,omitempty"`
+	DisplayName          string    `json:"display_name,omitempty"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+// OnboardingStore persists onboarding progress so returning players skip finished steps
+type OnboardingStore interface {
+	// LoadOnboarding returns false when the account never connected before
+	LoadOnboarding(accountID string) (OnboardingRecord, bool, error)
+	SaveOnboarding(record OnboardingRecord) error
+}
+
+// MemoryOnboardingStore keeps progress in memory, it is lost on restart
+type MemoryOnboardingStore struct {
+	mu      sync.RWMutex
+	records map[string]OnboardingRecord
+}
+
+func NewMemoryOnboardingStore() *MemoryOnboardingStore {
+	return &MemoryOnboardingStore{records: make(map[string]OnboardingRecord)}
+}
+
+func (s *MemoryOnboardingStore) LoadOnboarding(accountID string) (OnboardingRecord, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.records[accountID]
+	record.Done = append([]string(nil), record.Done...)
+	return record, ok, nil
+}
+
+func (s *MemoryOnboardingStore) SaveOnboarding(record OnboardingRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record.Done = append([]string(nil), record.Done...)
+	s.records[record.AccountID] = record
+	return nil
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"sync"
+	"time"
+)
+
+// Rating is an account's skill rating
+type Rating struct {
+	AccountID string  `json:"account_id"`
+	Value     float64 `json:"value"`
+	// Deviation is how unsure the rating is, only Glicko uses it
+	Deviation float64   `json:"deviation"`
+	Games     int       `json:"games"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RatingStore persists ratings
+type RatingStore interface {
+	// LoadRating returns false for accounts that never played a rated match
+	LoadRating(accountID string) (Rating, bool, error)
+	SaveRating(rating Rating) error
+}
+
+// MemoryRatingStore keeps ratings in memory, they are lost on restart
+type MemoryRatingStore struct {
+	mu      sync.RWMutex
+	ratings map[string]Rating
+}
+
+func NewMemoryRatingStore() *MemoryRatingStore {
+	return &MemoryRatingStore{ratings: make(map[string]Rating)}
+}
+
+func (s *MemoryRatingStore) LoadRating(accountID string) (Rating, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rating, ok := s.ratings[accountID]
+	return rating, ok, nil
+}
+
+func (s *MemoryRatingStore) SaveRating(rating Rating) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ratings[rating.AccountID] = rating
+	return nil
+}
@@ -0,0 +1,238 @@
+package database
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RedisSessionStore keeps sessions in Redis, so they survive restarts and every node of a
+// deployment sees the same ones. Expiry is left to Redis.
+//
+// It speaks the Redis protocol itself over one connection, commands are short and
+// sessions are only touched on connect, disconnect and SetSessionState. A broken
+// connection is dialed again on the next command.
+type RedisSessionStore struct {
+	opts RedisOptions
+
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// RedisOptions says where the Redis server is
+type RedisOptions struct {
+	// Addr is host:port
+	Addr     string
+	Password string
+	DB       int
+	// Prefix is put in front of every key, "session:" when empty
+	Prefix string
+	// Timeout bounds dialing and every command, 3 seconds when zero
+	Timeout time.Duration
+}
+
+const (
+	defaultRedisPrefix  = "session:"
+	defaultRedisTimeout = 3 * time.Second
+)
+
+// errRedisNil is the nil reply, e.g. of GET on a missing key
+var errRedisNil = errors.New("redis: nil")
+
+func NewRedisSessionStore(opts RedisOptions) *RedisSessionStore {
+	if opts.Prefix == "" {
+		opts.Prefix = defaultRedisPrefix
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultRedisTimeout
+	}
+	return &RedisSessionStore{opts: opts}
+}
+
+func (s *RedisSessionStore) SaveSession(record SessionRecord, ttl time.Duration) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	ms := ttl.Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	_, err = s.do("SET", s.opts.Prefix+record.Token, string(data), "PX", strconv.FormatInt(ms, 10))
+	return err
+}
+
+func (s *RedisSessionStore) LoadSession(token string) (SessionRecord, bool, error) {
+	return s.get("GET", token)
+}
+
+// TakeSession uses GETDEL, so it needs Redis 6.2 or later
+func (s *RedisSessionStore) TakeSession(token string) (SessionRecord, bool, error) {
+	return s.get("GETDEL", token)
+}
+
+// get runs GET or GETDEL on the session of token
+func (s *RedisSessionStore) get(command, token string) (SessionRecord, bool, error) {
+	reply, err := s.do(command, s.opts.Prefix+token)
+	if err == errRedisNil {
+		return SessionRecord{}, false, nil
+	}
+	if err != nil {
+		return SessionRecord{}, false, err
+	}
+	data, ok := reply.(string)
+	if !ok {
+		return SessionRecord{}, false, fmt.Errorf("redis: unexpected %s reply %T", command, reply)
+	}
+	var record SessionRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return SessionRecord{}, false, fmt.Errorf("corrupt session %s: %v", token, err)
+	}
+	return record, true, nil
+}
+
+func (s *RedisSessionStore) DeleteSession(token string) error {
+	_, err := s.do("DEL", s.opts.Prefix+token)
+	return err
+}
+
+// Close closes the connection, the next command dials again
+func (s *RedisSessionStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.resetLocked()
+}
+
+func (s *RedisSessionStore) resetLocked() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn, s.rd = nil, nil
+	return err
+}
+
+// do runs one command and returns its reply: a string, an int64, a []interface{} or nil
+func (s *RedisSessionStore) do(args ...string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		if err := s.dialLocked(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := s.roundTripLocked(args)
+	if err != nil && err != errRedisNil && !isRedisError(err) {
+		// The connection is in an unknown state, start over next time
+		s.resetLocked()
+	}
+	return reply, err
+}
+
+func (s *RedisSessionStore) dialLocked() error {
+	conn, err := net.DialTimeout("tcp", s.opts.Addr, s.opts.Timeout)
+	if err != nil {
+		return fmt.Errorf("redis: %v", err)
+	}
+	s.conn, s.rd = conn, bufio.NewReader(conn)
+
+	if s.opts.Password != "" {
+		if _, err := s.roundTripLocked([]string{"AUTH", s.opts.Password}); err != nil {
+			s.resetLocked()
+			return err
+		}
+	}
+	if s.opts.DB != 0 {
+		if _, err := s.roundTripLocked([]string{"SELECT", strconv.Itoa(s.opts.DB)}); err != nil {
+			s.resetLocked()
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *RedisSessionStore) roundTripLocked(args []string) (interface{}, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	s.conn.SetDeadline(time.Now().Add(s.opts.Timeout))
+	if _, err := io.WriteString(s.conn, b.String()); err != nil {
+		return nil, fmt.Errorf("redis: %v", err)
+	}
+	return readRedisReply(s.rd)
+}
+
+// redisError is an error reply of the server, the connection is still fine after one
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+func isRedisError(err error) bool {
+	_, ok := err.(redisError)
+	return ok
+}
+
+func readRedisReply(rd *bufio.Reader) (interface{}, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redis: %v", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, errRedisNil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, fmt.Errorf("redis: %v", err)
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad array length %q", line)
+		}
+		if n < 0 {
+			return nil, errRedisNil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			item, err := readRedisReply(rd)
+			if err != nil && err != errRedisNil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply %q", line)
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"sync"
+	"time"
+)
+
+// RoomResult is what's left of a room once it closes
+type RoomResult struct {
+	RoomID   string                 `json:"room_id"`
+	Reason   string                 `json:"reason"`
+	ClosedAt time.Time              `json:"closed_at"`
+	Results  map[string]interface{} `json:"results"`
+	// Logs are the room's last log lines, only set when log export is enabled
+	Logs []LogEntry `json:"logs,omitempty"`
+}
+
+// LogEntry is one timestamped log line
+type LogEntry struct {
+	At      time.Time `json:"at"`
+	Message string    `json:"message"`
+}
+
+// ResultStore persists finished room results
+// Swap the in-memory implementation for a real database when needed
+type ResultStore interface {
+	SaveRoomResult(result RoomResult) error
+}
+
+// MemoryResultStore keeps results in memory, good enough for development
+type MemoryResultStore struct {
+	mu      sync.Mutex
+	results []RoomResult
+}
+
+func NewMemoryResultStore() *MemoryResultStore {
+	return &MemoryResultStore{}
+}
+
+func (s *MemoryResultStore) SaveRoomResult(result RoomResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results = append(s.results, result)
+	return nil
+}
+
+// RoomResults returns a copy of everything saved so far
+func (s *MemoryResultStore) RoomResults() []RoomResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]RoomResult(nil), s.results...)
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// SessionRecord is what a reconnecting client gets back: its player ID and the transient
+// state the game kept for it
+type SessionRecord struct {
+	Token     string `json:"token"`
+	PlayerID  string `json:"player_id"`
+	AccountID string `json:"account_id"`
+	// RoomID is the room the player was in, empty when none
+	RoomID string                     `json:"room_id,omitempty"`
+	State  map[string]json.RawMessage `json:"state,omitempty"`
+	// LastSeq is the sseq of the last message delivered to the player, a resumed
+	// connection numbers its messages from the one after it
+	LastSeq   uint64    `json:"last_seq,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SessionStore keeps sessions by their token for ttl after the last save
+type SessionStore interface {
+	SaveSession(record SessionRecord, ttl time.Duration) error
+	// LoadSession returns false for unknown and expired tokens
+	LoadSession(token string) (SessionRecord, bool, error)
+	// TakeSession is LoadSession that also deletes the session in the same step, so a
+	// token can only ever be taken once, even by connections racing on different nodes
+	TakeSession(token string) (SessionRecord, bool, error)
+	DeleteSession(token string) error
+}
+
+// sessionSweepInterval is how often MemorySessionStore drops the expired sessions nobody
+// came back for
+const sessionSweepInterval = time.Minute
+
+// MemorySessionStore keeps sessions in memory, they are lost on restart and not shared
+// between nodes, see RedisSessionStore
+type MemorySessionStore struct {
+	mu        sync.Mutex
+	sessions  map[string]memorySession
+	nextSweep time.Time
+}
+
+type memorySession struct {
+	record    SessionRecord
+	expiresAt time.Time
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]memorySession)}
+}
+
+func (s *MemorySessionStore) SaveSession(record SessionRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	// Expired sessions are dropped on the way every so often, nobody else cleans up
+	if now.After(s.nextSweep) {
+		for token, session := range s.sessions {
+			if now.After(session.expiresAt) {
+				delete(s.sessions, token)
+			}
+		}
+		s.nextSweep = now.Add(sessionSweepInterval)
+	}
+	record.State = copyState(record.State)
+	s.sessions[record.Token] = memorySession{record: record, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+func (s *MemorySessionStore) LoadSession(token string) (SessionRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadLocked(token)
+}
+
+func (s *MemorySessionStore) TakeSession(token string) (SessionRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok, err := s.loadLocked(token)
+	delete(s.sessions, token)
+	return record, ok, err
+}
+
+func (s *MemorySessionStore) loadLocked(token string) (SessionRecord, bool, error) {
+	session, ok := s.sessions[token]
+	if !ok {
+		return SessionRecord{}, false, nil
+	}
+	if time.Now().After(session.expiresAt) {
+		delete(s.sessions, token)
+		return SessionRecord{}, false, nil
+	}
+	record := session.record
+	record.State = copyState(record.State)
+	return record, true, nil
+}
+
+func (s *MemorySessionStore) DeleteSession(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, token)
+	return nil
+}
+
+func copyState(state map[string]json.RawMessage) map[string]json.RawMessage {
+	if state == nil {
+		return nil
+	}
+	copied := make(map[string]json.RawMessage, len(state))
+	for key, value := range state {
+		copied[key] = append(json.RawMessage(nil), value...)
+	}
+	return copied
+}
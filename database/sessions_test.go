@@ -0,0 +1,74 @@
+package database
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemorySessionStoreTakeOnce(t *testing.T) {
+	store := NewMemorySessionStore()
+	if err := store.SaveSession(SessionRecord{Token: "t", PlayerID: "p"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	var taken atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok, _ := store.TakeSession("t"); ok {
+				taken.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := taken.Load(); n != 1 {
+		t.Fatalf("session taken %d times, want once", n)
+	}
+	if _, ok, _ := store.LoadSession("t"); ok {
+		t.Fatal("taken session still loads")
+	}
+}
+
+func TestMemorySessionStoreExpiry(t *testing.T) {
+	store := NewMemorySessionStore()
+	store.SaveSession(SessionRecord{Token: "old"}, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok, _ := store.TakeSession("old"); ok {
+		t.Fatal("expired session was taken")
+	}
+	store.mu.Lock()
+	_, kept := store.sessions["old"]
+	store.mu.Unlock()
+	if kept {
+		t.Fatal("expired session wasn't dropped when looked up")
+	}
+}
+
+func TestMemorySessionStoreSweep(t *testing.T) {
+	store := NewMemorySessionStore()
+	store.SaveSession(SessionRecord{Token: "old"}, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	// Within the sweep interval saves don't walk the map
+	store.SaveSession(SessionRecord{Token: "new"}, time.Minute)
+	store.mu.Lock()
+	n := len(store.sessions)
+	store.nextSweep = time.Time{}
+	store.mu.Unlock()
+	if n != 2 {
+		t.Fatalf("%d sessions before the sweep, want 2", n)
+	}
+
+	store.SaveSession(SessionRecord{Token: "newer"}, time.Minute)
+	store.mu.Lock()
+	_, kept := store.sessions["old"]
+	store.mu.Unlock()
+	if kept {
+		t.Fatal("expired session survived the sweep")
+	}
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"sync"
+	"time"
+)
+
+// SurveyResponse is a player's answer to the end-of-match survey
+type SurveyResponse struct {
+	MatchID   string `json:"match_id"`
+	Mode      string `json:"mode,omitempty"`
+	Map       string `json:"map,omitempty"`
+	AccountID string `json:"account_id"`
+	Rating    int    `json:"rating,omitempty"`
+	Feedback  string `json:"feedback,omitempty"`
+	// Reported is an account from the same match the player reported, with a reason
+	Reported     string    `json:"reported,omitempty"`
+	ReportReason string    `json:"report_reason,omitempty"`
+	SubmittedAt  time.Time `json:"submitted_at"`
+}
+
+// SurveySummary aggregates the responses of one mode and map
+type SurveySummary struct {
+	Mode          string  `json:"mode"`
+	Map           string  `json:"map"`
+	Responses     int     `json:"responses"`
+	Ratings       int     `json:"ratings"`
+	AverageRating float64 `json:"average_rating"`
+	Reports       int     `json:"reports"`
+	Feedback      int     `json:"feedback"`
+}
+
+// SurveyStore persists survey responses
+type SurveyStore interface {
+	SaveSurvey(response SurveyResponse) error
+	Surveys() ([]SurveyResponse, error)
+}
+
+// SummarizeSurveys groups responses per mode and map
+func SummarizeSurveys(responses []SurveyResponse) []SurveySummary {
+	var summaries []SurveySummary
+	index := make(map[[2]string]int)
+	for _, r := range responses {
+		key := [2]string{r.Mode, r.Map}
+		i, ok := index[key]
+		if !ok {
+			i = len(summaries)
+			index[key] = i
+			summaries = append(summaries, SurveySummary{Mode: r.Mode, Map: r.Map})
+		}
+
+		s := &summaries[i]
+		s.Responses++
+		if r.Rating > 0 {
+			// Running mean so the ratings don't have to be kept around
+			s.Ratings++
+			s.AverageRating += (float64(r.Rating) - s.AverageRating) / float64(s.Ratings)
+		}
+		if r.Reported != "" {
+			s.Reports++
+		}
+		if r.Feedback != "" {
+			s.Feedback++
+		}
+	}
+	return summaries
+}
+
+// MemorySurveyStore keeps responses in memory, good enough for development
+type MemorySurveyStore struct {
+	mu        sync.Mutex
+	responses []SurveyResponse
+}
+
+func NewMemorySurveyStore() *MemorySurveyStore {
+	return &MemorySurveyStore{}
+}
+
+func (s *MemorySurveyStore) SaveSurvey(response SurveyResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses = append(s.responses, response)
+	return nil
+}
+
+func (s *MemorySurveyStore) Surveys() ([]SurveyResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SurveyResponse(nil), s.responses...), nil
+}
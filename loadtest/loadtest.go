@@ -0,0 +1,374 @@
+// Package loadtest opens many WebSocket clients against a running GameServer, sends a
+// weighted mix of messages at a fixed rate and reports latency percentiles and error rates.
+// cmd/loadtest wraps it in a binary.
+package loadtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/iknizzz1807/socket-server-template/client"
+)
+
+const (
+	defaultReplyTimeout = 5 * time.Second
+	defaultRate         = 1
+)
+
+// Step is one kind of message in the mix
+type Step struct {
+	Type    client.MessageType `json:"type"`
+	Payload interface{}        `json:"payload,omitempty"`
+	// Weight is how often the step is picked relative to the others, 1 when 0
+	Weight int `json:"weight,omitempty"`
+	// Expect is the message type answering this one, empty when the server doesn't answer.
+	// Replies carrying the client's tag and seq back (e.g. the chat echo) are matched exactly,
+	// others are matched to the oldest unanswered send of that type.
+	Expect client.MessageType `json:"expect,omitempty"`
+}
+
+// DefaultMix sends chat messages, which every server answers with an echo
+var DefaultMix = []Step{{Type: client.ChatMessage, Payload: "loadtest", Expect: client.ChatMessage}}
+
+// Config describes a load test run
+type Config struct {
+	// URL of the server's websocket endpoint, e.g. ws://localhost:8080/ws
+	URL     string
+	Clients int
+	// Rate is messages per second per client, 1 when 0
+	Rate     float64
+	Duration time.Duration
+	// RampUp spreads the connects over this long instead of opening all at once
+	RampUp time.Duration
+	Mix    []Step
+	// ReplyTimeout is how long to wait for an expected reply, 5s when 0
+	ReplyTimeout time.Duration
+	Header       http.Header
+}
+
+// Report sums up a run
+type Report struct {
+	// Clients is how many were configured, late ones don't connect when the run ends during ramp up
+	Clients      int `json:"clients"`
+	Connected    int `json:"connected"`
+	DialErrors   int `json:"dial_errors"`
+	Disconnects  int `json:"disconnects"`
+	Sent         int `json:"sent"`
+	SendErrors   int `json:"send_errors"`
+	Received     int `json:"received"`
+	Replies      int `json:"replies"`
+	ReplyTimeout int `json:"reply_timeouts"`
+
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+
+	// ErrorRate is failed connects, sends and missing replies over everything attempted
+	ErrorRate float64       `json:"error_rate"`
+	Elapsed   time.Duration `json:"elapsed"`
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("clients %d/%d connected (%d dial errors, %d dropped), sent %d (%d errors), received %d, "+
+		"replies %d (%d timed out), latency p50 %v p90 %v p99 %v max %v, error rate %.2f%%, in %v",
+		r.Connected, r.Clients, r.DialErrors, r.Disconnects, r.Sent, r.SendErrors, r.Received,
+		r.Replies, r.ReplyTimeout, r.P50, r.P90, r.P99, r.Max, r.ErrorRate*100, r.Elapsed.Round(time.Millisecond))
+}
+
+// wireMessage is the part of a structured message the load test looks at
+type wireMessage struct {
+	Type     client.MessageType `json:"type"`
+	PlayerID string             `json:"player_id,omitempty"`
+	Payload  interface{}        `json:"payload,omitempty"`
+	Seq      uint64             `json:"seq,omitempty"`
+}
+
+// Run drives cfg.Clients connections until cfg.Duration passes or ctx is cancelled
+func Run(ctx context.Context, cfg Config) (Report, error) {
+	if cfg.URL == "" || cfg.Clients <= 0 {
+		return Report{}, fmt.Errorf("loadtest needs a url and at least one client")
+	}
+	if cfg.Rate <= 0 {
+		cfg.Rate = defaultRate
+	}
+	if cfg.ReplyTimeout <= 0 {
+		cfg.ReplyTimeout = defaultReplyTimeout
+	}
+	if len(cfg.Mix) == 0 {
+		cfg.Mix = DefaultMix
+	}
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+
+	start := time.Now()
+	results := make([]clientResult, cfg.Clients)
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Clients; i++ {
+		delay := time.Duration(0)
+		if cfg.RampUp > 0 {
+			delay = cfg.RampUp * time.Duration(i) / time.Duration(cfg.Clients)
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = runClient(ctx, cfg, fmt.Sprintf("loadtest-%d", i), delay)
+		}(i)
+	}
+	wg.Wait()
+
+	return summarize(cfg.Clients, results, time.Since(start)), nil
+}
+
+type pendingSend struct {
+	seq    uint64
+	msgTyp client.MessageType
+	sentAt time.Time
+}
+
+type clientResult struct {
+	connected, dialErr, dropped bool
+
+	sent, sendErrors, received, timeouts int
+	latencies                            []time.Duration
+}
+
+// runClient is one connection: a writer sending at the configured rate, a reader timing replies
+func runClient(ctx context.Context, cfg Config, tag string, delay time.Duration) clientResult {
+	var res clientResult
+
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+		return res
+	}
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, cfg.URL, cfg.Header)
+	if err != nil {
+		// A dial cut short by the end of the run isn't the server's fault
+		res.dialErr = ctx.Err() == nil
+		return res
+	}
+	res.connected = true
+	defer conn.Close()
+
+	var mu sync.Mutex
+	var pending []pendingSend
+
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				if ctx.Err() == nil {
+					mu.Lock()
+					res.dropped = true
+					mu.Unlock()
+				}
+				return
+			}
+
+			var msg wireMessage
+			json.Unmarshal(data, &msg)
+			now := time.Now()
+
+			mu.Lock()
+			res.received++
+			if i := matchReply(pending, msg, tag); i >= 0 {
+				res.latencies = append(res.latencies, now.Sub(pending[i].sentAt))
+				pending = append(pending[:i], pending[i+1:]...)
+			}
+			mu.Unlock()
+		}
+	}()
+
+	weights := 0
+	for _, s := range cfg.Mix {
+		weights += stepWeight(s)
+	}
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / cfg.Rate))
+	defer ticker.Stop()
+
+	var seq uint64
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-readerDone:
+			break loop
+		case <-ticker.C:
+		}
+
+		step := pickStep(cfg.Mix, rng.Intn(weights))
+		seq++
+		data, err := json.Marshal(wireMessage{Type: step.Type, PlayerID: tag, Payload: step.Payload, Seq: seq})
+		if err != nil {
+			mu.Lock()
+			res.sendErrors++
+			mu.Unlock()
+			continue
+		}
+
+		// Registered before writing, the reply can beat us back otherwise
+		mu.Lock()
+		pending = expire(pending, cfg.ReplyTimeout, &res.timeouts)
+		if step.Expect != "" {
+			pending = append(pending, pendingSend{seq: seq, msgTyp: step.Expect, sentAt: time.Now()})
+		}
+		mu.Unlock()
+
+		conn.SetWriteDeadline(time.Now().Add(cfg.ReplyTimeout))
+		err = conn.WriteMessage(websocket.TextMessage, data)
+
+		mu.Lock()
+		if err != nil {
+			res.sendErrors++
+			pending = forget(pending, seq)
+		} else {
+			res.sent++
+		}
+		mu.Unlock()
+	}
+
+	// Give replies in flight a moment before counting them as lost
+	grace := time.NewTimer(cfg.ReplyTimeout)
+	defer grace.Stop()
+	for {
+		mu.Lock()
+		left := len(pending)
+		mu.Unlock()
+		if left == 0 {
+			break
+		}
+		select {
+		case <-readerDone:
+		case <-grace.C:
+		case <-time.After(10 * time.Millisecond):
+			continue
+		}
+		break
+	}
+
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	conn.Close()
+	<-readerDone
+
+	mu.Lock()
+	defer mu.Unlock()
+	res.timeouts += len(pending)
+	return res
+}
+
+// matchReply finds the send msg answers: exactly by tag and seq, else the oldest of its type
+func matchReply(pending []pendingSend, msg wireMessage, tag string) int {
+	oldest := -1
+	for i, p := range pending {
+		if p.msgTyp != msg.Type {
+			continue
+		}
+		if msg.PlayerID == tag && msg.Seq == p.seq {
+			return i
+		}
+		if oldest < 0 && msg.Seq == 0 {
+			oldest = i
+		}
+	}
+	return oldest
+}
+
+func expire(pending []pendingSend, timeout time.Duration, timeouts *int) []pendingSend {
+	cutoff := time.Now().Add(-timeout)
+	n := 0
+	for n < len(pending) && pending[n].sentAt.Before(cutoff) {
+		n++
+	}
+	*timeouts += n
+	return pending[n:]
+}
+
+func forget(pending []pendingSend, seq uint64) []pendingSend {
+	for i, p := range pending {
+		if p.seq == seq {
+			return append(pending[:i], pending[i+1:]...)
+		}
+	}
+	return pending
+}
+
+func stepWeight(s Step) int {
+	if s.Weight <= 0 {
+		return 1
+	}
+	return s.Weight
+}
+
+func pickStep(mix []Step, n int) Step {
+	for _, s := range mix {
+		if n -= stepWeight(s); n < 0 {
+			return s
+		}
+	}
+	return mix[len(mix)-1]
+}
+
+func summarize(clients int, results []clientResult, elapsed time.Duration) Report {
+	report := Report{Clients: clients, Elapsed: elapsed}
+	var latencies []time.Duration
+	for _, r := range results {
+		if r.connected {
+			report.Connected++
+		}
+		if r.dialErr {
+			report.DialErrors++
+		}
+		if r.dropped {
+			report.Disconnects++
+		}
+		report.Sent += r.sent
+		report.SendErrors += r.sendErrors
+		report.Received += r.received
+		report.ReplyTimeout += r.timeouts
+		latencies = append(latencies, r.latencies...)
+	}
+	report.Replies = len(latencies)
+
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		report.P50 = percentile(latencies, 0.50)
+		report.P90 = percentile(latencies, 0.90)
+		report.P99 = percentile(latencies, 0.99)
+		report.Max = latencies[len(latencies)-1]
+	}
+
+	attempts := report.Connected + report.DialErrors + report.Sent + report.SendErrors
+	if attempts > 0 {
+		failures := report.DialErrors + report.SendErrors + report.ReplyTimeout
+		report.ErrorRate = float64(failures) / float64(attempts)
+	}
+	return report
+}
+
+// percentile of sorted latencies, nearest rank
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(p*float64(len(sorted))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
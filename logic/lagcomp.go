@@ -0,0 +1,117 @@
+package logic
+
+import (
+	"sync"
+	"time"
+)
+
+// Snapshot is the authoritative state of the world at one point in time
+type Snapshot struct {
+	Time      time.Time
+	Positions map[string]Vec2
+}
+
+// Within returns the IDs of every entity within radius of p, handy for hit checks
+func (s Snapshot) Within(p Vec2, radius float64) []string {
+	region := Region{Center: p, Radius: radius}
+	var ids []string
+	for id, pos := range s.Positions {
+		if region.Contains(pos) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// StateHistory keeps the last few snapshots in a ring buffer so actions
+// can be resolved against the world as the client saw it when acting
+type StateHistory struct {
+	mu        sync.RWMutex
+	snapshots []Snapshot
+	next      int
+	count     int
+}
+
+func NewStateHistory(capacity int) *StateHistory {
+	if capacity < 2 {
+		capacity = 2
+	}
+	return &StateHistory{snapshots: make([]Snapshot, capacity)}
+}
+
+// Record stores the state of one tick, snapshots must be recorded in time order
+func (h *StateHistory) Record(t time.Time, positions map[string]Vec2) {
+	copied := make(map[string]Vec2, len(positions))
+	for id, pos := range positions {
+		copied[id] = pos
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.snapshots[h.next] = Snapshot{Time: t, Positions: copied}
+	h.next = (h.next + 1) % len(h.snapshots)
+	if h.count < len(h.snapshots) {
+		h.count++
+	}
+}
+
+// at returns the i-th oldest snapshot, h.mu must be held
+func (h *StateHistory) at(i int) Snapshot {
+	start := (h.next - h.count + len(h.snapshots)) % len(h.snapshots)
+	return h.snapshots[(start+i)%len(h.snapshots)]
+}
+
+// At returns the world state at time t, interpolating positions between the two
+// surrounding ticks. Times outside the recorded window are clamped to the oldest
+// or newest snapshot and reported with ok == false.
+func (h *StateHistory) At(t time.Time) (snapshot Snapshot, ok bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.count == 0 {
+		return Snapshot{}, false
+	}
+
+	oldest, newest := h.at(0), h.at(h.count-1)
+	if t.Before(oldest.Time) {
+		return oldest, false
+	}
+	if !t.Before(newest.Time) {
+		return newest, t.Equal(newest.Time)
+	}
+
+	for i := 1; i < h.count; i++ {
+		after := h.at(i)
+		if after.Time.Before(t) {
+			continue
+		}
+		before := h.at(i - 1)
+		return interpolate(before, after, t), true
+	}
+
+	return newest, false
+}
+
+func interpolate(a, b Snapshot, t time.Time) Snapshot {
+	span := b.Time.Sub(a.Time)
+	if span <= 0 {
+		return b
+	}
+	f := float64(t.Sub(a.Time)) / float64(span)
+
+	positions := make(map[string]Vec2, len(b.Positions))
+	for id, pb := range b.Positions {
+		pa, existed := a.Positions[id]
+		if !existed {
+			// Spawned between the two ticks, nothing to blend with
+			positions[id] = pb
+			continue
+		}
+		positions[id] = Vec2{
+			X: pa.X + (pb.X-pa.X)*f,
+			Y: pa.Y + (pb.Y-pa.Y)*f,
+		}
+	}
+	return Snapshot{Time: t, Positions: positions}
+}
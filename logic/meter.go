@@ -0,0 +1,78 @@
+package logic
+
+import "time"
+
+// MeterRule describes a resource that is spent by actions and regenerates over time,
+// like action points, stamina or mana
+type MeterRule struct {
+	Max float64
+	// Regen is how much comes back per second
+	Regen float64
+	// RegenDelay pauses regeneration for a while after every spend, like stamina in most games
+	RegenDelay time.Duration
+}
+
+// CooldownRule is a meter for an action usable once per cooldown: spend it with a cost of 1
+func CooldownRule(cooldown time.Duration) MeterRule {
+	return MeterRule{Max: 1, Regen: 1 / cooldown.Seconds()}
+}
+
+// Meter is one resource of one player, it starts full
+// It is not safe for concurrent use, the caller keeps it behind a lock.
+type Meter struct {
+	rule      MeterRule
+	value     float64
+	updated   time.Time
+	lastSpend time.Time
+}
+
+func NewMeter(rule MeterRule, now time.Time) *Meter {
+	return &Meter{rule: rule, value: rule.Max, updated: now}
+}
+
+// regen brings the value up to date
+func (m *Meter) regen(now time.Time) {
+	from := m.updated
+	if resume := m.lastSpend.Add(m.rule.RegenDelay); m.rule.RegenDelay > 0 && resume.After(from) {
+		from = resume
+	}
+	if now.After(from) {
+		m.value += now.Sub(from).Seconds() * m.rule.Regen
+		if m.value > m.rule.Max {
+			m.value = m.rule.Max
+		}
+	}
+	if now.After(m.updated) {
+		m.updated = now
+	}
+}
+
+// Value is the amount available at now
+func (m *Meter) Value(now time.Time) float64 {
+	m.regen(now)
+	return m.value
+}
+
+// CanSpend reports whether cost is available without spending it
+func (m *Meter) CanSpend(cost float64, now time.Time) bool {
+	return m.Value(now) >= cost
+}
+
+// Spend takes cost if enough is available, otherwise nothing changes
+func (m *Meter) Spend(cost float64, now time.Time) bool {
+	if !m.CanSpend(cost, now) {
+		return false
+	}
+	m.value -= cost
+	m.lastSpend = now
+	return true
+}
+
+// Add gives back amount (potions, pickups...), never above Max
+func (m *Meter) Add(amount float64, now time.Time) {
+	m.regen(now)
+	m.value += amount
+	if m.value > m.rule.Max {
+		m.value = m.rule.Max
+	}
+}
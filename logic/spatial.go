@@ -0,0 +1,111 @@
+package logic
+
+import "math"
+
+// Vec2 is a position or velocity in world space
+type Vec2 struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+func (v Vec2) Sub(o Vec2) Vec2 {
+	return Vec2{X: v.X - o.X, Y: v.Y - o.Y}
+}
+
+func (v Vec2) Len() float64 {
+	return math.Hypot(v.X, v.Y)
+}
+
+// Region is a circular area, e.g. what a player can currently see
+type Region struct {
+	Center Vec2
+	Radius float64
+}
+
+func (r Region) Contains(p Vec2) bool {
+	d := p.Sub(r.Center)
+	return d.X*d.X+d.Y*d.Y <= r.Radius*r.Radius
+}
+
+type cellKey struct {
+	x, y int
+}
+
+// SpatialGrid indexes regions by the fixed-size cells they overlap,
+// so finding every region containing a point only looks at a single cell.
+// It is not safe for concurrent use, callers have to do their own locking.
+type SpatialGrid struct {
+	cellSize float64
+	cells    map[cellKey]map[string]struct{}
+	regions  map[string]Region
+}
+
+func NewSpatialGrid(cellSize float64) *SpatialGrid {
+	if cellSize <= 0 {
+		cellSize = 100
+	}
+	return &SpatialGrid{
+		cellSize: cellSize,
+		cells:    make(map[cellKey]map[string]struct{}),
+		regions:  make(map[string]Region),
+	}
+}
+
+func (g *SpatialGrid) cellOf(p Vec2) cellKey {
+	return cellKey{
+		x: int(math.Floor(p.X / g.cellSize)),
+		y: int(math.Floor(p.Y / g.cellSize)),
+	}
+}
+
+// forEachCell calls fn for every cell overlapped by the bounding box of r
+func (g *SpatialGrid) forEachCell(r Region, fn func(cellKey)) {
+	min := g.cellOf(Vec2{X: r.Center.X - r.Radius, Y: r.Center.Y - r.Radius})
+	max := g.cellOf(Vec2{X: r.Center.X + r.Radius, Y: r.Center.Y + r.Radius})
+	for x := min.x; x <= max.x; x++ {
+		for y := min.y; y <= max.y; y++ {
+			fn(cellKey{x: x, y: y})
+		}
+	}
+}
+
+// Set adds or moves the region with the given id
+func (g *SpatialGrid) Set(id string, r Region) {
+	g.Remove(id)
+
+	g.regions[id] = r
+	g.forEachCell(r, func(k cellKey) {
+		cell, ok := g.cells[k]
+		if !ok {
+			cell = make(map[string]struct{})
+			g.cells[k] = cell
+		}
+		cell[id] = struct{}{}
+	})
+}
+
+func (g *SpatialGrid) Remove(id string) {
+	r, ok := g.regions[id]
+	if !ok {
+		return
+	}
+
+	delete(g.regions, id)
+	g.forEachCell(r, func(k cellKey) {
+		delete(g.cells[k], id)
+		if len(g.cells[k]) == 0 {
+			delete(g.cells, k)
+		}
+	})
+}
+
+// Query returns the ids of every region containing p
+func (g *SpatialGrid) Query(p Vec2) []string {
+	var ids []string
+	for id := range g.cells[g.cellOf(p)] {
+		if g.regions[id].Contains(p) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
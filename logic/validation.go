@@ -1,5 +1,87 @@
 package logic
 
+import (
+	"math"
+	"time"
+)
+
 // This is for:
 // - Validating game data, user messages
 // Send back errors, ban for cheating,...
+
+// Bounds is the rectangle of the world players can be in
+type Bounds struct {
+	Min Vec2 `json:"min"`
+	Max Vec2 `json:"max"`
+}
+
+func (b Bounds) Contains(p Vec2) bool {
+	return p.X >= b.Min.X && p.X <= b.Max.X && p.Y >= b.Min.Y && p.Y <= b.Max.Y
+}
+
+// MoveLimits are the movement rules the server enforces, zero values turn a check off
+type MoveLimits struct {
+	// MaxSpeed in world units per second
+	MaxSpeed float64
+	// Slack is extra distance allowed on every move, it absorbs network jitter
+	// bunching inputs together. Something like MaxSpeed * 0.1 works well.
+	Slack float64
+	// TeleportDistance flags any single move longer than this, however long it took
+	TeleportDistance float64
+	// Bounds, when set, is where positions have to stay
+	Bounds *Bounds
+}
+
+type ViolationKind string
+
+const (
+	ViolationBounds   ViolationKind = "bounds"
+	ViolationTeleport ViolationKind = "teleport"
+	ViolationSpeed    ViolationKind = "speed"
+)
+
+// MoveViolation describes a move that broke the limits
+type MoveViolation struct {
+	Kind     ViolationKind
+	From, To Vec2
+	Elapsed  time.Duration
+	// Distance moved and the most that was allowed for Elapsed
+	Distance float64
+	Allowed  float64
+}
+
+// CheckMove validates a move from the authoritative position from to the claimed
+// position to, elapsed after the last accepted move
+func (l MoveLimits) CheckMove(from, to Vec2, elapsed time.Duration) (MoveViolation, bool) {
+	v := MoveViolation{From: from, To: to, Elapsed: elapsed, Distance: to.Sub(from).Len()}
+
+	if math.IsNaN(v.Distance) || math.IsInf(v.Distance, 0) {
+		v.Kind = ViolationBounds
+		return v, true
+	}
+	if l.Bounds != nil && !l.Bounds.Contains(to) {
+		v.Kind = ViolationBounds
+		return v, true
+	}
+	if l.TeleportDistance > 0 && v.Distance > l.TeleportDistance {
+		v.Kind, v.Allowed = ViolationTeleport, l.TeleportDistance
+		return v, true
+	}
+	if l.MaxSpeed > 0 {
+		v.Allowed = l.MaxSpeed*elapsed.Seconds() + l.Slack
+		if v.Distance > v.Allowed {
+			v.Kind = ViolationSpeed
+			return v, true
+		}
+	}
+	return v, false
+}
+
+// CheckPosition only checks the bounds, for the first position of an entity
+func (l MoveLimits) CheckPosition(p Vec2) (MoveViolation, bool) {
+	if math.IsNaN(p.X) || math.IsNaN(p.Y) || math.IsInf(p.X, 0) || math.IsInf(p.Y, 0) ||
+		(l.Bounds != nil && !l.Bounds.Contains(p)) {
+		return MoveViolation{Kind: ViolationBounds, From: p, To: p}, true
+	}
+	return MoveViolation{}, false
+}
@@ -0,0 +1,144 @@
+package messages
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Binary frames start with one byte telling what kind of frame it is
+const (
+	FrameMove  byte = 0x01
+	FrameVoice byte = 0x02
+)
+
+// MoveFrameSize is the fixed size of an encoded move frame:
+//
+//	offset  size  field
+//	0       1     kind (FrameMove)
+//	1       4     entity id   uint32
+//	5       4     sequence    uint32
+//	9       4     position x  float32
+//	13      4     position y  float32
+//	17      4     velocity x  float32
+//	21      4     velocity y  float32
+//
+// All values are big-endian.
+const MoveFrameSize = 25
+
+// VoiceHeaderSize is the size of a voice frame before the sender id and the audio:
+//
+//	offset  size  field
+//	0       1     kind (FrameVoice)
+//	1       2     sequence   uint16, counted by the sender, wraps around
+//	3       1     volume     uint8, 255 is full volume
+//	4       1     sender id length n, 0 in frames from clients
+//	5       n     sender id, set by the server
+//	5+n     rest  one opus packet
+//
+// All values are big-endian.
+const VoiceHeaderSize = 5
+
+var ErrUnknownFrame = errors.New("unknown binary frame")
+
+// MoveFrame is the binary version of PLAYER_MOVE, used for the high-frequency movement hot path
+type MoveFrame struct {
+	EntityID uint32
+	Seq      uint32
+	X, Y     float32
+	VX, VY   float32
+}
+
+// FrameKind returns the kind byte of a binary frame
+func FrameKind(data []byte) (byte, error) {
+	if len(data) == 0 {
+		return 0, fmt.Errorf("empty binary frame")
+	}
+	return data[0], nil
+}
+
+// EncodeMove writes the frame into a new MoveFrameSize byte slice
+func EncodeMove(f MoveFrame) []byte {
+	buf := make([]byte, MoveFrameSize)
+	buf[0] = FrameMove
+	binary.BigEndian.PutUint32(buf[1:], f.EntityID)
+	binary.BigEndian.PutUint32(buf[5:], f.Seq)
+	binary.BigEndian.PutUint32(buf[9:], math.Float32bits(f.X))
+	binary.BigEndian.PutUint32(buf[13:], math.Float32bits(f.Y))
+	binary.BigEndian.PutUint32(buf[17:], math.Float32bits(f.VX))
+	binary.BigEndian.PutUint32(buf[21:], math.Float32bits(f.VY))
+	return buf
+}
+
+// DecodeMove parses a move frame, rejecting anything that isn't exactly one
+func DecodeMove(data []byte) (MoveFrame, error) {
+	if len(data) != MoveFrameSize {
+		return MoveFrame{}, fmt.Errorf("move frame must be %d bytes, got %d", MoveFrameSize, len(data))
+	}
+	if data[0] != FrameMove {
+		return MoveFrame{}, fmt.Errorf("%w: kind 0x%02x", ErrUnknownFrame, data[0])
+	}
+
+	f := MoveFrame{
+		EntityID: binary.BigEndian.Uint32(data[1:]),
+		Seq:      binary.BigEndian.Uint32(data[5:]),
+		X:        math.Float32frombits(binary.BigEndian.Uint32(data[9:])),
+		Y:        math.Float32frombits(binary.BigEndian.Uint32(data[13:])),
+		VX:       math.Float32frombits(binary.BigEndian.Uint32(data[17:])),
+		VY:       math.Float32frombits(binary.BigEndian.Uint32(data[21:])),
+	}
+
+	// NaN or Inf positions would poison any physics they touch
+	for _, v := range []float32{f.X, f.Y, f.VX, f.VY} {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return MoveFrame{}, fmt.Errorf("move frame contains non-finite values")
+		}
+	}
+	return f, nil
+}
+
+// VoiceFrame is a packet of opus audio relayed between the players of a room
+type VoiceFrame struct {
+	// Sender is the player that spoke, empty in frames from clients
+	Sender string
+	Seq    uint16
+	Volume uint8
+	// Data is the opus packet, DecodeVoice doesn't copy it
+	Data []byte
+}
+
+// EncodeVoice writes the frame into a new byte slice
+func EncodeVoice(f VoiceFrame) ([]byte, error) {
+	if len(f.Sender) > math.MaxUint8 {
+		return nil, fmt.Errorf("voice sender id can have at most %d bytes, got %d", math.MaxUint8, len(f.Sender))
+	}
+	buf := make([]byte, VoiceHeaderSize+len(f.Sender)+len(f.Data))
+	buf[0] = FrameVoice
+	binary.BigEndian.PutUint16(buf[1:], f.Seq)
+	buf[3] = f.Volume
+	buf[4] = byte(len(f.Sender))
+	copy(buf[VoiceHeaderSize:], f.Sender)
+	copy(buf[VoiceHeaderSize+len(f.Sender):], f.Data)
+	return buf, nil
+}
+
+// DecodeVoice parses a voice frame, the audio may be empty
+func DecodeVoice(data []byte) (VoiceFrame, error) {
+	if len(data) < VoiceHeaderSize {
+		return VoiceFrame{}, fmt.Errorf("voice frame must be at least %d bytes, got %d", VoiceHeaderSize, len(data))
+	}
+	if data[0] != FrameVoice {
+		return VoiceFrame{}, fmt.Errorf("%w: kind 0x%02x", ErrUnknownFrame, data[0])
+	}
+	senderEnd := VoiceHeaderSize + int(data[4])
+	if len(data) < senderEnd {
+		return VoiceFrame{}, fmt.Errorf("voice frame too short for its %d byte sender id", data[4])
+	}
+	return VoiceFrame{
+		Sender: string(data[VoiceHeaderSize:senderEnd]),
+		Seq:    binary.BigEndian.Uint16(data[1:]),
+		Volume: data[3],
+		Data:   data[senderEnd:],
+	}, nil
+}
@@ -0,0 +1,35 @@
+package messages_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/iknizzz1807/socket-server-template/messages"
+	"github.com/iknizzz1807/socket-server-template/server/servertest"
+)
+
+func FuzzMoveCodec(f *testing.F) {
+	f.Add(messages.EncodeMove(messages.MoveFrame{EntityID: 1 << 31, Seq: 1, VX: -0.5, VY: 0.25}))
+	servertest.FuzzMoveCodec(f)
+}
+
+// FuzzVoiceCodec checks that what decodes encodes to the same bytes
+func FuzzVoiceCodec(f *testing.F) {
+	seed, _ := messages.EncodeVoice(messages.VoiceFrame{Sender: "player-1", Seq: 9, Volume: 128, Data: []byte{1, 2, 3}})
+	f.Add(seed)
+	f.Add([]byte{messages.FrameVoice, 0, 0, 0, 200})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		voice, err := messages.DecodeVoice(data)
+		if err != nil {
+			return
+		}
+		encoded, err := messages.EncodeVoice(voice)
+		if err != nil {
+			t.Fatalf("decoded %+v from %x, which doesn't encode: %v", voice, data, err)
+		}
+		if !bytes.Equal(encoded, data) {
+			t.Fatalf("decoded %+v from %x, which encodes to %x", voice, data, encoded)
+		}
+	})
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"runtime/debug"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Every room runs one goroutine that owns its game logic. The tick loop, AfterFunc timers,
+// handlers registered with Handle and everything passed to Do or Call run there, one at a
+// time and in the order they were queued. Game state only touched from those functions
+// needs no lock, and the same inputs in the same order always give the same result.
+//
+// Never wait on the room from its own goroutine: Call or Step from a tick, timer or
+// handler would deadlock. Do only queues and is always safe.
+//
+// A panic on the room goroutine is recovered and published with MessagePanicked, the room
+// goes on with the next item. A handler's player is disconnected like for any message that
+// panicked, what the tick or function was in the middle of may be half done.
+
+// roomMailboxSize is how far a room may fall behind before Do refuses work
+const roomMailboxSize = 1024
+
+var ErrRoomBusy = errors.New("room mailbox is full")
+
+// RoomHandler handles a member's message on the room goroutine
+type RoomHandler func(player *Player, msg StructuredMessage)
+
+// Do queues fn on the room goroutine without waiting for it
+// A room that is too far behind returns ErrRoomBusy instead of blocking the caller.
+func (r *Room) Do(fn func()) error {
+	select {
+	case <-r.done:
+		return ErrRoomClosed
+	default:
+	}
+
+	select {
+	case r.mailbox <- fn:
+		return nil
+	default:
+		return ErrRoomBusy
+	}
+}
+
+// Call runs fn on the room goroutine and waits until it returned
+func (r *Room) Call(fn func()) error {
+	finished := make(chan struct{})
+	if err := r.post(func() {
+		defer close(finished)
+		fn()
+	}); err != nil {
+		return err
+	}
+
+	select {
+	case <-finished:
+		return nil
+	case <-r.done:
+		// fn may have been the one closing the room
+		select {
+		case <-finished:
+			return nil
+		default:
+			return ErrRoomClosed
+		}
+	}
+}
+
+// Handle routes the members' messages of msgType to fn on the room goroutine, ahead of
+// the built-in routing. A nil fn removes the handler.
+func (r *Room) Handle(msgType MessageType, fn RoomHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if fn == nil {
+		delete(r.handlers, msgType)
+		return
+	}
+	if r.handlers == nil {
+		r.handlers = make(map[MessageType]RoomHandler)
+	}
+	r.handlers[msgType] = fn
+}
+
+// dispatch queues msg for the room's handler, handled is false when it has none
+func (r *Room) dispatch(player *Player, msg StructuredMessage) (bool, error) {
+	r.mu.Lock()
+	fn := r.handlers[msg.Type]
+	r.mu.Unlock()
+
+	if fn == nil {
+		return false, nil
+	}
+	return true, r.Do(func() {
+		defer r.recoverPanic(player, &msg)
+		fn(player, msg)
+	})
+}
+
+// post queues fn, waiting for room in the mailbox. Only for callers outside the room.
+func (r *Room) post(fn func()) error {
+	select {
+	case <-r.done:
+		return ErrRoomClosed
+	case r.mailbox <- fn:
+		return nil
+	}
+}
+
+// run is the room goroutine, it ends when the room closes
+func (r *Room) run() {
+	defer func() {
+		if r.loop != nil {
+			r.loop.timer.Stop()
+		}
+	}()
+
+	for {
+		var ticks <-chan time.Time
+		if r.loop != nil {
+			ticks = r.loop.timer.C
+		}
+
+		select {
+		case loop := <-r.tickStart:
+			r.loop = loop.start()
+
+		case fn := <-r.mailbox:
+			// A tick control queued right after StartTicker must find the loop
+			select {
+			case loop := <-r.tickStart:
+				r.loop = loop.start()
+			default:
+			}
+			r.safely(fn)
+
+		case <-ticks:
+			r.safely(r.loop.fire)
+
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// safely runs an item of the room goroutine, see recoverPanic
+func (r *Room) safely(fn func()) {
+	defer r.recoverPanic(nil, nil)
+	fn()
+}
+
+// recoverPanic keeps a panic on the room goroutine from taking the server down. msg is the
+// message a handler of player was given, nil for ticks, timers and Do.
+func (r *Room) recoverPanic(player *Player, msg *StructuredMessage) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	stack := debug.Stack()
+	// Not r.Logf, the panic may have left the room locked
+	log.Printf("Room %s panicked: %v\n%s", r.ID, v, stack)
+
+	event := MessagePanicked{Room: r, Value: v, Stack: stack}
+	if player == nil {
+		r.gs.metrics.panics.add(0, 1)
+		Publish(r.gs.events, event)
+		return
+	}
+	r.gs.metrics.panics.add(player.metricShard, 1)
+	event.Player = player
+	event.MessageType = websocket.TextMessage
+	event.Frame, _ = json.Marshal(msg)
+	Publish(r.gs.events, event)
+	go r.gs.disconnectWithReason(player, ReasonInternalError)
+}
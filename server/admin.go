@@ -0,0 +1,179 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// The admin listener serves diagnostics and the admin APIs on their own port, so they're
+// never reachable through the game's public address. Every request needs
+// "Authorization: Bearer <token>".
+//
+//	/debug/pprof/           profiles, e.g. go tool pprof http://host:6060/debug/pprof/heap
+//	/debug/vars             expvar: memstats and cmdline
+//	/debug/stats            RuntimeStats as JSON
+//	/admin/kick             see KickAdminHandler
+//	/admin/bans             see BanAdminHandler
+//	/admin/announce         see AnnounceAdminHandler
+//	/admin/netstats         see NetStatsHandler
+//	/admin/metrics/history  see MetricsHistoryHandler
+//	/admin/reload           see ConfigReloadHandler, only for servers created from a config file
+
+var ErrAdminTokenRequired = errors.New("the admin listener needs a token")
+
+// RuntimeStats is the body of /debug/stats
+type RuntimeStats struct {
+	At          time.Time `json:"at"`
+	Goroutines  int       `json:"goroutines"`
+	HeapAlloc   uint64    `json:"heap_alloc_bytes"`
+	HeapInuse   uint64    `json:"heap_inuse_bytes"`
+	HeapObjects uint64    `json:"heap_objects"`
+	Sys         uint64    `json:"sys_bytes"`
+	NumGC       uint32    `json:"num_gc"`
+	// GC pauses in milliseconds, LastGCPause is 0 before the first collection
+	GCPauseTotal  float64         `json:"gc_pause_total_ms"`
+	LastGCPause   float64         `json:"last_gc_pause_ms"`
+	GCCPUFraction float64         `json:"gc_cpu_fraction"`
+	Metrics       MetricsSnapshot `json:"metrics"`
+}
+
+// RuntimeStats reads the Go runtime stats next to the latest metrics snapshot
+// ReadMemStats briefly stops the world, don't poll it in a tight loop.
+func (gs *GameServer) RuntimeStats() RuntimeStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	stats := RuntimeStats{
+		At:            time.Now(),
+		Goroutines:    runtime.NumGoroutine(),
+		HeapAlloc:     mem.HeapAlloc,
+		HeapInuse:     mem.HeapInuse,
+		HeapObjects:   mem.HeapObjects,
+		Sys:           mem.Sys,
+		NumGC:         mem.NumGC,
+		GCPauseTotal:  float64(mem.PauseTotalNs) / 1e6,
+		GCCPUFraction: mem.GCCPUFraction,
+		Metrics:       gs.Metrics(),
+	}
+	if mem.NumGC > 0 {
+		stats.LastGCPause = float64(mem.PauseNs[(mem.NumGC+255)%256]) / 1e6
+	}
+	return stats
+}
+
+// RuntimeStatsHandler serves RuntimeStats as JSON. It has no authentication, only mount it
+// on a private mux or behind admin auth, StartAdmin does the latter.
+func (gs *GameServer) RuntimeStatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(gs.RuntimeStats()); err != nil {
+			log.Printf("Error writing runtime stats: %v", err)
+		}
+	})
+}
+
+// requireToken lets through requests with the bearer token
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// adminHandler is the mux of the admin listener, without auth
+func (gs *GameServer) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.Handle("/debug/stats", gs.RuntimeStatsHandler())
+	mux.Handle("/admin/kick", gs.KickAdminHandler())
+	mux.Handle("/admin/bans", gs.BanAdminHandler())
+	mux.Handle("/admin/announce", gs.AnnounceAdminHandler())
+	mux.Handle("/admin/netstats", gs.NetStatsHandler())
+	mux.Handle("/admin/metrics/history", gs.MetricsHistoryHandler())
+	if path := gs.configPath(); path != "" {
+		mux.Handle("/admin/reload", gs.ConfigReloadHandler(path))
+	}
+	return mux
+}
+
+// StartAdmin serves the diagnostics on addr until Shutdown, token is required
+func (gs *GameServer) StartAdmin(addr, token string) error {
+	if token == "" {
+		return ErrAdminTokenRequired
+	}
+	// Kept out of httpServers so /healthz doesn't advertise it
+	srv := &http.Server{Addr: addr, Handler: requireToken(token, gs.adminHandler())}
+	gs.serversMu.Lock()
+	gs.adminServer = srv
+	gs.serversMu.Unlock()
+
+	log.Printf("Admin server starting on %s", addr)
+	return serveResult(srv.ListenAndServe())
+}
+
+// Announce sends an ANNOUNCEMENT to every connected player
+func (gs *GameServer) Announce(text string) BroadcastResult {
+	log.Printf("Announcing: %s", text)
+	return gs.BroadcastTo(func(*Player) bool { return true }, Announcement, AnnouncementPayload{Text: text})
+}
+
+// announceRequest is the body of POST requests to AnnounceAdminHandler
+type announceRequest struct {
+	Text string `json:"text"`
+}
+
+// AnnounceAdminHandler is an admin API for announcements:
+//
+//	POST /admin/announce  {"text": "Restarting in 5 minutes"}
+//
+// The answer counts the players reached like POST /inject. It has no authentication, only mount it on a private
+// mux or behind admin auth.
+func (gs *GameServer) AnnounceAdminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req announceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid announcement: %v", err), http.StatusBadRequest)
+			return
+		}
+		if req.Text == "" {
+			http.Error(w, "announcement text is required", http.StatusBadRequest)
+			return
+		}
+
+		res := gs.Announce(req.Text)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Sent   int      `json:"sent"`
+			Failed int      `json:"failed"`
+			Errors []string `json:"errors,omitempty"`
+		}{res.Sent, res.Failed, errorStrings(res.Errors)})
+	})
+}
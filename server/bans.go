@@ -0,0 +1,205 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/iknizzz1807/socket-server-template/database"
+)
+
+var (
+	ErrBanned         = errors.New("banned")
+	ErrBansNotEnabled = errors.New("bans are not enabled")
+)
+
+// BanPlayer bans the account of a connected player and disconnects all of its sessions
+// d <= 0 bans permanently.
+func (gs *GameServer) BanPlayer(playerID, reason string, d time.Duration) error {
+	player, exists := gs.players.get(playerID)
+	if !exists {
+		return fmt.Errorf("player not found")
+	}
+	return gs.BanAccount(player.AccountID, reason, d)
+}
+
+// BanAccount bans an account, connected or not, and disconnects its sessions
+func (gs *GameServer) BanAccount(accountID, reason string, d time.Duration) error {
+	if err := gs.addBan(database.BanAccount, accountID, reason, d); err != nil {
+		return err
+	}
+
+	for _, player := range gs.accountSessions(accountID) {
+		gs.dropSession(player)
+		gs.disconnectWithReason(player, ReasonBanned.WithDetail(reason))
+	}
+	return nil
+}
+
+// BanIP bans an address and disconnects everyone connected from it
+func (gs *GameServer) BanIP(ip, reason string, d time.Duration) error {
+	if err := gs.addBan(database.BanIP, ip, reason, d); err != nil {
+		return err
+	}
+
+	var matches []*Player
+	for _, p := range gs.players.snapshot() {
+		if p.remoteIP == ip {
+			matches = append(matches, p)
+		}
+	}
+
+	for _, player := range matches {
+		gs.dropSession(player)
+		gs.disconnectWithReason(player, ReasonBanned.WithDetail(reason))
+	}
+	return nil
+}
+
+// Unban lifts a ban, kind is database.BanAccount or database.BanIP
+func (gs *GameServer) Unban(kind, value string) error {
+	if gs.banStore == nil {
+		return ErrBansNotEnabled
+	}
+	log.Printf("Lifting %s ban of %s", kind, value)
+	return gs.banStore.RemoveBan(kind, value)
+}
+
+func (gs *GameServer) addBan(kind, value, reason string, d time.Duration) error {
+	if gs.banStore == nil {
+		return ErrBansNotEnabled
+	}
+	if kind != database.BanAccount && kind != database.BanIP {
+		return fmt.Errorf("unknown ban kind %q", kind)
+	}
+	if value == "" {
+		return fmt.Errorf("nothing to ban")
+	}
+
+	ban := database.Ban{Kind: kind, Value: value, Reason: reason, CreatedAt: time.Now()}
+	if d > 0 {
+		ban.ExpiresAt = ban.CreatedAt.Add(d)
+	}
+	log.Printf("Banning %s %s (%s), expires %v", kind, value, reason, ban.ExpiresAt)
+	if err := gs.banStore.AddBan(ban); err != nil {
+		return err
+	}
+	Publish(gs.events, PlayerBanned{Ban: ban})
+	return nil
+}
+
+// checkBans returns ErrBanned when the account or the IP is banned
+// Storage errors let the connection through, an outage shouldn't lock everyone out.
+func (gs *GameServer) checkBans(accountID, ip string) error {
+	if gs.banStore == nil {
+		return nil
+	}
+
+	now := time.Now()
+	for _, c := range []struct{ kind, value string }{{database.BanAccount, accountID}, {database.BanIP, ip}} {
+		if c.value == "" {
+			continue
+		}
+		ban, banned, err := gs.banStore.ActiveBan(c.kind, c.value, now)
+		if err != nil {
+			log.Printf("Error checking %s ban of %s: %v", c.kind, c.value, err)
+			continue
+		}
+		if banned {
+			return fmt.Errorf("%s %s: %w (%s)", c.kind, c.value, ErrBanned, ban.Reason)
+		}
+	}
+	return nil
+}
+
+// checkBansBeforeUpgrade answers 403 itself for banned clients, before a socket is set up
+func (gs *GameServer) checkBansBeforeUpgrade(w http.ResponseWriter, r *http.Request, ip string) bool {
+	accountID, _ := gs.resolveAccount(r)
+	if err := gs.checkBans(accountID, ip); err != nil {
+		log.Printf("Rejecting connection: %v", err)
+		http.Error(w, "banned", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// banRequest is the body of POST requests to BanAdminHandler
+type banRequest struct {
+	Kind   string `json:"kind"`
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+	// Duration like "24h", empty bans permanently
+	Duration string `json:"duration"`
+}
+
+// BanAdminHandler is an admin API for the ban list:
+//
+//	GET    /admin/bans                        lists active bans
+//	POST   /admin/bans  {"kind": "ip", "value": "1.2.3.4", "reason": "...", "duration": "24h"}
+//	DELETE /admin/bans?kind=ip&value=1.2.3.4
+//
+// It has no authentication, only mount it on a private mux or behind admin auth.
+func (gs *GameServer) BanAdminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if gs.banStore == nil {
+			http.Error(w, ErrBansNotEnabled.Error(), http.StatusNotImplemented)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			bans, err := gs.banStore.Bans()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(bans); err != nil {
+				log.Printf("Error writing bans: %v", err)
+			}
+
+		case http.MethodPost:
+			var req banRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, fmt.Sprintf("invalid ban: %v", err), http.StatusBadRequest)
+				return
+			}
+			var d time.Duration
+			if req.Duration != "" {
+				var err error
+				if d, err = time.ParseDuration(req.Duration); err != nil {
+					http.Error(w, fmt.Sprintf("invalid duration: %v", err), http.StatusBadRequest)
+					return
+				}
+			}
+
+			var err error
+			switch req.Kind {
+			case database.BanAccount:
+				err = gs.BanAccount(req.Value, req.Reason, d)
+			case database.BanIP:
+				err = gs.BanIP(req.Value, req.Reason, d)
+			default:
+				err = fmt.Errorf("unknown ban kind %q", req.Kind)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+
+		case http.MethodDelete:
+			if err := gs.Unban(r.URL.Query().Get("kind"), r.URL.Query().Get("value")); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// A tick of a busy room sends each member a burst of small updates. With batching on,
+// clients that asked for the "batching" feature in HELLO get everything written to them
+// during a tick of their room as one BATCH frame instead, fewer frames and syscalls for
+// the same data. Messages keep their order; a tick that wrote a single message sends it
+// as is. The BATCH frame carries the sseq, the messages inside it have none.
+//
+// Sends during the tick succeed once queued, a write error only shows up in the log
+// when the batch is flushed.
+
+const defaultBatchBytes = 64 << 10
+
+// outbox collects a player's messages during a tick
+type outbox struct {
+	mu     sync.Mutex
+	msgs   [][]byte
+	size   int
+	limit  int
+	closed bool
+	// prio is the most urgent of the queued messages, the batch is written with it
+	prio Priority
+}
+
+// beginBatch starts collecting, false if the player already is
+func (p *Player) beginBatch(limit int) bool {
+	return p.batch.CompareAndSwap(nil, &outbox{limit: limit})
+}
+
+// endBatch writes what was collected and goes back to writing directly
+func (p *Player) endBatch() {
+	b := p.batch.Load()
+	if b == nil {
+		return
+	}
+
+	// Written under b.mu: a message sent meanwhile waits and goes out after the batch
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	p.batch.CompareAndSwap(b, nil)
+	p.flushLocked(b)
+}
+
+// queue adds an encoded message to the batch, false when the batch was already flushed
+func (b *outbox) queue(p *Player, data []byte, prio Priority) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return false
+	}
+	b.msgs = append(b.msgs, data)
+	b.size += len(data)
+	if prio > b.prio {
+		b.prio = prio
+	}
+	if b.size >= b.limit {
+		p.flushLocked(b)
+	}
+	return true
+}
+
+func (p *Player) flushLocked(b *outbox) {
+	msgs, prio := b.msgs, b.prio
+	b.msgs, b.size, b.prio = nil, 0, PriorityChat
+
+	var err error
+	switch len(msgs) {
+	case 0:
+		return
+	case 1:
+		err = p.writeFrame(websocket.TextMessage, msgs[0], true, nil, prio)
+	default:
+		err = p.writeFrame(websocket.TextMessage, encodeBatch(p.ID, msgs), true, nil, prio)
+	}
+	if err != nil {
+		log.Printf("Error flushing %d batched messages to player %s: %v", len(msgs), p.ID, err)
+	}
+}
+
+// encodeBatch wraps the messages into a BATCH message
+func encodeBatch(playerID string, msgs [][]byte) []byte {
+	size := len(`{"messages":[]}`) + len(msgs)
+	for _, m := range msgs {
+		size += len(m)
+	}
+
+	payload := make([]byte, 0, size)
+	payload = append(payload, `{"messages":[`...)
+	for i, m := range msgs {
+		if i > 0 {
+			payload = append(payload, ',')
+		}
+		payload = append(payload, m...)
+	}
+	payload = append(payload, "]}"...)
+	return encodeEnvelope(StructuredMessage{Type: Batch, PlayerID: playerID}, payload)
+}
+
+// batched wraps a tick function so the members that support it get the tick as one frame
+func (r *Room) batched(fn func(now time.Time)) func(now time.Time) {
+	limit := r.gs.batchBytes
+	if limit <= 0 {
+		return fn
+	}
+
+	return func(now time.Time) {
+		var open []*Player
+		for _, member := range r.Members() {
+			if member.HasFeature("batching") && member.beginBatch(limit) {
+				open = append(open, member)
+			}
+		}
+		defer func() {
+			for _, member := range open {
+				member.endBatch()
+			}
+		}()
+		fn(now)
+	}
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Bots are players without a websocket. They take a slot, join rooms and get every message a
+// client would, but through a channel, and what they send goes through the same pipeline as
+// frames read from a connection. Use them to fill matches or to drive the server in tests.
+
+const defaultBotInboxSize = 256
+
+// BotMessage is a frame the server sent to a bot
+type BotMessage struct {
+	// Type is websocket.TextMessage, BinaryMessage or CloseMessage when the bot was disconnected
+	Type int
+	Data []byte
+}
+
+// BotOptions tunes a bot, the zero value is fine
+type BotOptions struct {
+	// AccountID defaults to the bot's player ID
+	AccountID string
+	// Class is the slot class the bot is counted against, SlotRegular when empty
+	Class SlotClass
+	// InboxSize is how many frames can wait for the bot, 256 when 0
+	// Frames beyond that are dropped instead of stalling the server, see Bot.Dropped.
+	InboxSize int
+}
+
+// botLink is the Transport of a bot, what's written to it lands in the inbox
+type botLink struct {
+	playerID string
+
+	mu      sync.Mutex
+	inbox   chan BotMessage
+	closed  bool
+	dropped atomic.Uint64
+}
+
+// Bot is an in-process player
+type Bot struct {
+	Player *Player
+	gs     *GameServer
+	link   *botLink
+}
+
+// AddBot registers a bot player, it counts against capacity like any other player
+func (gs *GameServer) AddBot(opts BotOptions) (*Bot, error) {
+	if opts.Class == "" {
+		opts.Class = SlotRegular
+	}
+	if opts.InboxSize <= 0 {
+		opts.InboxSize = defaultBotInboxSize
+	}
+
+	link := &botLink{inbox: make(chan BotMessage, opts.InboxSize)}
+	player := gs.newPlayer(link, opts.Class)
+	link.playerID = player.ID
+	if opts.AccountID != "" {
+		player.AccountID = opts.AccountID
+	}
+	player.bot = link
+	// Bots are the server's own, they don't introduce themselves
+	player.joinPending.Store(false)
+
+	if err := gs.addPlayer(player); err != nil {
+		return nil, err
+	}
+	return &Bot{Player: player, gs: gs, link: link}, nil
+}
+
+// IsBot reports whether the player is a bot added with AddBot
+func (p *Player) IsBot() bool {
+	return p.bot != nil
+}
+
+// Messages returns the frames the server sent to the bot, it's closed when the bot leaves
+func (b *Bot) Messages() <-chan BotMessage {
+	return b.link.inbox
+}
+
+// Send submits a structured message as if the bot's client had sent it
+func (b *Bot) Send(msgType MessageType, payload interface{}) error {
+	data, err := encodeMessage(StructuredMessage{Type: msgType, PlayerID: b.Player.ID}, payload)
+	if err != nil {
+		return err
+	}
+	return b.SendRaw(websocket.TextMessage, data)
+}
+
+// SendRaw submits a text or binary frame as if it had been read from a connection
+func (b *Bot) SendRaw(messageType int, data []byte) error {
+	if !b.gs.isConnected(b.Player) {
+		return fmt.Errorf("bot %s has left", b.Player.ID)
+	}
+	return b.gs.handleInbound(b.Player, messageType, data)
+}
+
+// Leave disconnects the bot, freeing its slot
+func (b *Bot) Leave() {
+	b.gs.unregisterPlayer(b.Player)
+}
+
+// Dropped counts frames dropped because the bot didn't keep up with its inbox
+func (b *Bot) Dropped() uint64 {
+	return b.link.dropped.Load()
+}
+
+// ReadFrame is never called, what bots send goes straight to handleInbound
+func (l *botLink) ReadFrame(deadline time.Time) (int, []byte, error) {
+	return 0, nil, fmt.Errorf("bot %s has no connection to read", l.playerID)
+}
+
+// WriteFrame queues a frame for the bot, it never blocks
+func (l *botLink) WriteFrame(messageType int, data []byte, deadline time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return fmt.Errorf("bot %s has left", l.playerID)
+	}
+
+	// Copy since callers reuse buffers for the next player
+	frame := BotMessage{Type: messageType, Data: append([]byte(nil), data...)}
+	select {
+	case l.inbox <- frame:
+	default:
+		if l.dropped.Add(1) == 1 {
+			log.Printf("Bot %s is not reading its messages, dropping frames", l.playerID)
+		}
+	}
+	return nil
+}
+
+// Close ends the bot's inbox, with a CloseMessage first unless it's a plain close
+func (l *botLink) Close(code int, reason string) error {
+	if code != websocket.CloseNormalClosure || reason != "" {
+		l.WriteFrame(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Time{})
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.closed {
+		l.closed = true
+		close(l.inbox)
+	}
+	return nil
+}
+
+func (l *botLink) RemoteAddr() net.Addr {
+	return botAddr(l.playerID)
+}
+
+// botAddr is the address of a bot, its player ID
+type botAddr string
+
+func (a botAddr) Network() string { return "bot" }
+func (a botAddr) String() string  { return string(a) }
+
+func (gs *GameServer) isConnected(player *Player) bool {
+	current, ok := gs.players.get(player.ID)
+	return ok && current == player
+}
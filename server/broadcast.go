@@ -0,0 +1,60 @@
+package server
+
+// Targeted broadcasts take a snapshot of the players and filter it outside the players lock,
+// so the filter may call back into the server and a slow write never holds the lock.
+
+// PlayerFilter picks the players a broadcast goes to
+type PlayerFilter func(player *Player) bool
+
+// PlayerIDs matches the players with the given IDs
+func PlayerIDs(ids ...string) PlayerFilter {
+	set := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return func(player *Player) bool {
+		_, ok := set[player.ID]
+		return ok
+	}
+}
+
+// Not inverts a filter, e.g. Not(PlayerIDs(senderID))
+func Not(filter PlayerFilter) PlayerFilter {
+	return func(player *Player) bool { return !filter(player) }
+}
+
+// BroadcastTo sends a structured message to every connected player the filter matches
+func (gs *GameServer) BroadcastTo(filter PlayerFilter, msgType MessageType, payload interface{}) BroadcastResult {
+	var recipients []*Player
+	for _, player := range gs.snapshotPlayers() {
+		if player.Joined() && filter(player) {
+			recipients = append(recipients, player)
+		}
+	}
+	return deliverTo(gs, recipients, msgType, payload, "broadcast")
+}
+
+// BroadcastExcept sends a structured message to every connected player but the sender
+func (gs *GameServer) BroadcastExcept(senderID string, msgType MessageType, payload interface{}) BroadcastResult {
+	return gs.BroadcastTo(func(player *Player) bool { return player.ID != senderID }, msgType, payload)
+}
+
+// BroadcastTo sends a structured message to the members of the room the filter matches
+func (r *Room) BroadcastTo(filter PlayerFilter, msgType MessageType, payload interface{}) BroadcastResult {
+	var recipients []*Player
+	for _, player := range r.Members() {
+		if filter(player) {
+			recipients = append(recipients, player)
+		}
+	}
+	return deliverTo(r.gs, recipients, msgType, payload, "room "+r.ID)
+}
+
+// BroadcastExcept sends a structured message to every member of the room but the sender
+func (r *Room) BroadcastExcept(senderID string, msgType MessageType, payload interface{}) BroadcastResult {
+	return r.BroadcastTo(func(player *Player) bool { return player.ID != senderID }, msgType, payload)
+}
+
+func (gs *GameServer) snapshotPlayers() []*Player {
+	return gs.players.snapshot()
+}
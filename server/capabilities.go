@@ -0,0 +1,137 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ProtocolVersion is the version of the message protocol this server speaks
+// Bump it on breaking changes to messages.json or the binary frames, and raise
+// MinProtocolVersion once older clients are no longer supported.
+const (
+	ProtocolVersion    = 1
+	MinProtocolVersion = 1
+)
+
+// Capabilities describes how this deployment is configured, so clients and tools can adapt
+// It is built on every call because limits can change at runtime.
+func (gs *GameServer) Capabilities() CapabilitiesPayload {
+	codecs := []string{"json", "binary"}
+	if gs.compression != nil {
+		codecs = append(codecs, "permessage-deflate")
+	}
+
+	gs.serversMu.Lock()
+	webTransport := gs.webTransport != nil
+	tcp := len(gs.tcpListeners) > 0
+	udp := gs.udp != nil
+	grpcBridge := gs.grpcServer != nil
+	gs.serversMu.Unlock()
+
+	modules := []string{"rooms", "parties", "presence", "direct_messages", "lag_compensation", "interest"}
+	optional := []struct {
+		name    string
+		enabled bool
+	}{
+		{"waiting_queue", gs.queue != nil},
+		{"spectators", gs.maxSpectators > 0},
+		{"friends", gs.friendStore != nil},
+		{"blocks", gs.blockStore != nil},
+		{"offline_messages", gs.offlineMessages != nil},
+		{"room_results", gs.resultStore != nil},
+		{"activity_persistence", gs.activityStore != nil},
+		{"onboarding", gs.onboarding != nil},
+		{"surveys", gs.surveys != nil},
+		{"bans", gs.banStore != nil},
+		{"reliable_delivery", gs.reliable != nil},
+		{"idle_kick", gs.idle != nil},
+		{"join_handshake", gs.join != nil},
+		{"webtransport", webTransport},
+		{"tcp", tcp},
+		{"udp", udp},
+		{"grpc", grpcBridge},
+		{"batching", gs.batchBytes > 0},
+		{"party_encryption", gs.partyEncryption},
+		{"session_resume", gs.sessions != nil},
+		{"ratings", gs.ratings != nil},
+		{"matchmaking", gs.matchmaker != nil},
+		{"room_manager", gs.roomManager != nil},
+		{"webhooks", gs.webhooks != nil},
+		{"discord", gs.discord != nil},
+		{"rtt", gs.rtt != nil},
+		{"chunked_transfer", gs.chunks != nil},
+		{"rtc_signaling", gs.signaling != nil},
+		{"voice", gs.voice != nil},
+	}
+	for _, m := range optional {
+		if m.enabled {
+			modules = append(modules, m.name)
+		}
+	}
+
+	// Both can change at runtime, see reload.go
+	gs.roomsMu.RLock()
+	quota := gs.defaultRoomQuota
+	gs.roomsMu.RUnlock()
+	gs.playersMu.RLock()
+	maxPlayers := gs.maxPlayers
+	gs.playersMu.RUnlock()
+
+	limits := map[string]int{
+		"max_players":                  maxPlayers,
+		"max_spectators":               gs.maxSpectators,
+		"max_connections_per_ip":       gs.maxConnectionsPerIP(),
+		"max_party_size":               gs.maxPartySize,
+		"tick_rate":                    gs.tickRate,
+		"read_timeout_ms":              int(gs.readTimeout / time.Millisecond),
+		"write_timeout_ms":             int(gs.writeTimeout / time.Millisecond),
+		"room_max_entities":            quota.MaxEntities,
+		"room_max_stored_bytes":        quota.MaxStoredBytes,
+		"room_max_messages_per_second": int(quota.MaxMessagesPerSecond),
+	}
+
+	return CapabilitiesPayload{
+		ProtocolVersion:    ProtocolVersion,
+		MinProtocolVersion: MinProtocolVersion,
+		Codecs:             codecs,
+		Modules:            modules,
+		Limits:             limits,
+	}
+}
+
+// greet runs once a connection got a slot, before its messages are read:
+// capabilities come first, then onboarding for accounts that haven't finished it
+func (gs *GameServer) greet(player *Player) {
+	if err := gs.SendCapabilities(player.ID, gs.Capabilities()); err != nil {
+		log.Printf("Error sending capabilities to player %s: %v", player.ID, err)
+	}
+	gs.offerUDP(player)
+	gs.startSession(player)
+	player.greeted.Store(true)
+	if onConnect := gs.currentHooks().onConnect; onConnect != nil {
+		onConnect(player)
+	}
+	if !player.Joined() {
+		// Onboarding starts once the JOIN is accepted
+		gs.awaitJoin(player)
+		return
+	}
+	gs.startOnboarding(player)
+}
+
+// handleCapabilities serves GET /capabilities, it only describes the deployment so it needs no auth
+func (gs *GameServer) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	// Public information, so tools on any page may read it
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if err := json.NewEncoder(w).Encode(gs.Capabilities()); err != nil {
+		log.Printf("Error writing capabilities: %v", err)
+	}
+}
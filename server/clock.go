@@ -0,0 +1,30 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Message timestamps are unix milliseconds of the server clock. To line them up with its
+// own clock a client runs NTP-style TIME_SYNC round trips: it sends client_send (t0), the
+// server fills in when it received the request (t1) and sent the answer (t2), and the
+// client notes when the answer arrived (t3). Then
+//
+//	offset = ((t1 - t0) + (t2 - t3)) / 2    server clock minus client clock
+//	rtt    = (t3 - t0) - (t2 - t1)
+//
+// The estimate assumes both directions take as long, so clients should send a few rounds
+// and keep the offset of the shortest round trip. The Go client does this in SyncClock.
+
+// handleTimeSync answers TIME_SYNC, stamping the times as close to the socket as possible
+func (gs *GameServer) handleTimeSync(player *Player, msg StructuredMessage) error {
+	received := time.Now().UnixMilli()
+	var payload TimeSyncPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid time sync: %v", err)
+	}
+	payload.ServerReceive = received
+	payload.ServerSend = time.Now().UnixMilli()
+	return gs.SendTimeSync(player.ID, payload)
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// When the server ends a connection it says why: the close frame carries one of the codes
+// below and the reason's name, e.g. "KICKED", followed by ": " and a detail for people when
+// there is one. Clients switch on the code or the name, never on the detail. OnDisconnect
+// hooks find the same reason on Player.DisconnectReason, including for players that left
+// on their own (LEFT) or timed out.
+//
+// Connections that are stuck on a write get no close frame, it couldn't get through.
+
+// Close codes of the server, from the range reserved for applications
+const (
+	CloseIdle             = 4000
+	CloseSessionReplaced  = 4001
+	CloseAlreadyConnected = 4002
+	CloseKicked           = 4003
+	CloseServerFull       = 4004
+	CloseProtocolError    = 4005
+	CloseShutdown         = 4006
+	CloseBanned           = 4007
+	CloseSlowConsumer     = 4008
+	CloseUnauthorized     = 4009
+)
+
+// maxCloseReason is what fits in a close frame next to the code
+const maxCloseReason = 123
+
+// DisconnectReason is why a connection ended
+type DisconnectReason struct {
+	// Code is the close code, see above
+	Code int `json:"code"`
+	// Reason is the name clients can rely on
+	Reason string `json:"reason"`
+	// Detail explains it to people, may be empty
+	Detail string `json:"detail,omitempty"`
+}
+
+var (
+	// ReasonLeft is a client that closed the connection or dropped off itself
+	ReasonLeft = DisconnectReason{Code: websocket.CloseNormalClosure, Reason: "LEFT"}
+	// ReasonClosed is a disconnect by the game without a more specific reason, e.g.
+	// UnregisterPlayer
+	ReasonClosed = DisconnectReason{Code: websocket.CloseNormalClosure, Reason: "CLOSED"}
+	// ReasonInternalError is a connection whose message crashed its handler
+	ReasonInternalError = DisconnectReason{Code: websocket.CloseInternalServerErr, Reason: "INTERNAL_ERROR"}
+
+	ReasonIdle             = DisconnectReason{Code: CloseIdle, Reason: "IDLE"}
+	ReasonSessionReplaced  = DisconnectReason{Code: CloseSessionReplaced, Reason: "SESSION_REPLACED"}
+	ReasonAlreadyConnected = DisconnectReason{Code: CloseAlreadyConnected, Reason: "ALREADY_CONNECTED"}
+	ReasonKicked           = DisconnectReason{Code: CloseKicked, Reason: "KICKED"}
+	ReasonServerFull       = DisconnectReason{Code: CloseServerFull, Reason: "SERVER_FULL"}
+	ReasonProtocolError    = DisconnectReason{Code: CloseProtocolError, Reason: "PROTOCOL_ERROR"}
+	ReasonShutdown         = DisconnectReason{Code: CloseShutdown, Reason: "SHUTDOWN"}
+	ReasonBanned           = DisconnectReason{Code: CloseBanned, Reason: "BANNED"}
+	ReasonSlowConsumer     = DisconnectReason{Code: CloseSlowConsumer, Reason: "SLOW_CONSUMER"}
+	ReasonUnauthorized     = DisconnectReason{Code: CloseUnauthorized, Reason: "UNAUTHORIZED"}
+)
+
+// WithDetail returns the reason with a detail for people
+func (r DisconnectReason) WithDetail(detail string) DisconnectReason {
+	r.Detail = detail
+	return r
+}
+
+// String is the text of the close frame, cut to fit
+func (r DisconnectReason) String() string {
+	s := r.Reason
+	if r.Detail != "" {
+		s += ": " + r.Detail
+	}
+	if len(s) > maxCloseReason {
+		s = strings.ToValidUTF8(s[:maxCloseReason], "")
+	}
+	return s
+}
+
+// DisconnectReason is why the player was disconnected, ReasonClosed while it's connected
+// or when nothing more specific is known
+func (p *Player) DisconnectReason() DisconnectReason {
+	if r := p.disconnectReason.Load(); r != nil {
+		return *r
+	}
+	return ReasonClosed
+}
+
+// setDisconnectReason records why the player goes, the first reason wins
+func (p *Player) setDisconnectReason(reason DisconnectReason) {
+	p.disconnectReason.CompareAndSwap(nil, &reason)
+}
+
+// leftReason is the reason of a read loop that ended with err
+func leftReason(err error) DisconnectReason {
+	if isTimeout(err) {
+		return ReasonIdle.WithDetail("read timeout")
+	}
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) && closeErr.Text != "" {
+		return ReasonLeft.WithDetail(closeErr.Text)
+	}
+	return ReasonLeft
+}
+
+// refuseReason is the reason a connection that didn't make it in is closed with
+func refuseReason(err error) DisconnectReason {
+	switch {
+	case errors.Is(err, ErrAccountConnected):
+		return ReasonAlreadyConnected
+	case errors.Is(err, ErrServerFull), errors.Is(err, ErrTooManyConnections):
+		return ReasonServerFull.WithDetail(err.Error())
+	case errors.Is(err, ErrBanned):
+		return ReasonBanned
+	case errors.Is(err, ErrLoginRequired), errors.Is(err, ErrInvalidToken):
+		return ReasonUnauthorized
+	}
+	return ReasonClosed
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"compress/flate"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Messages smaller than this are not worth compressing by default
+const defaultCompressionThreshold = 512
+
+// compressionConfig is set through WithCompression, nil means compression is off
+type compressionConfig struct {
+	level     int
+	threshold int
+}
+
+// clientSupportsDeflate checks whether the client offered permessage-deflate in the handshake
+func clientSupportsDeflate(r *http.Request) bool {
+	for _, ext := range r.Header.Values("Sec-Websocket-Extensions") {
+		if strings.Contains(ext, "permessage-deflate") {
+			return true
+		}
+	}
+	return false
+}
+
+// setupCompression configures compression for a freshly upgraded connection
+// Only clients that negotiated permessage-deflate get compressed frames
+func (gs *GameServer) setupCompression(player *Player, r *http.Request) {
+	ws, ok := baseTransport(player.transport).(*wsTransport)
+	if gs.compression == nil || !ok || !clientSupportsDeflate(r) {
+		return
+	}
+
+	if err := ws.conn.SetCompressionLevel(gs.compression.level); err != nil {
+		// Can't happen with a level validated by WithCompression, compress with the default then
+		player.tracef("compression level: %v", err)
+	}
+	ws.compressThreshold = gs.compression.threshold
+}
+
+func validateCompressionLevel(level int) error {
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		return fmt.Errorf("invalid compression level %d", level)
+	}
+	return nil
+}
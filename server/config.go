@@ -0,0 +1,732 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iknizzz1807/socket-server-template/database"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration written as "10s" or "5m" in config files
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Config holds the deployment settings of a server
+// Load it with LoadConfig, which applies defaults, the config file and environment variables in that order.
+type Config struct {
+	ListenAddr   string   `json:"listen_addr" yaml:"listen_addr"`
+	MaxPlayers   int      `json:"max_players" yaml:"max_players"`
+	ReadTimeout  Duration `json:"read_timeout" yaml:"read_timeout"`
+	WriteTimeout Duration `json:"write_timeout" yaml:"write_timeout"`
+	// AllowedOrigins are the origin patterns accepted on upgrade, see WithAllowedOrigins
+	AllowedOrigins []string `json:"allowed_origins" yaml:"allowed_origins"`
+	// DevOrigins allows connections from any origin, never enable it in production
+	DevOrigins bool `json:"dev_origins" yaml:"dev_origins"`
+	// TickRate is the default number of room ticks per second
+	TickRate int `json:"tick_rate" yaml:"tick_rate"`
+	// Message rate limit of rooms, 0 means unlimited. See RoomQuota.
+	RoomMaxMessagesPerSecond float64 `json:"room_max_messages_per_second" yaml:"room_max_messages_per_second"`
+	RoomMessageBurst         int     `json:"room_message_burst" yaml:"room_message_burst"`
+	// MaxConnectionsPerIP caps connections from one address, 0 means no cap
+	MaxConnectionsPerIP int `json:"max_connections_per_ip" yaml:"max_connections_per_ip"`
+	// Modes are the game-mode profiles rooms can be created with, see CreateRoomWithMode
+	Modes map[string]ModeProfile `json:"modes" yaml:"modes"`
+	// TLS is used when both files are set
+	TLSCertFile string `json:"tls_cert_file" yaml:"tls_cert_file"`
+	TLSKeyFile  string `json:"tls_key_file" yaml:"tls_key_file"`
+	// WebTransportAddr also serves /wt over HTTP/3 when set, it needs the TLS files
+	WebTransportAddr string `json:"webtransport_addr" yaml:"webtransport_addr"`
+	// TCPAddr also accepts native clients on a raw TCP socket when set, see StartTCP
+	TCPAddr string `json:"tcp_addr" yaml:"tcp_addr"`
+	// UDPAddr opens the unreliable UDP side channel when set, see StartUDP
+	UDPAddr string `json:"udp_addr" yaml:"udp_addr"`
+	// GRPCAddr serves the gRPC bridge when set, with TLS when the TLS files are set
+	GRPCAddr string `json:"grpc_addr" yaml:"grpc_addr"`
+	// AdminAddr serves pprof and runtime stats when set, AdminToken is their bearer token
+	AdminAddr  string `json:"admin_addr" yaml:"admin_addr"`
+	AdminToken string `json:"admin_token" yaml:"admin_token"`
+	// ScriptsDir loads Lua game scripts from the directory and reloads them on change, see WithScripts
+	ScriptsDir string `json:"scripts_dir" yaml:"scripts_dir"`
+	// Login enables the /auth/ endpoints when a provider is configured, see WithLogin.
+	// LoginSecret signs the tokens, LoginBaseURL is the public URL of the server.
+	LoginSecret         string `json:"login_secret" yaml:"login_secret"`
+	LoginBaseURL        string `json:"login_base_url" yaml:"login_base_url"`
+	LoginRedirectURL    string `json:"login_redirect_url" yaml:"login_redirect_url"`
+	LoginRequired       bool   `json:"login_required" yaml:"login_required"`
+	GoogleClientID      string `json:"google_client_id" yaml:"google_client_id"`
+	GoogleClientSecret  string `json:"google_client_secret" yaml:"google_client_secret"`
+	DiscordClientID     string `json:"discord_client_id" yaml:"discord_client_id"`
+	DiscordClientSecret string `json:"discord_client_secret" yaml:"discord_client_secret"`
+	SteamLogin          bool   `json:"steam_login" yaml:"steam_login"`
+	// InjectSecret enables POST /inject for backends, see WithInjection
+	InjectSecret string `json:"inject_secret" yaml:"inject_secret"`
+	// SessionRedisAddr keeps resumable sessions in Redis, SessionTTL is how long they are
+	// kept after a disconnect. See WithSessionStore.
+	SessionRedisAddr     string   `json:"session_redis_addr" yaml:"session_redis_addr"`
+	SessionRedisPassword string   `json:"session_redis_password" yaml:"session_redis_password"`
+	SessionRedisDB       int      `json:"session_redis_db" yaml:"session_redis_db"`
+	SessionTTL           Duration `json:"session_ttl" yaml:"session_ttl"`
+	// Region and PingURL are the ping hints of the lobby, see WithRegion
+	Region  string `json:"region" yaml:"region"`
+	PingURL string `json:"ping_url" yaml:"ping_url"`
+	// EmptyRoomTTL closes rooms empty for that long, see WithRoomManager. The room manager
+	// also runs when a mode sets min_free_rooms.
+	EmptyRoomTTL Duration `json:"empty_room_ttl" yaml:"empty_room_ttl"`
+	// Webhooks get game events posted to them, see WithWebhooks. WebhookSecret signs the
+	// ones without a secret of their own.
+	Webhooks      []Webhook `json:"webhooks" yaml:"webhooks"`
+	WebhookSecret string    `json:"webhook_secret" yaml:"webhook_secret"`
+	// The Discord bot posts events to discord_channel_id and takes commands from
+	// discord_admins, see WithDiscord
+	DiscordBotToken      string   `json:"discord_bot_token" yaml:"discord_bot_token"`
+	DiscordChannelID     string   `json:"discord_channel_id" yaml:"discord_channel_id"`
+	DiscordEvents        []string `json:"discord_events" yaml:"discord_events"`
+	DiscordApplicationID string   `json:"discord_application_id" yaml:"discord_application_id"`
+	DiscordPublicKey     string   `json:"discord_public_key" yaml:"discord_public_key"`
+	DiscordAdmins        []string `json:"discord_admins" yaml:"discord_admins"`
+	// RTTInterval pings players that often to measure their round trip, off when 0.
+	// RTTInState adds it to state messages, see WithRTT.
+	RTTInterval Duration `json:"rtt_interval" yaml:"rtt_interval"`
+	RTTInState  bool     `json:"rtt_in_state" yaml:"rtt_in_state"`
+	// Sim* simulate a bad network in both directions for local testing, see
+	// WithNetworkSimulation. Never set them in production.
+	SimLatency Duration `json:"sim_latency" yaml:"sim_latency"`
+	SimJitter  Duration `json:"sim_jitter" yaml:"sim_jitter"`
+	SimDrop    float64  `json:"sim_drop" yaml:"sim_drop"`
+	SimReorder float64  `json:"sim_reorder" yaml:"sim_reorder"`
+	// CoordinatorURL lists the server in that coordinator's server browser under
+	// PublicAddr and ServerName, see WithRegistration. CoordinatorAddr runs a coordinator
+	// on that address, see StartCoordinator. Both use CoordinatorSecret.
+	CoordinatorURL    string `json:"coordinator_url" yaml:"coordinator_url"`
+	CoordinatorAddr   string `json:"coordinator_addr" yaml:"coordinator_addr"`
+	CoordinatorSecret string `json:"coordinator_secret" yaml:"coordinator_secret"`
+	PublicAddr        string `json:"public_addr" yaml:"public_addr"`
+	ServerName        string `json:"server_name" yaml:"server_name"`
+	// BanFile keeps the ban list in that JSON file so bans survive restarts, see
+	// WithBanStore. It is created on the first ban.
+	BanFile string `json:"ban_file" yaml:"ban_file"`
+	// MetricsHistoryFile records a metrics snapshot every minute in that file and serves
+	// them on /admin/metrics/history, see WithMetricsHistory. A week is kept.
+	MetricsHistoryFile string `json:"metrics_history_file" yaml:"metrics_history_file"`
+
+	// path is the file LoadConfig read, empty when there was none
+	path string
+}
+
+// Environment variables override the config file, e.g. GAME_MAX_PLAYERS=200
+const (
+	EnvListenAddr     = "GAME_LISTEN_ADDR"
+	EnvMaxPlayers     = "GAME_MAX_PLAYERS"
+	EnvReadTimeout    = "GAME_READ_TIMEOUT"
+	EnvWriteTimeout   = "GAME_WRITE_TIMEOUT"
+	EnvAllowedOrigins = "GAME_ALLOWED_ORIGINS" // comma separated
+	EnvDevOrigins     = "GAME_DEV_ORIGINS"
+	EnvTickRate       = "GAME_TICK_RATE"
+	EnvRoomRate       = "GAME_ROOM_MAX_MESSAGES_PER_SECOND"
+	EnvMaxPerIP       = "GAME_MAX_CONNECTIONS_PER_IP"
+	EnvTLSCertFile    = "GAME_TLS_CERT_FILE"
+	EnvTLSKeyFile     = "GAME_TLS_KEY_FILE"
+	EnvWebTransport   = "GAME_WEBTRANSPORT_ADDR"
+	EnvTCPAddr        = "GAME_TCP_ADDR"
+	EnvUDPAddr        = "GAME_UDP_ADDR"
+	EnvGRPCAddr       = "GAME_GRPC_ADDR"
+	EnvAdminAddr      = "GAME_ADMIN_ADDR"
+	EnvAdminToken     = "GAME_ADMIN_TOKEN"
+	EnvScriptsDir     = "GAME_SCRIPTS_DIR"
+	EnvLoginSecret    = "GAME_LOGIN_SECRET"
+	EnvLoginBaseURL   = "GAME_LOGIN_BASE_URL"
+	EnvLoginRedirect  = "GAME_LOGIN_REDIRECT_URL"
+	EnvLoginRequired  = "GAME_LOGIN_REQUIRED"
+	EnvGoogleID       = "GAME_GOOGLE_CLIENT_ID"
+	EnvGoogleSecret   = "GAME_GOOGLE_CLIENT_SECRET"
+	EnvDiscordID      = "GAME_DISCORD_CLIENT_ID"
+	EnvDiscordSecret  = "GAME_DISCORD_CLIENT_SECRET"
+	EnvSteamLogin     = "GAME_STEAM_LOGIN"
+	EnvInjectSecret   = "GAME_INJECT_SECRET"
+	EnvSessionRedis   = "GAME_SESSION_REDIS_ADDR"
+	EnvSessionRedisPW = "GAME_SESSION_REDIS_PASSWORD"
+	EnvSessionRedisDB = "GAME_SESSION_REDIS_DB"
+	EnvSessionTTL     = "GAME_SESSION_TTL"
+	EnvRegion         = "GAME_REGION"
+	EnvPingURL        = "GAME_PING_URL"
+	EnvEmptyRoomTTL   = "GAME_EMPTY_ROOM_TTL"
+	EnvWebhookSecret  = "GAME_WEBHOOK_SECRET"
+	EnvDiscordToken   = "GAME_DISCORD_BOT_TOKEN"
+	EnvRTTInterval    = "GAME_RTT_INTERVAL"
+	EnvSimLatency     = "GAME_SIM_LATENCY"
+	EnvSimJitter      = "GAME_SIM_JITTER"
+	EnvSimDrop        = "GAME_SIM_DROP"
+	EnvSimReorder     = "GAME_SIM_REORDER"
+	EnvCoordinatorURL = "GAME_COORDINATOR_URL"
+	EnvCoordinator    = "GAME_COORDINATOR_ADDR"
+	EnvCoordinatorKey = "GAME_COORDINATOR_SECRET"
+	EnvPublicAddr     = "GAME_PUBLIC_ADDR"
+	EnvServerName     = "GAME_SERVER_NAME"
+	EnvBanFile        = "GAME_BAN_FILE"
+	EnvMetricsHistory = "GAME_METRICS_HISTORY_FILE"
+)
+
+const (
+	defaultReadTimeout = 10 * time.Minute
+	defaultTickRate    = 20
+)
+
+// DefaultConfig is what the server runs with when nothing is configured
+func DefaultConfig() Config {
+	return Config{
+		ListenAddr:   ":8080",
+		MaxPlayers:   100,
+		ReadTimeout:  Duration(defaultReadTimeout),
+		WriteTimeout: Duration(defaultWriteTimeout),
+		TickRate:     defaultTickRate,
+	}
+}
+
+// LoadConfig reads the config file at path (JSON or YAML, picked by extension) on top of
+// the defaults, then applies environment variables and validates the result.
+// An empty path skips the file.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	cfg.path = path
+
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to read config: %v", err)
+		}
+
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json":
+			err = json.Unmarshal(raw, &cfg)
+		case ".yaml", ".yml":
+			err = yaml.Unmarshal(raw, &cfg)
+		default:
+			return cfg, fmt.Errorf("unknown config format %q, use .json, .yaml or .yml", filepath.Ext(path))
+		}
+		if err != nil {
+			return cfg, fmt.Errorf("invalid config file %s: %v", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) applyEnv() error {
+	if v, ok := os.LookupEnv(EnvListenAddr); ok {
+		c.ListenAddr = v
+	}
+	if v, ok := os.LookupEnv(EnvMaxPlayers); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", EnvMaxPlayers, err)
+		}
+		c.MaxPlayers = n
+	}
+	if v, ok := os.LookupEnv(EnvReadTimeout); ok {
+		if err := c.ReadTimeout.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("invalid %s: %v", EnvReadTimeout, err)
+		}
+	}
+	if v, ok := os.LookupEnv(EnvWriteTimeout); ok {
+		if err := c.WriteTimeout.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("invalid %s: %v", EnvWriteTimeout, err)
+		}
+	}
+	if v, ok := os.LookupEnv(EnvAllowedOrigins); ok {
+		c.AllowedOrigins = nil
+		for _, origin := range strings.Split(v, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				c.AllowedOrigins = append(c.AllowedOrigins, origin)
+			}
+		}
+	}
+	if v, ok := os.LookupEnv(EnvDevOrigins); ok {
+		dev, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", EnvDevOrigins, err)
+		}
+		c.DevOrigins = dev
+	}
+	if v, ok := os.LookupEnv(EnvTickRate); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", EnvTickRate, err)
+		}
+		c.TickRate = n
+	}
+	if v, ok := os.LookupEnv(EnvRoomRate); ok {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", EnvRoomRate, err)
+		}
+		c.RoomMaxMessagesPerSecond = rate
+	}
+	if v, ok := os.LookupEnv(EnvMaxPerIP); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", EnvMaxPerIP, err)
+		}
+		c.MaxConnectionsPerIP = n
+	}
+	if v, ok := os.LookupEnv(EnvTLSCertFile); ok {
+		c.TLSCertFile = v
+	}
+	if v, ok := os.LookupEnv(EnvTLSKeyFile); ok {
+		c.TLSKeyFile = v
+	}
+	if v, ok := os.LookupEnv(EnvWebTransport); ok {
+		c.WebTransportAddr = v
+	}
+	if v, ok := os.LookupEnv(EnvTCPAddr); ok {
+		c.TCPAddr = v
+	}
+	if v, ok := os.LookupEnv(EnvUDPAddr); ok {
+		c.UDPAddr = v
+	}
+	if v, ok := os.LookupEnv(EnvGRPCAddr); ok {
+		c.GRPCAddr = v
+	}
+	if v, ok := os.LookupEnv(EnvAdminAddr); ok {
+		c.AdminAddr = v
+	}
+	if v, ok := os.LookupEnv(EnvAdminToken); ok {
+		c.AdminToken = v
+	}
+	if v, ok := os.LookupEnv(EnvScriptsDir); ok {
+		c.ScriptsDir = v
+	}
+	if v, ok := os.LookupEnv(EnvLoginSecret); ok {
+		c.LoginSecret = v
+	}
+	if v, ok := os.LookupEnv(EnvLoginBaseURL); ok {
+		c.LoginBaseURL = v
+	}
+	if v, ok := os.LookupEnv(EnvLoginRedirect); ok {
+		c.LoginRedirectURL = v
+	}
+	if v, ok := os.LookupEnv(EnvLoginRequired); ok {
+		required, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", EnvLoginRequired, err)
+		}
+		c.LoginRequired = required
+	}
+	if v, ok := os.LookupEnv(EnvGoogleID); ok {
+		c.GoogleClientID = v
+	}
+	if v, ok := os.LookupEnv(EnvGoogleSecret); ok {
+		c.GoogleClientSecret = v
+	}
+	if v, ok := os.LookupEnv(EnvDiscordID); ok {
+		c.DiscordClientID = v
+	}
+	if v, ok := os.LookupEnv(EnvDiscordSecret); ok {
+		c.DiscordClientSecret = v
+	}
+	if v, ok := os.LookupEnv(EnvSteamLogin); ok {
+		steam, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", EnvSteamLogin, err)
+		}
+		c.SteamLogin = steam
+	}
+	if v, ok := os.LookupEnv(EnvInjectSecret); ok {
+		c.InjectSecret = v
+	}
+	if v, ok := os.LookupEnv(EnvSessionRedis); ok {
+		c.SessionRedisAddr = v
+	}
+	if v, ok := os.LookupEnv(EnvSessionRedisPW); ok {
+		c.SessionRedisPassword = v
+	}
+	if v, ok := os.LookupEnv(EnvSessionRedisDB); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", EnvSessionRedisDB, err)
+		}
+		c.SessionRedisDB = n
+	}
+	if v, ok := os.LookupEnv(EnvSessionTTL); ok {
+		if err := c.SessionTTL.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("invalid %s: %v", EnvSessionTTL, err)
+		}
+	}
+	if v, ok := os.LookupEnv(EnvRegion); ok {
+		c.Region = v
+	}
+	if v, ok := os.LookupEnv(EnvPingURL); ok {
+		c.PingURL = v
+	}
+	if v, ok := os.LookupEnv(EnvEmptyRoomTTL); ok {
+		if err := c.EmptyRoomTTL.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("invalid %s: %v", EnvEmptyRoomTTL, err)
+		}
+	}
+	if v, ok := os.LookupEnv(EnvWebhookSecret); ok {
+		c.WebhookSecret = v
+	}
+	if v, ok := os.LookupEnv(EnvDiscordToken); ok {
+		c.DiscordBotToken = v
+	}
+	if v, ok := os.LookupEnv(EnvRTTInterval); ok {
+		if err := c.RTTInterval.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("invalid %s: %v", EnvRTTInterval, err)
+		}
+	}
+	if v, ok := os.LookupEnv(EnvSimLatency); ok {
+		if err := c.SimLatency.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("invalid %s: %v", EnvSimLatency, err)
+		}
+	}
+	if v, ok := os.LookupEnv(EnvSimJitter); ok {
+		if err := c.SimJitter.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("invalid %s: %v", EnvSimJitter, err)
+		}
+	}
+	if v, ok := os.LookupEnv(EnvSimDrop); ok {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", EnvSimDrop, err)
+		}
+		c.SimDrop = rate
+	}
+	if v, ok := os.LookupEnv(EnvSimReorder); ok {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", EnvSimReorder, err)
+		}
+		c.SimReorder = rate
+	}
+	if v, ok := os.LookupEnv(EnvCoordinatorURL); ok {
+		c.CoordinatorURL = v
+	}
+	if v, ok := os.LookupEnv(EnvCoordinator); ok {
+		c.CoordinatorAddr = v
+	}
+	if v, ok := os.LookupEnv(EnvCoordinatorKey); ok {
+		c.CoordinatorSecret = v
+	}
+	if v, ok := os.LookupEnv(EnvPublicAddr); ok {
+		c.PublicAddr = v
+	}
+	if v, ok := os.LookupEnv(EnvServerName); ok {
+		c.ServerName = v
+	}
+	if v, ok := os.LookupEnv(EnvBanFile); ok {
+		c.BanFile = v
+	}
+	if v, ok := os.LookupEnv(EnvMetricsHistory); ok {
+		c.MetricsHistoryFile = v
+	}
+	return nil
+}
+
+// Validate reports the first setting that can't work
+func (c Config) Validate() error {
+	switch {
+	case c.ListenAddr == "":
+		return fmt.Errorf("listen_addr is required")
+	case c.MaxPlayers <= 0:
+		return fmt.Errorf("max_players must be positive, got %d", c.MaxPlayers)
+	case c.ReadTimeout <= 0:
+		return fmt.Errorf("read_timeout must be positive")
+	case c.WriteTimeout <= 0:
+		return fmt.Errorf("write_timeout must be positive")
+	case c.TickRate <= 0 || c.TickRate > 1000:
+		return fmt.Errorf("tick_rate must be between 1 and 1000, got %d", c.TickRate)
+	case c.MaxConnectionsPerIP < 0:
+		return fmt.Errorf("max_connections_per_ip can't be negative")
+	case c.RoomMaxMessagesPerSecond < 0 || c.RoomMessageBurst < 0:
+		return fmt.Errorf("room message rate and burst can't be negative")
+	case (c.TLSCertFile == "") != (c.TLSKeyFile == ""):
+		return fmt.Errorf("tls_cert_file and tls_key_file must be set together")
+	case c.WebTransportAddr != "" && !c.TLS():
+		return fmt.Errorf("webtransport_addr needs tls_cert_file and tls_key_file")
+	case c.AdminAddr != "" && c.AdminToken == "":
+		return fmt.Errorf("admin_addr needs admin_token")
+	case (len(c.loginProviders()) > 0 || c.LoginRequired) && len(c.LoginSecret) < minLoginSecret:
+		return fmt.Errorf("login needs a login_secret of at least %d bytes", minLoginSecret)
+	case len(c.loginProviders()) > 0 && c.LoginBaseURL == "":
+		return fmt.Errorf("login providers need login_base_url")
+	case c.InjectSecret != "" && len(c.InjectSecret) < minInjectKey:
+		return fmt.Errorf("inject_secret must be at least %d bytes", minInjectKey)
+	case c.SessionTTL < 0 || c.SessionRedisDB < 0:
+		return fmt.Errorf("session_ttl and session_redis_db can't be negative")
+	case c.EmptyRoomTTL < 0:
+		return fmt.Errorf("empty_room_ttl can't be negative")
+	case c.RTTInterval < 0:
+		return fmt.Errorf("rtt_interval can't be negative")
+	case c.RTTInState && c.RTTInterval == 0:
+		return fmt.Errorf("rtt_in_state needs rtt_interval")
+	case c.CoordinatorURL != "" && !strings.HasPrefix(c.CoordinatorURL, "http://") && !strings.HasPrefix(c.CoordinatorURL, "https://"):
+		return fmt.Errorf("coordinator_url must be http or https, got %q", c.CoordinatorURL)
+	case c.CoordinatorURL != "" && c.PublicAddr == "":
+		return fmt.Errorf("coordinator_url needs public_addr")
+	}
+
+	for name, mode := range c.Modes {
+		if err := mode.validate(); err != nil {
+			return fmt.Errorf("mode %s: %v", name, err)
+		}
+	}
+	for _, hook := range c.Webhooks {
+		if err := hook.validate(); err != nil {
+			return err
+		}
+	}
+	if err := c.discord().validate(); err != nil {
+		return err
+	}
+	if err := c.netConditions().validate(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// TLS reports whether the server should serve wss://
+func (c Config) TLS() bool {
+	return c.TLSCertFile != ""
+}
+
+// Options turns the config into server options, pass extra ones after them to override
+// It panics when the ban file can't be read, running without the ban list would let
+// banned players back in, or when the metrics history file can't be opened.
+func (c Config) Options() []Option {
+	opts := []Option{
+		WithReadTimeout(time.Duration(c.ReadTimeout)),
+		WithWriteTimeout(time.Duration(c.WriteTimeout)),
+		WithAllowedOrigins(c.AllowedOrigins...),
+		WithTickRate(c.TickRate),
+		WithMaxConnectionsPerIP(c.MaxConnectionsPerIP),
+		WithModes(c.Modes),
+		WithRegion(c.Region, c.PingURL),
+	}
+	if c.DevOrigins {
+		opts = append(opts, WithDevOrigins())
+	}
+	if c.RoomMaxMessagesPerSecond > 0 {
+		opts = append(opts, WithRoomQuota(RoomQuota{
+			MaxMessagesPerSecond: c.RoomMaxMessagesPerSecond,
+			MessageBurst:         c.RoomMessageBurst,
+		}))
+	}
+	if c.ScriptsDir != "" {
+		opts = append(opts, WithScripts(ScriptConfig{Dir: c.ScriptsDir, PollInterval: time.Second}))
+	}
+	if providers := c.loginProviders(); len(providers) > 0 || c.LoginRequired {
+		opts = append(opts, WithLogin(LoginConfig{
+			BaseURL:     c.LoginBaseURL,
+			Secret:      []byte(c.LoginSecret),
+			Providers:   providers,
+			RedirectURL: c.LoginRedirectURL,
+			Required:    c.LoginRequired,
+		}))
+	}
+	if c.InjectSecret != "" {
+		opts = append(opts, WithInjection([]byte(c.InjectSecret)))
+	}
+	if c.SessionRedisAddr != "" {
+		store := database.NewRedisSessionStore(database.RedisOptions{
+			Addr:     c.SessionRedisAddr,
+			Password: c.SessionRedisPassword,
+			DB:       c.SessionRedisDB,
+		})
+		opts = append(opts, WithSessionStore(store, time.Duration(c.SessionTTL)))
+	}
+	if c.roomManager() {
+		opts = append(opts, WithRoomManager(RoomManagerConfig{EmptyTTL: time.Duration(c.EmptyRoomTTL)}))
+	}
+	if len(c.Webhooks) > 0 {
+		hooks := make([]Webhook, len(c.Webhooks))
+		for i, hook := range c.Webhooks {
+			if hook.Secret == "" {
+				hook.Secret = c.WebhookSecret
+			}
+			hooks[i] = hook
+		}
+		opts = append(opts, WithWebhooks(WebhookConfig{Hooks: hooks}))
+	}
+	if discord := c.discord(); discord.ChannelID != "" || discord.PublicKey != "" {
+		opts = append(opts, WithDiscord(discord))
+	}
+	if c.RTTInterval > 0 {
+		opts = append(opts, WithRTT(RTTConfig{Interval: time.Duration(c.RTTInterval), InState: c.RTTInState}))
+	}
+	if cond := c.netConditions(); cond != (NetConditions{}) {
+		opts = append(opts, WithNetworkSimulation(NetSimConfig{Inbound: cond, Outbound: cond}))
+	}
+	if c.CoordinatorURL != "" {
+		opts = append(opts, WithRegistration(RegistrationConfig{
+			Coordinator: c.CoordinatorURL,
+			Address:     c.PublicAddr,
+			Name:        c.ServerName,
+			Secret:      c.CoordinatorSecret,
+		}))
+	}
+	if c.BanFile != "" {
+		store, err := database.NewFileBanStore(c.BanFile)
+		if err != nil {
+			panic(fmt.Sprintf("ban_file: %v", err))
+		}
+		opts = append(opts, WithBanStore(store))
+	}
+	if c.MetricsHistoryFile != "" {
+		store, err := database.NewFileMetricsStore(c.MetricsHistoryFile, defaultMetricsHistoryPoints)
+		if err != nil {
+			panic(fmt.Sprintf("metrics_history_file: %v", err))
+		}
+		opts = append(opts, WithMetricsHistory(store, 0))
+	}
+	return opts
+}
+
+// netConditions are the simulated conditions of each direction
+func (c Config) netConditions() NetConditions {
+	return NetConditions{
+		Latency: time.Duration(c.SimLatency),
+		Jitter:  time.Duration(c.SimJitter),
+		Drop:    c.SimDrop,
+		Reorder: c.SimReorder,
+	}
+}
+
+// roomManager reports whether the config needs the room manager
+func (c Config) roomManager() bool {
+	if c.EmptyRoomTTL > 0 {
+		return true
+	}
+	for _, mode := range c.Modes {
+		if mode.MinFreeRooms > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Config) discord() DiscordConfig {
+	return DiscordConfig{
+		BotToken:      c.DiscordBotToken,
+		ChannelID:     c.DiscordChannelID,
+		Events:        c.DiscordEvents,
+		ApplicationID: c.DiscordApplicationID,
+		PublicKey:     c.DiscordPublicKey,
+		Admins:        c.DiscordAdmins,
+	}
+}
+
+// loginProviders are the identity providers with credentials in the config
+func (c Config) loginProviders() map[string]LoginProvider {
+	providers := make(map[string]LoginProvider)
+	if c.GoogleClientID != "" {
+		providers["google"] = GoogleLogin(c.GoogleClientID, c.GoogleClientSecret)
+	}
+	if c.DiscordClientID != "" {
+		providers["discord"] = DiscordLogin(c.DiscordClientID, c.DiscordClientSecret)
+	}
+	if c.SteamLogin {
+		providers["steam"] = SteamLogin()
+	}
+	return providers
+}
+
+// NewGameServerFromConfig creates a server with everything the config sets
+func NewGameServerFromConfig(cfg Config, opts ...Option) *GameServer {
+	gs := NewGameServer(cfg.MaxPlayers, append(cfg.Options(), opts...)...)
+	// Kept so reloads can tell which settings changed
+	gs.config = &cfg
+	return gs
+}
+
+// Start listens on the configured address, with TLS when cert files are configured
+// The WebTransport, TCP, UDP, gRPC and admin listeners run next to it, their errors are only logged.
+func (gs *GameServer) Start(cfg Config) error {
+	if cfg.AdminAddr != "" {
+		go func() {
+			if err := gs.StartAdmin(cfg.AdminAddr, cfg.AdminToken); err != nil {
+				log.Printf("Admin server error: %v", err)
+			}
+		}()
+	}
+	if cfg.GRPCAddr != "" {
+		go func() {
+			if err := gs.startGRPC(cfg); err != nil {
+				log.Printf("gRPC server error: %v", err)
+			}
+		}()
+	}
+	if cfg.UDPAddr != "" {
+		go func() {
+			if err := gs.StartUDP(cfg.UDPAddr); err != nil {
+				log.Printf("UDP channel error: %v", err)
+			}
+		}()
+	}
+	if cfg.TCPAddr != "" {
+		go func() {
+			if err := gs.StartTCP(cfg.TCPAddr); err != nil {
+				log.Printf("TCP server error: %v", err)
+			}
+		}()
+	}
+	if cfg.WebTransportAddr != "" {
+		go func() {
+			if err := gs.StartWebTransport(cfg.WebTransportAddr, cfg.TLSCertFile, cfg.TLSKeyFile); err != nil {
+				log.Printf("WebTransport server error: %v", err)
+			}
+		}()
+	}
+	if cfg.CoordinatorAddr != "" {
+		go func() {
+			if err := gs.StartCoordinator(cfg.CoordinatorAddr, CoordinatorConfig{Secret: cfg.CoordinatorSecret}); err != nil {
+				log.Printf("Coordinator error: %v", err)
+			}
+		}()
+	}
+	if cfg.TLS() {
+		return gs.StartServerTLS(cfg.ListenAddr, cfg.TLSCertFile, cfg.TLSKeyFile)
+	}
+	return gs.StartServer(cfg.ListenAddr)
+}
+
+// TickInterval is the time between two room ticks at the configured tick rate
+func (gs *GameServer) TickInterval() time.Duration {
+	return time.Second / time.Duration(gs.tickRate)
+}
+
+func (gs *GameServer) startGRPC(cfg Config) error {
+	if !cfg.TLS() {
+		return gs.StartGRPC(cfg.GRPCAddr)
+	}
+	creds, err := credentials.NewServerTLSFromFile(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return err
+	}
+	return gs.StartGRPC(cfg.GRPCAddr, grpc.Creds(creds))
+}
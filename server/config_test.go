@@ -0,0 +1,25 @@
+package server
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigBanFileSurvivesRestart(t *testing.T) {
+	t.Setenv(EnvBanFile, filepath.Join(t.TempDir(), "bans.json"))
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := NewGameServerFromConfig(cfg)
+	if err := first.BanAccount("cheater", "aimbot", 0); err != nil {
+		t.Fatalf("ban with ban_file set: %v", err)
+	}
+
+	restarted := NewGameServerFromConfig(cfg)
+	if err := restarted.checkBans("cheater", ""); !errors.Is(err, ErrBanned) {
+		t.Fatalf("ban lost across restart: %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Every player carries a context that is cancelled the moment its connection closes:
+// on disconnect, kick, eviction, or when Shutdown unregisters everyone. Handlers, hooks
+// and anything they start (database calls, timers, goroutines) should use it, so work
+// for a player that left stops instead of finishing for nobody.
+//
+// Connections accepted by the server inherit the values of the upgrade request's context,
+// e.g. what an auth middleware put there, but not its cancellation.
+
+// Context is cancelled once the player disconnects
+func (p *Player) Context() context.Context {
+	return p.ctx
+}
+
+// AfterFunc runs fn after d unless the player disconnects first
+// The returned stop works like time.Timer.Stop.
+func (p *Player) AfterFunc(d time.Duration, fn func()) (stop func() bool) {
+	t := time.AfterFunc(d, func() {
+		if p.ctx.Err() == nil {
+			fn()
+		}
+	})
+	unhook := context.AfterFunc(p.ctx, func() { t.Stop() })
+	return func() bool {
+		unhook()
+		return t.Stop()
+	}
+}
+
+// setParentContext derives the player's context from parent, only before it is registered
+func (p *Player) setParentContext(parent context.Context) {
+	p.cancel()
+	p.ctx, p.cancel = context.WithCancel(parent)
+}
+
+// RegisterPlayerContext is RegisterPlayer with a parent for the player's context
+// Cancelling ctx disconnects the player.
+func (gs *GameServer) RegisterPlayerContext(ctx context.Context, conn *websocket.Conn) (*Player, error) {
+	return gs.registerPlayer(ctx, conn, SlotRegular)
+}
+
+func (gs *GameServer) registerPlayer(ctx context.Context, conn *websocket.Conn, class SlotClass) (*Player, error) {
+	player := gs.newWebSocketPlayer(conn, class)
+	player.setParentContext(ctx)
+	if err := gs.checkBans(player.AccountID, connIP(conn)); err != nil {
+		player.cancel()
+		return nil, err
+	}
+	if err := gs.addPlayer(player); err != nil {
+		player.cancel()
+		return nil, err
+	}
+
+	if ctx.Done() != nil {
+		context.AfterFunc(player.ctx, func() {
+			// Only matters when the caller cancelled, a no-op after UnregisterPlayer
+			gs.unregisterPlayer(player)
+		})
+	}
+	return player, nil
+}
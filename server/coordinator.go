@@ -0,0 +1,321 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A coordinator keeps the list of game servers behind one address, for a server browser:
+// every game server with WithRegistration reports its address, region and population to it
+// every few seconds, and clients GET /servers from the coordinator before picking a server
+// to connect to. Servers that stop reporting drop off the list after the TTL, and a server
+// shutting down takes itself off right away.
+//
+//	GET    /servers?region=eu&include_full=true  the list, least loaded first
+//	POST   /servers                              a report, a ServerInfoPayload
+//	DELETE /servers/<id>                         takes a server off
+//
+// Reports and removals need the bearer token when the coordinator has a Secret. Listing is
+// public like the lobby. Any GameServer can run the coordinator with StartCoordinator, or
+// mount Coordinator.Handler next to its own endpoints.
+
+const (
+	defaultCoordinatorTTL       = 30 * time.Second
+	defaultRegistrationInterval = 10 * time.Second
+	// maxServerReport caps the body of a report
+	maxServerReport = 64 << 10
+)
+
+// CoordinatorConfig configures a coordinator, zero values get the defaults
+type CoordinatorConfig struct {
+	// Secret is the bearer token game servers report with, anyone may report when empty
+	Secret string
+	// TTL drops servers that haven't reported for that long, 30 seconds when 0
+	TTL time.Duration
+}
+
+// Coordinator is the registry of game servers, see coordinator.go
+type Coordinator struct {
+	cfg     CoordinatorConfig
+	mu      sync.Mutex
+	servers map[string]ServerInfoPayload
+}
+
+// NewCoordinator returns an empty coordinator
+func NewCoordinator(cfg CoordinatorConfig) *Coordinator {
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultCoordinatorTTL
+	}
+	return &Coordinator{cfg: cfg, servers: make(map[string]ServerInfoPayload)}
+}
+
+// Report adds a server or updates its entry
+func (c *Coordinator) Report(info ServerInfoPayload) error {
+	if err := ValidatePayload(&info); err != nil {
+		return err
+	}
+	info.UpdatedAt = time.Now().UnixMilli()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.servers[info.ID] = info
+	return nil
+}
+
+// Remove takes a server off the list
+func (c *Coordinator) Remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.servers, id)
+}
+
+// Servers lists the servers of a region, of every region when empty, least loaded first.
+// Full servers are left out unless includeFull.
+func (c *Coordinator) Servers(region string, includeFull bool) []ServerInfoPayload {
+	stale := time.Now().Add(-c.cfg.TTL).UnixMilli()
+
+	c.mu.Lock()
+	servers := make([]ServerInfoPayload, 0, len(c.servers))
+	for id, info := range c.servers {
+		switch {
+		case info.UpdatedAt < stale:
+			delete(c.servers, id)
+		case region != "" && info.Region != region:
+		case !includeFull && info.MaxPlayers > 0 && info.Players >= info.MaxPlayers:
+		default:
+			servers = append(servers, info)
+		}
+	}
+	c.mu.Unlock()
+
+	// The ID breaks ties so the order doesn't shuffle between requests
+	sort.Slice(servers, func(i, j int) bool {
+		li, lj := serverLoad(servers[i]), serverLoad(servers[j])
+		if li != lj {
+			return li < lj
+		}
+		return servers[i].ID < servers[j].ID
+	})
+	return servers
+}
+
+// serverLoad is how full a server is, servers without a cap count as empty
+func serverLoad(info ServerInfoPayload) float64 {
+	if info.MaxPlayers <= 0 {
+		return 0
+	}
+	return float64(info.Players) / float64(info.MaxPlayers)
+}
+
+// Handler serves /servers
+func (c *Coordinator) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/servers", c.handleServers)
+	mux.HandleFunc("/servers/", c.handleServers)
+	return mux
+}
+
+func (c *Coordinator) handleServers(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && r.URL.Path == "/servers" {
+		includeFull := false
+		if v := r.URL.Query().Get("include_full"); v != "" {
+			var err error
+			if includeFull, err = strconv.ParseBool(v); err != nil {
+				http.Error(w, "invalid include_full", http.StatusBadRequest)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		list := ServerListPayload{Servers: c.Servers(r.URL.Query().Get("region"), includeFull)}
+		if err := json.NewEncoder(w).Encode(list); err != nil {
+			log.Printf("Error writing server list: %v", err)
+		}
+		return
+	}
+
+	if c.cfg.Secret != "" {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(c.cfg.Secret)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="coordinator"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	switch id, hasID := strings.CutPrefix(r.URL.Path, "/servers/"); {
+	case r.Method == http.MethodPost && r.URL.Path == "/servers":
+		var info ServerInfoPayload
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxServerReport)).Decode(&info); err != nil {
+			http.Error(w, "invalid report: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := c.Report(info); err != nil {
+			http.Error(w, "invalid report: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodDelete && hasID && id != "":
+		c.Remove(id)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// StartCoordinator serves a coordinator on its own address, it is shut down with the
+// server
+func (gs *GameServer) StartCoordinator(addr string, cfg CoordinatorConfig) error {
+	// Kept out of httpServers so /healthz doesn't advertise it
+	srv := &http.Server{Addr: addr, Handler: NewCoordinator(cfg).Handler()}
+	gs.serversMu.Lock()
+	gs.coordinatorServer = srv
+	gs.serversMu.Unlock()
+
+	log.Printf("Coordinator starting on %s", addr)
+	return serveResult(srv.ListenAndServe())
+}
+
+// RegistrationConfig lists the server with a coordinator, zero values get the defaults
+type RegistrationConfig struct {
+	// Coordinator is the base URL of the coordinator, like https://servers.example.com
+	Coordinator string
+	// Address is the URL clients connect to, like wss://eu1.example.com/ws
+	Address string
+	// Name is shown in the server browser
+	Name string
+	// Secret is the coordinator's bearer token
+	Secret string
+	// Interval is how often the server reports, 10 seconds when 0. Keep it well under
+	// the coordinator's TTL.
+	Interval time.Duration
+}
+
+// registration reports the server to the coordinator until deregistered
+type registration struct {
+	cfg    RegistrationConfig
+	client *http.Client
+
+	// mu keeps a report from landing after the deregistration
+	mu      sync.Mutex
+	stopped bool
+	stop    chan struct{}
+}
+
+func newRegistration(cfg RegistrationConfig) *registration {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultRegistrationInterval
+	}
+	if !strings.HasPrefix(cfg.Coordinator, "http://") && !strings.HasPrefix(cfg.Coordinator, "https://") {
+		panic(fmt.Sprintf("coordinator url must be http or https, got %q", cfg.Coordinator))
+	}
+	if cfg.Address == "" {
+		panic("registration needs the address clients connect to")
+	}
+	cfg.Coordinator = strings.TrimSuffix(cfg.Coordinator, "/")
+	return &registration{cfg: cfg, client: &http.Client{Timeout: 5 * time.Second}, stop: make(chan struct{})}
+}
+
+// ServerInfo is what the server reports to its coordinator
+func (gs *GameServer) ServerInfo() ServerInfoPayload {
+	gs.playersMu.RLock()
+	players, maxPlayers := gs.players.len()-gs.spectators, gs.maxPlayers
+	gs.playersMu.RUnlock()
+	gs.roomsMu.RLock()
+	rooms := len(gs.rooms)
+	gs.roomsMu.RUnlock()
+
+	info := ServerInfoPayload{
+		ID:         gs.nodeID,
+		Region:     gs.region,
+		Players:    players,
+		MaxPlayers: maxPlayers,
+		Rooms:      rooms,
+		Modules:    gs.Capabilities().Modules,
+	}
+	if gs.registration != nil {
+		info.Name, info.Address = gs.registration.cfg.Name, gs.registration.cfg.Address
+	}
+	return info
+}
+
+// runRegistration reports to the coordinator every interval until the server shuts down
+func (gs *GameServer) runRegistration() {
+	reg := gs.registration
+	ticker := time.NewTicker(reg.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := reg.report(gs.ServerInfo()); err != nil {
+			log.Printf("Error reporting to coordinator %s: %v", reg.cfg.Coordinator, err)
+		}
+		select {
+		case <-ticker.C:
+		case <-reg.stop:
+			return
+		}
+	}
+}
+
+func (reg *registration) report(info ServerInfoPayload) error {
+	body, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.stopped {
+		return nil
+	}
+	return reg.do(context.Background(), http.MethodPost, "/servers", body)
+}
+
+// deregister stops the reports and takes the server off the coordinator
+func (reg *registration) deregister(ctx context.Context, id string) error {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.stopped {
+		return nil
+	}
+	reg.stopped = true
+	close(reg.stop)
+	return reg.do(ctx, http.MethodDelete, "/servers/"+url.PathEscape(id), nil)
+}
+
+func (reg *registration) do(ctx context.Context, method, path string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, method, reg.cfg.Coordinator+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if reg.cfg.Secret != "" {
+		req.Header.Set("Authorization", "Bearer "+reg.cfg.Secret)
+	}
+
+	resp, err := reg.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrCountdownRunning = errors.New("a countdown is already running")
+
+// countdown is the room's running countdown, guarded by Room.mu
+type countdown struct {
+	label   string
+	startAt time.Time
+}
+
+func (c *countdown) payload(now time.Time) CountdownPayload {
+	remaining := c.startAt.Sub(now)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return CountdownPayload{
+		Label:       c.label,
+		StartAt:     c.startAt.UnixMilli(),
+		ServerTime:  now.UnixMilli(),
+		RemainingMs: remaining.Milliseconds(),
+	}
+}
+
+// StartCountdown tells every member that label starts in d and runs fn at that moment
+// Clients should start at StartAt converted to their clock rather than counting RemainingMs
+// down, so everyone begins together whatever their latency. Players joining while it runs
+// get the countdown too, with the time that is left.
+func (r *Room) StartCountdown(label string, d time.Duration, fn func()) error {
+	r.mu.Lock()
+	if r.countdown != nil {
+		r.mu.Unlock()
+		return ErrCountdownRunning
+	}
+	now := time.Now()
+	c := &countdown{label: label, startAt: now.Add(d)}
+	r.countdown = c
+	r.mu.Unlock()
+
+	err := r.AfterFunc(d, func() {
+		r.mu.Lock()
+		current := r.countdown == c
+		if current {
+			r.countdown = nil
+		}
+		r.mu.Unlock()
+
+		// Cancelled or replaced in the meantime
+		if current && fn != nil {
+			fn()
+		}
+	})
+	if err != nil {
+		r.mu.Lock()
+		r.countdown = nil
+		r.mu.Unlock()
+		return err
+	}
+
+	r.Logf("Countdown %q started, ends in %v", label, d)
+	r.Broadcast(Countdown, c.payload(now))
+	return nil
+}
+
+// CancelCountdown stops the running countdown, its function won't run
+func (r *Room) CancelCountdown() {
+	r.mu.Lock()
+	c := r.countdown
+	r.countdown = nil
+	r.mu.Unlock()
+
+	if c == nil {
+		return
+	}
+
+	r.Logf("Countdown %q cancelled", c.label)
+	payload := c.payload(time.Now())
+	payload.Cancelled = true
+	r.Broadcast(Countdown, payload)
+}
+
+// sendCountdown catches a late joiner up on the running countdown
+func (r *Room) sendCountdown(player *Player) {
+	r.mu.Lock()
+	c := r.countdown
+	r.mu.Unlock()
+
+	if c == nil {
+		return
+	}
+	if err := r.gs.SendCountdown(player.ID, c.payload(time.Now())); err != nil {
+		r.Logf("Error sending countdown to player %s: %v", player.ID, err)
+	}
+}
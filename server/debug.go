@@ -0,0 +1,95 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// How much of a payload is shown in traces
+const traceSummaryLen = 120
+
+// SetDebug turns verbose per-message tracing on or off for a single player
+// Traces cover inbound messages (type, payload summary, handler path, processing time)
+// and outbound frames (size, payload summary, write time), without flooding logs for everyone else.
+func (gs *GameServer) SetDebug(playerID string, enabled bool) error {
+	player, exists := gs.players.get(playerID)
+	if !exists {
+		return fmt.Errorf("player not found")
+	}
+
+	player.debug.Store(enabled)
+	log.Printf("Debug tracing for player %s set to %v", playerID, enabled)
+	return nil
+}
+
+func (p *Player) Debug() bool {
+	return p.debug.Load()
+}
+
+// tracef logs only when debug tracing is on for the player
+func (p *Player) tracef(format string, args ...interface{}) {
+	if !p.debug.Load() {
+		return
+	}
+	log.Printf("[trace %s] %s", p.ID, fmt.Sprintf(format, args...))
+}
+
+// summarize shortens a payload for trace output
+func summarize(data []byte) string {
+	if len(data) <= traceSummaryLen {
+		return strconv.Quote(string(data))
+	}
+	cut := traceSummaryLen
+	// Don't cut a multi-byte character in half
+	for cut > 0 && !utf8.RuneStart(data[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s... (%d bytes)", strconv.Quote(string(data[:cut])), len(data))
+}
+
+// traceInbound times the processing of one inbound message
+func (p *Player) traceInbound(data []byte) func(err error) {
+	if !p.debug.Load() {
+		return func(error) {}
+	}
+
+	start := time.Now()
+	p.tracef("in %d bytes: %s", len(data), summarize(data))
+	return func(err error) {
+		if err != nil {
+			p.tracef("in processed in %v with error: %v", time.Since(start), err)
+			return
+		}
+		p.tracef("in processed in %v", time.Since(start))
+	}
+}
+
+// PlayerDebugHandler is an admin endpoint to toggle tracing for one player, e.g.
+//
+//	POST /dev/players/debug?player=ID&enabled=true
+//
+// It has no authentication, only mount it on a private mux or behind admin auth.
+func (gs *GameServer) PlayerDebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+		if err != nil {
+			http.Error(w, "invalid enabled value", http.StatusBadRequest)
+			return
+		}
+
+		if err := gs.SetDebug(r.URL.Query().Get("player"), enabled); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,74 @@
+package server
+
+import "sync"
+
+// Clients that retry after a timeout put a "dedup" id on the message, apart from the "id"
+// of reliable messages so the two can't be mixed up. The server remembers the
+// last ids of every connection and drops a message whose id it already saw, so a purchase
+// or cast retried because its answer got lost is applied once. Every message with an id
+// is answered with MESSAGE_ACK, duplicates too, so the client can stop retrying.
+// Ids are per connection, a client that reconnects starts with a fresh window.
+
+const defaultDedupWindow = 256
+
+// dedupWindow remembers the last size message ids of a connection
+type dedupWindow struct {
+	mu    sync.Mutex
+	seen  map[uint64]struct{}
+	order []uint64
+	size  int
+}
+
+func newDedupWindow(size int) *dedupWindow {
+	if size <= 0 {
+		return nil
+	}
+	return &dedupWindow{seen: make(map[uint64]struct{}, size), size: size}
+}
+
+func (w *dedupWindow) contains(id uint64) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, ok := w.seen[id]
+	return ok
+}
+
+// add remembers id, false when it was already there
+func (w *dedupWindow) add(id uint64) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.seen[id]; ok {
+		return false
+	}
+	w.seen[id] = struct{}{}
+	w.order = append(w.order, id)
+	if len(w.order) > w.size {
+		delete(w.seen, w.order[0])
+		w.order = w.order[1:]
+	}
+	return true
+}
+
+// isDuplicate reports whether the player already sent a message with this id
+func (p *Player) isDuplicate(id uint64) bool {
+	return p.dedup != nil && id != 0 && p.dedup.contains(id)
+}
+
+// markSeen remembers an admitted message's id, false if a concurrent copy got there first
+func (p *Player) markSeen(id uint64) bool {
+	if p.dedup == nil || id == 0 {
+		return true
+	}
+	return p.dedup.add(id)
+}
+
+// ackClientMessage confirms a message with an id was received
+func (gs *GameServer) ackClientMessage(player *Player, id uint64) {
+	if id == 0 {
+		return
+	}
+	if err := gs.SendMessageAck(player.ID, MessageAckPayload{ID: id}); err != nil {
+		player.tracef("ack of message %d failed: %v", id, err)
+	}
+}
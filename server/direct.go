@@ -0,0 +1,149 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	ErrRecipientOffline = errors.New("recipient is offline")
+	ErrBlocked          = errors.New("blocked by recipient")
+	ErrInvalidMessage   = errors.New("invalid direct message")
+)
+
+// dmFailureReason maps delivery errors to the reason sent with DIRECT_MESSAGE_FAILED
+func dmFailureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrRecipientOffline):
+		return "offline"
+	case errors.Is(err, ErrBlocked):
+		return "blocked"
+	default:
+		return "invalid"
+	}
+}
+
+// offlineMessages holds direct messages for accounts that are not connected
+type offlineMessages struct {
+	mu         sync.Mutex
+	pending    map[string][]DirectMessagePayload
+	maxPerUser int
+}
+
+func newOfflineMessages(maxPerUser int) *offlineMessages {
+	return &offlineMessages{
+		pending:    make(map[string][]DirectMessagePayload),
+		maxPerUser: maxPerUser,
+	}
+}
+
+func (o *offlineMessages) push(msg DirectMessagePayload) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.pending[msg.To]) >= o.maxPerUser {
+		return false
+	}
+	o.pending[msg.To] = append(o.pending[msg.To], msg)
+	return true
+}
+
+func (o *offlineMessages) take(accountID string) []DirectMessagePayload {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	msgs := o.pending[accountID]
+	delete(o.pending, accountID)
+	return msgs
+}
+
+// DeliverDirectMessage delivers a private message from one account to every session of another
+// If the target is offline the message is queued when offline messages are enabled,
+// otherwise it fails with an error describing why.
+func (gs *GameServer) DeliverDirectMessage(fromAccountID, toAccountID, text string) error {
+	if toAccountID == "" || text == "" {
+		return fmt.Errorf("%w: needs a target and text", ErrInvalidMessage)
+	}
+
+	if gs.blockStore != nil {
+		blocked, err := gs.blockStore.IsBlocked(toAccountID, fromAccountID)
+		if err != nil {
+			return fmt.Errorf("failed to check block list: %v", err)
+		}
+		if blocked {
+			return ErrBlocked
+		}
+	}
+
+	msg := DirectMessagePayload{
+		From:   fromAccountID,
+		To:     toAccountID,
+		Text:   text,
+		SentAt: time.Now().Unix(),
+	}
+
+	sessions := gs.accountSessions(toAccountID)
+	if len(sessions) == 0 {
+		if gs.offlineMessages != nil && gs.offlineMessages.push(msg) {
+			return nil
+		}
+		return ErrRecipientOffline
+	}
+
+	for _, session := range sessions {
+		if err := gs.SendDirectMessage(session.ID, msg); err != nil {
+			log.Printf("Error delivering direct message to player %s: %v", session.ID, err)
+		}
+	}
+	return nil
+}
+
+// deliverOfflineMessages flushes queued direct messages once the account connects
+func (gs *GameServer) deliverOfflineMessages(player *Player) {
+	if gs.offlineMessages == nil {
+		return
+	}
+
+	for _, msg := range gs.offlineMessages.take(player.AccountID) {
+		if err := gs.SendDirectMessage(player.ID, msg); err != nil {
+			log.Printf("Error delivering queued direct message to player %s: %v", player.ID, err)
+		}
+	}
+}
+
+// handleDirectMessage routes DIRECT_MESSAGE and the block list messages
+func (gs *GameServer) handleDirectMessage(player *Player, msg StructuredMessage) error {
+	switch msg.Type {
+	case DirectMessage:
+		var payload DirectMessagePayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			gs.SendDirectMessageFailed(player.ID, DirectMessageFailedPayload{Reason: dmFailureReason(ErrInvalidMessage)})
+			return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+		}
+
+		err := gs.DeliverDirectMessage(player.AccountID, payload.To, payload.Text)
+		if err != nil {
+			gs.SendDirectMessageFailed(player.ID, DirectMessageFailedPayload{To: payload.To, Reason: dmFailureReason(err)})
+		}
+		return err
+
+	case BlockAccount, UnblockAccount:
+		if gs.blockStore == nil {
+			return fmt.Errorf("block lists are not enabled")
+		}
+		var payload BlockPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid block payload: %v", err)
+		}
+		if msg.Type == UnblockAccount {
+			return gs.blockStore.Unblock(player.AccountID, payload.AccountID)
+		}
+		return gs.blockStore.Block(player.AccountID, payload.AccountID)
+	}
+
+	return fmt.Errorf("unknown direct message type %s", msg.Type)
+}
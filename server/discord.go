@@ -0,0 +1,367 @@
+package server
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// The Discord bot mirrors game events to a channel and takes admin commands from Discord.
+// It needs no gateway connection: events are posted with the bot token through the REST
+// API, and commands arrive as slash command interactions on POST /discord/interactions,
+// which is the "Interactions Endpoint URL" of the Discord application. Interactions are
+// verified with the application's public key and only run for the configured admins.
+//
+//	/kick player:<player ID> reason:<text>
+//	/announce text:<text>
+//
+// Commands go through the admin API (see admin.go), so they behave exactly like the same
+// call to the admin listener.
+
+const (
+	discordAPI           = "https://discord.com/api/v10"
+	discordQueueSize     = 100
+	discordMaxAttempts   = 3
+	discordMaxBody       = 1 << 16
+	discordMaxMessageLen = 2000
+	discordSigHdr        = "X-Signature-Ed25519"
+	discordTimeHdr       = "X-Signature-Timestamp"
+)
+
+// Interaction and response types of the Discord API
+const (
+	discordPing               = 1
+	discordApplicationCommand = 2
+	discordPong               = 1
+	discordChannelMessage     = 4
+	discordEphemeral          = 1 << 6
+)
+
+// DiscordConfig configures the Discord bot, see discord.go
+type DiscordConfig struct {
+	// BotToken authenticates the bot, needed to post events and register commands
+	BotToken string
+	// ChannelID is where events are posted, nothing is posted when empty
+	ChannelID string
+	// Events are the events to post, the names of the webhook events, all when empty
+	Events []string
+	// ApplicationID registers the slash commands at start when set
+	ApplicationID string
+	// PublicKey is the hex public key of the application, it enables the commands
+	PublicKey string
+	// Admins are the Discord user IDs allowed to run commands
+	Admins []string
+}
+
+func (c DiscordConfig) validate() error {
+	if (c.ChannelID != "" || c.ApplicationID != "") && c.BotToken == "" {
+		return fmt.Errorf("discord channel and application need a bot token")
+	}
+	if c.PublicKey != "" {
+		if key, err := hex.DecodeString(c.PublicKey); err != nil || len(key) != ed25519.PublicKeySize {
+			return fmt.Errorf("discord public key must be %d hex bytes", ed25519.PublicKeySize)
+		}
+	}
+	for _, e := range c.Events {
+		if !webhookEvents[e] {
+			return fmt.Errorf("unknown discord event %q", e)
+		}
+	}
+	return nil
+}
+
+type discordBot struct {
+	cfg       DiscordConfig
+	api       string
+	client    *http.Client
+	publicKey ed25519.PublicKey
+	queue     chan string
+	// lastFull is when server_full was last posted, in unix nanoseconds
+	lastFull atomic.Int64
+}
+
+func newDiscordBot(cfg DiscordConfig) *discordBot {
+	if err := cfg.validate(); err != nil {
+		panic(err.Error())
+	}
+	bot := &discordBot{
+		cfg:    cfg,
+		api:    discordAPI,
+		client: &http.Client{Timeout: defaultWebhookTimeout},
+		queue:  make(chan string, discordQueueSize),
+	}
+	if cfg.PublicKey != "" {
+		bot.publicKey, _ = hex.DecodeString(cfg.PublicKey)
+	}
+	return bot
+}
+
+func (bot *discordBot) wants(event string) bool {
+	return bot.cfg.ChannelID != "" && (len(bot.cfg.Events) == 0 || slices.Contains(bot.cfg.Events, event))
+}
+
+// startDiscord subscribes the bot to the events it posts and starts its worker, which runs
+// for the life of the server
+func (gs *GameServer) startDiscord() {
+	bot := gs.discord
+	if bot.wants(WebhookMatchStarted) {
+		Subscribe(gs.events, func(e MatchStarted) {
+			bot.post(gs.nodeID, fmt.Sprintf("Match started in room %s%s with %d players", e.Room.ID, modeSuffix(e.Room.Mode()), len(e.Players)))
+		})
+	}
+	if bot.wants(WebhookMatchEnded) {
+		Subscribe(gs.events, func(e MatchEnded) {
+			bot.post(gs.nodeID, fmt.Sprintf("Room %s%s closed: %s", e.Result.RoomID, modeSuffix(e.Room.Mode()), e.Result.Reason))
+		})
+	}
+	if bot.wants(WebhookPlayerBanned) {
+		Subscribe(gs.events, func(e PlayerBanned) {
+			until := "permanently"
+			if !e.Ban.ExpiresAt.IsZero() {
+				until = "until " + e.Ban.ExpiresAt.UTC().Format(time.RFC1123)
+			}
+			bot.post(gs.nodeID, fmt.Sprintf("Banned %s %s %s: %s", e.Ban.Kind, e.Ban.Value, until, e.Ban.Reason))
+		})
+	}
+	if bot.wants(WebhookServerFull) {
+		Subscribe(gs.events, func(e ServerFull) {
+			now := time.Now().UnixNano()
+			last := bot.lastFull.Load()
+			if now-last < int64(webhookFullCooldown) || !bot.lastFull.CompareAndSwap(last, now) {
+				return
+			}
+			bot.post(gs.nodeID, "Server is full, players are being turned away")
+		})
+	}
+
+	go bot.run(gs.stop)
+}
+
+func modeSuffix(mode string) string {
+	if mode == "" {
+		return ""
+	}
+	return " (" + mode + ")"
+}
+
+// post queues a message for the channel, without blocking the publisher
+func (bot *discordBot) post(node, text string) {
+	text = fmt.Sprintf("[%s] %s", node, text)
+	if len(text) > discordMaxMessageLen {
+		text = text[:discordMaxMessageLen]
+	}
+	select {
+	case bot.queue <- text:
+	default:
+		log.Printf("Discord queue is full, dropping: %s", text)
+	}
+}
+
+// run registers the commands, then posts the queued messages in order until stop is closed
+func (bot *discordBot) run(stop <-chan struct{}) {
+	if bot.cfg.ApplicationID != "" {
+		if err := bot.registerCommands(); err != nil {
+			log.Printf("Error registering Discord commands: %v", err)
+		}
+	}
+	for {
+		var text string
+		select {
+		case text = <-bot.queue:
+		case <-stop:
+			return
+		}
+		body, _ := json.Marshal(map[string]string{"content": text})
+		if err := bot.call(http.MethodPost, "/channels/"+bot.cfg.ChannelID+"/messages", body); err != nil {
+			log.Printf("Error posting to Discord: %v", err)
+		}
+	}
+}
+
+// discordCommands are registered as the application's slash commands
+var discordCommands = []map[string]interface{}{
+	{
+		"name":        "kick",
+		"description": "Kick a player from the game server",
+		"options": []map[string]interface{}{
+			{"type": 3, "name": "player", "description": "Player ID", "required": true},
+			{"type": 3, "name": "reason", "description": "Shown to the player"},
+		},
+	},
+	{
+		"name":        "announce",
+		"description": "Send an announcement to every player",
+		"options": []map[string]interface{}{
+			{"type": 3, "name": "text", "description": "The announcement", "required": true},
+		},
+	},
+}
+
+func (bot *discordBot) registerCommands() error {
+	body, err := json.Marshal(discordCommands)
+	if err != nil {
+		return err
+	}
+	return bot.call(http.MethodPut, "/applications/"+bot.cfg.ApplicationID+"/commands", body)
+}
+
+// call sends a request to the Discord API, waiting out rate limits
+func (bot *discordBot) call(method, path string, body []byte) error {
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequest(method, bot.api+path, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bot "+bot.cfg.BotToken)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := bot.client.Do(req)
+		if err != nil {
+			return err
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, discordMaxBody))
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode < 300:
+			return nil
+		case resp.StatusCode == http.StatusTooManyRequests && attempt < discordMaxAttempts:
+			var limit struct {
+				RetryAfter float64 `json:"retry_after"`
+			}
+			json.Unmarshal(data, &limit)
+			time.Sleep(time.Duration(max(limit.RetryAfter, 1) * float64(time.Second)))
+		default:
+			return fmt.Errorf("%s %s: status %d: %s", method, path, resp.StatusCode, data)
+		}
+	}
+}
+
+type discordUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+// discordInteraction is the part of an interaction the bot reads
+type discordInteraction struct {
+	Type int `json:"type"`
+	Data struct {
+		Name    string `json:"name"`
+		Options []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"options"`
+	} `json:"data"`
+	// Member is set in guild channels, User in direct messages
+	Member *struct {
+		User discordUser `json:"user"`
+	} `json:"member"`
+	User *discordUser `json:"user"`
+}
+
+// handleDiscordInteraction serves POST /discord/interactions
+func (gs *GameServer) handleDiscordInteraction(w http.ResponseWriter, r *http.Request) {
+	bot := gs.discord
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, discordMaxBody))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	sig, err := hex.DecodeString(r.Header.Get(discordSigHdr))
+	if err != nil || !ed25519.Verify(bot.publicKey, append([]byte(r.Header.Get(discordTimeHdr)), body...), sig) {
+		http.Error(w, "bad signature", http.StatusUnauthorized)
+		return
+	}
+
+	var in discordInteraction
+	if err := json.Unmarshal(body, &in); err != nil {
+		http.Error(w, fmt.Sprintf("invalid interaction: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if in.Type == discordPing {
+		json.NewEncoder(w).Encode(map[string]int{"type": discordPong})
+		return
+	}
+	reply := "Unknown interaction"
+	if in.Type == discordApplicationCommand {
+		reply = gs.runDiscordCommand(in)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"type": discordChannelMessage,
+		"data": map[string]interface{}{"content": reply, "flags": discordEphemeral},
+	})
+}
+
+// runDiscordCommand turns the command into an admin API call and describes the outcome
+func (gs *GameServer) runDiscordCommand(in discordInteraction) string {
+	user := in.User
+	if in.Member != nil {
+		user = &in.Member.User
+	}
+	if user == nil || !slices.Contains(gs.discord.cfg.Admins, user.ID) {
+		return "You are not allowed to run server commands"
+	}
+	opts := make(map[string]string)
+	for _, o := range in.Data.Options {
+		opts[o.Name] = o.Value
+	}
+
+	var path string
+	var req interface{}
+	switch in.Data.Name {
+	case "kick":
+		path, req = "/admin/kick", kickRequest{PlayerID: opts["player"], Reason: opts["reason"]}
+	case "announce":
+		path, req = "/admin/announce", announceRequest{Text: opts["text"]}
+	default:
+		return "Unknown command /" + in.Data.Name
+	}
+	log.Printf("Discord user %s (%s) ran /%s %v", user.Username, user.ID, in.Data.Name, opts)
+
+	status, answer := gs.adminCall(path, req)
+	if status >= 300 {
+		return fmt.Sprintf("/%s failed: %s", in.Data.Name, strings.TrimSpace(answer))
+	}
+	return fmt.Sprintf("/%s done", in.Data.Name)
+}
+
+// adminCall serves a POST to the admin API in process
+func (gs *GameServer) adminCall(path string, req interface{}) (int, string) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return http.StatusInternalServerError, err.Error()
+	}
+	r, err := http.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	if err != nil {
+		return http.StatusInternalServerError, err.Error()
+	}
+	rec := &adminRecorder{header: make(http.Header), status: http.StatusOK}
+	gs.adminHandler().ServeHTTP(rec, r)
+	return rec.status, rec.body.String()
+}
+
+// adminRecorder keeps the answer of an in-process admin call
+type adminRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *adminRecorder) Header() http.Header         { return rec.header }
+func (rec *adminRecorder) Write(b []byte) (int, error) { return rec.body.Write(b) }
+func (rec *adminRecorder) WriteHeader(status int)      { rec.status = status }
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Outgoing messages are built without marshaling twice: the payload is encoded into a
+// pooled buffer and the envelope is appended around it by hand, so a message costs one
+// allocation for its wire bytes. Broadcasts encode the payload once for all recipients.
+// The bytes are the same json.Marshal would produce for the StructuredMessage.
+
+// maxPooledBuffer keeps the odd huge payload from pinning its buffer in the pool
+const maxPooledBuffer = 64 << 10
+
+// envelopeSize is about what the envelope adds to the payload
+const envelopeSize = 160
+
+type encodeBuffer struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+var encodeBuffers = sync.Pool{
+	New: func() interface{} {
+		b := &encodeBuffer{}
+		b.enc = json.NewEncoder(&b.buf)
+		return b
+	},
+}
+
+// encodePayload marshals payload into a pooled buffer
+// The returned bytes are only valid until release.
+func encodePayload(payload interface{}) (json.RawMessage, *encodeBuffer, error) {
+	b := encodeBuffers.Get().(*encodeBuffer)
+	b.buf.Reset()
+	if err := b.enc.Encode(payload); err != nil {
+		b.release()
+		return nil, nil, fmt.Errorf("failed to marshal payload: %v", err)
+	}
+	// Encode ends every value with a newline, Marshal doesn't
+	return bytes.TrimSuffix(b.buf.Bytes(), []byte{'\n'}), b, nil
+}
+
+func (b *encodeBuffer) release() {
+	if b.buf.Cap() > maxPooledBuffer {
+		return
+	}
+	encodeBuffers.Put(b)
+}
+
+// encodeMessage fills in the payload and timestamp of msg and returns the wire bytes
+func encodeMessage(msg StructuredMessage, payload interface{}) ([]byte, error) {
+	raw, b, err := encodePayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	defer b.release()
+	return encodeEnvelope(msg, raw), nil
+}
+
+// encodeRelayed re-encodes a client's message for other players. Only the type and the
+// payload are kept, under the sender's real ID, so nothing else the client put in its
+// envelope reaches them.
+func encodeRelayed(sender *Player, msg StructuredMessage) ([]byte, error) {
+	return encodeMessage(StructuredMessage{Type: msg.Type, PlayerID: sender.ID}, msg.Payload)
+}
+
+// encodeEnvelope wraps an encoded payload, stamping the timestamp and version of msg
+func encodeEnvelope(msg StructuredMessage, raw json.RawMessage) []byte {
+	msg.Payload = raw
+	msg.Timestamp = time.Now().UnixMilli()
+	msg.Version = messageSchemas[msg.Type].Version
+	return appendMessage(make([]byte, 0, len(raw)+envelopeSize), msg)
+}
+
+// appendMessage appends msg in the field order and omitempty rules of its json tags,
+// msg.Payload must be compact JSON
+func appendMessage(dst []byte, msg StructuredMessage) []byte {
+	dst = append(dst, `{"type":`...)
+	dst = appendJSONString(dst, string(msg.Type))
+	dst = append(dst, `,"player_id":`...)
+	dst = appendJSONString(dst, msg.PlayerID)
+	dst = append(dst, `,"payload":`...)
+	if len(msg.Payload) == 0 {
+		dst = append(dst, "null"...)
+	} else {
+		dst = append(dst, msg.Payload...)
+	}
+	dst = append(dst, `,"timestamp":`...)
+	dst = strconv.AppendInt(dst, msg.Timestamp, 10)
+	dst = appendUintField(dst, `,"seq":`, msg.Seq)
+	dst = appendUintField(dst, `,"ack":`, msg.Ack)
+	dst = appendUintField(dst, `,"rtt":`, msg.RTT)
+	dst = appendUintField(dst, `,"v":`, uint64(msg.Version))
+	dst = appendUintField(dst, `,"id":`, msg.ID)
+	dst = appendUintField(dst, `,"dedup":`, msg.DedupID)
+	dst = appendUintField(dst, `,"req":`, msg.Req)
+	dst = appendUintField(dst, `,"re":`, msg.Re)
+	dst = appendUintField(dst, `,"sseq":`, msg.ServerSeq)
+	return append(dst, '}')
+}
+
+func appendUintField(dst []byte, name string, v uint64) []byte {
+	if v == 0 {
+		return dst
+	}
+	return strconv.AppendUint(append(dst, name...), v, 10)
+}
+
+// appendJSONString quotes s like json.Marshal. Types and IDs are plain ASCII, anything
+// needing escapes takes the slow path.
+func appendJSONString(dst []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c >= 0x7f || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' {
+			quoted, _ := json.Marshal(s)
+			return append(dst, quoted...)
+		}
+	}
+	dst = append(dst, '"')
+	dst = append(dst, s...)
+	return append(dst, '"')
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// The Marshal benchmarks encode the way encoding.go replaced, json.Marshal of the payload
+// and then of the whole StructuredMessage, to compare against.
+
+var benchMove = PlayerMovePayload{X: 120.5, Y: -48.25, VX: 3.5, VY: -1}
+
+const benchRecipients = 100
+
+func marshalMessage(msg StructuredMessage, payload interface{}) ([]byte, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	msg.Payload = raw
+	msg.Timestamp = time.Now().UnixMilli()
+	msg.Version = messageSchemas[msg.Type].Version
+	return json.Marshal(msg)
+}
+
+func BenchmarkEncodeMessage(b *testing.B) {
+	msg := StructuredMessage{Type: PlayerMove, PlayerID: "player-1", Seq: 42}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := encodeMessage(msg, benchMove); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalMessage(b *testing.B) {
+	msg := StructuredMessage{Type: PlayerMove, PlayerID: "player-1", Seq: 42}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := marshalMessage(msg, benchMove); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// The fan-out benchmarks build one message per recipient, each with its own ack like
+// deliverTo does
+func BenchmarkEncodeFanOut(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		raw, buf, err := encodePayload(benchMove)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for r := 0; r < benchRecipients; r++ {
+			encodeEnvelope(StructuredMessage{Type: PlayerMove, PlayerID: "player-" + strconv.Itoa(r), Ack: uint64(r)}, raw)
+		}
+		buf.release()
+	}
+}
+
+func BenchmarkMarshalFanOut(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for r := 0; r < benchRecipients; r++ {
+			msg := StructuredMessage{Type: PlayerMove, PlayerID: "player-" + strconv.Itoa(r), Ack: uint64(r)}
+			if _, err := marshalMessage(msg, benchMove); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package server
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// Rooms and parties can encrypt payloads end to end, so private chat or trade details
+// pass proxies, load balancers and the message logs as ciphertext. An encrypted room or
+// party has an AES-256-GCM key: members get it in a PAYLOAD_KEY message when they join,
+// and when a member leaves a new key is made and sent to the ones left, so former members
+// can't read on.
+//
+// A sealed payload is {"kid": 7, "enc": "<base64 of nonce and ciphertext>"} in place of
+// the usual payload. Key IDs are unique in the server, the client looks up the key it got
+// for kid and opens it with "room:<id>" or "party:<id>" as additional data. Clients seal
+// their own payloads the same way, PARTY_CHAT is forwarded untouched so the server never
+// reads those. Server code seals with BroadcastSealed and opens client payloads with
+// OpenPayload.
+//
+// The keys go out over the game connection, this is about everything between the players
+// and the game server, not about the server itself.
+
+var (
+	// ErrNotEncrypted is returned by BroadcastSealed and OpenPayload without encryption
+	ErrNotEncrypted = errors.New("encryption is not enabled")
+	// ErrUnknownKey is a sealed payload whose key is not current anymore
+	ErrUnknownKey = errors.New("unknown payload key")
+)
+
+// payloadKeySeq numbers the keys of all rooms and parties
+var payloadKeySeq atomic.Uint32
+
+type payloadKey struct {
+	id   int
+	raw  []byte
+	aead cipher.AEAD
+}
+
+func newPayloadKey() (*payloadKey, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &payloadKey{id: int(payloadKeySeq.Add(1)), raw: raw, aead: aead}, nil
+}
+
+// keyRing holds the key of a room or party. The previous key still opens payloads for
+// a while after a rotation, they may have been sealed before the new key arrived.
+type keyRing struct {
+	scope, id string
+
+	mu       sync.Mutex
+	current  *payloadKey
+	previous *payloadKey
+}
+
+func newKeyRing(scope, id string) (*keyRing, error) {
+	k := &keyRing{scope: scope, id: id}
+	if err := k.rotate(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (k *keyRing) rotate() error {
+	key, err := newPayloadKey()
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	k.previous, k.current = k.current, key
+	k.mu.Unlock()
+	return nil
+}
+
+// announcement is the PAYLOAD_KEY message of the current key
+func (k *keyRing) announcement() PayloadKeyPayload {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return PayloadKeyPayload{
+		Scope: k.scope,
+		ID:    k.id,
+		KeyID: k.current.id,
+		Key:   base64.StdEncoding.EncodeToString(k.current.raw),
+	}
+}
+
+func (k *keyRing) additionalData() []byte {
+	return []byte(k.scope + ":" + k.id)
+}
+
+func (k *keyRing) seal(plaintext []byte) (SealedPayload, error) {
+	k.mu.Lock()
+	key := k.current
+	k.mu.Unlock()
+
+	nonce := make([]byte, key.aead.NonceSize(), key.aead.NonceSize()+len(plaintext)+key.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return SealedPayload{}, err
+	}
+	sealed := key.aead.Seal(nonce, nonce, plaintext, k.additionalData())
+	return SealedPayload{KeyID: key.id, Data: base64.StdEncoding.EncodeToString(sealed)}, nil
+}
+
+func (k *keyRing) open(raw json.RawMessage) (json.RawMessage, error) {
+	var sealed SealedPayload
+	if err := json.Unmarshal(raw, &sealed); err != nil || sealed.Data == "" {
+		return nil, fmt.Errorf("payload is not sealed")
+	}
+	k.mu.Lock()
+	var key *payloadKey
+	for _, candidate := range []*payloadKey{k.current, k.previous} {
+		if candidate != nil && candidate.id == sealed.KeyID {
+			key = candidate
+		}
+	}
+	k.mu.Unlock()
+	if key == nil {
+		return nil, ErrUnknownKey
+	}
+
+	data, err := base64.StdEncoding.DecodeString(sealed.Data)
+	if err != nil || len(data) < key.aead.NonceSize() {
+		return nil, fmt.Errorf("malformed sealed payload")
+	}
+	nonce, ciphertext := data[:key.aead.NonceSize()], data[key.aead.NonceSize():]
+	plaintext, err := key.aead.Open(nil, nonce, ciphertext, k.additionalData())
+	if err != nil {
+		return nil, fmt.Errorf("sealed payload doesn't open: %v", err)
+	}
+	return plaintext, nil
+}
+
+// sendKey gives the players the current key
+func (gs *GameServer) sendKey(keys *keyRing, players ...*Player) {
+	res := deliverTo(gs, players, PayloadKey, keys.announcement(), "keys")
+	if res.Failed > 0 {
+		log.Printf("Sending the %s %s key failed for %d players: %v", keys.scope, keys.id, res.Failed, res.Errors)
+	}
+}
+
+// rotateKey makes a new key for the remaining members after someone left
+func (gs *GameServer) rotateKey(keys *keyRing, members []*Player) {
+	if err := keys.rotate(); err != nil {
+		log.Printf("Rotating the %s %s key failed: %v", keys.scope, keys.id, err)
+		return
+	}
+	gs.sendKey(keys, members...)
+}
+
+// sealedBroadcast seals the payload once and sends it to the players
+func (gs *GameServer) sealedBroadcast(keys *keyRing, players []*Player, msgType MessageType, payload interface{}) (BroadcastResult, error) {
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		return BroadcastResult{}, err
+	}
+	sealed, err := keys.seal(plaintext)
+	if err != nil {
+		return BroadcastResult{}, err
+	}
+	return deliverTo(gs, players, msgType, sealed, "sealed"), nil
+}
+
+// EnableEncryption makes the room encrypted and sends the key to its members, it does
+// nothing when it already is
+func (r *Room) EnableEncryption() error {
+	if r.keys.Load() != nil {
+		return nil
+	}
+	keys, err := newKeyRing("room", r.ID)
+	if err != nil {
+		return err
+	}
+	if r.keys.CompareAndSwap(nil, keys) {
+		r.gs.sendKey(keys, r.Members()...)
+	}
+	return nil
+}
+
+// Encrypted tells whether the room has payload encryption, see EnableEncryption
+func (r *Room) Encrypted() bool {
+	return r.keys.Load() != nil
+}
+
+// BroadcastSealed sends a message whose payload only the members can read
+func (r *Room) BroadcastSealed(msgType MessageType, payload interface{}) (BroadcastResult, error) {
+	keys := r.keys.Load()
+	if keys == nil {
+		return BroadcastResult{}, ErrNotEncrypted
+	}
+	return r.gs.sealedBroadcast(keys, r.Members(), msgType, payload)
+}
+
+// OpenPayload decrypts a payload a member sealed with the room key
+func (r *Room) OpenPayload(payload json.RawMessage) (json.RawMessage, error) {
+	keys := r.keys.Load()
+	if keys == nil {
+		return nil, ErrNotEncrypted
+	}
+	return keys.open(payload)
+}
+
+// EnableEncryption makes the party encrypted and sends the key to its members, it does
+// nothing when it already is. WithPartyEncryption does it for every party.
+func (p *Party) EnableEncryption() error {
+	if p.keys.Load() != nil {
+		return nil
+	}
+	keys, err := newKeyRing("party", p.ID)
+	if err != nil {
+		return err
+	}
+	if p.keys.CompareAndSwap(nil, keys) {
+		p.gs.sendKey(keys, p.Members()...)
+	}
+	return nil
+}
+
+// Encrypted tells whether the party has payload encryption
+func (p *Party) Encrypted() bool {
+	return p.keys.Load() != nil
+}
+
+// BroadcastSealed sends a message whose payload only the members can read
+func (p *Party) BroadcastSealed(msgType MessageType, payload interface{}) (BroadcastResult, error) {
+	keys := p.keys.Load()
+	if keys == nil {
+		return BroadcastResult{}, ErrNotEncrypted
+	}
+	return p.gs.sealedBroadcast(keys, p.Members(), msgType, payload)
+}
+
+// OpenPayload decrypts a payload a member sealed with the party key
+func (p *Party) OpenPayload(payload json.RawMessage) (json.RawMessage, error) {
+	keys := p.keys.Load()
+	if keys == nil {
+		return nil, ErrNotEncrypted
+	}
+	return keys.open(payload)
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/iknizzz1807/socket-server-template/logic"
+)
+
+var ErrEntityNotFound = errors.New("entity not found")
+
+// EntityStore holds the authoritative state of a room's entities
+// Clients never write to it directly: positions come from validated moves and everything
+// else from game code through Update, so GAME_STATE_SYNC only ever sends state out.
+// It is the room's only entity registry, the entity quota counts what was spawned in it.
+type EntityStore struct {
+	room     *Room
+	mu       sync.RWMutex
+	entities map[string]*EntityState
+	// players are the entities of room members, they don't count against the entity quota
+	players map[string]struct{}
+}
+
+func newEntityStore(room *Room) *EntityStore {
+	return &EntityStore{
+		room:     room,
+		entities: make(map[string]*EntityState),
+		players:  make(map[string]struct{}),
+	}
+}
+
+// Entities returns the room's authoritative entity state
+func (r *Room) Entities() *EntityStore {
+	return r.entityStore
+}
+
+// copyEntity copies the attributes map too, values themselves are shared
+func copyEntity(e *EntityState) EntityState {
+	c := *e
+	if e.Attributes != nil {
+		c.Attributes = make(map[string]interface{}, len(e.Attributes))
+		for k, v := range e.Attributes {
+			c.Attributes[k] = v
+		}
+	}
+	return c
+}
+
+// Get returns a copy of an entity
+func (s *EntityStore) Get(id string) (EntityState, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.entities[id]
+	if !ok {
+		return EntityState{}, false
+	}
+	return copyEntity(e), true
+}
+
+// All returns a copy of every entity
+func (s *EntityStore) All() []EntityState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	all := make([]EntityState, 0, len(s.entities))
+	for _, e := range s.entities {
+		all = append(all, copyEntity(e))
+	}
+	return all
+}
+
+// Spawn adds a server-created entity, counted against the room's entity quota
+// Spawning an ID that exists replaces that entity.
+func (s *EntityStore) Spawn(e EntityState) error {
+	if e.ID == "" {
+		return fmt.Errorf("entity needs an id")
+	}
+	s.room.mu.Lock()
+	closed, maxEntities := s.room.closed, s.room.quota.MaxEntities
+	s.room.mu.Unlock()
+	if closed {
+		return ErrRoomClosed
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, exists := s.entities[e.ID]
+	if !exists && maxEntities > 0 && len(s.entities)-len(s.players) >= maxEntities {
+		return fmt.Errorf("%w: max %d entities", ErrQuotaExceeded, maxEntities)
+	}
+	e.Version = 1
+	s.entities[e.ID] = &e
+	return nil
+}
+
+// Despawn removes an entity
+func (s *EntityStore) Despawn(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entities, id)
+	delete(s.players, id)
+}
+
+// Update runs fn on a copy of the entity and keeps the result unless fn returns an error,
+// so validation and the change happen together
+func (s *EntityStore) Update(id string, fn func(e *EntityState) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.entities[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrEntityNotFound, id)
+	}
+
+	next := copyEntity(current)
+	if err := fn(&next); err != nil {
+		return err
+	}
+	next.ID = id
+	next.Version = current.Version + 1
+	s.entities[id] = &next
+	return nil
+}
+
+// addPlayer gives a joining player its entity, players don't count against the entity quota
+func (s *EntityStore) addPlayer(player *Player) {
+	e := &EntityState{ID: player.ID, Owner: player.ID, Version: 1}
+	if pos, ok := player.Position(); ok {
+		e.X, e.Y = pos.X, pos.Y
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entities[player.ID] = e
+	s.players[player.ID] = struct{}{}
+}
+
+func (s *EntityStore) removePlayer(playerID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entities, playerID)
+	delete(s.players, playerID)
+}
+
+// movePlayer applies a move that passed validation
+func (s *EntityStore) movePlayer(playerID string, pos logic.Vec2) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entities[playerID]; ok {
+		next := *e
+		next.X, next.Y = pos.X, pos.Y
+		next.Version++
+		s.entities[playerID] = &next
+	}
+}
+
+// sendWorldState answers a client's GAME_STATE_SYNC with the authoritative snapshot
+func (gs *GameServer) sendWorldState(player *Player) error {
+	room := player.room.Load()
+	if room == nil {
+		return Reject(ErrorNotInRoom, fmt.Errorf("player %s is not in a room", player.ID))
+	}
+	return gs.SendStructuredMessage(player.ID, GameStateSync, WorldStatePayload{
+		RoomID:   room.ID,
+		Entities: room.Entities().All(),
+	})
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"log"
+	"reflect"
+	"runtime/debug"
+	"sync"
+
+	"github.com/iknizzz1807/socket-server-template/database"
+)
+
+// The event bus lets chat, metrics, persistence, webhooks and game code react to what happens
+// on the server without the connection code knowing about them:
+//
+//	unsubscribe := server.Subscribe(gs.Events(), func(e server.PlayerJoined) {
+//		log.Printf("%s is in", e.Player.ID)
+//	})
+//
+// Handlers run synchronously on the goroutine that published the event, in the order they
+// subscribed. They must not block, hand slow work (HTTP calls, storage) to a goroutine.
+// A panicking handler is logged and skipped, the others still run.
+
+// PlayerJoined is published once a player is visible to others, after the JOIN handshake
+// when it's enabled. Spectators are published too, see Player.IsSpectator.
+type PlayerJoined struct {
+	Player *Player
+}
+
+// PlayerLeft is published after a player that had joined is unregistered
+type PlayerLeft struct {
+	Player *Player
+}
+
+// MessageReceived is published for every structured message that passed admission, before
+// it's routed
+type MessageReceived struct {
+	Player  *Player
+	Message StructuredMessage
+}
+
+// RoomCreated is published for every new room
+type RoomCreated struct {
+	Room *Room
+}
+
+// RoomRemoved is published when a closed room is taken off the server, the counterpart of
+// RoomCreated (RoomClosed is the name of the message players get)
+type RoomRemoved struct {
+	Room   *Room
+	Reason string
+}
+
+// MatchStarted is published when the matchmaker or a tournament put players in a room.
+// Game code starting matches its own way can publish it too.
+type MatchStarted struct {
+	Room    *Room
+	Players []*Player
+}
+
+// MatchEnded is published when a room closes, with what gets saved to the result store
+type MatchEnded struct {
+	Room   *Room
+	Result database.RoomResult
+}
+
+// PlayerBanned is published for every new ban, Kind is database.BanAccount or
+// database.BanIP
+type PlayerBanned struct {
+	Ban database.Ban
+}
+
+// ServerFull is published when a connection finds no free slot, Queued when it waits in
+// the queue instead of being turned away
+type ServerFull struct {
+	Player *Player
+	Queued bool
+}
+
+// MessagePanicked is published when handling a frame from a player panicked. The panic
+// was recovered and the player is being disconnected with ReasonInternalError. Panics on
+// a room goroutine are published too, with Room set, and without a Player when a tick,
+// timer or Do panicked.
+type MessagePanicked struct {
+	Room   *Room
+	Player *Player
+	// Frame is the websocket message type and data of the frame
+	MessageType int
+	Frame       []byte
+	Value       interface{}
+	Stack       []byte
+}
+
+// TransferProgress is published for every chunk of a transfer, see transfers.go. Inbound
+// transfers come from the player, Done is the bytes sent or received so far.
+type TransferProgress struct {
+	Player  *Player
+	ID      string
+	Type    MessageType
+	Inbound bool
+	Done    int
+	Size    int
+}
+
+// TransferFinished is published when a transfer completed, or failed with Err. Inbound
+// transfers are published before their payload is handled.
+type TransferFinished struct {
+	Player  *Player
+	ID      string
+	Type    MessageType
+	Inbound bool
+	Size    int
+	Err     error
+}
+
+// EventBus delivers events by their Go type, see Subscribe and Publish
+type EventBus struct {
+	mu       sync.RWMutex
+	handlers map[reflect.Type][]*eventHandler
+}
+
+type eventHandler struct {
+	fn func(interface{})
+}
+
+func newEventBus() *EventBus {
+	return &EventBus{handlers: make(map[reflect.Type][]*eventHandler)}
+}
+
+// Events returns the server's event bus
+func (gs *GameServer) Events() *EventBus {
+	return gs.events
+}
+
+// Subscribe calls fn with every event of type E until unsubscribe is called
+func Subscribe[E any](bus *EventBus, fn func(E)) (unsubscribe func()) {
+	t := reflect.TypeFor[E]()
+	h := &eventHandler{fn: func(e interface{}) { fn(e.(E)) }}
+
+	bus.mu.Lock()
+	bus.handlers[t] = append(bus.handlers[t], h)
+	bus.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			bus.mu.Lock()
+			defer bus.mu.Unlock()
+			handlers := bus.handlers[t]
+			for i, other := range handlers {
+				if other == h {
+					// Copy so a Publish iterating the old slice isn't affected
+					bus.handlers[t] = append(handlers[:i:i], handlers[i+1:]...)
+					break
+				}
+			}
+		})
+	}
+}
+
+// Publish calls the handlers subscribed to events of type E
+func Publish[E any](bus *EventBus, event E) {
+	bus.mu.RLock()
+	handlers := bus.handlers[reflect.TypeFor[E]()]
+	bus.mu.RUnlock()
+
+	for _, h := range handlers {
+		h.call(event)
+	}
+}
+
+func (h *eventHandler) call(event interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Event handler for %T panicked: %v\n%s", event, r, debug.Stack())
+		}
+	}()
+	h.fn(event)
+}
+
+// playerVisible is called once a joined player got its slot
+func (gs *GameServer) playerVisible(player *Player) {
+	gs.presenceConnected(player)
+	Publish(gs.events, PlayerJoined{Player: player})
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// Broadcasts write to their recipients on a pool of workers shared by the whole server,
+// so one player with a full socket buffer only holds up the worker writing to it, not
+// everyone after it in the list. Each write still has the player's write deadline (see
+// WithWriteTimeout), a stuck player costs a worker at most that long and is then closed.
+//
+// A broadcast returns once all its writes finished, so two broadcasts from the same
+// goroutine reach every player in order. Small broadcasts aren't worth the handoff and
+// are written inline.
+
+// fanOutMin is the fewest recipients handed to the pool
+const fanOutMin = 8
+
+func defaultBroadcastWorkers() int {
+	return 4 * runtime.GOMAXPROCS(0)
+}
+
+type fanOutJob struct {
+	player *Player
+	write  func(*Player) error
+	done   func(*Player, error)
+}
+
+type broadcastPool struct {
+	jobs chan fanOutJob
+}
+
+// newBroadcastPool starts the workers, they run for the life of the server
+func newBroadcastPool(workers int) *broadcastPool {
+	p := &broadcastPool{jobs: make(chan fanOutJob, workers)}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *broadcastPool) work() {
+	for job := range p.jobs {
+		job.done(job.player, job.write(job.player))
+	}
+}
+
+// fanOut calls write for every player and waits for all of them, slice names the failures
+func (gs *GameServer) fanOut(players []*Player, write func(*Player) error, slice string) BroadcastResult {
+	var (
+		mu  sync.Mutex
+		res BroadcastResult
+	)
+	done := func(player *Player, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			if res.Failed == 0 {
+				res.Errors = append(res.Errors, fmt.Errorf("%s, player %s: %v", slice, player.ID, err))
+			}
+			res.Failed++
+			return
+		}
+		res.Sent++
+	}
+
+	if gs.fanout == nil || len(players) < fanOutMin {
+		for _, player := range players {
+			done(player, write(player))
+		}
+		return res
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(players))
+	finished := func(player *Player, err error) {
+		done(player, err)
+		wg.Done()
+	}
+	for _, player := range players {
+		gs.fanout.jobs <- fanOutJob{player: player, write: write, done: finished}
+	}
+	wg.Wait()
+	return res
+}
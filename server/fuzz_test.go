@@ -0,0 +1,36 @@
+package server_test
+
+import (
+	"testing"
+
+	"github.com/iknizzz1807/socket-server-template/messages"
+	"github.com/iknizzz1807/socket-server-template/server"
+	"github.com/iknizzz1807/socket-server-template/server/servertest"
+)
+
+// fuzzOptions turns on the modules with handlers of their own, so their messages get past
+// the not-enabled checks
+func fuzzOptions() []server.Option {
+	return []server.Option{
+		server.WithSpectators(10),
+		server.WithMaxPartySize(4),
+		server.WithDedupWindow(16),
+		server.WithChunkedTransfers(server.ChunkConfig{}),
+		server.WithSignaling(server.SignalingConfig{}),
+		server.WithVoice(server.VoiceConfig{Range: 50}),
+	}
+}
+
+func FuzzMessages(f *testing.F) {
+	f.Add([]byte(`{"type":"ROOM_CREATE","payload":{"room_id":"r","password":"p"},"req":1}`))
+	f.Add([]byte(`{"type":"TRANSFER_START","payload":{"id":1,"type":"CHAT_MESSAGE","size":4096,"chunk_size":1024}}`))
+	f.Add([]byte(`{"type":"RTC_OFFER","payload":{"peer_id":"x","sdp":"v=0"}}`))
+	f.Add([]byte(`{"type":"PARTY_CHAT","player_id":"someone-else","payload":{"text":"hi"},"id":3,"ack":4}`))
+	servertest.FuzzMessages(f, fuzzOptions()...)
+}
+
+func FuzzBinaryFrames(f *testing.F) {
+	voice, _ := messages.EncodeVoice(messages.VoiceFrame{Seq: 1, Volume: 255, Data: []byte{0xf8, 0xff, 0xfe}})
+	f.Add(voice)
+	servertest.FuzzBinaryFrames(f, fuzzOptions()...)
+}
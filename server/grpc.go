@@ -0,0 +1,233 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// The gRPC bridge lets backend services and native clients play over a bidirectional
+// stream, see game.proto. Each stream is a Player like any websocket connection.
+// When the server ends a stream the trailer carries close-code and close-reason.
+
+// frameDescriptor is game.v1.Frame, built here so the bridge needs no generated code
+var frameDescriptor = mustFrameDescriptor()
+
+func mustFrameDescriptor() protoreflect.MessageDescriptor {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Type:     typ.Enum(),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+	}
+	fd := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("game.proto"),
+		Package: proto.String("game.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Frame"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("type", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32),
+				field("data", 2, descriptorpb.FieldDescriptorProto_TYPE_BYTES),
+			},
+		}},
+	}
+	file, err := protodesc.NewFile(fd, nil)
+	if err != nil {
+		panic(fmt.Sprintf("game.proto descriptor: %v", err))
+	}
+	return file.Messages().ByName("Frame")
+}
+
+var gameServiceDesc = grpc.ServiceDesc{
+	ServiceName: "game.v1.Game",
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Connect",
+		Handler:       func(srv interface{}, stream grpc.ServerStream) error { return srv.(*GameServer).handleGRPC(stream) },
+		ServerStreams: true,
+		ClientStreams: true,
+	}},
+	Metadata: "game.proto",
+}
+
+// grpcConn adapts a Connect stream to the transport interface
+type grpcConn struct {
+	stream grpc.ServerStream
+	addr   net.Addr
+	queue  *frameQueue
+	typ    protoreflect.FieldDescriptor
+	data   protoreflect.FieldDescriptor
+
+	closeOnce sync.Once
+	// closed ends the stream handler, code and reason go out in the trailer
+	closed chan struct{}
+	code   int
+	reason string
+}
+
+func newGRPCConn(stream grpc.ServerStream) *grpcConn {
+	fields := frameDescriptor.Fields()
+	c := &grpcConn{
+		stream: stream,
+		addr:   grpcAddr("unknown"),
+		queue:  newFrameQueue(),
+		typ:    fields.ByName("type"),
+		data:   fields.ByName("data"),
+		closed: make(chan struct{}),
+	}
+	if p, ok := peer.FromContext(stream.Context()); ok {
+		c.addr = p.Addr
+	}
+	go c.read()
+	return c
+}
+
+func (c *grpcConn) read() {
+	for {
+		msg := dynamicpb.NewMessage(frameDescriptor)
+		if err := c.stream.RecvMsg(msg); err != nil {
+			c.queue.finish(err)
+			return
+		}
+		messageType := int(msg.Get(c.typ).Int())
+		if !c.queue.push(messageType, msg.Get(c.data).Bytes(), c.closed) {
+			c.queue.finish(net.ErrClosed)
+			return
+		}
+	}
+}
+
+func (c *grpcConn) ReadFrame(deadline time.Time) (int, []byte, error) {
+	return c.queue.read(deadline)
+}
+
+// WriteFrame can't put a deadline on SendMsg, so a missed deadline closes the stream,
+// which unblocks it
+func (c *grpcConn) WriteFrame(messageType int, data []byte, deadline time.Time) error {
+	if !deadline.IsZero() {
+		timer := time.AfterFunc(time.Until(deadline), func() {
+			c.Close(websocket.CloseGoingAway, "write timeout")
+		})
+		defer timer.Stop()
+	}
+	msg := dynamicpb.NewMessage(frameDescriptor)
+	msg.Set(c.typ, protoreflect.ValueOfInt32(int32(messageType)))
+	msg.Set(c.data, protoreflect.ValueOfBytes(data))
+	return c.stream.SendMsg(msg)
+}
+
+func (c *grpcConn) Close(code int, reason string) error {
+	c.closeOnce.Do(func() {
+		c.code, c.reason = code, reason
+		close(c.closed)
+	})
+	return nil
+}
+
+func (c *grpcConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+// grpcAddr stands in when the stream has no peer
+type grpcAddr string
+
+func (a grpcAddr) Network() string { return "grpc" }
+func (a grpcAddr) String() string  { return string(a) }
+
+// wait blocks until the player is done with the stream and reports how it ended
+func (c *grpcConn) wait() error {
+	select {
+	case <-c.closed:
+	case <-c.stream.Context().Done():
+		c.Close(websocket.CloseGoingAway, "")
+		return nil
+	}
+	c.stream.SetTrailer(metadata.Pairs("close-code", strconv.Itoa(c.code), "close-reason", c.reason))
+	if c.code == websocket.CloseNormalClosure {
+		return nil
+	}
+	return status.Error(codes.Aborted, c.reason)
+}
+
+// grpcRequest builds the request the HTTP hooks expect from the stream metadata
+func grpcRequest(stream grpc.ServerStream) (*http.Request, error) {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	u := "grpc://game/ws"
+	if q := md.Get("query"); len(q) > 0 {
+		u += "?" + q[0]
+	}
+	r, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range md {
+		if strings.HasPrefix(k, ":") || k == "query" {
+			continue
+		}
+		for _, v := range vs {
+			r.Header.Add(k, v)
+		}
+	}
+	return r, nil
+}
+
+// StartGRPC serves the game.v1.Game service on addr until Shutdown, pass grpc.Creds for TLS
+func (gs *GameServer) StartGRPC(addr string, opts ...grpc.ServerOption) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := grpc.NewServer(opts...)
+	srv.RegisterService(&gameServiceDesc, gs)
+	gs.serversMu.Lock()
+	gs.grpcServer = srv
+	gs.grpcAddr = ln.Addr().String()
+	gs.serversMu.Unlock()
+
+	log.Printf("gRPC server starting on %s", addr)
+	err = srv.Serve(ln)
+	if errors.Is(err, grpc.ErrServerStopped) {
+		return nil
+	}
+	return err
+}
+
+func (gs *GameServer) handleGRPC(stream grpc.ServerStream) error {
+	r, err := grpcRequest(stream)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	conn := newGRPCConn(stream)
+	r.RemoteAddr = conn.addr.String()
+
+	err = gs.ServeTransport(conn, r)
+	switch {
+	case errors.Is(err, ErrTooManyConnections):
+		return status.Error(codes.ResourceExhausted, "too many connections")
+	case err != nil:
+		return status.Error(codes.PermissionDenied, "banned")
+	}
+	return conn.wait()
+}
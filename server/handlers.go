@@ -0,0 +1,107 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+)
+
+// Typed handlers take the json.Unmarshal out of message handling, the payload is decoded
+// into the handler's type before it runs:
+//
+//	server.RegisterTypedHandler(gs, server.RoomJoin, func(p *server.Player, join server.RoomJoinPayload) error {
+//		...
+//	})
+//
+// The payload is checked against the validate rules of the type too, see validation.go.
+// Payloads that don't decode or break a rule are answered with SCHEMA_ERROR naming the
+// field, and the handler never runs. Typed handlers run after the room handlers and ahead
+// of the built-in routing, like the other handlers they can be changed while the server runs.
+
+// messageHandler handles a message of one type
+type messageHandler func(player *Player, msg StructuredMessage) error
+
+// RegisterTypedHandler routes msgType messages to fn with their payload decoded into T,
+// replacing the handler msgType had. A nil fn removes it. msgType has to be in messages.json
+// like every type clients send. It panics on invalid validate rules in T.
+func RegisterTypedHandler[T any](gs *GameServer, msgType MessageType, fn func(player *Player, payload T) error) {
+	var handler messageHandler
+	if fn != nil {
+		handler = typedHandler(gs, func(player *Player, _ StructuredMessage, payload T) error {
+			return fn(player, payload)
+		})
+	}
+	gs.setTypedHandler(msgType, handler)
+}
+
+// typedHandler decodes and checks the payload into T before calling fn
+func typedHandler[T any](gs *GameServer, fn func(player *Player, msg StructuredMessage, payload T) error) messageHandler {
+	mustParseRules(reflect.TypeFor[T]())
+	return func(player *Player, msg StructuredMessage) error {
+		var payload T
+		if v := decodePayload(msg, &payload); v != nil {
+			gs.rejectSchema(player, msg, v)
+			return v
+		}
+		return fn(player, msg, payload)
+	}
+}
+
+// setTypedHandler replaces the typed handler of msgType, nil removes it
+func (gs *GameServer) setTypedHandler(msgType MessageType, handler messageHandler) {
+	gs.setHooks(func(h *hooks) {
+		typed := maps.Clone(h.typed)
+		if typed == nil {
+			typed = make(map[MessageType]messageHandler)
+		}
+		if handler == nil {
+			delete(typed, msgType)
+		} else {
+			typed[msgType] = handler
+		}
+		h.typed = typed
+	})
+}
+
+// handleTyped runs the typed handler of msg's type, handled is false when it has none
+func (gs *GameServer) handleTyped(player *Player, msg StructuredMessage) (bool, error) {
+	handler := gs.currentHooks().typed[msg.Type]
+	if handler == nil {
+		return false, nil
+	}
+	return true, handler(player, msg)
+}
+
+// decodePayload decodes the payload of msg into v and checks its rules, a missing payload
+// leaves v as it is
+func decodePayload(msg StructuredMessage, v interface{}) *SchemaViolation {
+	s, ok := messageSchemas[msg.Type]
+	if !ok {
+		s = MessageSchema{Type: msg.Type}
+	}
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, v); err != nil {
+			return s.decodeViolation(msg, reflect.TypeOf(v).Elem().String(), err)
+		}
+	}
+	if err := ValidatePayload(v); err != nil {
+		return s.violation(msg, err)
+	}
+	return nil
+}
+
+// decodeViolation is the SchemaViolation for a payload that doesn't decode into typeName,
+// naming the field when the JSON had the wrong type for one
+func (s MessageSchema) decodeViolation(msg StructuredMessage, typeName string, err error) *SchemaViolation {
+	v := &SchemaViolation{Type: msg.Type, Version: msg.Version, Supported: s.Version,
+		Reason: fmt.Sprintf("payload does not match %s: %v", typeName, err)}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		v.Field = typeErr.Field
+		v.Rule = "type=" + typeErr.Type.String()
+		v.Reason = fmt.Sprintf("%s: expected %s, got %s", typeErr.Field, typeErr.Type, typeErr.Value)
+	}
+	return v
+}
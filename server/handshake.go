@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+)
+
+// Clients open with HELLO, declaring their protocol version and the features they want.
+// The server answers WELCOME with what they'll actually use: a client newer than the server
+// is downgraded to ProtocolVersion, one older than MinProtocolVersion is disconnected.
+// Clients that never say HELLO are assumed to speak MinProtocolVersion with no extra features.
+
+// handshake is what a player negotiated, set once by its HELLO
+type handshake struct {
+	version  int
+	features map[string]bool
+	client   string
+}
+
+// protocolState is embedded in Player
+type protocolState struct {
+	negotiated atomic.Pointer[handshake]
+}
+
+// ProtocolVersion is the version negotiated with HELLO, MinProtocolVersion without one
+func (p *Player) ProtocolVersion() int {
+	if h := p.protocol.negotiated.Load(); h != nil {
+		return h.version
+	}
+	return MinProtocolVersion
+}
+
+// HasFeature reports whether the player asked for a feature in HELLO and the server has it
+func (p *Player) HasFeature(name string) bool {
+	h := p.protocol.negotiated.Load()
+	return h != nil && h.features[name]
+}
+
+// supportedFeatures are the codecs and modules of this deployment
+func (gs *GameServer) supportedFeatures() map[string]bool {
+	caps := gs.Capabilities()
+	features := make(map[string]bool, len(caps.Codecs)+len(caps.Modules))
+	for _, c := range caps.Codecs {
+		features[c] = true
+	}
+	for _, m := range caps.Modules {
+		features[m] = true
+	}
+	return features
+}
+
+func (gs *GameServer) handleHello(player *Player, msg StructuredMessage) error {
+	var hello HelloPayload
+	if err := json.Unmarshal(msg.Payload, &hello); err != nil {
+		return fmt.Errorf("invalid hello: %v", err)
+	}
+
+	if hello.ProtocolVersion < MinProtocolVersion {
+		gs.logPlayerf(player, "Player %s (%s) speaks protocol %d, rejecting", player.ID, hello.Client, hello.ProtocolVersion)
+		gs.disconnectWithReason(player, ReasonProtocolError.WithDetail(
+			fmt.Sprintf("protocol version %d is no longer supported, update to at least %d", hello.ProtocolVersion, MinProtocolVersion)))
+		return nil
+	}
+
+	h := &handshake{version: hello.ProtocolVersion, features: make(map[string]bool), client: hello.Client}
+	welcome := WelcomePayload{ProtocolVersion: hello.ProtocolVersion, Features: []string{}}
+	if h.version > ProtocolVersion {
+		h.version = ProtocolVersion
+		welcome.ProtocolVersion = ProtocolVersion
+		welcome.Downgraded = true
+	}
+
+	supported := gs.supportedFeatures()
+	for _, f := range hello.Features {
+		if supported[f] {
+			h.features[f] = true
+			welcome.Features = append(welcome.Features, f)
+		} else {
+			welcome.Unsupported = append(welcome.Unsupported, f)
+		}
+	}
+
+	if !player.protocol.negotiated.CompareAndSwap(nil, h) {
+		return fmt.Errorf("player %s sent HELLO twice", player.ID)
+	}
+	player.tracef("negotiated protocol %d with %s, features %v", h.version, h.client, welcome.Features)
+	return gs.SendWelcome(player.ID, welcome)
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// /healthz is liveness: it fails when the server is wedged, e.g. a deadlock on the player or
+// room registry, even though the listeners still accept connections.
+// /readyz is readiness: it also fails while shutting down, when full without a waiting queue
+// and when a check registered with WithHealthCheck fails (storage, a backplane, ...).
+// Both answer 200 or 503 with a HealthReport.
+
+// healthProbeTimeout bounds every lock probe and health check
+const healthProbeTimeout = 2 * time.Second
+
+// HealthCheck reports whether a dependency is usable, it should return before ctx expires
+type HealthCheck func(ctx context.Context) error
+
+type namedHealthCheck struct {
+	name  string
+	check HealthCheck
+}
+
+// ListenerStatus is a listener the server started
+type ListenerStatus struct {
+	Kind string `json:"kind"`
+	Addr string `json:"addr"`
+}
+
+// HealthReport is the body of /healthz and /readyz
+type HealthReport struct {
+	Status string `json:"status"`
+	// Problems lists why the server isn't healthy or ready, empty when it is
+	Problems      []string          `json:"problems,omitempty"`
+	Checks        map[string]string `json:"checks,omitempty"`
+	Listeners     []ListenerStatus  `json:"listeners"`
+	Players       int               `json:"players"`
+	MaxPlayers    int               `json:"max_players"`
+	Spectators    int               `json:"spectators"`
+	MaxSpectators int               `json:"max_spectators"`
+}
+
+// probeLock reports whether lock can be taken within the timeout
+// A wedged lock leaves the probe's goroutine behind until it's released, there's no way around that.
+func probeLock(lock, unlock func()) bool {
+	done := make(chan struct{})
+	go func() {
+		lock()
+		unlock()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(healthProbeTimeout):
+		return false
+	}
+}
+
+// liveness returns what keeps the server from making progress
+func (gs *GameServer) liveness() []string {
+	var problems []string
+	if !probeLock(gs.playersMu.RLock, gs.playersMu.RUnlock) {
+		problems = append(problems, "player registry is wedged")
+	}
+	if !probeLock(gs.roomsMu.RLock, gs.roomsMu.RUnlock) {
+		problems = append(problems, "room registry is wedged")
+	}
+	return problems
+}
+
+// Listeners returns the listeners started so far, Shutdown clears them
+func (gs *GameServer) Listeners() []ListenerStatus {
+	gs.serversMu.Lock()
+	defer gs.serversMu.Unlock()
+
+	var listeners []ListenerStatus
+	for _, srv := range gs.httpServers {
+		listeners = append(listeners, ListenerStatus{"http", srv.Addr})
+	}
+	if gs.webTransport != nil {
+		listeners = append(listeners, ListenerStatus{"webtransport", gs.webTransport.H3.Addr})
+	}
+	for _, ln := range gs.tcpListeners {
+		listeners = append(listeners, ListenerStatus{"tcp", ln.Addr().String()})
+	}
+	if gs.udp != nil {
+		listeners = append(listeners, ListenerStatus{"udp", gs.udp.conn.LocalAddr().String()})
+	}
+	if gs.grpcServer != nil {
+		listeners = append(listeners, ListenerStatus{"grpc", gs.grpcAddr})
+	}
+	return listeners
+}
+
+// runHealthChecks runs the registered checks side by side, failed ones map to their error
+func (gs *GameServer) runHealthChecks(ctx context.Context) map[string]string {
+	if len(gs.healthChecks) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+
+	results := make(map[string]string, len(gs.healthChecks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range gs.healthChecks {
+		wg.Add(1)
+		go func(c namedHealthCheck) {
+			defer wg.Done()
+			status := "ok"
+			if err := c.check(ctx); err != nil {
+				status = err.Error()
+			}
+			mu.Lock()
+			results[c.name] = status
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+	return results
+}
+
+// Health reports liveness, or readiness when ready is set
+func (gs *GameServer) Health(ctx context.Context, ready bool) HealthReport {
+	report := HealthReport{Listeners: gs.Listeners()}
+	if report.Listeners == nil {
+		report.Listeners = []ListenerStatus{}
+	}
+
+	report.Problems = gs.liveness()
+	if len(report.Problems) == 0 {
+		gs.playersMu.RLock()
+		report.Players = gs.players.len() - gs.spectators
+		report.MaxPlayers = gs.maxPlayers
+		report.Spectators = gs.spectators
+		report.MaxSpectators = gs.maxSpectators
+		gs.playersMu.RUnlock()
+	}
+
+	if ready {
+		if gs.shuttingDown.Load() {
+			report.Problems = append(report.Problems, "shutting down")
+		}
+		if report.MaxPlayers > 0 && report.Players >= report.MaxPlayers && gs.queue == nil {
+			report.Problems = append(report.Problems, "full")
+		}
+		report.Checks = gs.runHealthChecks(ctx)
+		names := make([]string, 0, len(report.Checks))
+		for name, status := range report.Checks {
+			if status != "ok" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			report.Problems = append(report.Problems, name+": "+report.Checks[name])
+		}
+	}
+
+	report.Status = "ok"
+	if len(report.Problems) > 0 {
+		report.Status = "fail"
+	}
+	return report
+}
+
+func (gs *GameServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	gs.writeHealth(w, r, false)
+}
+
+func (gs *GameServer) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	gs.writeHealth(w, r, true)
+}
+
+func (gs *GameServer) writeHealth(w http.ResponseWriter, r *http.Request, ready bool) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	report := gs.Health(r.Context(), ready)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Error writing health report: %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package server
+
+// Hooks let a game add behavior at the connection lifecycle without touching the read loop,
+// e.g. welcome messages, cleanup or analytics. They run on the player's goroutines, so a slow
+// hook slows that player down. Setting a hook again replaces it, nil removes it, and all of
+// them can be changed while the server runs.
+
+// MessageHook sees every structured message that passed admission, before the built-in routing.
+// Returning handled skips the routing, an error rejects the message like a failed handler.
+type MessageHook func(player *Player, msg StructuredMessage) (handled bool, err error)
+
+type hooks struct {
+	onConnect    func(*Player)
+	onDisconnect func(*Player)
+	onMessage    MessageHook
+	// typed are the handlers of RegisterTypedHandler, see handlers.go
+	typed map[MessageType]messageHandler
+}
+
+// OnConnect runs fn once a connection was greeted, capabilities and UDP token included.
+// Players waiting in the queue are greeted once they get a slot, bots are never greeted.
+func (gs *GameServer) OnConnect(fn func(player *Player)) {
+	gs.setHooks(func(h *hooks) { h.onConnect = fn })
+}
+
+// OnDisconnect runs fn after a greeted player is unregistered, so never for a player that
+// OnConnect wasn't due for. player.DisconnectReason tells why it went.
+func (gs *GameServer) OnDisconnect(fn func(player *Player)) {
+	gs.setHooks(func(h *hooks) { h.onDisconnect = fn })
+}
+
+// OnMessage runs fn for structured messages, binary frames don't go through it
+func (gs *GameServer) OnMessage(fn MessageHook) {
+	gs.setHooks(func(h *hooks) { h.onMessage = fn })
+}
+
+// setHooks swaps in a changed copy, so the hot path reads the hooks without locking
+func (gs *GameServer) setHooks(change func(h *hooks)) {
+	gs.hooksMu.Lock()
+	defer gs.hooksMu.Unlock()
+	h := *gs.currentHooks()
+	change(&h)
+	gs.hooks.Store(&h)
+}
+
+// currentHooks never returns nil
+func (gs *GameServer) currentHooks() *hooks {
+	if h := gs.hooks.Load(); h != nil {
+		return h
+	}
+	return &hooks{}
+}
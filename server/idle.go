@@ -0,0 +1,146 @@
+package server
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Players that send nothing for a while are warned with IDLE_WARNING and then disconnected
+// with close code CloseIdle and reason "IDLE", instead of having their read time out silently.
+// The policy is picked per player: spectators use IdleConfig.Spectators, other players the
+// policy of their slot class, else the one of their room (Room.SetIdlePolicy), else Players.
+
+const (
+	defaultIdleCheckInterval = time.Second
+)
+
+// IdlePolicy says when a quiet player is warned and kicked
+type IdlePolicy struct {
+	// Timeout is how long a player may send nothing, 0 never kicks (the read timeout still applies)
+	Timeout time.Duration
+	// Warning is how long before the kick IDLE_WARNING is sent, 0 kicks without warning
+	Warning time.Duration
+}
+
+// IdleConfig sets the idle policies of the server, see WithIdlePolicy
+type IdleConfig struct {
+	// Players is the policy of everyone without an override
+	Players IdlePolicy
+	// Spectators is the policy of spectators, who are usually fine to sit and watch
+	Spectators IdlePolicy
+	// Classes override the policy for slot classes, e.g. moderators who lurk, over room policies too
+	Classes map[SlotClass]IdlePolicy
+	// CheckInterval is how often players are checked, 1s when 0
+	CheckInterval time.Duration
+}
+
+// idleState is embedded in Player, lastActive is the unix nano time of the last message.
+// lastSeen counts passive frames like PONG too, they show the connection is alive but not
+// that the player is.
+type idleState struct {
+	lastActive atomic.Int64
+	lastSeen   atomic.Int64
+	warned     atomic.Bool
+	// passive is set while a passive frame is handled
+	passive atomic.Bool
+}
+
+func (s *idleState) active(now time.Time) {
+	s.lastActive.Store(now.UnixNano())
+	s.lastSeen.Store(now.UnixNano())
+	s.warned.Store(false)
+}
+
+func (s *idleState) seen(now time.Time) {
+	s.lastSeen.Store(now.UnixNano())
+}
+
+// IdleFor is how long the player has sent nothing
+func (p *Player) IdleFor() time.Duration {
+	return time.Since(time.Unix(0, p.idle.lastActive.Load()))
+}
+
+// SetIdlePolicy overrides the idle policy of the room's players, nil goes back to the server's
+func (r *Room) SetIdlePolicy(policy *IdlePolicy) {
+	r.idlePolicy.Store(policy)
+}
+
+// IdlePolicy is the policy the player is currently judged by
+func (gs *GameServer) IdlePolicy(player *Player) IdlePolicy {
+	cfg := gs.idle
+	if cfg == nil {
+		return IdlePolicy{}
+	}
+	if player.IsSpectator() {
+		return cfg.Spectators
+	}
+	if policy, ok := cfg.Classes[player.SlotClass]; ok {
+		return policy
+	}
+	if room := player.room.Load(); room != nil {
+		if policy := room.idlePolicy.Load(); policy != nil {
+			return *policy
+		}
+	}
+	return cfg.Players
+}
+
+// readTimeoutFor is how long the player's read loop waits for a message, longer than
+// the idle policy so the player is warned and kicked before the read times out
+func (gs *GameServer) readTimeoutFor(player *Player) time.Duration {
+	timeout := gs.readTimeout
+	if gs.idle != nil {
+		if policy := gs.IdlePolicy(player); policy.Timeout > 0 && policy.Timeout+2*gs.idle.CheckInterval > timeout {
+			timeout = policy.Timeout + 2*gs.idle.CheckInterval
+		}
+	}
+	return timeout
+}
+
+func (gs *GameServer) readDeadline(player *Player) time.Time {
+	return time.Now().Add(gs.readTimeoutFor(player))
+}
+
+// runIdleChecks warns and kicks idle players until the server shuts down
+func (gs *GameServer) runIdleChecks() {
+	ticker := time.NewTicker(gs.idle.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, player := range gs.snapshotPlayers() {
+				gs.checkIdle(player, now)
+			}
+		case <-gs.stop:
+			return
+		}
+	}
+}
+
+func (gs *GameServer) checkIdle(player *Player, now time.Time) {
+	// Bots are driven by the server, quiet ones are quiet on purpose
+	if player.bot != nil {
+		return
+	}
+	policy := gs.IdlePolicy(player)
+	if policy.Timeout <= 0 {
+		return
+	}
+
+	idle := now.Sub(time.Unix(0, player.idle.lastActive.Load()))
+	if idle >= policy.Timeout {
+		gs.logPlayerf(player, "Player %s was idle for %v, disconnecting", player.ID, idle.Round(time.Second))
+		gs.metrics.idleKicks.add(player.metricShard, 1)
+		gs.disconnectWithReason(player, ReasonIdle)
+		return
+	}
+
+	if policy.Warning > 0 && idle >= policy.Timeout-policy.Warning && !player.idle.warned.Swap(true) {
+		left := policy.Timeout - idle
+		if err := gs.SendIdleWarning(player.ID, IdleWarningPayload{KickInMs: left.Milliseconds()}); err != nil {
+			log.Printf("Error warning idle player %s: %v", player.ID, err)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// ErrPlayerIDTaken is returned when registering a player whose ID is already connected
+var ErrPlayerIDTaken = errors.New("player id is already taken")
+
+// generateUniqueID returns a random UUIDv4. 122 random bits from crypto/rand don't collide
+// between connections registering at the same time, and can't be guessed from another ID.
+func generateUniqueID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// Only happens when the OS has no randomness to give, nothing sensible to fall back to
+		panic(fmt.Sprintf("failed to read random bytes: %v", err))
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
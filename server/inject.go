@@ -0,0 +1,210 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Game backends push events to players without holding a socket by POSTing to /inject:
+//
+//	{"type": "SHOP_REFRESH", "payload": {...}, "player_ids": ["..."]}
+//
+// player_ids, account_ids and room_id pick the recipients (all of them together), without
+// any the message goes to every connected player. The answer is the BroadcastResult.
+//
+// Requests are signed with the shared secret: X-Inject-Signature is the hex HMAC-SHA256 of
+// "<timestamp>\n<nonce>\n<body>", with the unix timestamp and a random nonce in
+// X-Inject-Timestamp and X-Inject-Nonce. Requests older than five minutes are refused and
+// a nonce works once, so a captured request can't be replayed. SignInjection does this
+// for Go backends.
+
+const (
+	injectMaxSkew  = 5 * time.Minute
+	maxInjectBody  = 1 << 20
+	minInjectKey   = 32
+	injectSigHdr   = "X-Inject-Signature"
+	injectTimeHdr  = "X-Inject-Timestamp"
+	injectNonceHdr = "X-Inject-Nonce"
+)
+
+type injector struct {
+	secret []byte
+
+	mu sync.Mutex
+	// seen are the nonces of the last injectMaxSkew, both ways
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+// injectRequest is the body of POST /inject
+type injectRequest struct {
+	Type       MessageType     `json:"type"`
+	Payload    json.RawMessage `json:"payload"`
+	PlayerIDs  []string        `json:"player_ids"`
+	AccountIDs []string        `json:"account_ids"`
+	RoomID     string          `json:"room_id"`
+}
+
+func injectMAC(secret []byte, timestamp, nonce string, body []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(timestamp + "\n" + nonce + "\n"))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// SignInjection sets the signature headers of a request to the inject endpoint, body is
+// the request body
+func SignInjection(req *http.Request, body, secret []byte) error {
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	n := hex.EncodeToString(nonce[:])
+	req.Header.Set(injectTimeHdr, timestamp)
+	req.Header.Set(injectNonceHdr, n)
+	req.Header.Set(injectSigHdr, hex.EncodeToString(injectMAC(secret, timestamp, n, body)))
+	return nil
+}
+
+// verify checks the signature and freshness of a request, and remembers its nonce
+func (in *injector) verify(r *http.Request, body []byte, now time.Time) error {
+	timestamp, nonce := r.Header.Get(injectTimeHdr), r.Header.Get(injectNonceHdr)
+	given, err := hex.DecodeString(r.Header.Get(injectSigHdr))
+	if err != nil || nonce == "" || len(nonce) > 64 || !hmac.Equal(given, injectMAC(in.secret, timestamp, nonce, body)) {
+		return fmt.Errorf("bad signature")
+	}
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("bad timestamp")
+	}
+	if skew := now.Sub(time.Unix(sec, 0)); skew > injectMaxSkew || skew < -injectMaxSkew {
+		return fmt.Errorf("timestamp outside of the %v window", injectMaxSkew)
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if now.Sub(in.lastPrune) > injectMaxSkew {
+		for n, at := range in.seen {
+			if now.Sub(at) > 2*injectMaxSkew {
+				delete(in.seen, n)
+			}
+		}
+		in.lastPrune = now
+	}
+	if _, replayed := in.seen[nonce]; replayed {
+		return fmt.Errorf("nonce already used")
+	}
+	in.seen[nonce] = now
+	return nil
+}
+
+// injectRecipients resolves the targets of a request
+func (gs *GameServer) injectRecipients(req injectRequest) []*Player {
+	if len(req.PlayerIDs) == 0 && len(req.AccountIDs) == 0 && req.RoomID == "" {
+		var all []*Player
+		for _, player := range gs.snapshotPlayers() {
+			if player.Joined() {
+				all = append(all, player)
+			}
+		}
+		return all
+	}
+
+	seen := make(map[*Player]bool)
+	var players []*Player
+	add := func(player *Player) {
+		if !seen[player] {
+			seen[player] = true
+			players = append(players, player)
+		}
+	}
+	for _, id := range req.PlayerIDs {
+		if player, ok := gs.GetPlayer(id); ok {
+			add(player)
+		}
+	}
+	for _, account := range req.AccountIDs {
+		for _, player := range gs.accountSessions(account) {
+			add(player)
+		}
+	}
+	if req.RoomID != "" {
+		if room, ok := gs.GetRoom(req.RoomID); ok {
+			for _, player := range room.Members() {
+				add(player)
+			}
+		}
+	}
+	return players
+}
+
+// InjectHandler is the endpoint backends inject messages through, see WithInjection. The
+// signature is its authentication, so it may be public; Handler and StartServer mount it
+// at /inject when enabled.
+func (gs *GameServer) InjectHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if gs.injector == nil {
+			http.Error(w, "injection is not enabled", http.StatusNotFound)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxInjectBody))
+		if err != nil {
+			http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err := gs.injector.verify(r, body, time.Now()); err != nil {
+			log.Printf("Rejecting injection from %s: %v", r.RemoteAddr, err)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req injectRequest
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid injection: %v", err), http.StatusBadRequest)
+			return
+		}
+		if req.Type == "" {
+			http.Error(w, "type is required", http.StatusBadRequest)
+			return
+		}
+		if schema, ok := messageSchemas[req.Type]; ok && schema.Direction == "client" {
+			http.Error(w, fmt.Sprintf("%s is a client message", req.Type), http.StatusBadRequest)
+			return
+		}
+
+		res := deliverTo(gs, gs.injectRecipients(req), req.Type, req.Payload, "inject")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Sent   int      `json:"sent"`
+			Failed int      `json:"failed"`
+			Errors []string `json:"errors,omitempty"`
+		}{res.Sent, res.Failed, errorStrings(res.Errors)})
+	})
+}
+
+func errorStrings(errs []error) []string {
+	var out []string
+	for _, err := range errs {
+		out = append(out, err.Error())
+	}
+	return out
+}
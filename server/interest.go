@@ -0,0 +1,191 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+
+	"github.com/iknizzz1807/socket-server-template/logic"
+)
+
+// Every room filters entity updates by area of interest: a player's area is a circle of its
+// view radius around its last validated move, in its own room only, and
+// Room.BroadcastEntityUpdate only reaches the players whose area contains the entity.
+
+// Default size of a spatial grid cell, roughly the typical view radius works well
+const defaultInterestCellSize = 100
+
+// maxInterestCells caps a view radius at that many cells, a huge radius would have the
+// grid walk millions of cells on every move
+const maxInterestCells = 32
+
+var ErrInvalidViewRadius = errors.New("invalid view radius")
+
+// interestManager keeps track of what every player is subscribed to (their area of interest)
+// and which players currently see which entity, so updates only go to players that care
+type interestManager struct {
+	mu   sync.Mutex
+	grid *logic.SpatialGrid
+	// viewers maps entity ID -> players that received its last update
+	viewers map[string]map[string]struct{}
+}
+
+func newInterestManager(cellSize float64) *interestManager {
+	return &interestManager{
+		grid:    logic.NewSpatialGrid(cellSize),
+		viewers: make(map[string]map[string]struct{}),
+	}
+}
+
+// set places a player's area, centers off the grid are ignored
+func (im *interestManager) set(playerID string, center logic.Vec2, radius float64) {
+	if !isFinite(center.X) || !isFinite(center.Y) {
+		return
+	}
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	im.grid.Set(playerID, logic.Region{Center: center, Radius: radius})
+}
+
+func (im *interestManager) clear(playerID string) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	im.grid.Remove(playerID)
+	for _, viewers := range im.viewers {
+		delete(viewers, playerID)
+	}
+}
+
+// forget drops an entity, returning the players that saw it
+func (im *interestManager) forget(entityID string) []string {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	var left []string
+	for id := range im.viewers[entityID] {
+		left = append(left, id)
+	}
+	delete(im.viewers, entityID)
+	return left
+}
+
+func isFinite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// checkViewRadius makes sure a radius fits the grid
+func (gs *GameServer) checkViewRadius(radius float64) error {
+	limit := maxInterestCells * gs.interestCellSize
+	if !isFinite(radius) || radius < 0 || radius > limit {
+		return fmt.Errorf("%w: %v, must be between 0 and %v", ErrInvalidViewRadius, radius, limit)
+	}
+	return nil
+}
+
+// SetViewRadius sets how far around its position a player gets entity updates, the
+// interest cell size when 0. It applies from the player's next move.
+func (gs *GameServer) SetViewRadius(player *Player, radius float64) error {
+	if err := gs.checkViewRadius(radius); err != nil {
+		return err
+	}
+	player.viewRadius.Store(math.Float64bits(radius))
+	return nil
+}
+
+func (gs *GameServer) viewRadius(player *Player) float64 {
+	if radius := math.Float64frombits(player.viewRadius.Load()); radius > 0 {
+		return radius
+	}
+	return gs.interestCellSize
+}
+
+// followMove moves the player's area of interest in its room to an accepted position
+func (r *Room) followMove(player *Player, pos logic.Vec2) {
+	r.interest.set(player.ID, pos, r.gs.viewRadius(player))
+}
+
+// SetInterest places the player's area of interest in its room by hand, e.g. for a camera
+// away from its avatar, and keeps radius for its next moves. An invalid radius is logged
+// and ignored, see SetViewRadius.
+func (gs *GameServer) SetInterest(playerID string, center logic.Vec2, radius float64) {
+	player, ok := gs.GetPlayer(playerID)
+	if !ok {
+		return
+	}
+	if err := gs.SetViewRadius(player, radius); err != nil {
+		log.Printf("Ignoring interest of player %s: %v", playerID, err)
+		return
+	}
+	if room := player.room.Load(); room != nil {
+		room.interest.set(playerID, center, radius)
+	}
+}
+
+// ClearInterest stops all entity updates for the player
+func (gs *GameServer) ClearInterest(playerID string) {
+	for _, room := range gs.snapshotRooms() {
+		room.interest.clear(playerID)
+	}
+}
+
+// BroadcastEntityUpdate sends an entity update only to members whose area of interest
+// contains pos. Members that saw the entity before but no longer do get an ENTITY_LEAVE
+// message instead.
+func (r *Room) BroadcastEntityUpdate(entityID string, pos logic.Vec2, msgType MessageType, payload interface{}) {
+	im := r.interest
+	im.mu.Lock()
+	recipients := im.grid.Query(pos)
+
+	current := make(map[string]struct{}, len(recipients))
+	for _, id := range recipients {
+		current[id] = struct{}{}
+	}
+
+	var left []string
+	for id := range im.viewers[entityID] {
+		if _, ok := current[id]; !ok {
+			left = append(left, id)
+		}
+	}
+	im.viewers[entityID] = current
+	im.mu.Unlock()
+
+	for _, id := range recipients {
+		if err := r.gs.SendStructuredMessage(id, msgType, payload); err != nil {
+			log.Printf("Error sending entity %s update to player %s: %v", entityID, id, err)
+		}
+	}
+	r.gs.sendEntityLeave(entityID, left)
+}
+
+// BroadcastEntityUpdate sends an entity update through the interest of the room the
+// entity is in.
+//
+// Deprecated: interest is per room, use Room.BroadcastEntityUpdate.
+func (gs *GameServer) BroadcastEntityUpdate(entityID string, pos logic.Vec2, msgType MessageType, payload interface{}) {
+	for _, room := range gs.snapshotRooms() {
+		if _, ok := room.Entities().Get(entityID); ok {
+			room.BroadcastEntityUpdate(entityID, pos, msgType, payload)
+			return
+		}
+	}
+	log.Printf("Not sending update of entity %s, it is in no room", entityID)
+}
+
+// RemoveEntity tells everyone currently seeing the entity that it is gone
+func (gs *GameServer) RemoveEntity(entityID string) {
+	for _, room := range gs.snapshotRooms() {
+		gs.sendEntityLeave(entityID, room.interest.forget(entityID))
+	}
+}
+
+func (gs *GameServer) sendEntityLeave(entityID string, playerIDs []string) {
+	for _, id := range playerIDs {
+		if err := gs.SendStructuredMessage(id, EntityLeave, EntityLeavePayload{EntityID: entityID}); err != nil {
+			log.Printf("Error sending entity %s leave to player %s: %v", entityID, id, err)
+		}
+	}
+}
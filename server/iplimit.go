@@ -0,0 +1,108 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrTooManyConnections is returned when an IP is at its connection cap, see WithMaxConnectionsPerIP
+var ErrTooManyConnections = errors.New("too many connections from this IP")
+
+// ipLimiter counts open connections per remote IP, so one machine can't take every slot
+// Connections are counted from the upgrade until they close, queued and spectating ones included.
+type ipLimiter struct {
+	mu    sync.Mutex
+	max   int // 0 means no limit
+	conns map[string]int
+}
+
+func newIPLimiter() *ipLimiter {
+	return &ipLimiter{conns: make(map[string]int)}
+}
+
+func (l *ipLimiter) acquire(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.max > 0 && l.conns[ip] >= l.max {
+		return false
+	}
+	l.conns[ip]++
+	return true
+}
+
+func (l *ipLimiter) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conns[ip] <= 1 {
+		delete(l.conns, ip)
+		return
+	}
+	l.conns[ip]--
+}
+
+// SetMaxConnectionsPerIP changes the per-IP cap, 0 removes it
+// Lowering it doesn't drop anyone, it only rejects new connections.
+func (gs *GameServer) SetMaxConnectionsPerIP(n int) {
+	gs.ipLimit.mu.Lock()
+	gs.ipLimit.max = n
+	gs.ipLimit.mu.Unlock()
+}
+
+func (gs *GameServer) maxConnectionsPerIP() int {
+	gs.ipLimit.mu.Lock()
+	defer gs.ipLimit.mu.Unlock()
+	return gs.ipLimit.max
+}
+
+// clientIP is the address connections are counted against
+// Behind a reverse proxy every connection comes from the proxy, use WithClientIPResolver there.
+func (gs *GameServer) clientIP(r *http.Request) string {
+	if gs.ipResolver != nil {
+		return gs.ipResolver(r)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// connIP is the remote address of a connection registered without its HTTP request
+func connIP(conn *websocket.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
+
+// acquireIP is called before upgrading, it answers 429 itself when the IP is over its cap
+func (gs *GameServer) acquireIP(w http.ResponseWriter, r *http.Request) (string, bool) {
+	ip := gs.clientIP(r)
+	if !gs.ipLimit.acquire(ip) {
+		log.Printf("Rejecting connection from %s: too many connections from this IP", ip)
+		http.Error(w, "too many connections", http.StatusTooManyRequests)
+		return "", false
+	}
+	return ip, true
+}
+
+// releaseIP gives the player's connection back to its IP, only the first call counts
+func (gs *GameServer) releaseIP(player *Player) {
+	if player.remoteIP != "" && player.ipReleased.CompareAndSwap(false, true) {
+		gs.ipLimit.release(player.remoteIP)
+	}
+}
+
+// dropConn closes a connection that never made it into the game
+func (gs *GameServer) dropConn(player *Player) {
+	player.closeConn()
+	gs.releaseIP(player)
+}
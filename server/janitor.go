@@ -0,0 +1,52 @@
+package server
+
+import (
+	"log"
+	"time"
+)
+
+// A healthy read loop drops a silent player once its read deadline passes. A player that
+// stays registered well past that has no working read loop: it got stuck in a handler,
+// or the player was registered by code that never started HandlePlayerMessages.
+// The janitor finds those leaked players and unregisters them.
+
+const defaultJanitorInterval = time.Minute
+
+// runJanitor sweeps leaked players every interval until the server shuts down
+func (gs *GameServer) runJanitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			if n := gs.sweepStalePlayers(now, interval); n > 0 {
+				log.Printf("Janitor removed %d leaked players", n)
+			}
+		case <-gs.stop:
+			return
+		}
+	}
+}
+
+// sweepStalePlayers unregisters players silent for longer than their read loop allows,
+// grace covers a loop that is about to notice
+func (gs *GameServer) sweepStalePlayers(now time.Time, grace time.Duration) int {
+	removed := 0
+	for _, player := range gs.snapshotPlayers() {
+		// Bots have no read loop and may stay quiet forever
+		if player.bot != nil {
+			continue
+		}
+		idle := now.Sub(time.Unix(0, player.idle.lastSeen.Load()))
+		if idle <= gs.readTimeoutFor(player)+grace {
+			continue
+		}
+
+		gs.logPlayerf(player, "Player %s silent for %v without its read loop noticing, removing it", player.ID, idle.Round(time.Second))
+		gs.metrics.leakedPlayers.add(player.metricShard, 1)
+		gs.unregisterPlayer(player)
+		removed++
+	}
+	return removed
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// With the join handshake on, a new connection has to send JOIN with its profile before
+// anything else. Until JOIN_ACCEPTED the player holds a slot but is invisible: broadcasts
+// skip it, friends aren't told it's online, it can't be put in a room and every message
+// but JOIN and HELLO is rejected. Connections that don't join in time are closed.
+
+var ErrJoinPending = errors.New("player has not joined yet")
+
+const (
+	defaultJoinTimeout     = 30 * time.Second
+	maxAvatarLength        = 256
+	maxClientVersionLength = 64
+)
+
+// JoinConfig says what a valid JOIN looks like
+type JoinConfig struct {
+	// ValidateName checks the display name, nil allows 3 to 20 printable characters
+	ValidateName func(name string) error
+	// UniqueNames rejects names another connected player uses, ignoring case
+	UniqueNames bool
+	// Avatars lists the allowed avatars, empty allows any up to 256 bytes. Players may leave
+	// their avatar empty either way.
+	Avatars []string
+	// ValidateClient checks the client version, nil accepts any (an empty one included)
+	ValidateClient func(version string) error
+	// Timeout closes connections that haven't joined after this long, 30s when 0
+	Timeout time.Duration
+}
+
+// PlayerProfile is what a player sent in its accepted JOIN
+type PlayerProfile struct {
+	Name          string
+	Avatar        string
+	ClientVersion string
+}
+
+// joinRejection is a JOIN_REJECTED in the making
+type joinRejection struct {
+	field  string
+	reason string
+}
+
+func (e *joinRejection) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.field, e.reason)
+}
+
+type joinHandshake struct {
+	cfg     JoinConfig
+	avatars map[string]bool
+
+	// names maps folded display names to the player using them, with UniqueNames
+	mu    sync.Mutex
+	names map[string]string
+}
+
+func newJoinHandshake(cfg JoinConfig) *joinHandshake {
+	if cfg.ValidateName == nil {
+		cfg.ValidateName = defaultValidateName
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultJoinTimeout
+	}
+	j := &joinHandshake{cfg: cfg, names: make(map[string]string)}
+	if len(cfg.Avatars) > 0 {
+		j.avatars = make(map[string]bool, len(cfg.Avatars))
+		for _, a := range cfg.Avatars {
+			j.avatars[a] = true
+		}
+	}
+	return j
+}
+
+// Profile returns the player's accepted JOIN, false before that
+func (p *Player) Profile() (PlayerProfile, bool) {
+	profile := p.profile.Load()
+	if profile == nil {
+		return PlayerProfile{}, false
+	}
+	return *profile, true
+}
+
+// Joined reports whether the player is visible, always true without the join handshake
+func (p *Player) Joined() bool {
+	return !p.joinPending.Load()
+}
+
+func (j *joinHandshake) validate(join JoinPayload) *joinRejection {
+	if err := j.cfg.ValidateName(join.Name); err != nil {
+		return &joinRejection{field: "name", reason: err.Error()}
+	}
+	if j.avatars != nil && join.Avatar != "" && !j.avatars[join.Avatar] {
+		return &joinRejection{field: "avatar", reason: "unknown avatar"}
+	}
+	if len(join.Avatar) > maxAvatarLength || !utf8.ValidString(join.Avatar) {
+		return &joinRejection{field: "avatar", reason: fmt.Sprintf("must be valid text of at most %d bytes", maxAvatarLength)}
+	}
+	if len(join.ClientVersion) > maxClientVersionLength {
+		return &joinRejection{field: "client_version", reason: fmt.Sprintf("must be at most %d bytes", maxClientVersionLength)}
+	}
+	if j.cfg.ValidateClient != nil {
+		if err := j.cfg.ValidateClient(join.ClientVersion); err != nil {
+			return &joinRejection{field: "client_version", reason: err.Error()}
+		}
+	}
+	return nil
+}
+
+// claimName reserves the name for the player, false when someone else has it
+func (j *joinHandshake) claimName(playerID, name string) bool {
+	if !j.cfg.UniqueNames {
+		return true
+	}
+	key := strings.ToLower(name)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if owner, taken := j.names[key]; taken && owner != playerID {
+		return false
+	}
+	j.names[key] = playerID
+	return true
+}
+
+func (j *joinHandshake) releaseName(player *Player) {
+	profile := player.profile.Load()
+	if !j.cfg.UniqueNames || profile == nil {
+		return
+	}
+	key := strings.ToLower(profile.Name)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.names[key] == player.ID {
+		delete(j.names, key)
+	}
+}
+
+// awaitJoin closes the connection if the player is still pending after the timeout
+func (gs *GameServer) awaitJoin(player *Player) {
+	time.AfterFunc(gs.join.cfg.Timeout, func() {
+		if player.joinPending.Load() && gs.isConnected(player) {
+			gs.logPlayerf(player, "Player %s did not join within %v, disconnecting", player.ID, gs.join.cfg.Timeout)
+			gs.disconnectWithReason(player, ReasonProtocolError.WithDetail("join timeout"))
+		}
+	})
+}
+
+// checkJoinMessage only lets JOIN and the protocol handshake through until the player joined
+func checkJoinMessage(player *Player, msgType MessageType) error {
+	if player.joinPending.Load() && msgType != Join && msgType != Hello && msgType != MessageAck {
+		return fmt.Errorf("player %s can't send %s: %w", player.ID, msgType, ErrJoinPending)
+	}
+	return nil
+}
+
+func (gs *GameServer) handleJoin(player *Player, msg StructuredMessage) error {
+	if gs.join == nil {
+		return fmt.Errorf("join handshake is not enabled")
+	}
+	if !player.joinPending.Load() {
+		return fmt.Errorf("player %s already joined", player.ID)
+	}
+
+	var join JoinPayload
+	if err := json.Unmarshal(msg.Payload, &join); err != nil {
+		return fmt.Errorf("invalid join: %v", err)
+	}
+	join.Name = strings.TrimSpace(join.Name)
+
+	rejection := gs.join.validate(join)
+	if rejection == nil && !gs.join.claimName(player.ID, join.Name) {
+		rejection = &joinRejection{field: "name", reason: "already taken"}
+	}
+	if rejection != nil {
+		// The player stays pending and may try again until the timeout
+		if err := gs.SendJoinRejected(player.ID, JoinRejectedPayload{Field: rejection.field, Reason: rejection.reason}); err != nil {
+			return err
+		}
+		return rejection
+	}
+
+	player.profile.Store(&PlayerProfile{Name: join.Name, Avatar: join.Avatar, ClientVersion: join.ClientVersion})
+	player.joinPending.Store(false)
+	gs.logPlayerf(player, "Player %s joined as %q (client %q)", player.ID, join.Name, join.ClientVersion)
+
+	if err := gs.SendJoinAccepted(player.ID, JoinAcceptedPayload{PlayerID: player.ID, Name: join.Name}); err != nil {
+		return err
+	}
+	// Only now is the player visible
+	gs.playerVisible(player)
+	gs.BroadcastExcept(player.ID, PlayerJoin, PlayerProfilePayload{PlayerID: player.ID, Name: join.Name, Avatar: join.Avatar})
+	gs.startOnboarding(player)
+	return nil
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ErrPlayerNotConnected is returned by KickPlayer for an unknown player ID
+var ErrPlayerNotConnected = errors.New("player not found")
+
+// defaultKickGrace is the grace period of kicks through KickAdminHandler
+const defaultKickGrace = time.Second
+
+// KickPlayer removes a player: it sends KICKED with the reason, waits up to grace for it to
+// be written, then closes the connection with reason KICKED and unregisters the player.
+// It blocks for at most grace plus the close.
+func (gs *GameServer) KickPlayer(playerID, reason string, grace time.Duration) error {
+	player, ok := gs.GetPlayer(playerID)
+	if !ok {
+		return ErrPlayerNotConnected
+	}
+	disconnect := ReasonKicked.WithDetail(reason)
+	player.setDisconnectReason(disconnect)
+	log.Printf("Kicking player %s: %s", player.ID, reason)
+
+	data, err := encodeStructuredMessage(player.ID, Kicked, KickedPayload{Reason: reason})
+	if err != nil {
+		return err
+	}
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		if err := player.writeMessage(Kicked, data); err != nil {
+			gs.logPlayerf(player, "Error sending KICKED to player %s: %v", player.ID, err)
+		}
+	}()
+
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case <-written:
+	case <-timer.C:
+	}
+
+	gs.disconnectWithReason(player, disconnect)
+	return nil
+}
+
+// kickRequest is the body of POST requests to KickAdminHandler
+type kickRequest struct {
+	PlayerID string `json:"player_id"`
+	Reason   string `json:"reason"`
+	// Grace like "2s", one second when empty
+	Grace string `json:"grace"`
+}
+
+// KickAdminHandler is an admin API for kicks:
+//
+//	POST /admin/kick  {"player_id": "...", "reason": "...", "grace": "2s"}
+//
+// It has no authentication, only mount it on a private mux or behind admin auth.
+func (gs *GameServer) KickAdminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req kickRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid kick: %v", err), http.StatusBadRequest)
+			return
+		}
+		grace := defaultKickGrace
+		if req.Grace != "" {
+			var err error
+			if grace, err = time.ParseDuration(req.Grace); err != nil {
+				http.Error(w, fmt.Sprintf("invalid grace: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if err := gs.KickPlayer(req.PlayerID, req.Reason, grace); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrPlayerNotConnected) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"time"
+
+	"github.com/iknizzz1807/socket-server-template/logic"
+)
+
+// Defaults for lag compensation: one second of history at 20 ticks per second
+const (
+	defaultHistorySize = 20
+	defaultMaxRewind   = time.Second
+)
+
+// Every room keeps its own history: a ticking room records the positions of its entities
+// after each tick, so actions are rewound against the world they happened in.
+
+// recordTick stores the entity positions the tick left behind, on the room goroutine
+func (r *Room) recordTick() {
+	s := r.entityStore
+	s.mu.RLock()
+	positions := make(map[string]logic.Vec2, len(s.entities))
+	for id, e := range s.entities {
+		positions[id] = logic.Vec2{X: e.X, Y: e.Y}
+	}
+	s.mu.RUnlock()
+
+	// Real time, not the tick's simulation clock, as RewindTo takes server time
+	r.history.Record(time.Now(), positions)
+}
+
+// RewindTo returns the room's world state at the moment the client performed an action
+// The timestamp is in server time, so convert client timestamps before calling this.
+// Rewinding is capped at maxRewind so laggy (or lying) clients can't shoot into the distant past.
+func (r *Room) RewindTo(t time.Time) (logic.Snapshot, bool) {
+	return rewind(r.history, r.gs.maxRewind, t)
+}
+
+// ResolveAt runs fn against the room's world state at time t, e.g. to check whether a shot hit:
+//
+//	room.ResolveAt(shotTime, func(world logic.Snapshot) {
+//		hits := world.Within(target, hitRadius)
+//	})
+func (r *Room) ResolveAt(t time.Time, fn func(world logic.Snapshot)) bool {
+	snapshot, ok := r.RewindTo(t)
+	fn(snapshot)
+	return ok
+}
+
+func rewind(history *logic.StateHistory, maxRewind time.Duration, t time.Time) (logic.Snapshot, bool) {
+	if oldest := time.Now().Add(-maxRewind); t.Before(oldest) {
+		t = oldest
+	}
+	return history.At(t)
+}
+
+// RecordTick stores positions in the server-wide history read by GameServer.RewindTo
+//
+// Deprecated: rooms record their own history every tick, use Room.RewindTo.
+func (gs *GameServer) RecordTick(positions map[string]logic.Vec2) {
+	gs.history.Record(time.Now(), positions)
+}
+
+// RewindTo returns the server-wide state at time t, only recorded by RecordTick
+//
+// Deprecated: a server with several rooms has several worlds, use Room.RewindTo.
+func (gs *GameServer) RewindTo(t time.Time) (logic.Snapshot, bool) {
+	return rewind(gs.history, gs.maxRewind, t)
+}
+
+// ResolveAt runs fn against the server-wide state at time t
+//
+// Deprecated: use Room.ResolveAt.
+func (gs *GameServer) ResolveAt(t time.Time, fn func(world logic.Snapshot)) bool {
+	snapshot, ok := gs.RewindTo(t)
+	fn(snapshot)
+	return ok
+}
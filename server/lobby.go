@@ -0,0 +1,158 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The lobby browser lists the open rooms players can pick from, through LIST_ROOMS on the
+// socket or GET /rooms before connecting. Rooms are listed unless they were unlisted with
+// SetListed(false), which the rooms of matchmaking and tournaments are, or made private (see
+// private.go). Full rooms are left out unless asked for. Every entry carries ping hints: the
+// region and node hosting it, and the list has an URL of this server to measure the round
+// trip against.
+
+const (
+	defaultRoomListLimit = 20
+	maxRoomListLimit     = 100
+)
+
+// SetName gives the room the name shown in the lobby
+func (r *Room) SetName(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.name = name
+}
+
+func (r *Room) Name() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.name
+}
+
+// SetListed shows or hides the room in the lobby, rooms are listed when created
+func (r *Room) SetListed(listed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.unlisted = !listed
+}
+
+// listing describes the room for the lobby, false when it doesn't belong there
+func (r *Room) listing() (RoomListingPayload, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || r.unlisted || r.privateLocked() || r.playback != nil {
+		return RoomListingPayload{}, false
+	}
+	listing := RoomListingPayload{
+		ID:      r.ID,
+		Name:    r.name,
+		Mode:    r.mode,
+		Map:     r.mapName,
+		Players: len(r.members),
+		Region:  r.gs.region,
+		Node:    r.gs.nodeID,
+	}
+	if r.profile != nil {
+		listing.MaxPlayers = r.profile.MaxPlayers
+	}
+	return listing, true
+}
+
+// ListRooms returns one page of the rooms matching the filter, fullest first
+func (gs *GameServer) ListRooms(filter ListRoomsPayload) RoomListPayload {
+	gs.roomsMu.RLock()
+	rooms := make([]*Room, 0, len(gs.rooms))
+	for _, room := range gs.rooms {
+		rooms = append(rooms, room)
+	}
+	gs.roomsMu.RUnlock()
+
+	name := strings.ToLower(filter.Name)
+	var matches []RoomListingPayload
+	for _, room := range rooms {
+		listing, ok := room.listing()
+		switch {
+		case !ok:
+		case filter.Mode != "" && listing.Mode != filter.Mode:
+		case filter.Map != "" && listing.Map != filter.Map:
+		case name != "" && !strings.Contains(strings.ToLower(listing.Name), name):
+		case !filter.IncludeFull && listing.MaxPlayers > 0 && listing.Players >= listing.MaxPlayers:
+		default:
+			matches = append(matches, listing)
+		}
+	}
+	// The room ID breaks ties so pages don't shuffle between requests
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Players != matches[j].Players {
+			return matches[i].Players > matches[j].Players
+		}
+		return matches[i].ID < matches[j].ID
+	})
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultRoomListLimit
+	}
+	if limit > maxRoomListLimit {
+		limit = maxRoomListLimit
+	}
+	offset := filter.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	list := RoomListPayload{Rooms: []RoomListingPayload{}, Total: len(matches), Offset: offset, PingURL: gs.pingURL}
+	if offset < len(matches) {
+		list.Rooms = matches[offset:min(offset+limit, len(matches))]
+	}
+	return list
+}
+
+// handleListRooms answers LIST_ROOMS
+func (gs *GameServer) handleListRooms(player *Player, msg StructuredMessage) error {
+	var filter ListRoomsPayload
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &filter); err != nil {
+			return fmt.Errorf("invalid room list request: %v", err)
+		}
+	}
+	return gs.SendRoomList(player.ID, gs.ListRooms(filter))
+}
+
+// handleRooms serves GET /rooms with the LIST_ROOMS filter as query parameters, e.g.
+// /rooms?mode=deathmatch&offset=20. Lobbies are public, so it needs no auth.
+func (gs *GameServer) handleRooms(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := ListRoomsPayload{Mode: q.Get("mode"), Map: q.Get("map"), Name: q.Get("name")}
+	var err error
+	if v := q.Get("include_full"); v != "" {
+		if filter.IncludeFull, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "invalid include_full", http.StatusBadRequest)
+			return
+		}
+	}
+	for param, dst := range map[string]*int{"offset": &filter.Offset, "limit": &filter.Limit} {
+		if v := q.Get(param); v != "" {
+			if *dst, err = strconv.Atoi(v); err != nil {
+				http.Error(w, "invalid "+param, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if err := json.NewEncoder(w).Encode(gs.ListRooms(filter)); err != nil {
+		log.Printf("Error writing room list: %v", err)
+	}
+}
@@ -0,0 +1,473 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Players log in through an identity provider in the browser, then connect with a short
+// lived token the server minted for them:
+//
+//	GET /auth/<provider>/login     redirects to the provider
+//	GET /auth/<provider>/callback  where the provider sends the browser back
+//
+// The callback mints a token for the account "<provider>:<user id>" and either redirects
+// to LoginConfig.RedirectURL with #token=...&account_id=... or answers it as JSON. The
+// client passes it on the upgrade as ?token=... (browsers can't set headers on websockets)
+// or as Authorization: Bearer. Tokens only admit new connections, a connection outlives
+// its token. They are signed with the shared secret, so any server of a deployment takes
+// the tokens of the others.
+//
+// MintLoginToken gives games with their own login the same tokens.
+
+var (
+	ErrLoginRequired = errors.New("a valid login token is required")
+	ErrInvalidToken  = errors.New("invalid or expired login token")
+)
+
+const (
+	defaultTokenTTL = 5 * time.Minute
+	// loginStateTTL is how long a login may take at the provider
+	loginStateTTL    = 10 * time.Minute
+	loginCookie      = "login_state"
+	minLoginSecret   = 32
+	loginHTTPTimeout = 10 * time.Second
+)
+
+// loginClient talks to the providers
+var loginClient = &http.Client{Timeout: loginHTTPTimeout}
+
+// LoginProvider is an identity provider players can log in with
+type LoginProvider interface {
+	// LoginURL is where the browser logs in, it has to come back to callback with state
+	// in the query
+	LoginURL(callback, state string) string
+	// UserID checks the request to callback and returns the user's ID at the provider
+	UserID(ctx context.Context, r *http.Request, callback string) (string, error)
+}
+
+// LoginConfig sets up the login endpoints and token checks, see WithLogin
+type LoginConfig struct {
+	// BaseURL is the public address of the server, e.g. https://play.example.com. Register
+	// BaseURL + /auth/<provider>/callback as the redirect URL with each provider.
+	BaseURL string
+	// Secret signs tokens, at least 32 random bytes shared by all servers of a deployment
+	Secret []byte
+	// TokenTTL is how long a token admits new connections, 5 minutes when 0
+	TokenTTL time.Duration
+	// Providers by name, e.g. "google": GoogleLogin(id, secret)
+	Providers map[string]LoginProvider
+	// RedirectURL is the game page the browser goes back to with the token in the
+	// fragment. Without it the callback answers JSON.
+	RedirectURL string
+	// Required refuses connections without a valid token, otherwise they stay anonymous
+	Required bool
+}
+
+type login struct {
+	cfg LoginConfig
+}
+
+// loginClaims is the signed part of tokens and of the OAuth state
+type loginClaims struct {
+	Subject  string `json:"sub,omitempty"`
+	Provider string `json:"p,omitempty"`
+	Nonce    string `json:"n,omitempty"`
+	Expires  int64  `json:"exp"`
+}
+
+// sign encodes the claims as base64(json).base64(hmac)
+func (l *login) sign(c loginClaims) string {
+	body, _ := json.Marshal(c)
+	payload := base64.RawURLEncoding.EncodeToString(body)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(l.mac(payload))
+}
+
+func (l *login) mac(payload string) []byte {
+	h := hmac.New(sha256.New, l.cfg.Secret)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+// verify returns the claims of a signed string that hasn't expired
+func (l *login) verify(signed string) (loginClaims, error) {
+	var c loginClaims
+	payload, sig, ok := strings.Cut(signed, ".")
+	if !ok {
+		return c, ErrInvalidToken
+	}
+	given, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(given, l.mac(payload)) {
+		return c, ErrInvalidToken
+	}
+	body, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || json.Unmarshal(body, &c) != nil {
+		return c, ErrInvalidToken
+	}
+	if time.Now().Unix() >= c.Expires {
+		return c, ErrInvalidToken
+	}
+	return c, nil
+}
+
+// account checks the token of an upgrade request
+func (l *login) account(r *http.Request) (string, error) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		token, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	if token == "" {
+		return "", ErrLoginRequired
+	}
+	c, err := l.verify(token)
+	if err != nil || c.Subject == "" {
+		return "", ErrInvalidToken
+	}
+	return c.Subject, nil
+}
+
+// MintLoginToken signs a token that admits connections of accountID until it expires
+func (gs *GameServer) MintLoginToken(accountID string) (string, time.Time, error) {
+	if gs.login == nil {
+		return "", time.Time{}, fmt.Errorf("login is not enabled")
+	}
+	expires := time.Now().Add(gs.login.cfg.TokenTTL)
+	return gs.login.sign(loginClaims{Subject: accountID, Expires: expires.Unix()}), expires, nil
+}
+
+// resolveAccount is the account of an upgrade request: its login token, else what the
+// AccountResolver says. false leaves the connection its own anonymous account.
+func (gs *GameServer) resolveAccount(r *http.Request) (string, bool) {
+	if gs.login != nil {
+		if account, err := gs.login.account(r); err == nil {
+			return account, true
+		}
+	}
+	if gs.accountResolver != nil {
+		return gs.accountResolver(r), true
+	}
+	return "", false
+}
+
+// checkLogin refuses requests without a valid token when login is required
+func (gs *GameServer) checkLogin(r *http.Request) error {
+	if gs.login == nil || !gs.login.cfg.Required {
+		return nil
+	}
+	_, err := gs.login.account(r)
+	return err
+}
+
+// checkLoginBeforeUpgrade answers 401 to upgrades checkLogin refuses
+func (gs *GameServer) checkLoginBeforeUpgrade(w http.ResponseWriter, r *http.Request) bool {
+	if err := gs.checkLogin(r); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// LoginHandler serves the login endpoints under /auth/, Handler and StartServer mount it
+// when login is enabled
+func (gs *GameServer) LoginHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /auth/{provider}/login", gs.handleLogin)
+	mux.HandleFunc("GET /auth/{provider}/callback", gs.handleLoginCallback)
+	return mux
+}
+
+func (gs *GameServer) loginProvider(w http.ResponseWriter, r *http.Request) (string, LoginProvider, bool) {
+	if gs.login == nil {
+		http.Error(w, "login is not enabled", http.StatusNotFound)
+		return "", nil, false
+	}
+	name := r.PathValue("provider")
+	provider, ok := gs.login.cfg.Providers[name]
+	if !ok {
+		http.Error(w, "unknown login provider", http.StatusNotFound)
+		return "", nil, false
+	}
+	return name, provider, true
+}
+
+func (l *login) callbackURL(provider string) string {
+	return strings.TrimSuffix(l.cfg.BaseURL, "/") + "/auth/" + provider + "/callback"
+}
+
+func (gs *GameServer) handleLogin(w http.ResponseWriter, r *http.Request) {
+	name, provider, ok := gs.loginProvider(w, r)
+	if !ok {
+		return
+	}
+
+	// The state is signed and tied to this browser by the cookie
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	state := gs.login.sign(loginClaims{
+		Provider: name,
+		Nonce:    hex.EncodeToString(nonce[:]),
+		Expires:  time.Now().Add(loginStateTTL).Unix(),
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginCookie,
+		Value:    hex.EncodeToString(nonce[:]),
+		Path:     "/auth/",
+		MaxAge:   int(loginStateTTL / time.Second),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(gs.login.cfg.BaseURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, provider.LoginURL(gs.login.callbackURL(name), state), http.StatusFound)
+}
+
+func (gs *GameServer) handleLoginCallback(w http.ResponseWriter, r *http.Request) {
+	name, provider, ok := gs.loginProvider(w, r)
+	if !ok {
+		return
+	}
+
+	state, err := gs.login.verify(r.URL.Query().Get("state"))
+	cookie, cookieErr := r.Cookie(loginCookie)
+	if err != nil || cookieErr != nil || state.Provider != name ||
+		subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state.Nonce)) != 1 {
+		http.Error(w, "invalid login state, start over", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: loginCookie, Path: "/auth/", MaxAge: -1})
+
+	userID, err := provider.UserID(r.Context(), r, gs.login.callbackURL(name))
+	if err != nil {
+		log.Printf("Login with %s failed: %v", name, err)
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+
+	account := name + ":" + userID
+	token, expires, err := gs.MintLoginToken(account)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Account %s logged in", account)
+
+	if redirect := gs.login.cfg.RedirectURL; redirect != "" {
+		// The fragment never reaches a server log
+		fragment := url.Values{"token": {token}, "account_id": {account}}
+		http.Redirect(w, r, redirect+"#"+fragment.Encode(), http.StatusFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":      token,
+		"account_id": account,
+		"expires_at": expires.Unix(),
+	})
+}
+
+// OAuth2Provider logs in with the authorization code flow of an OAuth2 or OpenID Connect
+// provider, the user ID comes from its userinfo endpoint
+type OAuth2Provider struct {
+	ClientID     string
+	ClientSecret string
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+	Scopes       []string
+	// IDField is the userinfo field holding the user ID, "sub" (OpenID Connect) when empty
+	IDField string
+}
+
+// GoogleLogin logs in with a Google account
+func GoogleLogin(clientID, clientSecret string) *OAuth2Provider {
+	return &OAuth2Provider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		AuthURL:      "https://accounts.google.com/o/oauth2/v2/auth",
+		TokenURL:     "https://oauth2.googleapis.com/token",
+		UserInfoURL:  "https://openidconnect.googleapis.com/v1/userinfo",
+		Scopes:       []string{"openid"},
+	}
+}
+
+// DiscordLogin logs in with a Discord account
+func DiscordLogin(clientID, clientSecret string) *OAuth2Provider {
+	return &OAuth2Provider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		AuthURL:      "https://discord.com/oauth2/authorize",
+		TokenURL:     "https://discord.com/api/oauth2/token",
+		UserInfoURL:  "https://discord.com/api/users/@me",
+		Scopes:       []string{"identify"},
+		IDField:      "id",
+	}
+}
+
+func (p *OAuth2Provider) LoginURL(callback, state string) string {
+	q := url.Values{
+		"response_type": {"code"},
+		"client_id":     {p.ClientID},
+		"redirect_uri":  {callback},
+		"scope":         {strings.Join(p.Scopes, " ")},
+		"state":         {state},
+	}
+	return p.AuthURL + "?" + q.Encode()
+}
+
+func (p *OAuth2Provider) UserID(ctx context.Context, r *http.Request, callback string) (string, error) {
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		return "", fmt.Errorf("provider refused: %s", e)
+	}
+
+	// Exchange the code for an access token
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {q.Get("code")},
+		"redirect_uri":  {callback},
+		"client_id":     {p.ClientID},
+		"client_secret": {p.ClientSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var tok struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := fetchJSON(req, &tok); err != nil {
+		return "", fmt.Errorf("token exchange: %v", err)
+	}
+	if tok.AccessToken == "" {
+		return "", fmt.Errorf("token exchange: no access token")
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, p.UserInfoURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+	var info map[string]json.RawMessage
+	if err := fetchJSON(req, &info); err != nil {
+		return "", fmt.Errorf("userinfo: %v", err)
+	}
+
+	field := p.IDField
+	if field == "" {
+		field = "sub"
+	}
+	// IDs are strings or numbers depending on the provider
+	var id interface{}
+	dec := json.NewDecoder(strings.NewReader(string(info[field])))
+	dec.UseNumber()
+	if err := dec.Decode(&id); err != nil || id == nil || fmt.Sprint(id) == "" {
+		return "", fmt.Errorf("userinfo has no %s", field)
+	}
+	return fmt.Sprint(id), nil
+}
+
+// fetchJSON runs req and decodes a 200 answer into v
+func fetchJSON(req *http.Request, v interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := loginClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, body)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// steamOpenID is Steam's OpenID 2.0 endpoint, Steam has no OAuth2 login for games
+const steamOpenID = "https://steamcommunity.com/openid/login"
+
+var steamClaimedID = regexp.MustCompile(`^https://steamcommunity\.com/openid/id/([0-9]{1,20})$`)
+
+type steamProvider struct {
+	endpoint string
+}
+
+// SteamLogin logs in with a Steam account, the user ID is the SteamID64
+func SteamLogin() LoginProvider {
+	return &steamProvider{endpoint: steamOpenID}
+}
+
+func (p *steamProvider) LoginURL(callback, state string) string {
+	returnTo := callback + "?" + url.Values{"state": {state}}.Encode()
+	realm := callback
+	if u, err := url.Parse(callback); err == nil {
+		realm = u.Scheme + "://" + u.Host
+	}
+	q := url.Values{
+		"openid.ns":         {"http://specs.openid.net/auth/2.0"},
+		"openid.mode":       {"checkid_setup"},
+		"openid.return_to":  {returnTo},
+		"openid.realm":      {realm},
+		"openid.identity":   {"http://specs.openid.net/auth/2.0/identifier_select"},
+		"openid.claimed_id": {"http://specs.openid.net/auth/2.0/identifier_select"},
+	}
+	return p.endpoint + "?" + q.Encode()
+}
+
+func (p *steamProvider) UserID(ctx context.Context, r *http.Request, callback string) (string, error) {
+	q := r.URL.Query()
+	if q.Get("openid.mode") != "id_res" {
+		return "", fmt.Errorf("login cancelled")
+	}
+	if !strings.HasPrefix(q.Get("openid.return_to"), callback+"?") || q.Get("openid.op_endpoint") != p.endpoint {
+		return "", fmt.Errorf("assertion is not for this server")
+	}
+	m := steamClaimedID.FindStringSubmatch(q.Get("openid.claimed_id"))
+	if m == nil {
+		return "", fmt.Errorf("unexpected claimed id %q", q.Get("openid.claimed_id"))
+	}
+
+	// Only Steam can tell whether the signed assertion is genuine
+	check := url.Values{}
+	for key, values := range q {
+		if strings.HasPrefix(key, "openid.") {
+			check[key] = values
+		}
+	}
+	check.Set("openid.mode", "check_authentication")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, strings.NewReader(check.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := loginClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "is_valid:true") {
+		return "", fmt.Errorf("steam did not confirm the login")
+	}
+	return m[1], nil
+}
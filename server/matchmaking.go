@@ -0,0 +1,350 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Players look for a match with MATCHMAKING_JOIN. A party leader queues the whole party as
+// one ticket, members can't queue on their own. Every interval the strategy picks groups
+// from the waiting tickets of each mode, the matchmaker creates a room for each group,
+// moves the players in and sends them MATCH_FOUND. The default strategy matches players
+// of similar rating and accepts wider gaps the longer a ticket waits.
+
+var (
+	ErrMatchmakingNotEnabled = errors.New("matchmaking is not enabled")
+	ErrNotPartyLeader        = errors.New("only the party leader can queue the party")
+)
+
+const defaultMatchmakingInterval = time.Second
+
+// MatchTicket is a player or party waiting for a match
+type MatchTicket struct {
+	Players  []*Player
+	Mode     string
+	Rating   float64
+	QueuedAt time.Time
+}
+
+// MatchmakingStrategy groups waiting tickets into matches
+type MatchmakingStrategy interface {
+	// Match returns the groups to start now from the tickets of one mode, oldest ticket
+	// first. A ticket may be in one group at most, the others keep waiting.
+	Match(tickets []*MatchTicket, now time.Time) [][]*MatchTicket
+}
+
+// RatingBandStrategy makes matches of MatchSize players whose ratings are within a band
+// of the oldest ticket's. The band starts at Band and widens by WidenPerSecond while the
+// ticket waits, up to MaxBand.
+type RatingBandStrategy struct {
+	// MatchSize is the number of players in a match, 2 when 0
+	MatchSize int
+	// Band is the allowed rating difference of a new ticket, 100 when 0
+	Band float64
+	// WidenPerSecond grows the band per second of waiting, 10 when 0
+	WidenPerSecond float64
+	// MaxBand caps the band, 0 lets it grow without limit
+	MaxBand float64
+}
+
+func (s RatingBandStrategy) band(ticket *MatchTicket, now time.Time) float64 {
+	band, widen := s.Band, s.WidenPerSecond
+	if band == 0 {
+		band = 100
+	}
+	if widen == 0 {
+		widen = 10
+	}
+	band += widen * now.Sub(ticket.QueuedAt).Seconds()
+	if s.MaxBand > 0 && band > s.MaxBand {
+		band = s.MaxBand
+	}
+	return band
+}
+
+func (s RatingBandStrategy) Match(tickets []*MatchTicket, now time.Time) [][]*MatchTicket {
+	size := s.MatchSize
+	if size == 0 {
+		size = 2
+	}
+
+	used := make(map[*MatchTicket]bool)
+	var matches [][]*MatchTicket
+	for _, anchor := range tickets {
+		if used[anchor] || len(anchor.Players) > size {
+			continue
+		}
+		band := s.band(anchor, now)
+
+		var candidates []*MatchTicket
+		for _, t := range tickets {
+			if t != anchor && !used[t] && math.Abs(t.Rating-anchor.Rating) <= band {
+				candidates = append(candidates, t)
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return math.Abs(candidates[i].Rating-anchor.Rating) < math.Abs(candidates[j].Rating-anchor.Rating)
+		})
+
+		group, players := []*MatchTicket{anchor}, len(anchor.Players)
+		for _, t := range candidates {
+			if players+len(t.Players) <= size {
+				group = append(group, t)
+				players += len(t.Players)
+			}
+		}
+		if players < size {
+			continue
+		}
+		for _, t := range group {
+			used[t] = true
+		}
+		matches = append(matches, group)
+	}
+	return matches
+}
+
+// MatchmakingConfig configures the matchmaker, zero values get the defaults
+type MatchmakingConfig struct {
+	// Strategy is RatingBandStrategy{} when nil
+	Strategy MatchmakingStrategy
+	// Interval is how often matches are made, one second when 0
+	Interval time.Duration
+	// StartMatch puts the players of a match into a room. When nil a room of the mode is
+	// created, a plain room for the empty mode.
+	StartMatch func(mode string, players []*Player) (*Room, error)
+}
+
+type matchmaker struct {
+	cfg MatchmakingConfig
+
+	mu sync.Mutex
+	// tickets are in queue order, byPlayer finds the ticket of every queued player
+	tickets  []*MatchTicket
+	byPlayer map[string]*MatchTicket
+}
+
+// JoinMatchmaking queues the player, or its party when it leads one, for a match of mode
+func (gs *GameServer) JoinMatchmaking(player *Player, mode string) error {
+	if gs.matchmaker == nil {
+		return ErrMatchmakingNotEnabled
+	}
+	if mode != "" {
+		gs.roomsMu.RLock()
+		_, ok := gs.modes[mode]
+		gs.roomsMu.RUnlock()
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownMode, mode)
+		}
+	}
+
+	players := []*Player{player}
+	if party := player.Party(); party != nil {
+		if party.LeaderID() != player.ID {
+			return ErrNotPartyLeader
+		}
+		players = party.Members()
+	}
+
+	rating := 0.0
+	if gs.ratings != nil {
+		for _, p := range players {
+			r, err := gs.Rating(p.AccountID)
+			if err != nil {
+				return err
+			}
+			rating += r.Value
+		}
+		rating /= float64(len(players))
+	}
+
+	ticket := &MatchTicket{Players: players, Mode: mode, Rating: rating, QueuedAt: time.Now()}
+	m := gs.matchmaker
+	m.mu.Lock()
+	for _, p := range players {
+		m.removeLocked(p.ID)
+	}
+	m.tickets = append(m.tickets, ticket)
+	for _, p := range players {
+		m.byPlayer[p.ID] = ticket
+	}
+	m.mu.Unlock()
+
+	status := MatchmakingStatusPayload{Queued: true, Mode: mode, Rating: rating}
+	for _, p := range players {
+		if err := gs.SendMatchmakingStatus(p.ID, status); err != nil {
+			log.Printf("Error sending matchmaking status to player %s: %v", p.ID, err)
+		}
+	}
+	return nil
+}
+
+// LeaveMatchmaking takes the player's ticket out of the queue, with its whole party
+func (gs *GameServer) LeaveMatchmaking(player *Player) {
+	gs.leaveMatchmaking(player, true)
+}
+
+// leaveMatchmaking tells the rest of the party, and the player too when it's still there
+func (gs *GameServer) leaveMatchmaking(player *Player, connected bool) {
+	if gs.matchmaker == nil {
+		return
+	}
+	m := gs.matchmaker
+	m.mu.Lock()
+	ticket := m.removeLocked(player.ID)
+	m.mu.Unlock()
+	if ticket == nil {
+		return
+	}
+
+	status := MatchmakingStatusPayload{Queued: false, Mode: ticket.Mode, Rating: ticket.Rating}
+	for _, p := range ticket.Players {
+		if p == player && !connected {
+			continue
+		}
+		if err := gs.SendMatchmakingStatus(p.ID, status); err != nil {
+			log.Printf("Error sending matchmaking status to player %s: %v", p.ID, err)
+		}
+	}
+}
+
+// removeLocked drops the ticket of the player, if it has one
+func (m *matchmaker) removeLocked(playerID string) *MatchTicket {
+	ticket := m.byPlayer[playerID]
+	if ticket == nil {
+		return nil
+	}
+	for _, p := range ticket.Players {
+		delete(m.byPlayer, p.ID)
+	}
+	for i, t := range m.tickets {
+		if t == ticket {
+			m.tickets = append(m.tickets[:i], m.tickets[i+1:]...)
+			break
+		}
+	}
+	return ticket
+}
+
+// runMatchmaker makes matches every interval until the server shuts down
+func (gs *GameServer) runMatchmaker() {
+	ticker := time.NewTicker(gs.matchmaker.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, match := range gs.matchmaker.makeMatches(now) {
+				gs.startMatch(match)
+			}
+		case <-gs.stop:
+			return
+		}
+	}
+}
+
+// makeMatches takes the matched tickets out of the queue
+func (m *matchmaker) makeMatches(now time.Time) [][]*MatchTicket {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	byMode := make(map[string][]*MatchTicket)
+	var modes []string
+	for _, t := range m.tickets {
+		if byMode[t.Mode] == nil {
+			modes = append(modes, t.Mode)
+		}
+		byMode[t.Mode] = append(byMode[t.Mode], t)
+	}
+
+	var matches [][]*MatchTicket
+	for _, mode := range modes {
+	next:
+		for _, match := range m.cfg.Strategy.Match(byMode[mode], now) {
+			for _, t := range match {
+				if m.byPlayer[t.Players[0].ID] != t {
+					// The strategy returned a ticket twice or one it made up
+					continue next
+				}
+			}
+			for _, t := range match {
+				m.removeLocked(t.Players[0].ID)
+			}
+			if len(match) > 0 {
+				matches = append(matches, match)
+			}
+		}
+	}
+	return matches
+}
+
+// startMatch moves the players of a match into its room
+func (gs *GameServer) startMatch(match []*MatchTicket) {
+	var players []*Player
+	var ids []string
+	total := 0.0
+	for _, t := range match {
+		for _, p := range t.Players {
+			players = append(players, p)
+			ids = append(ids, p.ID)
+			total += t.Rating
+		}
+	}
+	mode := match[0].Mode
+
+	room, err := gs.matchmaker.cfg.StartMatch(mode, players)
+	if err != nil {
+		log.Printf("Failed to start %q match of %v: %v", mode, ids, err)
+		return
+	}
+	room.Logf("Matched %v", ids)
+	Publish(gs.events, MatchStarted{Room: room, Players: players})
+	found := MatchFoundPayload{RoomID: room.ID, Mode: mode, Players: ids, AverageRating: total / float64(len(players))}
+	for _, p := range players {
+		if err := gs.SendMatchFound(p.ID, found); err != nil {
+			log.Printf("Error sending match to player %s: %v", p.ID, err)
+		}
+	}
+}
+
+// defaultStartMatch creates a room of the mode and moves every player in
+func (gs *GameServer) defaultStartMatch(mode string, players []*Player) (*Room, error) {
+	var room *Room
+	var err error
+	if mode == "" {
+		room, err = gs.CreateRoom("")
+	} else {
+		room, err = gs.CreateRoomWithMode("", mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	room.SetListed(false)
+	for _, p := range players {
+		if err := room.Join(p); err != nil {
+			room.Logf("Matched player %s couldn't join: %v", p.ID, err)
+		}
+	}
+	return room, nil
+}
+
+// handleMatchmakingMessage routes MATCHMAKING_JOIN and MATCHMAKING_LEAVE
+func (gs *GameServer) handleMatchmakingMessage(player *Player, msg StructuredMessage) error {
+	if msg.Type == MatchmakingLeave {
+		gs.LeaveMatchmaking(player)
+		return nil
+	}
+	var payload MatchmakingJoinPayload
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid matchmaking join: %v", err)
+		}
+	}
+	return gs.JoinMatchmaking(player, payload.Mode)
+}
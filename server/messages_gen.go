@@ -0,0 +1,1441 @@
+// Code generated by msggen from messages.json. DO NOT EDIT.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// Player input, numbered with seq for client-side prediction
+	PlayerMove MessageType = "PLAYER_MOVE"
+	// Game state updates, carries ack of the last processed input
+	GameStateSync MessageType = "GAME_STATE_SYNC"
+	// Another player joined, sent once its JOIN was accepted
+	PlayerJoin  MessageType = "PLAYER_JOIN"
+	PlayerLeave MessageType = "PLAYER_LEAVE"
+	ChatMessage MessageType = "CHAT_MESSAGE"
+	// Position of a connection waiting for a free slot
+	QueueUpdate MessageType = "QUEUE_UPDATE"
+	// A waiting connection got a slot
+	QueueAdmitted MessageType = "QUEUE_ADMITTED"
+	// An entity left the player's area of interest
+	EntityLeave MessageType = "ENTITY_LEAVE"
+	RoomClosed  MessageType = "ROOM_CLOSED"
+	// Switch to spectating, echoed back on success
+	SpectateJoin MessageType = "SPECTATE_JOIN"
+	// Switch back to playing, echoed back on success
+	SpectateLeave MessageType = "SPECTATE_LEAVE"
+	// Create a party with the sender as leader
+	PartyCreate MessageType = "PARTY_CREATE"
+	// Invite a player, pushed to the invited player
+	PartyInvite MessageType = "PARTY_INVITE"
+	// Accept an invite
+	PartyJoin  MessageType = "PARTY_JOIN"
+	PartyLeave MessageType = "PARTY_LEAVE"
+	// Party members and metadata, sent to members on every change
+	PartyUpdate MessageType = "PARTY_UPDATE"
+	// Chat only delivered to the sender's party
+	PartyChat MessageType = "PARTY_CHAT"
+	// Client sets its own status, e.g. away
+	PresenceSet MessageType = "PRESENCE_SET"
+	// Pushed to friends when an account changes status
+	PresenceUpdate MessageType = "PRESENCE_UPDATE"
+	FriendAdd      MessageType = "FRIEND_ADD"
+	FriendRemove   MessageType = "FRIEND_REMOVE"
+	// Request the friend list, answered with every friend and their status
+	FriendList MessageType = "FRIEND_LIST"
+	// Private message to one account, only delivered to that account
+	DirectMessage MessageType = "DIRECT_MESSAGE"
+	// A direct message could not be delivered
+	DirectMessageFailed MessageType = "DIRECT_MESSAGE_FAILED"
+	BlockAccount        MessageType = "BLOCK_ACCOUNT"
+	UnblockAccount      MessageType = "UNBLOCK_ACCOUNT"
+	// What this deployment supports, sent on connect (also served at GET /capabilities)
+	Capabilities MessageType = "CAPABILITIES"
+	// The onboarding step the player has to finish next
+	OnboardingStep MessageType = "ONBOARDING_STEP"
+	// Finishes the current onboarding step
+	OnboardingReply MessageType = "ONBOARDING_REPLY"
+	// Onboarding is done, gameplay messages are accepted from now on
+	OnboardingComplete MessageType = "ONBOARDING_COMPLETE"
+	// An action was refused because a meter (action points, stamina, cooldown) ran short
+	ActionRejected MessageType = "ACTION_REJECTED"
+	// A synchronized countdown started, was cancelled, or is running when joining a room
+	Countdown MessageType = "COUNTDOWN"
+	// A move was rejected, snap back to the authoritative position
+	MoveCorrection MessageType = "MOVE_CORRECTION"
+	// Asks the players of a finished match for feedback
+	SurveyRequest MessageType = "SURVEY_REQUEST"
+	// Answer to a SURVEY_REQUEST, every field but match_id is optional
+	SurveyResponse MessageType = "SURVEY_RESPONSE"
+	// A message was rejected because its type, version or payload did not match the schema
+	SchemaError MessageType = "SCHEMA_ERROR"
+	// A message was rejected, sent for every rejected message with a code to switch on. Clients answer a request of the server they refuse with it
+	Error MessageType = "ERROR"
+	// First message of a client, declares its protocol version and the features it wants
+	Hello MessageType = "HELLO"
+	// Answer to HELLO with the protocol version and features to use
+	Welcome MessageType = "WELCOME"
+	// Acknowledges a message by the id it carried, reliable server messages and client messages sent with an id
+	MessageAck MessageType = "MESSAGE_ACK"
+	// The player has been idle and is disconnected with close reason IDLE unless it sends something
+	IdleWarning MessageType = "IDLE_WARNING"
+	// The player's profile, required before anything else when the server asks for it in capabilities (join_handshake)
+	Join MessageType = "JOIN"
+	// The JOIN was valid, the player is now visible to others
+	JoinAccepted MessageType = "JOIN_ACCEPTED"
+	// The JOIN was invalid, fix it and send JOIN again
+	JoinRejected MessageType = "JOIN_REJECTED"
+	// The devices an account is connected from, sent to each of them when one connects or leaves
+	SessionList MessageType = "SESSION_LIST"
+	// The token to put in front of UDP datagrams, sent on connect when the UDP channel is enabled
+	UDPSession MessageType = "UDP_SESSION"
+	// Messages written during one room tick, combined into a single frame: the payload is {"messages": [...]           } with the messages in order. Only sent to clients that asked for the batching feature in HELLO
+	Batch MessageType = "BATCH"
+	// The player is being removed from the server, the close frame with reason KICKED follows
+	Kicked MessageType = "KICKED"
+	// The key of an encrypted room or party, sent on join and whenever it changes
+	PayloadKey MessageType = "PAYLOAD_KEY"
+	// The token to resume this session with after a reconnect, see server/resume.go
+	SessionToken MessageType = "SESSION_TOKEN"
+	// The account rating changed after a rated match
+	RatingUpdate MessageType = "RATING_UPDATE"
+	// Look for a match, a party leader queues the whole party
+	MatchmakingJoin MessageType = "MATCHMAKING_JOIN"
+	// Stop looking for a match
+	MatchmakingLeave MessageType = "MATCHMAKING_LEAVE"
+	// Sent when the player starts or stops looking for a match
+	MatchmakingStatus MessageType = "MATCHMAKING_STATUS"
+	// A match was made and the player moved into its room
+	MatchFound MessageType = "MATCH_FOUND"
+	// The bracket of a tournament, sent to participants and watchers on every change
+	TournamentUpdate MessageType = "TOURNAMENT_UPDATE"
+	// Follow a tournament, answered with its TOURNAMENT_UPDATE
+	TournamentWatch MessageType = "TOURNAMENT_WATCH"
+	// Stop following a tournament
+	TournamentUnwatch MessageType = "TOURNAMENT_UNWATCH"
+	// Ask for the joinable rooms, answered with ROOM_LIST (also served at GET /rooms)
+	ListRooms MessageType = "LIST_ROOMS"
+	// One page of the joinable rooms, fullest first
+	RoomList MessageType = "ROOM_LIST"
+	// Join a room by ID, answered with ROOM_JOINED or ROOM_JOIN_REJECTED
+	RoomJoin MessageType = "ROOM_JOIN"
+	// The ROOM_JOIN went through, the password is never echoed back
+	RoomJoined MessageType = "ROOM_JOINED"
+	// The ROOM_JOIN was refused, e.g. a wrong password or a missing invite
+	RoomJoinRejected MessageType = "ROOM_JOIN_REJECTED"
+	// Invite a player into the sender's room, pushed to the invited player
+	RoomInvite MessageType = "ROOM_INVITE"
+	// A server-wide notice from the operators, see Announce
+	Announcement MessageType = "ANNOUNCEMENT"
+	// Clock sync round trip: the client sends client_send, the server answers with its receive and send times
+	TimeSync MessageType = "TIME_SYNC"
+	// Round trip probe, answer right away with PONG carrying the same n
+	Ping MessageType = "PING"
+	// Answer to PING, it does not count as activity for idle kicks
+	Pong MessageType = "PONG"
+	// Starts a payload too large for one frame, sent in TRANSFER_CHUNK messages and handled as a message of type once complete
+	TransferStart MessageType = "TRANSFER_START"
+	// A piece of a transfer, every chunk but the last has chunk_size bytes
+	TransferChunk MessageType = "TRANSFER_CHUNK"
+	// Gives up a transfer, sent by either side
+	TransferAbort MessageType = "TRANSFER_ABORT"
+	// Become a WebRTC peer of the player's room, answered with RTC_PEERS
+	RTCJoin MessageType = "RTC_JOIN"
+	// Stop being a WebRTC peer, the other peers get RTC_PEER_LEFT
+	RTCLeave MessageType = "RTC_LEAVE"
+	// The peers of the room to connect to and the ICE servers to use, answers RTC_JOIN
+	RTCPeers MessageType = "RTC_PEERS"
+	// Another player of the room became a peer, it sends the offer
+	RTCPeerJoined MessageType = "RTC_PEER_JOINED"
+	// A peer left the room or stopped signaling, close the connection to it
+	RTCPeerLeft MessageType = "RTC_PEER_LEFT"
+	// SDP offer relayed to a peer of the same room
+	RTCOffer MessageType = "RTC_OFFER"
+	// SDP answer relayed back to the peer that sent the offer
+	RTCAnswer MessageType = "RTC_ANSWER"
+	// ICE candidate relayed to a peer of the same room
+	RTCCandidate MessageType = "RTC_CANDIDATE"
+)
+
+// QueueStatusPayload is sent with QUEUE_UPDATE messages
+type QueueStatusPayload struct {
+	Position    int `json:"position"`
+	QueueLength int `json:"queue_length"`
+	// 0 until the server has seen enough slots free up to make a guess
+	EstimatedWaitSeconds int64 `json:"estimated_wait_seconds"`
+}
+
+// EntityLeavePayload is sent with ENTITY_LEAVE when an entity drops out of a player's area of interest
+type EntityLeavePayload struct {
+	EntityID string `json:"entity_id"`
+}
+
+// RoomClosedPayload is sent to every member when a room is closed
+type RoomClosedPayload struct {
+	RoomID string `json:"room_id"`
+	Reason string `json:"reason"`
+}
+
+// PartyInvitePayload is sent by the inviting client with ToPlayerID and pushed to the invited player with PartyID and FromPlayerID
+type PartyInvitePayload struct {
+	PartyID      string `json:"party_id,omitempty"`
+	FromPlayerID string `json:"from_player_id,omitempty"`
+	ToPlayerID   string `json:"to_player_id" validate:"required,max=128"`
+}
+
+type PartyJoinPayload struct {
+	PartyID string `json:"party_id" validate:"required,max=128"`
+}
+
+type PartyStatePayload struct {
+	PartyID  string            `json:"party_id"`
+	LeaderID string            `json:"leader_id"`
+	Members  []string          `json:"members"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+type PresencePayload struct {
+	AccountID string `json:"account_id,omitempty"`
+	// online, away, in_game or offline
+	Status string `json:"status"`
+}
+
+type FriendPayload struct {
+	AccountID string `json:"account_id" validate:"required,max=128"`
+}
+
+type FriendListPayload struct {
+	Friends []PresencePayload `json:"friends"`
+}
+
+type DirectMessagePayload struct {
+	// Set by the server, whatever the client sends is ignored
+	From string `json:"from,omitempty"`
+	To   string `json:"to" validate:"max=128"`
+	Text string `json:"text" validate:"max=2000"`
+	// Unix time the server received it, useful for messages delivered after reconnect
+	SentAt int64 `json:"sent_at,omitempty"`
+}
+
+type DirectMessageFailedPayload struct {
+	To     string `json:"to"`
+	Reason string `json:"reason"`
+}
+
+type BlockPayload struct {
+	AccountID string `json:"account_id" validate:"required,max=128"`
+}
+
+// CapabilitiesPayload lists enabled modules, protocol versions, codecs and limits of a deployment
+type CapabilitiesPayload struct {
+	ProtocolVersion    int `json:"protocol_version"`
+	MinProtocolVersion int `json:"min_protocol_version"`
+	// Message encodings and extensions the server accepts
+	Codecs []string `json:"codecs"`
+	// Optional features enabled on this server
+	Modules []string `json:"modules"`
+	// Numeric limits, durations are in milliseconds
+	Limits map[string]int `json:"limits"`
+}
+
+type OnboardingStepPayload struct {
+	// terms, name or tutorial
+	Step         string `json:"step"`
+	TermsVersion string `json:"terms_version,omitempty"`
+	TermsURL     string `json:"terms_url,omitempty"`
+	// Tutorial room the player was put in
+	RoomID string `json:"room_id,omitempty"`
+	// Why the previous reply was rejected
+	Error string `json:"error,omitempty"`
+}
+
+type OnboardingReplyPayload struct {
+	Step string `json:"step"`
+	// Terms step: the player accepted the terms
+	Accept bool `json:"accept,omitempty"`
+	// Name step: the picked display name
+	Name string `json:"name,omitempty"`
+}
+
+type OnboardingCompletePayload struct {
+	DisplayName string `json:"display_name,omitempty"`
+}
+
+type ActionRejectedPayload struct {
+	// The rejected message type
+	Type  MessageType `json:"type"`
+	Meter string      `json:"meter"`
+	// What the meter had left
+	Value float64 `json:"value"`
+	Cost  float64 `json:"cost"`
+}
+
+type CountdownPayload struct {
+	// What starts, e.g. race or round
+	Label string `json:"label,omitempty"`
+	// Server clock unix milliseconds when the countdown ends
+	StartAt int64 `json:"start_at"`
+	// Server clock unix milliseconds when the message was sent, to estimate the clock offset
+	ServerTime int64 `json:"server_time"`
+	// StartAt minus ServerTime, for clients without a clock offset
+	RemainingMs int64 `json:"remaining_ms"`
+	Cancelled   bool  `json:"cancelled,omitempty"`
+}
+
+// PlayerMovePayload is where the client moved its player
+type PlayerMovePayload struct {
+	X  float64 `json:"x"`
+	Y  float64 `json:"y"`
+	VX float64 `json:"vx,omitempty"`
+	VY float64 `json:"vy,omitempty"`
+}
+
+type MoveCorrectionPayload struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	// The rejected input
+	Seq    uint64 `json:"seq"`
+	Reason string `json:"reason"`
+}
+
+// EntityState is the server-authoritative state of one entity
+type EntityState struct {
+	ID string `json:"id"`
+	// Player controlling the entity, empty for server-owned ones
+	Owner      string                 `json:"owner,omitempty"`
+	X          float64                `json:"x"`
+	Y          float64                `json:"y"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	// Bumped on every change
+	Version uint64 `json:"version"`
+}
+
+// WorldStatePayload is the authoritative snapshot sent in GAME_STATE_SYNC
+type WorldStatePayload struct {
+	RoomID   string        `json:"room_id"`
+	Entities []EntityState `json:"entities"`
+}
+
+type SurveyRequestPayload struct {
+	MatchID string `json:"match_id"`
+	Mode    string `json:"mode,omitempty"`
+	Map     string `json:"map,omitempty"`
+	// Accounts that can be reported
+	Players   []string `json:"players"`
+	MaxRating int      `json:"max_rating"`
+	// Seconds left to answer
+	ExpiresIn int `json:"expires_in"`
+}
+
+type SurveyResponsePayload struct {
+	MatchID string `json:"match_id"`
+	// 1 to max_rating, 0 to skip
+	Rating   int    `json:"rating,omitempty"`
+	Feedback string `json:"feedback,omitempty"`
+	// Account of a player to report
+	Report       string `json:"report,omitempty"`
+	ReportReason string `json:"report_reason,omitempty"`
+}
+
+type ErrorPayload struct {
+	// RATE_LIMITED, INVALID_PAYLOAD, NOT_IN_ROOM, UNAUTHORIZED, or REJECTED for anything else
+	Code string `json:"code"`
+	// Type of the rejected message, empty when it couldn't be read
+	Type MessageType `json:"type,omitempty"`
+	Seq  uint64      `json:"seq,omitempty"`
+	// The dedup id the rejected message carried
+	Dedup uint64 `json:"dedup,omitempty"`
+	// Explains the rejection to people, don't switch on it
+	Reason string `json:"reason"`
+}
+
+type SchemaErrorPayload struct {
+	// Type of the rejected message
+	Type string `json:"type"`
+	// Version the client sent
+	Version int `json:"version,omitempty"`
+	// Version the server understands, 0 for unknown types
+	Supported int    `json:"supported,omitempty"`
+	Seq       uint64 `json:"seq,omitempty"`
+	Reason    string `json:"reason"`
+	// Path of the payload field that broke a rule, like members[2].name
+	Field string `json:"field,omitempty"`
+	// The rule it broke, like max=128
+	Rule string `json:"rule,omitempty"`
+}
+
+type HelloPayload struct {
+	ProtocolVersion int `json:"protocol_version"`
+	// Codecs and modules the client would like to use
+	Features []string `json:"features,omitempty"`
+	// Name and version of the client, for logs
+	Client string `json:"client,omitempty"`
+}
+
+type WelcomePayload struct {
+	// Version both sides speak from now on
+	ProtocolVersion int `json:"protocol_version"`
+	// The client asked for a newer version than the server has
+	Downgraded bool `json:"downgraded,omitempty"`
+	// The requested features the server supports
+	Features []string `json:"features"`
+	// The requested features the server does not support
+	Unsupported []string `json:"unsupported,omitempty"`
+}
+
+type MessageAckPayload struct {
+	ID uint64 `json:"id"`
+}
+
+type IdleWarningPayload struct {
+	// Milliseconds left before the disconnect
+	KickInMs int64 `json:"kick_in_ms"`
+}
+
+type JoinPayload struct {
+	// Display name
+	Name          string `json:"name"`
+	Avatar        string `json:"avatar,omitempty"`
+	ClientVersion string `json:"client_version,omitempty"`
+}
+
+type JoinAcceptedPayload struct {
+	PlayerID string `json:"player_id"`
+	Name     string `json:"name"`
+}
+
+type JoinRejectedPayload struct {
+	// The invalid field: name, avatar or client_version
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
+}
+
+type PlayerProfilePayload struct {
+	PlayerID string `json:"player_id"`
+	Name     string `json:"name"`
+	Avatar   string `json:"avatar,omitempty"`
+}
+
+type SessionPayload struct {
+	PlayerID string `json:"player_id"`
+	Device   string `json:"device,omitempty"`
+	// Unix milliseconds
+	ConnectedAt int64 `json:"connected_at"`
+	// This is the connection receiving the list
+	Current bool `json:"current,omitempty"`
+}
+
+type SessionListPayload struct {
+	Sessions []SessionPayload `json:"sessions"`
+}
+
+// UDPSessionPayload is sent with UDP_SESSION messages
+type UDPSessionPayload struct {
+	// 32 hex characters, send the 16 bytes they encode in front of every datagram
+	Token string `json:"token"`
+	Port  int    `json:"port"`
+}
+
+type KickedPayload struct {
+	// Why, for people
+	Reason string `json:"reason"`
+}
+
+type PayloadKeyPayload struct {
+	// room or party
+	Scope string `json:"scope"`
+	// ID of the room or party
+	ID string `json:"id"`
+	// Sealed payloads name the key they use by this ID
+	KeyID int `json:"key_id"`
+	// AES-256-GCM key, base64
+	Key string `json:"key"`
+}
+
+// SealedPayload An encrypted payload, see server/encryption.go
+type SealedPayload struct {
+	// Key the payload is sealed with
+	KeyID int `json:"kid"`
+	// base64 of the 12 byte nonce followed by the ciphertext
+	Data string `json:"enc"`
+}
+
+type SessionTokenPayload struct {
+	// Reconnect with ?session=<token>, it works once
+	Token string `json:"token"`
+	// This connection resumed an earlier session
+	Resumed bool `json:"resumed"`
+	// How long the session is kept after a disconnect
+	ExpiresInMs int `json:"expires_in_ms"`
+	// The sseq of the last message delivered before the disconnect, the numbering continues after it. A client that saw a lower one missed messages
+	LastSeq uint64 `json:"last_seq,omitempty"`
+}
+
+type RatingUpdatePayload struct {
+	Rating float64 `json:"rating"`
+	// Difference to the rating before the match
+	Change float64 `json:"change"`
+	Games  int     `json:"games"`
+}
+
+type MatchmakingJoinPayload struct {
+	// Game mode to play, empty for plain rooms
+	Mode string `json:"mode"`
+}
+
+type MatchmakingStatusPayload struct {
+	Queued bool   `json:"queued"`
+	Mode   string `json:"mode"`
+	// Rating the ticket is matched by, the party average for parties
+	Rating float64 `json:"rating"`
+}
+
+type MatchFoundPayload struct {
+	RoomID string `json:"room_id"`
+	Mode   string `json:"mode"`
+	// Player IDs of everyone in the match
+	Players       []string `json:"players"`
+	AverageRating float64  `json:"average_rating"`
+}
+
+// TournamentMatchPayload is one match of a bracket
+type TournamentMatchPayload struct {
+	// W<round>-<n> in the winners bracket, L<round>-<n> in the losers bracket, GF for the grand final
+	ID string `json:"id"`
+	// winners, losers or final
+	Bracket string `json:"bracket"`
+	Round   int    `json:"round"`
+	// The two account IDs, empty while not decided yet and "BYE" for a bye
+	Players []string `json:"players"`
+	Winner  string   `json:"winner"`
+	RoomID  string   `json:"room_id"`
+	// pending, playing, finished or bye
+	Status string `json:"status"`
+}
+
+type TournamentPayload struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// single_elimination or double_elimination
+	Format string `json:"format"`
+	// Account IDs by seed
+	Participants []string                 `json:"participants"`
+	Matches      []TournamentMatchPayload `json:"matches"`
+	// Set once the tournament is over
+	Champion string `json:"champion"`
+}
+
+type TournamentWatchPayload struct {
+	TournamentID string `json:"tournament_id"`
+}
+
+type ListRoomsPayload struct {
+	// Only rooms of this mode
+	Mode string `json:"mode"`
+	// Only rooms on this map
+	Map string `json:"map"`
+	// Only rooms whose name contains this, ignoring case
+	Name string `json:"name"`
+	// Also list rooms that are full
+	IncludeFull bool `json:"include_full"`
+	Offset      int  `json:"offset" validate:"min=0"`
+	// Rooms per page, 20 when 0 and at most 100
+	Limit int `json:"limit" validate:"min=0"`
+}
+
+// RoomListingPayload is one room of a ROOM_LIST
+type RoomListingPayload struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Mode    string `json:"mode"`
+	Map     string `json:"map"`
+	Players int    `json:"players"`
+	// 0 when the room has no limit
+	MaxPlayers int `json:"max_players"`
+	// Ping hint: the region the room is hosted in
+	Region string `json:"region"`
+	// Ping hint: the server hosting the room
+	Node string `json:"node"`
+}
+
+type RoomListPayload struct {
+	Rooms []RoomListingPayload `json:"rooms"`
+	// Rooms matching the filter, on all pages
+	Total  int `json:"total"`
+	Offset int `json:"offset"`
+	// Ping hint: an URL of this server clients can time requests to
+	PingURL string `json:"ping_url"`
+}
+
+type RoomJoinPayload struct {
+	RoomID string `json:"room_id" validate:"required,max=128"`
+	// Needed for password protected rooms unless invited
+	Password string `json:"password,omitempty" validate:"max=128"`
+}
+
+type RoomJoinRejectedPayload struct {
+	RoomID string `json:"room_id"`
+	Reason string `json:"reason"`
+}
+
+// RoomInvitePayload is sent by the inviting client with ToPlayerID and pushed to the invited player with the room and FromPlayerID
+type RoomInvitePayload struct {
+	RoomID       string `json:"room_id"`
+	RoomName     string `json:"room_name,omitempty"`
+	FromPlayerID string `json:"from_player_id"`
+	ToPlayerID   string `json:"to_player_id" validate:"required,max=128"`
+}
+
+type AnnouncementPayload struct {
+	Text string `json:"text"`
+}
+
+// TimeSyncPayload carries unix milliseconds, see clock.go for how clients turn it into a clock offset
+type TimeSyncPayload struct {
+	ClientSend    int64 `json:"client_send"`
+	ServerReceive int64 `json:"server_receive,omitempty"`
+	ServerSend    int64 `json:"server_send,omitempty"`
+}
+
+type PingPayload struct {
+	N uint64 `json:"n"`
+	// Smoothed round trip measured by the server so far
+	RTT int64 `json:"rtt_ms,omitempty"`
+}
+
+type PongPayload struct {
+	N uint64 `json:"n"`
+}
+
+type TransferStartPayload struct {
+	// Chosen by the sender, unique among its transfers in progress
+	TransferID string `json:"transfer_id" validate:"required,max=64"`
+	// Type of the message the payload is for
+	Type MessageType `json:"type" validate:"required"`
+	// Payload size in bytes
+	Size      int `json:"size" validate:"required,min=1"`
+	ChunkSize int `json:"chunk_size" validate:"required,min=1"`
+	// Hex SHA-256 of the payload, checked when given
+	Checksum string `json:"checksum,omitempty"`
+}
+
+type TransferChunkPayload struct {
+	TransferID string `json:"transfer_id" validate:"required,max=64"`
+	// Position of the chunk from 0
+	Index int `json:"index" validate:"min=0"`
+	// The chunk's bytes, base64 encoded
+	Data []byte `json:"data" validate:"required"`
+}
+
+type TransferAbortPayload struct {
+	TransferID string `json:"transfer_id" validate:"required,max=64"`
+	Reason     string `json:"reason"`
+}
+
+// ICEServerPayload is a STUN or TURN server, in the shape of RTCIceServer
+type ICEServerPayload struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+type RTCPeersPayload struct {
+	RoomID string `json:"room_id"`
+	// Player IDs of the other peers, they connect to the new peer
+	Peers      []string           `json:"peers"`
+	ICEServers []ICEServerPayload `json:"ice_servers,omitempty"`
+}
+
+type RTCPeerPayload struct {
+	RoomID string `json:"room_id"`
+	PeerID string `json:"peer_id"`
+}
+
+// RTCSessionPayload is sent with PeerID set to the peer to reach and delivered with PeerID set to the sender
+type RTCSessionPayload struct {
+	PeerID string `json:"peer_id" validate:"required,max=128"`
+	SDP    string `json:"sdp" validate:"required,max=65536"`
+}
+
+// RTCCandidatePayload is sent with PeerID set to the peer to reach and delivered with PeerID set to the sender
+type RTCCandidatePayload struct {
+	PeerID string `json:"peer_id" validate:"required,max=128"`
+	// Empty signals the end of candidates
+	Candidate     string `json:"candidate" validate:"max=2048"`
+	SDPMid        string `json:"sdp_mid,omitempty"`
+	SDPMLineIndex int    `json:"sdp_mline_index" validate:"min=0"`
+}
+
+// ServerInfoPayload is what a game server reports to the coordinator, see server/coordinator.go
+type ServerInfoPayload struct {
+	ID   string `json:"id" validate:"required,max=128"`
+	Name string `json:"name,omitempty" validate:"max=128"`
+	// URL clients connect to, like wss://eu1.example.com/ws
+	Address    string `json:"address" validate:"required,max=512"`
+	Region     string `json:"region,omitempty" validate:"max=64"`
+	Players    int    `json:"players" validate:"min=0"`
+	MaxPlayers int    `json:"max_players" validate:"min=0"`
+	Rooms      int    `json:"rooms,omitempty" validate:"min=0"`
+	// The optional features of the server, as in CAPABILITIES
+	Modules []string `json:"modules,omitempty"`
+	// Unix milliseconds of the last report, set by the coordinator
+	UpdatedAt int64 `json:"updated_at,omitempty"`
+}
+
+// ServerListPayload is the answer of the coordinator's GET /servers, least loaded first
+type ServerListPayload struct {
+	Servers []ServerInfoPayload `json:"servers"`
+}
+
+// messageSchemas is the registry of every message type, see schemas.go
+var messageSchemas = map[MessageType]MessageSchema{
+	PlayerMove:          {Type: PlayerMove, Direction: "client", Version: 1, Gameplay: true, Payload: "PlayerMovePayload", newPayload: func() interface{} { return new(PlayerMovePayload) }},
+	GameStateSync:       {Type: GameStateSync, Direction: "both", Version: 1, Gameplay: true},
+	PlayerJoin:          {Type: PlayerJoin, Direction: "server", Version: 1, Payload: "PlayerProfilePayload", newPayload: func() interface{} { return new(PlayerProfilePayload) }},
+	PlayerLeave:         {Type: PlayerLeave, Direction: "server", Version: 1},
+	ChatMessage:         {Type: ChatMessage, Direction: "both", Version: 1},
+	QueueUpdate:         {Type: QueueUpdate, Direction: "server", Version: 1, Payload: "QueueStatusPayload", newPayload: func() interface{} { return new(QueueStatusPayload) }},
+	QueueAdmitted:       {Type: QueueAdmitted, Direction: "server", Version: 1},
+	EntityLeave:         {Type: EntityLeave, Direction: "server", Version: 1, Payload: "EntityLeavePayload", newPayload: func() interface{} { return new(EntityLeavePayload) }},
+	RoomClosed:          {Type: RoomClosed, Direction: "server", Version: 1, Payload: "RoomClosedPayload", newPayload: func() interface{} { return new(RoomClosedPayload) }},
+	SpectateJoin:        {Type: SpectateJoin, Direction: "both", Version: 1},
+	SpectateLeave:       {Type: SpectateLeave, Direction: "both", Version: 1},
+	PartyCreate:         {Type: PartyCreate, Direction: "client", Version: 1},
+	PartyInvite:         {Type: PartyInvite, Direction: "both", Version: 1, Payload: "PartyInvitePayload", newPayload: func() interface{} { return new(PartyInvitePayload) }},
+	PartyJoin:           {Type: PartyJoin, Direction: "client", Version: 1, Payload: "PartyJoinPayload", newPayload: func() interface{} { return new(PartyJoinPayload) }},
+	PartyLeave:          {Type: PartyLeave, Direction: "client", Version: 1},
+	PartyUpdate:         {Type: PartyUpdate, Direction: "server", Version: 1, Payload: "PartyStatePayload", newPayload: func() interface{} { return new(PartyStatePayload) }},
+	PartyChat:           {Type: PartyChat, Direction: "both", Version: 1},
+	PresenceSet:         {Type: PresenceSet, Direction: "client", Version: 1, Payload: "PresencePayload", newPayload: func() interface{} { return new(PresencePayload) }},
+	PresenceUpdate:      {Type: PresenceUpdate, Direction: "server", Version: 1, Payload: "PresencePayload", newPayload: func() interface{} { return new(PresencePayload) }},
+	FriendAdd:           {Type: FriendAdd, Direction: "client", Version: 1, Payload: "FriendPayload", newPayload: func() interface{} { return new(FriendPayload) }},
+	FriendRemove:        {Type: FriendRemove, Direction: "client", Version: 1, Payload: "FriendPayload", newPayload: func() interface{} { return new(FriendPayload) }},
+	FriendList:          {Type: FriendList, Direction: "both", Version: 1, Payload: "FriendListPayload", newPayload: func() interface{} { return new(FriendListPayload) }},
+	DirectMessage:       {Type: DirectMessage, Direction: "both", Version: 1, Payload: "DirectMessagePayload", newPayload: func() interface{} { return new(DirectMessagePayload) }},
+	DirectMessageFailed: {Type: DirectMessageFailed, Direction: "server", Version: 1, Payload: "DirectMessageFailedPayload", newPayload: func() interface{} { return new(DirectMessageFailedPayload) }},
+	BlockAccount:        {Type: BlockAccount, Direction: "client", Version: 1, Payload: "BlockPayload", newPayload: func() interface{} { return new(BlockPayload) }},
+	UnblockAccount:      {Type: UnblockAccount, Direction: "client", Version: 1, Payload: "BlockPayload", newPayload: func() interface{} { return new(BlockPayload) }},
+	Capabilities:        {Type: Capabilities, Direction: "server", Version: 1, Payload: "CapabilitiesPayload", newPayload: func() interface{} { return new(CapabilitiesPayload) }},
+	OnboardingStep:      {Type: OnboardingStep, Direction: "server", Version: 1, Payload: "OnboardingStepPayload", newPayload: func() interface{} { return new(OnboardingStepPayload) }},
+	OnboardingReply:     {Type: OnboardingReply, Direction: "client", Version: 1, Payload: "OnboardingReplyPayload", newPayload: func() interface{} { return new(OnboardingReplyPayload) }},
+	OnboardingComplete:  {Type: OnboardingComplete, Direction: "server", Version: 1, Payload: "OnboardingCompletePayload", newPayload: func() interface{} { return new(OnboardingCompletePayload) }},
+	ActionRejected:      {Type: ActionRejected, Direction: "server", Version: 1, Payload: "ActionRejectedPayload", newPayload: func() interface{} { return new(ActionRejectedPayload) }},
+	Countdown:           {Type: Countdown, Direction: "server", Version: 1, Payload: "CountdownPayload", newPayload: func() interface{} { return new(CountdownPayload) }},
+	MoveCorrection:      {Type: MoveCorrection, Direction: "server", Version: 1, Payload: "MoveCorrectionPayload", newPayload: func() interface{} { return new(MoveCorrectionPayload) }},
+	SurveyRequest:       {Type: SurveyRequest, Direction: "server", Version: 1, Payload: "SurveyRequestPayload", newPayload: func() interface{} { return new(SurveyRequestPayload) }},
+	SurveyResponse:      {Type: SurveyResponse, Direction: "client", Version: 1, Payload: "SurveyResponsePayload", newPayload: func() interface{} { return new(SurveyResponsePayload) }},
+	SchemaError:         {Type: SchemaError, Direction: "server", Version: 1, Payload: "SchemaErrorPayload", newPayload: func() interface{} { return new(SchemaErrorPayload) }},
+	Error:               {Type: Error, Direction: "both", Version: 1, Payload: "ErrorPayload", newPayload: func() interface{} { return new(ErrorPayload) }},
+	Hello:               {Type: Hello, Direction: "client", Version: 1, Payload: "HelloPayload", newPayload: func() interface{} { return new(HelloPayload) }},
+	Welcome:             {Type: Welcome, Direction: "server", Version: 1, Payload: "WelcomePayload", newPayload: func() interface{} { return new(WelcomePayload) }},
+	MessageAck:          {Type: MessageAck, Direction: "both", Version: 1, Payload: "MessageAckPayload", newPayload: func() interface{} { return new(MessageAckPayload) }},
+	IdleWarning:         {Type: IdleWarning, Direction: "server", Version: 1, Payload: "IdleWarningPayload", newPayload: func() interface{} { return new(IdleWarningPayload) }},
+	Join:                {Type: Join, Direction: "client", Version: 1, Payload: "JoinPayload", newPayload: func() interface{} { return new(JoinPayload) }},
+	JoinAccepted:        {Type: JoinAccepted, Direction: "server", Version: 1, Payload: "JoinAcceptedPayload", newPayload: func() interface{} { return new(JoinAcceptedPayload) }},
+	JoinRejected:        {Type: JoinRejected, Direction: "server", Version: 1, Payload: "JoinRejectedPayload", newPayload: func() interface{} { return new(JoinRejectedPayload) }},
+	SessionList:         {Type: SessionList, Direction: "server", Version: 1, Payload: "SessionListPayload", newPayload: func() interface{} { return new(SessionListPayload) }},
+	UDPSession:          {Type: UDPSession, Direction: "server", Version: 1, Payload: "UDPSessionPayload", newPayload: func() interface{} { return new(UDPSessionPayload) }},
+	Batch:               {Type: Batch, Direction: "server", Version: 1},
+	Kicked:              {Type: Kicked, Direction: "server", Version: 1, Payload: "KickedPayload", newPayload: func() interface{} { return new(KickedPayload) }},
+	PayloadKey:          {Type: PayloadKey, Direction: "server", Version: 1, Payload: "PayloadKeyPayload", newPayload: func() interface{} { return new(PayloadKeyPayload) }},
+	SessionToken:        {Type: SessionToken, Direction: "server", Version: 1, Payload: "SessionTokenPayload", newPayload: func() interface{} { return new(SessionTokenPayload) }},
+	RatingUpdate:        {Type: RatingUpdate, Direction: "server", Version: 1, Payload: "RatingUpdatePayload", newPayload: func() interface{} { return new(RatingUpdatePayload) }},
+	MatchmakingJoin:     {Type: MatchmakingJoin, Direction: "client", Version: 1, Payload: "MatchmakingJoinPayload", newPayload: func() interface{} { return new(MatchmakingJoinPayload) }},
+	MatchmakingLeave:    {Type: MatchmakingLeave, Direction: "client", Version: 1},
+	MatchmakingStatus:   {Type: MatchmakingStatus, Direction: "server", Version: 1, Payload: "MatchmakingStatusPayload", newPayload: func() interface{} { return new(MatchmakingStatusPayload) }},
+	MatchFound:          {Type: MatchFound, Direction: "server", Version: 1, Payload: "MatchFoundPayload", newPayload: func() interface{} { return new(MatchFoundPayload) }},
+	TournamentUpdate:    {Type: TournamentUpdate, Direction: "server", Version: 1, Payload: "TournamentPayload", newPayload: func() interface{} { return new(TournamentPayload) }},
+	TournamentWatch:     {Type: TournamentWatch, Direction: "client", Version: 1, Payload: "TournamentWatchPayload", newPayload: func() interface{} { return new(TournamentWatchPayload) }},
+	TournamentUnwatch:   {Type: TournamentUnwatch, Direction: "client", Version: 1, Payload: "TournamentWatchPayload", newPayload: func() interface{} { return new(TournamentWatchPayload) }},
+	ListRooms:           {Type: ListRooms, Direction: "client", Version: 1, Payload: "ListRoomsPayload", newPayload: func() interface{} { return new(ListRoomsPayload) }},
+	RoomList:            {Type: RoomList, Direction: "server", Version: 1, Payload: "RoomListPayload", newPayload: func() interface{} { return new(RoomListPayload) }},
+	RoomJoin:            {Type: RoomJoin, Direction: "client", Version: 1, Payload: "RoomJoinPayload", newPayload: func() interface{} { return new(RoomJoinPayload) }},
+	RoomJoined:          {Type: RoomJoined, Direction: "server", Version: 1, Payload: "RoomJoinPayload", newPayload: func() interface{} { return new(RoomJoinPayload) }},
+	RoomJoinRejected:    {Type: RoomJoinRejected, Direction: "server", Version: 1, Payload: "RoomJoinRejectedPayload", newPayload: func() interface{} { return new(RoomJoinRejectedPayload) }},
+	RoomInvite:          {Type: RoomInvite, Direction: "both", Version: 1, Payload: "RoomInvitePayload", newPayload: func() interface{} { return new(RoomInvitePayload) }},
+	Announcement:        {Type: Announcement, Direction: "server", Version: 1, Payload: "AnnouncementPayload", newPayload: func() interface{} { return new(AnnouncementPayload) }},
+	TimeSync:            {Type: TimeSync, Direction: "both", Version: 1, Payload: "TimeSyncPayload", newPayload: func() interface{} { return new(TimeSyncPayload) }},
+	Ping:                {Type: Ping, Direction: "server", Version: 1, Payload: "PingPayload", newPayload: func() interface{} { return new(PingPayload) }},
+	Pong:                {Type: Pong, Direction: "client", Version: 1, Payload: "PongPayload", newPayload: func() interface{} { return new(PongPayload) }},
+	TransferStart:       {Type: TransferStart, Direction: "both", Version: 1, Payload: "TransferStartPayload", newPayload: func() interface{} { return new(TransferStartPayload) }},
+	TransferChunk:       {Type: TransferChunk, Direction: "both", Version: 1, Payload: "TransferChunkPayload", newPayload: func() interface{} { return new(TransferChunkPayload) }},
+	TransferAbort:       {Type: TransferAbort, Direction: "both", Version: 1, Payload: "TransferAbortPayload", newPayload: func() interface{} { return new(TransferAbortPayload) }},
+	RTCJoin:             {Type: RTCJoin, Direction: "client", Version: 1},
+	RTCLeave:            {Type: RTCLeave, Direction: "client", Version: 1},
+	RTCPeers:            {Type: RTCPeers, Direction: "server", Version: 1, Payload: "RTCPeersPayload", newPayload: func() interface{} { return new(RTCPeersPayload) }},
+	RTCPeerJoined:       {Type: RTCPeerJoined, Direction: "server", Version: 1, Payload: "RTCPeerPayload", newPayload: func() interface{} { return new(RTCPeerPayload) }},
+	RTCPeerLeft:         {Type: RTCPeerLeft, Direction: "server", Version: 1, Payload: "RTCPeerPayload", newPayload: func() interface{} { return new(RTCPeerPayload) }},
+	RTCOffer:            {Type: RTCOffer, Direction: "both", Version: 1, Payload: "RTCSessionPayload", newPayload: func() interface{} { return new(RTCSessionPayload) }},
+	RTCAnswer:           {Type: RTCAnswer, Direction: "both", Version: 1, Payload: "RTCSessionPayload", newPayload: func() interface{} { return new(RTCSessionPayload) }},
+	RTCCandidate:        {Type: RTCCandidate, Direction: "both", Version: 1, Payload: "RTCCandidatePayload", newPayload: func() interface{} { return new(RTCCandidatePayload) }},
+}
+
+// gameplayMessages are the message types spectators are not allowed to send
+var gameplayMessages = map[MessageType]bool{
+	PlayerMove:    true,
+	GameStateSync: true,
+}
+
+// MessageHandler has one method per message type clients may send
+// Embed UnimplementedMessageHandler to only implement some of them
+type MessageHandler interface {
+	HandlePlayerMove(player *Player, msg StructuredMessage, payload PlayerMovePayload) error
+	HandleGameStateSync(player *Player, msg StructuredMessage) error
+	HandleChatMessage(player *Player, msg StructuredMessage) error
+	HandleSpectateJoin(player *Player, msg StructuredMessage) error
+	HandleSpectateLeave(player *Player, msg StructuredMessage) error
+	HandlePartyCreate(player *Player, msg StructuredMessage) error
+	HandlePartyInvite(player *Player, msg StructuredMessage, payload PartyInvitePayload) error
+	HandlePartyJoin(player *Player, msg StructuredMessage, payload PartyJoinPayload) error
+	HandlePartyLeave(player *Player, msg StructuredMessage) error
+	HandlePartyChat(player *Player, msg StructuredMessage) error
+	HandlePresenceSet(player *Player, msg StructuredMessage, payload PresencePayload) error
+	HandleFriendAdd(player *Player, msg StructuredMessage, payload FriendPayload) error
+	HandleFriendRemove(player *Player, msg StructuredMessage, payload FriendPayload) error
+	HandleFriendList(player *Player, msg StructuredMessage, payload FriendListPayload) error
+	HandleDirectMessage(player *Player, msg StructuredMessage, payload DirectMessagePayload) error
+	HandleBlockAccount(player *Player, msg StructuredMessage, payload BlockPayload) error
+	HandleUnblockAccount(player *Player, msg StructuredMessage, payload BlockPayload) error
+	HandleOnboardingReply(player *Player, msg StructuredMessage, payload OnboardingReplyPayload) error
+	HandleSurveyResponse(player *Player, msg StructuredMessage, payload SurveyResponsePayload) error
+	HandleError(player *Player, msg StructuredMessage, payload ErrorPayload) error
+	HandleHello(player *Player, msg StructuredMessage, payload HelloPayload) error
+	HandleMessageAck(player *Player, msg StructuredMessage, payload MessageAckPayload) error
+	HandleJoin(player *Player, msg StructuredMessage, payload JoinPayload) error
+	HandleMatchmakingJoin(player *Player, msg StructuredMessage, payload MatchmakingJoinPayload) error
+	HandleMatchmakingLeave(player *Player, msg StructuredMessage) error
+	HandleTournamentWatch(player *Player, msg StructuredMessage, payload TournamentWatchPayload) error
+	HandleTournamentUnwatch(player *Player, msg StructuredMessage, payload TournamentWatchPayload) error
+	HandleListRooms(player *Player, msg StructuredMessage, payload ListRoomsPayload) error
+	HandleRoomJoin(player *Player, msg StructuredMessage, payload RoomJoinPayload) error
+	HandleRoomInvite(player *Player, msg StructuredMessage, payload RoomInvitePayload) error
+	HandleTimeSync(player *Player, msg StructuredMessage, payload TimeSyncPayload) error
+	HandlePong(player *Player, msg StructuredMessage, payload PongPayload) error
+	HandleTransferStart(player *Player, msg StructuredMessage, payload TransferStartPayload) error
+	HandleTransferChunk(player *Player, msg StructuredMessage, payload TransferChunkPayload) error
+	HandleTransferAbort(player *Player, msg StructuredMessage, payload TransferAbortPayload) error
+	HandleRTCJoin(player *Player, msg StructuredMessage) error
+	HandleRTCLeave(player *Player, msg StructuredMessage) error
+	HandleRTCOffer(player *Player, msg StructuredMessage, payload RTCSessionPayload) error
+	HandleRTCAnswer(player *Player, msg StructuredMessage, payload RTCSessionPayload) error
+	HandleRTCCandidate(player *Player, msg StructuredMessage, payload RTCCandidatePayload) error
+}
+
+// UnimplementedMessageHandler rejects every message, embed it in your handler
+type UnimplementedMessageHandler struct{}
+
+func (UnimplementedMessageHandler) HandlePlayerMove(player *Player, msg StructuredMessage, payload PlayerMovePayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleGameStateSync(player *Player, msg StructuredMessage) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleChatMessage(player *Player, msg StructuredMessage) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleSpectateJoin(player *Player, msg StructuredMessage) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleSpectateLeave(player *Player, msg StructuredMessage) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandlePartyCreate(player *Player, msg StructuredMessage) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandlePartyInvite(player *Player, msg StructuredMessage, payload PartyInvitePayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandlePartyJoin(player *Player, msg StructuredMessage, payload PartyJoinPayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandlePartyLeave(player *Player, msg StructuredMessage) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandlePartyChat(player *Player, msg StructuredMessage) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandlePresenceSet(player *Player, msg StructuredMessage, payload PresencePayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleFriendAdd(player *Player, msg StructuredMessage, payload FriendPayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleFriendRemove(player *Player, msg StructuredMessage, payload FriendPayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleFriendList(player *Player, msg StructuredMessage, payload FriendListPayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleDirectMessage(player *Player, msg StructuredMessage, payload DirectMessagePayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleBlockAccount(player *Player, msg StructuredMessage, payload BlockPayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleUnblockAccount(player *Player, msg StructuredMessage, payload BlockPayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleOnboardingReply(player *Player, msg StructuredMessage, payload OnboardingReplyPayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleSurveyResponse(player *Player, msg StructuredMessage, payload SurveyResponsePayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleError(player *Player, msg StructuredMessage, payload ErrorPayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleHello(player *Player, msg StructuredMessage, payload HelloPayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleMessageAck(player *Player, msg StructuredMessage, payload MessageAckPayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleJoin(player *Player, msg StructuredMessage, payload JoinPayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleMatchmakingJoin(player *Player, msg StructuredMessage, payload MatchmakingJoinPayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleMatchmakingLeave(player *Player, msg StructuredMessage) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleTournamentWatch(player *Player, msg StructuredMessage, payload TournamentWatchPayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleTournamentUnwatch(player *Player, msg StructuredMessage, payload TournamentWatchPayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleListRooms(player *Player, msg StructuredMessage, payload ListRoomsPayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleRoomJoin(player *Player, msg StructuredMessage, payload RoomJoinPayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleRoomInvite(player *Player, msg StructuredMessage, payload RoomInvitePayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleTimeSync(player *Player, msg StructuredMessage, payload TimeSyncPayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandlePong(player *Player, msg StructuredMessage, payload PongPayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleTransferStart(player *Player, msg StructuredMessage, payload TransferStartPayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleTransferChunk(player *Player, msg StructuredMessage, payload TransferChunkPayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleTransferAbort(player *Player, msg StructuredMessage, payload TransferAbortPayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleRTCJoin(player *Player, msg StructuredMessage) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleRTCLeave(player *Player, msg StructuredMessage) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleRTCOffer(player *Player, msg StructuredMessage, payload RTCSessionPayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleRTCAnswer(player *Player, msg StructuredMessage, payload RTCSessionPayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+func (UnimplementedMessageHandler) HandleRTCCandidate(player *Player, msg StructuredMessage, payload RTCCandidatePayload) error {
+	return fmt.Errorf("unhandled message type %s", msg.Type)
+}
+
+// DispatchMessage decodes the payload of msg and calls the matching handler method
+func DispatchMessage(h MessageHandler, player *Player, msg StructuredMessage) error {
+	switch msg.Type {
+	case PlayerMove:
+		var payload PlayerMovePayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandlePlayerMove(player, msg, payload)
+	case GameStateSync:
+		return h.HandleGameStateSync(player, msg)
+	case ChatMessage:
+		return h.HandleChatMessage(player, msg)
+	case SpectateJoin:
+		return h.HandleSpectateJoin(player, msg)
+	case SpectateLeave:
+		return h.HandleSpectateLeave(player, msg)
+	case PartyCreate:
+		return h.HandlePartyCreate(player, msg)
+	case PartyInvite:
+		var payload PartyInvitePayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandlePartyInvite(player, msg, payload)
+	case PartyJoin:
+		var payload PartyJoinPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandlePartyJoin(player, msg, payload)
+	case PartyLeave:
+		return h.HandlePartyLeave(player, msg)
+	case PartyChat:
+		return h.HandlePartyChat(player, msg)
+	case PresenceSet:
+		var payload PresencePayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandlePresenceSet(player, msg, payload)
+	case FriendAdd:
+		var payload FriendPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleFriendAdd(player, msg, payload)
+	case FriendRemove:
+		var payload FriendPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleFriendRemove(player, msg, payload)
+	case FriendList:
+		var payload FriendListPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleFriendList(player, msg, payload)
+	case DirectMessage:
+		var payload DirectMessagePayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleDirectMessage(player, msg, payload)
+	case BlockAccount:
+		var payload BlockPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleBlockAccount(player, msg, payload)
+	case UnblockAccount:
+		var payload BlockPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleUnblockAccount(player, msg, payload)
+	case OnboardingReply:
+		var payload OnboardingReplyPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleOnboardingReply(player, msg, payload)
+	case SurveyResponse:
+		var payload SurveyResponsePayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleSurveyResponse(player, msg, payload)
+	case Error:
+		var payload ErrorPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleError(player, msg, payload)
+	case Hello:
+		var payload HelloPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleHello(player, msg, payload)
+	case MessageAck:
+		var payload MessageAckPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleMessageAck(player, msg, payload)
+	case Join:
+		var payload JoinPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleJoin(player, msg, payload)
+	case MatchmakingJoin:
+		var payload MatchmakingJoinPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleMatchmakingJoin(player, msg, payload)
+	case MatchmakingLeave:
+		return h.HandleMatchmakingLeave(player, msg)
+	case TournamentWatch:
+		var payload TournamentWatchPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleTournamentWatch(player, msg, payload)
+	case TournamentUnwatch:
+		var payload TournamentWatchPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleTournamentUnwatch(player, msg, payload)
+	case ListRooms:
+		var payload ListRoomsPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleListRooms(player, msg, payload)
+	case RoomJoin:
+		var payload RoomJoinPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleRoomJoin(player, msg, payload)
+	case RoomInvite:
+		var payload RoomInvitePayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleRoomInvite(player, msg, payload)
+	case TimeSync:
+		var payload TimeSyncPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleTimeSync(player, msg, payload)
+	case Pong:
+		var payload PongPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandlePong(player, msg, payload)
+	case TransferStart:
+		var payload TransferStartPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleTransferStart(player, msg, payload)
+	case TransferChunk:
+		var payload TransferChunkPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleTransferChunk(player, msg, payload)
+	case TransferAbort:
+		var payload TransferAbortPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleTransferAbort(player, msg, payload)
+	case RTCJoin:
+		return h.HandleRTCJoin(player, msg)
+	case RTCLeave:
+		return h.HandleRTCLeave(player, msg)
+	case RTCOffer:
+		var payload RTCSessionPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleRTCOffer(player, msg, payload)
+	case RTCAnswer:
+		var payload RTCSessionPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleRTCAnswer(player, msg, payload)
+	case RTCCandidate:
+		var payload RTCCandidatePayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", msg.Type, err)
+		}
+		return h.HandleRTCCandidate(player, msg, payload)
+	default:
+		return fmt.Errorf("unknown message type %s", msg.Type)
+	}
+}
+
+// SendPlayerJoin sends a PLAYER_JOIN message to one player
+func (gs *GameServer) SendPlayerJoin(playerID string, payload PlayerProfilePayload) error {
+	return gs.SendStructuredMessage(playerID, PlayerJoin, payload)
+}
+
+// SendQueueUpdate sends a QUEUE_UPDATE message to one player
+func (gs *GameServer) SendQueueUpdate(playerID string, payload QueueStatusPayload) error {
+	return gs.SendStructuredMessage(playerID, QueueUpdate, payload)
+}
+
+// SendEntityLeave sends a ENTITY_LEAVE message to one player
+func (gs *GameServer) SendEntityLeave(playerID string, payload EntityLeavePayload) error {
+	return gs.SendStructuredMessage(playerID, EntityLeave, payload)
+}
+
+// SendRoomClosed sends a ROOM_CLOSED message to one player
+func (gs *GameServer) SendRoomClosed(playerID string, payload RoomClosedPayload) error {
+	return gs.SendStructuredMessage(playerID, RoomClosed, payload)
+}
+
+// SendPartyInvite sends a PARTY_INVITE message to one player
+func (gs *GameServer) SendPartyInvite(playerID string, payload PartyInvitePayload) error {
+	return gs.SendStructuredMessage(playerID, PartyInvite, payload)
+}
+
+// SendPartyUpdate sends a PARTY_UPDATE message to one player
+func (gs *GameServer) SendPartyUpdate(playerID string, payload PartyStatePayload) error {
+	return gs.SendStructuredMessage(playerID, PartyUpdate, payload)
+}
+
+// SendPresenceUpdate sends a PRESENCE_UPDATE message to one player
+func (gs *GameServer) SendPresenceUpdate(playerID string, payload PresencePayload) error {
+	return gs.SendStructuredMessage(playerID, PresenceUpdate, payload)
+}
+
+// SendFriendList sends a FRIEND_LIST message to one player
+func (gs *GameServer) SendFriendList(playerID string, payload FriendListPayload) error {
+	return gs.SendStructuredMessage(playerID, FriendList, payload)
+}
+
+// SendDirectMessage sends a DIRECT_MESSAGE message to one player
+func (gs *GameServer) SendDirectMessage(playerID string, payload DirectMessagePayload) error {
+	return gs.SendStructuredMessage(playerID, DirectMessage, payload)
+}
+
+// SendDirectMessageFailed sends a DIRECT_MESSAGE_FAILED message to one player
+func (gs *GameServer) SendDirectMessageFailed(playerID string, payload DirectMessageFailedPayload) error {
+	return gs.SendStructuredMessage(playerID, DirectMessageFailed, payload)
+}
+
+// SendCapabilities sends a CAPABILITIES message to one player
+func (gs *GameServer) SendCapabilities(playerID string, payload CapabilitiesPayload) error {
+	return gs.SendStructuredMessage(playerID, Capabilities, payload)
+}
+
+// SendOnboardingStep sends a ONBOARDING_STEP message to one player
+func (gs *GameServer) SendOnboardingStep(playerID string, payload OnboardingStepPayload) error {
+	return gs.SendStructuredMessage(playerID, OnboardingStep, payload)
+}
+
+// SendOnboardingComplete sends a ONBOARDING_COMPLETE message to one player
+func (gs *GameServer) SendOnboardingComplete(playerID string, payload OnboardingCompletePayload) error {
+	return gs.SendStructuredMessage(playerID, OnboardingComplete, payload)
+}
+
+// SendActionRejected sends a ACTION_REJECTED message to one player
+func (gs *GameServer) SendActionRejected(playerID string, payload ActionRejectedPayload) error {
+	return gs.SendStructuredMessage(playerID, ActionRejected, payload)
+}
+
+// SendCountdown sends a COUNTDOWN message to one player
+func (gs *GameServer) SendCountdown(playerID string, payload CountdownPayload) error {
+	return gs.SendStructuredMessage(playerID, Countdown, payload)
+}
+
+// SendMoveCorrection sends a MOVE_CORRECTION message to one player
+func (gs *GameServer) SendMoveCorrection(playerID string, payload MoveCorrectionPayload) error {
+	return gs.SendStructuredMessage(playerID, MoveCorrection, payload)
+}
+
+// SendSurveyRequest sends a SURVEY_REQUEST message to one player
+func (gs *GameServer) SendSurveyRequest(playerID string, payload SurveyRequestPayload) error {
+	return gs.SendStructuredMessage(playerID, SurveyRequest, payload)
+}
+
+// SendSchemaError sends a SCHEMA_ERROR message to one player
+func (gs *GameServer) SendSchemaError(playerID string, payload SchemaErrorPayload) error {
+	return gs.SendStructuredMessage(playerID, SchemaError, payload)
+}
+
+// SendError sends a ERROR message to one player
+func (gs *GameServer) SendError(playerID string, payload ErrorPayload) error {
+	return gs.SendStructuredMessage(playerID, Error, payload)
+}
+
+// SendWelcome sends a WELCOME message to one player
+func (gs *GameServer) SendWelcome(playerID string, payload WelcomePayload) error {
+	return gs.SendStructuredMessage(playerID, Welcome, payload)
+}
+
+// SendMessageAck sends a MESSAGE_ACK message to one player
+func (gs *GameServer) SendMessageAck(playerID string, payload MessageAckPayload) error {
+	return gs.SendStructuredMessage(playerID, MessageAck, payload)
+}
+
+// SendIdleWarning sends a IDLE_WARNING message to one player
+func (gs *GameServer) SendIdleWarning(playerID string, payload IdleWarningPayload) error {
+	return gs.SendStructuredMessage(playerID, IdleWarning, payload)
+}
+
+// SendJoinAccepted sends a JOIN_ACCEPTED message to one player
+func (gs *GameServer) SendJoinAccepted(playerID string, payload JoinAcceptedPayload) error {
+	return gs.SendStructuredMessage(playerID, JoinAccepted, payload)
+}
+
+// SendJoinRejected sends a JOIN_REJECTED message to one player
+func (gs *GameServer) SendJoinRejected(playerID string, payload JoinRejectedPayload) error {
+	return gs.SendStructuredMessage(playerID, JoinRejected, payload)
+}
+
+// SendSessionList sends a SESSION_LIST message to one player
+func (gs *GameServer) SendSessionList(playerID string, payload SessionListPayload) error {
+	return gs.SendStructuredMessage(playerID, SessionList, payload)
+}
+
+// SendUDPSession sends a UDP_SESSION message to one player
+func (gs *GameServer) SendUDPSession(playerID string, payload UDPSessionPayload) error {
+	return gs.SendStructuredMessage(playerID, UDPSession, payload)
+}
+
+// SendKicked sends a KICKED message to one player
+func (gs *GameServer) SendKicked(playerID string, payload KickedPayload) error {
+	return gs.SendStructuredMessage(playerID, Kicked, payload)
+}
+
+// SendPayloadKey sends a PAYLOAD_KEY message to one player
+func (gs *GameServer) SendPayloadKey(playerID string, payload PayloadKeyPayload) error {
+	return gs.SendStructuredMessage(playerID, PayloadKey, payload)
+}
+
+// SendSessionToken sends a SESSION_TOKEN message to one player
+func (gs *GameServer) SendSessionToken(playerID string, payload SessionTokenPayload) error {
+	return gs.SendStructuredMessage(playerID, SessionToken, payload)
+}
+
+// SendRatingUpdate sends a RATING_UPDATE message to one player
+func (gs *GameServer) SendRatingUpdate(playerID string, payload RatingUpdatePayload) error {
+	return gs.SendStructuredMessage(playerID, RatingUpdate, payload)
+}
+
+// SendMatchmakingStatus sends a MATCHMAKING_STATUS message to one player
+func (gs *GameServer) SendMatchmakingStatus(playerID string, payload MatchmakingStatusPayload) error {
+	return gs.SendStructuredMessage(playerID, MatchmakingStatus, payload)
+}
+
+// SendMatchFound sends a MATCH_FOUND message to one player
+func (gs *GameServer) SendMatchFound(playerID string, payload MatchFoundPayload) error {
+	return gs.SendStructuredMessage(playerID, MatchFound, payload)
+}
+
+// SendTournamentUpdate sends a TOURNAMENT_UPDATE message to one player
+func (gs *GameServer) SendTournamentUpdate(playerID string, payload TournamentPayload) error {
+	return gs.SendStructuredMessage(playerID, TournamentUpdate, payload)
+}
+
+// SendRoomList sends a ROOM_LIST message to one player
+func (gs *GameServer) SendRoomList(playerID string, payload RoomListPayload) error {
+	return gs.SendStructuredMessage(playerID, RoomList, payload)
+}
+
+// SendRoomJoined sends a ROOM_JOINED message to one player
+func (gs *GameServer) SendRoomJoined(playerID string, payload RoomJoinPayload) error {
+	return gs.SendStructuredMessage(playerID, RoomJoined, payload)
+}
+
+// SendRoomJoinRejected sends a ROOM_JOIN_REJECTED message to one player
+func (gs *GameServer) SendRoomJoinRejected(playerID string, payload RoomJoinRejectedPayload) error {
+	return gs.SendStructuredMessage(playerID, RoomJoinRejected, payload)
+}
+
+// SendRoomInvite sends a ROOM_INVITE message to one player
+func (gs *GameServer) SendRoomInvite(playerID string, payload RoomInvitePayload) error {
+	return gs.SendStructuredMessage(playerID, RoomInvite, payload)
+}
+
+// SendAnnouncement sends a ANNOUNCEMENT message to one player
+func (gs *GameServer) SendAnnouncement(playerID string, payload AnnouncementPayload) error {
+	return gs.SendStructuredMessage(playerID, Announcement, payload)
+}
+
+// SendTimeSync sends a TIME_SYNC message to one player
+func (gs *GameServer) SendTimeSync(playerID string, payload TimeSyncPayload) error {
+	return gs.SendStructuredMessage(playerID, TimeSync, payload)
+}
+
+// SendPing sends a PING message to one player
+func (gs *GameServer) SendPing(playerID string, payload PingPayload) error {
+	return gs.SendStructuredMessage(playerID, Ping, payload)
+}
+
+// SendTransferStart sends a TRANSFER_START message to one player
+func (gs *GameServer) SendTransferStart(playerID string, payload TransferStartPayload) error {
+	return gs.SendStructuredMessage(playerID, TransferStart, payload)
+}
+
+// SendTransferChunk sends a TRANSFER_CHUNK message to one player
+func (gs *GameServer) SendTransferChunk(playerID string, payload TransferChunkPayload) error {
+	return gs.SendStructuredMessage(playerID, TransferChunk, payload)
+}
+
+// SendTransferAbort sends a TRANSFER_ABORT message to one player
+func (gs *GameServer) SendTransferAbort(playerID string, payload TransferAbortPayload) error {
+	return gs.SendStructuredMessage(playerID, TransferAbort, payload)
+}
+
+// SendRTCPeers sends a RTC_PEERS message to one player
+func (gs *GameServer) SendRTCPeers(playerID string, payload RTCPeersPayload) error {
+	return gs.SendStructuredMessage(playerID, RTCPeers, payload)
+}
+
+// SendRTCPeerJoined sends a RTC_PEER_JOINED message to one player
+func (gs *GameServer) SendRTCPeerJoined(playerID string, payload RTCPeerPayload) error {
+	return gs.SendStructuredMessage(playerID, RTCPeerJoined, payload)
+}
+
+// SendRTCPeerLeft sends a RTC_PEER_LEFT message to one player
+func (gs *GameServer) SendRTCPeerLeft(playerID string, payload RTCPeerPayload) error {
+	return gs.SendStructuredMessage(playerID, RTCPeerLeft, payload)
+}
+
+// SendRTCOffer sends a RTC_OFFER message to one player
+func (gs *GameServer) SendRTCOffer(playerID string, payload RTCSessionPayload) error {
+	return gs.SendStructuredMessage(playerID, RTCOffer, payload)
+}
+
+// SendRTCAnswer sends a RTC_ANSWER message to one player
+func (gs *GameServer) SendRTCAnswer(playerID string, payload RTCSessionPayload) error {
+	return gs.SendStructuredMessage(playerID, RTCAnswer, payload)
+}
+
+// SendRTCCandidate sends a RTC_CANDIDATE message to one player
+func (gs *GameServer) SendRTCCandidate(playerID string, payload RTCCandidatePayload) error {
+	return gs.SendStructuredMessage(playerID, RTCCandidate, payload)
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/iknizzz1807/socket-server-template/logic"
+)
+
+var ErrActionBudget = errors.New("not enough left to act")
+
+// actionCost is what one message type takes from a meter
+type actionCost struct {
+	meter string
+	cost  float64
+}
+
+// playerMeters holds a player's meters, created full on first use
+type playerMeters struct {
+	mu     sync.Mutex
+	meters map[string]*logic.Meter
+}
+
+// meterLocked returns the named meter, nil if no such meter is configured
+func (gs *GameServer) meterLocked(pm *playerMeters, name string, now time.Time) *logic.Meter {
+	m, ok := pm.meters[name]
+	if !ok {
+		rule, configured := gs.meterRules[name]
+		if !configured {
+			return nil
+		}
+		if pm.meters == nil {
+			pm.meters = make(map[string]*logic.Meter)
+		}
+		m = logic.NewMeter(rule, now)
+		pm.meters[name] = m
+	}
+	return m
+}
+
+// SpendMeter takes cost from one of the player's meters, false when not enough is left
+// Use it for actions the server triggers itself, message costs are enforced automatically.
+func (gs *GameServer) SpendMeter(player *Player, meter string, cost float64) (bool, error) {
+	pm := &player.meters
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	now := time.Now()
+	m := gs.meterLocked(pm, meter, now)
+	if m == nil {
+		return false, fmt.Errorf("unknown meter %s", meter)
+	}
+	return m.Spend(cost, now), nil
+}
+
+// MeterValue returns what the player has left on a meter
+func (gs *GameServer) MeterValue(player *Player, meter string) (float64, error) {
+	pm := &player.meters
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	now := time.Now()
+	m := gs.meterLocked(pm, meter, now)
+	if m == nil {
+		return 0, fmt.Errorf("unknown meter %s", meter)
+	}
+	return m.Value(now), nil
+}
+
+// RefillMeter adds amount to a meter (pickups, potions...), never above its max
+func (gs *GameServer) RefillMeter(player *Player, meter string, amount float64) error {
+	pm := &player.meters
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	now := time.Now()
+	m := gs.meterLocked(pm, meter, now)
+	if m == nil {
+		return fmt.Errorf("unknown meter %s", meter)
+	}
+	m.Add(amount, now)
+	return nil
+}
+
+// chargeAction spends the costs of a message type, all or nothing
+// The player is told which meter ran short so the client can show it.
+func (gs *GameServer) chargeAction(player *Player, msgType MessageType) error {
+	costs := gs.actionCosts[msgType]
+	if len(costs) == 0 {
+		return nil
+	}
+
+	pm := &player.meters
+	pm.mu.Lock()
+	now := time.Now()
+	for _, c := range costs {
+		m := gs.meterLocked(pm, c.meter, now)
+		if value := m.Value(now); value < c.cost {
+			pm.mu.Unlock()
+			gs.SendActionRejected(player.ID, ActionRejectedPayload{Type: msgType, Meter: c.meter, Value: value, Cost: c.cost})
+			return fmt.Errorf("player %s can't send %s, %s is at %.2f of %.2f: %w", player.ID, msgType, c.meter, value, c.cost, ErrActionBudget)
+		}
+	}
+	for _, c := range costs {
+		gs.meterLocked(pm, c.meter, now).Spend(c.cost, now)
+	}
+	pm.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counters are sharded so concurrent connections don't fight over one cache line,
+// and the aggregator folds the shards into a snapshot off the hot path.
+const (
+	metricShards           = 32
+	defaultMetricsInterval = time.Second
+	cacheLinePad           = 56
+)
+
+// paddedCounter takes a full cache line to avoid false sharing between shards
+type paddedCounter struct {
+	v atomic.Uint64
+	_ [cacheLinePad]byte
+}
+
+// counter is a wait-free sharded counter, incrementing never blocks or retries
+type counter struct {
+	shards [metricShards]paddedCounter
+}
+
+func (c *counter) add(shard uint32, n uint64) {
+	c.shards[shard%metricShards].v.Add(n)
+}
+
+func (c *counter) sum() uint64 {
+	var total uint64
+	for i := range c.shards {
+		total += c.shards[i].v.Load()
+	}
+	return total
+}
+
+// MetricsSnapshot is the aggregated view of the counters, refreshed every interval
+type MetricsSnapshot struct {
+	At            time.Time `json:"at"`
+	Players       int       `json:"players"`
+	MessagesIn    uint64    `json:"messages_in"`
+	MessagesOut   uint64    `json:"messages_out"`
+	BytesIn       uint64    `json:"bytes_in"`
+	BytesOut      uint64    `json:"bytes_out"`
+	WriteErrors   uint64    `json:"write_errors"`
+	WriteTimeouts uint64    `json:"write_timeouts"`
+	ProcessErrors uint64    `json:"process_errors"`
+	// MoveViolations counts moves that broke the movement limits
+	MoveViolations uint64 `json:"move_violations"`
+	// SlowConsumers counts players disconnected for not keeping up
+	SlowConsumers uint64 `json:"slow_consumers"`
+	// IdleKicks counts players disconnected by the idle policy
+	IdleKicks uint64 `json:"idle_kicks"`
+	// LeakedPlayers counts players the janitor removed because their read loop was gone
+	LeakedPlayers uint64 `json:"leaked_players"`
+	// Panics counts inbound frames and room goroutine items that panicked, see MessagePanicked
+	Panics uint64 `json:"panics"`
+	// Rates are per second over the last interval
+	MessagesInRate  float64 `json:"messages_in_rate"`
+	MessagesOutRate float64 `json:"messages_out_rate"`
+	// Inbound structured messages that passed the schema check, by type
+	MessagesInByType     map[MessageType]uint64  `json:"messages_in_by_type,omitempty"`
+	MessagesInRateByType map[MessageType]float64 `json:"messages_in_rate_by_type,omitempty"`
+}
+
+type metrics struct {
+	messagesIn     counter
+	messagesOut    counter
+	bytesIn        counter
+	bytesOut       counter
+	writeErrors    counter
+	writeTimeouts  counter
+	processErrors  counter
+	moveViolations counter
+	slowConsumers  counter
+	idleKicks      counter
+	leakedPlayers  counter
+	panics         counter
+	// byType maps a MessageType to its *counter, only known types get one
+	byType sync.Map
+
+	// nextShard hands out shards to new connections round-robin
+	nextShard atomic.Uint32
+	latest    atomic.Pointer[MetricsSnapshot]
+}
+
+func newMetrics() *metrics {
+	m := &metrics{}
+	m.latest.Store(&MetricsSnapshot{At: time.Now()})
+	return m
+}
+
+func (m *metrics) assignShard() uint32 {
+	return m.nextShard.Add(1) % metricShards
+}
+
+func (m *metrics) recordIn(shard uint32, size int) {
+	m.messagesIn.add(shard, 1)
+	m.bytesIn.add(shard, uint64(size))
+}
+
+// recordType counts an inbound message of a type that passed the schema check
+func (m *metrics) recordType(shard uint32, msgType MessageType) {
+	c, ok := m.byType.Load(msgType)
+	if !ok {
+		c, _ = m.byType.LoadOrStore(msgType, new(counter))
+	}
+	c.(*counter).add(shard, 1)
+}
+
+// recordOut counts a written frame, timeouts are counted separately by handleWriteError
+func (m *metrics) recordOut(shard uint32, size int, err error) {
+	if err != nil {
+		m.writeErrors.add(shard, 1)
+		return
+	}
+	m.messagesOut.add(shard, 1)
+	m.bytesOut.add(shard, uint64(size))
+}
+
+// aggregate folds the shards into a new snapshot
+func (m *metrics) aggregate(players int) {
+	prev := m.latest.Load()
+	now := time.Now()
+
+	snap := &MetricsSnapshot{
+		At:             now,
+		Players:        players,
+		MessagesIn:     m.messagesIn.sum(),
+		MessagesOut:    m.messagesOut.sum(),
+		BytesIn:        m.bytesIn.sum(),
+		BytesOut:       m.bytesOut.sum(),
+		WriteErrors:    m.writeErrors.sum(),
+		WriteTimeouts:  m.writeTimeouts.sum(),
+		ProcessErrors:  m.processErrors.sum(),
+		MoveViolations: m.moveViolations.sum(),
+		SlowConsumers:  m.slowConsumers.sum(),
+		IdleKicks:      m.idleKicks.sum(),
+		LeakedPlayers:  m.leakedPlayers.sum(),
+		Panics:         m.panics.sum(),
+	}
+	m.byType.Range(func(k, v interface{}) bool {
+		if snap.MessagesInByType == nil {
+			snap.MessagesInByType = make(map[MessageType]uint64)
+		}
+		snap.MessagesInByType[k.(MessageType)] = v.(*counter).sum()
+		return true
+	})
+	if elapsed := now.Sub(prev.At).Seconds(); elapsed > 0 {
+		snap.MessagesInRate = float64(snap.MessagesIn-prev.MessagesIn) / elapsed
+		snap.MessagesOutRate = float64(snap.MessagesOut-prev.MessagesOut) / elapsed
+		for msgType, n := range snap.MessagesInByType {
+			if snap.MessagesInRateByType == nil {
+				snap.MessagesInRateByType = make(map[MessageType]float64)
+			}
+			snap.MessagesInRateByType[msgType] = float64(n-prev.MessagesInByType[msgType]) / elapsed
+		}
+	}
+	m.latest.Store(snap)
+}
+
+// runMetricsAggregator refreshes the metrics snapshot every interval until the server shuts down
+func (gs *GameServer) runMetricsAggregator(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			gs.metrics.aggregate(gs.players.len())
+		case <-gs.stop:
+			return
+		}
+	}
+}
+
+// Metrics returns the latest aggregated snapshot, it's at most one interval old
+func (gs *GameServer) Metrics() MetricsSnapshot {
+	return *gs.metrics.latest.Load()
+}
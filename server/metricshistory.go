@@ -0,0 +1,158 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/iknizzz1807/socket-server-template/database"
+)
+
+// Metrics history saves a snapshot every so often, so small deployments get trends
+// without running Prometheus. The live view is still Metrics().
+
+const (
+	defaultMetricsHistoryInterval = time.Minute
+	defaultMetricsHistoryRange    = time.Hour
+	// A week of the default interval, what metrics_history_file keeps
+	defaultMetricsHistoryPoints = 7 * 24 * 60
+)
+
+var ErrMetricsHistoryNotEnabled = errors.New("metrics history is not enabled")
+
+type metricsHistory struct {
+	store    database.MetricsStore
+	interval time.Duration
+}
+
+// recordMetricsHistory saves a point every interval until the server shuts down
+func (gs *GameServer) recordMetricsHistory() {
+	ticker := time.NewTicker(gs.metricsHistory.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := gs.metricsHistory.store.SaveMetrics(gs.metricsPoint()); err != nil {
+				log.Printf("Error saving metrics snapshot: %v", err)
+			}
+		case <-gs.stop:
+			return
+		}
+	}
+}
+
+func (gs *GameServer) metricsPoint() database.MetricsPoint {
+	snap := gs.Metrics()
+
+	gs.roomsMu.RLock()
+	rooms := len(gs.rooms)
+	gs.roomsMu.RUnlock()
+
+	return database.MetricsPoint{
+		At:              snap.At,
+		NodeID:          gs.nodeID,
+		Players:         snap.Players,
+		Rooms:           rooms,
+		MessagesInRate:  snap.MessagesInRate,
+		MessagesOutRate: snap.MessagesOutRate,
+		BytesIn:         snap.BytesIn,
+		BytesOut:        snap.BytesOut,
+		WriteErrors:     snap.WriteErrors,
+		ProcessErrors:   snap.ProcessErrors,
+	}
+}
+
+// MetricsHistory returns the saved points in [from, to], thinned out to at most one per step
+// when step is positive
+func (gs *GameServer) MetricsHistory(from, to time.Time, step time.Duration) ([]database.MetricsPoint, error) {
+	if gs.metricsHistory == nil {
+		return nil, ErrMetricsHistoryNotEnabled
+	}
+	points, err := gs.metricsHistory.store.QueryMetrics(from, to)
+	if err != nil || step <= 0 {
+		return points, err
+	}
+
+	// Keep the last point of every bucket, counters are cumulative so nothing is lost
+	var thinned []database.MetricsPoint
+	for _, p := range points {
+		bucket := p.At.Truncate(step)
+		if n := len(thinned); n > 0 && thinned[n-1].At.Truncate(step).Equal(bucket) {
+			thinned[n-1] = p
+			continue
+		}
+		thinned = append(thinned, p)
+	}
+	return thinned, nil
+}
+
+// MetricsHistoryHandler is an admin endpoint for dashboards returning saved metrics as JSON, e.g.
+//
+//	GET /admin/metrics/history?since=6h&step=5m
+//	GET /admin/metrics/history?from=2024-01-01T00:00:00Z&to=2024-01-02T00:00:00Z
+//
+// since defaults to the last hour. It has no authentication, StartAdmin mounts it behind
+// the admin token.
+func (gs *GameServer) MetricsHistoryHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		from, to, step, err := parseHistoryQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		points, err := gs.MetricsHistory(from, to, step)
+		if errors.Is(err, ErrMetricsHistoryNotEnabled) {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(points); err != nil {
+			log.Printf("Error writing metrics history: %v", err)
+		}
+	})
+}
+
+func parseHistoryQuery(r *http.Request) (from, to time.Time, step time.Duration, err error) {
+	q := r.URL.Query()
+	to = time.Now()
+	from = to.Add(-defaultMetricsHistoryRange)
+
+	if v := q.Get("since"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return from, to, 0, fmt.Errorf("invalid since: %v", err)
+		}
+		from = to.Add(-d)
+	}
+	if v := q.Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, 0, fmt.Errorf("invalid from: %v", err)
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, 0, fmt.Errorf("invalid to: %v", err)
+		}
+	}
+	if v := q.Get("step"); v != "" {
+		if step, err = time.ParseDuration(v); err != nil {
+			return from, to, 0, fmt.Errorf("invalid step: %v", err)
+		}
+	}
+	return from, to, step, nil
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/iknizzz1807/socket-server-template/database"
+)
+
+func TestAdminMetricsHistory(t *testing.T) {
+	admin := requireToken("secret", NewGameServer(10).adminHandler())
+	if code := adminRequest(admin, http.MethodGet, "/admin/metrics/history", "secret"); code != http.StatusNotImplemented {
+		t.Fatalf("history without a store: %d", code)
+	}
+
+	path := filepath.Join(t.TempDir(), "metrics.jsonl")
+	cfg := DefaultConfig()
+	cfg.MetricsHistoryFile = path
+	gs := NewGameServerFromConfig(cfg)
+	if err := gs.metricsHistory.store.SaveMetrics(gs.metricsPoint()); err != nil {
+		t.Fatal(err)
+	}
+	gs.metricsHistory.store.(*database.FileMetricsStore).Close()
+
+	// A restarted server still has the point
+	restarted := NewGameServerFromConfig(cfg)
+	admin = requireToken("secret", restarted.adminHandler())
+	if code := adminRequest(admin, http.MethodGet, "/admin/metrics/history", ""); code != http.StatusUnauthorized {
+		t.Fatalf("history without token: %d", code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/admin/metrics/history?since=1h", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	admin.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("history: %d %s", rec.Code, rec.Body)
+	}
+	var points []database.MetricsPoint
+	if err := json.Unmarshal(rec.Body.Bytes(), &points); err != nil || len(points) != 1 {
+		t.Fatalf("history after restart: %v %s", err, rec.Body)
+	}
+}
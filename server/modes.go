@@ -0,0 +1,127 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrUnknownMode = errors.New("unknown game mode")
+	ErrRoomFull    = errors.New("room is full")
+)
+
+// ModeProfile is the tuning of one game mode, rooms get theirs when they are created
+// so modes with different settings can run side by side on one server.
+// Zero values fall back to the server-wide settings.
+type ModeProfile struct {
+	// TickRate is used by StartTicker when no interval is given
+	TickRate int `json:"tick_rate" yaml:"tick_rate"`
+	// MaxPlayers caps room members, 0 means no cap
+	MaxPlayers int `json:"max_players" yaml:"max_players"`
+	// AllowedMessages restricts which gameplay messages players in the room may send,
+	// empty allows all of them. Non-gameplay messages (chat, parties...) are not affected.
+	AllowedMessages []MessageType `json:"allowed_messages" yaml:"allowed_messages"`
+	// Timers are named durations the mode's code looks up with Room.Timer, e.g. "round" or "warmup"
+	Timers map[string]Duration `json:"timers" yaml:"timers"`
+	// MinFreeRooms is how many listed rooms of the mode with free slots the room manager
+	// keeps open, creating new ones as they fill. See WithRoomManager.
+	MinFreeRooms int `json:"min_free_rooms" yaml:"min_free_rooms"`
+}
+
+func (p ModeProfile) validate() error {
+	if p.TickRate < 0 || p.TickRate > 1000 {
+		return fmt.Errorf("tick_rate must be between 0 and 1000, got %d", p.TickRate)
+	}
+	if p.MaxPlayers < 0 {
+		return fmt.Errorf("max_players can't be negative")
+	}
+	if p.MinFreeRooms < 0 {
+		return fmt.Errorf("min_free_rooms can't be negative")
+	}
+	for _, t := range p.AllowedMessages {
+		if !gameplayMessages[t] {
+			return fmt.Errorf("%s is not a gameplay message type", t)
+		}
+	}
+	for name, d := range p.Timers {
+		if d <= 0 {
+			return fmt.Errorf("timer %s must be positive", name)
+		}
+	}
+	return nil
+}
+
+// roomProfile is the compiled profile a room keeps for its whole life
+type roomProfile struct {
+	ModeProfile
+	allowed map[MessageType]bool
+}
+
+func newRoomProfile(p ModeProfile) *roomProfile {
+	rp := &roomProfile{ModeProfile: p}
+	if len(p.AllowedMessages) > 0 {
+		rp.allowed = make(map[MessageType]bool, len(p.AllowedMessages))
+		for _, t := range p.AllowedMessages {
+			rp.allowed[t] = true
+		}
+	}
+	return rp
+}
+
+// CreateRoomWithMode creates a room tuned by the named mode profile
+// Later config reloads only affect rooms created afterwards.
+func (gs *GameServer) CreateRoomWithMode(id, mode string) (*Room, error) {
+	gs.roomsMu.RLock()
+	profile, ok := gs.modes[mode]
+	gs.roomsMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMode, mode)
+	}
+	return gs.createRoom(id, mode, newRoomProfile(profile))
+}
+
+// SetModes replaces the mode profiles new rooms can be created with
+func (gs *GameServer) SetModes(modes map[string]ModeProfile) error {
+	for name, p := range modes {
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("mode %s: %v", name, err)
+		}
+	}
+
+	gs.roomsMu.Lock()
+	gs.modes = modes
+	gs.roomsMu.Unlock()
+	return nil
+}
+
+// Timer returns a named duration of the room's mode, false when the mode doesn't define it
+func (r *Room) Timer(name string) (time.Duration, bool) {
+	if r.profile == nil {
+		return 0, false
+	}
+	d, ok := r.profile.Timers[name]
+	return time.Duration(d), ok
+}
+
+// AfterTimer runs fn once the named mode timer elapses, see AfterFunc
+func (r *Room) AfterTimer(name string, fn func()) error {
+	d, ok := r.Timer(name)
+	if !ok {
+		return fmt.Errorf("mode %q of room %s has no %s timer", r.Mode(), r.ID, name)
+	}
+	return r.AfterFunc(d, fn)
+}
+
+// checkModeMessage rejects gameplay messages the player's room mode doesn't allow
+func checkModeMessage(player *Player, msgType MessageType) error {
+	room := player.room.Load()
+	if room == nil || room.profile == nil || room.profile.allowed == nil {
+		return nil
+	}
+	if gameplayMessages[msgType] && !room.profile.allowed[msgType] {
+		return Reject(ErrorUnauthorized, fmt.Errorf("%s is not allowed in mode %q of room %s", msgType, room.Mode(), room.ID))
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/iknizzz1807/socket-server-template/logic"
+)
+
+// MoveAction is what happens to a move that broke the movement limits
+type MoveAction int
+
+const (
+	// MoveAllow lets the move through anyway, e.g. to only log suspicious players at first
+	MoveAllow MoveAction = iota
+	// MoveReject drops the move silently
+	MoveReject
+	// MoveRubberBand drops the move and sends the player back to its authoritative position
+	MoveRubberBand
+	// MoveKick disconnects the player
+	MoveKick
+)
+
+// MoveViolationHandler decides what to do about a bad move, it runs on the player's read goroutine
+type MoveViolationHandler func(player *Player, v logic.MoveViolation) MoveAction
+
+// RubberBandViolations logs the violation and snaps the player back, the default handler
+func RubberBandViolations(player *Player, v logic.MoveViolation) MoveAction {
+	log.Printf("Player %s %s violation: moved %.2f of %.2f allowed in %v", player.ID, v.Kind, v.Distance, v.Allowed, v.Elapsed)
+	return MoveRubberBand
+}
+
+type movementValidator struct {
+	limits  logic.MoveLimits
+	handler MoveViolationHandler
+}
+
+// moveState is the player's authoritative position
+type moveState struct {
+	mu    sync.Mutex
+	pos   logic.Vec2
+	at    time.Time
+	known bool
+}
+
+// Position returns the player's last accepted position, false before the first one
+func (p *Player) Position() (logic.Vec2, bool) {
+	p.move.mu.Lock()
+	defer p.move.mu.Unlock()
+	return p.move.pos, p.move.known
+}
+
+// SetPosition moves the player on the server's authority (spawns, teleporters...),
+// the next client move is validated from there
+func (p *Player) SetPosition(pos logic.Vec2) {
+	p.move.mu.Lock()
+	defer p.move.mu.Unlock()
+	p.move.pos, p.move.at, p.move.known = pos, time.Now(), true
+}
+
+// applyMove puts an accepted position into the room's authoritative state
+func (gs *GameServer) applyMove(player *Player, pos logic.Vec2) {
+	if room := player.room.Load(); room != nil {
+		room.Entities().movePlayer(player.ID, pos)
+		room.followMove(player, pos)
+	}
+}
+
+// validateMove checks a claimed move against the limits, false means it must not be applied
+func (gs *GameServer) validateMove(player *Player, to logic.Vec2, seq uint64) bool {
+	mv := gs.movement
+	if mv == nil {
+		// Nothing to check, the move is taken as is
+		player.SetPosition(to)
+		gs.applyMove(player, to)
+		return true
+	}
+
+	player.move.mu.Lock()
+	now := time.Now()
+	from, known := player.move.pos, player.move.known
+	var violation logic.MoveViolation
+	var bad bool
+	if known {
+		violation, bad = mv.limits.CheckMove(from, to, now.Sub(player.move.at))
+	} else {
+		violation, bad = mv.limits.CheckPosition(to)
+	}
+	if !bad {
+		player.move.pos, player.move.at, player.move.known = to, now, true
+	}
+	player.move.mu.Unlock()
+
+	if !bad {
+		gs.applyMove(player, to)
+		return true
+	}
+
+	gs.metrics.moveViolations.add(player.metricShard, 1)
+	switch mv.handler(player, violation) {
+	case MoveAllow:
+		player.SetPosition(to)
+		gs.applyMove(player, to)
+		return true
+
+	case MoveRubberBand:
+		if known {
+			correction := MoveCorrectionPayload{X: from.X, Y: from.Y, Seq: seq, Reason: string(violation.Kind)}
+			if err := gs.SendMoveCorrection(player.ID, correction); err != nil {
+				log.Printf("Error sending move correction to player %s: %v", player.ID, err)
+			}
+		}
+
+	case MoveKick:
+		gs.disconnectWithReason(player, ReasonKicked.WithDetail("invalid movement"))
+	}
+	return false
+}
@@ -0,0 +1,324 @@
+package server
+
+import (
+	"container/heap"
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Network simulation makes local connections behave like bad real ones, so games can be
+// tried under lag before they ship. Every player's transport is wrapped: frames in each
+// direction are held back by the latency plus or minus the jitter, some are dropped and
+// some are held back longer so later frames overtake them. It's for development only.
+//
+// Writes return as soon as the frame is queued, a failed delayed write fails the next
+// WriteFrame. Close is delayed like a frame, so messages sent right before a kick still
+// arrive first. The UDP channel and WebTransport datagrams bypass the simulation,
+// SendUnreliable falls back to regular (simulated) frames.
+
+// simReorderHold is how long reordered frames are held back without latency or jitter
+const simReorderHold = 50 * time.Millisecond
+
+// simCloseGrace bounds how long a delayed close waits behind a blocked write
+const simCloseGrace = time.Second
+
+var errSimClosed = errors.New("connection closed")
+
+// NetConditions describe one direction of a simulated connection
+type NetConditions struct {
+	// Latency delays every frame
+	Latency time.Duration
+	// Jitter varies the latency by up to that much either way
+	Jitter time.Duration
+	// Drop is the probability of losing a frame, 0 to 1
+	Drop float64
+	// Reorder is the probability of holding a frame back so later frames overtake it, 0 to 1
+	Reorder float64
+}
+
+// NetSimConfig configures network simulation, see netsim.go
+type NetSimConfig struct {
+	// Inbound applies to frames from the clients
+	Inbound NetConditions
+	// Outbound applies to frames to the clients
+	Outbound NetConditions
+	// Seed makes the drops and delays repeatable, a random seed per connection when 0
+	Seed int64
+}
+
+func (c NetConditions) validate() error {
+	switch {
+	case c.Latency < 0 || c.Jitter < 0:
+		return errors.New("simulated latency and jitter can't be negative")
+	case c.Drop < 0 || c.Drop > 1 || c.Reorder < 0 || c.Reorder > 1:
+		return errors.New("simulated drop and reorder rates must be between 0 and 1")
+	}
+	return nil
+}
+
+// simFrame is a frame on its way. A final frame closes the connection with code and
+// data as the reason, or ends the inbound stream with err.
+type simFrame struct {
+	due         time.Time
+	order       uint64
+	messageType int
+	data        []byte
+	// timeout is what was left of the write deadline when the frame was written
+	timeout time.Duration
+	final   bool
+	code    int
+	err     error
+}
+
+// simQueue is a heap of frames by due time, in arrival order when due together
+type simQueue []*simFrame
+
+func (q simQueue) Len() int { return len(q) }
+func (q simQueue) Less(i, j int) bool {
+	if q[i].due.Equal(q[j].due) {
+		return q[i].order < q[j].order
+	}
+	return q[i].due.Before(q[j].due)
+}
+func (q simQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *simQueue) Push(x interface{}) { *q = append(*q, x.(*simFrame)) }
+func (q *simQueue) Pop() interface{} {
+	old := *q
+	f := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return f
+}
+
+// simLink delays the frames of one direction and hands them to deliver in due order
+type simLink struct {
+	cond    NetConditions
+	deliver func(*simFrame)
+
+	mu     sync.Mutex
+	rng    *rand.Rand
+	frames simQueue
+	order  uint64
+	// last is when the last frame in order is due, frames that aren't reordered never
+	// overtake it
+	last time.Time
+	wake chan struct{}
+	stop chan struct{}
+}
+
+func newSimLink(cond NetConditions, seed int64, stop chan struct{}, deliver func(*simFrame)) *simLink {
+	l := &simLink{
+		cond:    cond,
+		deliver: deliver,
+		rng:     rand.New(rand.NewSource(seed)),
+		wake:    make(chan struct{}, 1),
+		stop:    stop,
+	}
+	go l.run()
+	return l
+}
+
+// add schedules f, false when the network lost it. Final frames are never lost or
+// reordered.
+func (l *simLink) add(f *simFrame) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !f.final && l.cond.Drop > 0 && l.rng.Float64() < l.cond.Drop {
+		return false
+	}
+	delay := l.cond.Latency
+	if l.cond.Jitter > 0 {
+		delay += time.Duration(l.rng.Int63n(int64(2*l.cond.Jitter)+1)) - l.cond.Jitter
+	}
+	f.due = time.Now().Add(max(delay, 0))
+
+	if !f.final && l.cond.Reorder > 0 && l.rng.Float64() < l.cond.Reorder {
+		hold := l.cond.Latency + l.cond.Jitter
+		if hold == 0 {
+			hold = simReorderHold
+		}
+		f.due = f.due.Add(hold)
+	} else {
+		if f.due.Before(l.last) {
+			f.due = l.last
+		}
+		l.last = f.due
+	}
+	l.order++
+	f.order = l.order
+	heap.Push(&l.frames, f)
+
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// run delivers the frames as they come due, until the transport is closed
+func (l *simLink) run() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		l.mu.Lock()
+		var next *simFrame
+		wait := time.Hour
+		if len(l.frames) > 0 {
+			if wait = time.Until(l.frames[0].due); wait <= 0 {
+				next = heap.Pop(&l.frames).(*simFrame)
+			}
+		}
+		l.mu.Unlock()
+
+		if next != nil {
+			l.deliver(next)
+			if next.final {
+				return
+			}
+			continue
+		}
+
+		timer.Reset(wait)
+		select {
+		case <-timer.C:
+		case <-l.wake:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// simTransport wraps a player's transport with simulated network conditions
+type simTransport struct {
+	inner Transport
+	in    *frameQueue
+	out   *simLink
+
+	// writeErr is the first failed delayed write
+	writeErr  atomic.Pointer[error]
+	closing   atomic.Bool
+	closeOnce sync.Once
+	stop      chan struct{}
+}
+
+func newSimTransport(t Transport, cfg NetSimConfig) *simTransport {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	s := &simTransport{inner: t, in: newFrameQueue(), stop: make(chan struct{})}
+	s.out = newSimLink(cfg.Outbound, seed, s.stop, s.write)
+	// The inbound link runs until the end of the stream, which follows the close
+	in := newSimLink(cfg.Inbound, seed+1, nil, s.receive)
+	go s.readLoop(in)
+	return s
+}
+
+// readLoop reads from the real connection for the read loop of the player, which reads
+// the delayed frames through ReadFrame
+func (s *simTransport) readLoop(in *simLink) {
+	for {
+		messageType, data, err := s.inner.ReadFrame(time.Time{})
+		if err != nil {
+			in.add(&simFrame{final: true, err: err})
+			return
+		}
+		in.add(&simFrame{messageType: messageType, data: data})
+	}
+}
+
+func (s *simTransport) receive(f *simFrame) {
+	if f.final {
+		s.in.finish(f.err)
+		return
+	}
+	s.in.push(f.messageType, f.data, s.stop)
+}
+
+func (s *simTransport) write(f *simFrame) {
+	if f.final {
+		s.closeInner(f.code, string(f.data))
+		return
+	}
+	if s.writeErr.Load() != nil {
+		return
+	}
+	// The frame waited in the simulated network, not in the socket
+	var deadline time.Time
+	if f.timeout > 0 {
+		deadline = time.Now().Add(f.timeout)
+	}
+	if err := s.inner.WriteFrame(f.messageType, f.data, deadline); err != nil {
+		s.writeErr.CompareAndSwap(nil, &err)
+	}
+}
+
+func (s *simTransport) ReadFrame(deadline time.Time) (int, []byte, error) {
+	return s.in.read(deadline)
+}
+
+func (s *simTransport) WriteFrame(messageType int, data []byte, deadline time.Time) error {
+	if err := s.writeErr.Load(); err != nil {
+		return *err
+	}
+	if s.closing.Load() {
+		return errSimClosed
+	}
+	// Copy since callers reuse buffers for the next player
+	f := &simFrame{messageType: messageType, data: append([]byte(nil), data...)}
+	if !deadline.IsZero() {
+		f.timeout = max(time.Until(deadline), time.Millisecond)
+	}
+	s.out.add(f)
+	return nil
+}
+
+// Close is delayed behind the frames already written, or by simCloseGrace at most
+func (s *simTransport) Close(code int, reason string) error {
+	if s.closing.Swap(true) {
+		return nil
+	}
+	s.out.add(&simFrame{final: true, code: code, data: []byte(reason)})
+	wait := s.out.cond.Latency + s.out.cond.Jitter + simCloseGrace
+	time.AfterFunc(wait, func() { s.closeInner(code, reason) })
+	return nil
+}
+
+func (s *simTransport) closeInner(code int, reason string) {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		s.inner.Close(code, reason)
+	})
+}
+
+func (s *simTransport) RemoteAddr() net.Addr {
+	return s.inner.RemoteAddr()
+}
+
+// baseTransport is the real connection under a simulated one
+func baseTransport(t Transport) Transport {
+	if s, ok := t.(*simTransport); ok {
+		return s.inner
+	}
+	return t
+}
+
+// simulateNetwork wraps the transport of a new connection when simulation is on
+func (gs *GameServer) simulateNetwork(t Transport) Transport {
+	if gs.netsim == nil {
+		return t
+	}
+	// Bots are in process, there is no network to simulate
+	if _, bot := t.(*botLink); bot {
+		return t
+	}
+	return newSimTransport(t, *gs.netsim)
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/iknizzz1807/socket-server-template/database"
+)
+
+// Onboarding steps, in their default order
+const (
+	OnboardingTerms    = "terms"
+	OnboardingName     = "name"
+	OnboardingTutorial = "tutorial"
+)
+
+var ErrOnboardingPending = errors.New("onboarding not finished")
+
+// OnboardingConfig describes the sequence new accounts go through before they can play
+type OnboardingConfig struct {
+	// Steps in the order they run, empty means terms, name, tutorial
+	Steps []string
+	// TermsVersion is stored with the acceptance, changing it asks everyone to accept again
+	TermsVersion string
+	TermsURL     string
+	// ValidateName checks a picked display name, nil allows 3 to 20 printable characters
+	ValidateName func(name string) error
+	// TutorialRoom returns the room a new player is put in for the tutorial, nil skips that step
+	// The player finishes the step by replying once the tutorial is done.
+	TutorialRoom func(player *Player) (*Room, error)
+}
+
+type onboarding struct {
+	store database.OnboardingStore
+	cfg   OnboardingConfig
+}
+
+// onboardingState is a player's progress, only used by the goroutine handling the player
+type onboardingState struct {
+	record database.OnboardingRecord
+	step   string
+	roomID string
+}
+
+// startOnboarding sends the first unfinished step, returning players that finished everything skip it
+func (gs *GameServer) startOnboarding(player *Player) {
+	if gs.onboarding == nil {
+		return
+	}
+
+	record, found, err := gs.onboarding.store.LoadOnboarding(player.AccountID)
+	if err != nil {
+		// Don't lock players out of the game because storage is down
+		log.Printf("Error loading onboarding of account %s, skipping it: %v", player.AccountID, err)
+		return
+	}
+	if !found {
+		record = database.OnboardingRecord{AccountID: player.AccountID}
+	}
+
+	state := &onboardingState{record: record}
+	state.step = gs.onboarding.nextStep(state.record)
+	if state.step == "" {
+		return
+	}
+
+	player.onboarding = state
+	gs.enterOnboardingStep(player, state, "")
+}
+
+// nextStep returns the first step the account hasn't finished, "" when there is none
+func (o *onboarding) nextStep(record database.OnboardingRecord) string {
+	for _, step := range o.cfg.Steps {
+		done := false
+		for _, d := range record.Done {
+			done = done || d == step
+		}
+
+		switch {
+		case step == OnboardingTerms && record.AcceptedTermsVersion != o.cfg.TermsVersion:
+			// New terms have to be accepted again
+		case step == OnboardingTutorial && o.cfg.TutorialRoom == nil:
+			continue
+		case done:
+			continue
+		}
+		return step
+	}
+	return ""
+}
+
+// enterOnboardingStep tells the player about the current step, errMsg explains a rejected reply
+func (gs *GameServer) enterOnboardingStep(player *Player, state *onboardingState, errMsg string) {
+	payload := OnboardingStepPayload{Step: state.step, Error: errMsg}
+
+	switch state.step {
+	case OnboardingTerms:
+		payload.TermsVersion = gs.onboarding.cfg.TermsVersion
+		payload.TermsURL = gs.onboarding.cfg.TermsURL
+
+	case OnboardingTutorial:
+		if state.roomID == "" {
+			room, err := gs.onboarding.cfg.TutorialRoom(player)
+			if err == nil {
+				err = room.Join(player)
+			}
+			if err != nil {
+				log.Printf("Error assigning tutorial room to player %s, skipping the tutorial: %v", player.ID, err)
+				gs.finishOnboardingStep(player, state)
+				return
+			}
+			state.roomID = room.ID
+		}
+		payload.RoomID = state.roomID
+	}
+
+	if err := gs.SendOnboardingStep(player.ID, payload); err != nil {
+		log.Printf("Error sending onboarding step to player %s: %v", player.ID, err)
+	}
+}
+
+// finishOnboardingStep persists the finished step and moves on to the next one
+func (gs *GameServer) finishOnboardingStep(player *Player, state *onboardingState) {
+	state.record.Done = append(state.record.Done, state.step)
+	state.step = gs.onboarding.nextStep(state.record)
+	state.record.Completed = state.step == ""
+	state.record.UpdatedAt = time.Now()
+
+	// Saved after every step so a player who disconnects halfway resumes where they left off
+	if err := gs.onboarding.store.SaveOnboarding(state.record); err != nil {
+		log.Printf("Error saving onboarding of account %s: %v", player.AccountID, err)
+	}
+
+	if !state.record.Completed {
+		gs.enterOnboardingStep(player, state, "")
+		return
+	}
+
+	player.onboarding = nil
+	log.Printf("Player %s finished onboarding", player.ID)
+	if err := gs.SendOnboardingComplete(player.ID, OnboardingCompletePayload{DisplayName: state.record.DisplayName}); err != nil {
+		log.Printf("Error sending onboarding completion to player %s: %v", player.ID, err)
+	}
+}
+
+func (gs *GameServer) handleOnboardingReply(player *Player, msg StructuredMessage) error {
+	state := player.onboarding
+	if state == nil {
+		return fmt.Errorf("player %s has no onboarding in progress", player.ID)
+	}
+
+	var payload OnboardingReplyPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid onboarding reply: %v", err)
+	}
+
+	// Rejected replies resend the current step with the reason, the player just tries again
+	if payload.Step != state.step {
+		gs.enterOnboardingStep(player, state, fmt.Sprintf("expected a reply to the %s step", state.step))
+		return nil
+	}
+
+	switch state.step {
+	case OnboardingTerms:
+		if !payload.Accept {
+			gs.enterOnboardingStep(player, state, "the terms have to be accepted to play")
+			return nil
+		}
+		state.record.AcceptedTermsVersion = gs.onboarding.cfg.TermsVersion
+
+	case OnboardingName:
+		name := strings.TrimSpace(payload.Name)
+		if err := gs.onboarding.cfg.ValidateName(name); err != nil {
+			gs.enterOnboardingStep(player, state, err.Error())
+			return nil
+		}
+		state.record.DisplayName = name
+	}
+
+	gs.finishOnboardingStep(player, state)
+	return nil
+}
+
+// checkOnboardingMessage rejects gameplay messages until onboarding is done
+func checkOnboardingMessage(player *Player, msgType MessageType) error {
+	if player.onboarding != nil && gameplayMessages[msgType] {
+		return fmt.Errorf("player %s can't send %s yet: %w", player.ID, msgType, ErrOnboardingPending)
+	}
+	return nil
+}
+
+func defaultValidateName(name string) error {
+	if n := utf8.RuneCountInString(name); n < 3 || n > 20 {
+		return fmt.Errorf("names must be 3 to 20 characters long")
+	}
+	for _, r := range name {
+		if !unicode.IsPrint(r) {
+			return fmt.Errorf("names can't contain control characters")
+		}
+	}
+	return nil
+}
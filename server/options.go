@@ -0,0 +1,714 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/iknizzz1807/socket-server-template/database"
+	"github.com/iknizzz1807/socket-server-template/logic"
+)
+
+// Option customizes a GameServer at construction time
+// Pass any number of them to NewGameServer, e.g. NewGameServer(100, WithWaitingQueue(50))
+type Option func(*GameServer)
+
+// WithWaitingQueue puts connections in a waiting queue when the server is full
+// instead of rejecting them. maxSize <= 0 means the queue has no limit.
+func WithWaitingQueue(maxSize int) Option {
+	return func(gs *GameServer) {
+		gs.queue = newWaitingQueue(maxSize)
+	}
+}
+
+// WithInterestCellSize sets the spatial grid cell size used for area-of-interest filtering
+// Pick something close to the usual view radius of a player, it is the default radius and
+// radii are capped at 32 cells.
+func WithInterestCellSize(cellSize float64) Option {
+	return func(gs *GameServer) {
+		if cellSize > 0 && !math.IsInf(cellSize, 0) {
+			gs.interestCellSize = cellSize
+		}
+	}
+}
+
+// WithReservedSlots gives slot classes extra capacity on top of maxPlayers,
+// e.g. WithReservedSlots(map[SlotClass]int{SlotAdmin: 2, SlotPremium: 10})
+func WithReservedSlots(reserved map[SlotClass]int) Option {
+	return func(gs *GameServer) {
+		for class, n := range reserved {
+			gs.reservedSlots[class] = n
+		}
+	}
+}
+
+// WithSlotClassifier sets how incoming connections are mapped to slot classes
+// Without it every connection is SlotRegular
+func WithSlotClassifier(classifier SlotClassifier) Option {
+	return func(gs *GameServer) {
+		gs.slotClassifier = classifier
+	}
+}
+
+// WithLagCompensation sets how many ticks of history each room keeps and how far back actions
+// may be rewound
+// historySize should cover maxRewind at your tick rate, e.g. 20 ticks/s * 1s = 20
+func WithLagCompensation(historySize int, maxRewind time.Duration) Option {
+	return func(gs *GameServer) {
+		gs.historySize = historySize
+		gs.history = logic.NewStateHistory(historySize)
+		gs.maxRewind = maxRewind
+	}
+}
+
+// WithResultStore sets where room results are persisted when a room closes
+func WithResultStore(store database.ResultStore) Option {
+	return func(gs *GameServer) {
+		gs.resultStore = store
+	}
+}
+
+// WithAckedMessageTypes adds outbound message types that get the recipient's last
+// processed input sequence attached as Ack. GAME_STATE_SYNC is always included.
+func WithAckedMessageTypes(types ...MessageType) Option {
+	return func(gs *GameServer) {
+		for _, t := range types {
+			gs.ackedTypes[t] = true
+		}
+	}
+}
+
+// WithRoomQuota sets the quota every new room starts with, rooms can override it with SetQuota
+func WithRoomQuota(q RoomQuota) Option {
+	return func(gs *GameServer) {
+		gs.defaultRoomQuota = q
+	}
+}
+
+// WithSpectators allows up to maxSpectators watch-only connections on /spectate,
+// on top of maxPlayers. Spectators get broadcasts and chat but can't send gameplay messages.
+func WithSpectators(maxSpectators int) Option {
+	return func(gs *GameServer) {
+		gs.maxSpectators = maxSpectators
+	}
+}
+
+// WithMaxPartySize caps the number of players per party, 0 means no limit
+func WithMaxPartySize(size int) Option {
+	return func(gs *GameServer) {
+		gs.maxPartySize = size
+	}
+}
+
+// WithFriendStore enables friend lists and PRESENCE_UPDATE pushes to friends
+func WithFriendStore(store database.FriendStore) Option {
+	return func(gs *GameServer) {
+		gs.friendStore = store
+	}
+}
+
+// WithAccountResolver sets how connections are mapped to accounts
+func WithAccountResolver(resolver AccountResolver) Option {
+	return func(gs *GameServer) {
+		gs.accountResolver = resolver
+	}
+}
+
+// WithBlockStore enables block lists, blocked accounts can't send direct messages
+func WithBlockStore(store database.BlockStore) Option {
+	return func(gs *GameServer) {
+		gs.blockStore = store
+	}
+}
+
+// WithOfflineMessages queues up to maxPerAccount direct messages for offline accounts
+// and delivers them on reconnect. Without it messages to offline accounts fail right away.
+func WithOfflineMessages(maxPerAccount int) Option {
+	return func(gs *GameServer) {
+		gs.offlineMessages = newOfflineMessages(maxPerAccount)
+	}
+}
+
+// WithCompression enables permessage-deflate for clients that support it
+// level is a compress/flate level (1 = fastest, 9 = best), frames smaller than
+// threshold bytes are sent uncompressed, 0 uses the default of 512 bytes.
+func WithCompression(level, threshold int) Option {
+	return func(gs *GameServer) {
+		if err := validateCompressionLevel(level); err != nil {
+			log.Printf("Compression disabled: %v", err)
+			return
+		}
+		if threshold <= 0 {
+			threshold = defaultCompressionThreshold
+		}
+		gs.upgrader.EnableCompression = true
+		gs.compression = &compressionConfig{level: level, threshold: threshold}
+	}
+}
+
+// WithWriteTimeout sets the deadline for every outbound frame, 0 disables write deadlines
+// A connection whose write times out is closed, see handleWriteError.
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(gs *GameServer) {
+		gs.writeTimeout = timeout
+	}
+}
+
+// WithReadTimeout disconnects players that send nothing for this long
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(gs *GameServer) {
+		gs.readTimeout = timeout
+	}
+}
+
+// WithTickRate sets the default room tick rate in ticks per second, see TickInterval
+func WithTickRate(ticksPerSecond int) Option {
+	return func(gs *GameServer) {
+		if ticksPerSecond > 0 {
+			gs.tickRate = ticksPerSecond
+		}
+	}
+}
+
+// WithAllowedOrigins restricts which web pages may connect, see originPolicy for the pattern syntax
+// Without it only pages served from the same host as the server can connect.
+func WithAllowedOrigins(patterns ...string) Option {
+	return func(gs *GameServer) {
+		gs.origins.Store(newOriginPolicy(patterns, gs.origins.Load().devMode))
+	}
+}
+
+// WithDevOrigins allows connections from any origin, never use it in production
+func WithDevOrigins() Option {
+	return func(gs *GameServer) {
+		gs.origins.Store(newOriginPolicy(gs.origins.Load().patterns, true))
+	}
+}
+
+// WithActivityStore persists player activity (connect, activity, disconnect and whatever
+// the game passes to PersistPlayerState) through an ordered per-player async queue
+func WithActivityStore(store database.ActivityStore) Option {
+	return func(gs *GameServer) {
+		gs.activityStore = store
+	}
+}
+
+// WithNodeID names this server in room log lines, defaults to the hostname
+func WithNodeID(id string) Option {
+	return func(gs *GameServer) {
+		gs.nodeID = id
+	}
+}
+
+// WithRoomLogExport attaches each room's recent log lines to its RoomResult when it closes,
+// so bug reports about a match come with what the room logged
+func WithRoomLogExport() Option {
+	return func(gs *GameServer) {
+		gs.exportRoomLogs = true
+	}
+}
+
+// WithOnboarding makes new accounts go through cfg's steps on their first connect
+// before they can send gameplay messages. Progress is saved in store so returning players skip it.
+func WithOnboarding(store database.OnboardingStore, cfg OnboardingConfig) Option {
+	return func(gs *GameServer) {
+		if len(cfg.Steps) == 0 {
+			cfg.Steps = []string{OnboardingTerms, OnboardingName, OnboardingTutorial}
+		}
+		if cfg.ValidateName == nil {
+			cfg.ValidateName = defaultValidateName
+		}
+		gs.onboarding = &onboarding{store: store, cfg: cfg}
+	}
+}
+
+// WithMaxConnectionsPerIP rejects upgrades from an IP that already has n open connections
+// (players, spectators and queued connections together), so one machine can't take every slot
+func WithMaxConnectionsPerIP(n int) Option {
+	return func(gs *GameServer) {
+		gs.ipLimit.max = n
+	}
+}
+
+// WithClientIPResolver decides which IP a connection counts against, e.g. the first
+// X-Forwarded-For entry when running behind a trusted reverse proxy.
+// Never trust forwarding headers that clients can set themselves.
+func WithClientIPResolver(resolver func(r *http.Request) string) Option {
+	return func(gs *GameServer) {
+		gs.ipResolver = resolver
+	}
+}
+
+// WithModes sets the game-mode profiles rooms can be created with, see CreateRoomWithMode
+// Invalid profiles are logged and left out.
+func WithModes(modes map[string]ModeProfile) Option {
+	return func(gs *GameServer) {
+		valid := make(map[string]ModeProfile, len(modes))
+		for name, p := range modes {
+			if err := p.validate(); err != nil {
+				log.Printf("Ignoring mode %s: %v", name, err)
+				continue
+			}
+			valid[name] = p
+		}
+		gs.modes = valid
+	}
+}
+
+// WithActionMeter defines a per-player resource (action points, stamina, a cooldown...)
+// that actions spend and that regenerates by rule, e.g.
+//
+//	WithActionMeter("stamina", logic.MeterRule{Max: 100, Regen: 10, RegenDelay: time.Second})
+//	WithActionMeter("dash", logic.CooldownRule(3*time.Second))
+func WithActionMeter(name string, rule logic.MeterRule) Option {
+	return func(gs *GameServer) {
+		if gs.meterRules == nil {
+			gs.meterRules = make(map[string]logic.MeterRule)
+		}
+		gs.meterRules[name] = rule
+	}
+}
+
+// WithActionCost makes every msgType message cost cost from meter, messages the player
+// can't afford are rejected with ACTION_REJECTED. Define the meter with WithActionMeter first.
+func WithActionCost(msgType MessageType, meter string, cost float64) Option {
+	return func(gs *GameServer) {
+		if _, ok := gs.meterRules[meter]; !ok {
+			log.Printf("Ignoring cost of %s: unknown meter %s", msgType, meter)
+			return
+		}
+		if gs.actionCosts == nil {
+			gs.actionCosts = make(map[MessageType][]actionCost)
+		}
+		gs.actionCosts[msgType] = append(gs.actionCosts[msgType], actionCost{meter: meter, cost: cost})
+	}
+}
+
+// WithBanStore enables the ban list, banned accounts and IPs are rejected before the upgrade
+func WithBanStore(store database.BanStore) Option {
+	return func(gs *GameServer) {
+		gs.banStore = store
+	}
+}
+
+// WithMovementValidation checks every PLAYER_MOVE (JSON or binary) against limits and the
+// player's authoritative position. handler decides what happens to bad moves, nil rubber-bands them.
+func WithMovementValidation(limits logic.MoveLimits, handler MoveViolationHandler) Option {
+	return func(gs *GameServer) {
+		if handler == nil {
+			handler = RubberBandViolations
+		}
+		gs.movement = &movementValidator{limits: limits, handler: handler}
+	}
+}
+
+// WithBroadcastRelays makes rooms with at least minMembers members broadcast through relay
+// workers owning sliceSize members each, writing to the slices in parallel. Meant for
+// battle-royale sized rooms where writing to everyone in turn takes longer than a tick.
+func WithBroadcastRelays(minMembers, sliceSize int) Option {
+	return func(gs *GameServer) {
+		if sliceSize < 1 {
+			sliceSize = 1
+		}
+		gs.relays = &relayConfig{minMembers: minMembers, sliceSize: sliceSize}
+	}
+}
+
+// WithMetricsHistory saves a metrics snapshot to store every interval (a minute when 0),
+// queried with MetricsHistory or MetricsHistoryHandler
+func WithMetricsHistory(store database.MetricsStore, interval time.Duration) Option {
+	return func(gs *GameServer) {
+		if interval <= 0 {
+			interval = defaultMetricsHistoryInterval
+		}
+		gs.metricsHistory = &metricsHistory{store: store, interval: interval}
+	}
+}
+
+// WithSurveys asks players for a rating, feedback and reports when their match ends,
+// responses go to store and are aggregated per mode and map by SurveySummaries
+func WithSurveys(store database.SurveyStore, cfg SurveyConfig) Option {
+	return func(gs *GameServer) {
+		if cfg.MaxRating <= 0 {
+			cfg.MaxRating = defaultSurveyMaxRating
+		}
+		if cfg.Window <= 0 {
+			cfg.Window = defaultSurveyWindow
+		}
+		if cfg.MaxFeedbackLength <= 0 {
+			cfg.MaxFeedbackLength = defaultSurveyMaxFeedback
+		}
+		gs.surveys = &surveys{store: store, cfg: cfg, pending: make(map[string]map[string]pendingSurvey)}
+	}
+}
+
+// WithSlowConsumerPolicy disconnects or degrades players whose connection can't keep up
+// with their messages, see SlowConsumerPolicy
+func WithSlowConsumerPolicy(policy SlowConsumerPolicy) Option {
+	return func(gs *GameServer) {
+		if policy.DegradedInterval <= 0 {
+			policy.DegradedInterval = defaultDegradedInterval
+		}
+		if len(policy.Droppable) == 0 {
+			policy.Droppable = []MessageType{GameStateSync}
+		}
+		p := &slowConsumerPolicy{SlowConsumerPolicy: policy, droppable: make(map[MessageType]bool)}
+		for _, t := range policy.Droppable {
+			p.droppable[t] = true
+		}
+		gs.slowPolicy = p
+	}
+}
+
+// WithReliableDelivery enables SendReliable, see ReliableConfig for the defaults
+func WithReliableDelivery(cfg ReliableConfig) Option {
+	return func(gs *GameServer) {
+		if cfg.RetryInterval <= 0 {
+			cfg.RetryInterval = defaultReliableRetryInterval
+		}
+		if cfg.MaxAttempts <= 0 {
+			cfg.MaxAttempts = defaultReliableMaxAttempts
+		}
+		if cfg.Timeout <= 0 {
+			cfg.Timeout = defaultReliableTimeout
+		}
+		gs.reliable = &reliableDelivery{cfg: cfg, pending: make(map[uint64]*Delivery)}
+	}
+}
+
+// WithDedupWindow sets how many message ids are remembered per connection to drop retried
+// duplicates, 256 by default and 0 turns it off
+func WithDedupWindow(size int) Option {
+	return func(gs *GameServer) {
+		gs.dedupWindow = size
+	}
+}
+
+// WithIdlePolicy warns idle players and then disconnects them with close reason IDLE,
+// see IdleConfig for per-role overrides and Room.SetIdlePolicy for per-room ones
+func WithIdlePolicy(cfg IdleConfig) Option {
+	return func(gs *GameServer) {
+		if cfg.CheckInterval <= 0 {
+			cfg.CheckInterval = defaultIdleCheckInterval
+		}
+		gs.idle = &cfg
+	}
+}
+
+// WithJanitor sweeps the players every interval (a minute when 0) and removes those that
+// stayed registered past their read timeout, counted as leaked_players in the metrics
+func WithJanitor(interval time.Duration) Option {
+	return func(gs *GameServer) {
+		if interval <= 0 {
+			interval = defaultJanitorInterval
+		}
+		gs.janitorInterval = interval
+	}
+}
+
+// WithJoinHandshake makes new connections send JOIN with a valid profile before they are
+// visible to anyone, see JoinConfig
+func WithJoinHandshake(cfg JoinConfig) Option {
+	return func(gs *GameServer) {
+		gs.join = newJoinHandshake(cfg)
+	}
+}
+
+// WithSessionPolicy decides what happens when an account connects while it is already
+// connected: reject the new connection, replace the old one or keep both with a device list
+func WithSessionPolicy(policy SessionPolicy) Option {
+	return func(gs *GameServer) {
+		gs.sessionPolicy = &policy
+	}
+}
+
+// WithHealthCheck adds a dependency to /readyz, e.g. a database ping or a backplane connection.
+// Checks run side by side on every request with a 2 second timeout.
+func WithHealthCheck(name string, check HealthCheck) Option {
+	return func(gs *GameServer) {
+		gs.healthChecks = append(gs.healthChecks, namedHealthCheck{name, check})
+	}
+}
+
+// WithScripts loads the Lua scripts of cfg.Dir and routes messages through their handlers
+// before the built-in ones. A directory that fails to load is logged and retried on reload.
+func WithScripts(cfg ScriptConfig) Option {
+	return func(gs *GameServer) {
+		if cfg.CallTimeout <= 0 {
+			cfg.CallTimeout = defaultScriptCallTimeout
+		}
+		gs.scripts = &scripts{gs: gs, cfg: cfg}
+		if err := gs.scripts.reload(); err != nil {
+			log.Printf("Loading scripts from %s failed: %v", cfg.Dir, err)
+		}
+		if cfg.PollInterval > 0 {
+			go gs.scripts.watch()
+		}
+	}
+}
+
+// WithPlugin loads the WebAssembly plugin at path, see LoadPlugin. A plugin that fails to
+// load is logged and left out.
+func WithPlugin(name, path string, limits PluginLimits) Option {
+	return func(gs *GameServer) {
+		wasm, err := os.ReadFile(path)
+		if err == nil {
+			err = gs.LoadPlugin(name, wasm, limits)
+		}
+		if err != nil {
+			log.Printf("Loading plugin %s from %s failed: %v", name, path, err)
+		}
+	}
+}
+
+// WithBatching sends the members of a ticking room everything of one tick as a single
+// BATCH frame, if their client asked for the batching feature. A batch reaching maxBytes
+// (64KB when 0) is flushed early.
+func WithBatching(maxBytes int) Option {
+	return func(gs *GameServer) {
+		if maxBytes <= 0 {
+			maxBytes = defaultBatchBytes
+		}
+		gs.batchBytes = maxBytes
+	}
+}
+
+// WithBroadcastWorkers sets how many goroutines write broadcasts in parallel (4 per CPU
+// by default). 0 writes them one after another on the caller's goroutine.
+func WithBroadcastWorkers(n int) Option {
+	return func(gs *GameServer) {
+		if n < 0 {
+			n = 0
+		}
+		gs.broadcastWorkers = n
+	}
+}
+
+// WithMessagePriority puts msgType in a priority class of the send queues, see Priority
+func WithMessagePriority(msgType MessageType, prio Priority) Option {
+	return func(gs *GameServer) {
+		if prio < PriorityChat {
+			prio = PriorityChat
+		} else if prio > PriorityControl {
+			prio = PriorityControl
+		}
+		gs.priorities[msgType] = prio
+	}
+}
+
+// WithLogin serves the login endpoints and checks login tokens on upgrade, see LoginConfig
+// It panics on a secret shorter than 32 bytes rather than start with forgeable tokens.
+func WithLogin(cfg LoginConfig) Option {
+	return func(gs *GameServer) {
+		if len(cfg.Secret) < minLoginSecret {
+			panic(fmt.Sprintf("login secret must be at least %d bytes", minLoginSecret))
+		}
+		if cfg.TokenTTL <= 0 {
+			cfg.TokenTTL = defaultTokenTTL
+		}
+		gs.login = &login{cfg: cfg}
+	}
+}
+
+// WithInjection lets backends holding secret push messages through POST /inject, see
+// InjectHandler. It panics on a secret shorter than 32 bytes.
+func WithInjection(secret []byte) Option {
+	return func(gs *GameServer) {
+		if len(secret) < minInjectKey {
+			panic(fmt.Sprintf("inject secret must be at least %d bytes", minInjectKey))
+		}
+		gs.injector = &injector{secret: secret, seen: make(map[string]time.Time)}
+	}
+}
+
+// WithPartyEncryption gives every party a payload key, see Party.EnableEncryption
+func WithPartyEncryption() Option {
+	return func(gs *GameServer) {
+		gs.partyEncryption = true
+	}
+}
+
+// WithSessionStore lets clients resume their session after a reconnect, see resume.go.
+// Sessions are kept for ttl after a disconnect, 5 minutes when ttl is 0.
+func WithSessionStore(store database.SessionStore, ttl time.Duration) Option {
+	return func(gs *GameServer) {
+		if ttl <= 0 {
+			ttl = defaultSessionTTL
+		}
+		gs.sessions = &sessions{store: store, ttl: ttl}
+	}
+}
+
+// WithRatings rates accounts from the matches reported with ReportMatch, system is Elo{}
+// when nil
+func WithRatings(store database.RatingStore, system RatingSystem) Option {
+	return func(gs *GameServer) {
+		if system == nil {
+			system = Elo{}
+		}
+		gs.ratings = &ratings{store: store, system: system}
+	}
+}
+
+// WithMatchmaking lets players look for a match with MATCHMAKING_JOIN, see matchmaking.go.
+// Combine it with WithRatings to match by skill.
+func WithMatchmaking(cfg MatchmakingConfig) Option {
+	return func(gs *GameServer) {
+		if cfg.Strategy == nil {
+			cfg.Strategy = RatingBandStrategy{}
+		}
+		if cfg.Interval <= 0 {
+			cfg.Interval = defaultMatchmakingInterval
+		}
+		if cfg.StartMatch == nil {
+			cfg.StartMatch = gs.defaultStartMatch
+		}
+		gs.matchmaker = &matchmaker{cfg: cfg, byPlayer: make(map[string]*MatchTicket)}
+	}
+}
+
+// WithRegion sets the ping hints of the lobby: the region rooms are hosted in and an URL
+// of this server clients can time requests to, e.g. https://eu1.your-game.com/healthz
+func WithRegion(region, pingURL string) Option {
+	return func(gs *GameServer) {
+		gs.region = region
+		gs.pingURL = pingURL
+	}
+}
+
+// WithRoomManager closes empty rooms and keeps free rooms of every mode open, see
+// roommanager.go and ModeProfile.MinFreeRooms
+func WithRoomManager(cfg RoomManagerConfig) Option {
+	return func(gs *GameServer) {
+		if cfg.Interval <= 0 {
+			cfg.Interval = defaultRoomManagerInterval
+		}
+		gs.roomManager = &cfg
+	}
+}
+
+// WithWebhooks posts game events to external URLs, see webhooks.go. It panics on an
+// invalid webhook.
+func WithWebhooks(cfg WebhookConfig) Option {
+	return func(gs *GameServer) {
+		if len(cfg.Hooks) > 0 {
+			gs.webhooks = newWebhooks(cfg)
+		}
+	}
+}
+
+// WithDiscord mirrors game events to a Discord channel and takes admin commands from
+// Discord, see discord.go. It panics on an invalid config.
+func WithDiscord(cfg DiscordConfig) Option {
+	return func(gs *GameServer) {
+		gs.discord = newDiscordBot(cfg)
+	}
+}
+
+// WithRTT pings players to measure their round trip, see rtt.go
+func WithRTT(cfg RTTConfig) Option {
+	return func(gs *GameServer) {
+		if cfg.Interval <= 0 {
+			cfg.Interval = defaultRTTInterval
+		}
+		gs.rtt = &cfg
+	}
+}
+
+// WithNetworkSimulation adds latency, jitter, drops and reordering to every connection,
+// see netsim.go. It's for local testing only. It panics on invalid conditions.
+func WithNetworkSimulation(cfg NetSimConfig) Option {
+	return func(gs *GameServer) {
+		for _, c := range []NetConditions{cfg.Inbound, cfg.Outbound} {
+			if err := c.validate(); err != nil {
+				panic(err.Error())
+			}
+		}
+		log.Printf("Simulating network conditions, in: %+v out: %+v. Never use this in production.", cfg.Inbound, cfg.Outbound)
+		gs.netsim = &cfg
+	}
+}
+
+// WithPayloadValidator checks the payloads of inbound msgType messages with validate, on
+// top of the rules of their payload struct. Messages it fails are answered with
+// SCHEMA_ERROR and dropped, see validation.go.
+func WithPayloadValidator(msgType MessageType, validate PayloadValidator) Option {
+	return func(gs *GameServer) {
+		if gs.payloadValidators == nil {
+			gs.payloadValidators = make(map[MessageType]PayloadValidator)
+		}
+		if prev := gs.payloadValidators[msgType]; prev != nil {
+			next := validate
+			validate = func(payload json.RawMessage) error {
+				if err := prev(payload); err != nil {
+					return err
+				}
+				return next(payload)
+			}
+		}
+		gs.payloadValidators[msgType] = validate
+	}
+}
+
+// WithPayloadRules checks inbound msgType payloads against the validate tags of prototype's
+// struct type, for payloads that aren't generated from messages.json or need stricter rules.
+// It panics on an invalid rule.
+func WithPayloadRules(msgType MessageType, prototype interface{}) Option {
+	return WithPayloadValidator(msgType, validatorFor(prototype))
+}
+
+// WithChunkedTransfers lets the server and clients send payloads too large for one frame
+// in chunks, see transfers.go
+func WithChunkedTransfers(cfg ChunkConfig) Option {
+	return func(gs *GameServer) {
+		if cfg.ChunkSize <= 0 {
+			cfg.ChunkSize = defaultChunkSize
+		}
+		if cfg.MaxSize <= 0 {
+			cfg.MaxSize = defaultMaxTransferSize
+		}
+		if cfg.MaxConcurrent <= 0 {
+			cfg.MaxConcurrent = defaultMaxTransfers
+		}
+		if cfg.Timeout <= 0 {
+			cfg.Timeout = defaultTransferTimeout
+		}
+		gs.chunks = &cfg
+	}
+}
+
+// WithSignaling relays WebRTC offers, answers and ICE candidates between the players of a
+// room, see signaling.go
+func WithSignaling(cfg SignalingConfig) Option {
+	return func(gs *GameServer) {
+		gs.signaling = &cfg
+	}
+}
+
+// WithVoice relays binary voice frames between the players of a room, see voice.go
+func WithVoice(cfg VoiceConfig) Option {
+	return func(gs *GameServer) {
+		if cfg.MaxFrameSize <= 0 {
+			cfg.MaxFrameSize = defaultVoiceFrameSize
+		}
+		if cfg.SendRate <= 0 {
+			cfg.SendRate = defaultVoiceSendRate
+		}
+		if cfg.ReceiveRate <= 0 {
+			cfg.ReceiveRate = defaultVoiceReceiveRate
+		}
+		gs.voice = &cfg
+	}
+}
+
+// WithRegistration lists the server in a coordinator's server browser, see coordinator.go.
+// It panics on a coordinator URL that isn't http or https, or without an address.
+func WithRegistration(cfg RegistrationConfig) Option {
+	return func(gs *GameServer) {
+		gs.registration = newRegistration(cfg)
+	}
+}
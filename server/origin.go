@@ -0,0 +1,94 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// originPolicy decides which pages may open a WebSocket to the server
+// Checking the origin prevents Cross-Site WebSocket Hijacking (CSWSH), where a malicious
+// website connects to the server from the victim's browser using their cookies.
+type originPolicy struct {
+	// patterns can be:
+	//   "https://game.example.com"  exact origin, scheme included
+	//   "game.example.com:8080"     exact host, any scheme
+	//   "*.example.com"             any subdomain of example.com (not example.com itself)
+	//   "*"                         anything, same as dev mode
+	patterns []string
+	// devMode allows every origin, handy for local testing with file:// pages
+	devMode bool
+}
+
+func newOriginPolicy(patterns []string, devMode bool) *originPolicy {
+	cleaned := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			cleaned = append(cleaned, p)
+		}
+	}
+	return &originPolicy{patterns: cleaned, devMode: devMode}
+}
+
+func (p *originPolicy) allows(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// Not a browser, native clients don't send an origin and can't be hijacked this way
+		return true
+	}
+	if p.devMode {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	host := strings.ToLower(u.Host)
+	fullOrigin := strings.ToLower(u.Scheme) + "://" + host
+
+	// Without an allow-list only same-origin pages may connect
+	if len(p.patterns) == 0 {
+		return host == strings.ToLower(r.Host)
+	}
+
+	for _, pattern := range p.patterns {
+		if matchOrigin(pattern, host, fullOrigin) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchOrigin(pattern, host, fullOrigin string) bool {
+	switch {
+	case pattern == "*":
+		return true
+	case strings.Contains(pattern, "://"):
+		return pattern == fullOrigin
+	case strings.HasPrefix(pattern, "*."):
+		// Compare without the port so *.example.com covers every port
+		hostname := host
+		if i := strings.LastIndex(hostname, ":"); i >= 0 {
+			hostname = hostname[:i]
+		}
+		return strings.HasSuffix(hostname, pattern[1:])
+	default:
+		return pattern == host
+	}
+}
+
+// checkOrigin is the upgrader's CheckOrigin, the policy can be swapped at runtime
+func (gs *GameServer) checkOrigin(r *http.Request) bool {
+	if gs.origins.Load().allows(r) {
+		return true
+	}
+	log.Printf("Rejected WebSocket from origin %q", r.Header.Get("Origin"))
+	return false
+}
+
+// SetAllowedOrigins replaces the origin allow-list, existing connections are not affected
+func (gs *GameServer) SetAllowedOrigins(patterns []string, devMode bool) {
+	gs.origins.Store(newOriginPolicy(patterns, devMode))
+}
@@ -0,0 +1,293 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+const defaultMaxPartySize = 4
+
+var (
+	ErrNotInParty    = errors.New("player is not in a party")
+	ErrPartyNotFound = errors.New("party not found")
+	ErrNotInvited    = errors.New("player was not invited to the party")
+	ErrPartyFull     = errors.New("party is full")
+)
+
+// Party is a group of players that stick together across rooms and matches
+type Party struct {
+	ID string
+
+	gs       *GameServer
+	mu       sync.Mutex
+	leaderID string
+	members  map[string]*Player
+	invites  map[string]struct{}
+	metadata map[string]string
+	// disbanded is set once the last member left, the party can't be joined anymore
+	disbanded bool
+	// keys is set once the party is encrypted, see encryption.go
+	keys atomic.Pointer[keyRing]
+}
+
+// Party returns the party the player is in, nil if none
+func (p *Player) Party() *Party {
+	return p.party.Load()
+}
+
+// CreateParty creates a new party led by the player, who leaves their current party first
+func (gs *GameServer) CreateParty(leader *Player) (*Party, error) {
+	gs.LeaveParty(leader)
+
+	party := &Party{
+		ID:       generateUniqueID(),
+		gs:       gs,
+		leaderID: leader.ID,
+		members:  map[string]*Player{leader.ID: leader},
+		invites:  make(map[string]struct{}),
+		metadata: make(map[string]string),
+	}
+	leader.party.Store(party)
+
+	gs.partiesMu.Lock()
+	for gs.parties[party.ID] != nil {
+		party.ID = generateUniqueID()
+	}
+	gs.parties[party.ID] = party
+	gs.partiesMu.Unlock()
+
+	party.sync()
+	if gs.partyEncryption {
+		if err := party.EnableEncryption(); err != nil {
+			log.Printf("Failed to encrypt party %s: %v", party.ID, err)
+		}
+	}
+	return party, nil
+}
+
+func (gs *GameServer) GetParty(id string) (*Party, bool) {
+	gs.partiesMu.RLock()
+	defer gs.partiesMu.RUnlock()
+	party, exists := gs.parties[id]
+	return party, exists
+}
+
+// Invite lets a member invite another connected player, who gets a PARTY_INVITE push
+func (p *Party) Invite(from *Player, toPlayerID string) error {
+	p.mu.Lock()
+	if _, member := p.members[from.ID]; !member {
+		p.mu.Unlock()
+		return ErrNotInParty
+	}
+	p.invites[toPlayerID] = struct{}{}
+	p.mu.Unlock()
+
+	return p.gs.SendPartyInvite(toPlayerID, PartyInvitePayload{
+		PartyID:      p.ID,
+		FromPlayerID: from.ID,
+		ToPlayerID:   toPlayerID,
+	})
+}
+
+// JoinParty accepts an invite, leaving the player's current party first
+func (gs *GameServer) JoinParty(player *Player, partyID string) error {
+	party, exists := gs.GetParty(partyID)
+	if !exists {
+		return ErrPartyNotFound
+	}
+	if player.Party() == party {
+		return nil
+	}
+
+	party.mu.Lock()
+	if _, invited := party.invites[player.ID]; !invited {
+		party.mu.Unlock()
+		return ErrNotInvited
+	}
+	party.mu.Unlock()
+
+	gs.LeaveParty(player)
+
+	party.mu.Lock()
+	if party.disbanded {
+		party.mu.Unlock()
+		return ErrPartyNotFound
+	}
+	if gs.maxPartySize > 0 && len(party.members) >= gs.maxPartySize {
+		party.mu.Unlock()
+		return ErrPartyFull
+	}
+	delete(party.invites, player.ID)
+	party.members[player.ID] = player
+	player.party.Store(party)
+	party.mu.Unlock()
+
+	party.sync()
+	if keys := party.keys.Load(); keys != nil {
+		gs.sendKey(keys, player)
+	}
+	return nil
+}
+
+// LeaveParty removes the player from their party, the next member takes over as leader
+// and the party is disbanded once empty
+func (gs *GameServer) LeaveParty(player *Player) {
+	party := player.party.Swap(nil)
+	if party == nil {
+		return
+	}
+
+	party.mu.Lock()
+	delete(party.members, player.ID)
+	if party.leaderID == player.ID {
+		party.leaderID = ""
+		for id := range party.members {
+			party.leaderID = id
+			break
+		}
+	}
+	empty := len(party.members) == 0
+	if empty {
+		party.disbanded = true
+	}
+	party.mu.Unlock()
+
+	if empty {
+		gs.partiesMu.Lock()
+		delete(gs.parties, party.ID)
+		gs.partiesMu.Unlock()
+		return
+	}
+	party.sync()
+	if keys := party.keys.Load(); keys != nil {
+		gs.rotateKey(keys, party.Members())
+	}
+}
+
+func (p *Party) LeaderID() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.leaderID
+}
+
+// Members returns a snapshot of the party members, useful for matchmaking to place them together
+func (p *Party) Members() []*Player {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	members := make([]*Player, 0, len(p.members))
+	for _, m := range p.members {
+		members = append(members, m)
+	}
+	return members
+}
+
+// SetMetadata sets shared party data (selected mode, loadouts, ready flags...) and syncs it to members
+func (p *Party) SetMetadata(key, value string) {
+	p.mu.Lock()
+	p.metadata[key] = value
+	p.mu.Unlock()
+
+	p.sync()
+}
+
+func (p *Party) state() PartyStatePayload {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	state := PartyStatePayload{
+		PartyID:  p.ID,
+		LeaderID: p.leaderID,
+		Members:  make([]string, 0, len(p.members)),
+		Metadata: make(map[string]string, len(p.metadata)),
+	}
+	for id := range p.members {
+		state.Members = append(state.Members, id)
+	}
+	for k, v := range p.metadata {
+		state.Metadata[k] = v
+	}
+	return state
+}
+
+// sync sends the current party state to every member
+func (p *Party) sync() {
+	p.Broadcast(PartyUpdate, p.state())
+}
+
+// Broadcast sends a structured message to every party member
+func (p *Party) Broadcast(msgType MessageType, payload interface{}) {
+	for _, member := range p.Members() {
+		if err := p.gs.SendStructuredMessage(member.ID, msgType, payload); err != nil {
+			log.Printf("Error sending to party %s member %s: %v", p.ID, member.ID, err)
+		}
+	}
+}
+
+// broadcastRaw forwards an already encoded message to every party member
+func (p *Party) broadcastRaw(msgType MessageType, message []byte) {
+	for _, member := range p.Members() {
+		if err := member.writeMessage(msgType, message); err != nil {
+			log.Printf("Error sending to party %s member %s: %v", p.ID, member.ID, err)
+		}
+	}
+}
+
+// JoinParty moves every member of the party into the room so parties stay together
+func (r *Room) JoinParty(party *Party) error {
+	for _, member := range party.Members() {
+		if err := r.Join(member); err != nil {
+			return fmt.Errorf("failed to move party %s into room %s: %v", party.ID, r.ID, err)
+		}
+	}
+	return nil
+}
+
+// handlePartyMessage routes the PARTY_* messages
+func (gs *GameServer) handlePartyMessage(player *Player, msg StructuredMessage) error {
+	switch msg.Type {
+	case PartyCreate:
+		_, err := gs.CreateParty(player)
+		return err
+
+	case PartyInvite:
+		var payload PartyInvitePayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid party invite: %v", err)
+		}
+		party := player.Party()
+		if party == nil {
+			return ErrNotInParty
+		}
+		return party.Invite(player, payload.ToPlayerID)
+
+	case PartyJoin:
+		var payload PartyJoinPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid party join: %v", err)
+		}
+		return gs.JoinParty(player, payload.PartyID)
+
+	case PartyLeave:
+		gs.LeaveParty(player)
+		return nil
+
+	case PartyChat:
+		party := player.Party()
+		if party == nil {
+			return ErrNotInParty
+		}
+		relayed, err := encodeRelayed(player, msg)
+		if err != nil {
+			return Reject(ErrorInvalidPayload, err)
+		}
+		party.broadcastRaw(msg.Type, relayed)
+		return nil
+	}
+
+	return fmt.Errorf("unknown party message %s", msg.Type)
+}
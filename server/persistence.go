@@ -0,0 +1,104 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	"github.com/iknizzz1807/socket-server-template/database"
+)
+
+// Activity record kinds written by the server itself
+const (
+	ActivityConnect    = "connect"
+	ActivityTouch      = "activity"
+	ActivityDisconnect = "disconnect"
+)
+
+const (
+	persistQueueSize = 64
+	// Plain activity touches are persisted at most this often per player
+	activityPersistInterval = 30 * time.Second
+)
+
+// persistQueue writes one player's records in order on its own goroutine,
+// so storage latency never stalls the player's read loop
+type persistQueue struct {
+	records chan database.ActivityRecord
+	done    chan struct{}
+	// lastTouch is only used by the read loop goroutine
+	lastTouch time.Time
+}
+
+func (gs *GameServer) startPersistQueue(player *Player) {
+	if gs.activityStore == nil {
+		return
+	}
+
+	q := &persistQueue{
+		records: make(chan database.ActivityRecord, persistQueueSize),
+		done:    make(chan struct{}),
+	}
+	player.persist = q
+
+	gs.persistWG.Add(1)
+	go func() {
+		defer gs.persistWG.Done()
+		defer close(q.done)
+
+		for record := range q.records {
+			if err := gs.activityStore.SaveActivity(record); err != nil {
+				log.Printf("Error persisting %s activity of player %s: %v", record.Kind, record.PlayerID, err)
+			}
+		}
+	}()
+
+	gs.PersistPlayerState(player, ActivityConnect, nil)
+}
+
+// PersistPlayerState queues a record for the player's activity log without blocking
+// Records are written in the order they were queued. If storage falls so far behind
+// that the queue is full the record is dropped and logged rather than stalling the game.
+func (gs *GameServer) PersistPlayerState(player *Player, kind string, data interface{}) {
+	q := player.persist
+	if q == nil {
+		return
+	}
+
+	record := database.ActivityRecord{
+		AccountID: player.AccountID,
+		PlayerID:  player.ID,
+		Kind:      kind,
+		At:        time.Now(),
+		Data:      data,
+	}
+
+	select {
+	case q.records <- record:
+	default:
+		log.Printf("Persistence queue of player %s is full, dropping %s record", player.ID, kind)
+	}
+}
+
+// touch updates the player's last activity, persisting it at most every activityPersistInterval
+func (gs *GameServer) touch(player *Player) {
+	now := time.Now()
+	player.LastActivity = now
+	player.idle.active(now)
+
+	if q := player.persist; q != nil && now.Sub(q.lastTouch) >= activityPersistInterval {
+		q.lastTouch = now
+		gs.PersistPlayerState(player, ActivityTouch, nil)
+	}
+}
+
+// flushPersistQueue writes the disconnect record and lets the queue drain in the background
+// Shutdown waits for every queue to finish through gs.persistWG.
+func (gs *GameServer) flushPersistQueue(player *Player) {
+	q := player.persist
+	if q == nil {
+		return
+	}
+
+	gs.PersistPlayerState(player, ActivityDisconnect, nil)
+	close(q.records)
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Pipe is an in-memory connection, for tests and in-process clients that want the full
+// connection path (handshakes, read timeouts, write timeouts) that bots skip. Hand the server
+// end to ServeTransport and talk through the client end:
+//
+//	srv, client := server.NewPipe(64)
+//	gs.ServeTransport(srv, nil)
+//	client.Send(websocket.TextMessage, data)
+type Pipe struct {
+	in  *frameQueue
+	out chan inboundFrame
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	closeErr  *websocket.CloseError
+}
+
+// PipeClient is the client end of a Pipe
+type PipeClient struct {
+	p *Pipe
+}
+
+// NewPipe creates a pipe whose client end buffers up to size frames, a client that doesn't
+// keep up makes the server's writes time out like a stalled socket would
+func NewPipe(size int) (*Pipe, *PipeClient) {
+	p := &Pipe{
+		in:     newFrameQueue(),
+		out:    make(chan inboundFrame, size),
+		closed: make(chan struct{}),
+	}
+	return p, &PipeClient{p: p}
+}
+
+func (p *Pipe) ReadFrame(deadline time.Time) (int, []byte, error) {
+	return p.in.read(deadline)
+}
+
+func (p *Pipe) WriteFrame(messageType int, data []byte, deadline time.Time) error {
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	// Copy since callers reuse buffers for the next player
+	frame := inboundFrame{messageType, append([]byte(nil), data...)}
+	select {
+	case p.out <- frame:
+		return nil
+	case <-p.closed:
+		return net.ErrClosed
+	case <-timeout:
+		return os.ErrDeadlineExceeded
+	}
+}
+
+// Close ends both directions, the client sees the code and reason as a *websocket.CloseError
+func (p *Pipe) Close(code int, reason string) error {
+	p.closeOnce.Do(func() {
+		p.closeErr = &websocket.CloseError{Code: code, Text: reason}
+		p.in.finish(p.closeErr)
+		close(p.closed)
+	})
+	return nil
+}
+
+func (p *Pipe) RemoteAddr() net.Addr {
+	return pipeAddr{}
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "pipe" }
+
+// Send hands a frame to the server, it blocks while the server is behind on reading
+func (c *PipeClient) Send(messageType int, data []byte) error {
+	if !c.p.in.push(messageType, data, c.p.closed) {
+		return c.p.closeErr
+	}
+	return nil
+}
+
+// Receive returns the next frame the server wrote, frames written before the pipe closed
+// come first
+func (c *PipeClient) Receive(ctx context.Context) (int, []byte, error) {
+	select {
+	case f := <-c.p.out:
+		return f.messageType, f.data, nil
+	default:
+	}
+	select {
+	case f := <-c.p.out:
+		return f.messageType, f.data, nil
+	case <-c.p.closed:
+		select {
+		case f := <-c.p.out:
+			return f.messageType, f.data, nil
+		default:
+		}
+		return 0, nil, c.p.closeErr
+	case <-ctx.Done():
+		return 0, nil, ctx.Err()
+	}
+}
+
+// Close disconnects like a client closing its websocket
+func (c *PipeClient) Close() error {
+	return c.p.Close(websocket.CloseNormalClosure, "")
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Playback feeds a replay (see replay.go) back into a room at a chosen speed. Recorded
+// broadcasts go out through Room.Broadcast like live ones, so members and spectators of
+// the room watch the match again. Recorded inbound frames are handed to PlaybackOptions.Inbound.
+
+var ErrPlaybackRunning = errors.New("room is already playing a replay")
+
+const maxReplayLine = 1 << 20
+
+// ReplayReader reads a replay file entry by entry
+type ReplayReader struct {
+	scanner *bufio.Scanner
+	Header  ReplayHeader
+}
+
+// NewReplayReader reads the header of a replay, then Next returns its entries in order
+func NewReplayReader(src io.Reader) (*ReplayReader, error) {
+	scanner := bufio.NewScanner(src)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxReplayLine)
+
+	rr := &ReplayReader{scanner: scanner}
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read replay header: %v", err)
+		}
+		return nil, fmt.Errorf("empty replay")
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &rr.Header); err != nil {
+		return nil, fmt.Errorf("invalid replay header: %v", err)
+	}
+	if rr.Header.Version != ReplayVersion {
+		return nil, fmt.Errorf("unsupported replay version %d", rr.Header.Version)
+	}
+	return rr, nil
+}
+
+// Next returns the next entry, io.EOF once the replay is over
+func (rr *ReplayReader) Next() (ReplayEntry, error) {
+	var e ReplayEntry
+	if !rr.scanner.Scan() {
+		if err := rr.scanner.Err(); err != nil {
+			return e, err
+		}
+		return e, io.EOF
+	}
+	if err := json.Unmarshal(rr.scanner.Bytes(), &e); err != nil {
+		return e, fmt.Errorf("invalid replay entry: %v", err)
+	}
+	return e, nil
+}
+
+// PlaybackOptions tunes a playback
+type PlaybackOptions struct {
+	// Speed multiplies the recorded pace, 2 plays twice as fast, 1 when 0
+	Speed float64
+	// Inbound gets the recorded client frames, e.g. to feed them to the game logic,
+	// nil skips them
+	Inbound func(entry ReplayEntry)
+}
+
+// Playback is a replay being played into a room
+type Playback struct {
+	room    *Room
+	reader  *ReplayReader
+	inbound func(entry ReplayEntry)
+
+	speed    atomic.Uint64 // float64 bits
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+	err      error
+}
+
+// StartPlayback plays src into the room in the background until it ends, Stop or Close
+func (r *Room) StartPlayback(src io.Reader, opts PlaybackOptions) (*Playback, error) {
+	reader, err := NewReplayReader(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Playback{
+		room:    r,
+		reader:  reader,
+		inbound: opts.Inbound,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	p.SetSpeed(opts.Speed)
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil, ErrRoomClosed
+	}
+	if r.playback != nil {
+		r.mu.Unlock()
+		return nil, ErrPlaybackRunning
+	}
+	r.playback = p
+	r.mu.Unlock()
+
+	r.Logf("Playing replay of room %s recorded at %s", reader.Header.RoomID, reader.Header.StartedAt.Format(time.RFC3339))
+	go p.run()
+	return p, nil
+}
+
+// Header describes the replay being played
+func (p *Playback) Header() ReplayHeader {
+	return p.reader.Header
+}
+
+// SetSpeed changes the pace from the next entry on, values <= 0 mean 1
+func (p *Playback) SetSpeed(speed float64) {
+	if speed <= 0 {
+		speed = 1
+	}
+	p.speed.Store(math.Float64bits(speed))
+}
+
+// Stop ends the playback early, it's safe to call more than once
+func (p *Playback) Stop() {
+	p.stopOnce.Do(func() { close(p.stop) })
+}
+
+// Done is closed once the playback is over
+func (p *Playback) Done() <-chan struct{} {
+	return p.done
+}
+
+// Err is why the playback ended early, nil when it played to the end or was stopped.
+// Only read it after Done is closed.
+func (p *Playback) Err() error {
+	return p.err
+}
+
+func (p *Playback) run() {
+	defer func() {
+		p.room.mu.Lock()
+		if p.room.playback == p {
+			p.room.playback = nil
+		}
+		p.room.mu.Unlock()
+		close(p.done)
+	}()
+
+	timer := time.NewTimer(0)
+	<-timer.C
+	defer timer.Stop()
+
+	var last int64
+	for {
+		entry, err := p.reader.Next()
+		if err == io.EOF {
+			p.room.Logf("Replay finished")
+			return
+		}
+		if err != nil {
+			p.err = err
+			p.room.Logf("Replay stopped: %v", err)
+			return
+		}
+
+		// Wait out the recorded gap scaled by the current speed
+		if gap := entry.At - last; gap > 0 {
+			speed := math.Float64frombits(p.speed.Load())
+			timer.Reset(time.Duration(float64(gap) * float64(time.Millisecond) / speed))
+			select {
+			case <-timer.C:
+			case <-p.stop:
+				return
+			}
+		}
+		last = entry.At
+
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+
+		switch entry.Dir {
+		case ReplayOutbound:
+			p.room.Broadcast(entry.Type, entry.Payload)
+		case ReplayInbound:
+			if p.inbound != nil {
+				p.inbound(entry)
+			}
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// Plugins are WebAssembly modules handling messages, for game logic the server shouldn't
+// trust: third-party mods, user content. Each one runs in its own wazero runtime with capped
+// memory, a time limit per call and no file system, network, environment or real clock.
+//
+// A plugin exports its memory and
+//
+//	alloc(size i32) i32
+//	handle(player_ptr, player_len, type_ptr, type_len, payload_ptr, payload_len i32) i32
+//
+// The server copies the player ID, message type and JSON payload into buffers from alloc,
+// they only have to live until handle returns. handle returns 0 to pass the message on,
+// 1 when it handled it and anything else to reject it. During handle the plugin may call
+// these imports of the "game" module, all strings are (ptr, len) pairs:
+//
+//	send(player, type, payload) i32   // 0 when sent
+//	broadcast(type, payload) i32      // the number of players reached
+//	log(text)
+//
+// WASI is there for toolchains that need it (TinyGo, Rust's wasm32-wasip1, Go's wasip1),
+// stdout and stderr are discarded. Reactor modules get _initialize called once.
+// A call that traps or runs out of time kills the instance, the next message gets a fresh one.
+
+const (
+	defaultPluginMemoryPages = 256 // 16MiB
+	defaultPluginCallTimeout = 50 * time.Millisecond
+	defaultPluginMaxSends    = 16
+	// pluginStartTimeout bounds instantiation, language runtimes take a while to initialize
+	pluginStartTimeout = time.Second
+)
+
+var ErrPluginNotFound = errors.New("plugin not found")
+
+// PluginLimits are the resources a plugin gets, zero values use the defaults
+type PluginLimits struct {
+	// MaxMemoryPages caps the plugin's memory in 64KiB pages, 256 (16MiB) by default
+	MaxMemoryPages uint32
+	// CallTimeout bounds one handle call, 50ms by default
+	CallTimeout time.Duration
+	// MaxSends caps the sends and broadcasts of one handle call, 16 by default.
+	// Past it send fails and broadcast reaches nobody.
+	MaxSends int
+	// Types only routes these message types to the plugin, all of them when empty
+	Types []MessageType
+}
+
+type plugin struct {
+	name     string
+	gs       *GameServer
+	limits   PluginLimits
+	types    map[MessageType]bool
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+
+	// mu serializes calls, a module instance runs one at a time
+	mu    sync.Mutex
+	mod   api.Module
+	sends int
+}
+
+// LoadPlugin compiles a WebAssembly plugin and routes messages through it after the scripts,
+// see plugins.go for what the module has to export. Loading a name again replaces the plugin.
+func (gs *GameServer) LoadPlugin(name string, wasm []byte, limits PluginLimits) error {
+	if limits.MaxMemoryPages == 0 {
+		limits.MaxMemoryPages = defaultPluginMemoryPages
+	}
+	if limits.CallTimeout <= 0 {
+		limits.CallTimeout = defaultPluginCallTimeout
+	}
+	if limits.MaxSends <= 0 {
+		limits.MaxSends = defaultPluginMaxSends
+	}
+
+	ctx := context.Background()
+	p := &plugin{
+		name:   name,
+		gs:     gs,
+		limits: limits,
+		runtime: wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+			WithMemoryLimitPages(limits.MaxMemoryPages).
+			WithCloseOnContextDone(true)),
+	}
+	if len(limits.Types) > 0 {
+		p.types = make(map[MessageType]bool, len(limits.Types))
+		for _, t := range limits.Types {
+			p.types[t] = true
+		}
+	}
+
+	if err := p.setup(ctx, wasm); err != nil {
+		p.runtime.Close(ctx)
+		return fmt.Errorf("plugin %s: %w", name, err)
+	}
+
+	gs.pluginsMu.Lock()
+	var old *plugin
+	for i, existing := range gs.plugins {
+		if existing.name == name {
+			old = existing
+			gs.plugins = append(gs.plugins[:i:i], gs.plugins[i+1:]...)
+			break
+		}
+	}
+	gs.plugins = append(gs.plugins[:len(gs.plugins):len(gs.plugins)], p)
+	gs.pluginsMu.Unlock()
+
+	if old != nil {
+		old.close()
+	}
+	log.Printf("Loaded plugin %s", name)
+	return nil
+}
+
+// UnloadPlugin stops routing messages to the plugin and frees it
+func (gs *GameServer) UnloadPlugin(name string) error {
+	gs.pluginsMu.Lock()
+	var found *plugin
+	for i, p := range gs.plugins {
+		if p.name == name {
+			found = p
+			gs.plugins = append(gs.plugins[:i:i], gs.plugins[i+1:]...)
+			break
+		}
+	}
+	gs.pluginsMu.Unlock()
+
+	if found == nil {
+		return ErrPluginNotFound
+	}
+	found.close()
+	return nil
+}
+
+// Plugins lists the loaded plugins in routing order
+func (gs *GameServer) Plugins() []string {
+	gs.pluginsMu.RLock()
+	defer gs.pluginsMu.RUnlock()
+	names := make([]string, len(gs.plugins))
+	for i, p := range gs.plugins {
+		names[i] = p.name
+	}
+	return names
+}
+
+func (gs *GameServer) unloadPlugins() {
+	gs.pluginsMu.Lock()
+	loaded := gs.plugins
+	gs.plugins = nil
+	gs.pluginsMu.Unlock()
+
+	for _, p := range loaded {
+		p.close()
+	}
+}
+
+// handlePlugins offers msg to the plugins in load order until one handles or rejects it
+func (gs *GameServer) handlePlugins(player *Player, msg StructuredMessage) (bool, error) {
+	gs.pluginsMu.RLock()
+	loaded := gs.plugins
+	gs.pluginsMu.RUnlock()
+
+	for _, p := range loaded {
+		if p.types != nil && !p.types[msg.Type] {
+			continue
+		}
+		if handled, err := p.handle(player, msg); handled || err != nil {
+			return true, err
+		}
+	}
+	return false, nil
+}
+
+// setup compiles the module and links the imports it may use
+func (p *plugin) setup(ctx context.Context, wasm []byte) error {
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, p.runtime); err != nil {
+		return err
+	}
+	_, err := p.runtime.NewHostModuleBuilder("game").
+		NewFunctionBuilder().WithFunc(p.hostSend).Export("send").
+		NewFunctionBuilder().WithFunc(p.hostBroadcast).Export("broadcast").
+		NewFunctionBuilder().WithFunc(p.hostLog).Export("log").
+		Instantiate(ctx)
+	if err != nil {
+		return err
+	}
+
+	if p.compiled, err = p.runtime.CompileModule(ctx, wasm); err != nil {
+		return err
+	}
+	for _, export := range []string{"alloc", "handle"} {
+		if _, ok := p.compiled.ExportedFunctions()[export]; !ok {
+			return fmt.Errorf("module doesn't export %s", export)
+		}
+	}
+	// Fail the load rather than the first message
+	return p.instantiate()
+}
+
+// instantiate starts a fresh instance, p.mu held or not yet shared
+func (p *plugin) instantiate() error {
+	ctx, cancel := context.WithTimeout(context.Background(), pluginStartTimeout)
+	defer cancel()
+
+	mod, err := p.runtime.InstantiateModule(ctx, p.compiled, wazero.NewModuleConfig().
+		WithName("").
+		WithStartFunctions("_initialize"))
+	if err != nil {
+		return err
+	}
+	p.mod = mod
+	return nil
+}
+
+func (p *plugin) handle(player *Player, msg StructuredMessage) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.mod == nil {
+		// Closed by UnloadPlugin
+		if p.compiled == nil {
+			return false, nil
+		}
+		if err := p.instantiate(); err != nil {
+			return true, fmt.Errorf("plugin %s: %w", p.name, err)
+		}
+	}
+
+	// A player that leaves mid-call aborts it
+	ctx, cancel := context.WithTimeout(player.Context(), p.limits.CallTimeout)
+	defer cancel()
+
+	args := make([]uint64, 0, 6)
+	for _, data := range [][]byte{[]byte(player.ID), []byte(msg.Type), msg.Payload} {
+		ptr, err := p.write(ctx, data)
+		if err != nil {
+			p.kill()
+			return true, fmt.Errorf("plugin %s: %w", p.name, err)
+		}
+		args = append(args, uint64(ptr), uint64(len(data)))
+	}
+
+	p.sends = 0
+	res, err := p.mod.ExportedFunction("handle").Call(ctx, args...)
+	if err != nil {
+		p.kill()
+		return true, fmt.Errorf("plugin %s: %w", p.name, err)
+	}
+
+	switch code := int32(res[0]); code {
+	case 0:
+		return false, nil
+	case 1:
+		return true, nil
+	default:
+		return true, fmt.Errorf("plugin %s rejected %s with code %d", p.name, msg.Type, code)
+	}
+}
+
+// write copies data into a buffer of the plugin
+func (p *plugin) write(ctx context.Context, data []byte) (uint32, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+	res, err := p.mod.ExportedFunction("alloc").Call(ctx, uint64(len(data)))
+	if err != nil {
+		return 0, err
+	}
+	ptr := uint32(res[0])
+	if !p.mod.Memory().Write(ptr, data) {
+		return 0, fmt.Errorf("alloc returned %d, out of memory bounds", ptr)
+	}
+	return ptr, nil
+}
+
+// kill drops an instance that trapped or timed out, its memory can't be trusted anymore
+func (p *plugin) kill() {
+	p.mod.Close(context.Background())
+	p.mod = nil
+}
+
+func (p *plugin) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.mod = nil
+	p.compiled = nil
+	p.runtime.Close(context.Background())
+}
+
+// readGuest copies a string argument out of the plugin's memory
+func readGuest(mod api.Module, ptr, size uint32) ([]byte, bool) {
+	view, ok := mod.Memory().Read(ptr, size)
+	if !ok {
+		return nil, false
+	}
+	return append([]byte(nil), view...), true
+}
+
+// readGuestPayload reads a JSON argument, empty means no payload
+func readGuestPayload(mod api.Module, ptr, size uint32) (json.RawMessage, bool) {
+	if size == 0 {
+		return nil, true
+	}
+	data, ok := readGuest(mod, ptr, size)
+	if !ok || !json.Valid(data) {
+		return nil, false
+	}
+	return data, true
+}
+
+// Host functions run inside handle, so p.mu is held and p.sends belongs to the current call
+
+func (p *plugin) hostSend(_ context.Context, mod api.Module, playerPtr, playerLen, typePtr, typeLen, payloadPtr, payloadLen uint32) uint32 {
+	if p.sends >= p.limits.MaxSends {
+		return 1
+	}
+	p.sends++
+
+	playerID, ok1 := readGuest(mod, playerPtr, playerLen)
+	msgType, ok2 := readGuest(mod, typePtr, typeLen)
+	payload, ok3 := readGuestPayload(mod, payloadPtr, payloadLen)
+	if !ok1 || !ok2 || !ok3 {
+		return 1
+	}
+	if err := p.gs.SendStructuredMessage(string(playerID), MessageType(msgType), payload); err != nil {
+		return 1
+	}
+	return 0
+}
+
+func (p *plugin) hostBroadcast(_ context.Context, mod api.Module, typePtr, typeLen, payloadPtr, payloadLen uint32) uint32 {
+	if p.sends >= p.limits.MaxSends {
+		return 0
+	}
+	p.sends++
+
+	msgType, ok1 := readGuest(mod, typePtr, typeLen)
+	payload, ok2 := readGuestPayload(mod, payloadPtr, payloadLen)
+	if !ok1 || !ok2 {
+		return 0
+	}
+	res := p.gs.BroadcastTo(func(*Player) bool { return true }, MessageType(msgType), payload)
+	return uint32(res.Sent)
+}
+
+func (p *plugin) hostLog(_ context.Context, mod api.Module, ptr, size uint32) {
+	if text, ok := readGuest(mod, ptr, size); ok {
+		log.Printf("[plugin %s] %s", p.name, text)
+	}
+}
@@ -0,0 +1,47 @@
+package server
+
+// Client-side prediction support
+// Clients number their inputs (the Seq field) and apply them locally right away.
+// Every state update sent back carries Ack, the last input the server processed,
+// so the client can drop acknowledged inputs and replay the rest on top of the server state.
+
+// AckInput marks an input sequence as processed for the player
+// It returns false if seq is not newer than the last processed input, the caller should then
+// drop the input. Inputs without a sequence number (0) are always accepted.
+func (gs *GameServer) AckInput(player *Player, seq uint64) bool {
+	if seq == 0 {
+		return true
+	}
+
+	for {
+		last := player.lastInputSeq.Load()
+		if seq <= last {
+			return false
+		}
+		if player.lastInputSeq.CompareAndSwap(last, seq) {
+			return true
+		}
+	}
+}
+
+// LastInputSeq returns the last input sequence processed for the player
+func (p *Player) LastInputSeq() uint64 {
+	return p.lastInputSeq.Load()
+}
+
+// encodeFor encodes a message for one recipient, attaching the input ack for acked message types
+func (gs *GameServer) encodeFor(player *Player, msgType MessageType, payload interface{}) ([]byte, error) {
+	return encodeMessage(gs.envelopeFor(player, msgType), payload)
+}
+
+// envelopeFor is the StructuredMessage a payload goes to the player in
+func (gs *GameServer) envelopeFor(player *Player, msgType MessageType) StructuredMessage {
+	msg := StructuredMessage{Type: msgType, PlayerID: player.ID}
+	if gs.ackedTypes[msgType] {
+		msg.Ack = player.LastInputSeq()
+		if gs.rtt != nil && gs.rtt.InState {
+			msg.RTT = uint64(rttMillis(player.RTT()))
+		}
+	}
+	return msg
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// Presence statuses
+const (
+	StatusOnline  = "online"
+	StatusAway    = "away"
+	StatusInGame  = "in_game"
+	StatusOffline = "offline"
+)
+
+// AccountResolver maps an incoming connection to the account it belongs to
+// Verify a token or session here, without it every connection is its own anonymous account
+type AccountResolver func(r *http.Request) string
+
+// presenceTracker knows the status of every account with at least one connection
+type presenceTracker struct {
+	mu       sync.RWMutex
+	status   map[string]string
+	sessions map[string]map[string]*Player
+}
+
+func newPresenceTracker() *presenceTracker {
+	return &presenceTracker{
+		status:   make(map[string]string),
+		sessions: make(map[string]map[string]*Player),
+	}
+}
+
+// Status returns the presence status of an account
+func (gs *GameServer) Status(accountID string) string {
+	gs.presence.mu.RLock()
+	defer gs.presence.mu.RUnlock()
+
+	if status, ok := gs.presence.status[accountID]; ok {
+		return status
+	}
+	return StatusOffline
+}
+
+// SetPresence changes the status of an online account and tells its online friends
+func (gs *GameServer) SetPresence(accountID, status string) error {
+	switch status {
+	case StatusOnline, StatusAway, StatusInGame:
+	default:
+		return fmt.Errorf("invalid presence status %q", status)
+	}
+
+	gs.presence.mu.Lock()
+	if _, online := gs.presence.sessions[accountID]; !online {
+		gs.presence.mu.Unlock()
+		return fmt.Errorf("account %s is not online", accountID)
+	}
+	changed := gs.presence.status[accountID] != status
+	gs.presence.status[accountID] = status
+	gs.presence.mu.Unlock()
+
+	if changed {
+		gs.notifyFriends(accountID, status)
+	}
+	return nil
+}
+
+// presenceConnected is called once a player got a slot
+func (gs *GameServer) presenceConnected(player *Player) {
+	gs.presence.mu.Lock()
+	sessions, online := gs.presence.sessions[player.AccountID]
+	if !online {
+		sessions = make(map[string]*Player)
+		gs.presence.sessions[player.AccountID] = sessions
+		gs.presence.status[player.AccountID] = StatusOnline
+	}
+	sessions[player.ID] = player
+	gs.presence.mu.Unlock()
+
+	if !online {
+		gs.notifyFriends(player.AccountID, StatusOnline)
+	}
+	gs.deliverOfflineMessages(player)
+}
+
+// presenceDisconnected marks the account offline once its last connection is gone
+func (gs *GameServer) presenceDisconnected(player *Player) {
+	gs.presence.mu.Lock()
+	sessions := gs.presence.sessions[player.AccountID]
+	delete(sessions, player.ID)
+	offline := len(sessions) == 0
+	if offline {
+		delete(gs.presence.sessions, player.AccountID)
+		delete(gs.presence.status, player.AccountID)
+	}
+	gs.presence.mu.Unlock()
+
+	if offline {
+		gs.notifyFriends(player.AccountID, StatusOffline)
+	}
+}
+
+// accountSessions returns every connection of an online account
+func (gs *GameServer) accountSessions(accountID string) []*Player {
+	gs.presence.mu.RLock()
+	defer gs.presence.mu.RUnlock()
+
+	players := make([]*Player, 0, len(gs.presence.sessions[accountID]))
+	for _, p := range gs.presence.sessions[accountID] {
+		players = append(players, p)
+	}
+	return players
+}
+
+// notifyFriends pushes PRESENCE_UPDATE to every online friend of the account
+func (gs *GameServer) notifyFriends(accountID, status string) {
+	if gs.friendStore == nil {
+		return
+	}
+
+	friends, err := gs.friendStore.Friends(accountID)
+	if err != nil {
+		log.Printf("Error loading friends of %s: %v", accountID, err)
+		return
+	}
+
+	update := PresencePayload{AccountID: accountID, Status: status}
+	for _, friendID := range friends {
+		for _, session := range gs.accountSessions(friendID) {
+			if err := gs.SendPresenceUpdate(session.ID, update); err != nil {
+				log.Printf("Error sending presence update to player %s: %v", session.ID, err)
+			}
+		}
+	}
+}
+
+// handlePresenceMessage routes PRESENCE_SET and the FRIEND_* messages
+func (gs *GameServer) handlePresenceMessage(player *Player, msg StructuredMessage) error {
+	if msg.Type == PresenceSet {
+		var payload PresencePayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid presence payload: %v", err)
+		}
+		return gs.SetPresence(player.AccountID, payload.Status)
+	}
+
+	if gs.friendStore == nil {
+		return fmt.Errorf("friend lists are not enabled")
+	}
+
+	switch msg.Type {
+	case FriendAdd, FriendRemove:
+		var payload FriendPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid friend payload: %v", err)
+		}
+		if payload.AccountID == "" || payload.AccountID == player.AccountID {
+			return fmt.Errorf("invalid friend %q", payload.AccountID)
+		}
+
+		if msg.Type == FriendRemove {
+			return gs.friendStore.RemoveFriend(player.AccountID, payload.AccountID)
+		}
+		if err := gs.friendStore.AddFriend(player.AccountID, payload.AccountID); err != nil {
+			return err
+		}
+		// Both sides see each other right away
+		if err := gs.SendPresenceUpdate(player.ID, PresencePayload{AccountID: payload.AccountID, Status: gs.Status(payload.AccountID)}); err != nil {
+			return err
+		}
+		for _, session := range gs.accountSessions(payload.AccountID) {
+			gs.SendPresenceUpdate(session.ID, PresencePayload{AccountID: player.AccountID, Status: gs.Status(player.AccountID)})
+		}
+		return nil
+
+	case FriendList:
+		friends, err := gs.friendStore.Friends(player.AccountID)
+		if err != nil {
+			return fmt.Errorf("failed to load friends: %v", err)
+		}
+		list := FriendListPayload{Friends: make([]PresencePayload, 0, len(friends))}
+		for _, id := range friends {
+			list.Friends = append(list.Friends, PresencePayload{AccountID: id, Status: gs.Status(id)})
+		}
+		return gs.SendFriendList(player.ID, list)
+	}
+
+	return fmt.Errorf("unknown presence message %s", msg.Type)
+}
@@ -0,0 +1,97 @@
+package server
+
+import "sync"
+
+// Writes to a player wait their turn, and a chat flood or a burst of bulk messages can
+// put many of them in line. When the connection frees up, the waiting write of the most
+// urgent class goes next, so acks, rejections and gameplay updates overtake the chat.
+// Within a class writes keep their order, and sseq always follows the wire order.
+
+// Priority is the class of a message type in a player's send queue
+type Priority int
+
+const (
+	// PriorityChat is for chat and other bulk traffic that can wait
+	PriorityChat Priority = iota
+	// PriorityGameplay is for game state, the default of every type not listed
+	PriorityGameplay
+	// PriorityControl is for protocol messages the client waits on. They also queue past
+	// the slow consumer's MaxPending limit.
+	PriorityControl
+
+	priorityLevels = iota
+)
+
+// defaultPriorities classifies the built-in types, see WithMessagePriority
+func defaultPriorities() map[MessageType]Priority {
+	return map[MessageType]Priority{
+		Capabilities:   PriorityControl,
+		Welcome:        PriorityControl,
+		MessageAck:     PriorityControl,
+		ActionRejected: PriorityControl,
+		SchemaError:    PriorityControl,
+		Error:          PriorityControl,
+		IdleWarning:    PriorityControl,
+		JoinAccepted:   PriorityControl,
+		JoinRejected:   PriorityControl,
+		QueueAdmitted:  PriorityControl,
+		RoomClosed:     PriorityControl,
+		Kicked:         PriorityControl,
+		PayloadKey:     PriorityControl,
+		TimeSync:       PriorityControl,
+		Ping:           PriorityControl,
+
+		ChatMessage:    PriorityChat,
+		PartyChat:      PriorityChat,
+		DirectMessage:  PriorityChat,
+		PresenceUpdate: PriorityChat,
+		FriendList:     PriorityChat,
+		SessionList:    PriorityChat,
+		SurveyRequest:  PriorityChat,
+		TransferChunk:  PriorityChat,
+	}
+}
+
+// priority of msgType for this player
+func (p *Player) priority(msgType MessageType) Priority {
+	if prio, ok := p.priorities[msgType]; ok {
+		return prio
+	}
+	return PriorityGameplay
+}
+
+// writeGate lets one writer at a time through, the most urgent waiting one first
+type writeGate struct {
+	mu      sync.Mutex
+	busy    bool
+	waiting [priorityLevels][]chan struct{}
+}
+
+func (g *writeGate) lock(prio Priority) {
+	g.mu.Lock()
+	if !g.busy {
+		g.busy = true
+		g.mu.Unlock()
+		return
+	}
+	turn := make(chan struct{})
+	g.waiting[prio] = append(g.waiting[prio], turn)
+	g.mu.Unlock()
+	<-turn
+}
+
+// unlock hands the gate straight to the next writer, if any
+func (g *writeGate) unlock() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for prio := priorityLevels - 1; prio >= 0; prio-- {
+		if queue := g.waiting[prio]; len(queue) > 0 {
+			close(queue[0])
+			queue[0] = nil
+			g.waiting[prio] = queue[1:]
+			return
+		}
+	}
+	g.busy = false
+}
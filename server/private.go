@@ -0,0 +1,219 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Clients join rooms with ROOM_JOIN. A room with a password needs it in the join, an
+// invite-only room needs an invite from one of its members (ROOM_INVITE). An invite also
+// lets the player past the password and is used up by the join. Private rooms, and rooms
+// hidden from the lobby, are never listed, and the hidden ones can only be joined by
+// invite. Server code calling Room.Join skips all of this.
+//
+// Wrong passwords are throttled per player: after roomJoinBurst of them the player can
+// only try again every 1/roomJoinRetryRate seconds. A room holds at most maxRoomInvites
+// pending invites, each valid for roomInviteTTL.
+
+var (
+	ErrRoomNotFound   = errors.New("room not found")
+	ErrWrongPassword  = errors.New("wrong room password")
+	ErrInviteRequired = errors.New("room is invite only")
+	ErrNotInRoom      = errors.New("player is not in the room")
+	ErrJoinThrottled  = errors.New("too many wrong room passwords, try again later")
+	ErrTooManyInvites = errors.New("room has too many pending invites")
+)
+
+// roomJoinReasons are the join refusals the player is told about in ROOM_JOIN_REJECTED
+var roomJoinReasons = []error{
+	ErrRoomNotFound, ErrWrongPassword, ErrInviteRequired, ErrJoinThrottled, ErrRoomClosed, ErrRoomFull,
+}
+
+const (
+	roomJoinBurst     = 5
+	roomJoinRetryRate = 0.2
+	maxRoomInvites    = 64
+	roomInviteTTL     = 10 * time.Minute
+)
+
+// SetPassword protects the room with a password, an empty one removes it. Only a bcrypt
+// hash is kept.
+func (r *Room) SetPassword(password string) error {
+	var hash []byte
+	if password != "" {
+		var err error
+		if hash, err = bcrypt.GenerateFromPassword(passwordKey(password), bcrypt.DefaultCost); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.password = hash
+	return nil
+}
+
+// passwordKey is what bcrypt hashes, a SHA-256 first so passwords past bcrypt's 72 bytes
+// aren't cut short
+func passwordKey(password string) []byte {
+	sum := sha256.Sum256([]byte(password))
+	return sum[:]
+}
+
+// SetInviteOnly makes the room joinable only by invited players
+func (r *Room) SetInviteOnly(inviteOnly bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inviteOnly = inviteOnly
+}
+
+// Private reports whether the room has a password or is invite only
+func (r *Room) Private() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.privateLocked()
+}
+
+func (r *Room) privateLocked() bool {
+	return r.password != nil || r.inviteOnly
+}
+
+// Invite lets a member invite another player, who gets a ROOM_INVITE push. The invite
+// stays valid for roomInviteTTL or until it is used, even if the player isn't connected yet.
+func (r *Room) Invite(from *Player, toPlayerID string) error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return ErrRoomClosed
+	}
+	if _, member := r.members[from.ID]; !member {
+		r.mu.Unlock()
+		return ErrNotInRoom
+	}
+	now := time.Now()
+	if r.invites == nil {
+		r.invites = make(map[string]time.Time)
+	}
+	if _, pending := r.invites[toPlayerID]; !pending && len(r.invites) >= maxRoomInvites {
+		r.pruneInvitesLocked(now)
+		if len(r.invites) >= maxRoomInvites {
+			r.mu.Unlock()
+			return ErrTooManyInvites
+		}
+	}
+	r.invites[toPlayerID] = now.Add(roomInviteTTL)
+	name := r.name
+	r.mu.Unlock()
+
+	return r.gs.SendRoomInvite(toPlayerID, RoomInvitePayload{
+		RoomID:       r.ID,
+		RoomName:     name,
+		FromPlayerID: from.ID,
+		ToPlayerID:   toPlayerID,
+	})
+}
+
+// pruneInvitesLocked drops the invites that expired
+func (r *Room) pruneInvitesLocked(now time.Time) {
+	for id, expires := range r.invites {
+		if now.After(expires) {
+			delete(r.invites, id)
+		}
+	}
+}
+
+// admit checks the player may join with password and uses up its invite
+func (r *Room) admit(player *Player, password string) error {
+	r.mu.Lock()
+	if r.closed || r.playback != nil {
+		r.mu.Unlock()
+		return ErrRoomClosed
+	}
+	if _, member := r.members[player.ID]; member {
+		r.mu.Unlock()
+		return nil
+	}
+	if expires, invited := r.invites[player.ID]; invited {
+		delete(r.invites, player.ID)
+		if !time.Now().After(expires) {
+			r.mu.Unlock()
+			return nil
+		}
+	}
+	if r.inviteOnly || r.unlisted {
+		r.mu.Unlock()
+		return ErrInviteRequired
+	}
+	hash := r.password
+	r.mu.Unlock()
+
+	if hash == nil {
+		return nil
+	}
+	// bcrypt is slow on purpose, so it runs without r.mu
+	if !player.joinFailures.ready() {
+		return ErrJoinThrottled
+	}
+	if bcrypt.CompareHashAndPassword(hash, passwordKey(password)) != nil {
+		player.joinFailures.allow()
+		return ErrWrongPassword
+	}
+	return nil
+}
+
+// JoinWithPassword is Join for players asking to join: the room's password and invites
+// are checked first
+func (r *Room) JoinWithPassword(player *Player, password string) error {
+	if err := r.admit(player, password); err != nil {
+		return err
+	}
+	return r.Join(player)
+}
+
+// handleRoomMessage routes ROOM_JOIN and ROOM_INVITE
+func (gs *GameServer) handleRoomMessage(player *Player, msg StructuredMessage) error {
+	switch msg.Type {
+	case RoomJoin:
+		var payload RoomJoinPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return Reject(ErrorInvalidPayload, fmt.Errorf("invalid room join: %v", err))
+		}
+		err := ErrRoomNotFound
+		room, exists := gs.GetRoom(payload.RoomID)
+		if exists {
+			err = room.JoinWithPassword(player, payload.Password)
+		}
+		if err != nil {
+			for _, reason := range roomJoinReasons {
+				if errors.Is(err, reason) {
+					err = Reject(ErrorCode(err), err)
+					break
+				}
+			}
+			if sendErr := gs.SendRoomJoinRejected(player.ID, RoomJoinRejectedPayload{RoomID: payload.RoomID, Reason: publicReason(err)}); sendErr != nil {
+				log.Printf("Error sending room join rejection to player %s: %v", player.ID, sendErr)
+			}
+			return err
+		}
+		return gs.SendRoomJoined(player.ID, RoomJoinPayload{RoomID: room.ID})
+
+	case RoomInvite:
+		var payload RoomInvitePayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return Reject(ErrorInvalidPayload, fmt.Errorf("invalid room invite: %v", err))
+		}
+		room := player.room.Load()
+		if room == nil {
+			return ErrNotInRoom
+		}
+		return room.Invite(player, payload.ToPlayerID)
+	}
+
+	return fmt.Errorf("unknown room message %s", msg.Type)
+}
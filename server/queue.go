@@ -0,0 +1,198 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// How often waiting connections get a position update even if nothing changed
+const queueUpdateInterval = 5 * time.Second
+
+type queuedConn struct {
+	// player is created before queueing so every write goes through Player.mu,
+	// it only becomes visible to the rest of the server once admitted
+	player     *Player
+	enqueuedAt time.Time
+	// admitted is signalled once the player got a slot
+	admitted chan struct{}
+	// moved is signalled whenever the position in line may have changed
+	moved chan struct{}
+}
+
+type waitingQueue struct {
+	mu      sync.Mutex
+	entries []*queuedConn
+	maxSize int
+
+	// Moving average of the time between two freed slots, used for wait estimates
+	avgSlotInterval time.Duration
+	lastSlotFreed   time.Time
+}
+
+func newWaitingQueue(maxSize int) *waitingQueue {
+	return &waitingQueue{maxSize: maxSize}
+}
+
+func (q *waitingQueue) push(player *Player) (*queuedConn, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.maxSize > 0 && len(q.entries) >= q.maxSize {
+		return nil, fmt.Errorf("waiting queue is full: %w", ErrServerFull)
+	}
+
+	entry := &queuedConn{
+		player:     player,
+		enqueuedAt: time.Now(),
+		admitted:   make(chan struct{}, 1),
+		moved:      make(chan struct{}, 1),
+	}
+	q.entries = append(q.entries, entry)
+	// Send the initial position right away
+	entry.moved <- struct{}{}
+	return entry, nil
+}
+
+func (q *waitingQueue) remove(entry *queuedConn) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, e := range q.entries {
+		if e == entry {
+			q.entries = append(q.entries[:i], q.entries[i+1:]...)
+			q.notifyLocked()
+			return
+		}
+	}
+}
+
+// notifyLocked tells every waiting connection to resend its position, q.mu must be held
+func (q *waitingQueue) notifyLocked() {
+	for _, e := range q.entries {
+		select {
+		case e.moved <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// slotFreed feeds the wait-time estimate
+func (q *waitingQueue) slotFreed() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	if !q.lastSlotFreed.IsZero() {
+		interval := now.Sub(q.lastSlotFreed)
+		if q.avgSlotInterval == 0 {
+			q.avgSlotInterval = interval
+		} else {
+			// Weight recent departures more than old ones
+			q.avgSlotInterval = (q.avgSlotInterval*4 + interval) / 5
+		}
+	}
+	q.lastSlotFreed = now
+}
+
+// status returns the 1-based position of the entry, or false if it is no longer queued
+func (q *waitingQueue) status(entry *queuedConn) (QueueStatusPayload, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, e := range q.entries {
+		if e == entry {
+			position := i + 1
+			return QueueStatusPayload{
+				Position:             position,
+				QueueLength:          len(q.entries),
+				EstimatedWaitSeconds: int64((q.avgSlotInterval * time.Duration(position)).Seconds()),
+			}, true
+		}
+	}
+	return QueueStatusPayload{}, false
+}
+
+// admitFromQueue registers waiting connections in order while there are free slots
+func (gs *GameServer) admitFromQueue() {
+	q := gs.queue
+	q.mu.Lock()
+	var admitted []*queuedConn
+	for len(q.entries) > 0 {
+		entry := q.entries[0]
+		if err := gs.claimSlot(entry.player); err != nil {
+			break
+		}
+		q.entries = q.entries[1:]
+		admitted = append(admitted, entry)
+	}
+	if len(admitted) > 0 {
+		q.notifyLocked()
+	}
+	q.mu.Unlock()
+
+	// Announced without q.mu, a slow client must not hold up the queue
+	for _, entry := range admitted {
+		gs.announcePlayer(entry.player)
+		entry.admitted <- struct{}{}
+	}
+}
+
+// waitInQueue keeps a connection in line until it gets a slot or goes away
+// Reserved slot classes only end up here once their reserved slots are used up too
+func (gs *GameServer) waitInQueue(player *Player) {
+	entry, err := gs.queue.push(player)
+	if err != nil {
+		log.Printf("Rejecting connection: %v", err)
+		gs.refuseConn(player, err)
+		return
+	}
+
+	// A slot may have opened between the failed registration and joining the queue
+	gs.admitFromQueue()
+
+	ticker := time.NewTicker(queueUpdateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-entry.admitted:
+			// Bans issued while waiting only show up now
+			if err := gs.checkBans(player.AccountID, player.remoteIP); err != nil {
+				log.Printf("Dropping admitted player %s: %v", player.ID, err)
+				gs.unregisterPlayer(player)
+				return
+			}
+			log.Printf("Player %s admitted from queue after %v", player.ID, time.Since(entry.enqueuedAt).Round(time.Second))
+			if err := gs.SendStructuredMessage(player.ID, QueueAdmitted, nil); err != nil {
+				log.Printf("Error notifying admitted player %s: %v", player.ID, err)
+			}
+			gs.greet(player)
+			gs.HandlePlayerMessages(player)
+			return
+
+		case <-entry.moved:
+		case <-ticker.C:
+		}
+
+		status, queued := gs.queue.status(entry)
+		if !queued {
+			// Admitted in the meantime, the next loop iteration picks it up
+			continue
+		}
+
+		msg, err := encodeStructuredMessage(player.ID, QueueUpdate, status)
+		if err != nil {
+			log.Printf("Error encoding queue update: %v", err)
+			continue
+		}
+		if err := player.writeMessage(QueueUpdate, msg); err != nil {
+			// The client gave up waiting
+			player.setDisconnectReason(ReasonLeft)
+			gs.queue.remove(entry)
+			gs.dropConn(player)
+			return
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrQuotaExceeded = errors.New("room quota exceeded")
+
+// RoomQuota caps what a single room may use so one custom game mode can't exhaust the node
+// Zero values mean no limit
+type RoomQuota struct {
+	MaxEntities          int
+	MaxStoredBytes       int
+	MaxMessagesPerSecond float64
+	// Short bursts above the rate are allowed up to this many messages, defaults to one second worth
+	MessageBurst int
+}
+
+// SetQuota replaces the room's quota, current usage is kept
+func (r *Room) SetQuota(q RoomQuota) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.setQuotaLocked(q)
+}
+
+func (r *Room) setQuotaLocked(q RoomQuota) {
+	r.quota = q
+	r.msgLimiter = nil
+	if q.MaxMessagesPerSecond > 0 {
+		burst := float64(q.MessageBurst)
+		if burst <= 0 {
+			burst = q.MaxMessagesPerSecond
+		}
+		r.msgLimiter = newTokenBucket(q.MaxMessagesPerSecond, burst)
+	}
+}
+
+// AddEntity spawns an entity with no state, counted against the room's entity quota
+// An ID that already exists is left alone.
+//
+// Deprecated: use Entities().Spawn, which sets the entity's state too.
+func (r *Room) AddEntity(entityID string) error {
+	if _, exists := r.entityStore.Get(entityID); exists {
+		return nil
+	}
+	return r.entityStore.Spawn(EntityState{ID: entityID})
+}
+
+// RemoveEntity despawns an entity from the room.
+//
+// Deprecated: use Entities().Despawn. Not to be confused with GameServer.RemoveEntity, which
+// tells interested players an entity is gone.
+func (r *Room) RemoveEntity(entityID string) {
+	r.entityStore.Despawn(entityID)
+}
+
+// Store saves room-scoped data (map state, custom mode settings...) counted against the storage quota
+func (r *Room) Store(key string, data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return ErrRoomClosed
+	}
+	newTotal := r.storedBytes - len(r.storage[key]) + len(data)
+	if r.quota.MaxStoredBytes > 0 && newTotal > r.quota.MaxStoredBytes {
+		return fmt.Errorf("%w: max %d stored bytes", ErrQuotaExceeded, r.quota.MaxStoredBytes)
+	}
+	r.storage[key] = append([]byte(nil), data...)
+	r.storedBytes = newTotal
+	return nil
+}
+
+// Load returns a copy of the data stored under key, changing it doesn't change the room's storage
+func (r *Room) Load(key string) ([]byte, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, ok := r.storage[key]
+	if !ok {
+		return nil, false
+	}
+	return append([]byte(nil), data...), true
+}
+
+func (r *Room) Delete(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.storedBytes -= len(r.storage[key])
+	delete(r.storage, key)
+}
+
+// allowMessage applies the room's aggregate message rate
+func (r *Room) allowMessage() bool {
+	r.mu.Lock()
+	limiter := r.msgLimiter
+	r.mu.Unlock()
+
+	return limiter == nil || limiter.allow()
+}
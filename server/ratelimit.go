@@ -0,0 +1,65 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket is a simple rate limiter, rate tokens are added per second up to burst
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// allow takes one token if available
+func (b *tokenBucket) allow() bool {
+	return b.allowN(1)
+}
+
+// ready reports whether a token is available without taking it
+func (b *tokenBucket) ready() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	return b.tokens >= 1
+}
+
+// allowN takes n tokens if available, for buckets counting bytes
+func (b *tokenBucket) allowN(n float64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	if b.tokens < n {
+		return false
+	}
+	b.tokens -= n
+	return true
+}
@@ -0,0 +1,263 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iknizzz1807/socket-server-template/database"
+)
+
+// Rated matches are reported with ReportMatch: the finishing rank of every account, lower
+// is better and equal ranks are a draw. Each account is rated as if it had played every
+// other one, so the same call works for 1v1, free-for-all and team games (give the team
+// members the same rank). Players get a RATING_UPDATE with their new rating.
+
+var ErrRatingsNotEnabled = errors.New("ratings are not enabled")
+
+// RatingSystem computes ratings, see Elo and Glicko
+type RatingSystem interface {
+	// Initial is the rating of an account without rated matches
+	Initial(accountID string) database.Rating
+	// Update returns the rating of player after a match against the opponents, scores
+	// are 1 for a win, 0.5 for a draw and 0 for a loss against the opponent at the
+	// same index
+	Update(player database.Rating, opponents []database.Rating, scores []float64, now time.Time) database.Rating
+}
+
+// Elo is the classic rating system, every match moves ratings by up to K
+type Elo struct {
+	// K is the largest change per opponent, 32 when 0. It is divided by the number of
+	// opponents so a free-for-all doesn't move ratings more than a duel.
+	K float64
+	// Start is the rating of new accounts, 1500 when 0
+	Start float64
+}
+
+func (e Elo) Initial(accountID string) database.Rating {
+	start := e.Start
+	if start == 0 {
+		start = 1500
+	}
+	return database.Rating{AccountID: accountID, Value: start}
+}
+
+func (e Elo) Update(player database.Rating, opponents []database.Rating, scores []float64, now time.Time) database.Rating {
+	if len(opponents) == 0 {
+		return player
+	}
+	k := e.K
+	if k == 0 {
+		k = 32
+	}
+	k /= float64(len(opponents))
+
+	change := 0.0
+	for i, opponent := range opponents {
+		expected := 1 / (1 + math.Pow(10, (opponent.Value-player.Value)/400))
+		change += k * (scores[i] - expected)
+	}
+	player.Value += change
+	player.Games++
+	player.UpdatedAt = now
+	return player
+}
+
+// Glicko is Glickman's rating system: every rating has a deviation that shrinks with
+// every match and grows again while an account doesn't play, unsure ratings move faster
+type Glicko struct {
+	// Start is the rating of new accounts, 1500 when 0
+	Start float64
+	// MaxDeviation is the deviation of new accounts and the most it grows to, 350 when 0
+	MaxDeviation float64
+	// MinDeviation keeps ratings from freezing, 30 when 0
+	MinDeviation float64
+	// DecayPerDay is how much uncertainty a day without a match adds, 0 when 0
+	DecayPerDay float64
+}
+
+const glickoQ = math.Ln10 / 400
+
+func (g Glicko) defaults() Glicko {
+	if g.Start == 0 {
+		g.Start = 1500
+	}
+	if g.MaxDeviation == 0 {
+		g.MaxDeviation = 350
+	}
+	if g.MinDeviation == 0 {
+		g.MinDeviation = 30
+	}
+	return g
+}
+
+func (g Glicko) Initial(accountID string) database.Rating {
+	g = g.defaults()
+	return database.Rating{AccountID: accountID, Value: g.Start, Deviation: g.MaxDeviation}
+}
+
+// deviation is the player's deviation at now, grown by the days without a match
+func (g Glicko) deviation(r database.Rating, now time.Time) float64 {
+	rd := r.Deviation
+	if rd == 0 {
+		rd = g.MaxDeviation
+	}
+	if g.DecayPerDay > 0 && !r.UpdatedAt.IsZero() {
+		days := now.Sub(r.UpdatedAt).Hours() / 24
+		rd = math.Sqrt(rd*rd + g.DecayPerDay*g.DecayPerDay*days)
+	}
+	return math.Min(rd, g.MaxDeviation)
+}
+
+func glickoG(rd float64) float64 {
+	return 1 / math.Sqrt(1+3*glickoQ*glickoQ*rd*rd/(math.Pi*math.Pi))
+}
+
+func (g Glicko) Update(player database.Rating, opponents []database.Rating, scores []float64, now time.Time) database.Rating {
+	g = g.defaults()
+	if len(opponents) == 0 {
+		return player
+	}
+	rd := g.deviation(player, now)
+
+	var dInv, sum float64
+	for i, opponent := range opponents {
+		gj := glickoG(g.deviation(opponent, now))
+		expected := 1 / (1 + math.Pow(10, -gj*(player.Value-opponent.Value)/400))
+		dInv += glickoQ * glickoQ * gj * gj * expected * (1 - expected)
+		sum += gj * (scores[i] - expected)
+	}
+	denominator := 1/(rd*rd) + dInv
+	player.Value += glickoQ / denominator * sum
+	player.Deviation = math.Max(math.Sqrt(1/denominator), g.MinDeviation)
+	player.Games++
+	player.UpdatedAt = now
+	return player
+}
+
+type ratings struct {
+	store  database.RatingStore
+	system RatingSystem
+
+	// locks holds a lock for every account in a report, so two reports with the same
+	// account don't both rate it from the same old rating
+	mu    sync.Mutex
+	locks map[string]*accountLock
+}
+
+type accountLock struct {
+	sync.Mutex
+	// refs counts the reports holding or waiting for the lock, it is dropped at 0
+	refs int
+}
+
+// lock locks the accounts in sorted order, so overlapping reports can't deadlock, and
+// returns the unlock
+func (r *ratings) lock(accounts []string) func() {
+	sort.Strings(accounts)
+	r.mu.Lock()
+	if r.locks == nil {
+		r.locks = make(map[string]*accountLock)
+	}
+	held := make([]*accountLock, len(accounts))
+	for i, account := range accounts {
+		l := r.locks[account]
+		if l == nil {
+			l = &accountLock{}
+			r.locks[account] = l
+		}
+		l.refs++
+		held[i] = l
+	}
+	r.mu.Unlock()
+
+	for _, l := range held {
+		l.Lock()
+	}
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for i, l := range held {
+			l.Unlock()
+			if l.refs--; l.refs == 0 {
+				delete(r.locks, accounts[i])
+			}
+		}
+	}
+}
+
+// Rating returns the account's rating, the initial one if it never played a rated match
+func (gs *GameServer) Rating(accountID string) (database.Rating, error) {
+	if gs.ratings == nil {
+		return database.Rating{}, ErrRatingsNotEnabled
+	}
+	rating, ok, err := gs.ratings.store.LoadRating(accountID)
+	if err != nil {
+		return database.Rating{}, err
+	}
+	if !ok {
+		rating = gs.ratings.system.Initial(accountID)
+	}
+	return rating, nil
+}
+
+// ReportMatch rates a finished match, ranks maps account IDs to where they finished (lower
+// is better, equal is a draw). It returns the new ratings. Reports that share an account
+// are rated one after the other, reports on other nodes sharing the store are not.
+func (gs *GameServer) ReportMatch(ranks map[string]int) (map[string]database.Rating, error) {
+	if gs.ratings == nil {
+		return nil, ErrRatingsNotEnabled
+	}
+	accounts := make([]string, 0, len(ranks))
+	for account := range ranks {
+		accounts = append(accounts, account)
+	}
+	defer gs.ratings.lock(accounts)()
+
+	before := make(map[string]database.Rating, len(ranks))
+	for account := range ranks {
+		rating, err := gs.Rating(account)
+		if err != nil {
+			return nil, err
+		}
+		before[account] = rating
+	}
+
+	// Everyone is rated against the ratings from before the match
+	now := time.Now()
+	after := make(map[string]database.Rating, len(ranks))
+	for account, rank := range ranks {
+		var opponents []database.Rating
+		var scores []float64
+		for other, otherRank := range ranks {
+			if other == account {
+				continue
+			}
+			score := 0.5
+			if rank < otherRank {
+				score = 1
+			} else if rank > otherRank {
+				score = 0
+			}
+			opponents = append(opponents, before[other])
+			scores = append(scores, score)
+		}
+		after[account] = gs.ratings.system.Update(before[account], opponents, scores, now)
+	}
+
+	for account, rating := range after {
+		if err := gs.ratings.store.SaveRating(rating); err != nil {
+			return nil, err
+		}
+		update := RatingUpdatePayload{Rating: rating.Value, Change: rating.Value - before[account].Value, Games: rating.Games}
+		for _, player := range gs.accountSessions(account) {
+			if err := gs.SendRatingUpdate(player.ID, update); err != nil {
+				log.Printf("Error sending rating update to player %s: %v", player.ID, err)
+			}
+		}
+	}
+	return after, nil
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iknizzz1807/socket-server-template/database"
+)
+
+// slowRatingStore widens the window between loading and saving a rating
+type slowRatingStore struct {
+	*database.MemoryRatingStore
+}
+
+func (s slowRatingStore) LoadRating(accountID string) (database.Rating, bool, error) {
+	time.Sleep(time.Millisecond)
+	return s.MemoryRatingStore.LoadRating(accountID)
+}
+
+func TestReportMatchConcurrent(t *testing.T) {
+	gs := NewGameServer(10, WithRatings(slowRatingStore{database.NewMemoryRatingStore()}, nil))
+
+	const matches = 20
+	var wg sync.WaitGroup
+	for i := 0; i < matches; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Everyone plays the champion, the other two are shared to cross the lock order
+			ranks := map[string]int{"champion": 1, fmt.Sprintf("challenger-%d", i): 2, "x": 3, "y": 3}
+			if _, err := gs.ReportMatch(ranks); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, account := range []string{"champion", "x", "y"} {
+		rating, err := gs.Rating(account)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rating.Games != matches {
+			t.Fatalf("%s has %d games, want %d", account, rating.Games, matches)
+		}
+	}
+	if n := len(gs.ratings.locks); n != 0 {
+		t.Fatalf("%d account locks left after the reports", n)
+	}
+}
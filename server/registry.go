@@ -0,0 +1,97 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// The connected players live in a sharded map, so the hot paths (sends by ID, lookups,
+// broadcast snapshots) of thousands of connections don't queue on one lock.
+// Joining and leaving still go through gs.playersMu, which guards capacity and the slot
+// counts, but only for the few instructions of the bookkeeping.
+
+// playerShards is a power of two, see shard
+const playerShards = 64
+
+type playerRegistry struct {
+	shards [playerShards]playerShard
+	count  atomic.Int64
+}
+
+type playerShard struct {
+	mu      sync.RWMutex
+	players map[string]*Player
+	// Keeps neighbouring shard locks off the same cache line
+	_ [32]byte
+}
+
+func newPlayerRegistry() *playerRegistry {
+	r := &playerRegistry{}
+	for i := range r.shards {
+		r.shards[i].players = make(map[string]*Player)
+	}
+	return r
+}
+
+// shard picks the shard of an ID with FNV-1a
+func (r *playerRegistry) shard(id string) *playerShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(id); i++ {
+		h ^= uint32(id[i])
+		h *= 16777619
+	}
+	return &r.shards[h&(playerShards-1)]
+}
+
+func (r *playerRegistry) get(id string) (*Player, bool) {
+	s := r.shard(id)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	player, ok := s.players[id]
+	return player, ok
+}
+
+// add is false when the ID is taken
+func (r *playerRegistry) add(player *Player) bool {
+	s := r.shard(player.ID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, taken := s.players[player.ID]; taken {
+		return false
+	}
+	s.players[player.ID] = player
+	r.count.Add(1)
+	return true
+}
+
+// remove takes the player out, unless its ID belongs to another player by now
+func (r *playerRegistry) remove(player *Player) bool {
+	s := r.shard(player.ID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.players[player.ID] != player {
+		return false
+	}
+	delete(s.players, player.ID)
+	r.count.Add(-1)
+	return true
+}
+
+func (r *playerRegistry) len() int {
+	return int(r.count.Load())
+}
+
+// snapshot copies the players one shard at a time, players joining or leaving meanwhile
+// may or may not be in it
+func (r *playerRegistry) snapshot() []*Player {
+	players := make([]*Player, 0, r.len())
+	for i := range r.shards {
+		s := &r.shards[i]
+		s.mu.RLock()
+		for _, player := range s.players {
+			players = append(players, player)
+		}
+		s.mu.RUnlock()
+	}
+	return players
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/gorilla/websocket"
+	"github.com/iknizzz1807/socket-server-template/messages"
+)
+
+// Every message the server refuses is answered with ERROR, so clients never have to guess
+// from silence. It carries one of the codes below, which clients switch on, the type, seq
+// and id of the rejected message to match it with what was sent, and a reason for people.
+// The specific answers some rejections have, SCHEMA_ERROR or ACTION_REJECTED, still come
+// first, and ERROR answering a request carries its re (see rpc.go). Handlers pick the code
+// of their own errors with Reject, errors without one are sent as REJECTED. Only the text
+// of a Rejection (or a schema violation) is sent as the reason, any other error gets the
+// generic reason of its code so internal error text never reaches clients.
+
+// The codes of ERROR
+const (
+	// ErrorRateLimited is a message over a rate, a room quota or an action meter
+	ErrorRateLimited = "RATE_LIMITED"
+	// ErrorInvalidPayload is a message that couldn't be read or broke its schema
+	ErrorInvalidPayload = "INVALID_PAYLOAD"
+	// ErrorNotInRoom is a message that needs a room the player isn't in
+	ErrorNotInRoom = "NOT_IN_ROOM"
+	// ErrorUnauthorized is a message the player may not send, or not yet
+	ErrorUnauthorized = "UNAUTHORIZED"
+	// ErrorRejected is any other refusal
+	ErrorRejected = "REJECTED"
+)
+
+// genericReasons are the reasons sent for errors that aren't a Rejection
+var genericReasons = map[string]string{
+	ErrorRateLimited:    "rate limited",
+	ErrorInvalidPayload: "invalid payload",
+	ErrorNotInRoom:      "not in a room",
+	ErrorUnauthorized:   "not allowed",
+	ErrorRejected:       "message rejected",
+}
+
+// Rejection is a handler error with the code to send in ERROR
+type Rejection struct {
+	Code string
+	Err  error
+}
+
+// Reject returns err with the code ERROR should carry for it, for handlers whose errors
+// clients can act on
+func Reject(code string, err error) error {
+	return &Rejection{Code: code, Err: err}
+}
+
+func (r *Rejection) Error() string { return r.Err.Error() }
+func (r *Rejection) Unwrap() error { return r.Err }
+
+// ErrorCode is the ERROR code of an error returned while handling a message
+func ErrorCode(err error) string {
+	var rejection *Rejection
+	var violation *SchemaViolation
+	switch {
+	case errors.As(err, &rejection):
+		return rejection.Code
+	case errors.As(err, &violation):
+		return ErrorInvalidPayload
+	case errors.Is(err, ErrQuotaExceeded), errors.Is(err, ErrActionBudget), errors.Is(err, ErrRoomBusy),
+		errors.Is(err, ErrTooManyTransfers), errors.Is(err, ErrVoiceBandwidth),
+		errors.Is(err, ErrJoinThrottled), errors.Is(err, ErrTooManyInvites):
+		return ErrorRateLimited
+	case errors.Is(err, ErrNotInRoom), errors.Is(err, ErrRoomClosed), errors.Is(err, ErrPeerNotFound):
+		return ErrorNotInRoom
+	case errors.Is(err, ErrJoinPending), errors.Is(err, ErrOnboardingPending),
+		errors.Is(err, ErrLoginRequired), errors.Is(err, ErrInvalidToken),
+		errors.Is(err, ErrNotPartyLeader), errors.Is(err, ErrNotInvited),
+		errors.Is(err, ErrInviteRequired), errors.Is(err, ErrWrongPassword):
+		return ErrorUnauthorized
+	}
+	return ErrorRejected
+}
+
+// publicReason is the reason a client may be told for err: the text of a Rejection or a
+// schema violation, or the generic reason of its code
+func publicReason(err error) string {
+	var rejection *Rejection
+	var violation *SchemaViolation
+	switch {
+	case errors.As(err, &rejection):
+		return rejection.Err.Error()
+	case errors.As(err, &violation):
+		return violation.Error()
+	}
+	return genericReasons[ErrorCode(err)]
+}
+
+// rejectFrame answers a frame that failed with ERROR
+func (gs *GameServer) rejectFrame(player *Player, messageType int, frame []byte, err error) {
+	var msg StructuredMessage
+	if messageType == websocket.BinaryMessage {
+		kind, _ := messages.FrameKind(frame)
+		switch kind {
+		case messages.FrameMove:
+			msg.Type = PlayerMove
+		case messages.FrameVoice:
+			msg.Type = Voice
+		}
+	} else {
+		// Whatever could be read of it, the frame may not even be JSON
+		json.Unmarshal(frame, &msg)
+	}
+	payload := ErrorPayload{Code: ErrorCode(err), Type: msg.Type, Seq: msg.Seq, Dedup: msg.DedupID, Reason: publicReason(err)}
+	if sendErr := gs.Reply(player, msg, Error, payload); sendErr != nil {
+		gs.logPlayerf(player, "Error sending error to player %s: %v", player.ID, sendErr)
+	}
+}
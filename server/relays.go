@@ -0,0 +1,193 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Rooms past a certain size fan broadcasts out through relays: workers that each own a slice
+// of the members and write to them in parallel, so a broadcast takes about as long as
+// its largest slice instead of the whole room.
+
+const relayQueueSize = 16
+
+// BroadcastResult sums up one broadcast
+type BroadcastResult struct {
+	Sent   int
+	Failed int
+	// Errors holds the first error of every slice that had failures
+	Errors []error
+}
+
+func (res *BroadcastResult) merge(other BroadcastResult) {
+	res.Sent += other.Sent
+	res.Failed += other.Failed
+	res.Errors = append(res.Errors, other.Errors...)
+}
+
+type relayConfig struct {
+	minMembers int
+	sliceSize  int
+}
+
+type relayJob struct {
+	msgType MessageType
+	payload interface{}
+	result  chan BroadcastResult
+}
+
+// relay writes broadcasts to its slice of a room on its own goroutine
+type relay struct {
+	id      int
+	mu      sync.Mutex
+	members map[string]*Player
+	jobs    chan relayJob
+	stop    chan struct{}
+}
+
+func (rl *relay) run(gs *GameServer) {
+	for {
+		select {
+		case job := <-rl.jobs:
+			job.result <- rl.deliver(gs, job.msgType, job.payload)
+		case <-rl.stop:
+			return
+		}
+	}
+}
+
+func (rl *relay) deliver(gs *GameServer, msgType MessageType, payload interface{}) BroadcastResult {
+	rl.mu.Lock()
+	members := make([]*Player, 0, len(rl.members))
+	for _, p := range rl.members {
+		members = append(members, p)
+	}
+	rl.mu.Unlock()
+
+	return deliverTo(gs, members, msgType, payload, fmt.Sprintf("relay %d", rl.id))
+}
+
+func (rl *relay) size() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return len(rl.members)
+}
+
+// deliverTo writes a broadcast to players on the broadcast workers, slice names the failures
+func deliverTo(gs *GameServer, players []*Player, msgType MessageType, payload interface{}, slice string) BroadcastResult {
+	recipients := make([]*Player, 0, len(players))
+	for _, player := range players {
+		if !player.skipUpdate(msgType) {
+			recipients = append(recipients, player)
+		}
+	}
+
+	// The payload is the same for everyone, only the envelope differs
+	raw, buf, encodeErr := encodePayload(payload)
+	if encodeErr == nil {
+		defer buf.release()
+	}
+
+	return gs.fanOut(recipients, func(player *Player) error {
+		if encodeErr != nil {
+			return encodeErr
+		}
+		return player.writeMessage(msgType, encodeEnvelope(gs.envelopeFor(player, msgType), raw))
+	}, slice)
+}
+
+// assignRelayLocked puts a member on the least loaded relay, adding one when all are full
+// Relays are only started once the room reaches the configured size. Called with r.mu held.
+func (r *Room) assignRelayLocked(player *Player) {
+	cfg := r.gs.relays
+	if cfg == nil {
+		return
+	}
+	if r.relays == nil {
+		if len(r.members) < cfg.minMembers {
+			return
+		}
+		// The room just got big: hand every member to a relay
+		r.relayOf = make(map[string]*relay)
+		for _, p := range r.members {
+			r.placeLocked(p)
+		}
+		return
+	}
+	r.placeLocked(player)
+}
+
+func (r *Room) placeLocked(player *Player) {
+	var target *relay
+	for _, rl := range r.relays {
+		if n := rl.size(); n < r.gs.relays.sliceSize && (target == nil || n < target.size()) {
+			target = rl
+		}
+	}
+	if target == nil {
+		target = &relay{
+			id:      len(r.relays),
+			members: make(map[string]*Player),
+			jobs:    make(chan relayJob, relayQueueSize),
+			stop:    make(chan struct{}),
+		}
+		r.relays = append(r.relays, target)
+		go target.run(r.gs)
+	}
+
+	target.mu.Lock()
+	target.members[player.ID] = player
+	target.mu.Unlock()
+	r.relayOf[player.ID] = target
+}
+
+// unassignRelayLocked takes a leaving member off its relay, called with r.mu held
+func (r *Room) unassignRelayLocked(playerID string) {
+	rl, ok := r.relayOf[playerID]
+	if !ok {
+		return
+	}
+	delete(r.relayOf, playerID)
+	rl.mu.Lock()
+	delete(rl.members, playerID)
+	rl.mu.Unlock()
+}
+
+// stopRelaysLocked ends the relay goroutines when the room closes, called with r.mu held
+func (r *Room) stopRelaysLocked() {
+	for _, rl := range r.relays {
+		close(rl.stop)
+	}
+	r.relays = nil
+	r.relayOf = nil
+}
+
+// BroadcastReport sends a structured message to every member and reports how it went
+// Large rooms write through their relays in parallel, see WithBroadcastRelays.
+func (r *Room) BroadcastReport(msgType MessageType, payload interface{}) BroadcastResult {
+	r.recordBroadcast(msgType, payload)
+
+	r.mu.Lock()
+	relays := append([]*relay(nil), r.relays...)
+	r.mu.Unlock()
+
+	if len(relays) == 0 {
+		return deliverTo(r.gs, r.Members(), msgType, payload, "room")
+	}
+
+	results := make(chan BroadcastResult, len(relays))
+	var res BroadcastResult
+	pending := 0
+	for _, rl := range relays {
+		select {
+		case rl.jobs <- relayJob{msgType: msgType, payload: payload, result: results}:
+			pending++
+		case <-rl.stop:
+			// The room closed in the meantime
+		}
+	}
+	for ; pending > 0; pending-- {
+		res.merge(<-results)
+	}
+	return res
+}
@@ -0,0 +1,236 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Reliable messages carry an id the client answers with MESSAGE_ACK. Until then the server
+// resends the message every RetryInterval, to the same player or, after a reconnect, to
+// the player's account. A message that isn't acknowledged in time fails its Delivery.
+// Clients should ack duplicates too and only act on an id once.
+
+var (
+	ErrReliableNotEnabled = errors.New("reliable delivery is not enabled")
+	ErrDeliveryFailed     = errors.New("message was not acknowledged")
+)
+
+const (
+	defaultReliableRetryInterval = time.Second
+	defaultReliableMaxAttempts   = 5
+	defaultReliableTimeout       = 30 * time.Second
+)
+
+// ReliableConfig configures reliable delivery, zero values get the defaults
+type ReliableConfig struct {
+	// RetryInterval is how long to wait for an ack before sending again
+	RetryInterval time.Duration
+	// MaxAttempts is how many times a message is sent before giving up
+	MaxAttempts int
+	// Timeout is how long a message may stay unacknowledged in total, time spent
+	// waiting for the player to reconnect included
+	Timeout time.Duration
+}
+
+// Delivery tracks a message sent with SendReliable
+type Delivery struct {
+	id        uint64
+	msgType   MessageType
+	payload   json.RawMessage
+	accountID string
+	deadline  time.Time
+
+	// Guarded by reliableDelivery.mu
+	playerID string
+	attempts int
+	timer    *time.Timer
+
+	done chan struct{}
+	err  error
+}
+
+// ID is the id the message carries
+func (d *Delivery) ID() uint64 {
+	return d.id
+}
+
+// Done is closed once the message was acknowledged or delivery failed
+func (d *Delivery) Done() <-chan struct{} {
+	return d.done
+}
+
+// Err is nil once the message was acknowledged and ErrDeliveryFailed if it never was,
+// only meaningful after Done is closed
+func (d *Delivery) Err() error {
+	select {
+	case <-d.done:
+		return d.err
+	default:
+		return nil
+	}
+}
+
+// Wait blocks until the message was acknowledged, delivery failed or ctx is done
+func (d *Delivery) Wait(ctx context.Context) error {
+	select {
+	case <-d.done:
+		return d.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type reliableDelivery struct {
+	cfg ReliableConfig
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]*Delivery
+}
+
+// SendReliable sends a structured message the player has to acknowledge, retrying until it does.
+// Watch the returned Delivery to find out whether it arrived.
+func (gs *GameServer) SendReliable(playerID string, msgType MessageType, payload interface{}) (*Delivery, error) {
+	rd := gs.reliable
+	if rd == nil {
+		return nil, ErrReliableNotEnabled
+	}
+
+	player, ok := gs.GetPlayer(playerID)
+	if !ok {
+		return nil, fmt.Errorf("player not found")
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	rd.mu.Lock()
+	rd.nextID++
+	d := &Delivery{
+		id:        rd.nextID,
+		msgType:   msgType,
+		payload:   raw,
+		accountID: player.AccountID,
+		deadline:  time.Now().Add(rd.cfg.Timeout),
+		playerID:  playerID,
+		done:      make(chan struct{}),
+	}
+	rd.pending[d.id] = d
+	rd.mu.Unlock()
+
+	gs.attemptDelivery(d)
+	return d, nil
+}
+
+// PendingDeliveries is the number of reliable messages waiting for an ack
+func (gs *GameServer) PendingDeliveries() int {
+	if gs.reliable == nil {
+		return 0
+	}
+	gs.reliable.mu.Lock()
+	defer gs.reliable.mu.Unlock()
+	return len(gs.reliable.pending)
+}
+
+// attemptDelivery sends d once more, or fails it when it ran out of attempts or time
+func (gs *GameServer) attemptDelivery(d *Delivery) {
+	rd := gs.reliable
+
+	rd.mu.Lock()
+	if rd.pending[d.id] != d {
+		// Acknowledged in the meantime
+		rd.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	if d.attempts >= rd.cfg.MaxAttempts || !now.Before(d.deadline) {
+		rd.finishLocked(d, ErrDeliveryFailed)
+		rd.mu.Unlock()
+		log.Printf("Reliable %s %d to player %s was not acknowledged after %d attempts", d.msgType, d.id, d.playerID, d.attempts)
+		return
+	}
+
+	target := gs.deliveryTarget(d)
+	if target != nil {
+		d.playerID = target.ID
+		d.attempts++
+	}
+	wait := rd.cfg.RetryInterval
+	if left := d.deadline.Sub(now); left < wait {
+		wait = left
+	}
+	d.timer = time.AfterFunc(wait, func() { gs.attemptDelivery(d) })
+	rd.mu.Unlock()
+
+	if target == nil {
+		// Offline, try again when the player may have reconnected
+		return
+	}
+	msg := gs.envelopeFor(target, d.msgType)
+	msg.ID = d.id
+	data, err := encodeMessage(msg, d.payload)
+	if err == nil {
+		err = target.writeMessage(d.msgType, data)
+	}
+	if err != nil {
+		// The retry covers it
+		target.tracef("reliable %s %d failed: %v", d.msgType, d.id, err)
+	}
+}
+
+// deliveryTarget is the player d goes to: the one it was sent to, or another session
+// of the same account after a reconnect
+func (gs *GameServer) deliveryTarget(d *Delivery) *Player {
+	if player, ok := gs.GetPlayer(d.playerID); ok {
+		return player
+	}
+	if d.accountID == "" {
+		return nil
+	}
+	for _, player := range gs.snapshotPlayers() {
+		if player.AccountID == d.accountID {
+			return player
+		}
+	}
+	return nil
+}
+
+func (rd *reliableDelivery) finishLocked(d *Delivery, err error) {
+	delete(rd.pending, d.id)
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.err = err
+	close(d.done)
+}
+
+func (gs *GameServer) handleMessageAck(player *Player, msg StructuredMessage) error {
+	var ack MessageAckPayload
+	if err := json.Unmarshal(msg.Payload, &ack); err != nil {
+		return fmt.Errorf("invalid ack: %v", err)
+	}
+	rd := gs.reliable
+	if rd == nil {
+		return nil
+	}
+
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+
+	d, ok := rd.pending[ack.ID]
+	if !ok {
+		// Late ack of a retried message
+		return nil
+	}
+	if d.playerID != player.ID && (d.accountID == "" || d.accountID != player.AccountID) {
+		return fmt.Errorf("player %s acked message %d sent to %s", player.ID, ack.ID, d.playerID)
+	}
+	rd.finishLocked(d, nil)
+	return nil
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Hot-swappable settings: max_players, max_connections_per_ip, allowed_origins, dev_origins,
+// the room message rate and game modes (for rooms created after the reload).
+// Everything else in Config is only read at startup, changing it in the file logs a warning
+// and waits for the next restart. Connected players are never dropped by a reload.
+
+// ApplyConfig switches the server to the hot-swappable settings of cfg
+func (gs *GameServer) ApplyConfig(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	gs.configMu.Lock()
+	defer gs.configMu.Unlock()
+
+	if old := gs.config; old != nil {
+		if old.ListenAddr != cfg.ListenAddr || old.TLSCertFile != cfg.TLSCertFile || old.TLSKeyFile != cfg.TLSKeyFile {
+			log.Printf("Config reload: listen address and TLS changes need a restart")
+		}
+		if old.ReadTimeout != cfg.ReadTimeout || old.WriteTimeout != cfg.WriteTimeout || old.TickRate != cfg.TickRate {
+			log.Printf("Config reload: timeout and tick rate changes need a restart")
+		}
+	}
+
+	gs.SetMaxPlayers(cfg.MaxPlayers)
+	gs.SetMaxConnectionsPerIP(cfg.MaxConnectionsPerIP)
+	gs.SetAllowedOrigins(cfg.AllowedOrigins, cfg.DevOrigins)
+	gs.setDefaultMessageRate(cfg.RoomMaxMessagesPerSecond, cfg.RoomMessageBurst)
+	if err := gs.SetModes(cfg.Modes); err != nil {
+		return err
+	}
+
+	gs.config = &cfg
+	log.Printf("Config applied: max players %d, %d allowed origins, room message rate %v/s",
+		cfg.MaxPlayers, len(cfg.AllowedOrigins), cfg.RoomMaxMessagesPerSecond)
+	return nil
+}
+
+// ReloadConfig loads the config file again (see LoadConfig) and applies it
+// A broken file leaves the running settings untouched.
+func (gs *GameServer) ReloadConfig(path string) error {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	return gs.ApplyConfig(cfg)
+}
+
+// ReloadOnSignal reloads the config file whenever the process gets SIGHUP
+func (gs *GameServer) ReloadOnSignal(path string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-hup:
+			case <-gs.stop:
+				return
+			}
+			log.Printf("SIGHUP received, reloading %s", path)
+			if err := gs.ReloadConfig(path); err != nil {
+				log.Printf("Config reload failed, keeping the current settings: %v", err)
+			}
+		}
+	}()
+}
+
+// configPath is the file the server's config was loaded from, empty when there was none
+func (gs *GameServer) configPath() string {
+	gs.configMu.Lock()
+	defer gs.configMu.Unlock()
+	if gs.config == nil {
+		return ""
+	}
+	return gs.config.path
+}
+
+// ConfigReloadHandler is an admin endpoint doing the same as SIGHUP, e.g.
+//
+//	POST /admin/reload
+//
+// The admin listener mounts it when the server was created from a config file. It has no
+// authentication, only mount it on a private mux or behind admin auth.
+func (gs *GameServer) ConfigReloadHandler(path string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := gs.ReloadConfig(path); err != nil {
+			http.Error(w, fmt.Sprintf("reload failed: %v", err), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// SetMaxPlayers changes the capacity, players above a lowered limit stay connected
+// Raising it admits waiting players right away.
+func (gs *GameServer) SetMaxPlayers(n int) {
+	gs.playersMu.Lock()
+	gs.maxPlayers = n
+	gs.playersMu.Unlock()
+
+	if gs.queue != nil {
+		gs.admitFromQueue()
+	}
+}
+
+// setDefaultMessageRate changes the message rate of the default room quota
+// Rooms still on the old default follow, rooms the game gave their own quota keep it.
+func (gs *GameServer) setDefaultMessageRate(perSecond float64, burst int) {
+	gs.roomsMu.Lock()
+	old := gs.defaultRoomQuota
+	quota := old
+	quota.MaxMessagesPerSecond = perSecond
+	quota.MessageBurst = burst
+	gs.defaultRoomQuota = quota
+
+	rooms := make([]*Room, 0, len(gs.rooms))
+	for _, room := range gs.rooms {
+		rooms = append(rooms, room)
+	}
+	gs.roomsMu.Unlock()
+
+	if quota == old {
+		return
+	}
+	for _, room := range rooms {
+		room.mu.Lock()
+		if room.quota == old {
+			room.setQuotaLocked(quota)
+		}
+		room.mu.Unlock()
+	}
+}
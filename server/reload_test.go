@@ -0,0 +1,62 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func adminRequest(h http.Handler, method, path, token string) int {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestAdminReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("max_players: 10\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs := NewGameServerFromConfig(cfg)
+	admin := requireToken("secret", gs.adminHandler())
+
+	os.WriteFile(path, []byte("max_players: 50\n"), 0o600)
+	if code := adminRequest(admin, http.MethodPost, "/admin/reload", ""); code != http.StatusUnauthorized {
+		t.Fatalf("reload without token: %d", code)
+	}
+	if code := adminRequest(admin, http.MethodGet, "/admin/reload", "secret"); code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET reload: %d", code)
+	}
+	if code := adminRequest(admin, http.MethodPost, "/admin/reload", "secret"); code != http.StatusNoContent {
+		t.Fatalf("reload: %d", code)
+	}
+	gs.playersMu.RLock()
+	maxPlayers := gs.maxPlayers
+	gs.playersMu.RUnlock()
+	if maxPlayers != 50 {
+		t.Fatalf("max players %d after reload, want 50", maxPlayers)
+	}
+
+	os.WriteFile(path, []byte("max_players: -1\n"), 0o600)
+	if code := adminRequest(admin, http.MethodPost, "/admin/reload", "secret"); code != http.StatusBadRequest {
+		t.Fatalf("reload of a broken file: %d", code)
+	}
+}
+
+func TestAdminReloadNeedsConfigFile(t *testing.T) {
+	gs := NewGameServer(10)
+	admin := requireToken("secret", gs.adminHandler())
+	if code := adminRequest(admin, http.MethodPost, "/admin/reload", "secret"); code != http.StatusNotFound {
+		t.Fatalf("reload without a config file: %d", code)
+	}
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Replays are JSON lines: a ReplayHeader, then one ReplayEntry per inbound message or
+// room broadcast, in the order the room saw them. Good for chasing desyncs and post-match review.
+
+const ReplayVersion = 1
+
+var (
+	ErrRecording    = errors.New("room is already recording")
+	ErrNotRecording = errors.New("room is not recording")
+)
+
+const (
+	ReplayInbound  = "in"
+	ReplayOutbound = "out"
+)
+
+// ReplayHeader is the first line of a replay file
+type ReplayHeader struct {
+	Version   int       `json:"version"`
+	RoomID    string    `json:"room_id"`
+	Mode      string    `json:"mode,omitempty"`
+	NodeID    string    `json:"node_id,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	Players   []string  `json:"players"`
+}
+
+// ReplayEntry is one recorded message, At is milliseconds since the recording started
+type ReplayEntry struct {
+	At       int64       `json:"t"`
+	Dir      string      `json:"dir"`
+	PlayerID string      `json:"player,omitempty"`
+	Type     MessageType `json:"type,omitempty"`
+	// Text or Binary hold an inbound frame as it was read
+	Text   string `json:"text,omitempty"`
+	Binary []byte `json:"binary,omitempty"`
+	// Payload is the payload of a broadcast
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type recorder struct {
+	mu      sync.Mutex
+	w       *bufio.Writer
+	enc     *json.Encoder
+	closer  io.Closer
+	started time.Time
+	err     error
+}
+
+func (rec *recorder) write(e ReplayEntry) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.err != nil {
+		return
+	}
+	e.At = time.Since(rec.started).Milliseconds()
+	rec.err = rec.enc.Encode(e)
+}
+
+func (rec *recorder) close() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	err := rec.err
+	if ferr := rec.w.Flush(); err == nil {
+		err = ferr
+	}
+	if rec.closer != nil {
+		if cerr := rec.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	// Late writers from before the swap find it closed
+	rec.err = ErrNotRecording
+	return err
+}
+
+// StartRecording writes a replay of the room to w until StopRecording or Close
+func (r *Room) StartRecording(w io.Writer) error {
+	return r.startRecording(w, nil)
+}
+
+// StartRecordingFile records the room into a new file at path
+func (r *Room) StartRecordingFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create replay file: %v", err)
+	}
+	if err := r.startRecording(f, f); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+func (r *Room) startRecording(w io.Writer, closer io.Closer) error {
+	bw := bufio.NewWriter(w)
+	rec := &recorder{w: bw, enc: json.NewEncoder(bw), closer: closer, started: time.Now()}
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return ErrRoomClosed
+	}
+	if r.recording.Load() != nil {
+		r.mu.Unlock()
+		return ErrRecording
+	}
+
+	header := ReplayHeader{
+		Version:   ReplayVersion,
+		RoomID:    r.ID,
+		Mode:      r.mode,
+		NodeID:    r.gs.nodeID,
+		StartedAt: rec.started,
+		Players:   make([]string, 0, len(r.members)),
+	}
+	for id := range r.members {
+		header.Players = append(header.Players, id)
+	}
+	// Stored under r.mu so no member joins between the header and the first entry
+	err := rec.enc.Encode(header)
+	if err == nil {
+		r.recording.Store(rec)
+	}
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to write replay header: %v", err)
+	}
+
+	r.Logf("Recording started")
+	return nil
+}
+
+// StopRecording ends the recording and flushes it, closing the file if it made one
+func (r *Room) StopRecording() error {
+	rec := r.recording.Swap(nil)
+	if rec == nil {
+		return ErrNotRecording
+	}
+	r.Logf("Recording stopped")
+	if err := rec.close(); err != nil {
+		return fmt.Errorf("failed to write replay of room %s: %v", r.ID, err)
+	}
+	return nil
+}
+
+// Recording reports whether the room is being recorded
+func (r *Room) Recording() bool {
+	return r.recording.Load() != nil
+}
+
+// recordInbound adds a frame read from a member
+func (r *Room) recordInbound(player *Player, messageType int, data []byte) {
+	rec := r.recording.Load()
+	if rec == nil {
+		return
+	}
+	e := ReplayEntry{Dir: ReplayInbound, PlayerID: player.ID}
+	if messageType == websocket.BinaryMessage {
+		e.Binary = data
+	} else {
+		e.Text = string(data)
+	}
+	rec.write(e)
+}
+
+// recordBroadcast adds a broadcast, the payload is stored once and not per member
+func (r *Room) recordBroadcast(msgType MessageType, payload interface{}) {
+	rec := r.recording.Load()
+	if rec == nil {
+		return
+	}
+	e := ReplayEntry{Dir: ReplayOutbound, Type: msgType}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			r.Logf("Error recording %s broadcast: %v", msgType, err)
+			return
+		}
+		e.Payload = data
+	}
+	rec.write(e)
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/iknizzz1807/socket-server-template/database"
+)
+
+// With a session store every connection gets a SESSION_TOKEN after the capabilities. A
+// client that lost its connection reconnects to /ws?session=<token> and comes back as the
+// same player: same player ID and account, the state the game kept with SetSessionState,
+// and back in its room if the room is on this node. A token works once, the resumed
+// connection gets a new one. The sseq numbering carries on where the old connection left
+// off and SESSION_TOKEN reports the last one delivered, so the client can tell whether it
+// missed messages while away.
+//
+// Sessions are kept for the TTL after the disconnect. With a shared store such as
+// RedisSessionStore they survive restarts and a client can resume on any node.
+
+const defaultSessionTTL = 5 * time.Minute
+
+// ErrSessionsNotEnabled is returned by SetSessionState without a session store
+var ErrSessionsNotEnabled = errors.New("sessions are not enabled")
+
+// sessions is the resume state of the server, nil without a session store
+type sessions struct {
+	store database.SessionStore
+	ttl   time.Duration
+}
+
+// playerSession is the resumable part of a player
+type playerSession struct {
+	mu      sync.Mutex
+	token   string
+	resumed bool
+	// lastSeq is the sseq the resumed session left off at
+	lastSeq uint64
+	roomID  string
+	state   map[string]json.RawMessage
+}
+
+func newSessionToken() string {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("failed to read random bytes: %v", err))
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// resumeSession picks up the session the client asks for with ?session=, an unknown or
+// expired token just gets a new session. resolved is whether the account came from the
+// request, a token can't switch accounts then. The ban check before the upgrade only saw
+// the account of the request, so the session's account is checked again here and a banned
+// one gets ErrBanned.
+func (gs *GameServer) resumeSession(player *Player, r *http.Request, resolved bool) error {
+	if gs.sessions == nil {
+		return nil
+	}
+	player.session = &playerSession{token: newSessionToken()}
+
+	token := r.URL.Query().Get("session")
+	if token == "" {
+		return nil
+	}
+	// Taken, not loaded, so two connections racing with the same token can't both resume
+	record, ok, err := gs.sessions.store.TakeSession(token)
+	if err != nil {
+		log.Printf("Error loading session: %v", err)
+		return nil
+	}
+	if !ok || (resolved && record.AccountID != player.AccountID) {
+		return nil
+	}
+	if err := gs.checkBans(record.AccountID, player.remoteIP); err != nil {
+		return err
+	}
+
+	// The old connection may not have noticed it's gone yet
+	if old, connected := gs.GetPlayer(record.PlayerID); connected {
+		if old.session != nil {
+			old.session.mu.Lock()
+			old.session.token = ""
+			old.session.mu.Unlock()
+		}
+		gs.disconnectWithReason(old, ReasonSessionReplaced)
+	}
+	player.ID = record.PlayerID
+	player.AccountID = record.AccountID
+	player.session.resumed = true
+	player.session.roomID = record.RoomID
+	player.session.state = record.State
+	player.session.lastSeq = record.LastSeq
+	// Nothing was written to the connection yet, its first message gets LastSeq+1
+	player.out.last = record.LastSeq
+	player.out.delivered.Store(record.LastSeq)
+	log.Printf("Player %s resumed its session", player.ID)
+	return nil
+}
+
+// startSession sends the token and puts a resumed player back in its room
+func (gs *GameServer) startSession(player *Player) {
+	s := player.session
+	if s == nil {
+		return
+	}
+	if s.resumed && s.roomID != "" && player.Joined() {
+		if room, ok := gs.GetRoom(s.roomID); ok {
+			if err := room.Join(player); err != nil {
+				gs.logPlayerf(player, "Player %s couldn't rejoin room %s: %v", player.ID, s.roomID, err)
+			}
+		}
+	}
+	gs.saveSession(player)
+
+	err := gs.SendSessionToken(player.ID, SessionTokenPayload{
+		Token:       s.token,
+		Resumed:     s.resumed,
+		ExpiresInMs: int(gs.sessions.ttl / time.Millisecond),
+		LastSeq:     s.lastSeq,
+	})
+	if err != nil {
+		log.Printf("Error sending session token to player %s: %v", player.ID, err)
+	}
+}
+
+// saveSession writes the player's session to the store
+func (gs *GameServer) saveSession(player *Player) error {
+	s := player.session
+	if s == nil {
+		return nil
+	}
+	if player.DisconnectReason().Code == CloseBanned {
+		// Banned players don't get to come back with their token
+		gs.dropSession(player)
+		return nil
+	}
+	s.mu.Lock()
+	if s.token == "" {
+		// Taken over by a resumed connection
+		s.mu.Unlock()
+		return nil
+	}
+	record := database.SessionRecord{
+		Token:     s.token,
+		PlayerID:  player.ID,
+		AccountID: player.AccountID,
+		State:     s.state,
+		LastSeq:   player.LastDeliveredSeq(),
+		UpdatedAt: time.Now(),
+	}
+	if room := player.room.Load(); room != nil {
+		record.RoomID = room.ID
+	}
+	err := gs.sessions.store.SaveSession(record, gs.sessions.ttl)
+	s.mu.Unlock()
+
+	if err != nil {
+		log.Printf("Error saving session of player %s: %v", player.ID, err)
+	}
+	return err
+}
+
+// dropSession deletes the player's session from the store, it can't be resumed anymore
+func (gs *GameServer) dropSession(player *Player) {
+	s := player.session
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	token := s.token
+	s.token = ""
+	s.mu.Unlock()
+	if token == "" {
+		return
+	}
+	if err := gs.sessions.store.DeleteSession(token); err != nil {
+		log.Printf("Error deleting session of player %s: %v", player.ID, err)
+	}
+}
+
+// SessionState returns what SetSessionState stored under key, also after a resume
+func (p *Player) SessionState(key string) (json.RawMessage, bool) {
+	if p.session == nil {
+		return nil, false
+	}
+	p.session.mu.Lock()
+	defer p.session.mu.Unlock()
+	value, ok := p.session.state[key]
+	return value, ok
+}
+
+// SetSessionState keeps value with the player's session so it survives a reconnect, e.g.
+// an unfinished trade. It is saved to the store right away. Without a session store it
+// returns ErrSessionsNotEnabled.
+func (gs *GameServer) SetSessionState(player *Player, key string, value interface{}) error {
+	if player.session == nil {
+		return ErrSessionsNotEnabled
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	player.session.mu.Lock()
+	if player.session.state == nil {
+		player.session.state = make(map[string]json.RawMessage)
+	}
+	player.session.state[key] = raw
+	player.session.mu.Unlock()
+	return gs.saveSession(player)
+}
@@ -0,0 +1,85 @@
+package server_test
+
+import (
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iknizzz1807/socket-server-template/database"
+	"github.com/iknizzz1807/socket-server-template/server"
+	"github.com/iknizzz1807/socket-server-template/server/servertest"
+)
+
+func TestResumeSession(t *testing.T) {
+	ts := servertest.NewTestServer(t, server.WithSessionStore(database.NewMemorySessionStore(), time.Minute))
+	first := ts.Connect(t)
+	var token server.SessionTokenPayload
+	first.ExpectPayload(server.SessionToken, &token)
+	first.Close()
+	ts.WaitForPlayers(t, 0)
+
+	resumed := ts.ConnectWith(t, "/ws?session="+url.QueryEscape(token.Token), nil)
+	var again server.SessionTokenPayload
+	resumed.ExpectPayload(server.SessionToken, &again)
+	if !again.Resumed || resumed.PlayerID() != first.PlayerID() {
+		t.Fatalf("resumed as %s (resumed=%v), want %s", resumed.PlayerID(), again.Resumed, first.PlayerID())
+	}
+	if again.Token == token.Token {
+		t.Fatal("resumed connection got the used token again")
+	}
+}
+
+func TestResumeSessionOnlyOnce(t *testing.T) {
+	ts := servertest.NewTestServer(t, server.WithSessionStore(database.NewMemorySessionStore(), time.Minute))
+	first := ts.Connect(t)
+	var token server.SessionTokenPayload
+	first.ExpectPayload(server.SessionToken, &token)
+	first.Close()
+	ts.WaitForPlayers(t, 0)
+
+	path := "/ws?session=" + url.QueryEscape(token.Token)
+	results := make(chan bool, 4)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(results); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := ts.ConnectWith(t, path, nil)
+			var payload server.SessionTokenPayload
+			c.ExpectPayload(server.SessionToken, &payload)
+			results <- payload.Resumed
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	resumes := 0
+	for resumed := range results {
+		if resumed {
+			resumes++
+		}
+	}
+	if resumes != 1 {
+		t.Fatalf("token resumed %d sessions, want 1", resumes)
+	}
+}
+
+func TestResumeSessionContinuesSequence(t *testing.T) {
+	ts := servertest.NewTestServer(t, server.WithSessionStore(database.NewMemorySessionStore(), time.Minute))
+	first := ts.Connect(t)
+	var token server.SessionTokenPayload
+	seen := first.ExpectPayload(server.SessionToken, &token).ServerSeq
+	first.Close()
+	ts.WaitForPlayers(t, 0)
+
+	resumed := ts.ConnectWith(t, "/ws?session="+url.QueryEscape(token.Token), nil)
+	var again server.SessionTokenPayload
+	msg := resumed.ExpectPayload(server.SessionToken, &again)
+	if again.LastSeq < seen {
+		t.Fatalf("last_seq %d, but the client already saw sseq %d", again.LastSeq, seen)
+	}
+	if msg.ServerSeq <= again.LastSeq {
+		t.Fatalf("resumed connection numbered from %d, want after %d", msg.ServerSeq, again.LastSeq)
+	}
+}
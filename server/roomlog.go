@@ -0,0 +1,118 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/iknizzz1807/socket-server-template/database"
+)
+
+// How many log lines a room keeps for export
+const defaultRoomLogLines = 500
+
+// RoomLogEntry is one line of a room's log
+type RoomLogEntry = database.LogEntry
+
+// roomLog keeps the most recent lines logged for a room, guarded by Room.mu
+type roomLog struct {
+	entries []RoomLogEntry
+	next    int
+	full    bool
+}
+
+func (l *roomLog) add(e RoomLogEntry) {
+	if cap(l.entries) == 0 {
+		l.entries = make([]RoomLogEntry, 0, defaultRoomLogLines)
+	}
+	if !l.full {
+		l.entries = append(l.entries, e)
+		l.full = len(l.entries) == cap(l.entries)
+		return
+	}
+	l.entries[l.next] = e
+	l.next = (l.next + 1) % len(l.entries)
+}
+
+// snapshot returns the lines oldest first
+func (l *roomLog) snapshot() []RoomLogEntry {
+	out := make([]RoomLogEntry, 0, len(l.entries))
+	out = append(out, l.entries[l.next:]...)
+	return append(out, l.entries[:l.next]...)
+}
+
+// defaultNodeID names this server in logs when WithNodeID isn't used
+func defaultNodeID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return host
+}
+
+// SetMode records the game mode the room runs, it shows up in every room log line
+// It only changes the label, rooms get a mode's settings from CreateRoomWithMode.
+func (r *Room) SetMode(mode string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mode = mode
+}
+
+func (r *Room) Mode() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.mode
+}
+
+// Logf logs with the room's context (room ID, mode, node) and keeps the line for ExportLogs
+// Use it from tick functions, timers and handlers instead of log.Printf.
+func (r *Room) Logf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+
+	r.mu.Lock()
+	mode := r.mode
+	r.logs.add(RoomLogEntry{At: time.Now(), Message: msg})
+	r.mu.Unlock()
+
+	log.Printf("[room=%s mode=%s node=%s] %s", r.ID, mode, r.gs.nodeID, msg)
+}
+
+// ExportLogs returns the room's most recent log lines, oldest first, e.g. for a bug report
+func (r *Room) ExportLogs() []RoomLogEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.logs.snapshot()
+}
+
+// logPlayerf logs in the context of the player's room if the player is in one
+func (gs *GameServer) logPlayerf(player *Player, format string, args ...interface{}) {
+	if room := player.room.Load(); room != nil {
+		room.Logf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// RoomLogsHandler is an admin endpoint returning an open room's log lines as JSON, e.g.
+//
+//	GET /dev/rooms/logs?room=ID
+//
+// Logs of closed rooms are part of their RoomResult when WithRoomLogExport is set.
+// It has no authentication, only mount it on a private mux or behind admin auth.
+func (gs *GameServer) RoomLogsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		room, exists := gs.GetRoom(r.URL.Query().Get("room"))
+		if !exists {
+			http.Error(w, "room not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(room.ExportLogs()); err != nil {
+			log.Printf("Error writing logs of room %s: %v", room.ID, err)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"log"
+	"time"
+)
+
+// The room manager takes room bookkeeping off the operators. Every interval it closes rooms
+// that have been empty for longer than the TTL, and for every mode with MinFreeRooms it
+// creates rooms until that many listed rooms of the mode have free slots, so a new one is
+// waiting in the lobby before the last one fills. Subscribe to RoomCreated to name or set
+// up the rooms it creates, and to RoomRemoved to see them go.
+
+const (
+	defaultRoomManagerInterval = time.Second
+	roomEmptyReason            = "empty"
+)
+
+// RoomManagerConfig configures the room manager, zero values get the defaults
+type RoomManagerConfig struct {
+	// EmptyTTL closes rooms nobody was in for that long, 0 keeps them open. The free rooms
+	// a mode's MinFreeRooms asks for are never closed.
+	EmptyTTL time.Duration
+	// Interval is how often rooms are checked, one second when 0
+	Interval time.Duration
+}
+
+// runRoomManager scales and collects rooms every interval until the server shuts down
+func (gs *GameServer) runRoomManager() {
+	ticker := time.NewTicker(gs.roomManager.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			gs.manageRooms(now)
+		case <-gs.stop:
+			return
+		}
+	}
+}
+
+// manageRooms closes the rooms empty for too long, then tops up the free rooms of every mode
+func (gs *GameServer) manageRooms(now time.Time) {
+	gs.roomsMu.RLock()
+	rooms := make([]*Room, 0, len(gs.rooms))
+	for _, room := range gs.rooms {
+		rooms = append(rooms, room)
+	}
+	modes := gs.modes
+	gs.roomsMu.RUnlock()
+
+	free := make(map[string]int)
+	for _, room := range rooms {
+		if mode, ok := room.freeSlotMode(); ok {
+			free[mode]++
+		}
+	}
+
+	if ttl := gs.roomManager.EmptyTTL; ttl > 0 {
+		for _, room := range rooms {
+			since, empty := room.EmptySince()
+			if !empty || now.Sub(since) < ttl {
+				continue
+			}
+			mode, counted := room.freeSlotMode()
+			if counted && free[mode] <= modes[mode].MinFreeRooms {
+				continue
+			}
+			if err := room.Close(roomEmptyReason); err != nil {
+				log.Printf("Error closing empty room %s: %v", room.ID, err)
+			}
+			if counted {
+				free[mode]--
+			}
+		}
+	}
+
+	for mode, profile := range modes {
+		for n := free[mode]; n < profile.MinFreeRooms; n++ {
+			room, err := gs.CreateRoomWithMode("", mode)
+			if err != nil {
+				log.Printf("Error creating a %q room: %v", mode, err)
+				break
+			}
+			room.Logf("Created to keep %d free %q rooms", profile.MinFreeRooms, mode)
+		}
+	}
+}
+
+// freeSlotMode returns the room's mode, false unless players can find the room in the
+// lobby and fit in it
+func (r *Room) freeSlotMode() (string, bool) {
+	listing, ok := r.listing()
+	return listing.Mode, ok && (listing.MaxPlayers == 0 || listing.Players < listing.MaxPlayers)
+}
+
+// EmptySince returns when the last player left the room, or when it was created if
+// nobody joined yet. It is false while the room has members.
+func (r *Room) EmptySince() (time.Time, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.members) > 0 {
+		return time.Time{}, false
+	}
+	return r.emptySince, true
+}
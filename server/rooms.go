@@ -0,0 +1,377 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/iknizzz1807/socket-server-template/database"
+	"github.com/iknizzz1807/socket-server-template/logic"
+)
+
+var (
+	ErrRoomExists = errors.New("room already exists")
+	ErrRoomClosed = errors.New("room is closed")
+)
+
+// Room groups players that play together, with its own goroutine, timers and tick loop
+// Everything started through the room (AfterFunc, StartTicker, Do) is stopped by Close
+type Room struct {
+	ID string
+
+	gs   *GameServer
+	mu   sync.Mutex
+	mode string
+	// mapName is a label like mode, see SetMap
+	mapName string
+	// name and unlisted are for the lobby, see lobby.go
+	name     string
+	unlisted bool
+	// password is a bcrypt hash, it and the rest are checked by ROOM_JOIN, see private.go
+	password   []byte
+	inviteOnly bool
+	// invites maps invited player IDs to when their invite expires
+	invites map[string]time.Time
+	// emptySince is when the last member left, see roommanager.go
+	emptySince time.Time
+	logs       roomLog
+	// profile is set at creation by CreateRoomWithMode and never changes, nil for plain rooms
+	profile   *roomProfile
+	members   map[string]*Player
+	results   map[string]interface{}
+	timers    map[*time.Timer]struct{}
+	closed    bool
+	countdown *countdown
+
+	// The room goroutine, see actor.go. done is closed by Close.
+	mailbox   chan func()
+	done      chan struct{}
+	tickStart chan *tickLoop
+	ticking   bool
+	// loop is only touched by the room goroutine
+	loop     *tickLoop
+	handlers map[MessageType]RoomHandler
+
+	quota       RoomQuota
+	msgLimiter  *tokenBucket
+	entityStore *EntityStore
+	interest    *interestManager
+	// history is recorded after every tick for lag compensation, see lagcomp.go
+	history     *logic.StateHistory
+	storage     map[string][]byte
+	storedBytes int
+
+	// relays fan broadcasts out in parallel once the room is big, see relays.go
+	relays  []*relay
+	relayOf map[string]*relay
+
+	recording atomic.Pointer[recorder]
+	playback  *Playback
+
+	// idlePolicy overrides the server's for the room's players, see idle.go
+	idlePolicy atomic.Pointer[IdlePolicy]
+
+	// keys is set once the room is encrypted, see encryption.go
+	keys atomic.Pointer[keyRing]
+
+	// rtcPeers are the members signaling WebRTC connections, see signaling.go
+	rtcPeers map[string]struct{}
+}
+
+// CreateRoom creates an empty room, an empty id generates one
+func (gs *GameServer) CreateRoom(id string) (*Room, error) {
+	return gs.createRoom(id, "", nil)
+}
+
+func (gs *GameServer) createRoom(id, mode string, profile *roomProfile) (*Room, error) {
+	gs.roomsMu.Lock()
+	if id == "" {
+		id = generateUniqueID()
+		for gs.rooms[id] != nil {
+			id = generateUniqueID()
+		}
+	} else if _, exists := gs.rooms[id]; exists {
+		gs.roomsMu.Unlock()
+		return nil, ErrRoomExists
+	}
+
+	room := &Room{
+		ID:      id,
+		gs:      gs,
+		mode:    mode,
+		profile: profile,
+		members: make(map[string]*Player),
+		results: make(map[string]interface{}),
+		timers:  make(map[*time.Timer]struct{}),
+		storage: make(map[string][]byte),
+
+		emptySince: time.Now(),
+
+		mailbox:   make(chan func(), roomMailboxSize),
+		done:      make(chan struct{}),
+		tickStart: make(chan *tickLoop, 1),
+	}
+	go room.run()
+	room.entityStore = newEntityStore(room)
+	room.interest = newInterestManager(gs.interestCellSize)
+	room.history = logic.NewStateHistory(gs.historySize)
+	room.SetQuota(gs.defaultRoomQuota)
+	gs.rooms[id] = room
+	gs.roomsMu.Unlock()
+
+	room.Logf("Room created")
+	Publish(gs.events, RoomCreated{Room: room})
+	return room, nil
+}
+
+func (gs *GameServer) GetRoom(id string) (*Room, bool) {
+	gs.roomsMu.RLock()
+	defer gs.roomsMu.RUnlock()
+	room, exists := gs.rooms[id]
+	return room, exists
+}
+
+// snapshotRooms returns the rooms open right now
+func (gs *GameServer) snapshotRooms() []*Room {
+	gs.roomsMu.RLock()
+	defer gs.roomsMu.RUnlock()
+	rooms := make([]*Room, 0, len(gs.rooms))
+	for _, room := range gs.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+// Join moves the player into the room, leaving the previous one if any
+func (r *Room) Join(player *Player) error {
+	if !player.Joined() {
+		return ErrJoinPending
+	}
+
+	// Leave first so two room locks are never held at once
+	if prev := player.room.Load(); prev != nil && prev != r {
+		prev.Leave(player.ID)
+	}
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return ErrRoomClosed
+	}
+	if _, member := r.members[player.ID]; !member && r.profile != nil &&
+		r.profile.MaxPlayers > 0 && len(r.members) >= r.profile.MaxPlayers {
+		r.mu.Unlock()
+		return ErrRoomFull
+	}
+
+	r.members[player.ID] = player
+	player.room.Store(r)
+	r.entityStore.addPlayer(player)
+	r.assignRelayLocked(player)
+	r.mu.Unlock()
+
+	if pos, ok := player.Position(); ok {
+		r.followMove(player, pos)
+	}
+	r.gs.SetPresence(player.AccountID, StatusInGame)
+	r.sendCountdown(player)
+	if keys := r.keys.Load(); keys != nil {
+		r.gs.sendKey(keys, player)
+	}
+	return nil
+}
+
+func (r *Room) Leave(playerID string) {
+	r.mu.Lock()
+	player, ok := r.members[playerID]
+	var peers []string
+	var wasPeer bool
+	if ok {
+		peers, wasPeer = r.rtcLeaveLocked(playerID)
+		delete(r.members, playerID)
+		if len(r.members) == 0 {
+			r.emptySince = time.Now()
+		}
+		player.room.CompareAndSwap(r, nil)
+		r.entityStore.removePlayer(playerID)
+		r.unassignRelayLocked(playerID)
+	}
+	r.mu.Unlock()
+
+	if wasPeer {
+		r.notifyPeers(peers, RTCPeerLeft, playerID)
+	}
+	if ok {
+		r.interest.clear(playerID)
+		r.gs.SetPresence(player.AccountID, StatusOnline)
+		if keys := r.keys.Load(); keys != nil {
+			r.gs.rotateKey(keys, r.Members())
+		}
+	}
+}
+
+// Members returns a snapshot of the players currently in the room
+func (r *Room) Members() []*Player {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	members := make([]*Player, 0, len(r.members))
+	for _, p := range r.members {
+		members = append(members, p)
+	}
+	return members
+}
+
+// Broadcast sends a structured message to every member of the room
+// Failures are logged, use BroadcastReport to handle them yourself.
+func (r *Room) Broadcast(msgType MessageType, payload interface{}) {
+	res := r.BroadcastReport(msgType, payload)
+	if res.Failed > 0 {
+		r.Logf("Broadcast of %s failed for %d of %d members: %v", msgType, res.Failed, res.Sent+res.Failed, res.Errors)
+	}
+}
+
+// BroadcastMessage sends a raw text message to every member of the room, encoded once for
+// all of them like GameServer.BroadcastMessage
+func (r *Room) BroadcastMessage(message []byte) {
+	r.gs.broadcastShared(r.Members(), websocket.TextMessage, message)
+}
+
+// SetResult records a final result (score, placement...) to persist when the room closes
+func (r *Room) SetResult(key string, value interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results[key] = value
+}
+
+// AfterFunc runs fn on the room goroutine after d unless the room is closed first
+func (r *Room) AfterFunc(d time.Duration, fn func()) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return ErrRoomClosed
+	}
+
+	var t *time.Timer
+	t = time.AfterFunc(d, func() {
+		r.mu.Lock()
+		_, pending := r.timers[t]
+		delete(r.timers, t)
+		r.mu.Unlock()
+
+		if pending {
+			r.post(fn)
+		}
+	})
+	r.timers[t] = struct{}{}
+	return nil
+}
+
+// StartTicker runs the room's tick loop, calling fn on the room goroutine every interval
+// until the room closes
+// now is the room's simulation clock: it advances by exactly interval per tick, so it keeps
+// matching game time when the loop is slowed down, sped up or stepped (see simspeed.go)
+// interval <= 0 uses the tick rate of the room's mode, or the server's without one.
+func (r *Room) StartTicker(interval time.Duration, fn func(now time.Time)) error {
+	if interval <= 0 && r.profile != nil && r.profile.TickRate > 0 {
+		interval = time.Second / time.Duration(r.profile.TickRate)
+	}
+	if interval <= 0 {
+		interval = r.gs.TickInterval()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return ErrRoomClosed
+	}
+	if r.ticking {
+		return fmt.Errorf("room %s is already ticking", r.ID)
+	}
+
+	// Sent under mu so tick controls queued from now on are behind it, never blocks as
+	// only one loop is ever sent
+	r.ticking = true
+	batched := r.batched(fn)
+	tick := func(now time.Time) {
+		batched(now)
+		r.recordTick()
+	}
+	r.tickStart <- &tickLoop{interval: interval, scale: 1, now: time.Now(), fn: tick}
+	return nil
+}
+
+// Close tears the room down: stops its timers and tick loop, tells the members why,
+// persists the results and removes the room from the server. Players stay connected.
+// Closing an already closed room does nothing.
+func (r *Room) Close(reason string) error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+
+	for t := range r.timers {
+		t.Stop()
+	}
+	r.timers = nil
+	close(r.done)
+
+	r.stopRelaysLocked()
+	if r.playback != nil {
+		r.playback.Stop()
+	}
+	members := r.members
+	r.members = make(map[string]*Player)
+	r.rtcPeers = nil
+	r.storage = nil
+	r.storedBytes = 0
+	mode, mapName := r.mode, r.mapName
+	result := database.RoomResult{
+		RoomID:   r.ID,
+		Reason:   reason,
+		ClosedAt: time.Now(),
+		Results:  r.results,
+	}
+	r.mu.Unlock()
+
+	if r.Recording() {
+		if err := r.StopRecording(); err != nil {
+			r.Logf("Error finishing recording: %v", err)
+		}
+	}
+
+	for _, player := range members {
+		player.room.CompareAndSwap(r, nil)
+		r.gs.SetPresence(player.AccountID, StatusOnline)
+		if err := r.gs.SendStructuredMessage(player.ID, RoomClosed, RoomClosedPayload{RoomID: r.ID, Reason: reason}); err != nil {
+			r.Logf("Error notifying player %s about the room closing: %v", player.ID, err)
+		}
+	}
+
+	r.gs.requestSurveys(r, mode, mapName, members)
+
+	r.gs.roomsMu.Lock()
+	delete(r.gs.rooms, r.ID)
+	r.gs.roomsMu.Unlock()
+
+	r.Logf("Room closed: %s", reason)
+	Publish(r.gs.events, RoomRemoved{Room: r, Reason: reason})
+	if r.gs.exportRoomLogs {
+		result.Logs = r.ExportLogs()
+	}
+	Publish(r.gs.events, MatchEnded{Room: r, Result: result})
+
+	if r.gs.resultStore != nil {
+		if err := r.gs.resultStore.SaveRoomResult(result); err != nil {
+			return fmt.Errorf("failed to save results of room %s: %v", r.ID, err)
+		}
+	}
+	return nil
+}
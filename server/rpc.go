@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Requests are messages that want an answer. The sender numbers them with req, and the
+// answer, whatever its type, carries that number back in re. A request that fails is
+// answered with ERROR and re instead. Numbers count per sender and connection, the
+// server's and the client's don't mix.
+//
+// Clients send requests to handlers registered with RegisterRequestHandler, or to any
+// handler that answers with Reply. The built-in types answer with plain messages. The
+// server asks a client with Call, which waits for the answer until its context is done
+// or the player leaves. Answers that come after their call gave up are dropped.
+
+// defaultCallTimeout bounds calls whose context has no deadline
+const defaultCallTimeout = 10 * time.Second
+
+// CallError is the ERROR a client answered a call with
+type CallError struct {
+	Code   string
+	Reason string
+}
+
+func (e *CallError) Error() string {
+	return fmt.Sprintf("call rejected with %s: %s", e.Code, e.Reason)
+}
+
+// callState is a player's calls waiting for an answer
+type callState struct {
+	mu      sync.Mutex
+	next    uint64
+	pending map[uint64]chan StructuredMessage
+}
+
+func (c *callState) add() (uint64, chan StructuredMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending == nil {
+		c.pending = make(map[uint64]chan StructuredMessage)
+	}
+	c.next++
+	answer := make(chan StructuredMessage, 1)
+	c.pending[c.next] = answer
+	return c.next, answer
+}
+
+func (c *callState) remove(req uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pending, req)
+}
+
+// answer hands msg to the call it answers, false when nobody waits for it
+func (c *callState) answer(msg StructuredMessage) bool {
+	c.mu.Lock()
+	answer, ok := c.pending[msg.Re]
+	delete(c.pending, msg.Re)
+	c.mu.Unlock()
+
+	if ok {
+		answer <- msg
+	}
+	return ok
+}
+
+// Call sends a request to a player and returns the answer, a *CallError when the client
+// answered with ERROR. Without a deadline on ctx it gives up after 10 seconds.
+func (gs *GameServer) Call(ctx context.Context, playerID string, msgType MessageType, payload interface{}) (StructuredMessage, error) {
+	player, ok := gs.GetPlayer(playerID)
+	if !ok {
+		return StructuredMessage{}, ErrPlayerNotConnected
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultCallTimeout)
+		defer cancel()
+	}
+
+	req, answer := player.calls.add()
+	defer player.calls.remove(req)
+
+	msg := gs.envelopeFor(player, msgType)
+	msg.Req = req
+	data, err := encodeMessage(msg, payload)
+	if err != nil {
+		return StructuredMessage{}, err
+	}
+	if err := player.writeMessage(msgType, data); err != nil {
+		return StructuredMessage{}, err
+	}
+
+	select {
+	case reply := <-answer:
+		if reply.Type == Error {
+			var e ErrorPayload
+			json.Unmarshal(reply.Payload, &e)
+			return reply, &CallError{Code: e.Code, Reason: e.Reason}
+		}
+		return reply, nil
+	case <-player.ctx.Done():
+		return StructuredMessage{}, ErrPlayerNotConnected
+	case <-ctx.Done():
+		return StructuredMessage{}, ctx.Err()
+	}
+}
+
+// Reply answers the request req of a player with a message of msgType. When req isn't a
+// request it's sent as a plain message.
+func (gs *GameServer) Reply(player *Player, req StructuredMessage, msgType MessageType, payload interface{}) error {
+	msg := gs.envelopeFor(player, msgType)
+	msg.Re = req.Req
+	data, err := encodeMessage(msg, payload)
+	if err != nil {
+		return err
+	}
+	return player.writeMessage(msgType, data)
+}
+
+// RegisterRequestHandler is RegisterTypedHandler for requests: what fn returns is sent
+// back as replyType, its error as ERROR. A nil fn removes the handler.
+func RegisterRequestHandler[Req, Resp any](gs *GameServer, msgType, replyType MessageType, fn func(player *Player, request Req) (Resp, error)) {
+	var handler messageHandler
+	if fn != nil {
+		handler = typedHandler(gs, func(player *Player, msg StructuredMessage, request Req) error {
+			response, err := fn(player, request)
+			if err != nil {
+				return err
+			}
+			return gs.Reply(player, msg, replyType, response)
+		})
+	}
+	gs.setTypedHandler(msgType, handler)
+}
+
+// answerCall hands an answer to the call waiting for it, answers skip the routing
+func (gs *GameServer) answerCall(player *Player, msg StructuredMessage) {
+	if !player.calls.answer(msg) {
+		player.tracef("dropping %s answering call %d, nobody waits for it", msg.Type, msg.Re)
+	}
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// With RTT measurement on, the server sends every player a PING each interval and times
+// the PONG that comes back. It works on every transport, unlike WebSocket control frames.
+// The samples are smoothed like TCP does (RFC 6298), with the jitter as the mean deviation.
+// PONG only proves the connection is alive: it keeps the janitor away but doesn't count as
+// activity for idle kicks. Clients get their own RTT in every PING, and in the envelope
+// ("rtt", milliseconds) of the acked message types like GAME_STATE_SYNC when InState is on.
+
+const defaultRTTInterval = 2 * time.Second
+
+// RTTConfig configures RTT measurement, zero values get the defaults
+type RTTConfig struct {
+	// Interval between pings, 2 seconds when 0
+	Interval time.Duration
+	// InState adds the recipient's RTT to the messages that carry the input ack, see
+	// WithAckedMessageTypes
+	InState bool
+}
+
+// rttState is embedded in Player
+type rttState struct {
+	mu sync.Mutex
+	// n and sent are the last ping, a late answer to an earlier one is ignored
+	n       uint64
+	sent    time.Time
+	srtt    time.Duration
+	rttvar  time.Duration
+	last    time.Duration
+	samples int
+}
+
+// RTTStats is a player's measured round trip
+type RTTStats struct {
+	// RTT is the smoothed round trip, 0 before the first sample
+	RTT time.Duration `json:"rtt"`
+	// Jitter is the mean deviation of the samples
+	Jitter  time.Duration `json:"jitter"`
+	Last    time.Duration `json:"last"`
+	Samples int           `json:"samples"`
+}
+
+// RTTStats returns the player's round trip measurements
+func (p *Player) RTTStats() RTTStats {
+	p.rtt.mu.Lock()
+	defer p.rtt.mu.Unlock()
+	return RTTStats{RTT: p.rtt.srtt, Jitter: p.rtt.rttvar, Last: p.rtt.last, Samples: p.rtt.samples}
+}
+
+// RTT is the player's smoothed round trip, 0 before it was measured
+func (p *Player) RTT() time.Duration {
+	p.rtt.mu.Lock()
+	defer p.rtt.mu.Unlock()
+	return p.rtt.srtt
+}
+
+// runPings pings every player each interval until the server shuts down
+func (gs *GameServer) runPings() {
+	ticker := time.NewTicker(gs.rtt.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, player := range gs.snapshotPlayers() {
+				// Bots have no connection to measure
+				if player.bot == nil && player.Joined() {
+					gs.ping(player)
+				}
+			}
+		case <-gs.stop:
+			return
+		}
+	}
+}
+
+func (gs *GameServer) ping(player *Player) {
+	s := &player.rtt
+	s.mu.Lock()
+	s.n++
+	payload := PingPayload{N: s.n, RTT: rttMillis(s.srtt)}
+	s.sent = time.Now()
+	s.mu.Unlock()
+
+	if err := gs.SendPing(player.ID, payload); err != nil {
+		player.tracef("ping failed: %v", err)
+	}
+}
+
+// rttMillis is the RTT as sent to clients, a measured one is at least 1 so 0 keeps
+// meaning "not measured yet" on fast local connections
+func rttMillis(rtt time.Duration) int64 {
+	if rtt > 0 && rtt < time.Millisecond {
+		return 1
+	}
+	return rtt.Milliseconds()
+}
+
+// handlePong takes a sample from the answer to the last ping
+func (gs *GameServer) handlePong(player *Player, msg StructuredMessage) error {
+	now := time.Now()
+	var payload PongPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid pong: %v", err)
+	}
+
+	s := &player.rtt
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if payload.N != s.n || s.sent.IsZero() {
+		return nil
+	}
+	sample := now.Sub(s.sent)
+	s.sent = time.Time{}
+	s.last = sample
+	if s.samples == 0 {
+		s.srtt, s.rttvar = sample, sample/2
+	} else {
+		s.rttvar = (3*s.rttvar + (s.srtt - sample).Abs()) / 4
+		s.srtt = (7*s.srtt + sample) / 8
+	}
+	s.samples++
+	return nil
+}
+
+// playerNetStats is one entry of NetStatsHandler
+type playerNetStats struct {
+	PlayerID  string     `json:"player_id"`
+	AccountID string     `json:"account_id"`
+	RTT       RTTStats   `json:"rtt"`
+	Writes    WriteStats `json:"writes"`
+}
+
+// NetStatsHandler is an admin API for the connection quality of the players:
+//
+//	GET /admin/netstats              every player
+//	GET /admin/netstats?player_id=.. one player
+//
+// Durations are in nanoseconds. It has no authentication, only mount it on a private mux
+// or behind admin auth.
+func (gs *GameServer) NetStatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		players := gs.snapshotPlayers()
+		if id := r.URL.Query().Get("player_id"); id != "" {
+			player, ok := gs.GetPlayer(id)
+			if !ok {
+				http.Error(w, ErrPlayerNotConnected.Error(), http.StatusNotFound)
+				return
+			}
+			players = []*Player{player}
+		}
+
+		stats := make([]playerNetStats, 0, len(players))
+		for _, p := range players {
+			stats = append(stats, playerNetStats{PlayerID: p.ID, AccountID: p.AccountID, RTT: p.RTTStats(), Writes: p.WriteStats()})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(stats); err != nil {
+			log.Printf("Error writing net stats: %v", err)
+		}
+	})
+}
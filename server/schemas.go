@@ -0,0 +1,110 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// The schema registry (messageSchemas in messages_gen.go) is generated from messages.json like
+// everything else about messages. Inbound messages are checked against it before they're
+// handled, so handlers only see known types in a version and shape they understand.
+
+// MessageSchema describes one message type
+type MessageSchema struct {
+	Type MessageType `json:"type"`
+	// Direction is client, server or both
+	Direction string `json:"direction"`
+	// Payload names the payload struct, empty when the payload is free-form
+	Payload  string `json:"payload,omitempty"`
+	Version  int    `json:"version"`
+	Gameplay bool   `json:"gameplay,omitempty"`
+
+	newPayload func() interface{}
+}
+
+// FromClient reports whether clients may send the message
+func (s MessageSchema) FromClient() bool {
+	return s.Direction == "client" || s.Direction == "both"
+}
+
+// SchemaViolation is why an inbound message was rejected, it's sent back as SCHEMA_ERROR
+type SchemaViolation struct {
+	Type      MessageType
+	Version   int
+	Supported int
+	Reason    string
+	// Field and Rule are set when a payload field broke a validation rule, see validation.go
+	Field string
+	Rule  string
+}
+
+func (e *SchemaViolation) Error() string {
+	return fmt.Sprintf("schema error on %s: %s", e.Type, e.Reason)
+}
+
+// Schema returns the schema of a message type
+func Schema(msgType MessageType) (MessageSchema, bool) {
+	s, ok := messageSchemas[msgType]
+	return s, ok
+}
+
+// Schemas lists every message type, sorted by type
+func Schemas() []MessageSchema {
+	schemas := make([]MessageSchema, 0, len(messageSchemas))
+	for _, s := range messageSchemas {
+		schemas = append(schemas, s)
+	}
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].Type < schemas[j].Type })
+	return schemas
+}
+
+// checkSchema validates an inbound message against the registry, then its payload against
+// the validation rules. A missing version is taken as the current one, so clients that don't
+// send it keep working.
+func (gs *GameServer) checkSchema(msg StructuredMessage) *SchemaViolation {
+	s, ok := messageSchemas[msg.Type]
+	if !ok {
+		return &SchemaViolation{Type: msg.Type, Version: msg.Version, Reason: "unknown message type"}
+	}
+	if !s.FromClient() {
+		return &SchemaViolation{Type: msg.Type, Version: msg.Version, Supported: s.Version, Reason: "not accepted from clients"}
+	}
+	if msg.Version != 0 && msg.Version != s.Version {
+		return &SchemaViolation{Type: msg.Type, Version: msg.Version, Supported: s.Version,
+			Reason: fmt.Sprintf("version %d is not supported, use %d", msg.Version, s.Version)}
+	}
+	if s.newPayload != nil {
+		payload := s.newPayload()
+		if len(msg.Payload) > 0 {
+			if err := json.Unmarshal(msg.Payload, payload); err != nil {
+				return s.decodeViolation(msg, s.Payload, err)
+			}
+		}
+		if err := ValidatePayload(payload); err != nil {
+			return s.violation(msg, err)
+		}
+	}
+	if validate := gs.payloadValidators[msg.Type]; validate != nil {
+		if err := validate(msg.Payload); err != nil {
+			return s.violation(msg, err)
+		}
+	}
+	return nil
+}
+
+// rejectSchema tells the client why its message was dropped
+func (gs *GameServer) rejectSchema(player *Player, msg StructuredMessage, v *SchemaViolation) {
+	payload := SchemaErrorPayload{
+		Type:      string(v.Type),
+		Version:   v.Version,
+		Supported: v.Supported,
+		Seq:       msg.Seq,
+		Reason:    v.Reason,
+		Field:     v.Field,
+		Rule:      v.Rule,
+	}
+	if sendErr := gs.SendSchemaError(player.ID, payload); sendErr != nil {
+		gs.logPlayerf(player, "Error sending schema error to player %s: %v", player.ID, sendErr)
+	}
+}
@@ -0,0 +1,395 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Game rules can live in Lua scripts instead of Go, so designers iterate without a rebuild.
+// Every *.lua file of the script directory runs once at load, in name order, and registers
+// what it handles on the global game table:
+//
+//	game.on("CHAT_MESSAGE", function(player_id, payload, room_id)
+//	  if payload.text == "" then error("empty message") end
+//	  game.broadcast("CHAT_MESSAGE", {from = player_id, text = payload.text})
+//	  return true -- handled, skips the built-in routing
+//	end)
+//
+//	game.on_tick(function(room_id, now) game.room_broadcast(room_id, "TICK", {t = now}) end)
+//
+// Besides on and on_tick, scripts get game.send(player_id, type, payload),
+// game.broadcast(type, payload), game.room_broadcast(room_id, type, payload) and
+// game.log(...). Only the base, table, string and math libraries are loaded, scripts
+// can't touch files or the process.
+//
+// All scripts share one VM, so calls into it are serialized and a call running past
+// CallTimeout is aborted. Reloading builds a fresh VM from the directory and swaps it in
+// only once every file loaded, a broken script leaves the running ones in place.
+
+const (
+	defaultScriptCallTimeout = 100 * time.Millisecond
+	// maxScriptDepth stops payload conversion on self-referencing tables
+	maxScriptDepth = 32
+)
+
+// ScriptConfig turns on Lua scripting, see WithScripts
+type ScriptConfig struct {
+	// Dir holds the *.lua files
+	Dir string
+	// PollInterval is how often Dir is checked for changed files, 0 only reloads
+	// through ReloadScripts
+	PollInterval time.Duration
+	// CallTimeout bounds a single handler or tick call, 100ms when 0
+	CallTimeout time.Duration
+}
+
+// scripts is the live script set of a server, nil without WithScripts
+type scripts struct {
+	gs  *GameServer
+	cfg ScriptConfig
+	vm  atomic.Pointer[scriptVM]
+	// reloadMu keeps two reloads from racing to swap their VM in
+	reloadMu sync.Mutex
+	version  string
+}
+
+// scriptVM is one loaded generation of the scripts
+type scriptVM struct {
+	mu       sync.Mutex
+	L        *lua.LState
+	closed   bool
+	handlers map[MessageType]*lua.LFunction
+	tick     *lua.LFunction
+}
+
+// ErrScriptsDisabled is returned by the script API of a server without WithScripts
+var ErrScriptsDisabled = errors.New("scripting is not enabled")
+
+// ReloadScripts loads the script directory again, on error the running scripts stay
+func (gs *GameServer) ReloadScripts() error {
+	if gs.scripts == nil {
+		return ErrScriptsDisabled
+	}
+	return gs.scripts.reload()
+}
+
+// StartScriptTicker runs the on_tick handler of the scripts as the room's tick loop,
+// interval works as in StartTicker. It picks up reloaded scripts on the next tick.
+func (r *Room) StartScriptTicker(interval time.Duration) error {
+	s := r.gs.scripts
+	if s == nil {
+		return ErrScriptsDisabled
+	}
+	return r.StartTicker(interval, func(now time.Time) {
+		if err := s.runTick(r, now); err != nil {
+			r.Logf("Script tick failed: %v", err)
+		}
+	})
+}
+
+func (s *scripts) reload() error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	files, version, err := s.scan()
+	if err != nil {
+		return err
+	}
+	vm, err := s.load(files)
+	if err != nil {
+		return err
+	}
+
+	old := s.vm.Swap(vm)
+	s.version = version
+	if old != nil {
+		old.close()
+	}
+	log.Printf("Loaded %d scripts from %s (%d message handlers, tick: %v)", len(files), s.cfg.Dir, len(vm.handlers), vm.tick != nil)
+	return nil
+}
+
+// scan lists the scripts, version changes whenever a file is added, removed or modified
+func (s *scripts) scan() ([]string, string, error) {
+	files, err := filepath.Glob(filepath.Join(s.cfg.Dir, "*.lua"))
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Strings(files)
+
+	var version strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, "", err
+		}
+		fmt.Fprintf(&version, "%s:%d:%d;", file, info.Size(), info.ModTime().UnixNano())
+	}
+	return files, version.String(), nil
+}
+
+// watch reloads the scripts when the directory changes, until the server shuts down
+func (s *scripts) watch() {
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.gs.stop:
+			return
+		}
+
+		_, version, err := s.scan()
+		s.reloadMu.Lock()
+		changed := err == nil && version != s.version
+		s.reloadMu.Unlock()
+		if !changed {
+			continue
+		}
+
+		if err := s.reload(); err != nil {
+			log.Printf("Script reload failed, keeping the running scripts: %v", err)
+			// Don't retry the same broken files every poll
+			s.reloadMu.Lock()
+			s.version = version
+			s.reloadMu.Unlock()
+		}
+	}
+}
+
+func (s *scripts) load(files []string) (*scriptVM, error) {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	// The base library can still reach the file system
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring", "require", "module"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	vm := &scriptVM{L: L, handlers: make(map[MessageType]*lua.LFunction)}
+	L.SetGlobal("game", s.gameTable(vm))
+
+	for _, file := range files {
+		ctx, cancel := context.WithTimeout(context.Background(), s.cfg.CallTimeout)
+		L.SetContext(ctx)
+		err := L.DoFile(file)
+		cancel()
+		if err != nil {
+			L.Close()
+			return nil, fmt.Errorf("%s: %w", filepath.Base(file), err)
+		}
+	}
+	L.RemoveContext()
+	return vm, nil
+}
+
+// gameTable is the API the scripts see
+func (s *scripts) gameTable(vm *scriptVM) *lua.LTable {
+	gs := s.gs
+	return vm.L.SetFuncs(vm.L.NewTable(), map[string]lua.LGFunction{
+		"on": func(L *lua.LState) int {
+			vm.handlers[MessageType(L.CheckString(1))] = L.CheckFunction(2)
+			return 0
+		},
+		"on_tick": func(L *lua.LState) int {
+			vm.tick = L.CheckFunction(1)
+			return 0
+		},
+		"send": func(L *lua.LState) int {
+			payload := fromLua(L.Get(3), 0)
+			if err := gs.SendStructuredMessage(L.CheckString(1), MessageType(L.CheckString(2)), payload); err != nil {
+				L.Push(lua.LString(err.Error()))
+				return 1
+			}
+			return 0
+		},
+		"broadcast": func(L *lua.LState) int {
+			res := gs.BroadcastTo(func(*Player) bool { return true }, MessageType(L.CheckString(1)), fromLua(L.Get(2), 0))
+			L.Push(lua.LNumber(res.Sent))
+			return 1
+		},
+		"room_broadcast": func(L *lua.LState) int {
+			room, ok := gs.GetRoom(L.CheckString(1))
+			if !ok {
+				L.Push(lua.LNumber(0))
+				return 1
+			}
+			res := room.BroadcastReport(MessageType(L.CheckString(2)), fromLua(L.Get(3), 0))
+			L.Push(lua.LNumber(res.Sent))
+			return 1
+		},
+		"log": func(L *lua.LState) int {
+			parts := make([]string, L.GetTop())
+			for i := range parts {
+				parts[i] = L.ToStringMeta(L.Get(i + 1)).String()
+			}
+			log.Printf("[script] %s", strings.Join(parts, " "))
+			return 0
+		},
+	})
+}
+
+// handle runs the script handler of msg's type, if any
+func (s *scripts) handle(player *Player, msg StructuredMessage) (bool, error) {
+	if s == nil {
+		return false, nil
+	}
+
+	var payload interface{}
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return false, fmt.Errorf("invalid payload for %s: %v", msg.Type, err)
+		}
+	}
+	roomID := ""
+	if room := player.room.Load(); room != nil {
+		roomID = room.ID
+	}
+
+	var handled bool
+	ran, err := s.call(player.Context(), func(vm *scriptVM) (*lua.LFunction, []lua.LValue) {
+		return vm.handlers[msg.Type], []lua.LValue{lua.LString(player.ID), toLua(vm.L, payload), lua.LString(roomID)}
+	}, func(ret lua.LValue) { handled = lua.LVAsBool(ret) })
+	if err != nil {
+		return true, fmt.Errorf("script handler for %s: %w", msg.Type, err)
+	}
+	return ran && handled, nil
+}
+
+func (s *scripts) runTick(room *Room, now time.Time) error {
+	_, err := s.call(context.Background(), func(vm *scriptVM) (*lua.LFunction, []lua.LValue) {
+		return vm.tick, []lua.LValue{lua.LString(room.ID), lua.LNumber(float64(now.UnixNano()) / 1e9)}
+	}, nil)
+	return err
+}
+
+// call runs the function pick chooses on the current VM, ran is false when there is none
+// Cancelling ctx aborts the call like its timeout.
+func (s *scripts) call(ctx context.Context, pick func(vm *scriptVM) (*lua.LFunction, []lua.LValue), result func(lua.LValue)) (ran bool, err error) {
+	vm := s.vm.Load()
+	if vm == nil {
+		return false, nil
+	}
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	// Lost a race with a reload, the next message or tick gets the new scripts
+	if vm.closed {
+		return false, nil
+	}
+
+	fn, args := pick(vm)
+	if fn == nil {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.CallTimeout)
+	defer cancel()
+	vm.L.SetContext(ctx)
+	defer vm.L.RemoveContext()
+
+	if err := vm.L.CallByParam(lua.P{Fn: fn, NRet: 1, Protect: true}, args...); err != nil {
+		return true, err
+	}
+	ret := vm.L.Get(-1)
+	vm.L.Pop(1)
+	if result != nil {
+		result(ret)
+	}
+	return true, nil
+}
+
+func (vm *scriptVM) close() {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	vm.closed = true
+	vm.L.Close()
+}
+
+// toLua converts decoded JSON into Lua values
+func toLua(L *lua.LState, v interface{}) lua.LValue {
+	switch v := v.(type) {
+	case nil:
+		return lua.LNil
+	case bool:
+		return lua.LBool(v)
+	case float64:
+		return lua.LNumber(v)
+	case string:
+		return lua.LString(v)
+	case []interface{}:
+		t := L.CreateTable(len(v), 0)
+		for _, item := range v {
+			t.Append(toLua(L, item))
+		}
+		return t
+	case map[string]interface{}:
+		t := L.CreateTable(0, len(v))
+		for key, item := range v {
+			t.RawSetString(key, toLua(L, item))
+		}
+		return t
+	default:
+		return lua.LString(fmt.Sprint(v))
+	}
+}
+
+// fromLua converts a Lua value into something encoding/json handles. Tables with only the
+// keys 1..n become arrays, any other table an object with string keys.
+func fromLua(v lua.LValue, depth int) interface{} {
+	switch v := v.(type) {
+	case lua.LBool:
+		return bool(v)
+	case lua.LNumber:
+		return float64(v)
+	case lua.LString:
+		return string(v)
+	case *lua.LTable:
+		if depth >= maxScriptDepth {
+			return nil
+		}
+		if n := v.MaxN(); n > 0 && n == countKeys(v) {
+			items := make([]interface{}, 0, n)
+			for i := 1; i <= n; i++ {
+				items = append(items, fromLua(v.RawGetInt(i), depth+1))
+			}
+			return items
+		}
+		obj := make(map[string]interface{})
+		v.ForEach(func(key, value lua.LValue) {
+			obj[key.String()] = fromLua(value, depth+1)
+		})
+		return obj
+	default:
+		return nil
+	}
+}
+
+func countKeys(t *lua.LTable) int {
+	n := 0
+	t.ForEach(func(lua.LValue, lua.LValue) { n++ })
+	return n
+}
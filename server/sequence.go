@@ -0,0 +1,64 @@
+package server
+
+import (
+	"bytes"
+	"log"
+	"strconv"
+	"sync/atomic"
+)
+
+// Every structured message to a connection is stamped with "sseq", counting up from 1
+// in the order the frames are written. A client that sees a gap or a number go backwards
+// lost or reordered something. A new connection starts over at 1, one that resumed a
+// session carries on after the last sseq of the old one, see resume.go.
+// Raw text and binary frames are not numbered.
+
+// outboundSeq is embedded in Player
+type outboundSeq struct {
+	// last is the last sseq handed out, guarded by Player.mu
+	last uint64
+	// delivered is the last sseq written without error
+	delivered atomic.Uint64
+}
+
+// LastDeliveredSeq is the sseq of the last structured message written to the player,
+// a client resuming with a lower one missed what came after it
+func (p *Player) LastDeliveredSeq() uint64 {
+	return p.out.delivered.Load()
+}
+
+// stampSeq returns a copy of the encoded message with "sseq" added as its last key,
+// so it wins over an sseq a forwarded client message may carry
+func stampSeq(data []byte, seq uint64) []byte {
+	trimmed := bytes.TrimRight(data, " \t\r\n")
+	if len(trimmed) < 2 || trimmed[len(trimmed)-1] != '}' {
+		return data
+	}
+	body := trimmed[:len(trimmed)-1]
+
+	out := make([]byte, 0, len(trimmed)+24)
+	out = append(out, body...)
+	if len(bytes.TrimSpace(body)) > 1 {
+		out = append(out, ',')
+	}
+	out = append(out, `"sseq":`...)
+	out = strconv.AppendUint(out, seq, 10)
+	return append(out, '}')
+}
+
+// broadcastEncoded forwards an encoded structured message to every connected player
+func (gs *GameServer) broadcastEncoded(msgType MessageType, message []byte) {
+	var recipients []*Player
+	for _, player := range gs.snapshotPlayers() {
+		if player.Joined() {
+			recipients = append(recipients, player)
+		}
+	}
+	gs.fanOut(recipients, func(player *Player) error {
+		err := player.writeMessage(msgType, message)
+		if err != nil {
+			log.Printf("Error broadcasting to player %s: %v", player.ID, err)
+		}
+		return err
+	}, "broadcast")
+}
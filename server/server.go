@@ -0,0 +1,1123 @@
+// Package server contains the game server implementation.
+//
+// Everything exported here may change between versions of the template.
+// Projects built on top of it should import server/v1 instead, which only
+// changes in backwards compatible ways.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/iknizzz1807/socket-server-template/database"
+	"github.com/iknizzz1807/socket-server-template/logic"
+	"github.com/iknizzz1807/socket-server-template/messages"
+	"github.com/quic-go/webtransport-go"
+	"google.golang.org/grpc"
+)
+
+type Player struct {
+	ID string
+	// AccountID identifies who is playing, several connections can share one account
+	AccountID string
+	// Conn is the websocket of players connected to /ws or /spectate, nil for every other
+	// transport. Prefer Transport, which works for all of them.
+	Conn         *websocket.Conn
+	LastActivity time.Time
+	SlotClass    SlotClass
+	mu           sync.Mutex
+
+	// reservedSlot is set while the player takes a reserved slot, under gs.playersMu
+	reservedSlot bool
+
+	// gate orders the writers waiting for the connection by priority, taken before mu
+	gate       writeGate
+	priorities map[MessageType]Priority
+
+	// room is the room the player is currently in, nil if none
+	room atomic.Pointer[Room]
+
+	// lastInputSeq is the highest input sequence processed, echoed back as Ack
+	lastInputSeq atomic.Uint64
+
+	// spectating is only changed with GameServer.playersMu held
+	spectating atomic.Bool
+
+	party atomic.Pointer[Party]
+
+	// debug turns on verbose tracing for just this connection, see SetDebug
+	debug atomic.Bool
+
+	metrics     *metrics
+	metricShard uint32
+
+	// Every write gets a deadline, writeTimedOut is set once one of them passed
+	writeTimeout  time.Duration
+	writeTimedOut atomic.Bool
+
+	// disconnectReason is why the connection ended, set once
+	disconnectReason atomic.Pointer[DisconnectReason]
+
+	// persist is the player's ordered persistence queue, nil without an activity store
+	persist *persistQueue
+
+	// onboarding is set while a new account goes through onboarding, see onboarding.go
+	onboarding *onboardingState
+
+	// session is what survives a reconnect, nil without a session store, see resume.go
+	session *playerSession
+
+	// remoteIP counts against the per-IP connection cap until the connection closes
+	remoteIP   string
+	ipReleased atomic.Bool
+
+	// meters are the player's action points, stamina, cooldowns... see meters.go
+	meters playerMeters
+
+	// move is the authoritative position movement is validated against
+	move moveState
+	// viewRadius holds the float64 bits of the radius set with SetViewRadius
+	viewRadius atomic.Uint64
+
+	// bot is set for players added with AddBot, it's their transport too
+	bot *botLink
+
+	transport Transport
+
+	// batch collects the structured messages of a room tick, see batching.go
+	batch atomic.Pointer[outbox]
+
+	// ctx is cancelled by closeConn and sendClose, see context.go
+	ctx    context.Context
+	cancel context.CancelFunc
+	// udp is set once the player was handed a UDP token, see udp.go
+	udp atomic.Pointer[udpLink]
+	// greeted is set when OnConnect would run, OnDisconnect only runs for those players
+	greeted atomic.Bool
+	// inboundMu keeps the UDP channel and the main connection from running frames side by side
+	inboundMu sync.Mutex
+
+	// protocol is what the client negotiated with HELLO, see handshake.go
+	protocol protocolState
+
+	// writes tracks how the connection keeps up, judged by slowPolicy (nil when off)
+	writes     writeStats
+	slowPolicy *slowConsumerPolicy
+
+	// out numbers the structured messages sent to the player, see sequence.go
+	out outboundSeq
+
+	// dedup remembers recent message ids to drop retried duplicates, nil when off
+	dedup *dedupWindow
+
+	// joinFailures throttles wrong room passwords, see private.go
+	joinFailures *tokenBucket
+
+	// idle is when the player last sent something, see idle.go
+	idle idleState
+
+	// rtt is the measured round trip, see rtt.go
+	rtt rttState
+
+	// calls are the server's requests waiting for the player's answer, see rpc.go
+	calls callState
+	// transfers are the chunked transfers in progress, see transfers.go
+	transfers transferState
+	// voice holds the player's voice budgets, see voice.go
+	voice voiceState
+
+	// joinPending is set until the player's JOIN is accepted, see join.go
+	joinPending atomic.Bool
+	profile     atomic.Pointer[PlayerProfile]
+
+	// device and connectedAt describe the connection in the account's session list
+	device      string
+	connectedAt time.Time
+}
+
+type GameServer struct {
+	players *playerRegistry
+	// playersMu guards capacity and the slot counts, every add and remove of players
+	// happens under it. Lookups only need the registry, see registry.go.
+	playersMu    sync.RWMutex
+	upgrader     websocket.Upgrader
+	maxPlayers   int
+	readTimeout  time.Duration
+	tickRate     int
+	writeTimeout time.Duration
+
+	// queue holds connections waiting for a free slot, nil when disabled
+	queue *waitingQueue
+
+	// Extra capacity per slot class on top of maxPlayers, see takeSlotLocked
+	reservedSlots  map[SlotClass]int
+	reservedInUse  map[SlotClass]int
+	slotClassifier SlotClassifier
+
+	// Spectators live in the players map too but have their own capacity
+	maxSpectators int
+	spectators    int
+
+	// interestCellSize is the grid cell size of every room's interest, see interest.go
+	interestCellSize float64
+
+	// historySize is how many ticks each room keeps for lag compensation, see lagcomp.go
+	historySize int
+	maxRewind   time.Duration
+	// history is only fed by the deprecated GameServer.RecordTick
+	history *logic.StateHistory
+
+	rooms            map[string]*Room
+	roomsMu          sync.RWMutex
+	modes            map[string]ModeProfile
+	resultStore      database.ResultStore
+	defaultRoomQuota RoomQuota
+
+	// ackedTypes are outbound message types that carry the recipient's last processed input
+	ackedTypes map[MessageType]bool
+	// payloadValidators check inbound payloads on top of their rules, see validation.go
+	payloadValidators map[MessageType]PayloadValidator
+
+	parties      map[string]*Party
+	partiesMu    sync.RWMutex
+	maxPartySize int
+
+	presence        *presenceTracker
+	friendStore     database.FriendStore
+	accountResolver AccountResolver
+	// login mints and checks the tokens of the login endpoints, nil when disabled
+	login *login
+	// injector checks requests to /inject, nil when disabled
+	injector *injector
+	// partyEncryption encrypts every new party, see encryption.go
+	partyEncryption bool
+	// sessions lets clients resume after a reconnect, nil when disabled
+	sessions *sessions
+	// ratings and matchmaker are nil when disabled, see ratings.go and matchmaking.go
+	ratings    *ratings
+	matchmaker *matchmaker
+	// webhooks is nil without webhooks, see webhooks.go
+	webhooks *webhooks
+	// discord is nil without the Discord bot, see discord.go
+	discord *discordBot
+	// roomManager is nil when disabled, see roommanager.go
+	roomManager *RoomManagerConfig
+	// rtt is nil without RTT measurement, see rtt.go
+	rtt *RTTConfig
+	// chunks is nil without chunked transfers, see transfers.go
+	chunks *ChunkConfig
+	// signaling is nil without WebRTC signaling, see signaling.go
+	signaling *SignalingConfig
+	// voice is nil without the voice relay, see voice.go
+	voice *VoiceConfig
+	// registration is nil unless the server reports to a coordinator, see coordinator.go
+	registration *registration
+	// netsim wraps every connection when set, see netsim.go
+	netsim *NetSimConfig
+	// tournaments are the brackets created with CreateTournament
+	tournaments tournaments
+
+	blockStore      database.BlockStore
+	offlineMessages *offlineMessages
+
+	compression *compressionConfig
+
+	metrics *metrics
+
+	// origins is checked on every upgrade, see origin.go
+	origins atomic.Pointer[originPolicy]
+
+	activityStore database.ActivityStore
+	persistWG     sync.WaitGroup
+
+	// mux holds the public endpoints, built once by Handler
+	routesOnce  sync.Once
+	mux         *http.ServeMux
+	serversMu   sync.Mutex
+	httpServers []*http.Server
+	// webTransport is set by StartWebTransport
+	webTransport *webtransport.Server
+	tcpListeners []net.Listener
+	udp          *udpChannel
+	grpcServer   *grpc.Server
+	grpcAddr     string
+	adminServer  *http.Server
+	// coordinatorServer is set by StartCoordinator, see coordinator.go
+	coordinatorServer *http.Server
+
+	// shuttingDown fails /readyz from the moment Shutdown is called
+	shuttingDown atomic.Bool
+	// stop is closed by Shutdown, every background loop of the server returns then
+	stop         chan struct{}
+	stopOnce     sync.Once
+	healthChecks []namedHealthCheck
+
+	events *EventBus
+
+	hooksMu sync.Mutex
+	hooks   atomic.Pointer[hooks]
+
+	// scripts are the Lua game scripts, nil without WithScripts
+	scripts *scripts
+
+	pluginsMu sync.RWMutex
+	plugins   []*plugin
+
+	// batchBytes turns on tick batching, it's the size a batch is flushed at early
+	batchBytes int
+
+	// broadcastWorkers write broadcasts in parallel, see fanout.go. No pool when 0.
+	broadcastWorkers int
+	fanout           *broadcastPool
+
+	// nodeID identifies this server in room logs
+	nodeID string
+	// region and pingURL are the lobby's ping hints, see WithRegion
+	region, pingURL string
+	exportRoomLogs  bool
+
+	onboarding *onboarding
+
+	// config is what the server was configured with, nil without NewGameServerFromConfig
+	config   *Config
+	configMu sync.Mutex
+
+	ipLimit    *ipLimiter
+	ipResolver func(r *http.Request) string
+
+	meterRules  map[string]logic.MeterRule
+	actionCosts map[MessageType][]actionCost
+
+	banStore database.BanStore
+
+	movement *movementValidator
+
+	relays *relayConfig
+
+	metricsHistory *metricsHistory
+
+	// surveys is the end-of-match survey, nil when disabled
+	surveys *surveys
+
+	slowPolicy *slowConsumerPolicy
+
+	// priorities classes the message types in the send queues, see priority.go
+	priorities map[MessageType]Priority
+
+	// reliable tracks messages waiting for MESSAGE_ACK, nil when disabled
+	reliable *reliableDelivery
+
+	// dedupWindow is how many message ids are remembered per connection, 0 turns it off
+	dedupWindow int
+
+	// idle warns and kicks idle players, nil leaves it to the read timeout
+	idle *IdleConfig
+
+	// janitorInterval is how often leaked players are swept, 0 when the janitor is off
+	janitorInterval time.Duration
+
+	// join requires a JOIN with a valid profile from new connections, nil when off
+	join *joinHandshake
+
+	// sessionPolicy handles accounts connecting twice, nil allows it silently
+	sessionPolicy *SessionPolicy
+}
+
+// ErrServerFull is returned by RegisterPlayer when every slot is taken
+var ErrServerFull = errors.New("server is full")
+
+//go:generate go run ../cmd/msggen -defs messages.json -go messages_gen.go -client ../client/messages_gen.go -ts ../test_client/messages.gen.ts -docs ../docs/messages.md
+
+type MessageType string
+
+type StructuredMessage struct {
+	Type     MessageType     `json:"type"`
+	PlayerID string          `json:"player_id"`
+	Payload  json.RawMessage `json:"payload"`
+	// Timestamp is when the message was sent, in unix milliseconds, see clock.go
+	Timestamp int64 `json:"timestamp"`
+	// Seq is the client's input sequence number, set on PLAYER_MOVE and other inputs
+	Seq uint64 `json:"seq,omitempty"`
+	// Ack is the last input sequence the server processed for the recipient
+	Ack uint64 `json:"ack,omitempty"`
+	// RTT is the recipient's round trip in milliseconds, next to Ack (see rtt.go)
+	RTT uint64 `json:"rtt,omitempty"`
+	// Version of the payload format, see schemas.go
+	Version int `json:"v,omitempty"`
+	// ID of a reliable message, acknowledged with MESSAGE_ACK (see reliable.go)
+	ID uint64 `json:"id,omitempty"`
+	// DedupID is put on client messages that may be retried (see dedup.go)
+	DedupID uint64 `json:"dedup,omitempty"`
+	// Req numbers a request, answered by a message with the same number in Re (see rpc.go)
+	Req uint64 `json:"req,omitempty"`
+	Re  uint64 `json:"re,omitempty"`
+	// ServerSeq numbers the messages of a connection, stamped when written (see sequence.go)
+	ServerSeq uint64 `json:"sseq,omitempty"`
+}
+
+// The message types themselves are listed in messages.json,
+// run go generate after changing it to update messages_gen.go and the client stubs
+
+func NewGameServer(maxPlayers int, opts ...Option) *GameServer {
+	gs := &GameServer{
+		players:          newPlayerRegistry(),
+		maxPlayers:       maxPlayers,
+		readTimeout:      defaultReadTimeout,
+		tickRate:         defaultTickRate,
+		writeTimeout:     defaultWriteTimeout,
+		interestCellSize: defaultInterestCellSize,
+		historySize:      defaultHistorySize,
+		history:          logic.NewStateHistory(defaultHistorySize),
+		maxRewind:        defaultMaxRewind,
+		rooms:            make(map[string]*Room),
+		parties:          make(map[string]*Party),
+		maxPartySize:     defaultMaxPartySize,
+		presence:         newPresenceTracker(),
+		metrics:          newMetrics(),
+		events:           newEventBus(),
+		ackedTypes:       map[MessageType]bool{GameStateSync: true},
+		reservedSlots:    make(map[SlotClass]int),
+		reservedInUse:    make(map[SlotClass]int),
+		slotClassifier:   defaultSlotClassifier,
+		nodeID:           defaultNodeID(),
+		ipLimit:          newIPLimiter(),
+		dedupWindow:      defaultDedupWindow,
+		priorities:       defaultPriorities(),
+		stop:             make(chan struct{}),
+
+		broadcastWorkers: defaultBroadcastWorkers(),
+	}
+
+	gs.upgrader.CheckOrigin = gs.checkOrigin
+	gs.origins.Store(newOriginPolicy(nil, false))
+
+	for _, opt := range opts {
+		opt(gs)
+	}
+
+	go gs.runMetricsAggregator(defaultMetricsInterval)
+	if gs.metricsHistory != nil {
+		go gs.recordMetricsHistory()
+	}
+	if gs.idle != nil {
+		go gs.runIdleChecks()
+	}
+	if gs.janitorInterval > 0 {
+		go gs.runJanitor(gs.janitorInterval)
+	}
+	if gs.matchmaker != nil {
+		go gs.runMatchmaker()
+	}
+	if gs.roomManager != nil {
+		go gs.runRoomManager()
+	}
+	if gs.webhooks != nil {
+		gs.startWebhooks()
+	}
+	if gs.discord != nil {
+		gs.startDiscord()
+	}
+	if gs.rtt != nil {
+		go gs.runPings()
+	}
+	if gs.registration != nil {
+		go gs.runRegistration()
+	}
+	if gs.broadcastWorkers > 0 {
+		gs.fanout = newBroadcastPool(gs.broadcastWorkers)
+	}
+
+	return gs
+}
+
+func (gs *GameServer) newPlayer(t Transport, class SlotClass) *Player {
+	id := generateUniqueID()
+	player := &Player{
+		ID:           id,
+		AccountID:    id,
+		transport:    gs.simulateNetwork(t),
+		LastActivity: time.Now(),
+		SlotClass:    class,
+		metrics:      gs.metrics,
+		metricShard:  gs.metrics.assignShard(),
+		writeTimeout: gs.writeTimeout,
+		slowPolicy:   gs.slowPolicy,
+		priorities:   gs.priorities,
+		dedup:        newDedupWindow(gs.dedupWindow),
+		joinFailures: newTokenBucket(roomJoinRetryRate, roomJoinBurst),
+		connectedAt:  time.Now(),
+	}
+	player.ctx, player.cancel = context.WithCancel(context.Background())
+	player.idle.active(player.LastActivity)
+	player.joinPending.Store(gs.join != nil)
+	return player
+}
+
+func (gs *GameServer) RegisterPlayer(conn *websocket.Conn) (*Player, error) {
+	return gs.RegisterPlayerWithClass(conn, SlotRegular)
+}
+
+// RegisterPlayerWithClass registers a player counted against the capacity of the given slot class
+func (gs *GameServer) RegisterPlayerWithClass(conn *websocket.Conn, class SlotClass) (*Player, error) {
+	return gs.registerPlayer(context.Background(), conn, class)
+}
+
+// addPlayer takes a slot for an already created player
+func (gs *GameServer) addPlayer(player *Player) error {
+	if err := gs.claimSlot(player); err != nil {
+		return err
+	}
+	gs.announcePlayer(player)
+	return nil
+}
+
+// claimSlot registers the player if there's a slot for it, without writing to anyone
+func (gs *GameServer) claimSlot(player *Player) error {
+	gs.playersMu.Lock()
+	if _, taken := gs.players.get(player.ID); taken {
+		gs.playersMu.Unlock()
+		return ErrPlayerIDTaken
+	}
+	if !gs.takeSlotLocked(player) {
+		gs.playersMu.Unlock()
+		return ErrServerFull
+	}
+
+	// Started before the player is visible so UnregisterPlayer always finds the queue
+	gs.startPersistQueue(player)
+	gs.players.add(player)
+	gs.playersMu.Unlock()
+
+	log.Printf("Player %s connected", player.ID)
+	return nil
+}
+
+// announcePlayer tells the others about a player that just claimed its slot
+func (gs *GameServer) announcePlayer(player *Player) {
+	if player.Joined() {
+		gs.playerVisible(player)
+	}
+	gs.sendSessionLists(player.AccountID)
+}
+
+// disconnectWithReason sends a close frame telling the client why, then drops the connection
+func (gs *GameServer) disconnectWithReason(player *Player, reason DisconnectReason) {
+	player.sendClose(reason)
+	gs.unregisterPlayer(player)
+}
+
+// GetPlayer returns a connected player or spectator by ID
+func (gs *GameServer) GetPlayer(id string) (*Player, bool) {
+	return gs.players.get(id)
+}
+
+// PlayerCount returns how many players and spectators are connected right now
+func (gs *GameServer) PlayerCount() int {
+	return gs.players.len()
+}
+
+func (gs *GameServer) UnregisterPlayer(playerID string) {
+	if player, ok := gs.players.get(playerID); ok {
+		gs.unregisterPlayer(player)
+	}
+}
+
+// unregisterPlayer is UnregisterPlayer for this very player, it does nothing once a
+// resumed session took over the ID, see resume.go
+func (gs *GameServer) unregisterPlayer(player *Player) {
+	playerID := player.ID
+	gs.playersMu.Lock()
+	exists := gs.players.remove(player)
+	if exists {
+		if player.IsSpectator() {
+			gs.spectators--
+		} else {
+			gs.releaseSlotLocked(player)
+		}
+		log.Printf("Player %s disconnected", playerID)
+	}
+	gs.playersMu.Unlock()
+
+	if exists {
+		// A no-op when the connection was closed with a reason already
+		player.sendClose(player.DisconnectReason())
+		gs.ClearInterest(playerID)
+		gs.leaveMatchmaking(player, false)
+		gs.unwatchTournaments(player)
+		gs.LeaveParty(player)
+		if player.Joined() {
+			gs.presenceDisconnected(player)
+		}
+		if gs.join != nil {
+			gs.join.releaseName(player)
+		}
+		gs.sendSessionLists(player.AccountID)
+		gs.dropUDP(player)
+		gs.flushPersistQueue(player)
+		gs.releaseIP(player)
+		gs.saveSession(player)
+		if room := player.room.Load(); room != nil {
+			room.Leave(playerID)
+		}
+		if player.Joined() {
+			Publish(gs.events, PlayerLeft{Player: player})
+		}
+		if onDisconnect := gs.currentHooks().onDisconnect; onDisconnect != nil && player.greeted.Load() {
+			onDisconnect(player)
+		}
+	}
+
+	// A slot just opened up, let the next waiting connection in
+	if exists && !player.IsSpectator() && gs.queue != nil {
+		gs.queue.slotFreed()
+		gs.admitFromQueue()
+	}
+}
+
+// BroadcastMessage sends a message to all connected players
+// This is just for raw text messages, and they are sent to all the players.
+// The frame is encoded and compressed once for all websocket recipients.
+func (gs *GameServer) BroadcastMessage(message []byte) {
+	var recipients []*Player
+	for _, player := range gs.players.snapshot() {
+		if player.Joined() {
+			recipients = append(recipients, player)
+		}
+	}
+	gs.broadcastShared(recipients, websocket.TextMessage, message)
+}
+
+// closeConn closes the player's connection, or the inbox of a bot, without a close frame
+// Record the reason first, see setDisconnectReason.
+func (p *Player) closeConn() {
+	p.cancel()
+	p.transport.Close(websocket.CloseNormalClosure, "")
+}
+
+// write sends a single frame to the player, serializing concurrent writers
+func (p *Player) write(messageType int, data []byte) error {
+	return p.writeFrame(messageType, data, false, nil, PriorityGameplay)
+}
+
+// writeMessage sends an encoded structured message of msgType, stamped with the next sseq
+func (p *Player) writeMessage(msgType MessageType, data []byte) error {
+	prio := p.priority(msgType)
+	if b := p.batch.Load(); b != nil && b.queue(p, data, prio) {
+		return nil
+	}
+	return p.writeFrame(websocket.TextMessage, data, true, nil, prio)
+}
+
+// writeShared sends a frame many players get the same bytes of, see sharedFrame
+func (p *Player) writeShared(f *sharedFrame) error {
+	return p.writeFrame(f.messageType, f.data, false, f, PriorityGameplay)
+}
+
+func (p *Player) writeFrame(messageType int, data []byte, numbered bool, shared *sharedFrame, prio Priority) error {
+	// Fail fast instead of piling up behind a stalled connection
+	if !p.enterWrite(prio) {
+		p.metrics.recordOut(p.metricShard, len(data), ErrSlowConsumer)
+		return ErrSlowConsumer
+	}
+	defer p.leaveWrite()
+
+	p.gate.lock(prio)
+	defer p.gate.unlock()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Numbered under mu so the order of sseq is the order on the wire
+	var seq uint64
+	if numbered {
+		p.out.last++
+		seq = p.out.last
+		data = stampSeq(data, seq)
+	}
+
+	start := time.Now()
+	var deadline time.Time
+	if p.writeTimeout > 0 {
+		deadline = start.Add(p.writeTimeout)
+	}
+
+	var err error
+	if ws, ok := p.transport.(*wsTransport); ok && shared != nil {
+		err = ws.writeShared(shared, deadline)
+	} else {
+		err = p.transport.WriteFrame(messageType, data, deadline)
+	}
+	p.metrics.recordOut(p.metricShard, len(data), err)
+	if err != nil {
+		p.handleWriteError(err)
+	} else {
+		p.recordWriteLatency(time.Since(start))
+		if numbered {
+			p.out.delivered.Store(seq)
+		}
+	}
+
+	if p.debug.Load() {
+		p.tracef("out %d bytes in %v (err: %v): %s", len(data), time.Since(start), err, summarize(data))
+	}
+	return err
+}
+
+// encodeStructuredMessage wraps the payload into a StructuredMessage and returns the wire bytes
+func encodeStructuredMessage(playerID string, msgType MessageType, payload interface{}) ([]byte, error) {
+	return encodeMessage(StructuredMessage{Type: msgType, PlayerID: playerID}, payload)
+}
+
+func (gs *GameServer) SendStructuredMessage(playerID string, msgType MessageType, payload interface{}) error {
+	// Find and send to specific player
+	player, exists := gs.players.get(playerID)
+	if !exists {
+		return fmt.Errorf("player not found")
+	}
+
+	msgBytes, err := gs.encodeFor(player, msgType, payload)
+	if err != nil {
+		return err
+	}
+
+	return player.writeMessage(msgType, msgBytes)
+}
+
+// HandlePlayerMessages handles incoming messages from a player
+func (gs *GameServer) HandlePlayerMessages(player *Player) {
+	defer gs.unregisterPlayer(player)
+
+	for {
+		messageType, message, err := player.transport.ReadFrame(gs.readDeadline(player))
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Printf("Unexpected close error for player %s: %v", player.ID, err)
+			}
+			player.setDisconnectReason(leftReason(err))
+			break
+		}
+
+		gs.handleInbound(player, messageType, message)
+	}
+}
+
+// handleInbound runs one frame from a player through the pipeline, bots submit theirs here too
+// The processing error is logged and returned. A panic is recovered and returned as an error,
+// see recoverInbound.
+func (gs *GameServer) handleInbound(player *Player, messageType int, message []byte) (err error) {
+	player.inboundMu.Lock()
+	defer player.inboundMu.Unlock()
+	defer gs.recoverInbound(player, messageType, message, &err)
+
+	gs.metrics.recordIn(player.metricShard, len(message))
+	if room := player.room.Load(); room != nil {
+		room.recordInbound(player, messageType, message)
+	}
+
+	traceDone := player.traceInbound(message)
+	if messageType == websocket.BinaryMessage {
+		err = gs.processBinaryMessage(player, message)
+	} else {
+		err = gs.processMessage(player, message)
+	}
+	traceDone(err)
+	if err != nil {
+		gs.metrics.processErrors.add(player.metricShard, 1)
+		gs.logPlayerf(player, "Message processing error from player %s: %v", player.ID, err)
+		gs.rejectFrame(player, messageType, message, err)
+	}
+
+	// Passive frames keep the connection alive without counting as activity
+	if player.idle.passive.Swap(false) {
+		player.idle.seen(time.Now())
+	} else {
+		gs.touch(player)
+	}
+	return err
+}
+
+// recoverInbound turns a panic while handling a frame into an error. The player is
+// disconnected, what it was in the middle of may be half done, and the frame is published
+// with MessagePanicked so it can be reproduced. Nothing else depends on the read loop, so
+// the other players carry on.
+func (gs *GameServer) recoverInbound(player *Player, messageType int, message []byte, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	stack := debug.Stack()
+	log.Printf("Handling a message from player %s panicked: %v\n%s", player.ID, r, stack)
+	gs.metrics.panics.add(player.metricShard, 1)
+	Publish(gs.events, MessagePanicked{Player: player, MessageType: messageType, Frame: message, Value: r, Stack: stack})
+
+	// Not on this goroutine, the panic may have left locks the disconnect needs
+	go gs.disconnectWithReason(player, ReasonInternalError)
+	*err = fmt.Errorf("panic: %v", r)
+}
+
+// processTextMessage handles text-based game messages
+func (gs *GameServer) processTextMessage(player *Player, message []byte) {
+	// Implement your game-specific message processing logic here
+	log.Printf("Received text message from %s: %s", player.ID, string(message))
+
+	// Example: Echo message back to all players
+	gs.BroadcastMessage(message)
+}
+
+// processMessage handles structured messages with type-based routing
+func (gs *GameServer) processMessage(player *Player, data []byte) error {
+	var msg StructuredMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return Reject(ErrorInvalidPayload, fmt.Errorf("invalid message format"))
+	}
+	if v := gs.checkSchema(msg); v != nil {
+		gs.rejectSchema(player, msg, v)
+		return v
+	}
+	gs.metrics.recordType(player.metricShard, msg.Type)
+
+	// Answers were asked for, they skip admission and the routing
+	if msg.Re != 0 {
+		gs.answerCall(player, msg)
+		return nil
+	}
+
+	// A retry of something already handled, its first ack may have been lost
+	if player.isDuplicate(msg.DedupID) {
+		player.tracef("dropping duplicate %s %d", msg.Type, msg.DedupID)
+		gs.ackClientMessage(player, msg.DedupID)
+		return nil
+	}
+
+	if err := gs.admitMessage(player, msg.Type); err != nil {
+		return err
+	}
+
+	// Only admitted messages count as seen, a retry of a rejected one gets another chance
+	if !player.markSeen(msg.DedupID) {
+		gs.ackClientMessage(player, msg.DedupID)
+		return nil
+	}
+	defer gs.ackClientMessage(player, msg.DedupID)
+
+	player.tracef("routing %s (seq %d, payload %s)", msg.Type, msg.Seq, summarize(msg.Payload))
+	Publish(gs.events, MessageReceived{Player: player, Message: msg})
+	if onMessage := gs.currentHooks().onMessage; onMessage != nil {
+		if handled, err := onMessage(player, msg); handled || err != nil {
+			return err
+		}
+	}
+	if handled, err := gs.scripts.handle(player, msg); handled || err != nil {
+		return err
+	}
+	if handled, err := gs.handlePlugins(player, msg); handled || err != nil {
+		return err
+	}
+	if room := player.room.Load(); room != nil {
+		if handled, err := room.dispatch(player, msg); handled || err != nil {
+			return err
+		}
+	}
+	if handled, err := gs.handleTyped(player, msg); handled || err != nil {
+		return err
+	}
+
+	// Example message type handling
+	switch msg.Type {
+	case PlayerMove:
+		// Inputs that arrive late are already covered by newer ones
+		if !gs.AckInput(player, msg.Seq) {
+			gs.logPlayerf(player, "Dropping stale move %d from player %s", msg.Seq, player.ID)
+			return nil
+		}
+
+		var move PlayerMovePayload
+		if err := json.Unmarshal(msg.Payload, &move); err != nil {
+			return Reject(ErrorInvalidPayload, fmt.Errorf("invalid move from player %s: %v", player.ID, err))
+		}
+		if !gs.validateMove(player, logic.Vec2{X: move.X, Y: move.Y}, msg.Seq) {
+			return nil
+		}
+
+		// Process player movement
+		gs.logPlayerf(player, "Player %s moved", player.ID)
+
+	case ChatMessage:
+		// Broadcast chat message to all players
+		relayed, err := encodeRelayed(player, msg)
+		if err != nil {
+			return Reject(ErrorInvalidPayload, err)
+		}
+		gs.broadcastEncoded(msg.Type, relayed)
+
+	case GameStateSync:
+		// Clients don't get to write game state, a sync from them asks for the authoritative one.
+		// Change state through validated handlers and Room.Entities().Update instead.
+		return gs.sendWorldState(player)
+
+	case SpectateJoin:
+		if err := gs.StartSpectating(player); err != nil {
+			return err
+		}
+		return gs.SendStructuredMessage(player.ID, SpectateJoin, nil)
+
+	case SpectateLeave:
+		if err := gs.StopSpectating(player); err != nil {
+			return err
+		}
+		return gs.SendStructuredMessage(player.ID, SpectateLeave, nil)
+
+	case PartyCreate, PartyInvite, PartyJoin, PartyLeave, PartyChat:
+		return gs.handlePartyMessage(player, msg)
+
+	case MatchmakingJoin, MatchmakingLeave:
+		return gs.handleMatchmakingMessage(player, msg)
+
+	case TournamentWatch, TournamentUnwatch:
+		return gs.handleTournamentMessage(player, msg)
+
+	case TimeSync:
+		return gs.handleTimeSync(player, msg)
+
+	case Pong:
+		player.idle.passive.Store(true)
+		return gs.handlePong(player, msg)
+
+	case RoomJoin, RoomInvite:
+		return gs.handleRoomMessage(player, msg)
+
+	case ListRooms:
+		return gs.handleListRooms(player, msg)
+
+	case PresenceSet, FriendAdd, FriendRemove, FriendList:
+		return gs.handlePresenceMessage(player, msg)
+
+	case DirectMessage, BlockAccount, UnblockAccount:
+		return gs.handleDirectMessage(player, msg)
+
+	case OnboardingReply:
+		return gs.handleOnboardingReply(player, msg)
+
+	case SurveyResponse:
+		return gs.handleSurveyResponse(player, msg)
+
+	case Hello:
+		return gs.handleHello(player, msg)
+
+	case MessageAck:
+		return gs.handleMessageAck(player, msg)
+
+	case Join:
+		return gs.handleJoin(player, msg)
+
+	case TransferStart, TransferChunk, TransferAbort:
+		return gs.handleTransferMessage(player, msg)
+
+	case RTCJoin, RTCLeave, RTCOffer, RTCAnswer, RTCCandidate:
+		return gs.handleSignaling(player, msg)
+
+	// Can have more if needed
+	default:
+		gs.logPlayerf(player, "Unhandled message type: %s", msg.Type)
+	}
+
+	return nil
+}
+
+// admitMessage applies the checks every inbound message goes through, JSON or binary
+func (gs *GameServer) admitMessage(player *Player, msgType MessageType) error {
+	if err := checkJoinMessage(player, msgType); err != nil {
+		player.tracef("%s rejected before join", msgType)
+		return err
+	}
+
+	// Throttle rooms that go over their aggregate message rate
+	if room := player.room.Load(); room != nil && !room.allowMessage() {
+		player.tracef("%s rejected by room %s quota", msgType, room.ID)
+		return fmt.Errorf("dropped message from player %s: %w", player.ID, ErrQuotaExceeded)
+	}
+
+	if err := checkSpectatorMessage(player, msgType); err != nil {
+		player.tracef("%s rejected for spectator", msgType)
+		return err
+	}
+
+	if err := checkOnboardingMessage(player, msgType); err != nil {
+		player.tracef("%s rejected during onboarding", msgType)
+		return err
+	}
+
+	if err := checkModeMessage(player, msgType); err != nil {
+		player.tracef("%s rejected by room mode", msgType)
+		return err
+	}
+
+	// Charged last so rejected messages don't cost anything
+	if err := gs.chargeAction(player, msgType); err != nil {
+		player.tracef("%s rejected by meters", msgType)
+		return err
+	}
+	return nil
+}
+
+// processBinaryMessage handles binary frames, see messages/binary.go for the layouts
+func (gs *GameServer) processBinaryMessage(player *Player, message []byte) error {
+	kind, err := messages.FrameKind(message)
+	if err != nil {
+		return Reject(ErrorInvalidPayload, err)
+	}
+
+	switch kind {
+	case messages.FrameMove:
+		// Same rules as a JSON PLAYER_MOVE, without paying for JSON on the hot path
+		if err := gs.admitMessage(player, PlayerMove); err != nil {
+			return err
+		}
+
+		move, err := messages.DecodeMove(message)
+		if err != nil {
+			return Reject(ErrorInvalidPayload, fmt.Errorf("invalid move frame from player %s: %v", player.ID, err))
+		}
+		if !gs.AckInput(player, uint64(move.Seq)) {
+			player.tracef("dropping stale binary move %d", move.Seq)
+			return nil
+		}
+		if !gs.validateMove(player, logic.Vec2{X: float64(move.X), Y: float64(move.Y)}, uint64(move.Seq)) {
+			return nil
+		}
+
+		// Process player movement
+		player.tracef("binary move entity %d to (%v, %v)", move.EntityID, move.X, move.Y)
+
+	case messages.FrameVoice:
+		return gs.relayVoice(player, message)
+
+	default:
+		// Implement your game-specific binary message processing logic
+		log.Printf("Received binary message from %s (length: %d)", player.ID, len(message))
+	}
+
+	return nil
+}
+
+func (gs *GameServer) StartServer(addr string) error {
+	srv := gs.newHTTPServer(addr)
+	log.Printf("Server starting on %s", addr)
+	return serveResult(srv.ListenAndServe())
+}
+
+// Handler returns the public endpoints, for embedding the server in another http.Server
+// or in tests. StartServer and friends serve the same mux.
+func (gs *GameServer) Handler() http.Handler {
+	gs.routesOnce.Do(func() {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/ws", gs.handleWS)
+		mux.HandleFunc("/spectate", gs.handleSpectate)
+		mux.HandleFunc("/capabilities", gs.handleCapabilities)
+		mux.HandleFunc("/rooms", gs.handleRooms)
+		mux.HandleFunc("/healthz", gs.handleHealthz)
+		mux.HandleFunc("/readyz", gs.handleReadyz)
+		if gs.login != nil {
+			mux.Handle("/auth/", gs.LoginHandler())
+		}
+		if gs.injector != nil {
+			mux.Handle("/inject", gs.InjectHandler())
+		}
+		if gs.discord != nil && gs.discord.publicKey != nil {
+			mux.HandleFunc("/discord/interactions", gs.handleDiscordInteraction)
+		}
+		gs.mux = mux
+	})
+	return gs.mux
+}
+
+func (gs *GameServer) handleWS(w http.ResponseWriter, r *http.Request) {
+	if !gs.checkLoginBeforeUpgrade(w, r) {
+		return
+	}
+	ip, ok := gs.acquireIP(w, r)
+	if !ok {
+		return
+	}
+	if !gs.checkBansBeforeUpgrade(w, r, ip) {
+		gs.ipLimit.release(ip)
+		return
+	}
+
+	conn, err := gs.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade error: %v", err)
+		gs.ipLimit.release(ip)
+		return
+	}
+
+	player := gs.newWebSocketPlayer(conn, gs.slotClassifier(r))
+	gs.setupCompression(player, r)
+	gs.admit(player, r, ip)
+}
+
+// admit registers a freshly upgraded connection and starts reading from it
+func (gs *GameServer) admit(player *Player, r *http.Request, ip string) {
+	player.setParentContext(context.WithoutCancel(r.Context()))
+	player.remoteIP = ip
+	player.device = deviceName(r)
+	account, resolved := gs.resolveAccount(r)
+	if resolved {
+		player.AccountID = account
+	}
+	if err := gs.resumeSession(player, r, resolved); err != nil {
+		log.Printf("Rejecting resumed session: %v", err)
+		gs.refuseConn(player, err)
+		return
+	}
+
+	if err := gs.enforceSessionPolicy(player); err != nil {
+		log.Printf("Player registration error: %v", err)
+		gs.refuseConn(player, err)
+		return
+	}
+
+	err := gs.addPlayer(player)
+	if errors.Is(err, ErrServerFull) {
+		Publish(gs.events, ServerFull{Player: player, Queued: gs.queue != nil})
+	}
+	if errors.Is(err, ErrServerFull) && gs.queue != nil {
+		// Park the connection in the waiting queue instead of rejecting it
+		go gs.waitInQueue(player)
+		return
+	}
+	if err != nil {
+		log.Printf("Player registration error: %v", err)
+		gs.refuseConn(player, err)
+		return
+	}
+
+	gs.greet(player)
+	go gs.HandlePlayerMessages(player)
+}
+
+// newHTTPServer creates a server for Handler that Shutdown will stop
+func (gs *GameServer) newHTTPServer(addr string) *http.Server {
+	srv := &http.Server{Addr: addr, Handler: gs.Handler()}
+	gs.serversMu.Lock()
+	gs.httpServers = append(gs.httpServers, srv)
+	gs.serversMu.Unlock()
+	return srv
+}
+
+// serveResult hides the error every server returns once Shutdown was called
+func serveResult(err error) error {
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
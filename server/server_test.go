@@ -0,0 +1,25 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestListenersServeHandler(t *testing.T) {
+	// Two servers in one process, the second used to panic on the default mux
+	for i := 0; i < 2; i++ {
+		gs := NewGameServer(10)
+		srv := gs.newHTTPServer("")
+		if srv.Handler != gs.Handler() {
+			t.Fatal("listener doesn't serve Handler")
+		}
+		for _, path := range []string{"/healthz", "/capabilities", "/rooms"} {
+			rec := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("server %d %s: %d", i, path, rec.Code)
+			}
+		}
+	}
+}
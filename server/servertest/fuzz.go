@@ -0,0 +1,129 @@
+package servertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/iknizzz1807/socket-server-template/messages"
+	"github.com/iknizzz1807/socket-server-template/server"
+)
+
+// The fuzz targets run frames through the same pipeline as frames read from a connection,
+// on a bot of an in-process server. Call them from a fuzz test of the game, with the options
+// the game runs with so its own handlers are fuzzed too:
+//
+//	func FuzzMessages(f *testing.F) {
+//		servertest.FuzzMessages(f, server.WithModes(modes))
+//	}
+//
+// and run them with go test -fuzz FuzzMessages. They fail on any panic, including the ones
+// the server recovers from (see server.MessagePanicked), the failing input is kept in
+// testdata/fuzz for go test to replay. The server keeps its state between inputs, so new
+// coverage rarely shows up again when the fuzzer minimizes the input. Pass
+// -fuzzminimizetime 1s or it spends a minute on every one.
+
+// FuzzMessages fuzzes text frames, seeded with a message of every type clients send
+func FuzzMessages(f *testing.F, opts ...server.Option) {
+	for _, schema := range server.Schemas() {
+		if schema.Direction == "server" {
+			continue
+		}
+		for _, payload := range []string{`{}`, `null`, `[]`, `"x"`} {
+			f.Add([]byte(`{"type":"` + string(schema.Type) + `","player_id":"","payload":` + payload + `,"timestamp":0,"v":1}`))
+		}
+	}
+	f.Add(seedMessage(server.PlayerMove, server.PlayerMovePayload{X: 1, Y: 2}))
+	f.Add(seedMessage(server.RoomJoin, server.RoomJoinPayload{RoomID: "lobby", Password: "secret"}))
+	f.Add(seedMessage(server.TimeSync, server.TimeSyncPayload{ClientSend: 1}))
+	f.Add([]byte(`{"type":"PLAYER_MOVE","payload":{"x":1e308,"y":-1e308},"seq":18446744073709551615}`))
+	f.Add([]byte(`{"type":"CHAT_MESSAGE","payload":"hi","dedup":1}`))
+	f.Add([]byte(`{"type":`))
+	f.Add([]byte(`not json`))
+
+	h := newFuzzHarness(f, opts)
+	f.Fuzz(func(t *testing.T, frame []byte) {
+		h.send(t, websocket.TextMessage, frame)
+	})
+}
+
+// FuzzBinaryFrames fuzzes binary frames, seeded with move and voice frames
+func FuzzBinaryFrames(f *testing.F, opts ...server.Option) {
+	f.Add(messages.EncodeMove(messages.MoveFrame{EntityID: 1, Seq: 1, X: 10, Y: 20}))
+	f.Add(messages.EncodeMove(messages.MoveFrame{Seq: ^uint32(0), X: -1e38, VX: 1e38}))
+	f.Add([]byte{messages.FrameMove})
+	f.Add([]byte{messages.FrameVoice, 0, 1, 0xff, 0, 0xf8, 0xff, 0xfe})
+	f.Add([]byte{messages.FrameVoice, 0, 1, 0xff, 40, 'x'})
+	f.Add([]byte{0xff, 0, 0})
+
+	h := newFuzzHarness(f, opts)
+	f.Fuzz(func(t *testing.T, frame []byte) {
+		h.send(t, websocket.BinaryMessage, frame)
+	})
+}
+
+// FuzzMoveCodec fuzzes the binary move codec: what decodes must encode to the same bytes,
+// and never to a non-finite position
+func FuzzMoveCodec(f *testing.F) {
+	f.Add(messages.EncodeMove(messages.MoveFrame{EntityID: 7, Seq: 3, X: 1.5, Y: -2}))
+	f.Add([]byte{messages.FrameMove, 0x7f, 0xc0, 0, 0})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		move, err := messages.DecodeMove(data)
+		if err != nil {
+			return
+		}
+		if encoded := messages.EncodeMove(move); !bytes.Equal(encoded, data) {
+			t.Fatalf("decoded %+v from %x, which encodes to %x", move, data, encoded)
+		}
+	})
+}
+
+// fuzzHarness feeds frames to a bot, replacing it when an input made it leave
+type fuzzHarness struct {
+	gs       *server.GameServer
+	bot      *server.Bot
+	panicked atomic.Pointer[server.MessagePanicked]
+}
+
+func newFuzzHarness(f *testing.F, opts []server.Option) *fuzzHarness {
+	h := &fuzzHarness{gs: server.NewGameServer(defaultMaxPlayers, opts...)}
+	unsubscribe := server.Subscribe(h.gs.Events(), func(e server.MessagePanicked) {
+		h.panicked.Store(&e)
+	})
+	f.Cleanup(unsubscribe)
+	return h
+}
+
+func (h *fuzzHarness) send(t *testing.T, messageType int, frame []byte) {
+	if h.bot == nil || !h.connected() {
+		bot, err := h.gs.AddBot(server.BotOptions{})
+		if err != nil {
+			t.Fatalf("adding the fuzz bot: %v", err)
+		}
+		h.bot = bot
+	}
+
+	h.bot.SendRaw(messageType, frame)
+	if e := h.panicked.Swap(nil); e != nil {
+		t.Fatalf("frame %q panicked: %v\n%s", frame, e.Value, e.Stack)
+	}
+
+	// Nobody reads what the server sent back
+	for len(h.bot.Messages()) > 0 {
+		<-h.bot.Messages()
+	}
+}
+
+func (h *fuzzHarness) connected() bool {
+	p, ok := h.gs.GetPlayer(h.bot.Player.ID)
+	return ok && p == h.bot.Player
+}
+
+// seedMessage encodes a message for a seed corpus
+func seedMessage(msgType server.MessageType, payload interface{}) []byte {
+	data, _ := json.Marshal(map[string]interface{}{"type": msgType, "payload": payload})
+	return data
+}
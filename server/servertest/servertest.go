@@ -0,0 +1,287 @@
+// Package servertest runs a GameServer in-process for integration tests, with a client
+// wrapper that sends typed messages and waits for the ones it expects.
+//
+//	func TestChat(t *testing.T) {
+//		ts := servertest.NewTestServer(t)
+//		alice, bob := ts.Connect(t), ts.Connect(t)
+//		alice.Send(server.ChatMessage, "hi")
+//		bob.Expect(server.ChatMessage)
+//	}
+package servertest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/iknizzz1807/socket-server-template/server"
+)
+
+// DefaultTimeout is how long Expect and the other waits give up after
+var DefaultTimeout = 2 * time.Second
+
+const defaultMaxPlayers = 100
+
+// Server is a GameServer behind an httptest.Server, closed when the test ends
+type Server struct {
+	GS   *server.GameServer
+	HTTP *httptest.Server
+	// URL is the ws:// address of the /ws endpoint
+	URL string
+}
+
+// NewTestServer starts a server with room for 100 players and the given options
+func NewTestServer(t testing.TB, opts ...server.Option) *Server {
+	t.Helper()
+	return NewTestServerWith(t, server.NewGameServer(defaultMaxPlayers, opts...))
+}
+
+// NewTestServerWith serves an already configured GameServer, it is shut down when the test
+// ends
+func NewTestServerWith(t testing.TB, gs *server.GameServer) *Server {
+	t.Helper()
+	srv := httptest.NewServer(gs.Handler())
+	// Cleanups run last in first out: the GameServer goes before the HTTP server
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+		defer cancel()
+		if err := gs.Shutdown(ctx); err != nil {
+			t.Errorf("shutting down the test server: %v", err)
+		}
+	})
+
+	return &Server{
+		GS:   gs,
+		HTTP: srv,
+		URL:  "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws",
+	}
+}
+
+// Connect opens a client on /ws and waits until the server registered it
+func (s *Server) Connect(t testing.TB) *Client {
+	t.Helper()
+	return s.ConnectWith(t, "/ws", nil)
+}
+
+// ConnectWith opens a client on another endpoint (e.g. /spectate) or with extra headers
+func (s *Server) ConnectWith(t testing.TB, path string, header http.Header) *Client {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(s.HTTP.URL, "http") + path
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("dial %s: %v", url, err)
+	}
+
+	c := &Client{t: t, conn: conn, notify: make(chan struct{}, 1)}
+	go c.read()
+	t.Cleanup(c.Close)
+
+	// Every connection is greeted with CAPABILITIES, which also tells us our player ID
+	greeting := c.Expect(server.Capabilities)
+	c.playerID = greeting.PlayerID
+	return c
+}
+
+// Player returns the server side of a client
+func (s *Server) Player(t testing.TB, c *Client) *server.Player {
+	t.Helper()
+	player, ok := s.GS.GetPlayer(c.PlayerID())
+	if !ok {
+		t.Fatalf("player %s is not connected", c.PlayerID())
+	}
+	return player
+}
+
+// Client is a test connection, its helpers fail the test instead of returning errors
+type Client struct {
+	t        testing.TB
+	conn     *websocket.Conn
+	playerID string
+
+	writeMu sync.Mutex
+
+	mu       sync.Mutex
+	received []server.StructuredMessage
+	readErr  error
+	notify   chan struct{}
+	once     sync.Once
+}
+
+// PlayerID is the ID the server gave this connection
+func (c *Client) PlayerID() string {
+	return c.playerID
+}
+
+func (c *Client) read() {
+	for {
+		_, data, err := c.conn.ReadMessage()
+
+		c.mu.Lock()
+		if err != nil {
+			c.readErr = err
+		} else {
+			var msg server.StructuredMessage
+			// Frames that aren't structured messages are skipped
+			if json.Unmarshal(data, &msg) == nil && msg.Type != "" {
+				c.received = append(c.received, msg)
+			}
+		}
+		c.mu.Unlock()
+
+		select {
+		case c.notify <- struct{}{}:
+		default:
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Send sends a structured message
+func (c *Client) Send(msgType server.MessageType, payload interface{}) {
+	c.t.Helper()
+	c.SendSeq(msgType, 0, payload)
+}
+
+// SendSeq sends a structured message with an input sequence number
+func (c *Client) SendSeq(msgType server.MessageType, seq uint64, payload interface{}) {
+	c.t.Helper()
+	data, err := json.Marshal(payload)
+	if err != nil {
+		c.t.Fatalf("marshal %s payload: %v", msgType, err)
+	}
+	msg, err := json.Marshal(server.StructuredMessage{Type: msgType, PlayerID: c.playerID, Payload: data, Seq: seq})
+	if err != nil {
+		c.t.Fatalf("marshal %s: %v", msgType, err)
+	}
+	c.SendRaw(websocket.TextMessage, msg)
+}
+
+// SendRaw writes a frame as is
+func (c *Client) SendRaw(messageType int, data []byte) {
+	c.t.Helper()
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.conn.WriteMessage(messageType, data); err != nil {
+		c.t.Fatalf("send: %v", err)
+	}
+}
+
+// Expect waits for the next message of msgType, messages of other types stay queued
+func (c *Client) Expect(msgType server.MessageType) server.StructuredMessage {
+	c.t.Helper()
+	msg, err := c.wait(msgType, DefaultTimeout)
+	if err != nil {
+		c.t.Fatalf("expected %s: %v", msgType, err)
+	}
+	return msg
+}
+
+// ExpectPayload waits for a message of msgType and decodes its payload into v
+func (c *Client) ExpectPayload(msgType server.MessageType, v interface{}) server.StructuredMessage {
+	c.t.Helper()
+	msg := c.Expect(msgType)
+	if err := json.Unmarshal(msg.Payload, v); err != nil {
+		c.t.Fatalf("decode %s payload: %v", msgType, err)
+	}
+	return msg
+}
+
+// ExpectNone fails if a message of msgType arrives within d
+func (c *Client) ExpectNone(msgType server.MessageType, d time.Duration) {
+	c.t.Helper()
+	if msg, err := c.wait(msgType, d); err == nil {
+		c.t.Fatalf("unexpected %s: %s", msgType, msg.Payload)
+	}
+}
+
+// ExpectClosed waits for the server to close the connection and returns the close error
+func (c *Client) ExpectClosed() error {
+	c.t.Helper()
+	deadline := time.NewTimer(DefaultTimeout)
+	defer deadline.Stop()
+	for {
+		c.mu.Lock()
+		err := c.readErr
+		c.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		select {
+		case <-c.notify:
+		case <-deadline.C:
+			c.t.Fatalf("connection still open after %v", DefaultTimeout)
+		}
+	}
+}
+
+func (c *Client) wait(msgType server.MessageType, timeout time.Duration) (server.StructuredMessage, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		c.mu.Lock()
+		for i, msg := range c.received {
+			if msg.Type == msgType {
+				c.received = append(c.received[:i], c.received[i+1:]...)
+				c.mu.Unlock()
+				return msg, nil
+			}
+		}
+		err := c.readErr
+		c.mu.Unlock()
+		if err != nil {
+			return server.StructuredMessage{}, fmt.Errorf("connection closed: %v", err)
+		}
+
+		select {
+		case <-c.notify:
+		case <-deadline.C:
+			return server.StructuredMessage{}, fmt.Errorf("nothing after %v", timeout)
+		}
+	}
+}
+
+// Drain throws away everything received so far
+func (c *Client) Drain() {
+	c.mu.Lock()
+	c.received = nil
+	c.mu.Unlock()
+}
+
+// Close closes the connection, it runs on cleanup too
+func (c *Client) Close() {
+	c.once.Do(func() {
+		c.writeMu.Lock()
+		c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		c.writeMu.Unlock()
+		c.conn.Close()
+	})
+}
+
+// Eventually polls cond until it holds, failing the test after DefaultTimeout
+// Use it for state that settles asynchronously, like a player leaving after Close.
+func Eventually(t testing.TB, cond func() bool, format string, args ...interface{}) {
+	t.Helper()
+	deadline := time.Now().Add(DefaultTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting: "+format, args...)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// WaitForPlayers waits until exactly n players and spectators are connected
+func (s *Server) WaitForPlayers(t testing.TB, n int) {
+	t.Helper()
+	Eventually(t, func() bool { return s.GS.PlayerCount() == n }, "want %d players connected", n)
+}
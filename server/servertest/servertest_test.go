@@ -0,0 +1,48 @@
+package servertest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iknizzz1807/socket-server-template/server"
+	"github.com/iknizzz1807/socket-server-template/server/servertest"
+)
+
+func TestChatReachesEveryone(t *testing.T) {
+	ts := servertest.NewTestServer(t)
+	alice, bob := ts.Connect(t), ts.Connect(t)
+	ts.WaitForPlayers(t, 2)
+
+	alice.Send(server.ChatMessage, "hi")
+	var text string
+	msg := bob.ExpectPayload(server.ChatMessage, &text)
+	if text != "hi" || msg.PlayerID != alice.PlayerID() {
+		t.Fatalf("bob got %q from %s, want \"hi\" from %s", text, msg.PlayerID, alice.PlayerID())
+	}
+	alice.Expect(server.ChatMessage)
+}
+
+func TestClientLeaving(t *testing.T) {
+	ts := servertest.NewTestServer(t)
+	c := ts.Connect(t)
+	ts.Player(t, c)
+
+	c.Close()
+	ts.WaitForPlayers(t, 0)
+}
+
+func TestShutdownClosesClients(t *testing.T) {
+	ts := servertest.NewTestServer(t)
+	c := ts.Connect(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := ts.GS.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ExpectClosed(); err == nil {
+		t.Fatal("connection closed without an error")
+	}
+	ts.WaitForPlayers(t, 0)
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// By default an account may be connected any number of times without anyone being told.
+// WithSessionPolicy picks what happens instead when an account that is already connected
+// connects again. Accounts come from the AccountResolver, without one every connection is
+// its own account and the policy never applies. Bots are left out.
+
+// SessionPolicy is what happens when an already connected account connects again
+type SessionPolicy int
+
+const (
+	// SessionsMultiple keeps every connection and sends each the account's SESSION_LIST
+	SessionsMultiple SessionPolicy = iota
+	// SessionsRejectNew closes the new connection with ALREADY_CONNECTED
+	SessionsRejectNew
+	// SessionsReplaceOld closes the old connections with SESSION_REPLACED
+	SessionsReplaceOld
+)
+
+var ErrAccountConnected = errors.New("account is already connected")
+
+const maxDeviceLength = 128
+
+// Session is one connection of an account
+type Session struct {
+	PlayerID    string
+	Device      string
+	RemoteIP    string
+	ConnectedAt time.Time
+}
+
+// deviceName is the ?device= the client connected with, its User-Agent otherwise
+func deviceName(r *http.Request) string {
+	device := r.URL.Query().Get("device")
+	if device == "" {
+		device = r.UserAgent()
+	}
+	if len(device) > maxDeviceLength {
+		device = device[:maxDeviceLength]
+	}
+	return device
+}
+
+// accountConnections returns the account's connections, bots left out
+func (gs *GameServer) accountConnections(accountID string) []*Player {
+	var players []*Player
+	for _, player := range gs.snapshotPlayers() {
+		if player.AccountID == accountID && player.bot == nil {
+			players = append(players, player)
+		}
+	}
+	sort.Slice(players, func(i, j int) bool { return players[i].connectedAt.Before(players[j].connectedAt) })
+	return players
+}
+
+// AccountSessions lists the connections of an account, oldest first
+func (gs *GameServer) AccountSessions(accountID string) []Session {
+	players := gs.accountConnections(accountID)
+	sessions := make([]Session, 0, len(players))
+	for _, player := range players {
+		sessions = append(sessions, Session{
+			PlayerID:    player.ID,
+			Device:      player.device,
+			RemoteIP:    player.remoteIP,
+			ConnectedAt: player.connectedAt,
+		})
+	}
+	return sessions
+}
+
+// enforceSessionPolicy runs before a new connection is registered or queued
+func (gs *GameServer) enforceSessionPolicy(player *Player) error {
+	if gs.sessionPolicy == nil {
+		return nil
+	}
+	existing := gs.accountConnections(player.AccountID)
+	if len(existing) == 0 {
+		return nil
+	}
+
+	switch *gs.sessionPolicy {
+	case SessionsRejectNew:
+		return ErrAccountConnected
+	case SessionsReplaceOld:
+		for _, old := range existing {
+			gs.logPlayerf(old, "Account %s connected again as %s, replacing session %s", old.AccountID, player.ID, old.ID)
+			gs.disconnectWithReason(old, ReasonSessionReplaced)
+		}
+	}
+	return nil
+}
+
+// refuseConn closes a connection that didn't make it in, telling the client why when it can
+func (gs *GameServer) refuseConn(player *Player, err error) {
+	if player.bot == nil {
+		player.sendClose(refuseReason(err))
+	}
+	gs.dropConn(player)
+}
+
+// sendSessionLists tells every connection of the account where else it is connected
+func (gs *GameServer) sendSessionLists(accountID string) {
+	if gs.sessionPolicy == nil || *gs.sessionPolicy != SessionsMultiple {
+		return
+	}
+	players := gs.accountConnections(accountID)
+	for _, recipient := range players {
+		list := SessionListPayload{Sessions: make([]SessionPayload, 0, len(players))}
+		for _, player := range players {
+			list.Sessions = append(list.Sessions, SessionPayload{
+				PlayerID:    player.ID,
+				Device:      player.device,
+				ConnectedAt: player.connectedAt.UnixMilli(),
+				Current:     player == recipient,
+			})
+		}
+		if err := gs.SendSessionList(recipient.ID, list); err != nil {
+			recipient.tracef("session list failed: %v", err)
+		}
+	}
+}
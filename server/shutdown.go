@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"log"
+)
+
+// Shutdown stops accepting connections and the server's background loops, disconnects
+// every player, closes every room and waits until pending activity records are persisted
+// or ctx expires
+func (gs *GameServer) Shutdown(ctx context.Context) error {
+	gs.shuttingDown.Store(true)
+	gs.stopOnce.Do(func() { close(gs.stop) })
+
+	// Off the server browser first, so nobody picks a server on its way out
+	if gs.registration != nil {
+		if err := gs.registration.deregister(ctx, gs.nodeID); err != nil {
+			log.Printf("Error leaving coordinator %s: %v", gs.registration.cfg.Coordinator, err)
+		}
+	}
+
+	gs.serversMu.Lock()
+	servers := gs.httpServers
+	gs.httpServers = nil
+	if gs.adminServer != nil {
+		servers = append(servers, gs.adminServer)
+		gs.adminServer = nil
+	}
+	if gs.coordinatorServer != nil {
+		servers = append(servers, gs.coordinatorServer)
+		gs.coordinatorServer = nil
+	}
+	wt := gs.webTransport
+	gs.webTransport = nil
+	listeners := gs.tcpListeners
+	gs.tcpListeners = nil
+	udp := gs.udp
+	gs.udp = nil
+	grpcServer := gs.grpcServer
+	gs.grpcServer = nil
+	gs.serversMu.Unlock()
+
+	if grpcServer != nil {
+		// Streams end as their players are unregistered below, GracefulStop would wait on them first
+		grpcServer.Stop()
+	}
+
+	if udp != nil {
+		udp.conn.Close()
+	}
+
+	for _, ln := range listeners {
+		ln.Close()
+	}
+
+	if wt != nil {
+		if err := wt.Close(); err != nil {
+			log.Printf("WebTransport server shutdown error: %v", err)
+		}
+	}
+
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("HTTP server %s shutdown error: %v", srv.Addr, err)
+		}
+	}
+
+	// WebSocket connections are hijacked, so the HTTP server doesn't close them for us.
+	// The close frames go out in parallel, a stuck client holds up one worker for a second.
+	players := gs.players.snapshot()
+	gs.fanOut(players, func(player *Player) error {
+		player.sendClose(ReasonShutdown)
+		return nil
+	}, "shutdown")
+	for _, player := range players {
+		gs.unregisterPlayer(player)
+	}
+	// Their goroutines, timers and tick loops stop with them
+	for _, room := range gs.snapshotRooms() {
+		if err := room.Close("server shutting down"); err != nil {
+			log.Printf("Error closing room %s: %v", room.ID, err)
+		}
+	}
+	gs.unloadPlugins()
+
+	flushed := make(chan struct{})
+	go func() {
+		gs.persistWG.Wait()
+		close(flushed)
+	}()
+
+	select {
+	case <-flushed:
+		log.Printf("Server shut down")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
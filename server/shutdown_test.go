@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestShutdownStopsBackgroundWork(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	gs := NewGameServer(10,
+		WithBroadcastWorkers(0),
+		WithIdlePolicy(IdleConfig{}),
+		WithJanitor(time.Millisecond),
+		WithRoomManager(RoomManagerConfig{Interval: time.Millisecond}),
+		WithRTT(RTTConfig{Interval: time.Millisecond}),
+	)
+	room, err := gs.CreateRoom("ticking")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := room.StartTicker(time.Millisecond, func(time.Time) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := gs.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, open := gs.GetRoom("ticking"); open {
+		t.Fatal("room still open after Shutdown")
+	}
+	select {
+	case <-room.done:
+	default:
+		t.Fatal("room goroutine not told to stop")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("%d goroutines left behind:\n%s", runtime.NumGoroutine()-before, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// A second Shutdown has nothing left to do
+	if err := gs.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// The server can bootstrap WebRTC connections between the players of a room, for voice or
+// data channels, without carrying any of their traffic. A player sends RTC_JOIN to become a
+// peer of its room and gets RTC_PEERS back with the other peers and the ICE servers to use,
+// the other peers get RTC_PEER_JOINED and send it their offers. RTC_OFFER, RTC_ANSWER and
+// RTC_CANDIDATE are relayed as they are, with peer_id swapped from the target to the
+// sender, and only between peers of the same room. Leaving the room or sending RTC_LEAVE
+// ends the peering, the other peers get RTC_PEER_LEFT.
+
+var (
+	ErrSignalingNotEnabled = errors.New("WebRTC signaling is not enabled")
+	ErrPeerNotFound        = errors.New("peer is not signaling in the room")
+	ErrTooManyPeers        = errors.New("room has too many WebRTC peers")
+)
+
+// SignalingConfig configures WebRTC signaling
+type SignalingConfig struct {
+	// ICEServers are the STUN and TURN servers sent with RTC_PEERS
+	ICEServers []ICEServerPayload
+	// ICEServersFor replaces ICEServers when set, for TURN credentials issued per player
+	ICEServersFor func(player *Player) []ICEServerPayload
+	// MaxPeers caps the peers of a room, every peer connects to every other one. No cap
+	// when 0.
+	MaxPeers int
+}
+
+// RTCPeers returns the IDs of the room's WebRTC peers
+func (r *Room) RTCPeers() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rtcPeersLocked("")
+}
+
+func (r *Room) rtcPeersLocked(except string) []string {
+	peers := make([]string, 0, len(r.rtcPeers))
+	for id := range r.rtcPeers {
+		if id != except {
+			peers = append(peers, id)
+		}
+	}
+	sort.Strings(peers)
+	return peers
+}
+
+// rtcJoin makes a member a peer, returning the other peers and whether it is new
+func (r *Room) rtcJoin(player *Player, maxPeers int) ([]string, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil, false, ErrRoomClosed
+	}
+	if _, member := r.members[player.ID]; !member {
+		return nil, false, ErrNotInRoom
+	}
+	_, already := r.rtcPeers[player.ID]
+	if !already && maxPeers > 0 && len(r.rtcPeers) >= maxPeers {
+		return nil, false, ErrTooManyPeers
+	}
+	if r.rtcPeers == nil {
+		r.rtcPeers = make(map[string]struct{})
+	}
+	r.rtcPeers[player.ID] = struct{}{}
+	return r.rtcPeersLocked(player.ID), !already, nil
+}
+
+// rtcLeave ends the peering of a player, returning the peers to tell
+func (r *Room) rtcLeave(playerID string) ([]string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rtcLeaveLocked(playerID)
+}
+
+func (r *Room) rtcLeaveLocked(playerID string) ([]string, bool) {
+	if _, peer := r.rtcPeers[playerID]; !peer {
+		return nil, false
+	}
+	delete(r.rtcPeers, playerID)
+	return r.rtcPeersLocked(""), true
+}
+
+func (r *Room) rtcPeer(playerID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, peer := r.rtcPeers[playerID]
+	return peer
+}
+
+// notifyPeers sends RTC_PEER_JOINED or RTC_PEER_LEFT about peerID to the other peers
+func (r *Room) notifyPeers(peers []string, msgType MessageType, peerID string) {
+	for _, id := range peers {
+		if err := r.gs.SendStructuredMessage(id, msgType, RTCPeerPayload{RoomID: r.ID, PeerID: peerID}); err != nil {
+			r.Logf("Error sending %s to peer %s: %v", msgType, id, err)
+		}
+	}
+}
+
+// handleSignaling routes RTC_JOIN, RTC_LEAVE and the messages relayed between peers
+func (gs *GameServer) handleSignaling(player *Player, msg StructuredMessage) error {
+	if gs.signaling == nil {
+		return ErrSignalingNotEnabled
+	}
+	room := player.room.Load()
+	if room == nil {
+		return ErrNotInRoom
+	}
+
+	switch msg.Type {
+	case RTCJoin:
+		peers, joined, err := room.rtcJoin(player, gs.signaling.MaxPeers)
+		if err != nil {
+			return err
+		}
+		iceServers := gs.signaling.ICEServers
+		if gs.signaling.ICEServersFor != nil {
+			iceServers = gs.signaling.ICEServersFor(player)
+		}
+		if err := gs.Reply(player, msg, RTCPeers, RTCPeersPayload{RoomID: room.ID, Peers: peers, ICEServers: iceServers}); err != nil {
+			return err
+		}
+		if joined {
+			room.notifyPeers(peers, RTCPeerJoined, player.ID)
+		}
+		return nil
+
+	case RTCLeave:
+		if peers, left := room.rtcLeave(player.ID); left {
+			room.notifyPeers(peers, RTCPeerLeft, player.ID)
+		}
+		return nil
+
+	case RTCOffer, RTCAnswer:
+		var session RTCSessionPayload
+		if err := json.Unmarshal(msg.Payload, &session); err != nil {
+			return Reject(ErrorInvalidPayload, err)
+		}
+		to := session.PeerID
+		if err := room.checkPeers(player.ID, to); err != nil {
+			return err
+		}
+		session.PeerID = player.ID
+		return gs.SendStructuredMessage(to, msg.Type, session)
+
+	case RTCCandidate:
+		var candidate RTCCandidatePayload
+		if err := json.Unmarshal(msg.Payload, &candidate); err != nil {
+			return Reject(ErrorInvalidPayload, err)
+		}
+		to := candidate.PeerID
+		if err := room.checkPeers(player.ID, to); err != nil {
+			return err
+		}
+		candidate.PeerID = player.ID
+		return gs.SendStructuredMessage(to, msg.Type, candidate)
+	}
+
+	return fmt.Errorf("unknown signaling message %s", msg.Type)
+}
+
+// checkPeers makes sure signaling only flows between two peers of the same room
+func (r *Room) checkPeers(from, to string) error {
+	if from == to || !r.rtcPeer(from) || !r.rtcPeer(to) {
+		return fmt.Errorf("%w: %s", ErrPeerNotFound, to)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrNotTicking       = errors.New("room has no tick loop")
+	ErrInvalidTimeScale = errors.New("time scale must be a positive finite number")
+)
+
+// Time scales outside this range are clamped, so the wait between ticks can't overflow a time.Duration
+const (
+	MinTimeScale = 0.01
+	MaxTimeScale = 100.0
+)
+
+// tickLoop is the state of a room's tick loop, only touched by the room goroutine
+type tickLoop struct {
+	interval time.Duration
+	scale    float64
+	paused   bool
+	now      time.Time
+	fn       func(now time.Time)
+	timer    *time.Timer
+}
+
+// wait is the real time between two ticks at the current speed
+func (l *tickLoop) wait() time.Duration {
+	return time.Duration(float64(l.interval) / l.scale)
+}
+
+func (l *tickLoop) tick() {
+	l.now = l.now.Add(l.interval)
+	l.fn(l.now)
+}
+
+func (l *tickLoop) start() *tickLoop {
+	l.timer = time.NewTimer(l.wait())
+	return l
+}
+
+// fire runs a tick that came due and schedules the next one
+func (l *tickLoop) fire() {
+	// Deferred so a tick that panics doesn't stop the loop
+	defer l.timer.Reset(l.wait())
+	if !l.paused {
+		l.tick()
+	}
+}
+
+// controlTick queues cmd on the room goroutine, the next tick follows the changed speed
+func (r *Room) controlTick(cmd func(*tickLoop)) error {
+	r.mu.Lock()
+	closed, ticking := r.closed, r.ticking
+	r.mu.Unlock()
+
+	if closed {
+		return ErrRoomClosed
+	}
+	if !ticking {
+		return ErrNotTicking
+	}
+
+	return r.post(func() {
+		l := r.loop
+		cmd(l)
+		if !l.timer.Stop() {
+			select {
+			case <-l.timer.C:
+			default:
+			}
+		}
+		l.timer.Reset(l.wait())
+	})
+}
+
+// SetTimeScale slows down (< 1) or speeds up (> 1) the room's tick loop
+// Every tick still advances game time by the same interval, only real time between ticks changes.
+// The scale is clamped to [MinTimeScale, MaxTimeScale], zero, negative, NaN and infinite scales are rejected.
+func (r *Room) SetTimeScale(scale float64) error {
+	if math.IsNaN(scale) || math.IsInf(scale, 0) || scale <= 0 {
+		return fmt.Errorf("%w, got %v", ErrInvalidTimeScale, scale)
+	}
+	scale = min(max(scale, MinTimeScale), MaxTimeScale)
+	return r.controlTick(func(l *tickLoop) {
+		l.scale = scale
+	})
+}
+
+// Pause stops ticking until Resume, use Step to advance one tick at a time
+func (r *Room) Pause() error {
+	return r.controlTick(func(l *tickLoop) {
+		l.paused = true
+	})
+}
+
+func (r *Room) Resume() error {
+	return r.controlTick(func(l *tickLoop) {
+		l.paused = false
+	})
+}
+
+// Step runs exactly one tick, only allowed while paused
+func (r *Room) Step() error {
+	result := make(chan error, 1)
+	err := r.controlTick(func(l *tickLoop) {
+		if !l.paused {
+			result <- fmt.Errorf("room %s must be paused to step", r.ID)
+			return
+		}
+		l.tick()
+		result <- nil
+	})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case err := <-result:
+		return err
+	case <-r.done:
+		return ErrRoomClosed
+	}
+}
+
+// SimControlHandler is a dev endpoint to control room simulation speed, e.g.
+//
+//	POST /dev/rooms/speed?room=ID&action=pause|resume|step
+//	POST /dev/rooms/speed?room=ID&action=scale&value=0.25
+//
+// It has no authentication, only mount it on a private mux or behind admin auth.
+func (gs *GameServer) SimControlHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		room, exists := gs.GetRoom(r.URL.Query().Get("room"))
+		if !exists {
+			http.Error(w, "room not found", http.StatusNotFound)
+			return
+		}
+
+		var err error
+		switch r.URL.Query().Get("action") {
+		case "pause":
+			err = room.Pause()
+		case "resume":
+			err = room.Resume()
+		case "step":
+			err = room.Step()
+		case "scale":
+			scale, parseErr := strconv.ParseFloat(r.URL.Query().Get("value"), 64)
+			if parseErr != nil {
+				http.Error(w, "invalid scale value", http.StatusBadRequest)
+				return
+			}
+			err = room.SetTimeScale(scale)
+		default:
+			http.Error(w, "unknown action", http.StatusBadRequest)
+			return
+		}
+
+		if errors.Is(err, ErrInvalidTimeScale) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
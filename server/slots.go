@@ -0,0 +1,56 @@
+package server
+
+import "net/http"
+
+// SlotClass decides which capacity pool a connection is counted against
+type SlotClass string
+
+const (
+	SlotRegular   SlotClass = "regular"
+	SlotPremium   SlotClass = "premium"
+	SlotModerator SlotClass = "moderator"
+	SlotAdmin     SlotClass = "admin"
+)
+
+// SlotClassifier picks the slot class for an incoming connection
+// Make sure it checks something the client can't fake (a signed token, a session cookie...),
+// trusting a plain query parameter would let anyone skip the line
+type SlotClassifier func(r *http.Request) SlotClass
+
+func defaultSlotClassifier(r *http.Request) SlotClass {
+	return SlotRegular
+}
+
+// takeSlotLocked counts a player in, false when there's no slot for it. gs.playersMu must
+// be held. Everyone can join while fewer than maxPlayers hold regular slots. Reserved
+// classes additionally get their own slots on top of that, so admins can always get in
+// even when the server is packed. Only players let in past maxPlayers use up reserved
+// slots, and those don't count against maxPlayers.
+func (gs *GameServer) takeSlotLocked(player *Player) bool {
+	if gs.players.len()-gs.spectators-gs.reservedTakenLocked() < gs.maxPlayers {
+		return true
+	}
+	if gs.reservedInUse[player.SlotClass] < gs.reservedSlots[player.SlotClass] {
+		gs.reservedInUse[player.SlotClass]++
+		player.reservedSlot = true
+		return true
+	}
+	return false
+}
+
+// reservedTakenLocked is how many players sit in reserved slots, gs.playersMu must be held
+func (gs *GameServer) reservedTakenLocked() int {
+	taken := 0
+	for _, n := range gs.reservedInUse {
+		taken += n
+	}
+	return taken
+}
+
+// releaseSlotLocked gives back the reserved slot of a player leaving its slot
+func (gs *GameServer) releaseSlotLocked(player *Player) {
+	if player.reservedSlot {
+		gs.reservedInUse[player.SlotClass]--
+		player.reservedSlot = false
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"errors"
+	"testing"
+)
+
+func newSlotTestServer() *GameServer {
+	return NewGameServer(2, WithReservedSlots(map[SlotClass]int{SlotAdmin: 1}))
+}
+
+func addTestBot(t *testing.T, gs *GameServer, class SlotClass) *Bot {
+	t.Helper()
+	bot, err := gs.AddBot(BotOptions{Class: class})
+	if err != nil {
+		t.Fatalf("%s join: %v", class, err)
+	}
+	return bot
+}
+
+func TestReservedSlotAfterRegularLeaves(t *testing.T) {
+	gs := newSlotTestServer()
+	first := addTestBot(t, gs, SlotRegular)
+	addTestBot(t, gs, SlotRegular)
+	if _, err := gs.AddBot(BotOptions{Class: SlotRegular}); !errors.Is(err, ErrServerFull) {
+		t.Fatalf("third regular got in: %v", err)
+	}
+
+	admin := addTestBot(t, gs, SlotAdmin)
+	if !admin.Player.reservedSlot {
+		t.Fatal("admin past maxPlayers should hold a reserved slot")
+	}
+
+	first.Leave()
+	addTestBot(t, gs, SlotRegular)
+	if _, err := gs.AddBot(BotOptions{Class: SlotRegular}); !errors.Is(err, ErrServerFull) {
+		t.Fatalf("regular got past maxPlayers: %v", err)
+	}
+}
+
+func TestReservedSlotFreedOnLeave(t *testing.T) {
+	gs := newSlotTestServer()
+	addTestBot(t, gs, SlotRegular)
+	addTestBot(t, gs, SlotRegular)
+	admin := addTestBot(t, gs, SlotAdmin)
+	if _, err := gs.AddBot(BotOptions{Class: SlotAdmin}); !errors.Is(err, ErrServerFull) {
+		t.Fatalf("second admin got a reserved slot that is taken: %v", err)
+	}
+
+	admin.Leave()
+	addTestBot(t, gs, SlotAdmin)
+}
+
+func TestReservedSlotUsesFreeRegularSlot(t *testing.T) {
+	gs := newSlotTestServer()
+	addTestBot(t, gs, SlotRegular)
+	admin := addTestBot(t, gs, SlotAdmin)
+	if admin.Player.reservedSlot {
+		t.Fatal("admin below maxPlayers shouldn't use up a reserved slot")
+	}
+}
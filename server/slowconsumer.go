@@ -0,0 +1,154 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// A stalled TCP connection makes every write to it wait for the write deadline, and everyone
+// writing to that player (broadcasters holding locks included) waits with it. The slow consumer
+// policy watches how many writes pile up per player and how long they take, then either
+// disconnects the player or degrades it to fewer state updates until it catches up.
+
+var ErrSlowConsumer = errors.New("player is not keeping up with its messages")
+
+// SlowConsumerAction is what happens to a player that can't keep up
+type SlowConsumerAction int
+
+const (
+	// SlowDisconnect closes the connection, the slot frees up once the read loop notices
+	SlowDisconnect SlowConsumerAction = iota
+	// SlowDegrade keeps the player but sends it droppable updates at a lower rate
+	SlowDegrade
+)
+
+// SlowConsumerPolicy decides when a player is too slow and what to do about it
+type SlowConsumerPolicy struct {
+	// MaxPending is how many writes may wait for the player at once, 0 means no limit
+	// Writes beyond it fail right away with ErrSlowConsumer instead of queueing.
+	MaxPending int
+	// MaxLatency is the average write time above which a player counts as slow, 0 means no limit
+	MaxLatency time.Duration
+	Action     SlowConsumerAction
+	// DegradedInterval is how often a degraded player still gets droppable updates, 200ms when 0
+	DegradedInterval time.Duration
+	// Droppable lists the message types a degraded player may miss, GAME_STATE_SYNC when empty
+	// Newer updates of these types supersede older ones, so skipping some is harmless.
+	Droppable []MessageType
+}
+
+const (
+	defaultDegradedInterval = 200 * time.Millisecond
+	// latencyWeight is how much a new write counts in the average write time
+	latencyWeight = 0.2
+)
+
+type slowConsumerPolicy struct {
+	SlowConsumerPolicy
+	droppable map[MessageType]bool
+}
+
+// writeStats is embedded in Player, avgLatency is only touched with the player's mu held
+type writeStats struct {
+	pending    atomic.Int32
+	avgLatency time.Duration
+	degraded   atomic.Bool
+	// lastDroppable is the unix nano time of the last droppable update sent while degraded
+	lastDroppable atomic.Int64
+	evicted       atomic.Bool
+}
+
+// WriteStats is a snapshot of how a player's connection keeps up
+type WriteStats struct {
+	Pending    int           `json:"pending"`
+	AvgLatency time.Duration `json:"avg_latency"`
+	Degraded   bool          `json:"degraded"`
+}
+
+// WriteStats returns the player's pending writes and average write time
+func (p *Player) WriteStats() WriteStats {
+	p.mu.Lock()
+	avg := p.writes.avgLatency
+	p.mu.Unlock()
+	return WriteStats{
+		Pending:    int(p.writes.pending.Load()),
+		AvgLatency: avg,
+		Degraded:   p.writes.degraded.Load(),
+	}
+}
+
+// enterWrite counts a write waiting for the player, false means too many already wait
+// Control messages always get in line.
+func (p *Player) enterWrite(prio Priority) bool {
+	n := p.writes.pending.Add(1)
+	if policy := p.slowPolicy; policy != nil && policy.MaxPending > 0 && int(n) > policy.MaxPending && prio < PriorityControl {
+		p.writes.pending.Add(-1)
+		p.slowConsumer("%d writes pending", n-1)
+		return false
+	}
+	return true
+}
+
+func (p *Player) leaveWrite() {
+	p.writes.pending.Add(-1)
+}
+
+// recordWriteLatency updates the average write time, called with the player's mu held
+func (p *Player) recordWriteLatency(d time.Duration) {
+	avg := p.writes.avgLatency
+	if avg == 0 {
+		avg = d
+	} else {
+		avg += time.Duration(latencyWeight * float64(d-avg))
+	}
+	p.writes.avgLatency = avg
+
+	policy := p.slowPolicy
+	if policy == nil || policy.MaxLatency <= 0 {
+		return
+	}
+	switch {
+	case avg > policy.MaxLatency:
+		p.slowConsumer("average write time %v", avg)
+	case avg < policy.MaxLatency/2 && p.writes.pending.Load() <= 1 && p.writes.degraded.CompareAndSwap(true, false):
+		log.Printf("Player %s caught up, sending full updates again", p.ID)
+	}
+}
+
+// slowConsumer applies the policy to a player that fell behind
+func (p *Player) slowConsumer(format string, args ...interface{}) {
+	if p.slowPolicy.Action == SlowDegrade {
+		if !p.writes.degraded.Swap(true) {
+			log.Printf("Player %s is falling behind ("+format+"), degrading updates", append([]interface{}{p.ID}, args...)...)
+		}
+		return
+	}
+
+	if p.writes.evicted.Swap(true) {
+		// Already being disconnected
+		return
+	}
+	p.metrics.slowConsumers.add(p.metricShard, 1)
+	log.Printf("Player %s is not keeping up ("+format+"), closing connection", append([]interface{}{p.ID}, args...)...)
+	// Closing is safe next to a blocked writer and unblocks it
+	p.setDisconnectReason(ReasonSlowConsumer)
+	p.closeConn()
+}
+
+// skipUpdate reports whether a degraded player should miss this update
+func (p *Player) skipUpdate(msgType MessageType) bool {
+	policy := p.slowPolicy
+	if policy == nil || !p.writes.degraded.Load() || !policy.droppable[msgType] {
+		return false
+	}
+
+	now := time.Now().UnixNano()
+	last := p.writes.lastDroppable.Load()
+	if now-last < int64(policy.DegradedInterval) {
+		return true
+	}
+	// Only one of several concurrent broadcasts gets through
+	return !p.writes.lastDroppable.CompareAndSwap(last, now)
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+var ErrSpectatorsFull = errors.New("no spectator slots left")
+
+// IsSpectator reports whether the player is only watching
+func (p *Player) IsSpectator() bool {
+	return p.spectating.Load()
+}
+
+// RegisterSpectator registers a connection that only watches, it doesn't take a player slot
+func (gs *GameServer) RegisterSpectator(conn *websocket.Conn) (*Player, error) {
+	player := gs.newWebSocketPlayer(conn, SlotRegular)
+	if err := gs.checkBans(player.AccountID, connIP(conn)); err != nil {
+		return nil, err
+	}
+	if err := gs.addSpectator(player); err != nil {
+		return nil, err
+	}
+	return player, nil
+}
+
+func (gs *GameServer) addSpectator(player *Player) error {
+	gs.playersMu.Lock()
+	if gs.spectators >= gs.maxSpectators {
+		gs.playersMu.Unlock()
+		return ErrSpectatorsFull
+	}
+
+	player.spectating.Store(true)
+	gs.startPersistQueue(player)
+	gs.players.add(player)
+	gs.spectators++
+	gs.playersMu.Unlock()
+
+	log.Printf("Spectator %s connected", player.ID)
+	if player.Joined() {
+		gs.playerVisible(player)
+	}
+	gs.sendSessionLists(player.AccountID)
+	return nil
+}
+
+// StartSpectating turns a player into a spectator, freeing their player slot
+func (gs *GameServer) StartSpectating(player *Player) error {
+	gs.playersMu.Lock()
+	if player.IsSpectator() {
+		gs.playersMu.Unlock()
+		return nil
+	}
+	if gs.spectators >= gs.maxSpectators {
+		gs.playersMu.Unlock()
+		return ErrSpectatorsFull
+	}
+
+	player.spectating.Store(true)
+	gs.spectators++
+	gs.releaseSlotLocked(player)
+	gs.playersMu.Unlock()
+
+	// The player slot is free now
+	if gs.queue != nil {
+		gs.queue.slotFreed()
+		gs.admitFromQueue()
+	}
+	return nil
+}
+
+// StopSpectating turns a spectator back into a player if there's a free player slot
+func (gs *GameServer) StopSpectating(player *Player) error {
+	gs.playersMu.Lock()
+	defer gs.playersMu.Unlock()
+
+	if !player.IsSpectator() {
+		return nil
+	}
+	if !gs.takeSlotLocked(player) {
+		return ErrServerFull
+	}
+
+	player.spectating.Store(false)
+	gs.spectators--
+	return nil
+}
+
+// checkSpectatorMessage rejects gameplay messages from spectators
+func checkSpectatorMessage(player *Player, msgType MessageType) error {
+	if player.IsSpectator() && gameplayMessages[msgType] {
+		return Reject(ErrorUnauthorized, fmt.Errorf("spectator %s can't send %s", player.ID, msgType))
+	}
+	return nil
+}
+
+func (gs *GameServer) handleSpectate(w http.ResponseWriter, r *http.Request) {
+	if !gs.checkLoginBeforeUpgrade(w, r) {
+		return
+	}
+	ip, ok := gs.acquireIP(w, r)
+	if !ok {
+		return
+	}
+	if !gs.checkBansBeforeUpgrade(w, r, ip) {
+		gs.ipLimit.release(ip)
+		return
+	}
+
+	conn, err := gs.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade error: %v", err)
+		gs.ipLimit.release(ip)
+		return
+	}
+
+	spectator := gs.newWebSocketPlayer(conn, SlotRegular)
+	spectator.remoteIP = ip
+	spectator.device = deviceName(r)
+	if account, ok := gs.resolveAccount(r); ok {
+		spectator.AccountID = account
+	}
+	gs.setupCompression(spectator, r)
+
+	if err := gs.enforceSessionPolicy(spectator); err != nil {
+		log.Printf("Spectator registration error: %v", err)
+		gs.refuseConn(spectator, err)
+		return
+	}
+	if err := gs.addSpectator(spectator); err != nil {
+		log.Printf("Spectator registration error: %v", err)
+		gs.refuseConn(spectator, err)
+		return
+	}
+
+	gs.greet(spectator)
+	go gs.HandlePlayerMessages(spectator)
+}
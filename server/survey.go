@@ -0,0 +1,222 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/iknizzz1807/socket-server-template/database"
+)
+
+// When a room closes its players get a SURVEY_REQUEST. Answers are only taken for matches
+// the account actually played, within the configured window, once per match.
+
+var (
+	ErrNoSurvey          = errors.New("no survey pending for this match")
+	ErrSurveysNotEnabled = errors.New("surveys are not enabled")
+)
+
+const (
+	defaultSurveyMaxRating   = 5
+	defaultSurveyWindow      = 10 * time.Minute
+	defaultSurveyMaxFeedback = 1000
+)
+
+// SurveyConfig tunes the end-of-match survey
+type SurveyConfig struct {
+	// MaxRating is the top of the rating scale, 5 when 0
+	MaxRating int
+	// Window is how long players have to answer, 10 minutes when 0
+	Window time.Duration
+	// MaxFeedbackLength caps the feedback text in characters, 1000 when 0
+	MaxFeedbackLength int
+	// Filter picks the rooms that get a survey, nil surveys every room
+	Filter func(room *Room) bool
+}
+
+type pendingSurvey struct {
+	mode, mapName string
+	players       []string
+	expires       time.Time
+}
+
+type surveys struct {
+	store database.SurveyStore
+	cfg   SurveyConfig
+
+	mu sync.Mutex
+	// pending is keyed by account then match ID
+	pending map[string]map[string]pendingSurvey
+}
+
+// SetMap labels the room with the map it's played on, surveys are aggregated per mode and map
+func (r *Room) SetMap(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mapName = name
+}
+
+func (r *Room) Map() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.mapName
+}
+
+// requestSurveys asks the players of a closed room for feedback
+func (gs *GameServer) requestSurveys(room *Room, mode, mapName string, members map[string]*Player) {
+	if gs.surveys == nil || len(members) == 0 {
+		return
+	}
+	if gs.surveys.cfg.Filter != nil && !gs.surveys.cfg.Filter(room) {
+		return
+	}
+
+	accounts := make([]string, 0, len(members))
+	for _, player := range members {
+		accounts = append(accounts, player.AccountID)
+	}
+	pending := pendingSurvey{mode: mode, mapName: mapName, players: accounts, expires: time.Now().Add(gs.surveys.cfg.Window)}
+	gs.surveys.add(room.ID, pending)
+
+	payload := SurveyRequestPayload{
+		MatchID:   room.ID,
+		Mode:      mode,
+		Map:       mapName,
+		Players:   accounts,
+		MaxRating: gs.surveys.cfg.MaxRating,
+		ExpiresIn: int(gs.surveys.cfg.Window.Seconds()),
+	}
+	for _, player := range members {
+		if err := gs.SendSurveyRequest(player.ID, payload); err != nil {
+			log.Printf("Error sending survey of match %s to player %s: %v", room.ID, player.ID, err)
+		}
+	}
+}
+
+func (s *surveys) add(matchID string, p pendingSurvey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop what expired while we're here, unanswered surveys would pile up otherwise
+	now := time.Now()
+	for account, matches := range s.pending {
+		for id, m := range matches {
+			if now.After(m.expires) {
+				delete(matches, id)
+			}
+		}
+		if len(matches) == 0 {
+			delete(s.pending, account)
+		}
+	}
+
+	for _, account := range p.players {
+		if s.pending[account] == nil {
+			s.pending[account] = make(map[string]pendingSurvey)
+		}
+		s.pending[account][matchID] = p
+	}
+}
+
+// take removes and returns the account's survey of a match, a survey can only be answered once
+func (s *surveys) take(accountID, matchID string) (pendingSurvey, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.pending[accountID][matchID]
+	if !ok || time.Now().After(p.expires) {
+		return pendingSurvey{}, false
+	}
+	delete(s.pending[accountID], matchID)
+	return p, true
+}
+
+func (gs *GameServer) handleSurveyResponse(player *Player, msg StructuredMessage) error {
+	if gs.surveys == nil {
+		return ErrSurveysNotEnabled
+	}
+
+	var payload SurveyResponsePayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid survey response: %v", err)
+	}
+	cfg := gs.surveys.cfg
+	if payload.Rating < 0 || payload.Rating > cfg.MaxRating {
+		return fmt.Errorf("survey rating %d out of range from player %s", payload.Rating, player.ID)
+	}
+	feedback := strings.TrimSpace(payload.Feedback)
+	if utf8.RuneCountInString(feedback) > cfg.MaxFeedbackLength {
+		return fmt.Errorf("survey feedback from player %s is too long", player.ID)
+	}
+
+	pending, ok := gs.surveys.take(player.AccountID, payload.MatchID)
+	if !ok {
+		return fmt.Errorf("survey from player %s for match %s: %w", player.ID, payload.MatchID, ErrNoSurvey)
+	}
+
+	response := database.SurveyResponse{
+		MatchID:     payload.MatchID,
+		Mode:        pending.mode,
+		Map:         pending.mapName,
+		AccountID:   player.AccountID,
+		Rating:      payload.Rating,
+		Feedback:    feedback,
+		SubmittedAt: time.Now(),
+	}
+	// Only players of the same match can be reported, and not yourself
+	if payload.Report != "" && payload.Report != player.AccountID {
+		for _, account := range pending.players {
+			if account == payload.Report {
+				response.Reported = payload.Report
+				response.ReportReason = strings.TrimSpace(payload.ReportReason)
+			}
+		}
+	}
+
+	if err := gs.surveys.store.SaveSurvey(response); err != nil {
+		return fmt.Errorf("failed to save survey of match %s: %v", payload.MatchID, err)
+	}
+	return nil
+}
+
+// SurveySummaries aggregates every saved response per mode and map
+func (gs *GameServer) SurveySummaries() ([]database.SurveySummary, error) {
+	if gs.surveys == nil {
+		return nil, ErrSurveysNotEnabled
+	}
+	responses, err := gs.surveys.store.Surveys()
+	if err != nil {
+		return nil, err
+	}
+	return database.SummarizeSurveys(responses), nil
+}
+
+// SurveySummaryHandler is an admin endpoint returning the survey aggregates as JSON
+//
+//	GET /admin/surveys
+//
+// It has no authentication, only mount it on a private mux or behind admin auth.
+func (gs *GameServer) SurveySummaryHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if gs.surveys == nil {
+			http.Error(w, ErrSurveysNotEnabled.Error(), http.StatusNotImplemented)
+			return
+		}
+		summaries, err := gs.SurveySummaries()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(summaries); err != nil {
+			log.Printf("Error writing survey summaries: %v", err)
+		}
+	})
+}
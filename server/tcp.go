@@ -0,0 +1,159 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// The TCP listener is for native clients (Unity, Unreal, custom engines) that don't want a
+// websocket stack. Frames are length-prefixed like on every stream transport, see transport.go.
+//
+// The first frame a client sends is a text frame with the handshake below, it stands in for
+// the upgrade request so account resolvers, slot classifiers and bans work unchanged:
+//
+//	{"path": "/ws?token=abc&device=unity", "headers": {"Authorization": "Bearer abc"}}
+//
+// After that the connection speaks the same messages as /ws. Being refused or kicked sends
+// a close frame (websocket.CloseMessage) with the usual close payload before the socket closes.
+
+// tcpHandshakeTimeout is how long a fresh connection gets to send its handshake
+const tcpHandshakeTimeout = 10 * time.Second
+
+var errBadHandshake = errors.New("bad handshake")
+
+type tcpHandshake struct {
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers"`
+}
+
+// tcpConn adapts a TCP connection to the transport interface
+type tcpConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	closeOnce sync.Once
+}
+
+func newTCPConn(conn net.Conn) *tcpConn {
+	return &tcpConn{conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *tcpConn) ReadFrame(deadline time.Time) (int, []byte, error) {
+	c.conn.SetReadDeadline(deadline)
+	return readStreamFrame(c.r)
+}
+
+func (c *tcpConn) WriteFrame(messageType int, data []byte, deadline time.Time) error {
+	buf, err := encodeStreamFrame(messageType, data)
+	if err != nil {
+		return err
+	}
+	c.conn.SetWriteDeadline(deadline)
+	_, err = c.conn.Write(buf)
+	return err
+}
+
+func (c *tcpConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// Close sends a close frame when it can get through within a second, the short deadline
+// also unblocks a writer stuck on a full socket
+func (c *tcpConn) Close(code int, reason string) error {
+	var err error
+	c.closeOnce.Do(func() {
+		if buf, encErr := encodeStreamFrame(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason)); encErr == nil {
+			c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+			c.conn.Write(buf)
+		}
+		err = c.conn.Close()
+	})
+	return err
+}
+
+// request turns the handshake into the request the HTTP hooks expect
+func (h tcpHandshake) request(conn net.Conn) (*http.Request, error) {
+	path := h.Path
+	if path == "" {
+		path = "/ws"
+	}
+	r, err := http.NewRequest(http.MethodGet, "tcp://"+conn.LocalAddr().String()+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range h.Headers {
+		r.Header.Set(k, v)
+	}
+	r.RemoteAddr = conn.RemoteAddr().String()
+	return r, nil
+}
+
+// StartTCP accepts native clients on addr until Shutdown, there is no TLS so put it behind a
+// TLS-terminating load balancer when it leaves a private network
+func (gs *GameServer) StartTCP(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	gs.serversMu.Lock()
+	gs.tcpListeners = append(gs.tcpListeners, ln)
+	gs.serversMu.Unlock()
+
+	log.Printf("TCP server starting on %s", addr)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		go gs.handleTCP(conn)
+	}
+}
+
+func (gs *GameServer) handleTCP(conn net.Conn) {
+	tc := newTCPConn(conn)
+	r, err := gs.readTCPHandshake(tc)
+	if err != nil {
+		log.Printf("TCP handshake error from %s: %v", conn.RemoteAddr(), err)
+		reason := ReasonProtocolError.WithDetail("bad handshake")
+		tc.Close(reason.Code, reason.String())
+		return
+	}
+
+	// The IP comes from the socket, a client could put anything in forwarding headers
+	err = gs.ServeTransport(tc, r)
+	if err != nil {
+		reason := refuseReason(err)
+		tc.Close(reason.Code, reason.String())
+	}
+}
+
+func (gs *GameServer) readTCPHandshake(tc *tcpConn) (*http.Request, error) {
+	messageType, data, err := tc.ReadFrame(time.Now().Add(tcpHandshakeTimeout))
+	if err != nil {
+		return nil, err
+	}
+	if messageType != websocket.TextMessage {
+		return nil, errBadHandshake
+	}
+	var h tcpHandshake
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, errBadHandshake
+	}
+	return h.request(tc.conn)
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// StartServerTLS is StartServer with TLS, certFile and keyFile are PEM encoded
+// Browsers refuse ws:// from a page served over HTTPS, so production deployments need wss://.
+// certFile may contain the whole chain, leaf certificate first.
+func (gs *GameServer) StartServerTLS(addr, certFile, keyFile string) error {
+	srv := gs.newHTTPServer(addr)
+	srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+
+	log.Printf("Server starting with TLS on %s", addr)
+	return serveResult(srv.ListenAndServeTLS(certFile, keyFile))
+}
+
+// AutoTLSConfig gets certificates from Let's Encrypt for the listed domains
+type AutoTLSConfig struct {
+	// Domains the server may request certificates for, at least one is required
+	Domains []string
+	// CacheDir keeps certificates across restarts so they aren't requested on every start
+	// Leaving it empty is only fine for testing, Let's Encrypt rate limits are strict.
+	CacheDir string
+	// Email is given to Let's Encrypt for expiry notices, optional
+	Email string
+	// ChallengeAddr answers HTTP-01 challenges and redirects everything else to HTTPS,
+	// defaults to ":80". Let's Encrypt always connects to port 80.
+	ChallengeAddr string
+}
+
+// StartServerAutoTLS serves wss:// on addr (usually ":443") with certificates obtained
+// and renewed automatically through autocert
+func (gs *GameServer) StartServerAutoTLS(addr string, cfg AutoTLSConfig) error {
+	if len(cfg.Domains) == 0 {
+		return fmt.Errorf("auto TLS needs at least one domain")
+	}
+	if cfg.ChallengeAddr == "" {
+		cfg.ChallengeAddr = ":80"
+	}
+
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(cfg.Domains...),
+		Email:      cfg.Email,
+	}
+	if cfg.CacheDir != "" {
+		manager.Cache = autocert.DirCache(cfg.CacheDir)
+	}
+
+	challenge := gs.newHTTPServer(cfg.ChallengeAddr)
+	challenge.Handler = manager.HTTPHandler(nil)
+	go func() {
+		if err := serveResult(challenge.ListenAndServe()); err != nil {
+			log.Printf("ACME challenge server error: %v", err)
+		}
+	}()
+
+	srv := gs.newHTTPServer(addr)
+	srv.TLSConfig = manager.TLSConfig()
+	srv.TLSConfig.MinVersion = tls.VersionTLS12
+
+	log.Printf("Server starting with automatic TLS on %s for %v", addr, cfg.Domains)
+	// Certificates come from TLSConfig.GetCertificate
+	return serveResult(srv.ListenAndServeTLS("", ""))
+}
@@ -0,0 +1,556 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Tournaments run a bracket over a fixed list of accounts. Whenever both players of a
+// match are known the tournament creates a room for it and moves their connections in.
+// The game decides the match by calling room.SetResult("winner", accountID) before closing
+// the room, or reports it with Tournament.ReportResult; either way the winner moves on,
+// and in double elimination the loser drops to the losers bracket. Participants and
+// players watching with TOURNAMENT_WATCH get a TOURNAMENT_UPDATE after every change.
+//
+// Brackets are filled up to a power of two with byes, which go to the top seeds. Double
+// elimination ends with one grand final, there is no bracket reset. A finished tournament
+// can still be looked up and watched for finishedTournamentTTL, then it is forgotten.
+
+// Tournament formats
+const (
+	SingleElimination = "single_elimination"
+	DoubleElimination = "double_elimination"
+)
+
+// Bracket names and match states, as sent in TOURNAMENT_UPDATE
+const (
+	bracketWinners = "winners"
+	bracketLosers  = "losers"
+	bracketFinal   = "final"
+
+	matchPending  = "pending"
+	matchPlaying  = "playing"
+	matchFinished = "finished"
+	matchBye      = "bye"
+
+	byeName = "BYE"
+)
+
+const finishedTournamentTTL = time.Hour
+
+var (
+	ErrTournamentNotFound   = errors.New("tournament not found")
+	ErrTooFewParticipants   = errors.New("a tournament needs at least two participants")
+	ErrUnknownFormat        = errors.New("unknown tournament format")
+	ErrBracketMatchNotFound = errors.New("bracket match not found")
+	ErrMatchNotPlaying      = errors.New("bracket match is not being played")
+	ErrNotInMatch           = errors.New("winner is not a player of the match")
+)
+
+// TournamentConfig describes a new tournament
+type TournamentConfig struct {
+	Name string
+	// Format is SingleElimination or DoubleElimination
+	Format string
+	// Participants are account IDs, best seed first
+	Participants []string
+	// Mode is the game mode of the match rooms, plain rooms when empty
+	Mode string
+}
+
+// Tournament is a running bracket, see CreateTournament
+type Tournament struct {
+	ID string
+
+	gs  *GameServer
+	cfg TournamentConfig
+
+	mu         sync.Mutex
+	matches    []*bracketMatch
+	byID       map[string]*bracketMatch
+	champion   string
+	finishedAt time.Time
+	// ready collects the matches whose players became known, they get a room once the
+	// lock is released
+	ready []*bracketMatch
+	// watchers are players following with TOURNAMENT_WATCH, by player ID
+	watchers map[string]*Player
+}
+
+type bracketSlot struct {
+	account string
+	bye     bool
+	filled  bool
+}
+
+type bracketLink struct {
+	match *bracketMatch
+	slot  int
+}
+
+type bracketMatch struct {
+	id      string
+	bracket string
+	round   int
+	slots   [2]bracketSlot
+	status  string
+	winner  string
+	roomID  string
+
+	// Where the winner and the loser go, nil when they are done
+	winnerTo, loserTo *bracketLink
+}
+
+// tournaments are the server's running tournaments
+type tournaments struct {
+	subscribe sync.Once
+
+	mu     sync.Mutex
+	byID   map[string]*Tournament
+	byRoom map[string]*Tournament
+	// nextSweep is when sweepLocked looks for finished tournaments again
+	nextSweep time.Time
+}
+
+// CreateTournament builds the bracket and starts the first matches
+func (gs *GameServer) CreateTournament(cfg TournamentConfig) (*Tournament, error) {
+	if len(cfg.Participants) < 2 {
+		return nil, ErrTooFewParticipants
+	}
+	if cfg.Format != SingleElimination && cfg.Format != DoubleElimination {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, cfg.Format)
+	}
+	seen := make(map[string]bool)
+	for _, account := range cfg.Participants {
+		if account == "" || account == byeName || seen[account] {
+			return nil, fmt.Errorf("invalid or duplicate participant %q", account)
+		}
+		seen[account] = true
+	}
+
+	t := &Tournament{
+		ID:       generateUniqueID(),
+		gs:       gs,
+		cfg:      cfg,
+		byID:     make(map[string]*bracketMatch),
+		watchers: make(map[string]*Player),
+	}
+	t.cfg.Participants = append([]string(nil), cfg.Participants...)
+
+	gs.tournaments.subscribe.Do(func() {
+		Subscribe(gs.events, gs.tournamentMatchEnded)
+	})
+	gs.tournaments.mu.Lock()
+	if gs.tournaments.byID == nil {
+		gs.tournaments.byID = make(map[string]*Tournament)
+		gs.tournaments.byRoom = make(map[string]*Tournament)
+	}
+	gs.tournaments.sweepLocked(time.Now())
+	gs.tournaments.byID[t.ID] = t
+	gs.tournaments.mu.Unlock()
+
+	t.mu.Lock()
+	t.build()
+	t.mu.Unlock()
+	log.Printf("Tournament %s (%s) created with %d participants", t.ID, cfg.Format, len(cfg.Participants))
+	t.startReady()
+	return t, nil
+}
+
+// GetTournament returns a running tournament, or a finished one up to
+// finishedTournamentTTL after its final
+func (gs *GameServer) GetTournament(id string) (*Tournament, bool) {
+	gs.tournaments.mu.Lock()
+	defer gs.tournaments.mu.Unlock()
+	gs.tournaments.sweepLocked(time.Now())
+	t, ok := gs.tournaments.byID[id]
+	return t, ok
+}
+
+// sweepLocked forgets the tournaments that finished more than finishedTournamentTTL ago,
+// at most once a minute
+func (ts *tournaments) sweepLocked(now time.Time) {
+	if now.Before(ts.nextSweep) {
+		return
+	}
+	ts.nextSweep = now.Add(time.Minute)
+	for id, t := range ts.byID {
+		t.mu.Lock()
+		expired := !t.finishedAt.IsZero() && now.Sub(t.finishedAt) > finishedTournamentTTL
+		t.mu.Unlock()
+		if expired {
+			delete(ts.byID, id)
+		}
+	}
+}
+
+// seedOrder lists the seeds of the first round so that the best seeds meet last, e.g.
+// 0 7 3 4 1 6 2 5 for eight
+func seedOrder(size int) []int {
+	order := []int{0}
+	for len(order) < size {
+		next := make([]int, 0, len(order)*2)
+		for _, seed := range order {
+			next = append(next, seed, len(order)*2-1-seed)
+		}
+		order = next
+	}
+	return order
+}
+
+func (t *Tournament) addMatch(bracket, prefix string, round, n int) *bracketMatch {
+	m := &bracketMatch{
+		id:      fmt.Sprintf("%s%d-%d", prefix, round, n+1),
+		bracket: bracket,
+		round:   round,
+		status:  matchPending,
+	}
+	t.matches = append(t.matches, m)
+	t.byID[m.id] = m
+	return m
+}
+
+// build creates every match of the bracket, links them and seeds the first round
+func (t *Tournament) build() {
+	size := 2
+	rounds := 1
+	for size < len(t.cfg.Participants) {
+		size *= 2
+		rounds++
+	}
+
+	winners := make([][]*bracketMatch, rounds+1)
+	for r := 1; r <= rounds; r++ {
+		for i := 0; i < size>>r; i++ {
+			winners[r] = append(winners[r], t.addMatch(bracketWinners, "W", r, i))
+		}
+	}
+	for r := 1; r < rounds; r++ {
+		for i, m := range winners[r] {
+			m.winnerTo = &bracketLink{winners[r+1][i/2], i % 2}
+		}
+	}
+
+	if t.cfg.Format == DoubleElimination {
+		// Losers bracket: odd rounds pair up its own survivors, even rounds bring in the
+		// losers of the next winners round
+		losersRounds := 2 * (rounds - 1)
+		losers := make([][]*bracketMatch, losersRounds+1)
+		for r := 1; r <= losersRounds; r++ {
+			count := size >> ((r+1)/2 + 1)
+			for i := 0; i < count; i++ {
+				losers[r] = append(losers[r], t.addMatch(bracketLosers, "L", r, i))
+			}
+		}
+		final := &bracketMatch{id: "GF", bracket: bracketFinal, round: 1, status: matchPending}
+		t.matches = append(t.matches, final)
+		t.byID[final.id] = final
+
+		winners[rounds][0].winnerTo = &bracketLink{final, 0}
+		if rounds == 1 {
+			winners[1][0].loserTo = &bracketLink{final, 1}
+		} else {
+			for i, m := range winners[1] {
+				m.loserTo = &bracketLink{losers[1][i/2], i % 2}
+			}
+			for r := 2; r <= rounds; r++ {
+				for i, m := range winners[r] {
+					m.loserTo = &bracketLink{losers[2*(r-1)][i], 1}
+				}
+			}
+			for r := 1; r <= losersRounds; r++ {
+				for i, m := range losers[r] {
+					switch {
+					case r == losersRounds:
+						m.winnerTo = &bracketLink{final, 1}
+					case r%2 == 1:
+						m.winnerTo = &bracketLink{losers[r+1][i], 0}
+					default:
+						m.winnerTo = &bracketLink{losers[r+1][i/2], i % 2}
+					}
+				}
+			}
+		}
+	}
+
+	for i, seed := range seedOrder(size) {
+		slot := bracketSlot{bye: true}
+		if seed < len(t.cfg.Participants) {
+			slot = bracketSlot{account: t.cfg.Participants[seed]}
+		}
+		t.fill(&bracketLink{winners[1][i/2], i % 2}, slot)
+	}
+}
+
+// fill puts a player, or a bye, into a slot and settles the match once both are known
+func (t *Tournament) fill(link *bracketLink, slot bracketSlot) {
+	m := link.match
+	slot.filled = true
+	m.slots[link.slot] = slot
+	if !m.slots[0].filled || !m.slots[1].filled {
+		return
+	}
+
+	switch {
+	case m.slots[0].bye && m.slots[1].bye:
+		m.status = matchBye
+		t.advance(m, bracketSlot{bye: true}, bracketSlot{bye: true})
+	case m.slots[0].bye || m.slots[1].bye:
+		player := m.slots[0]
+		if player.bye {
+			player = m.slots[1]
+		}
+		m.status = matchBye
+		m.winner = player.account
+		t.advance(m, player, bracketSlot{bye: true})
+	default:
+		t.ready = append(t.ready, m)
+	}
+}
+
+// advance sends the winner and loser of a settled match on
+func (t *Tournament) advance(m *bracketMatch, winner, loser bracketSlot) {
+	if m.winnerTo != nil {
+		t.fill(m.winnerTo, winner)
+	} else if !winner.bye {
+		t.champion = winner.account
+		t.finishedAt = time.Now()
+		log.Printf("Tournament %s won by %s", t.ID, winner.account)
+	}
+	if m.loserTo != nil {
+		t.fill(m.loserTo, loser)
+	}
+}
+
+// startReady creates the rooms of the matches that became ready and tells everyone
+func (t *Tournament) startReady() {
+	t.mu.Lock()
+	ready := t.ready
+	t.ready = nil
+	t.mu.Unlock()
+
+	for _, m := range ready {
+		room, err := t.startRoom(m)
+		t.mu.Lock()
+		m.status = matchPlaying
+		if err == nil {
+			m.roomID = room.ID
+		}
+		t.mu.Unlock()
+		if err != nil {
+			log.Printf("Tournament %s couldn't create a room for %s, report it with ReportResult: %v", t.ID, m.id, err)
+		}
+	}
+	t.broadcast()
+}
+
+func (t *Tournament) startRoom(m *bracketMatch) (*Room, error) {
+	gs := t.gs
+	var room *Room
+	var err error
+	if t.cfg.Mode == "" {
+		room, err = gs.CreateRoom("")
+	} else {
+		room, err = gs.CreateRoomWithMode("", t.cfg.Mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	room.SetListed(false)
+	gs.tournaments.mu.Lock()
+	gs.tournaments.byRoom[room.ID] = t
+	gs.tournaments.mu.Unlock()
+
+	room.Logf("Tournament %s match %s: %s vs %s", t.ID, m.id, m.slots[0].account, m.slots[1].account)
+	for _, slot := range m.slots {
+		// One connection per account plays, the first one
+		if sessions := gs.accountConnections(slot.account); len(sessions) > 0 {
+			if err := room.Join(sessions[0]); err != nil {
+				room.Logf("Player %s couldn't join: %v", sessions[0].ID, err)
+			}
+		}
+	}
+	Publish(gs.events, MatchStarted{Room: room, Players: room.Members()})
+	return room, nil
+}
+
+// ReportResult decides a match that is being played, e.g. when it was played outside
+// the room the tournament created
+func (t *Tournament) ReportResult(matchID, winner string) error {
+	t.mu.Lock()
+	m, ok := t.byID[matchID]
+	if !ok {
+		t.mu.Unlock()
+		return ErrBracketMatchNotFound
+	}
+	if m.status != matchPlaying {
+		t.mu.Unlock()
+		return ErrMatchNotPlaying
+	}
+	var win, lose bracketSlot
+	switch winner {
+	case m.slots[0].account:
+		win, lose = m.slots[0], m.slots[1]
+	case m.slots[1].account:
+		win, lose = m.slots[1], m.slots[0]
+	default:
+		t.mu.Unlock()
+		return ErrNotInMatch
+	}
+	m.status = matchFinished
+	m.winner = winner
+	t.advance(m, win, lose)
+	t.mu.Unlock()
+
+	t.startReady()
+	return nil
+}
+
+// Champion is the winner of the tournament, empty until it's over
+func (t *Tournament) Champion() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.champion
+}
+
+// State is the bracket as sent in TOURNAMENT_UPDATE
+func (t *Tournament) State() TournamentPayload {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state := TournamentPayload{
+		ID:           t.ID,
+		Name:         t.cfg.Name,
+		Format:       t.cfg.Format,
+		Participants: t.cfg.Participants,
+		Matches:      make([]TournamentMatchPayload, 0, len(t.matches)),
+		Champion:     t.champion,
+	}
+	for _, m := range t.matches {
+		players := make([]string, 2)
+		for i, slot := range m.slots {
+			if slot.bye {
+				players[i] = byeName
+			} else {
+				players[i] = slot.account
+			}
+		}
+		state.Matches = append(state.Matches, TournamentMatchPayload{
+			ID:      m.id,
+			Bracket: m.bracket,
+			Round:   m.round,
+			Players: players,
+			Winner:  m.winner,
+			RoomID:  m.roomID,
+			Status:  m.status,
+		})
+	}
+	return state
+}
+
+// Watch sends the player every TOURNAMENT_UPDATE from now on, starting with the current one
+func (t *Tournament) Watch(player *Player) error {
+	t.mu.Lock()
+	t.watchers[player.ID] = player
+	t.mu.Unlock()
+	return t.gs.SendTournamentUpdate(player.ID, t.State())
+}
+
+func (t *Tournament) Unwatch(playerID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.watchers, playerID)
+}
+
+// broadcast sends the bracket to the connected participants and the watchers
+func (t *Tournament) broadcast() {
+	state := t.State()
+
+	seen := make(map[*Player]bool)
+	var recipients []*Player
+	for _, account := range t.cfg.Participants {
+		for _, player := range t.gs.accountConnections(account) {
+			seen[player] = true
+			recipients = append(recipients, player)
+		}
+	}
+	t.mu.Lock()
+	for _, player := range t.watchers {
+		if !seen[player] {
+			recipients = append(recipients, player)
+		}
+	}
+	t.mu.Unlock()
+
+	res := deliverTo(t.gs, recipients, TournamentUpdate, state, "tournament")
+	if res.Failed > 0 {
+		log.Printf("Tournament %s update failed for %d players: %v", t.ID, res.Failed, res.Errors)
+	}
+}
+
+// tournamentMatchEnded advances the bracket when a match room closes with a winner
+func (gs *GameServer) tournamentMatchEnded(e MatchEnded) {
+	gs.tournaments.mu.Lock()
+	t, ok := gs.tournaments.byRoom[e.Room.ID]
+	delete(gs.tournaments.byRoom, e.Room.ID)
+	gs.tournaments.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	var matchID string
+	for _, m := range t.matches {
+		if m.roomID == e.Room.ID {
+			matchID = m.id
+		}
+	}
+	t.mu.Unlock()
+
+	winner, _ := e.Result.Results["winner"].(string)
+	if winner == "" {
+		log.Printf("Tournament %s match %s ended without a winner, report it with ReportResult", t.ID, matchID)
+		return
+	}
+	// Advancing creates rooms and sends updates, not for an event handler
+	go func() {
+		if err := t.ReportResult(matchID, winner); err != nil {
+			log.Printf("Tournament %s match %s: %v", t.ID, matchID, err)
+		}
+	}()
+}
+
+// unwatchTournaments forgets a player that left
+func (gs *GameServer) unwatchTournaments(player *Player) {
+	gs.tournaments.mu.Lock()
+	all := make([]*Tournament, 0, len(gs.tournaments.byID))
+	for _, t := range gs.tournaments.byID {
+		all = append(all, t)
+	}
+	gs.tournaments.mu.Unlock()
+
+	for _, t := range all {
+		t.Unwatch(player.ID)
+	}
+}
+
+// handleTournamentMessage routes TOURNAMENT_WATCH and TOURNAMENT_UNWATCH
+func (gs *GameServer) handleTournamentMessage(player *Player, msg StructuredMessage) error {
+	var payload TournamentWatchPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid %s: %v", msg.Type, err)
+	}
+	t, ok := gs.GetTournament(payload.TournamentID)
+	if !ok {
+		return ErrTournamentNotFound
+	}
+	if msg.Type == TournamentUnwatch {
+		t.Unwatch(player.ID)
+		return nil
+	}
+	return t.Watch(player)
+}
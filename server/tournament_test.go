@@ -0,0 +1,52 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFinishedTournamentIsForgotten(t *testing.T) {
+	gs := NewGameServer(10)
+	tour, err := gs.CreateTournament(TournamentConfig{Format: SingleElimination, Participants: []string{"alice", "bob"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	final := tour.State().Matches[0]
+	if err := tour.ReportResult(final.ID, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if tour.Champion() != "alice" {
+		t.Fatalf("champion %q, want alice", tour.Champion())
+	}
+	if _, ok := gs.GetTournament(tour.ID); !ok {
+		t.Fatal("a just finished tournament should still be found")
+	}
+
+	// An hour later the next lookup sweeps it
+	tour.mu.Lock()
+	tour.finishedAt = time.Now().Add(-finishedTournamentTTL - time.Second)
+	tour.mu.Unlock()
+	gs.tournaments.mu.Lock()
+	gs.tournaments.nextSweep = time.Time{}
+	gs.tournaments.mu.Unlock()
+	if _, ok := gs.GetTournament(tour.ID); ok {
+		t.Fatal("finished tournament still kept after its TTL")
+	}
+	if n := len(gs.tournaments.byID); n != 0 {
+		t.Fatalf("%d tournaments left", n)
+	}
+}
+
+func TestRunningTournamentIsKept(t *testing.T) {
+	gs := NewGameServer(10)
+	tour, err := gs.CreateTournament(TournamentConfig{Format: SingleElimination, Participants: []string{"alice", "bob", "carol"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs.tournaments.mu.Lock()
+	gs.tournaments.sweepLocked(time.Now().Add(24 * time.Hour))
+	gs.tournaments.mu.Unlock()
+	if _, ok := gs.GetTournament(tour.ID); !ok {
+		t.Fatal("running tournament was swept")
+	}
+}
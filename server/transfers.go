@@ -0,0 +1,335 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Payloads too large for one frame, map data, replays or asset manifests, go in chunks:
+// TRANSFER_START announces the message type, the size and the chunk size, TRANSFER_CHUNK
+// carries the pieces in any order and either side gives up with TRANSFER_ABORT. Once all
+// chunks are in, the payload is handled as a message of the announced type, through the
+// schema check and the handlers like any other. The seq and req of the TRANSFER_START
+// carry over to it.
+//
+// The server sends with SendChunked, at chat priority so gameplay overtakes the chunks.
+// Every player may have MaxConcurrent transfers going each way, further SendChunked calls
+// wait for a slot and further TRANSFER_STARTs are refused with ERROR RATE_LIMITED.
+// TransferProgress and TransferFinished are published for both directions.
+
+var (
+	ErrTransfersNotEnabled = errors.New("chunked transfers are not enabled")
+	ErrTooManyTransfers    = errors.New("too many transfers in progress")
+	ErrTransferAborted     = errors.New("transfer aborted")
+)
+
+const (
+	defaultChunkSize       = 16 << 10
+	defaultMaxTransferSize = 4 << 20
+	defaultMaxTransfers    = 2
+	defaultTransferTimeout = 30 * time.Second
+	// minChunkSize keeps clients from announcing more chunks than bytes are worth tracking
+	minChunkSize = 1 << 10
+)
+
+// ChunkConfig configures chunked transfers, zero values get the defaults
+type ChunkConfig struct {
+	// ChunkSize is the size of the chunks the server sends, 16 KiB when 0
+	ChunkSize int
+	// MaxSize caps the payload of a transfer from a client, 4 MiB when 0
+	MaxSize int
+	// MaxConcurrent is how many transfers a player may have going each way, 2 when 0
+	MaxConcurrent int
+	// Timeout is how long a transfer from a client may take, 30 seconds when 0
+	Timeout time.Duration
+}
+
+// Transfer is a payload on its way to a player in chunks, see SendChunked
+type Transfer struct {
+	ID   string
+	Type MessageType
+	Size int
+
+	sent    atomic.Int64
+	aborted atomic.Pointer[string]
+	done    chan struct{}
+	err     error
+}
+
+// Sent is how many bytes of the payload went out so far
+func (t *Transfer) Sent() int {
+	return int(t.sent.Load())
+}
+
+// Done is closed once every chunk went out or the transfer failed
+func (t *Transfer) Done() <-chan struct{} {
+	return t.done
+}
+
+// Err is why the transfer failed, only meaningful after Done is closed
+func (t *Transfer) Err() error {
+	select {
+	case <-t.done:
+		return t.err
+	default:
+		return nil
+	}
+}
+
+// Wait blocks until the transfer is done or ctx is
+func (t *Transfer) Wait(ctx context.Context) error {
+	select {
+	case <-t.done:
+		return t.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// inboundTransfer is a payload a player is sending, assembled in place
+type inboundTransfer struct {
+	start    TransferStartPayload
+	envelope StructuredMessage
+	data     []byte
+	got      []bool
+	received int
+	stop     func() bool
+}
+
+// transferState is a player's transfers in progress
+type transferState struct {
+	mu       sync.Mutex
+	inbound  map[string]*inboundTransfer
+	outbound map[string]*Transfer
+	// slots limits the transfers to the player, made on the first one
+	slotsOnce sync.Once
+	slots     chan struct{}
+}
+
+// SendChunked sends a payload to a player in chunks, the client handles it as a message
+// of msgType once it has all of them. It returns once the transfer is queued, it waits for
+// a slot when the player already has MaxConcurrent transfers going.
+func (gs *GameServer) SendChunked(playerID string, msgType MessageType, payload interface{}) (*Transfer, error) {
+	cfg := gs.chunks
+	if cfg == nil {
+		return nil, ErrTransfersNotEnabled
+	}
+	player, ok := gs.GetPlayer(playerID)
+	if !ok {
+		return nil, ErrPlayerNotConnected
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	t := &Transfer{ID: generateUniqueID(), Type: msgType, Size: len(data), done: make(chan struct{})}
+	ts := &player.transfers
+	ts.mu.Lock()
+	if ts.outbound == nil {
+		ts.outbound = make(map[string]*Transfer)
+	}
+	ts.outbound[t.ID] = t
+	ts.mu.Unlock()
+
+	go func() {
+		t.err = gs.sendChunks(player, t, data)
+		ts.mu.Lock()
+		delete(ts.outbound, t.ID)
+		ts.mu.Unlock()
+		close(t.done)
+		Publish(gs.events, TransferFinished{Player: player, ID: t.ID, Type: msgType, Size: t.Size, Err: t.err})
+	}()
+	return t, nil
+}
+
+// sendChunks writes the transfer once the player has a slot for it
+func (gs *GameServer) sendChunks(player *Player, t *Transfer, data []byte) error {
+	slots := player.transfers.slotsFor(gs.chunks.MaxConcurrent)
+	select {
+	case slots <- struct{}{}:
+		defer func() { <-slots }()
+	case <-player.ctx.Done():
+		return ErrPlayerNotConnected
+	}
+
+	sum := sha256.Sum256(data)
+	start := TransferStartPayload{TransferID: t.ID, Type: t.Type, Size: t.Size, ChunkSize: gs.chunks.ChunkSize, Checksum: hex.EncodeToString(sum[:])}
+	if err := gs.SendTransferStart(player.ID, start); err != nil {
+		return err
+	}
+
+	for index := 0; index*start.ChunkSize < len(data); index++ {
+		if reason := t.aborted.Load(); reason != nil {
+			return fmt.Errorf("%w by the client: %s", ErrTransferAborted, *reason)
+		}
+		if player.ctx.Err() != nil {
+			return ErrPlayerNotConnected
+		}
+		chunk := data[index*start.ChunkSize : min((index+1)*start.ChunkSize, len(data))]
+		if err := gs.SendTransferChunk(player.ID, TransferChunkPayload{TransferID: t.ID, Index: index, Data: chunk}); err != nil {
+			return err
+		}
+		sent := t.sent.Add(int64(len(chunk)))
+		Publish(gs.events, TransferProgress{Player: player, ID: t.ID, Type: t.Type, Done: int(sent), Size: t.Size})
+	}
+	return nil
+}
+
+func (ts *transferState) slotsFor(n int) chan struct{} {
+	ts.slotsOnce.Do(func() { ts.slots = make(chan struct{}, n) })
+	return ts.slots
+}
+
+// handleTransferMessage runs the transfers from a player
+func (gs *GameServer) handleTransferMessage(player *Player, msg StructuredMessage) error {
+	if gs.chunks == nil {
+		return ErrTransfersNotEnabled
+	}
+
+	switch msg.Type {
+	case TransferStart:
+		var start TransferStartPayload
+		if err := json.Unmarshal(msg.Payload, &start); err != nil {
+			return Reject(ErrorInvalidPayload, err)
+		}
+		return gs.startInbound(player, msg, start)
+
+	case TransferChunk:
+		var chunk TransferChunkPayload
+		if err := json.Unmarshal(msg.Payload, &chunk); err != nil {
+			return Reject(ErrorInvalidPayload, err)
+		}
+		return gs.receiveChunk(player, chunk)
+
+	case TransferAbort:
+		var abort TransferAbortPayload
+		if err := json.Unmarshal(msg.Payload, &abort); err != nil {
+			return Reject(ErrorInvalidPayload, err)
+		}
+		ts := &player.transfers
+		ts.mu.Lock()
+		in := ts.inbound[abort.TransferID]
+		delete(ts.inbound, abort.TransferID)
+		if out := ts.outbound[abort.TransferID]; out != nil {
+			out.aborted.Store(&abort.Reason)
+		}
+		ts.mu.Unlock()
+		if in != nil {
+			in.stop()
+			gs.finishInbound(player, in, fmt.Errorf("%w by the client: %s", ErrTransferAborted, abort.Reason))
+		}
+	}
+	return nil
+}
+
+// startInbound sets up a transfer from a player after checking it against the limits
+func (gs *GameServer) startInbound(player *Player, msg StructuredMessage, start TransferStartPayload) error {
+	cfg := gs.chunks
+	schema, ok := messageSchemas[start.Type]
+	switch {
+	case !ok || !schema.FromClient():
+		return Reject(ErrorInvalidPayload, fmt.Errorf("transfer of unknown type %s", start.Type))
+	case start.Type == TransferStart || start.Type == TransferChunk || start.Type == TransferAbort:
+		return Reject(ErrorInvalidPayload, fmt.Errorf("transfers can't carry %s", start.Type))
+	case start.Size > cfg.MaxSize:
+		return Reject(ErrorInvalidPayload, fmt.Errorf("transfer of %d bytes is over the limit of %d", start.Size, cfg.MaxSize))
+	case start.ChunkSize < min(start.Size, minChunkSize):
+		return Reject(ErrorInvalidPayload, fmt.Errorf("invalid chunk size %d for %d bytes", start.ChunkSize, start.Size))
+	}
+
+	ts := &player.transfers
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.inbound == nil {
+		ts.inbound = make(map[string]*inboundTransfer)
+	}
+	if _, exists := ts.inbound[start.TransferID]; exists {
+		return Reject(ErrorInvalidPayload, fmt.Errorf("transfer %s is already in progress", start.TransferID))
+	}
+	if len(ts.inbound) >= cfg.MaxConcurrent {
+		return ErrTooManyTransfers
+	}
+
+	chunks := 1 + (start.Size-1)/start.ChunkSize
+	in := &inboundTransfer{
+		start:    start,
+		envelope: StructuredMessage{Type: start.Type, PlayerID: player.ID, Seq: msg.Seq, Req: msg.Req},
+		data:     make([]byte, start.Size),
+		got:      make([]bool, chunks),
+	}
+	in.stop = player.AfterFunc(cfg.Timeout, func() {
+		ts.mu.Lock()
+		timedOut := ts.inbound[start.TransferID] == in
+		if timedOut {
+			delete(ts.inbound, start.TransferID)
+		}
+		ts.mu.Unlock()
+		if timedOut {
+			gs.SendTransferAbort(player.ID, TransferAbortPayload{TransferID: start.TransferID, Reason: "timed out"})
+			gs.finishInbound(player, in, fmt.Errorf("%w: timed out after %v", ErrTransferAborted, cfg.Timeout))
+		}
+	})
+	ts.inbound[start.TransferID] = in
+	return nil
+}
+
+// receiveChunk puts a chunk in place, handling the payload once it's complete
+func (gs *GameServer) receiveChunk(player *Player, chunk TransferChunkPayload) error {
+	ts := &player.transfers
+	ts.mu.Lock()
+	in := ts.inbound[chunk.TransferID]
+	if in == nil {
+		ts.mu.Unlock()
+		return Reject(ErrorInvalidPayload, fmt.Errorf("unknown transfer %s", chunk.TransferID))
+	}
+	if chunk.Index >= len(in.got) || len(chunk.Data) != min(in.start.ChunkSize, in.start.Size-chunk.Index*in.start.ChunkSize) {
+		ts.mu.Unlock()
+		return Reject(ErrorInvalidPayload, fmt.Errorf("chunk %d of transfer %s doesn't fit", chunk.Index, chunk.TransferID))
+	}
+	offset := chunk.Index * in.start.ChunkSize
+	// A resent chunk changes nothing
+	if !in.got[chunk.Index] {
+		in.got[chunk.Index] = true
+		in.received += copy(in.data[offset:], chunk.Data)
+	}
+	received, complete := in.received, in.received == in.start.Size
+	if complete {
+		delete(ts.inbound, chunk.TransferID)
+	}
+	ts.mu.Unlock()
+
+	Publish(gs.events, TransferProgress{Player: player, ID: in.start.TransferID, Type: in.start.Type, Inbound: true, Done: received, Size: in.start.Size})
+	if !complete {
+		return nil
+	}
+	in.stop()
+
+	if in.start.Checksum != "" {
+		if sum := sha256.Sum256(in.data); hex.EncodeToString(sum[:]) != in.start.Checksum {
+			err := fmt.Errorf("checksum of transfer %s doesn't match", in.start.TransferID)
+			gs.finishInbound(player, in, err)
+			return Reject(ErrorInvalidPayload, err)
+		}
+	}
+	gs.finishInbound(player, in, nil)
+
+	msg := in.envelope
+	msg.Payload = in.data
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return Reject(ErrorInvalidPayload, fmt.Errorf("transfer %s is not JSON: %v", in.start.TransferID, err))
+	}
+	return gs.processMessage(player, data)
+}
+
+func (gs *GameServer) finishInbound(player *Player, in *inboundTransfer, err error) {
+	Publish(gs.events, TransferFinished{Player: player, ID: in.start.TransferID, Type: in.start.Type, Inbound: true, Size: in.start.Size, Err: err})
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/quic-go/quic-go"
+)
+
+// Transport is what a player is connected with: a websocket, a TCP socket, a WebTransport
+// session, a gRPC stream, a bot's inbox or an in-memory pipe. Frames keep the websocket message
+// types (TextMessage, BinaryMessage) so registration, routing and broadcasts don't care where
+// a frame came from. Connections made outside the built-in listeners come in through ServeTransport.
+type Transport interface {
+	// ReadFrame blocks until the next frame or the deadline, a zero deadline waits forever.
+	// It's only called from the player's read loop.
+	ReadFrame(deadline time.Time) (messageType int, data []byte, err error)
+	// WriteFrame is never called concurrently, a zero deadline waits forever
+	WriteFrame(messageType int, data []byte, deadline time.Time) error
+	// Close ends the connection, telling the client why when the transport can. A normal
+	// closure without reason is a plain close. Only the first call counts, it's safe next to
+	// a blocked writer and unblocks it.
+	Close(code int, reason string) error
+	RemoteAddr() net.Addr
+}
+
+// Stream transports (WebTransport, TCP) frame messages as
+// [1 byte websocket message type][4 byte big endian length][data]
+
+// maxStreamFrame caps a single stream frame, a bigger length prefix ends the connection
+const maxStreamFrame = 1 << 20
+
+var errFrameTooLarge = errors.New("frame too large")
+
+// readStreamFrame reads one length-prefixed frame
+func readStreamFrame(r io.Reader) (int, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(header[1:])
+	if n > maxStreamFrame {
+		return 0, nil, errFrameTooLarge
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, err
+	}
+	return int(header[0]), data, nil
+}
+
+// encodeStreamFrame prefixes data with its type and length, in one buffer so it goes out in one write
+func encodeStreamFrame(messageType int, data []byte) ([]byte, error) {
+	if len(data) > maxStreamFrame {
+		return nil, errFrameTooLarge
+	}
+	buf := make([]byte, 5+len(data))
+	buf[0] = byte(messageType)
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(data)))
+	copy(buf[5:], data)
+	return buf, nil
+}
+
+type inboundFrame struct {
+	messageType int
+	data        []byte
+}
+
+// frameQueue gives ReadFrame deadlines to transports that are read by their own goroutines
+type frameQueue struct {
+	frames chan inboundFrame
+	// closed by finish once the main reader stops, err says why
+	done chan struct{}
+	err  error
+}
+
+func newFrameQueue() *frameQueue {
+	return &frameQueue{frames: make(chan inboundFrame, 16), done: make(chan struct{})}
+}
+
+// push hands a frame to ReadFrame, false once stop is closed
+func (q *frameQueue) push(messageType int, data []byte, stop <-chan struct{}) bool {
+	select {
+	case q.frames <- inboundFrame{messageType, data}:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// finish is called once by the main reader when it stops
+func (q *frameQueue) finish(err error) {
+	q.err = err
+	close(q.done)
+}
+
+func (q *frameQueue) read(deadline time.Time) (int, []byte, error) {
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case f := <-q.frames:
+		return f.messageType, f.data, nil
+	case <-q.done:
+		// Drain what was delivered before the reader stopped
+		select {
+		case f := <-q.frames:
+			return f.messageType, f.data, nil
+		default:
+		}
+		return 0, nil, q.err
+	case <-timeout:
+		return 0, nil, os.ErrDeadlineExceeded
+	}
+}
+
+// datagramTransport can also send unreliable datagrams, see SendUnreliable
+type datagramTransport interface {
+	Transport
+	SendDatagram(messageType int, data []byte) error
+}
+
+// Transport returns what the player is connected with
+func (p *Player) Transport() Transport {
+	return p.transport
+}
+
+// RemoteAddr is the address of the player's connection
+func (p *Player) RemoteAddr() net.Addr {
+	return p.transport.RemoteAddr()
+}
+
+// sendClose tells the client why it is being disconnected and closes the connection,
+// without waiting on other writers
+func (p *Player) sendClose(reason DisconnectReason) {
+	p.setDisconnectReason(reason)
+	p.cancel()
+	p.transport.Close(reason.Code, reason.String())
+}
+
+// addrIP is the host part of a transport's address, the whole address when it has no port
+func addrIP(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// ServeTransport runs a connection made outside the built-in listeners, e.g. a Pipe in tests
+// or a custom protocol. r stands in for the upgrade request so account resolvers, slot
+// classifiers and device names work, nil is an anonymous request for /ws. It returns
+// ErrTooManyConnections, ErrBanned or a login error without closing t, otherwise the player is served in the
+// background like any other connection.
+func (gs *GameServer) ServeTransport(t Transport, r *http.Request) error {
+	if r == nil {
+		r = &http.Request{Method: http.MethodGet, URL: &url.URL{Path: "/ws"}, Header: http.Header{}}
+	}
+	if err := gs.checkLogin(r); err != nil {
+		return err
+	}
+	ip := addrIP(t.RemoteAddr())
+	if !gs.ipLimit.acquire(ip) {
+		log.Printf("Rejecting connection from %s: too many connections from this IP", ip)
+		return ErrTooManyConnections
+	}
+
+	accountID, _ := gs.resolveAccount(r)
+	if err := gs.checkBans(accountID, ip); err != nil {
+		log.Printf("Rejecting connection: %v", err)
+		gs.ipLimit.release(ip)
+		return err
+	}
+
+	gs.admit(gs.newPlayer(t, gs.slotClassifier(r)), r, ip)
+	return nil
+}
+
+// SendUnreliable sends a message that may be lost or reordered, e.g. positions that the next
+// update replaces anyway. It goes over the player's UDP channel once the player has used it,
+// else as a WebTransport datagram. Everyone else gets a regular message, as does anything too
+// big for a datagram. Unreliable messages aren't numbered with sseq.
+func (gs *GameServer) SendUnreliable(playerID string, msgType MessageType, payload interface{}) error {
+	player, ok := gs.GetPlayer(playerID)
+	if !ok {
+		return fmt.Errorf("player not found")
+	}
+
+	data, err := gs.encodeFor(player, msgType, payload)
+	if err != nil {
+		return err
+	}
+
+	if link := player.udp.Load(); link != nil {
+		if ch := gs.udpChan(); ch != nil {
+			sent, err := ch.send(link, websocket.TextMessage, data)
+			if sent {
+				player.metrics.recordOut(player.metricShard, len(data), err)
+				return err
+			}
+		}
+	}
+
+	if dt, ok := player.transport.(datagramTransport); ok {
+		err := dt.SendDatagram(websocket.TextMessage, data)
+		if err == nil {
+			player.metrics.recordOut(player.metricShard, len(data), nil)
+			return nil
+		}
+		var tooLarge *quic.DatagramTooLargeError
+		if !errors.As(err, &tooLarge) {
+			return err
+		}
+	}
+	return player.writeMessage(msgType, data)
+}
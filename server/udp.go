@@ -0,0 +1,169 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// The UDP channel runs next to a player's main connection for high-frequency, loss-tolerant
+// traffic like movement. On connect the player gets a UDP_SESSION message with a token, every
+// datagram it sends is [16 byte token][1 byte websocket message type][data] and goes through the
+// same pipeline as frames from the main connection. The address of the last valid datagram is
+// where the server sends [1 byte message type][data] datagrams from SendUnreliable, so NAT
+// rebinding just works. Reliable events keep going over the main connection.
+
+const (
+	udpTokenSize = 16
+	// maxUDPPayload keeps datagrams under the usual path MTU, bigger messages go reliable
+	maxUDPPayload = 1200
+)
+
+type udpToken [udpTokenSize]byte
+
+// udpChannel is the socket and the token table of StartUDP
+type udpChannel struct {
+	conn *net.UDPConn
+
+	mu      sync.RWMutex
+	players map[udpToken]*Player
+}
+
+// udpLink is a player's side of the UDP channel
+type udpLink struct {
+	token udpToken
+	// addr is where the last valid datagram came from, nil until the first one
+	addr atomic.Pointer[net.UDPAddr]
+}
+
+// register hands the player a fresh token
+func (ch *udpChannel) register(player *Player) (*udpLink, error) {
+	link := &udpLink{}
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	for {
+		if _, err := rand.Read(link.token[:]); err != nil {
+			return nil, err
+		}
+		if _, taken := ch.players[link.token]; !taken {
+			break
+		}
+	}
+	player.udp.Store(link)
+	ch.players[link.token] = player
+	return link, nil
+}
+
+func (ch *udpChannel) unregister(link *udpLink) {
+	ch.mu.Lock()
+	delete(ch.players, link.token)
+	ch.mu.Unlock()
+}
+
+func (ch *udpChannel) send(link *udpLink, messageType int, data []byte) (bool, error) {
+	addr := link.addr.Load()
+	if addr == nil || len(data) > maxUDPPayload {
+		return false, nil
+	}
+	buf := make([]byte, 1+len(data))
+	buf[0] = byte(messageType)
+	copy(buf[1:], data)
+	_, err := ch.conn.WriteToUDP(buf, addr)
+	return true, err
+}
+
+// StartUDP opens the UDP channel on addr, players connecting from now on get a token
+// It returns once the socket is closed by Shutdown.
+func (gs *GameServer) StartUDP(addr string) error {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return err
+	}
+	ch := &udpChannel{conn: conn, players: make(map[udpToken]*Player)}
+	gs.serversMu.Lock()
+	gs.udp = ch
+	gs.serversMu.Unlock()
+
+	log.Printf("UDP channel starting on %s", conn.LocalAddr())
+	return gs.readUDP(ch)
+}
+
+func (gs *GameServer) readUDP(ch *udpChannel) error {
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := ch.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		if n <= udpTokenSize {
+			continue
+		}
+
+		var token udpToken
+		copy(token[:], buf[:udpTokenSize])
+		ch.mu.RLock()
+		player := ch.players[token]
+		ch.mu.RUnlock()
+		messageType := int(buf[udpTokenSize])
+		if player == nil || (messageType != websocket.TextMessage && messageType != websocket.BinaryMessage) {
+			continue
+		}
+
+		if link := player.udp.Load(); link != nil {
+			if prev := link.addr.Load(); prev == nil || prev.String() != addr.String() {
+				link.addr.Store(addr)
+			}
+		}
+		data := make([]byte, n-udpTokenSize-1)
+		copy(data, buf[udpTokenSize+1:n])
+		gs.handleInbound(player, messageType, data)
+	}
+}
+
+// udpChan returns the channel StartUDP opened, nil if it didn't
+func (gs *GameServer) udpChan() *udpChannel {
+	gs.serversMu.Lock()
+	defer gs.serversMu.Unlock()
+	return gs.udp
+}
+
+// offerUDP gives a freshly connected player its token, bots have no use for one
+func (gs *GameServer) offerUDP(player *Player) {
+	ch := gs.udpChan()
+	if ch == nil || player.bot != nil {
+		return
+	}
+	link, err := ch.register(player)
+	if err != nil {
+		log.Printf("Error creating UDP token for player %s: %v", player.ID, err)
+		return
+	}
+
+	port := ch.conn.LocalAddr().(*net.UDPAddr).Port
+	payload := UDPSessionPayload{Token: hex.EncodeToString(link.token[:]), Port: port}
+	if err := gs.SendUDPSession(player.ID, payload); err != nil {
+		log.Printf("Error sending UDP session to player %s: %v", player.ID, err)
+	}
+}
+
+// dropUDP forgets the player's token once it leaves
+func (gs *GameServer) dropUDP(player *Player) {
+	if link := player.udp.Load(); link != nil {
+		if ch := gs.udpChan(); ch != nil {
+			ch.unregister(link)
+		}
+	}
+}
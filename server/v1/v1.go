@@ -0,0 +1,416 @@
+// Package v1 is the stable API of the game server.
+//
+// Compatibility promise: nothing exported from this package is removed or
+// changes signature within v1. When the implementation in the server package
+// renames or reshapes something, the old name stays as a shim with a
+// "Deprecated:" comment pointing at the replacement, so existing call sites keep
+// compiling and linters flag them for migration. Functions and options keep their
+// shims here, methods keep theirs on the server type since the types are aliases.
+// Every option of the server package has its wrapper below. Breaking changes go
+// into a new v2 package next to this one, and both live side by side for a while.
+//
+// The types are aliases of the server package types, so values can be passed
+// freely between code written against v1 and code using the server package directly.
+// Methods added to those types later are experimental until they show up in the
+// list of promoted APIs in this file.
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/iknizzz1807/socket-server-template/database"
+	"github.com/iknizzz1807/socket-server-template/logic"
+	"github.com/iknizzz1807/socket-server-template/server"
+)
+
+// Core types
+type (
+	GameServer          = server.GameServer
+	Player              = server.Player
+	Room                = server.Room
+	Option              = server.Option
+	MessageType         = server.MessageType
+	StructuredMessage   = server.StructuredMessage
+	SlotClass           = server.SlotClass
+	SlotClassifier      = server.SlotClassifier
+	RoomQuota           = server.RoomQuota
+	QueueStatusPayload  = server.QueueStatusPayload
+	EntityLeavePayload  = server.EntityLeavePayload
+	RoomClosedPayload   = server.RoomClosedPayload
+	AutoTLSConfig       = server.AutoTLSConfig
+	Config              = server.Config
+	CapabilitiesPayload = server.CapabilitiesPayload
+)
+
+// Types the options take
+type (
+	AccountResolver      = server.AccountResolver
+	OnboardingConfig     = server.OnboardingConfig
+	ModeProfile          = server.ModeProfile
+	MoveAction           = server.MoveAction
+	MoveViolationHandler = server.MoveViolationHandler
+	SurveyConfig         = server.SurveyConfig
+	SlowConsumerAction   = server.SlowConsumerAction
+	SlowConsumerPolicy   = server.SlowConsumerPolicy
+	ReliableConfig       = server.ReliableConfig
+	IdleConfig           = server.IdleConfig
+	JoinConfig           = server.JoinConfig
+	SessionPolicy        = server.SessionPolicy
+	HealthCheck          = server.HealthCheck
+	ScriptConfig         = server.ScriptConfig
+	PluginLimits         = server.PluginLimits
+	Priority             = server.Priority
+	LoginConfig          = server.LoginConfig
+	RatingSystem         = server.RatingSystem
+	MatchmakingConfig    = server.MatchmakingConfig
+	RoomManagerConfig    = server.RoomManagerConfig
+	WebhookConfig        = server.WebhookConfig
+	DiscordConfig        = server.DiscordConfig
+	RTTConfig            = server.RTTConfig
+	NetSimConfig         = server.NetSimConfig
+	PayloadValidator     = server.PayloadValidator
+	ChunkConfig          = server.ChunkConfig
+	SignalingConfig      = server.SignalingConfig
+	VoiceConfig          = server.VoiceConfig
+	RegistrationConfig   = server.RegistrationConfig
+)
+
+// Message types
+const (
+	PlayerMove    = server.PlayerMove
+	GameStateSync = server.GameStateSync
+	PlayerJoin    = server.PlayerJoin
+	PlayerLeave   = server.PlayerLeave
+	ChatMessage   = server.ChatMessage
+	QueueUpdate   = server.QueueUpdate
+	QueueAdmitted = server.QueueAdmitted
+	EntityLeave   = server.EntityLeave
+	RoomClosed    = server.RoomClosed
+	Capabilities  = server.Capabilities
+)
+
+// Slot classes
+const (
+	SlotRegular   = server.SlotRegular
+	SlotPremium   = server.SlotPremium
+	SlotModerator = server.SlotModerator
+	SlotAdmin     = server.SlotAdmin
+)
+
+// Values of the option types
+const (
+	MoveAllow          = server.MoveAllow
+	MoveReject         = server.MoveReject
+	MoveRubberBand     = server.MoveRubberBand
+	MoveKick           = server.MoveKick
+	SlowDisconnect     = server.SlowDisconnect
+	SlowDegrade        = server.SlowDegrade
+	SessionsMultiple   = server.SessionsMultiple
+	SessionsRejectNew  = server.SessionsRejectNew
+	SessionsReplaceOld = server.SessionsReplaceOld
+	PriorityChat       = server.PriorityChat
+	PriorityGameplay   = server.PriorityGameplay
+	PriorityControl    = server.PriorityControl
+)
+
+// Errors
+var (
+	ErrServerFull    = server.ErrServerFull
+	ErrRoomExists    = server.ErrRoomExists
+	ErrRoomClosed    = server.ErrRoomClosed
+	ErrQuotaExceeded = server.ErrQuotaExceeded
+)
+
+func NewGameServer(maxPlayers int, opts ...Option) *GameServer {
+	return server.NewGameServer(maxPlayers, opts...)
+}
+
+// Configuration
+
+func DefaultConfig() Config {
+	return server.DefaultConfig()
+}
+
+func LoadConfig(path string) (Config, error) {
+	return server.LoadConfig(path)
+}
+
+func NewGameServerFromConfig(cfg Config, opts ...Option) *GameServer {
+	return server.NewGameServerFromConfig(cfg, opts...)
+}
+
+// Options
+
+func WithWaitingQueue(maxSize int) Option {
+	return server.WithWaitingQueue(maxSize)
+}
+
+func WithInterestCellSize(cellSize float64) Option {
+	return server.WithInterestCellSize(cellSize)
+}
+
+func WithReservedSlots(reserved map[SlotClass]int) Option {
+	return server.WithReservedSlots(reserved)
+}
+
+func WithSlotClassifier(classifier func(r *http.Request) SlotClass) Option {
+	return server.WithSlotClassifier(classifier)
+}
+
+func WithLagCompensation(historySize int, maxRewind time.Duration) Option {
+	return server.WithLagCompensation(historySize, maxRewind)
+}
+
+func WithResultStore(store database.ResultStore) Option {
+	return server.WithResultStore(store)
+}
+
+func WithAckedMessageTypes(types ...MessageType) Option {
+	return server.WithAckedMessageTypes(types...)
+}
+
+func WithRoomQuota(q RoomQuota) Option {
+	return server.WithRoomQuota(q)
+}
+
+func WithSpectators(maxSpectators int) Option {
+	return server.WithSpectators(maxSpectators)
+}
+
+func WithMaxPartySize(size int) Option {
+	return server.WithMaxPartySize(size)
+}
+
+func WithFriendStore(store database.FriendStore) Option {
+	return server.WithFriendStore(store)
+}
+
+func WithAccountResolver(resolver AccountResolver) Option {
+	return server.WithAccountResolver(resolver)
+}
+
+func WithBlockStore(store database.BlockStore) Option {
+	return server.WithBlockStore(store)
+}
+
+func WithOfflineMessages(maxPerAccount int) Option {
+	return server.WithOfflineMessages(maxPerAccount)
+}
+
+func WithCompression(level, threshold int) Option {
+	return server.WithCompression(level, threshold)
+}
+
+func WithWriteTimeout(timeout time.Duration) Option {
+	return server.WithWriteTimeout(timeout)
+}
+
+func WithReadTimeout(timeout time.Duration) Option {
+	return server.WithReadTimeout(timeout)
+}
+
+func WithTickRate(ticksPerSecond int) Option {
+	return server.WithTickRate(ticksPerSecond)
+}
+
+func WithAllowedOrigins(patterns ...string) Option {
+	return server.WithAllowedOrigins(patterns...)
+}
+
+func WithDevOrigins() Option {
+	return server.WithDevOrigins()
+}
+
+func WithActivityStore(store database.ActivityStore) Option {
+	return server.WithActivityStore(store)
+}
+
+func WithNodeID(id string) Option {
+	return server.WithNodeID(id)
+}
+
+func WithRoomLogExport() Option {
+	return server.WithRoomLogExport()
+}
+
+func WithOnboarding(store database.OnboardingStore, cfg OnboardingConfig) Option {
+	return server.WithOnboarding(store, cfg)
+}
+
+func WithMaxConnectionsPerIP(n int) Option {
+	return server.WithMaxConnectionsPerIP(n)
+}
+
+func WithClientIPResolver(resolver func(r *http.Request) string) Option {
+	return server.WithClientIPResolver(resolver)
+}
+
+func WithModes(modes map[string]ModeProfile) Option {
+	return server.WithModes(modes)
+}
+
+func WithActionMeter(name string, rule logic.MeterRule) Option {
+	return server.WithActionMeter(name, rule)
+}
+
+func WithActionCost(msgType MessageType, meter string, cost float64) Option {
+	return server.WithActionCost(msgType, meter, cost)
+}
+
+func WithBanStore(store database.BanStore) Option {
+	return server.WithBanStore(store)
+}
+
+func WithMovementValidation(limits logic.MoveLimits, handler MoveViolationHandler) Option {
+	return server.WithMovementValidation(limits, handler)
+}
+
+func WithBroadcastRelays(minMembers, sliceSize int) Option {
+	return server.WithBroadcastRelays(minMembers, sliceSize)
+}
+
+func WithMetricsHistory(store database.MetricsStore, interval time.Duration) Option {
+	return server.WithMetricsHistory(store, interval)
+}
+
+func WithSurveys(store database.SurveyStore, cfg SurveyConfig) Option {
+	return server.WithSurveys(store, cfg)
+}
+
+func WithSlowConsumerPolicy(policy SlowConsumerPolicy) Option {
+	return server.WithSlowConsumerPolicy(policy)
+}
+
+func WithReliableDelivery(cfg ReliableConfig) Option {
+	return server.WithReliableDelivery(cfg)
+}
+
+func WithDedupWindow(size int) Option {
+	return server.WithDedupWindow(size)
+}
+
+func WithIdlePolicy(cfg IdleConfig) Option {
+	return server.WithIdlePolicy(cfg)
+}
+
+func WithJanitor(interval time.Duration) Option {
+	return server.WithJanitor(interval)
+}
+
+func WithJoinHandshake(cfg JoinConfig) Option {
+	return server.WithJoinHandshake(cfg)
+}
+
+func WithSessionPolicy(policy SessionPolicy) Option {
+	return server.WithSessionPolicy(policy)
+}
+
+func WithHealthCheck(name string, check HealthCheck) Option {
+	return server.WithHealthCheck(name, check)
+}
+
+func WithScripts(cfg ScriptConfig) Option {
+	return server.WithScripts(cfg)
+}
+
+func WithPlugin(name, path string, limits PluginLimits) Option {
+	return server.WithPlugin(name, path, limits)
+}
+
+func WithBatching(maxBytes int) Option {
+	return server.WithBatching(maxBytes)
+}
+
+func WithBroadcastWorkers(n int) Option {
+	return server.WithBroadcastWorkers(n)
+}
+
+func WithMessagePriority(msgType MessageType, prio Priority) Option {
+	return server.WithMessagePriority(msgType, prio)
+}
+
+func WithLogin(cfg LoginConfig) Option {
+	return server.WithLogin(cfg)
+}
+
+func WithInjection(secret []byte) Option {
+	return server.WithInjection(secret)
+}
+
+func WithPartyEncryption() Option {
+	return server.WithPartyEncryption()
+}
+
+func WithSessionStore(store database.SessionStore, ttl time.Duration) Option {
+	return server.WithSessionStore(store, ttl)
+}
+
+func WithRatings(store database.RatingStore, system RatingSystem) Option {
+	return server.WithRatings(store, system)
+}
+
+func WithMatchmaking(cfg MatchmakingConfig) Option {
+	return server.WithMatchmaking(cfg)
+}
+
+func WithRegion(region, pingURL string) Option {
+	return server.WithRegion(region, pingURL)
+}
+
+func WithRoomManager(cfg RoomManagerConfig) Option {
+	return server.WithRoomManager(cfg)
+}
+
+func WithWebhooks(cfg WebhookConfig) Option {
+	return server.WithWebhooks(cfg)
+}
+
+func WithDiscord(cfg DiscordConfig) Option {
+	return server.WithDiscord(cfg)
+}
+
+func WithRTT(cfg RTTConfig) Option {
+	return server.WithRTT(cfg)
+}
+
+func WithNetworkSimulation(cfg NetSimConfig) Option {
+	return server.WithNetworkSimulation(cfg)
+}
+
+func WithPayloadValidator(msgType MessageType, validate PayloadValidator) Option {
+	return server.WithPayloadValidator(msgType, validate)
+}
+
+func WithPayloadRules(msgType MessageType, prototype interface{}) Option {
+	return server.WithPayloadRules(msgType, prototype)
+}
+
+func WithChunkedTransfers(cfg ChunkConfig) Option {
+	return server.WithChunkedTransfers(cfg)
+}
+
+func WithSignaling(cfg SignalingConfig) Option {
+	return server.WithSignaling(cfg)
+}
+
+func WithVoice(cfg VoiceConfig) Option {
+	return server.WithVoice(cfg)
+}
+
+func WithRegistration(cfg RegistrationConfig) Option {
+	return server.WithRegistration(cfg)
+}
+
+// Promoted APIs: the GameServer, Player and Room methods below are covered by the v1 promise.
+//
+//	GameServer: RegisterPlayer, RegisterPlayerWithClass, UnregisterPlayer, BroadcastMessage,
+//	            SendStructuredMessage, HandlePlayerMessages, StartServer,
+//	            SetInterest, ClearInterest, BroadcastEntityUpdate, RemoveEntity,
+//	            RecordTick, RewindTo, ResolveAt, AckInput, CreateRoom, GetRoom,
+//	            SetAllowedOrigins, StartServerTLS, StartServerAutoTLS, Start, TickInterval,
+//	            Capabilities, ApplyConfig, ReloadConfig, ReloadOnSignal, SetMaxPlayers,
+//	            Shutdown
+//	Player:     LastInputSeq
+//	Room:       Join, Leave, Members, Broadcast, SetResult, AfterFunc, StartTicker, Close,
+//	            SetQuota, AddEntity, RemoveEntity, Store, Load, Delete, RewindTo, ResolveAt
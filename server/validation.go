@@ -0,0 +1,320 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Payload fields can carry validation rules in a validate tag, set with "validate" in
+// messages.json for the generated payloads:
+//
+//	RoomID string `json:"room_id" validate:"required,max=128"`
+//
+//	required    not the zero value, for strings, slices and maps not empty
+//	min=N max=N numbers by value, strings by characters, slices and maps by length
+//	len=N       strings by characters, slices and maps by length
+//	oneof=a b c one of the values, for strings and numbers
+//
+// Rules other than required don't apply to zero values, so optional fields can be left out.
+// Nested structs, and structs in slices, are checked too. Inbound payloads that break a
+// rule are answered with SCHEMA_ERROR naming the field and the rule, and never reach the
+// handlers. Games add rules for their own payloads with WithPayloadRules, or check anything
+// else with WithPayloadValidator.
+
+// ValidationError is a payload field that broke one of its rules
+type ValidationError struct {
+	// Field is the path of the field in the payload, like "members[2].name"
+	Field string
+	// Rule is the rule it broke, like "max=128"
+	Rule   string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Reason
+	}
+	return e.Field + ": " + e.Reason
+}
+
+// PayloadValidator checks the raw payload of an inbound message, returning a
+// *ValidationError lets SCHEMA_ERROR name the field
+type PayloadValidator func(payload json.RawMessage) error
+
+// fieldRules are the parsed rules of one struct field
+type fieldRules struct {
+	index    int
+	name     string
+	required bool
+	min, max *float64
+	length   *int
+	oneof    []string
+	// rules are the rules as written, for errors
+	rules map[string]string
+}
+
+var structRules sync.Map // reflect.Type -> []fieldRules
+
+// ValidatePayload checks the validate rules of a payload struct, v may be a pointer to it
+func ValidatePayload(v interface{}) error {
+	return validateValue(reflect.ValueOf(v), "")
+}
+
+// mustParseRules parses the rules of a struct type and the types it contains, panicking on
+// a bad rule like options do
+func mustParseRules(t reflect.Type) {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	if _, seen := structRules.Load(t); seen {
+		return
+	}
+	if _, err := rulesOf(t); err != nil {
+		panic(err.Error())
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			mustParseRules(t.Field(i).Type)
+		}
+	}
+}
+
+func validateValue(v reflect.Value, path string) error {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		rules, err := rulesOf(v.Type())
+		if err != nil {
+			return err
+		}
+		for _, r := range rules {
+			fieldPath := r.name
+			if path != "" {
+				fieldPath = path + "." + r.name
+			}
+			if err := r.check(v.Field(r.index), fieldPath); err != nil {
+				return err
+			}
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			if err := validateValue(v.Field(i), joinPath(path, jsonName(v.Type().Field(i)))); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// jsonName is the name of the field in the payload
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return f.Name
+	}
+	return name
+}
+
+// rulesOf parses the rules of a struct type once
+func rulesOf(t reflect.Type) ([]fieldRules, error) {
+	if cached, ok := structRules.Load(t); ok {
+		return cached.([]fieldRules), nil
+	}
+
+	var rules []fieldRules
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("validate")
+		if !ok || !f.IsExported() {
+			continue
+		}
+		r, err := parseRules(f, tag)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", t.Name(), f.Name, err)
+		}
+		r.index = i
+		rules = append(rules, r)
+	}
+	structRules.Store(t, rules)
+	return rules, nil
+}
+
+func parseRules(f reflect.StructField, tag string) (fieldRules, error) {
+	r := fieldRules{name: jsonName(f), rules: make(map[string]string)}
+	kind := f.Type.Kind()
+	sized := kind == reflect.String || kind == reflect.Slice || kind == reflect.Map || kind == reflect.Array
+	numeric := isNumber(kind)
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		r.rules[name] = rule
+		switch name {
+		case "required":
+			r.required = true
+		case "min", "max":
+			if !sized && !numeric {
+				return r, fmt.Errorf("%s needs a number, string, slice or map", name)
+			}
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return r, fmt.Errorf("invalid %s: %v", rule, err)
+			}
+			if name == "min" {
+				r.min = &n
+			} else {
+				r.max = &n
+			}
+		case "len":
+			n, err := strconv.Atoi(arg)
+			if err != nil || !sized {
+				return r, fmt.Errorf("invalid %s for a %s", rule, f.Type)
+			}
+			r.length = &n
+		case "oneof":
+			if kind != reflect.String && !numeric {
+				return r, fmt.Errorf("oneof needs a string or a number")
+			}
+			r.oneof = strings.Fields(arg)
+		default:
+			return r, fmt.Errorf("unknown validation rule %q", rule)
+		}
+	}
+	return r, nil
+}
+
+func isNumber(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func (r fieldRules) check(v reflect.Value, path string) error {
+	fail := func(rule, format string, args ...interface{}) error {
+		return &ValidationError{Field: path, Rule: r.rules[rule], Reason: fmt.Sprintf(format, args...)}
+	}
+
+	if v.IsZero() {
+		if r.required {
+			return fail("required", "is required")
+		}
+		// Optional and left out, the other rules don't apply
+		return nil
+	}
+
+	var size float64
+	var what string
+	switch v.Kind() {
+	case reflect.String:
+		size, what = float64(utf8.RuneCountInString(v.String())), "characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		size, what = float64(v.Len()), "items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		size = v.Float()
+	}
+
+	switch {
+	case r.min != nil && size < *r.min:
+		if what != "" {
+			return fail("min", "needs at least %v %s", *r.min, what)
+		}
+		return fail("min", "must be at least %v", *r.min)
+	case r.max != nil && size > *r.max:
+		if what != "" {
+			return fail("max", "can have at most %v %s", *r.max, what)
+		}
+		return fail("max", "must be at most %v", *r.max)
+	case r.length != nil && int(size) != *r.length:
+		return fail("len", "must have exactly %d %s", *r.length, what)
+	}
+
+	if r.oneof != nil {
+		value := fmt.Sprint(v.Interface())
+		for _, allowed := range r.oneof {
+			if value == allowed {
+				return nil
+			}
+		}
+		return fail("oneof", "must be one of %s", strings.Join(r.oneof, ", "))
+	}
+	return nil
+}
+
+// validatorFor turns the rules of a prototype's type into a PayloadValidator
+func validatorFor(prototype interface{}) PayloadValidator {
+	t := reflect.TypeOf(prototype)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("payload rules need a struct, got %s", t))
+	}
+	mustParseRules(t)
+
+	return func(payload json.RawMessage) error {
+		v := reflect.New(t)
+		if len(payload) > 0 {
+			if err := json.Unmarshal(payload, v.Interface()); err != nil {
+				return fmt.Errorf("payload does not match %s: %v", t.Name(), err)
+			}
+		}
+		return ValidatePayload(v.Interface())
+	}
+}
+
+// violation turns a validation failure into the SchemaViolation sent back
+func (s MessageSchema) violation(msg StructuredMessage, err error) *SchemaViolation {
+	v := &SchemaViolation{Type: msg.Type, Version: msg.Version, Supported: s.Version, Reason: err.Error()}
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		v.Field, v.Rule = invalid.Field, invalid.Rule
+	}
+	return v
+}
+
+func init() {
+	// Bad rules in messages.json fail at start, not on the first message
+	for _, s := range messageSchemas {
+		if s.newPayload != nil {
+			mustParseRules(reflect.TypeOf(s.newPayload()).Elem())
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/iknizzz1807/socket-server-template/messages"
+)
+
+// Voice chat goes through the server as binary frames of opus audio (FrameVoice, see
+// messages/binary.go). The server relays every frame to the other players of the sender's
+// room, stamped with the sender's ID, and never decodes the audio. With a Range the frames
+// only reach the listeners that close to the sender, and the volume fades with the
+// distance, for proximity chat. Players without a known position hear everyone at full
+// volume.
+//
+// Every player has a send and a receive budget in bytes per second. Frames over the send
+// budget are refused with ERROR RATE_LIMITED, frames over a listener's receive budget are
+// dropped for that listener only, so a crowd of speakers can't flood anyone's connection.
+// Spectators listen but can't speak.
+
+var (
+	ErrVoiceNotEnabled = errors.New("voice is not enabled")
+	ErrVoiceBandwidth  = errors.New("voice bandwidth exceeded")
+)
+
+// Voice is the type voice frames go by in admission checks, meters and ERROR. It has no
+// JSON form, so it isn't in messages.json.
+const Voice MessageType = "VOICE"
+
+const (
+	// defaultVoiceFrameSize is the largest opus packet
+	defaultVoiceFrameSize   = 1275
+	defaultVoiceSendRate    = 8 << 10
+	defaultVoiceReceiveRate = 32 << 10
+)
+
+// VoiceConfig configures the voice relay, zero values get the defaults
+type VoiceConfig struct {
+	// Range only relays to listeners within that distance of the speaker, 0 relays to
+	// the whole room
+	Range float64
+	// MaxFrameSize caps the opus packet of a frame, 1275 bytes when 0
+	MaxFrameSize int
+	// SendRate is what a player may send, in bytes per second, 8 KiB when 0
+	SendRate int
+	// ReceiveRate is what a player may be sent, in bytes per second, 32 KiB when 0
+	ReceiveRate int
+}
+
+// voiceState is a player's voice budgets, made on the first frame either way
+type voiceState struct {
+	once    sync.Once
+	send    *tokenBucket
+	receive *tokenBucket
+}
+
+func (vs *voiceState) budgets(cfg *VoiceConfig) *voiceState {
+	vs.once.Do(func() {
+		// A second of audio can go at once, voice comes in bursts
+		vs.send = newTokenBucket(float64(cfg.SendRate), float64(cfg.SendRate))
+		vs.receive = newTokenBucket(float64(cfg.ReceiveRate), float64(cfg.ReceiveRate))
+	})
+	return vs
+}
+
+// relayVoice sends a voice frame from a player to the listeners of its room
+func (gs *GameServer) relayVoice(player *Player, message []byte) error {
+	cfg := gs.voice
+	if cfg == nil {
+		return ErrVoiceNotEnabled
+	}
+	if err := gs.admitMessage(player, Voice); err != nil {
+		return err
+	}
+	if player.IsSpectator() {
+		return Reject(ErrorUnauthorized, fmt.Errorf("spectator %s can't speak", player.ID))
+	}
+	room := player.room.Load()
+	if room == nil {
+		return ErrNotInRoom
+	}
+
+	frame, err := messages.DecodeVoice(message)
+	if err != nil {
+		return Reject(ErrorInvalidPayload, fmt.Errorf("invalid voice frame from player %s: %v", player.ID, err))
+	}
+	if len(frame.Data) == 0 || len(frame.Data) > cfg.MaxFrameSize {
+		return Reject(ErrorInvalidPayload, fmt.Errorf("voice packets must be 1 to %d bytes, got %d", cfg.MaxFrameSize, len(frame.Data)))
+	}
+	if !player.voice.budgets(cfg).send.allowN(float64(len(message))) {
+		return fmt.Errorf("dropped voice from player %s: %w", player.ID, ErrVoiceBandwidth)
+	}
+
+	frame.Sender = player.ID
+	volume := frame.Volume
+	pos, positioned := player.Position()
+	for _, listener := range room.Members() {
+		if listener == player {
+			continue
+		}
+		frame.Volume = volume
+		if cfg.Range > 0 && positioned {
+			if at, ok := listener.Position(); ok {
+				dist := at.Sub(pos).Len()
+				if dist >= cfg.Range {
+					continue
+				}
+				frame.Volume = uint8(float64(volume) * (1 - dist/cfg.Range))
+			}
+		}
+
+		data, err := messages.EncodeVoice(frame)
+		if err != nil {
+			return err
+		}
+		if !listener.voice.budgets(cfg).receive.allowN(float64(len(data))) {
+			listener.tracef("dropping voice from %s over the receive budget", player.ID)
+			continue
+		}
+		if err := listener.write(websocket.BinaryMessage, data); err != nil {
+			listener.tracef("error relaying voice from %s: %v", player.ID, err)
+		}
+	}
+	return nil
+}
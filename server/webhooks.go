@@ -0,0 +1,289 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Webhooks POST game events to external URLs:
+//
+//	{"id": "...", "event": "match_ended", "timestamp": 1700000000000, "data": {...}}
+//
+// Every webhook has its own bounded queue and worker, so a slow endpoint only delays its
+// own deliveries. When the queue is full new events are dropped and logged. Failed
+// deliveries (network errors, 429 and 5xx answers) are retried with a growing backoff.
+//
+// Deliveries of a webhook with a secret are signed: X-Webhook-Signature is the hex
+// HMAC-SHA256 of "<timestamp>\n<body>", with the unix timestamp in X-Webhook-Timestamp.
+// Receivers should refuse old timestamps and drop IDs they've already seen, a retry
+// repeats the ID. VerifyWebhook does this for Go receivers.
+
+// The events webhooks can subscribe to
+const (
+	WebhookMatchStarted = "match_started"
+	WebhookMatchEnded   = "match_ended"
+	WebhookPlayerBanned = "player_banned"
+	WebhookServerFull   = "server_full"
+)
+
+var webhookEvents = map[string]bool{
+	WebhookMatchStarted: true,
+	WebhookMatchEnded:   true,
+	WebhookPlayerBanned: true,
+	WebhookServerFull:   true,
+}
+
+const (
+	defaultWebhookQueueSize   = 1000
+	defaultWebhookMaxAttempts = 5
+	defaultWebhookTimeout     = 5 * time.Second
+	webhookRetryBase          = time.Second
+	webhookRetryMax           = time.Minute
+	// webhookFullCooldown keeps a full server from sending server_full for every
+	// connection it turns away
+	webhookFullCooldown = time.Minute
+	webhookMaxSkew      = 5 * time.Minute
+	webhookSigHdr       = "X-Webhook-Signature"
+	webhookTimeHdr      = "X-Webhook-Timestamp"
+	webhookIDHdr        = "X-Webhook-ID"
+)
+
+// Webhook is an endpoint game events are posted to
+type Webhook struct {
+	URL string `json:"url" yaml:"url"`
+	// Secret signs the deliveries, unsigned when empty
+	Secret string `json:"secret" yaml:"secret"`
+	// Events are the events to post, all of them when empty
+	Events []string `json:"events" yaml:"events"`
+}
+
+func (h Webhook) validate() error {
+	if !strings.HasPrefix(h.URL, "http://") && !strings.HasPrefix(h.URL, "https://") {
+		return fmt.Errorf("webhook url must be http or https, got %q", h.URL)
+	}
+	for _, e := range h.Events {
+		if !webhookEvents[e] {
+			return fmt.Errorf("unknown webhook event %q", e)
+		}
+	}
+	return nil
+}
+
+// WebhookConfig configures the webhook dispatcher, zero values get the defaults
+type WebhookConfig struct {
+	Hooks []Webhook
+	// QueueSize is the number of deliveries waiting per webhook, 1000 when 0
+	QueueSize int
+	// MaxAttempts is how often a delivery is tried, 5 when 0
+	MaxAttempts int
+	// Timeout limits every attempt, 5 seconds when 0
+	Timeout time.Duration
+}
+
+// webhookDelivery is one event on its way, the body is the same for every attempt
+type webhookDelivery struct {
+	id    string
+	event string
+	body  []byte
+}
+
+type webhookTarget struct {
+	Webhook
+	events map[string]bool
+	queue  chan webhookDelivery
+}
+
+type webhooks struct {
+	cfg     WebhookConfig
+	client  *http.Client
+	targets []*webhookTarget
+	// lastFull is when server_full was last sent, in unix nanoseconds
+	lastFull atomic.Int64
+}
+
+func newWebhooks(cfg WebhookConfig) *webhooks {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultWebhookQueueSize
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultWebhookMaxAttempts
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultWebhookTimeout
+	}
+	w := &webhooks{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}}
+	for _, hook := range cfg.Hooks {
+		if err := hook.validate(); err != nil {
+			panic(err.Error())
+		}
+		t := &webhookTarget{Webhook: hook, queue: make(chan webhookDelivery, cfg.QueueSize)}
+		if len(hook.Events) > 0 {
+			t.events = make(map[string]bool, len(hook.Events))
+			for _, e := range hook.Events {
+				t.events[e] = true
+			}
+		}
+		w.targets = append(w.targets, t)
+	}
+	return w
+}
+
+// startWebhooks subscribes the dispatcher to the events and starts one worker per webhook,
+// they run for the life of the server
+func (gs *GameServer) startWebhooks() {
+	w := gs.webhooks
+	Subscribe(gs.events, func(e MatchStarted) {
+		ids := make([]string, len(e.Players))
+		for i, p := range e.Players {
+			ids[i] = p.ID
+		}
+		w.post(WebhookMatchStarted, map[string]interface{}{
+			"room_id": e.Room.ID,
+			"mode":    e.Room.Mode(),
+			"players": ids,
+		})
+	})
+	Subscribe(gs.events, func(e MatchEnded) {
+		w.post(WebhookMatchEnded, map[string]interface{}{
+			"room_id":   e.Result.RoomID,
+			"mode":      e.Room.Mode(),
+			"reason":    e.Result.Reason,
+			"closed_at": e.Result.ClosedAt,
+			"results":   e.Result.Results,
+		})
+	})
+	Subscribe(gs.events, func(e PlayerBanned) {
+		w.post(WebhookPlayerBanned, e.Ban)
+	})
+	Subscribe(gs.events, func(e ServerFull) {
+		now := time.Now().UnixNano()
+		last := w.lastFull.Load()
+		if now-last < int64(webhookFullCooldown) || !w.lastFull.CompareAndSwap(last, now) {
+			return
+		}
+		gs.playersMu.RLock()
+		players, maxPlayers := gs.players.len()-gs.spectators, gs.maxPlayers
+		gs.playersMu.RUnlock()
+		w.post(WebhookServerFull, map[string]interface{}{
+			"players":     players,
+			"max_players": maxPlayers,
+			"queued":      e.Queued,
+		})
+	})
+
+	for _, t := range w.targets {
+		go w.deliver(t)
+	}
+}
+
+// post queues the event for every webhook that wants it, without blocking the publisher
+func (w *webhooks) post(event string, data interface{}) {
+	id := generateUniqueID()
+	body, err := json.Marshal(map[string]interface{}{
+		"id":        id,
+		"event":     event,
+		"timestamp": time.Now().UnixMilli(),
+		"data":      data,
+	})
+	if err != nil {
+		log.Printf("Error encoding %s webhook: %v", event, err)
+		return
+	}
+
+	for _, t := range w.targets {
+		if t.events != nil && !t.events[event] {
+			continue
+		}
+		select {
+		case t.queue <- webhookDelivery{id: id, event: event, body: body}:
+		default:
+			log.Printf("Webhook queue of %s is full, dropping %s event %s", t.URL, event, id)
+		}
+	}
+}
+
+// deliver sends the queued deliveries of one webhook in order
+func (w *webhooks) deliver(t *webhookTarget) {
+	for d := range t.queue {
+		backoff := webhookRetryBase
+		for attempt := 1; ; attempt++ {
+			retry, err := w.send(t, d)
+			if err == nil {
+				break
+			}
+			if !retry || attempt >= w.cfg.MaxAttempts {
+				log.Printf("Giving up on %s webhook %s to %s after %d attempts: %v", d.event, d.id, t.URL, attempt, err)
+				break
+			}
+			time.Sleep(backoff)
+			backoff = min(2*backoff, webhookRetryMax)
+		}
+	}
+}
+
+// send makes one attempt, retry tells whether another one could succeed
+func (w *webhooks) send(t *webhookTarget, d webhookDelivery) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, t.URL, bytes.NewReader(d.body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookIDHdr, d.id)
+	if t.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(webhookTimeHdr, timestamp)
+		req.Header.Set(webhookSigHdr, hex.EncodeToString(webhookMAC([]byte(t.Secret), timestamp, d.body)))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("status %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("status %d", resp.StatusCode)
+	}
+}
+
+func webhookMAC(secret []byte, timestamp string, body []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(timestamp + "\n"))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// VerifyWebhook checks the signature and freshness of a delivery, body is the request body.
+// Remembering the X-Webhook-ID of accepted deliveries is up to the receiver.
+func VerifyWebhook(r *http.Request, body, secret []byte) error {
+	timestamp := r.Header.Get(webhookTimeHdr)
+	given, err := hex.DecodeString(r.Header.Get(webhookSigHdr))
+	if err != nil || !hmac.Equal(given, webhookMAC(secret, timestamp, body)) {
+		return fmt.Errorf("bad signature")
+	}
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("bad timestamp")
+	}
+	if skew := time.Since(time.Unix(sec, 0)); skew > webhookMaxSkew || skew < -webhookMaxSkew {
+		return fmt.Errorf("timestamp outside of the %v window", webhookMaxSkew)
+	}
+	return nil
+}
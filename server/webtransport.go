@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/quic-go/webtransport-go"
+)
+
+// WebTransport runs the same protocol as /ws over HTTP/3. The client opens one bidirectional
+// stream right after the session is up and frames messages on it like every stream transport,
+// see transport.go. Datagrams carry [1 byte message type][data] and are read like any other
+// frame, the server only sends them through SendUnreliable.
+
+// webTransportStreamTimeout is how long a fresh session gets to open its stream
+const webTransportStreamTimeout = 10 * time.Second
+
+// webTransportConn adapts a WebTransport session to the transport interface
+type webTransportConn struct {
+	sess   *webtransport.Session
+	stream *webtransport.Stream
+
+	// frames from the stream and from datagrams, in arrival order
+	queue *frameQueue
+
+	closeOnce sync.Once
+}
+
+func newWebTransportConn(sess *webtransport.Session, stream *webtransport.Stream) *webTransportConn {
+	c := &webTransportConn{
+		sess:   sess,
+		stream: stream,
+		queue:  newFrameQueue(),
+	}
+	go c.readStream()
+	go c.readDatagrams()
+	return c
+}
+
+func (c *webTransportConn) readStream() {
+	ctx := c.sess.Context()
+	for {
+		messageType, data, err := readStreamFrame(c.stream)
+		if err != nil {
+			if errors.Is(err, errFrameTooLarge) {
+				c.Close(websocket.CloseMessageTooBig, "frame too large")
+			}
+			c.queue.finish(err)
+			return
+		}
+		if !c.queue.push(messageType, data, ctx.Done()) {
+			c.queue.finish(context.Cause(ctx))
+			return
+		}
+	}
+}
+
+func (c *webTransportConn) readDatagrams() {
+	ctx := c.sess.Context()
+	for {
+		msg, err := c.sess.ReceiveDatagram(ctx)
+		if err != nil {
+			return
+		}
+		if len(msg) < 2 {
+			continue
+		}
+		if !c.queue.push(int(msg[0]), msg[1:], ctx.Done()) {
+			return
+		}
+	}
+}
+
+func (c *webTransportConn) ReadFrame(deadline time.Time) (int, []byte, error) {
+	return c.queue.read(deadline)
+}
+
+func (c *webTransportConn) WriteFrame(messageType int, data []byte, deadline time.Time) error {
+	buf, err := encodeStreamFrame(messageType, data)
+	if err != nil {
+		return err
+	}
+	c.stream.SetWriteDeadline(deadline)
+	_, err = c.stream.Write(buf)
+	return err
+}
+
+func (c *webTransportConn) SendDatagram(messageType int, data []byte) error {
+	buf := make([]byte, 1+len(data))
+	buf[0] = byte(messageType)
+	copy(buf[1:], data)
+	return c.sess.SendDatagram(buf)
+}
+
+func (c *webTransportConn) RemoteAddr() net.Addr {
+	return c.sess.RemoteAddr()
+}
+
+// Close maps the websocket close code onto the session error code
+func (c *webTransportConn) Close(code int, reason string) error {
+	var err error
+	c.closeOnce.Do(func() {
+		err = c.sess.CloseWithError(webtransport.SessionErrorCode(code), reason)
+	})
+	return err
+}
+
+// StartWebTransport serves /wt over HTTP/3 next to the websocket listener, QUIC needs TLS
+// so there is no plaintext variant. Shutdown stops it.
+func (gs *GameServer) StartWebTransport(addr, certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("loading WebTransport certificate: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/wt", gs.handleWebTransport)
+	srv := &webtransport.Server{
+		H3: http3.Server{
+			Addr:      addr,
+			Handler:   mux,
+			TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS13},
+		},
+		CheckOrigin: gs.checkOrigin,
+	}
+
+	gs.serversMu.Lock()
+	gs.webTransport = srv
+	gs.serversMu.Unlock()
+
+	log.Printf("WebTransport server starting on %s", addr)
+	return serveResult(srv.ListenAndServe())
+}
+
+func (gs *GameServer) handleWebTransport(w http.ResponseWriter, r *http.Request) {
+	gs.serversMu.Lock()
+	srv := gs.webTransport
+	gs.serversMu.Unlock()
+	if srv == nil {
+		http.Error(w, "WebTransport not enabled", http.StatusNotFound)
+		return
+	}
+
+	ip, ok := gs.acquireIP(w, r)
+	if !ok {
+		return
+	}
+	if !gs.checkBansBeforeUpgrade(w, r, ip) {
+		gs.ipLimit.release(ip)
+		return
+	}
+
+	sess, err := srv.Upgrade(w, r)
+	if err != nil {
+		log.Printf("WebTransport upgrade error: %v", err)
+		gs.ipLimit.release(ip)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), webTransportStreamTimeout)
+	stream, err := sess.AcceptStream(ctx)
+	cancel()
+	if err != nil {
+		log.Printf("WebTransport stream error: %v", err)
+		sess.CloseWithError(webtransport.SessionErrorCode(websocket.CloseProtocolError), "no stream")
+		gs.ipLimit.release(ip)
+		return
+	}
+
+	player := gs.newPlayer(newWebTransportConn(sess, stream), gs.slotClassifier(r))
+	gs.admit(player, r, ip)
+}
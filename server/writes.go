@@ -0,0 +1,37 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net"
+	"time"
+)
+
+// How long a single frame may take to write before the connection is considered stuck
+const defaultWriteTimeout = 10 * time.Second
+
+// isTimeout reports whether a write failed because its deadline passed
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// handleWriteError classifies a failed write and applies the backpressure policy
+// A timed out write leaves the websocket in a corrupt state (gorilla/websocket fails every
+// write after that), so the only sane escalation is closing the connection: the read loop
+// then errors out and unregisters the player, freeing the slot and its buffers.
+func (p *Player) handleWriteError(err error) {
+	if !isTimeout(err) {
+		return
+	}
+
+	p.metrics.writeTimeouts.add(p.metricShard, 1)
+	if p.writeTimedOut.Swap(true) {
+		// Already escalated
+		return
+	}
+
+	log.Printf("Write to player %s timed out after %v, closing connection", p.ID, p.writeTimeout)
+	p.setDisconnectReason(ReasonSlowConsumer.WithDetail("write timeout"))
+	p.closeConn()
+}
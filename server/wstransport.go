@@ -0,0 +1,107 @@
+package server
+
+import (
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsTransport is the Transport of players connected to /ws and /spectate
+type wsTransport struct {
+	conn *websocket.Conn
+	// compressThreshold is the smallest frame worth deflating, 0 when compression is off
+	compressThreshold int
+
+	closeOnce sync.Once
+}
+
+func newWSTransport(conn *websocket.Conn) *wsTransport {
+	return &wsTransport{conn: conn}
+}
+
+func (t *wsTransport) ReadFrame(deadline time.Time) (int, []byte, error) {
+	t.conn.SetReadDeadline(deadline)
+	return t.conn.ReadMessage()
+}
+
+func (t *wsTransport) WriteFrame(messageType int, data []byte, deadline time.Time) error {
+	if t.compressThreshold > 0 {
+		// Small frames compress badly and cost CPU, only deflate the big ones
+		t.conn.EnableWriteCompression(len(data) >= t.compressThreshold)
+	}
+	t.conn.SetWriteDeadline(deadline)
+	return t.conn.WriteMessage(messageType, data)
+}
+
+// writeShared sends the pre-encoded frame, deflated by the rules of WriteFrame
+func (t *wsTransport) writeShared(f *sharedFrame, deadline time.Time) error {
+	pm, err := f.prepared()
+	if err != nil {
+		return err
+	}
+	if t.compressThreshold > 0 {
+		t.conn.EnableWriteCompression(len(f.data) >= t.compressThreshold)
+	}
+	t.conn.SetWriteDeadline(deadline)
+	return t.conn.WritePreparedMessage(pm)
+}
+
+func (t *wsTransport) Close(code int, reason string) error {
+	var err error
+	t.closeOnce.Do(func() {
+		if code != websocket.CloseNormalClosure || reason != "" {
+			// WriteControl is safe next to other writers
+			msg := websocket.FormatCloseMessage(code, reason)
+			t.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		}
+		err = t.conn.Close()
+	})
+	return err
+}
+
+func (t *wsTransport) RemoteAddr() net.Addr {
+	return t.conn.RemoteAddr()
+}
+
+// newWebSocketPlayer creates the player of an upgraded websocket
+func (gs *GameServer) newWebSocketPlayer(conn *websocket.Conn, class SlotClass) *Player {
+	player := gs.newPlayer(newWSTransport(conn), class)
+	player.Conn = conn
+	return player
+}
+
+// sharedFrame is a frame going out unchanged to many players. Websocket framing and
+// compression happen once for all of them instead of once per recipient, other transports
+// get the raw bytes. Structured messages can't share: every copy carries the recipient's
+// player_id and sseq.
+type sharedFrame struct {
+	messageType int
+	data        []byte
+
+	once sync.Once
+	pm   *websocket.PreparedMessage
+	err  error
+}
+
+// prepared encodes the frame on first use, a broadcast without websocket recipients never does
+func (f *sharedFrame) prepared() (*websocket.PreparedMessage, error) {
+	f.once.Do(func() {
+		f.pm, f.err = websocket.NewPreparedMessage(f.messageType, f.data)
+	})
+	return f.pm, f.err
+}
+
+// broadcastShared writes one frame to all the players, failures are logged
+func (gs *GameServer) broadcastShared(players []*Player, messageType int, data []byte) {
+	f := &sharedFrame{messageType: messageType, data: data}
+	gs.fanOut(players, func(player *Player) error {
+		err := player.writeShared(f)
+		if err != nil {
+			log.Printf("Error broadcasting to player %s: %v", player.ID, err)
+		}
+		return err
+	}, "broadcast")
+}